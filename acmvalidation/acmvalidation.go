@@ -0,0 +1,150 @@
+// Package acmvalidation checks the DNS validation of ACM certificates against the validation
+// records in Route 53
+package acmvalidation
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"cfn-root-cause/analyzer"
+	"cfn-root-cause/awserrors"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/arn"
+	"github.com/aws/aws-sdk-go-v2/service/acm"
+	acmtypes "github.com/aws/aws-sdk-go-v2/service/acm/types"
+	"github.com/aws/aws-sdk-go-v2/service/route53"
+	r53types "github.com/aws/aws-sdk-go-v2/service/route53/types"
+)
+
+// Client wraps the ACM and Route 53 clients
+type Client struct {
+	acm     *acm.Client
+	route53 *route53.Client
+
+	// zones are the public hosted zones of the account, listed once
+	zones []r53types.HostedZone
+}
+
+// NewClientWithConfig creates a new client with a custom AWS config
+func NewClientWithConfig(cfg aws.Config) *Client {
+	return &Client{
+		acm:     acm.NewFromConfig(cfg),
+		route53: route53.NewFromConfig(cfg),
+	}
+}
+
+// Validations returns the DNS validations of the domains of a certificate; nil if the certificate
+// no longer exists, as after the rollback of its creation
+func (c *Client) Validations(ctx context.Context, certificateArn string) ([]analyzer.DNSValidation, error) {
+	var optFns []func(*acm.Options)
+	if parsed, err := arn.Parse(certificateArn); err == nil && parsed.Region != "" {
+		optFns = append(optFns, func(o *acm.Options) { o.Region = parsed.Region })
+	}
+
+	output, err := c.acm.DescribeCertificate(ctx, &acm.DescribeCertificateInput{CertificateArn: aws.String(certificateArn)}, optFns...)
+	if err != nil {
+		var notFound *acmtypes.ResourceNotFoundException
+		if errors.As(err, &notFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to describe certificate %s: %w", certificateArn, awserrors.ParseAWSError(err, "ACM"))
+	}
+
+	var validations []analyzer.DNSValidation
+	for _, option := range output.Certificate.DomainValidationOptions {
+		if option.ValidationMethod != acmtypes.ValidationMethodDns || option.ResourceRecord == nil {
+			continue
+		}
+		validations = append(validations, analyzer.DNSValidation{
+			DomainName:  aws.ToString(option.DomainName),
+			RecordName:  aws.ToString(option.ResourceRecord.Name),
+			RecordType:  string(option.ResourceRecord.Type),
+			RecordValue: aws.ToString(option.ResourceRecord.Value),
+			Status:      string(option.ValidationStatus),
+		})
+	}
+	return validations, nil
+}
+
+// CheckRecord looks up the validation record in the public hosted zone of the account that is
+// responsible for its name, and sets HostedZone and RecordValues of the validation
+func (c *Client) CheckRecord(ctx context.Context, validation *analyzer.DNSValidation) error {
+	zone, err := c.zoneOf(ctx, validation.RecordName)
+	if err != nil || zone == nil {
+		return err
+	}
+	validation.HostedZone = strings.TrimSuffix(aws.ToString(zone.Name), ".")
+
+	recordType := r53types.RRType(validation.RecordType)
+	if recordType == "" {
+		recordType = r53types.RRTypeCname
+	}
+	output, err := c.route53.ListResourceRecordSets(ctx, &route53.ListResourceRecordSetsInput{
+		HostedZoneId:    zone.Id,
+		StartRecordName: aws.String(validation.RecordName),
+		StartRecordType: recordType,
+		MaxItems:        aws.Int32(1),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to list the records of hosted zone %s: %w", validation.HostedZone,
+			awserrors.ParseAWSError(err, "Route 53"))
+	}
+
+	validation.RecordValues = nil
+	for _, record := range output.ResourceRecordSets {
+		if !sameName(aws.ToString(record.Name), validation.RecordName) || record.Type != recordType {
+			continue
+		}
+		for _, value := range record.ResourceRecords {
+			validation.RecordValues = append(validation.RecordValues, aws.ToString(value.Value))
+		}
+	}
+	return nil
+}
+
+// zoneOf returns the public hosted zone with the longest name that contains the record name, nil
+// if the account has none
+func (c *Client) zoneOf(ctx context.Context, recordName string) (*r53types.HostedZone, error) {
+	if c.zones == nil {
+		paginator := route53.NewListHostedZonesPaginator(c.route53, &route53.ListHostedZonesInput{})
+		zones := []r53types.HostedZone{}
+		for paginator.HasMorePages() {
+			page, err := paginator.NextPage(ctx)
+			if err != nil {
+				return nil, fmt.Errorf("failed to list Route 53 hosted zones: %w", awserrors.ParseAWSError(err, "Route 53"))
+			}
+			for _, zone := range page.HostedZones {
+				if zone.Config == nil || !zone.Config.PrivateZone {
+					zones = append(zones, zone)
+				}
+			}
+		}
+		c.zones = zones
+	}
+
+	name := normalizeName(recordName)
+	var best *r53types.HostedZone
+	for i, zone := range c.zones {
+		zoneName := normalizeName(aws.ToString(zone.Name))
+		if name != zoneName && !strings.HasSuffix(name, "."+zoneName) {
+			continue
+		}
+		if best == nil || len(zoneName) > len(normalizeName(aws.ToString(best.Name))) {
+			best = &c.zones[i]
+		}
+	}
+	return best, nil
+}
+
+// sameName reports whether two DNS names are equal, ignoring case and the trailing dot
+func sameName(a, b string) bool {
+	return normalizeName(a) == normalizeName(b)
+}
+
+// normalizeName returns a DNS name in lower case without the trailing dot
+func normalizeName(name string) string {
+	return strings.TrimSuffix(strings.ToLower(name), ".")
+}
@@ -0,0 +1,98 @@
+// Package aggregate summarizes the analyses of several stacks
+package aggregate
+
+import (
+	"sort"
+
+	"cfn-root-cause/analyzer"
+	"cfn-root-cause/classify"
+)
+
+// MaxErrorCodes limits the number of error codes listed in a summary
+const MaxErrorCodes = 10
+
+// Count is the number of errors sharing a name, such as a category or an error code
+type Count struct {
+	Name  string
+	Count int
+}
+
+// Summary contains the aggregated counts of several stack analyses
+type Summary struct {
+	TotalStacks      int
+	StacksWithErrors int
+	TotalErrors      int
+	Ignored          int
+
+	// Categories counts errors by failure category, most frequent first
+	Categories []Count
+
+	// ErrorCodes counts errors by error code, most frequent first, at most MaxErrorCodes entries
+	ErrorCodes []Count
+
+	// Services counts errors by the service of the failed resource, most frequent first
+	Services []Count
+}
+
+// Summarize aggregates the given stack analyses
+func Summarize(stacks []*analyzer.StackAnalysis) Summary {
+	summary := Summary{TotalStacks: len(stacks)}
+	categories := make(map[string]int)
+	errorCodes := make(map[string]int)
+	services := make(map[string]int)
+
+	for _, stack := range stacks {
+		if len(stack.Errors) > 0 {
+			summary.StacksWithErrors++
+		}
+		summary.TotalErrors += len(stack.Errors)
+		summary.Ignored += len(stack.Ignored)
+
+		for _, err := range stack.Errors {
+			categories[classify.Category(err)]++
+			if code := classify.ErrorCode(err); code != "" {
+				errorCodes[code]++
+			}
+			if service := classify.Service(err); service != "" {
+				services[service]++
+			}
+		}
+	}
+
+	summary.Categories = sortedCounts(categories)
+	summary.Services = sortedCounts(services)
+	summary.ErrorCodes = sortedCounts(errorCodes)
+	if len(summary.ErrorCodes) > MaxErrorCodes {
+		summary.ErrorCodes = summary.ErrorCodes[:MaxErrorCodes]
+	}
+
+	return summary
+}
+
+// ByService counts the errors of one stack by the service of the failed resource, most frequent first
+func ByService(errors []analyzer.CorrelatedError) []Count {
+	services := make(map[string]int)
+	for _, err := range errors {
+		if service := classify.Service(err); service != "" {
+			services[service]++
+		}
+	}
+	return sortedCounts(services)
+}
+
+// sortedCounts converts counts to a slice ordered by count, then name
+func sortedCounts(counts map[string]int) []Count {
+	result := make([]Count, 0, len(counts))
+	for name, count := range counts {
+		result = append(result, Count{Name: name, Count: count})
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Count != result[j].Count {
+			return result[i].Count > result[j].Count
+		}
+		return result[i].Name < result[j].Name
+	})
+
+	return result
+}
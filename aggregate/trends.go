@@ -0,0 +1,146 @@
+package aggregate
+
+import (
+	"sort"
+	"time"
+
+	"cfn-root-cause/history"
+)
+
+// Trends contains the error frequencies across the analysis history
+type Trends struct {
+	// Analyses is the number of recorded analyses in the period
+	Analyses int
+
+	// TotalErrors is the number of distinct errors in the period
+	TotalErrors int
+
+	// ByStack, ByResourceType and ByErrorCode count errors, most frequent first
+	ByStack        []Count
+	ByResourceType []Count
+	ByErrorCode    []Count
+
+	// Weekly counts errors per week, oldest first
+	Weekly []Period
+
+	// Operations is the number of distinct stack operations with a health score, and HealthScore
+	// their average score
+	Operations  int
+	HealthScore int
+}
+
+// Period is the number of errors in a time period
+type Period struct {
+	Start        time.Time
+	Errors       int
+	TopErrorCode string
+
+	// HealthScore is the average health score of the operations analyzed in the period, -1 if there is none
+	HealthScore int
+}
+
+// SummarizeHistory counts the errors of the history records that occurred at or after since, and
+// averages the health scores of the operations analyzed since then by the week of their analysis.
+// Stacks are often analyzed repeatedly after the same failure, so each stack event and each
+// operation is counted once.
+func SummarizeHistory(records []history.Record, since time.Time) Trends {
+	var trends Trends
+	byStack := make(map[string]int)
+	byResourceType := make(map[string]int)
+	byErrorCode := make(map[string]int)
+	weekly := make(map[time.Time]map[string]int)
+	seen := make(map[string]bool)
+	weeklyScores := make(map[time.Time][]int)
+	seenOperations := make(map[string]bool)
+	totalScore := 0
+
+	for _, record := range records {
+		if record.Time.Before(since) {
+			continue
+		}
+		trends.Analyses++
+
+		if record.HealthScore != nil {
+			key := record.StackName + "\x00" + record.Operation
+			if record.Operation == "" || !seenOperations[key] {
+				seenOperations[key] = true
+				trends.Operations++
+				totalScore += *record.HealthScore
+				week := weekStart(record.Time)
+				weeklyScores[week] = append(weeklyScores[week], *record.HealthScore)
+				if weekly[week] == nil {
+					weekly[week] = make(map[string]int)
+				}
+			}
+		}
+
+		for _, entry := range record.Errors {
+			if entry.Timestamp.Before(since) {
+				continue
+			}
+			key := record.StackName + "\x00" + entry.EventId
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+
+			trends.TotalErrors++
+			byStack[record.StackName]++
+			byResourceType[entry.ResourceType]++
+			if entry.ErrorCode != "" {
+				byErrorCode[entry.ErrorCode]++
+			}
+
+			week := weekStart(entry.Timestamp)
+			if weekly[week] == nil {
+				weekly[week] = make(map[string]int)
+			}
+			weekly[week][entry.ErrorCode]++
+		}
+	}
+
+	trends.ByStack = sortedCounts(byStack)
+	trends.ByResourceType = sortedCounts(byResourceType)
+	trends.ByErrorCode = sortedCounts(byErrorCode)
+
+	if trends.Operations > 0 {
+		trends.HealthScore = totalScore / trends.Operations
+	}
+
+	for week, codes := range weekly {
+		period := Period{Start: week, HealthScore: average(weeklyScores[week])}
+		for _, count := range codes {
+			period.Errors += count
+		}
+		delete(codes, "")
+		if top := sortedCounts(codes); len(top) > 0 {
+			period.TopErrorCode = top[0].Name
+		}
+		trends.Weekly = append(trends.Weekly, period)
+	}
+	sort.Slice(trends.Weekly, func(i, j int) bool {
+		return trends.Weekly[i].Start.Before(trends.Weekly[j].Start)
+	})
+
+	return trends
+}
+
+// weekStart returns the start of the week (Monday 00:00 UTC) containing t
+func weekStart(t time.Time) time.Time {
+	t = t.UTC()
+	daysSinceMonday := (int(t.Weekday()) + 6) % 7
+	year, month, day := t.AddDate(0, 0, -daysSinceMonday).Date()
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
+
+// average returns the average of the scores, rounded down, or -1 if there are none
+func average(scores []int) int {
+	if len(scores) == 0 {
+		return -1
+	}
+	total := 0
+	for _, score := range scores {
+		total += score
+	}
+	return total / len(scores)
+}
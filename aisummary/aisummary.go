@@ -0,0 +1,83 @@
+// Package aisummary summarizes analysis reports with a large language model. The model is
+// accessed through a Provider, so the provider an organization may send error data to can be
+// selected in the configuration file: Amazon Bedrock, an OpenAI-compatible endpoint or a local Ollama server.
+package aisummary
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"cfn-root-cause/settings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+// Names of the supported providers
+const (
+	ProviderBedrock = "bedrock"
+	ProviderOpenAI  = "openai"
+	ProviderOllama  = "ollama"
+)
+
+// DefaultMaxTokens limits the length of a summary if the configuration sets no limit
+const DefaultMaxTokens = 500
+
+// requestTimeout limits how long a summary request may take; local models can be slow
+const requestTimeout = 2 * time.Minute
+
+// Provider completes prompts with a language model
+type Provider interface {
+	// Name describes the provider and model, e.g. "bedrock (anthropic.claude-3-5-haiku-20241022-v1:0)"
+	Name() string
+
+	// Complete returns the answer of the model to the system instructions and the prompt
+	Complete(ctx context.Context, system, prompt string) (string, error)
+}
+
+// factory creates a provider from the configuration
+type factory func(cfg settings.AIConfig, awsCfg aws.Config) (Provider, error)
+
+// factories contains the supported providers by name
+var factories = map[string]factory{
+	ProviderBedrock: newBedrock,
+	ProviderOpenAI:  newOpenAI,
+	ProviderOllama:  newOllama,
+}
+
+// Providers returns the names of the supported providers
+func Providers() []string {
+	names := make([]string, 0, len(factories))
+	for name := range factories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// New creates the provider selected in the configuration. awsCfg is used by Bedrock only.
+func New(cfg settings.AIConfig, awsCfg aws.Config) (Provider, error) {
+	if cfg.Provider == "" {
+		return nil, fmt.Errorf("no AI provider configured: set ai.provider in the config file to one of %s",
+			strings.Join(Providers(), ", "))
+	}
+	create, ok := factories[cfg.Provider]
+	if !ok {
+		return nil, fmt.Errorf("unknown AI provider '%s': must be one of %s", cfg.Provider, strings.Join(Providers(), ", "))
+	}
+	if cfg.Model == "" {
+		return nil, fmt.Errorf("no model configured for AI provider '%s': set ai.model in the config file", cfg.Provider)
+	}
+	if cfg.MaxTokens == 0 {
+		cfg.MaxTokens = DefaultMaxTokens
+	}
+	return create(cfg, awsCfg)
+}
+
+// newHTTPClient creates the HTTP client of the HTTP-based providers
+func newHTTPClient() *http.Client {
+	return &http.Client{Timeout: requestTimeout}
+}
@@ -0,0 +1,65 @@
+package aisummary
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"cfn-root-cause/awserrors"
+	"cfn-root-cause/settings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime/types"
+)
+
+// bedrock completes prompts with the Converse API of Amazon Bedrock, so the error data stays in the AWS account
+type bedrock struct {
+	client    *bedrockruntime.Client
+	model     string
+	maxTokens int
+}
+
+// newBedrock creates a Bedrock provider using the AWS credentials of the analysis
+func newBedrock(cfg settings.AIConfig, awsCfg aws.Config) (Provider, error) {
+	if cfg.Region != "" {
+		awsCfg = awsCfg.Copy()
+		awsCfg.Region = cfg.Region
+	}
+	return &bedrock{
+		client:    bedrockruntime.NewFromConfig(awsCfg),
+		model:     cfg.Model,
+		maxTokens: cfg.MaxTokens,
+	}, nil
+}
+
+func (b *bedrock) Name() string {
+	return fmt.Sprintf("%s (%s)", ProviderBedrock, b.model)
+}
+
+func (b *bedrock) Complete(ctx context.Context, system, prompt string) (string, error) {
+	output, err := b.client.Converse(ctx, &bedrockruntime.ConverseInput{
+		ModelId: aws.String(b.model),
+		System:  []types.SystemContentBlock{&types.SystemContentBlockMemberText{Value: system}},
+		Messages: []types.Message{{
+			Role:    types.ConversationRoleUser,
+			Content: []types.ContentBlock{&types.ContentBlockMemberText{Value: prompt}},
+		}},
+		InferenceConfig: &types.InferenceConfiguration{MaxTokens: aws.Int32(int32(b.maxTokens))},
+	})
+	if err != nil {
+		return "", awserrors.ParseAWSError(err, "Bedrock")
+	}
+
+	message, ok := output.Output.(*types.ConverseOutputMemberMessage)
+	if !ok {
+		return "", fmt.Errorf("bedrock returned no message")
+	}
+	var sb strings.Builder
+	for _, block := range message.Value.Content {
+		if text, ok := block.(*types.ContentBlockMemberText); ok {
+			sb.WriteString(text.Value)
+		}
+	}
+	return strings.TrimSpace(sb.String()), nil
+}
@@ -0,0 +1,70 @@
+package aisummary
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"cfn-root-cause/settings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+// defaultOllamaEndpoint is the address of a local Ollama server
+const defaultOllamaEndpoint = "http://localhost:11434"
+
+// ollama completes prompts with the chat API of an Ollama server, so the error data never leaves the machine
+type ollama struct {
+	http      *http.Client
+	endpoint  string
+	model     string
+	maxTokens int
+}
+
+// newOllama creates an Ollama provider
+func newOllama(cfg settings.AIConfig, _ aws.Config) (Provider, error) {
+	endpoint := cfg.Endpoint
+	if endpoint == "" {
+		endpoint = defaultOllamaEndpoint
+	}
+	return &ollama{
+		http:      newHTTPClient(),
+		endpoint:  strings.TrimSuffix(endpoint, "/"),
+		model:     cfg.Model,
+		maxTokens: cfg.MaxTokens,
+	}, nil
+}
+
+// ollamaRequest is the body of a chat request
+type ollamaRequest struct {
+	Model    string        `json:"model"`
+	Messages []chatMessage `json:"messages"`
+	Stream   bool          `json:"stream"`
+	Options  struct {
+		NumPredict int `json:"num_predict"`
+	} `json:"options"`
+}
+
+// ollamaResponse is the part of a chat response the provider reads
+type ollamaResponse struct {
+	Message chatMessage `json:"message"`
+}
+
+func (o *ollama) Name() string {
+	return fmt.Sprintf("%s (%s)", ProviderOllama, o.model)
+}
+
+func (o *ollama) Complete(ctx context.Context, system, prompt string) (string, error) {
+	body := ollamaRequest{
+		Model:    o.model,
+		Messages: []chatMessage{{Role: "system", Content: system}, {Role: "user", Content: prompt}},
+	}
+	body.Options.NumPredict = o.maxTokens
+
+	var response ollamaResponse
+	if err := postJSON(ctx, o.http, o.endpoint+"/api/chat", nil, body, &response); err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(response.Message.Content), nil
+}
@@ -0,0 +1,134 @@
+package aisummary
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+
+	"cfn-root-cause/settings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+// defaultOpenAIEndpoint is the base URL used if the configuration sets no endpoint
+const defaultOpenAIEndpoint = "https://api.openai.com/v1"
+
+// defaultAPIKeyEnv is the environment variable the API key is read from by default
+const defaultAPIKeyEnv = "OPENAI_API_KEY"
+
+// openAI completes prompts with the chat completions API of OpenAI and compatible servers,
+// such as Azure OpenAI, vLLM or LiteLLM proxies
+type openAI struct {
+	http      *http.Client
+	endpoint  string
+	apiKey    string
+	model     string
+	maxTokens int
+}
+
+// newOpenAI creates an OpenAI-compatible provider. The API key is optional, since self-hosted
+// servers often do not require one.
+func newOpenAI(cfg settings.AIConfig, _ aws.Config) (Provider, error) {
+	endpoint := cfg.Endpoint
+	if endpoint == "" {
+		endpoint = defaultOpenAIEndpoint
+	}
+	apiKeyEnv := cfg.APIKeyEnv
+	if apiKeyEnv == "" {
+		apiKeyEnv = defaultAPIKeyEnv
+	}
+	apiKey := os.Getenv(apiKeyEnv)
+	if apiKey == "" && endpoint == defaultOpenAIEndpoint {
+		return nil, fmt.Errorf("no API key for AI provider '%s': set $%s", ProviderOpenAI, apiKeyEnv)
+	}
+
+	return &openAI{
+		http:      newHTTPClient(),
+		endpoint:  strings.TrimSuffix(endpoint, "/"),
+		apiKey:    apiKey,
+		model:     cfg.Model,
+		maxTokens: cfg.MaxTokens,
+	}, nil
+}
+
+// chatMessage is a message of a chat request, shared by the OpenAI and Ollama APIs
+type chatMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+// openAIRequest is the body of a chat completions request
+type openAIRequest struct {
+	Model     string        `json:"model"`
+	Messages  []chatMessage `json:"messages"`
+	MaxTokens int           `json:"max_tokens"`
+}
+
+// openAIResponse is the part of a chat completions response the provider reads
+type openAIResponse struct {
+	Choices []struct {
+		Message chatMessage `json:"message"`
+	} `json:"choices"`
+}
+
+func (o *openAI) Name() string {
+	return fmt.Sprintf("%s (%s)", ProviderOpenAI, o.model)
+}
+
+func (o *openAI) Complete(ctx context.Context, system, prompt string) (string, error) {
+	body := openAIRequest{
+		Model:     o.model,
+		Messages:  []chatMessage{{Role: "system", Content: system}, {Role: "user", Content: prompt}},
+		MaxTokens: o.maxTokens,
+	}
+	headers := map[string]string{}
+	if o.apiKey != "" {
+		headers["Authorization"] = "Bearer " + o.apiKey
+	}
+
+	var response openAIResponse
+	if err := postJSON(ctx, o.http, o.endpoint+"/chat/completions", headers, body, &response); err != nil {
+		return "", err
+	}
+	if len(response.Choices) == 0 {
+		return "", fmt.Errorf("%s returned no choices", o.endpoint)
+	}
+	return strings.TrimSpace(response.Choices[0].Message.Content), nil
+}
+
+// postJSON posts a JSON request and decodes the JSON response; any non-2xx response is an error
+func postJSON(ctx context.Context, client *http.Client, url string, headers map[string]string, request, response interface{}) error {
+	payload, err := json.Marshal(request)
+	if err != nil {
+		return fmt.Errorf("failed to encode request: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "cfn-analyzer")
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to reach %s: %w", url, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("%s returned %s: %s", url, resp.Status, bytes.TrimSpace(body))
+	}
+	if err := json.NewDecoder(resp.Body).Decode(response); err != nil {
+		return fmt.Errorf("failed to decode response of %s: %w", url, err)
+	}
+	return nil
+}
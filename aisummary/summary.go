@@ -0,0 +1,85 @@
+package aisummary
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"cfn-root-cause/analyzer"
+	"cfn-root-cause/classify"
+)
+
+// MaxPromptErrors limits the number of errors sent to the model
+const MaxPromptErrors = 20
+
+// maxPromptMessageLength limits the length of a single message sent to the model
+const maxPromptMessageLength = 1000
+
+// systemInstructions tell the model what kind of summary to write
+const systemInstructions = "You are an AWS CloudFormation expert. Summarize the root cause of the failed " +
+	"deployment described by the user in at most five sentences for an engineer who has to fix it. Name the " +
+	"resource that failed first and why, distinguish the root cause from resources that only failed as a " +
+	"consequence, and end with the most likely fix. Do not invent resources or errors that are not listed."
+
+// Summarize asks the provider for a plain-language summary of the root cause of an analysis.
+// Analyses without errors are not sent and yield "".
+func Summarize(ctx context.Context, provider Provider, analysis *analyzer.StackAnalysis) (string, error) {
+	if len(analysis.Errors) == 0 {
+		return "", nil
+	}
+
+	summary, err := provider.Complete(ctx, systemInstructions, Prompt(analysis))
+	if err != nil {
+		return "", fmt.Errorf("failed to summarize stack %s with %s: %w", analysis.StackName, provider.Name(), err)
+	}
+	return summary, nil
+}
+
+// Prompt describes the errors and findings of an analysis for the model, oldest error first
+func Prompt(analysis *analyzer.StackAnalysis) string {
+	var sb strings.Builder
+
+	sb.WriteString(fmt.Sprintf("Stack: %s\n\nErrors, oldest first:\n", analysis.StackName))
+	errors := append([]analyzer.CorrelatedError{}, analysis.Errors...)
+	sort.SliceStable(errors, func(i, j int) bool {
+		return errors[i].StackError.Timestamp.Before(errors[j].StackError.Timestamp)
+	})
+	if len(errors) > MaxPromptErrors {
+		errors = errors[:MaxPromptErrors]
+	}
+	for _, err := range errors {
+		stackErr := err.StackError
+		sb.WriteString(fmt.Sprintf("- %s %s (%s) %s [%s]: %s\n", stackErr.Timestamp.UTC().Format("15:04:05"),
+			stackErr.LogicalResourceId, stackErr.ResourceType, stackErr.ResourceStatus, classify.Category(err),
+			truncate(stackErr.ResourceStatusReason)))
+		if err.CloudTrailEvent != nil && err.CloudTrailEvent.ErrorCode != "" {
+			sb.WriteString(fmt.Sprintf("  API call %s:%s failed with %s\n", err.CloudTrailEvent.EventSource,
+				err.CloudTrailEvent.EventName, err.CloudTrailEvent.ErrorCode))
+		}
+		if err.DetailedMessage != "" && err.DetailedMessage != stackErr.ResourceStatusReason {
+			sb.WriteString("  Details: " + truncate(err.DetailedMessage) + "\n")
+		}
+	}
+	if len(analysis.Errors) > MaxPromptErrors {
+		sb.WriteString(fmt.Sprintf("- and %d more errors\n", len(analysis.Errors)-MaxPromptErrors))
+	}
+
+	if len(analysis.Findings) > 0 {
+		sb.WriteString("\nRecognized patterns:\n")
+		for _, finding := range analysis.Findings {
+			sb.WriteString(fmt.Sprintf("- %s: %s\n", finding.Title, truncate(finding.Explanation)))
+		}
+	}
+
+	return sb.String()
+}
+
+// truncate shortens long messages such as policy documents embedded in error messages
+func truncate(message string) string {
+	message = strings.Join(strings.Fields(message), " ")
+	if len(message) <= maxPromptMessageLength {
+		return message
+	}
+	return message[:maxPromptMessageLength] + "..."
+}
@@ -17,15 +17,416 @@ type StackError struct {
 	ResourceStatusReason      string
 	EventId                   string
 	IsGeneralServiceException bool
+
+	// PhysicalResourceId is the name or ARN of the resource in its service, "" if it was not created
+	PhysicalResourceId string
 }
 
 // StackAnalysis contains the complete analysis results for a stack
 type StackAnalysis struct {
 	StackName      string
+	StackId        string
 	AnalysisTime   time.Time
 	Errors         []CorrelatedError
 	GeneralErrors  int
 	DetailedErrors int
+	Findings       []Finding
+	Ignored        []IgnoredError
+	Identity       *CallerIdentity
+	TemplateDiff   *TemplateDiff
+	Stats          *AnalysisStats
+
+	// RelatedStacks are other stacks the failure depends on, such as the stack providing an export
+	// the failed deployment imports; they are analyzed as well
+	RelatedStacks []string
+
+	// ProvisionedProduct is set when the stack was analyzed as a Service Catalog provisioned product
+	ProvisionedProduct *ProvisionedProduct
+
+	// AISummary is the plain-language summary of the root cause written by an AI provider, if requested
+	AISummary string
+
+	// Partial is set when the analysis was interrupted before all CloudTrail lookups completed
+	Partial bool
+
+	// Metadata records where and how the stack was analyzed; nil if unknown
+	Metadata *AnalysisMetadata
+
+	// StackContext holds the parameters and outputs of the stack, if requested
+	StackContext *StackContext
+
+	// LastEventId is the ID of the newest stack event analyzed, set when the events were stored so
+	// the next analysis of the stack only fetches newer events
+	LastEventId string
+
+	// Warnings are the conditions that make the analysis less reliable, such as skipped CloudTrail
+	// lookups or truncated stack events
+	Warnings []Warning
+
+	// Health summarizes the stack events of the analyzed operation; nil if there were no events
+	Health *OperationHealth
+
+	// StackStatus is the status of the stack when it was analyzed; nil if unknown
+	StackStatus *StackStatus
+}
+
+// StackStatus is the status of a stack and whether an operation is still running
+type StackStatus struct {
+	Status string
+
+	// Reason explains the status, e.g. the resource failure that started a rollback
+	Reason string
+
+	// InProgress is set while an operation of the stack has not finished, so more errors may follow
+	InProgress bool
+}
+
+// OperationHealth summarizes the stack events of a stack operation
+type OperationHealth struct {
+	// Operation is the client request token of the operation, or the event ID of its start
+	Operation string
+
+	Events   int
+	Failures int
+
+	// Retries counts resources that started an action again after it completed or failed
+	Retries int
+
+	// Rollback is set if the operation rolled back
+	Rollback bool
+
+	// LongestResource is the resource that took longest, LongestDuration how long it took
+	LongestResource string
+	LongestDuration time.Duration
+
+	// Score rates the deployment from 0 to 100; operations without failures, retries or rollback score 100
+	Score int
+}
+
+// StackContext holds the inputs and outputs of a stack as they are after the failed deployment
+type StackContext struct {
+	Parameters []StackParameter
+	Outputs    []StackOutput
+}
+
+// StackParameter is a parameter value of a stack
+type StackParameter struct {
+	Key   string
+	Value string
+
+	// ResolvedValue is the value of an SSM parameter the parameter refers to
+	ResolvedValue string
+
+	// NoEcho is set for parameters whose value CloudFormation masks
+	NoEcho bool
+}
+
+// StackOutput is an output of a stack
+type StackOutput struct {
+	Key         string
+	Value       string
+	Description string
+	ExportName  string
+}
+
+// AnalysisMetadata records where and how an analysis was made, so its results can be reproduced
+type AnalysisMetadata struct {
+	// Partition, Region and AccountID identify where the stack is, taken from its ARN
+	Partition string
+	Region    string
+	AccountID string
+
+	// ToolVersion is the version of the analyzer that made the analysis
+	ToolVersion string
+
+	// Duration is how long the analysis took
+	Duration time.Duration
+
+	// Sources are the data the analysis is based on, e.g. stack-events and cloudtrail, as listed
+	// by the Source constants
+	Sources []string
+}
+
+// Sources of the data an analysis is based on
+const (
+	SourceStackEvents = "stack-events"
+	SourceCloudTrail  = "cloudtrail"
+	SourceArchive     = "archive"
+	SourceStdin       = "stdin"
+	SourceCache       = "cache"
+)
+
+// TimeWindow is a time range, e.g. one CloudTrail was queried in
+type TimeWindow struct {
+	Start time.Time
+	End   time.Time
+}
+
+// TemplateDiff describes what changed in the template of the failed deployment
+type TemplateDiff struct {
+	// ChangeSetId identifies the change set of the failed deployment
+	ChangeSetId string
+
+	// Diff is a unified diff from the previously deployed template to the failed template;
+	// empty if the templates are identical
+	Diff string
+}
+
+// RegistryType describes the registered version of a private or third-party resource type
+type RegistryType struct {
+	TypeName string
+	Arn      string
+
+	// Visibility is PRIVATE for types registered or activated in the account, PUBLIC otherwise
+	Visibility string
+
+	// DefaultVersionId is the version of a private type used by stack operations
+	DefaultVersionId string
+
+	// PublicVersionNumber is the activated version of a public third-party type, and
+	// LatestPublicVersion the newest published one
+	PublicVersionNumber string
+	LatestPublicVersion string
+
+	// DeprecatedStatus is LIVE or DEPRECATED
+	DeprecatedStatus string
+	ExecutionRoleArn string
+	PublisherId      string
+	AutoUpdate       bool
+
+	// HandlerLogGroup is the CloudWatch Logs group the handlers of the type log to, "" if logging is not configured
+	HandlerLogGroup string
+}
+
+// LambdaActivity summarizes the invocations and log output of a Lambda function during a stack operation
+type LambdaActivity struct {
+	FunctionName string
+	Invocations  int
+	Errors       int
+	Throttles    int
+
+	// MaxDuration is the longest invocation, 0 if the function was not invoked
+	MaxDuration time.Duration
+
+	// LogGroupFound is false if the function has no log group, e.g. because it never ran
+	LogGroupFound bool
+
+	// LogMessages are the log messages of the function in the time window, oldest first
+	LogMessages []string
+}
+
+// LambdaAccountSettings are the Lambda quotas of the account and its current usage
+type LambdaAccountSettings struct {
+	// ConcurrentExecutions is the concurrency quota of the region, UnreservedConcurrentExecutions
+	// the part of it not reserved by functions
+	ConcurrentExecutions           int
+	UnreservedConcurrentExecutions int
+
+	// CodeSizeZipped and CodeSizeUnzipped are the maximum deployment package sizes in bytes
+	CodeSizeZipped   int64
+	CodeSizeUnzipped int64
+
+	// TotalCodeSize is the code storage quota in bytes, TotalCodeSizeUsed the storage used by all
+	// functions and layers
+	TotalCodeSize     int64
+	TotalCodeSizeUsed int64
+	FunctionCount     int64
+}
+
+// ProvisionedProduct describes a Service Catalog provisioned product and its latest provisioning record
+type ProvisionedProduct struct {
+	Id     string
+	Name   string
+	Status string
+
+	// StatusMessage explains the status, e.g. the reason of a TAINTED or ERROR provisioned product
+	StatusMessage string
+
+	ProductId   string
+	ProductName string
+
+	// ProvisioningArtifactId and ProvisioningArtifactName identify the product version that was provisioned
+	ProvisioningArtifactId   string
+	ProvisioningArtifactName string
+
+	// RecordId is the latest provisioning record, and RecordErrors the errors it reported
+	RecordId     string
+	RecordErrors []string
+
+	// StackId is the ARN of the CloudFormation stack that provisions the product
+	StackId string
+}
+
+// ServiceEvent is an issue of an AWS service reported by AWS Health, e.g. elevated API error rates
+type ServiceEvent struct {
+	Arn string
+
+	// Service is the AWS Health service code, e.g. EC2
+	Service       string
+	EventTypeCode string
+	Region        string
+
+	// Status is open, closed or upcoming
+	Status string
+
+	// StartTime is when the issue began, and EndTime when it was resolved; zero while it is open
+	StartTime time.Time
+	EndTime   time.Time
+
+	// Description is the latest update AWS published about the issue
+	Description string
+}
+
+// DatabaseEvent is an event RDS recorded for a database resource, e.g. a failed storage
+// modification or incompatible parameters
+type DatabaseEvent struct {
+	Time time.Time
+
+	// SourceIdentifier is the identifier of the DB instance, cluster or parameter group
+	SourceIdentifier string
+	SourceType       string
+	Categories       []string
+	Message          string
+}
+
+// DNSValidation is the DNS validation of a domain of an ACM certificate, together with the state
+// of its validation record in Route 53
+type DNSValidation struct {
+	DomainName  string
+	RecordName  string
+	RecordType  string
+	RecordValue string
+
+	// Status is the validation status ACM reports, e.g. PENDING_VALIDATION; "" if only the stack
+	// events name the record, since the certificate was deleted on rollback
+	Status string
+
+	// HostedZone is the name of the public Route 53 hosted zone of the record, "" if the account has none
+	HostedZone string
+
+	// RecordValues are the values of the record in the hosted zone, nil if it does not exist
+	RecordValues []string
+}
+
+// ResourceUsage is the number of resources of a kind in use and the quota applying to them
+type ResourceUsage struct {
+	// Resource describes the counted resources, e.g. "Elastic IP addresses"
+	Resource string
+
+	// Scope is the region or Availability Zone the quota applies to
+	Scope string
+
+	Used  int
+	Quota int
+
+	// ServiceCode and QuotaCode identify the quota in Service Quotas, e.g. ec2 and L-0263D0A3
+	ServiceCode string
+	QuotaCode   string
+
+	// DefaultQuota is true if Quota is the AWS default, since the quota of the account could not be read
+	DefaultQuota bool
+}
+
+// ResourceState is the current state of a resource as its service reports it through the Cloud
+// Control API
+type ResourceState struct {
+	TypeName   string
+	Identifier string
+
+	// Properties are the properties of the resource as the service returns them
+	Properties map[string]interface{}
+}
+
+// CallerIdentity describes where an analysis ran and as whom
+type CallerIdentity struct {
+	AccountID string
+	Region    string
+	Principal string
+}
+
+// IgnoredError is an error excluded from the counts by an ignore rule
+type IgnoredError struct {
+	Error   CorrelatedError
+	Comment string
+}
+
+// ReplaceErrors replaces the errors of the analysis, as when errors are filtered, ignored or set
+// aside by a baseline. Findings and warnings about resources without remaining errors are dropped,
+// and the summary counts are recalculated.
+func (a *StackAnalysis) ReplaceErrors(errors []CorrelatedError) {
+	a.Errors = errors
+	a.removeOrphanedFindings()
+	a.updateCounts()
+}
+
+// updateCounts recalculates the GeneralServiceException and CloudTrail detail counts from Errors
+func (a *StackAnalysis) updateCounts() {
+	a.GeneralErrors = 0
+	a.DetailedErrors = 0
+	for _, err := range a.Errors {
+		if err.StackError.IsGeneralServiceException {
+			a.GeneralErrors++
+		}
+		if err.CloudTrailEvent != nil {
+			a.DetailedErrors++
+		}
+	}
+}
+
+// removeOrphanedFindings drops findings and warnings about resources that no longer have errors;
+// warnings about the whole stack are kept
+func (a *StackAnalysis) removeOrphanedFindings() {
+	resources := make(map[string]bool)
+	for _, err := range a.Errors {
+		resources[err.StackError.LogicalResourceId] = true
+	}
+
+	var findings []Finding
+	for _, finding := range a.Findings {
+		if resources[finding.LogicalResourceId] {
+			findings = append(findings, finding)
+		}
+	}
+	a.Findings = findings
+
+	var warnings []Warning
+	for _, warning := range a.Warnings {
+		if warning.LogicalResourceId == "" || resources[warning.LogicalResourceId] {
+			warnings = append(warnings, warning)
+		}
+	}
+	a.Warnings = warnings
+}
+
+// AnalysisStats contains performance statistics collected during an analysis
+type AnalysisStats struct {
+	Phases                 []PhaseTiming
+	StackEventsScanned     int
+	CloudTrailCalls        int
+	CloudTrailEventsParsed int
+
+	// CloudTrailWindows are the time ranges CloudTrail was queried in, the earliest first
+	CloudTrailWindows []TimeWindow
+}
+
+// PhaseTiming records how long an analysis phase took
+type PhaseTiming struct {
+	Name     string
+	Duration time.Duration
+}
+
+// RecordPhase records the duration of a phase that started at the given time
+func (s *AnalysisStats) RecordPhase(name string, start time.Time) {
+	s.Phases = append(s.Phases, PhaseTiming{Name: name, Duration: time.Since(start)})
+}
+
+// TotalDuration returns the combined duration of all recorded phases
+func (s *AnalysisStats) TotalDuration() time.Duration {
+	var total time.Duration
+	for _, phase := range s.Phases {
+		total += phase.Duration
+	}
+	return total
 }
 
 // CorrelatedError represents a CloudFormation error with optional CloudTrail correlation
@@ -33,6 +434,71 @@ type CorrelatedError struct {
 	StackError      StackError
 	CloudTrailEvent *CloudTrailEvent
 	DetailedMessage string
+
+	// Classification is assigned by a user-defined classification rule, nil if no rule matched
+	Classification *Classification
+
+	// CorrelationWindow is the time window around the error the CloudTrail event matched in; it
+	// exceeds the default window if the correlation had to widen it
+	CorrelationWindow time.Duration
+
+	// Retries summarizes the calls the resource provider repeated with the same error as the
+	// CloudTrail event within the correlation window; nil unless the call failed more than once
+	Retries *RetrySummary
+}
+
+// RetrySummary describes a call that failed repeatedly with the same error
+type RetrySummary struct {
+	// Count is the number of failed calls, including the first
+	Count int
+
+	// First and Last are the times of the first and the last failed call
+	First time.Time
+	Last  time.Time
+}
+
+// Classification is the category, owner and severity a classification rule assigned to an error
+type Classification struct {
+	Rule     string
+	Category string
+	Owner    string
+	Severity string
+}
+
+// Finding describes a recognized failure pattern together with the evidence that supports it
+type Finding struct {
+	Pattern           string
+	LogicalResourceId string
+	Title             string
+	Explanation       string
+	Evidence          []string
+	Suggestion        string
+
+	// Transient marks findings whose cause usually disappears on retry, such as propagation delays
+	Transient bool
+}
+
+// Kinds of warnings collected during an analysis
+const (
+	// WarningCloudTrailSkipped is reported when CloudTrail was not or could not be queried for a failure
+	WarningCloudTrailSkipped = "cloudtrail-skipped"
+
+	// WarningDeliveryLag is reported for recent failures whose CloudTrail events may not be delivered yet
+	WarningDeliveryLag = "delivery-lag"
+
+	// WarningEventsTruncated is reported when the stack events do not reach back to the start of the operation
+	WarningEventsTruncated = "events-truncated"
+
+	// WarningLowConfidence is reported for CloudTrail events that match a failure only by time
+	WarningLowConfidence = "low-confidence"
+)
+
+// Warning describes a condition that makes an analysis less reliable. LogicalResourceId is the
+// resource the warning is about, or "" if it concerns the whole stack.
+type Warning struct {
+	Kind              string
+	LogicalResourceId string
+	Message           string
 }
 
 // CloudTrailEvent represents relevant CloudTrail log data
@@ -44,6 +510,23 @@ type CloudTrailEvent struct {
 	ResponseElements map[string]interface{}
 	ErrorCode        string
 	ErrorMessage     string
+	EventID          string
+	RequestID        string
+
+	// RequestParameters are the parameters of the API call, e.g. the instance type of RunInstances
+	RequestParameters map[string]interface{}
+
+	// Resources are the resources CloudTrail lists for the API call, by ARN or name
+	Resources []TrailResource
+}
+
+// TrailResource is a resource referenced by a CloudTrail event
+type TrailResource struct {
+	// Type is the resource type, e.g. AWS::Lambda::Function
+	Type string
+
+	// Name is the ARN or name of the resource
+	Name string
 }
 
 // AnalyzeStackErrors performs the main analysis workflow for a CloudFormation stack
@@ -56,4 +539,4 @@ func AnalyzeStackErrors(ctx context.Context, stackName string) (*StackAnalysis,
 func GetStackEvents(ctx context.Context, stackName string) ([]types.StackEvent, error) {
 	// TODO: Implement stack event retrieval
 	return nil, nil
-}
\ No newline at end of file
+}
@@ -0,0 +1,480 @@
+// Package anonymize produces anonymized copies of archived snapshots that keep the structure of
+// the original, so failures can be reproduced and reported against the analyzer without leaking
+// infrastructure details. Identifiers are replaced by stable pseudonyms: every occurrence of a
+// stack name, logical or physical resource ID, account ID, UUID, IP address or access key gets the
+// same pseudonym, so stack events and CloudTrail events still correlate as in the original.
+package anonymize
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+
+	"cfn-root-cause/analyzer"
+	"cfn-root-cause/archive"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation/types"
+)
+
+var (
+	// arnPattern matches ARNs in text
+	arnPattern = regexp.MustCompile(`arn:[\w-]+:[\w-]+:[\w-]*:\d{0,12}:[^\s"',;()\[\]{}<>]+`)
+
+	// accountPattern matches 12-digit account IDs
+	accountPattern = regexp.MustCompile(`\b\d{12}\b`)
+
+	// uuidPattern matches UUIDs, e.g. the stack ID segment of stack ARNs and request IDs
+	uuidPattern = regexp.MustCompile(`\b[0-9A-Fa-f]{8}-[0-9A-Fa-f]{4}-[0-9A-Fa-f]{4}-[0-9A-Fa-f]{4}-[0-9A-Fa-f]{12}\b`)
+
+	// ipv4Pattern matches IPv4 addresses
+	ipv4Pattern = regexp.MustCompile(`\b(?:\d{1,3}\.){3}\d{1,3}\b`)
+
+	// uniqueIdPattern matches access key IDs and the unique IDs of IAM users and roles
+	uniqueIdPattern = regexp.MustCompile(`\b(AKIA|ASIA|AIDA|AROA)[A-Z0-9]{4,}\b`)
+
+	// hexIdPattern matches EC2-style physical IDs like sg-0123456789abcdef0, whose prefix is kept
+	hexIdPattern = regexp.MustCompile(`^([a-z]+)-([0-9a-f]{8,17})$`)
+)
+
+// keptIdentityKeys are the user identity fields that describe the kind of principal, not who it is
+var keptIdentityKeys = map[string]bool{
+	"type":             true,
+	"invokedBy":        true,
+	"mfaAuthenticated": true,
+	"creationDate":     true,
+}
+
+// Anonymizer assigns pseudonyms consistently across all snapshots it is applied to
+type Anonymizer struct {
+	// pseudonyms maps original identifiers to their pseudonyms, originals maps pseudonyms back
+	pseudonyms map[string]string
+	originals  map[string]bool
+	counts     map[string]int
+
+	// lower maps the lowercase form of identifiers replaced in free text to the identifier
+	lower      map[string]string
+	identifier *regexp.Regexp
+}
+
+// New creates an anonymizer
+func New() *Anonymizer {
+	return &Anonymizer{
+		pseudonyms: make(map[string]string),
+		originals:  make(map[string]bool),
+		counts:     make(map[string]int),
+		lower:      make(map[string]string),
+	}
+}
+
+// pseudonym returns the pseudonym of a value, creating one with format from the number of values
+// of the kind seen so far. Values that are pseudonyms themselves are returned unchanged.
+func (a *Anonymizer) pseudonym(kind, value string, format func(n int) string) string {
+	if pseudonym, ok := a.pseudonyms[value]; ok {
+		return pseudonym
+	}
+	if a.originals[value] {
+		return value
+	}
+	a.counts[kind]++
+	pseudonym := format(a.counts[kind])
+	a.pseudonyms[value] = pseudonym
+	a.originals[pseudonym] = true
+	return pseudonym
+}
+
+// name returns the pseudonym of an identifier that is also replaced wherever it occurs in free text
+func (a *Anonymizer) name(kind, value string, format func(n int) string) string {
+	if value == "" {
+		return ""
+	}
+	pseudonym := a.pseudonym(kind, value, format)
+	if pseudonym != value && len(value) >= 3 {
+		if _, ok := a.lower[strings.ToLower(value)]; !ok {
+			a.lower[strings.ToLower(value)] = value
+			a.identifier = nil
+		}
+	}
+	return pseudonym
+}
+
+// numbered returns a format for pseudonyms like "prefix-1"
+func numbered(prefix string) func(n int) string {
+	return func(n int) string { return fmt.Sprintf("%s-%d", prefix, n) }
+}
+
+// Snapshot returns an anonymized copy of a snapshot. Timestamps, resource types, statuses, event
+// names and error codes are kept; identifiers are replaced by pseudonyms and free text such as
+// status reasons and CloudTrail error messages has the identifiers it contains replaced.
+func (a *Anonymizer) Snapshot(snapshot *archive.Snapshot) *archive.Snapshot {
+	stackName := a.stackName(snapshot.StackName)
+
+	// Identifiers are collected first, so free text mentioning a resource before its own
+	// event appears is anonymized as well
+	for _, event := range snapshot.Events {
+		a.stackName(aws.ToString(event.StackName))
+		a.logicalId(aws.ToString(event.LogicalResourceId), aws.ToString(event.ResourceType))
+	}
+	for _, event := range snapshot.Events {
+		a.physicalId(aws.ToString(event.StackId))
+		a.physicalId(aws.ToString(event.PhysicalResourceId))
+	}
+
+	anonymized := &archive.Snapshot{
+		Version:     snapshot.Version,
+		StackName:   stackName,
+		StackId:     a.physicalId(snapshot.StackId),
+		ArchivedAt:  snapshot.ArchivedAt,
+		Events:      make([]types.StackEvent, 0, len(snapshot.Events)),
+		TrailEvents: make(map[string][]analyzer.CloudTrailEvent, len(snapshot.TrailEvents)),
+	}
+	for _, event := range snapshot.Events {
+		anonymized.Events = append(anonymized.Events, a.stackEvent(event))
+	}
+	for _, eventId := range sortedKeys(snapshot.TrailEvents) {
+		trailEvents := snapshot.TrailEvents[eventId]
+		events := make([]analyzer.CloudTrailEvent, 0, len(trailEvents))
+		for _, trailEvent := range trailEvents {
+			events = append(events, a.trailEvent(trailEvent))
+		}
+		anonymized.TrailEvents[a.Text(eventId)] = events
+	}
+	return anonymized
+}
+
+// stackName returns the pseudonym of a stack name, e.g. repro-stack-1
+func (a *Anonymizer) stackName(name string) string {
+	return a.name("stack", name, numbered("repro-stack"))
+}
+
+// logicalId returns the pseudonym of a logical resource ID, named after the resource type, e.g.
+// Bucket1 for an AWS::S3::Bucket. The stack itself keeps the pseudonym of its name.
+func (a *Anonymizer) logicalId(logicalId, resourceType string) string {
+	if pseudonym, ok := a.pseudonyms[logicalId]; ok {
+		return pseudonym
+	}
+	kind := resourceType[strings.LastIndex(resourceType, ":")+1:]
+	kind = strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return r
+		}
+		return -1
+	}, kind)
+	if kind == "" {
+		kind = "Resource"
+	}
+	return a.name("logical:"+kind, logicalId, func(n int) string { return fmt.Sprintf("%s%d", kind, n) })
+}
+
+// physicalId returns the pseudonym of a physical resource ID. ARNs keep their partition, service,
+// region and resource type, EC2-style IDs their prefix.
+func (a *Anonymizer) physicalId(physicalId string) string {
+	switch {
+	case physicalId == "":
+		return ""
+	case strings.HasPrefix(physicalId, "arn:"):
+		return a.arn(physicalId)
+	case uuidPattern.MatchString(physicalId) && len(physicalId) == 36:
+		return a.uuid(physicalId)
+	}
+	if match := hexIdPattern.FindStringSubmatch(physicalId); match != nil {
+		return a.name("hex:"+match[1], physicalId, func(n int) string {
+			return fmt.Sprintf("%s-%0*x", match[1], len(match[2]), n)
+		})
+	}
+	return a.name("physical", physicalId, numbered("physical-id"))
+}
+
+// arn returns the pseudonym of an ARN
+func (a *Anonymizer) arn(arn string) string {
+	if pseudonym, ok := a.pseudonyms[arn]; ok {
+		return pseudonym
+	}
+	parts := strings.SplitN(arn, ":", 6)
+	if len(parts) < 6 {
+		return a.name("physical", arn, numbered("physical-id"))
+	}
+	if parts[4] != "" {
+		parts[4] = a.account(parts[4])
+	}
+
+	// The resource part is "type/name", "type:name" or just a name; the type is kept
+	resource := parts[5]
+	var kept, rest string
+	if i := strings.IndexAny(resource, "/:"); i >= 0 {
+		kept, rest = resource[:i+1], resource[i+1:]
+	} else {
+		rest = resource
+	}
+	segments := strings.FieldsFunc(rest, func(r rune) bool { return r == '/' || r == ':' })
+	separators := strings.FieldsFunc(rest, func(r rune) bool { return r != '/' && r != ':' })
+	var anonymized strings.Builder
+	anonymized.WriteString(kept)
+	for i, segment := range segments {
+		anonymized.WriteString(a.arnSegment(segment))
+		if i < len(separators) {
+			anonymized.WriteString(separators[i])
+		}
+	}
+	parts[5] = anonymized.String()
+	return a.name("arn", arn, func(int) string { return strings.Join(parts, ":") })
+}
+
+// arnSegment returns the pseudonym of a name in the resource part of an ARN
+func (a *Anonymizer) arnSegment(segment string) string {
+	if segment == "*" || a.originals[segment] {
+		return segment
+	}
+	if pseudonym, ok := a.pseudonyms[segment]; ok {
+		return pseudonym
+	}
+	if uuidPattern.MatchString(segment) && len(segment) == 36 {
+		return a.uuid(segment)
+	}
+	return a.name("arn-name", segment, numbered("name"))
+}
+
+// account returns the pseudonym of an account ID, e.g. 000000000001
+func (a *Anonymizer) account(account string) string {
+	return a.pseudonym("account", account, func(n int) string { return fmt.Sprintf("%012d", n) })
+}
+
+// uuid returns the pseudonym of a UUID
+func (a *Anonymizer) uuid(uuid string) string {
+	return a.pseudonym("uuid", strings.ToLower(uuid), func(n int) string {
+		return fmt.Sprintf("00000000-0000-0000-0000-%012d", n)
+	})
+}
+
+// Text replaces the identifiers in free text: ARNs, the stack names and resource IDs seen so far,
+// account IDs, UUIDs, IPv4 addresses, access key IDs and IAM unique IDs. Resource IDs are replaced regardless of
+// case, keeping lowercase mentions lowercase, but not as part of resource types like AWS::S3::Bucket.
+func (a *Anonymizer) Text(text string) string {
+	if text == "" {
+		return ""
+	}
+	text = arnPattern.ReplaceAllStringFunc(text, a.arn)
+	text = a.replaceIdentifiers(text)
+	text = uniqueIdPattern.ReplaceAllStringFunc(text, func(id string) string {
+		return a.pseudonym("unique-id", id, func(n int) string { return fmt.Sprintf("%sEXAMPLE%09d", id[:4], n) })
+	})
+	text = uuidPattern.ReplaceAllStringFunc(text, a.uuid)
+	text = accountPattern.ReplaceAllStringFunc(text, a.account)
+	text = ipv4Pattern.ReplaceAllStringFunc(text, func(ip string) string {
+		return a.pseudonym("ip", ip, func(n int) string {
+			return fmt.Sprintf("10.%d.%d.%d", n>>16&0xff, n>>8&0xff, n&0xff)
+		})
+	})
+	return text
+}
+
+// replaceIdentifiers replaces the stack names and resource IDs seen so far in text
+func (a *Anonymizer) replaceIdentifiers(text string) string {
+	if len(a.lower) == 0 {
+		return text
+	}
+	if a.identifier == nil {
+		identifiers := make([]string, 0, len(a.lower))
+		for lower := range a.lower {
+			identifiers = append(identifiers, regexp.QuoteMeta(lower))
+		}
+		// Longer identifiers first, so a physical ID wins over the logical ID it contains
+		sort.Slice(identifiers, func(i, j int) bool {
+			if len(identifiers[i]) != len(identifiers[j]) {
+				return len(identifiers[i]) > len(identifiers[j])
+			}
+			return identifiers[i] < identifiers[j]
+		})
+		a.identifier = regexp.MustCompile(`(?i)` + strings.Join(identifiers, "|"))
+	}
+
+	var result strings.Builder
+	last := 0
+	for _, match := range a.identifier.FindAllStringIndex(text, -1) {
+		start, end := match[0], match[1]
+		if !isBoundary(text, start, end) {
+			continue
+		}
+		found := text[start:end]
+		original := a.lower[strings.ToLower(found)]
+		pseudonym := a.pseudonyms[original]
+		if found != original && found == strings.ToLower(found) {
+			pseudonym = strings.ToLower(pseudonym)
+		}
+		result.WriteString(text[last:start])
+		result.WriteString(pseudonym)
+		last = end
+	}
+	result.WriteString(text[last:])
+	return result.String()
+}
+
+// isBoundary reports whether text[start:end] is a whole word that is not part of a resource type
+func isBoundary(text string, start, end int) bool {
+	if start > 0 && isWordByte(text[start-1]) || end < len(text) && isWordByte(text[end]) {
+		return false
+	}
+	return !strings.HasSuffix(text[:start], "::") && !strings.HasPrefix(text[end:], "::")
+}
+
+// isWordByte reports whether a byte can be part of an identifier
+func isWordByte(b byte) bool {
+	return b == '_' || b >= '0' && b <= '9' || b >= 'a' && b <= 'z' || b >= 'A' && b <= 'Z' || b >= 0x80
+}
+
+// stackEvent returns an anonymized copy of a stack event
+func (a *Anonymizer) stackEvent(event types.StackEvent) types.StackEvent {
+	anonymized := event
+	anonymized.EventId = a.optional(event.EventId, a.Text)
+	anonymized.StackId = a.optional(event.StackId, a.physicalId)
+	anonymized.StackName = a.optional(event.StackName, a.stackName)
+	anonymized.LogicalResourceId = a.optional(event.LogicalResourceId, func(logicalId string) string {
+		return a.logicalId(logicalId, aws.ToString(event.ResourceType))
+	})
+	anonymized.PhysicalResourceId = a.optional(event.PhysicalResourceId, a.physicalId)
+	anonymized.ClientRequestToken = a.optional(event.ClientRequestToken, func(token string) string {
+		return a.pseudonym("token", token, numbered("token"))
+	})
+	anonymized.ResourceStatusReason = a.optional(event.ResourceStatusReason, a.Text)
+	anonymized.HookStatusReason = a.optional(event.HookStatusReason, a.Text)
+	anonymized.ResourceProperties = a.optional(event.ResourceProperties, a.properties)
+	return anonymized
+}
+
+// optional applies an anonymization to an optional string
+func (a *Anonymizer) optional(value *string, anonymize func(string) string) *string {
+	if value == nil {
+		return nil
+	}
+	return aws.String(anonymize(*value))
+}
+
+// properties anonymizes the resource properties of a stack event. Property names, numbers and
+// booleans are kept, string values are replaced by the pseudonym of the identifier they are, or by
+// a generic pseudonym like value-1.
+func (a *Anonymizer) properties(properties string) string {
+	var parsed interface{}
+	if err := json.Unmarshal([]byte(properties), &parsed); err != nil {
+		return a.pseudonym("value", properties, numbered("value"))
+	}
+	data, err := json.Marshal(a.propertyValue(parsed))
+	if err != nil {
+		return a.pseudonym("value", properties, numbered("value"))
+	}
+	return string(data)
+}
+
+// propertyValue anonymizes a parsed property value
+func (a *Anonymizer) propertyValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		anonymized := make(map[string]interface{}, len(v))
+		for _, key := range sortedKeys(v) {
+			anonymized[key] = a.propertyValue(v[key])
+		}
+		return anonymized
+	case []interface{}:
+		anonymized := make([]interface{}, 0, len(v))
+		for _, item := range v {
+			anonymized = append(anonymized, a.propertyValue(item))
+		}
+		return anonymized
+	case string:
+		if pseudonym, ok := a.pseudonyms[v]; ok {
+			return pseudonym
+		}
+		if strings.HasPrefix(v, "arn:") {
+			return a.arn(v)
+		}
+		return a.pseudonym("value", v, numbered("value"))
+	default:
+		return v
+	}
+}
+
+// trailEvent returns an anonymized copy of a CloudTrail event
+func (a *Anonymizer) trailEvent(event analyzer.CloudTrailEvent) analyzer.CloudTrailEvent {
+	anonymized := event
+	anonymized.ErrorMessage = a.Text(event.ErrorMessage)
+	anonymized.EventID = a.Text(event.EventID)
+	anonymized.RequestID = a.Text(event.RequestID)
+	if event.UserIdentity != nil {
+		anonymized.UserIdentity = a.identity(event.UserIdentity)
+	}
+	if event.ResponseElements != nil {
+		anonymized.ResponseElements, _ = a.value(event.ResponseElements).(map[string]interface{})
+	}
+	if event.RequestParameters != nil {
+		anonymized.RequestParameters, _ = a.value(event.RequestParameters).(map[string]interface{})
+	}
+	if event.Resources != nil {
+		anonymized.Resources = make([]analyzer.TrailResource, 0, len(event.Resources))
+		for _, resource := range event.Resources {
+			anonymized.Resources = append(anonymized.Resources, analyzer.TrailResource{
+				Type: resource.Type,
+				Name: a.physicalId(resource.Name),
+			})
+		}
+	}
+	return anonymized
+}
+
+// identity anonymizes the user identity of a CloudTrail event. Names of principals and sessions
+// that contain no recognizable identifier are replaced by generic pseudonyms like principal-1.
+func (a *Anonymizer) identity(identity map[string]interface{}) map[string]interface{} {
+	anonymized := make(map[string]interface{}, len(identity))
+	for _, key := range sortedKeys(identity) {
+		switch v := identity[key].(type) {
+		case map[string]interface{}:
+			anonymized[key] = a.identity(v)
+		case string:
+			if keptIdentityKeys[key] {
+				anonymized[key] = v
+				continue
+			}
+			text := a.Text(v)
+			if text == v && v != "" && !a.originals[v] {
+				text = a.pseudonym("principal", v, numbered("principal"))
+			}
+			anonymized[key] = text
+		default:
+			anonymized[key] = a.value(v)
+		}
+	}
+	return anonymized
+}
+
+// value anonymizes the free text in the strings of a parsed JSON value
+func (a *Anonymizer) value(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		anonymized := make(map[string]interface{}, len(v))
+		for _, key := range sortedKeys(v) {
+			anonymized[key] = a.value(v[key])
+		}
+		return anonymized
+	case []interface{}:
+		anonymized := make([]interface{}, 0, len(v))
+		for _, item := range v {
+			anonymized = append(anonymized, a.value(item))
+		}
+		return anonymized
+	case string:
+		return a.Text(v)
+	default:
+		return v
+	}
+}
+
+// sortedKeys returns the keys of a map in order, so pseudonyms are numbered the same on every run
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,153 @@
+// Package archive stores snapshots of the stack events and the matching CloudTrail events of
+// stacks, so failures can still be analyzed after CloudTrail's 90-day LookupEvents window has
+// passed or the stack was deleted
+package archive
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"cfn-root-cause/analyzer"
+	"cfn-root-cause/cfnclient"
+	"cfn-root-cause/cloudtrail"
+	"cfn-root-cause/extractor"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation/types"
+)
+
+// SnapshotVersion is the version of the snapshot format
+const SnapshotVersion = 1
+
+// ErrNotFound indicates that no snapshot of a stack was archived
+var ErrNotFound = errors.New("no archived snapshot found")
+
+// Snapshot is the archived state of a stack: its events and the CloudTrail events around its errors
+type Snapshot struct {
+	Version    int                `json:"version"`
+	StackName  string             `json:"stackName"`
+	StackId    string             `json:"stackId,omitempty"`
+	ArchivedAt time.Time          `json:"archivedAt"`
+	Events     []types.StackEvent `json:"events"`
+
+	// TrailEvents are the CloudTrail events around each error, by event ID of the stack event
+	TrailEvents map[string][]analyzer.CloudTrailEvent `json:"trailEvents,omitempty"`
+}
+
+// SearchForStackErrors returns the archived CloudTrail events around a stack error, so a
+// snapshot can stand in for CloudTrail when an archived stack is analyzed
+func (s *Snapshot) SearchForStackErrors(ctx context.Context, stackError analyzer.StackError) ([]analyzer.CloudTrailEvent, error) {
+	return s.TrailEvents[stackError.EventId], nil
+}
+
+// latestEventId returns the ID of the newest stack event, or "" if there are none
+func (s *Snapshot) latestEventId() string {
+	if s == nil || len(s.Events) == 0 {
+		return ""
+	}
+	return aws.ToString(s.Events[0].EventId)
+}
+
+// Store persists snapshots
+type Store interface {
+	// Put stores a snapshot
+	Put(ctx context.Context, snapshot *Snapshot) error
+
+	// Latest returns the most recent snapshot of a stack, or an error wrapping ErrNotFound
+	Latest(ctx context.Context, stackName string) (*Snapshot, error)
+}
+
+// Capture takes a snapshot of the events of a stack and looks up the CloudTrail events around
+// each error. Lookups of errors already contained in the previous snapshot are not repeated, since
+// CloudTrail forgets events after 90 days. If lookups fail, the snapshot is returned together with
+// the lookup errors, so the stack events are archived anyway.
+func Capture(ctx context.Context, cfnClient *cfnclient.Client, ctClient *cloudtrail.Client, stackName string, previous *Snapshot) (*Snapshot, error) {
+	events, err := cfnClient.GetStackEvents(ctx, stackName)
+	if err != nil {
+		return nil, err
+	}
+
+	snapshot := &Snapshot{
+		Version:     SnapshotVersion,
+		StackName:   stackName,
+		ArchivedAt:  time.Now().UTC(),
+		Events:      events,
+		TrailEvents: map[string][]analyzer.CloudTrailEvent{},
+	}
+	for _, event := range events {
+		if event.StackId != nil {
+			snapshot.StackId = *event.StackId
+			break
+		}
+	}
+
+	var lookupErrs []error
+	for _, stackErr := range extractor.ExtractErrors(events) {
+		if previous != nil {
+			if trailEvents, ok := previous.TrailEvents[stackErr.EventId]; ok {
+				snapshot.TrailEvents[stackErr.EventId] = trailEvents
+				continue
+			}
+		}
+
+		trailEvents, err := ctClient.SearchForStackErrors(ctx, stackErr)
+		if errors.Is(err, cloudtrail.ErrBreakerOpen) || ctx.Err() != nil {
+			lookupErrs = append(lookupErrs, err)
+			break
+		}
+		if err != nil {
+			lookupErrs = append(lookupErrs, fmt.Errorf("failed to look up CloudTrail events for resource %s: %w",
+				stackErr.LogicalResourceId, err))
+			continue
+		}
+		snapshot.TrailEvents[stackErr.EventId] = trailEvents
+	}
+
+	return snapshot, errors.Join(lookupErrs...)
+}
+
+// Update captures a new snapshot of a stack and stores it, unless the stack has no new events
+// since the latest archived snapshot. It reports whether a snapshot was stored; the CloudTrail
+// lookup errors of a stored snapshot are returned as well.
+func Update(ctx context.Context, store Store, cfnClient *cfnclient.Client, ctClient *cloudtrail.Client, stackName string) (bool, error) {
+	previous, err := store.Latest(ctx, stackName)
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return false, err
+	}
+
+	snapshot, captureErr := Capture(ctx, cfnClient, ctClient, stackName, previous)
+	if snapshot == nil {
+		return false, captureErr
+	}
+	if previous != nil && snapshot.latestEventId() == previous.latestEventId() {
+		return false, nil
+	}
+
+	if err := store.Put(ctx, snapshot); err != nil {
+		return false, err
+	}
+	return true, captureErr
+}
+
+// Open opens the store at a location: an S3 URL like s3://bucket/prefix, or a local directory
+func Open(cfg aws.Config, location string) (Store, error) {
+	if location == "" {
+		return nil, fmt.Errorf("archive location must not be empty")
+	}
+	if strings.HasPrefix(location, "s3://") {
+		return newS3Store(cfg, location)
+	}
+	if strings.Contains(location, "://") {
+		return nil, fmt.Errorf("unsupported archive location '%s': must be s3://bucket/prefix or a directory", location)
+	}
+	return &dirStore{dir: location}, nil
+}
+
+// objectName returns the name of a snapshot relative to the stack's prefix.
+// Names sort in the order the snapshots were taken.
+func objectName(snapshot *Snapshot) string {
+	return snapshot.ArchivedAt.UTC().Format("20060102T150405.000Z") + ".json"
+}
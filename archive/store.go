@@ -0,0 +1,183 @@
+package archive
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"cfn-root-cause/awserrors"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// dirStore stores snapshots as JSON files in a local directory, one subdirectory per stack
+type dirStore struct {
+	dir string
+}
+
+// Put writes the snapshot to <dir>/<stack>/<time>.json
+func (s *dirStore) Put(ctx context.Context, snapshot *Snapshot) error {
+	data, err := encode(snapshot)
+	if err != nil {
+		return err
+	}
+
+	stackDir := filepath.Join(s.dir, snapshot.StackName)
+	if err := os.MkdirAll(stackDir, 0o755); err != nil {
+		return fmt.Errorf("failed to create archive directory: %w", err)
+	}
+
+	file := filepath.Join(stackDir, objectName(snapshot))
+	if err := os.WriteFile(file, data, 0o644); err != nil {
+		return fmt.Errorf("failed to write snapshot %s: %w", file, err)
+	}
+	return nil
+}
+
+// Latest reads the newest snapshot file of the stack
+func (s *dirStore) Latest(ctx context.Context, stackName string) (*Snapshot, error) {
+	stackDir := filepath.Join(s.dir, stackName)
+	entries, err := os.ReadDir(stackDir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("%w for stack '%s' in %s", ErrNotFound, stackName, s.dir)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read archive directory: %w", err)
+	}
+
+	var names []string
+	for _, entry := range entries {
+		if !entry.IsDir() && strings.HasSuffix(entry.Name(), ".json") {
+			names = append(names, entry.Name())
+		}
+	}
+	if len(names) == 0 {
+		return nil, fmt.Errorf("%w for stack '%s' in %s", ErrNotFound, stackName, s.dir)
+	}
+	sort.Strings(names)
+
+	file := filepath.Join(stackDir, names[len(names)-1])
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read snapshot %s: %w", file, err)
+	}
+	return decode(data, file)
+}
+
+// s3Store stores snapshots as JSON objects in an S3 bucket, below <prefix>/<stack>/
+type s3Store struct {
+	client *s3.Client
+	bucket string
+	prefix string
+}
+
+// newS3Store creates a store for an S3 URL like s3://bucket/prefix
+func newS3Store(cfg aws.Config, location string) (*s3Store, error) {
+	bucket, prefix, _ := strings.Cut(strings.TrimPrefix(location, "s3://"), "/")
+	if bucket == "" {
+		return nil, fmt.Errorf("invalid archive location '%s': missing bucket name", location)
+	}
+
+	return &s3Store{
+		client: s3.NewFromConfig(cfg),
+		bucket: bucket,
+		prefix: strings.Trim(prefix, "/"),
+	}, nil
+}
+
+// stackPrefix returns the key prefix of the snapshots of a stack
+func (s *s3Store) stackPrefix(stackName string) string {
+	return path.Join(s.prefix, stackName) + "/"
+}
+
+// Put uploads the snapshot to s3://<bucket>/<prefix>/<stack>/<time>.json
+func (s *s3Store) Put(ctx context.Context, snapshot *Snapshot) error {
+	data, err := encode(snapshot)
+	if err != nil {
+		return err
+	}
+
+	key := s.stackPrefix(snapshot.StackName) + objectName(snapshot)
+	_, err = s.client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:      aws.String(s.bucket),
+		Key:         aws.String(key),
+		Body:        bytes.NewReader(data),
+		ContentType: aws.String("application/json"),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to upload snapshot to s3://%s/%s: %w", s.bucket, key, awserrors.ParseAWSError(err, "S3"))
+	}
+	return nil
+}
+
+// Latest downloads the snapshot with the greatest key below the stack's prefix
+func (s *s3Store) Latest(ctx context.Context, stackName string) (*Snapshot, error) {
+	prefix := s.stackPrefix(stackName)
+
+	var latest string
+	paginator := s3.NewListObjectsV2Paginator(s.client, &s3.ListObjectsV2Input{
+		Bucket: aws.String(s.bucket),
+		Prefix: aws.String(prefix),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list snapshots in s3://%s/%s: %w", s.bucket, prefix, awserrors.ParseAWSError(err, "S3"))
+		}
+		for _, object := range page.Contents {
+			key := aws.ToString(object.Key)
+			if strings.HasSuffix(key, ".json") && key > latest {
+				latest = key
+			}
+		}
+	}
+	if latest == "" {
+		return nil, fmt.Errorf("%w for stack '%s' in s3://%s/%s", ErrNotFound, stackName, s.bucket, prefix)
+	}
+
+	output, err := s.client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(latest),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to download snapshot s3://%s/%s: %w", s.bucket, latest, awserrors.ParseAWSError(err, "S3"))
+	}
+	defer output.Body.Close()
+
+	data, err := io.ReadAll(output.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download snapshot s3://%s/%s: %w", s.bucket, latest, err)
+	}
+	return decode(data, "s3://"+s.bucket+"/"+latest)
+}
+
+// encode serializes a snapshot as JSON
+func encode(snapshot *Snapshot) ([]byte, error) {
+	data, err := json.Marshal(snapshot)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode snapshot: %w", err)
+	}
+	return data, nil
+}
+
+// decode parses a snapshot read from source and checks its version
+func decode(data []byte, source string) (*Snapshot, error) {
+	var snapshot Snapshot
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return nil, fmt.Errorf("failed to parse snapshot %s: %w", source, err)
+	}
+	if snapshot.Version > SnapshotVersion {
+		return nil, fmt.Errorf("snapshot %s has unsupported version %d: upgrade cfnrc", source, snapshot.Version)
+	}
+	return &snapshot, nil
+}
@@ -0,0 +1,126 @@
+// Command archiver is an AWS Lambda function that archives stack events and the matching
+// CloudTrail events to S3. Triggered by EventBridge "CloudFormation Stack Status Change" events it
+// archives the stack whose status changed; on a schedule it archives all stacks with a failure status.
+//
+// Build it for the provided.al2023 runtime:
+//
+//	GOOS=linux GOARCH=arm64 go build -o bootstrap ./archiver
+//
+// and set ARCHIVE_LOCATION to the archive, e.g. s3://my-bucket/cfnrc.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"cfn-root-cause/archive"
+	"cfn-root-cause/awsconfig"
+	"cfn-root-cause/cfnclient"
+	"cfn-root-cause/cloudtrail"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+// locationEnvVar names the environment variable holding the archive location
+const locationEnvVar = "ARCHIVE_LOCATION"
+
+// stackStatusChange is the part of an EventBridge event the archiver uses
+type stackStatusChange struct {
+	Detail struct {
+		StackId string `json:"stack-id"`
+	} `json:"detail"`
+}
+
+// result is the response of an invocation
+type result struct {
+	Archived  []string `json:"archived"`
+	Unchanged []string `json:"unchanged"`
+}
+
+func main() {
+	ctx := context.Background()
+
+	location := os.Getenv(locationEnvVar)
+	if location == "" {
+		fmt.Fprintf(os.Stderr, "Error: %s is not set\n", locationEnvVar)
+		os.Exit(1)
+	}
+
+	cfg, err := awsconfig.LoadDefaultConfig(ctx)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: failed to load AWS configuration: %v\n", err)
+		os.Exit(1)
+	}
+	store, err := archive.Open(cfg, location)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	err = serveInvocations(ctx, func(ctx context.Context, payload []byte) (interface{}, error) {
+		return handle(ctx, cfg, store, payload)
+	})
+	fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+	os.Exit(1)
+}
+
+// handle archives the stack named by a stack status change event, or all stacks with a failure
+// status for other events such as scheduled ones
+func handle(ctx context.Context, cfg aws.Config, store archive.Store, payload []byte) (*result, error) {
+	var event stackStatusChange
+	if err := json.Unmarshal(payload, &event); err != nil {
+		return nil, fmt.Errorf("failed to parse event: %w", err)
+	}
+
+	cfnClient := cfnclient.NewClientWithConfig(cfg)
+	ctClient := cloudtrail.NewClientWithConfig(cfg)
+	ctClient.UseBreaker(cloudtrail.NewBreaker(cloudtrail.DefaultBreakerThreshold))
+
+	var stackNames []string
+	if event.Detail.StackId != "" {
+		stackNames = []string{stackNameOf(event.Detail.StackId)}
+	} else {
+		summaries, err := cfnClient.ListStacksWithStatus(ctx, cfnclient.FailedStackStatuses)
+		if err != nil {
+			return nil, err
+		}
+		for _, summary := range summaries {
+			stackNames = append(stackNames, aws.ToString(summary.StackName))
+		}
+	}
+
+	res := &result{Archived: []string{}, Unchanged: []string{}}
+	var errs []error
+	for _, stackName := range stackNames {
+		stored, err := archive.Update(ctx, store, cfnClient, ctClient, stackName)
+		if err != nil {
+			// Lookup errors of a stored snapshot are logged, a missing snapshot fails the invocation
+			fmt.Fprintf(os.Stderr, "Warning: Stack %s: %v\n", stackName, err)
+			if !stored {
+				errs = append(errs, fmt.Errorf("stack %s: %w", stackName, err))
+				continue
+			}
+		}
+		if stored {
+			res.Archived = append(res.Archived, stackName)
+		} else {
+			res.Unchanged = append(res.Unchanged, stackName)
+		}
+	}
+
+	return res, errors.Join(errs...)
+}
+
+// stackNameOf returns the stack name of a stack ARN like
+// arn:aws:cloudformation:eu-central-1:123456789012:stack/my-stack/<uuid>
+func stackNameOf(stackId string) string {
+	if _, resource, ok := strings.Cut(stackId, ":stack/"); ok {
+		name, _, _ := strings.Cut(resource, "/")
+		return name
+	}
+	return stackId
+}
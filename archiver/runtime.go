@@ -0,0 +1,69 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+)
+
+// runtimeAPIVersion is the version of the Lambda runtime API
+const runtimeAPIVersion = "2018-06-01"
+
+// handlerFunc handles one invocation with its JSON payload
+type handlerFunc func(ctx context.Context, payload []byte) (interface{}, error)
+
+// invocationError is the error response reported to the Lambda runtime API
+type invocationError struct {
+	ErrorMessage string `json:"errorMessage"`
+	ErrorType    string `json:"errorType"`
+}
+
+// serveInvocations implements the loop of a custom Lambda runtime: it fetches the next invocation
+// from the runtime API, calls the handler and posts the result, until fetching fails
+func serveInvocations(ctx context.Context, handler handlerFunc) error {
+	api := os.Getenv("AWS_LAMBDA_RUNTIME_API")
+	if api == "" {
+		return fmt.Errorf("AWS_LAMBDA_RUNTIME_API is not set: the archiver runs as an AWS Lambda function")
+	}
+	baseURL := "http://" + api + "/" + runtimeAPIVersion + "/runtime/invocation/"
+
+	for {
+		resp, err := http.Get(baseURL + "next")
+		if err != nil {
+			return fmt.Errorf("failed to fetch next invocation: %w", err)
+		}
+		payload, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return fmt.Errorf("failed to read invocation: %w", err)
+		}
+		requestID := resp.Header.Get("Lambda-Runtime-Aws-Request-Id")
+
+		result, err := handler(ctx, payload)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			postResult(baseURL+requestID+"/error", invocationError{ErrorMessage: err.Error(), ErrorType: "ArchiveError"})
+			continue
+		}
+		postResult(baseURL+requestID+"/response", result)
+	}
+}
+
+// postResult posts the JSON encoded result of an invocation to the runtime API
+func postResult(url string, result interface{}) {
+	body, err := json.Marshal(result)
+	if err != nil {
+		body = []byte(`{}`)
+	}
+
+	resp, err := http.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: Failed to post invocation result: %v\n", err)
+		return
+	}
+	resp.Body.Close()
+}
@@ -0,0 +1,153 @@
+// Package awsconfig loads the AWS configuration shared by all service clients of a run: the
+// region and profile overrides, the MFA token code for profiles that assume a role with MFA and
+// the SDK retry settings. Loading it once resolves credentials (and SSO or MFA prompts) only once.
+package awsconfig
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+
+	"cfn-root-cause/awserrors"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials/stscreds"
+	"github.com/aws/smithy-go/middleware"
+	"golang.org/x/term"
+)
+
+// tokenCodeRegex matches the six-digit code of a virtual or hardware MFA device
+var tokenCodeRegex = regexp.MustCompile(`^[0-9]{6}$`)
+
+// MFA configures the MFA device used when a profile assumes a role
+type MFA struct {
+	// SerialNumber is the ARN or serial number of the MFA device; empty means mfa_serial of the profile
+	SerialNumber string
+
+	// TokenCode is the current code of the MFA device; empty means the code is prompted for
+	TokenCode string
+}
+
+// Options configures how the AWS configuration is loaded
+type Options struct {
+	// Region overrides the region of the environment and the profile
+	Region string
+
+	// Profile selects the shared configuration profile, overriding AWS_PROFILE
+	Profile string
+
+	// MFA configures the MFA device for profiles that assume a role with MFA
+	MFA MFA
+
+	// MaxAttempts is the maximum number of attempts of an API call including retries;
+	// 0 keeps the SDK default or max_attempts of the profile
+	MaxAttempts int
+
+	// RetryMode is the SDK retry mode (standard, adaptive); empty keeps the SDK default
+	// or retry_mode of the profile
+	RetryMode string
+
+	// Debug logs every API call with its latency, attempts and request ID to stderr
+	Debug bool
+}
+
+// Validate checks the options for invalid values
+func (o Options) Validate() error {
+	if o.MFA.TokenCode != "" && !tokenCodeRegex.MatchString(o.MFA.TokenCode) {
+		return fmt.Errorf("invalid MFA token code '%s': must be 6 digits", o.MFA.TokenCode)
+	}
+	if o.MaxAttempts < 0 {
+		return fmt.Errorf("invalid max attempts %d: must not be negative", o.MaxAttempts)
+	}
+	if o.RetryMode != "" {
+		if _, err := aws.ParseRetryMode(o.RetryMode); err != nil {
+			return fmt.Errorf("invalid retry mode '%s': must be standard or adaptive", o.RetryMode)
+		}
+	}
+	return nil
+}
+
+// Load loads the shared AWS configuration with the given options. Roles that require MFA are
+// assumed with the configured token code, or with a code read from the terminal once.
+func Load(ctx context.Context, opts Options) (aws.Config, error) {
+	if err := opts.Validate(); err != nil {
+		return aws.Config{}, err
+	}
+
+	tokens := &tokenProvider{code: opts.MFA.TokenCode}
+	loadOptions := []func(*config.LoadOptions) error{
+		config.WithAssumeRoleCredentialOptions(func(o *stscreds.AssumeRoleOptions) {
+			if opts.MFA.SerialNumber != "" {
+				o.SerialNumber = aws.String(opts.MFA.SerialNumber)
+			}
+			serialNumber := aws.ToString(o.SerialNumber)
+			o.TokenProvider = func() (string, error) {
+				return tokens.tokenCode(serialNumber)
+			}
+		}),
+	}
+	if opts.Region != "" {
+		loadOptions = append(loadOptions, config.WithRegion(opts.Region))
+	}
+	if opts.Profile != "" {
+		loadOptions = append(loadOptions, config.WithSharedConfigProfile(opts.Profile))
+		awserrors.SetActiveProfile(opts.Profile)
+	}
+	if opts.MaxAttempts > 0 {
+		loadOptions = append(loadOptions, config.WithRetryMaxAttempts(opts.MaxAttempts))
+	}
+	if opts.RetryMode != "" {
+		mode, _ := aws.ParseRetryMode(opts.RetryMode)
+		loadOptions = append(loadOptions, config.WithRetryMode(mode))
+	}
+	if opts.Debug {
+		loadOptions = append(loadOptions, config.WithAPIOptions([]func(*middleware.Stack) error{debugLogger(os.Stderr)}))
+	}
+
+	return config.LoadDefaultConfig(ctx, loadOptions...)
+}
+
+// LoadDefaultConfig loads the shared AWS configuration without overrides
+func LoadDefaultConfig(ctx context.Context) (aws.Config, error) {
+	return Load(ctx, Options{})
+}
+
+// tokenProvider supplies the MFA token code of one configuration
+type tokenProvider struct {
+	mu   sync.Mutex
+	code string
+}
+
+// tokenCode returns the configured MFA token code, or prompts for it in an interactive terminal.
+// A prompted code is kept, so the clients sharing the configuration ask only once.
+func (p *tokenProvider) tokenCode(serialNumber string) (string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.code != "" {
+		return p.code, nil
+	}
+
+	if !term.IsTerminal(int(os.Stdin.Fd())) {
+		return "", fmt.Errorf("%w for MFA device %s", awserrors.ErrMFATokenRequired, serialNumber)
+	}
+
+	fmt.Fprintf(os.Stderr, "MFA code for %s: ", serialNumber)
+	answer, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil {
+		return "", fmt.Errorf("failed to read MFA token code: %w", err)
+	}
+
+	code := strings.TrimSpace(answer)
+	if !tokenCodeRegex.MatchString(code) {
+		return "", fmt.Errorf("invalid MFA token code '%s': must be 6 digits", code)
+	}
+
+	p.code = code
+	return code, nil
+}
@@ -0,0 +1,88 @@
+package awsconfig
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	awsmiddleware "github.com/aws/aws-sdk-go-v2/aws/middleware"
+	"github.com/aws/aws-sdk-go-v2/aws/retry"
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
+	"github.com/aws/smithy-go/middleware"
+)
+
+// maxParamsLength limits the length of the parameter summary of a logged API call
+const maxParamsLength = 200
+
+// debugMiddlewareID identifies the API call logging middleware in the middleware stack
+const debugMiddlewareID = "cfnrc.DebugLog"
+
+// debugLogger returns an API option installing middleware that logs each API call to w:
+// service and operation, latency, number of attempts, request ID, parameters and error
+func debugLogger(w io.Writer) func(*middleware.Stack) error {
+	return func(stack *middleware.Stack) error {
+		return stack.Initialize.Add(middleware.InitializeMiddlewareFunc(debugMiddlewareID,
+			func(ctx context.Context, in middleware.InitializeInput, next middleware.InitializeHandler) (middleware.InitializeOutput, middleware.Metadata, error) {
+				start := time.Now()
+				out, metadata, err := next.HandleInitialize(ctx, in)
+
+				attempts := 1
+				if results, ok := retry.GetAttemptResults(metadata); ok && len(results.Results) > 0 {
+					attempts = len(results.Results)
+				}
+
+				line := fmt.Sprintf("[aws] %s.%s %s attempts=%d requestId=%s params=%s",
+					awsmiddleware.GetServiceID(ctx), awsmiddleware.GetOperationName(ctx),
+					time.Since(start).Round(time.Millisecond), attempts, requestID(metadata, err), summarizeParams(in.Parameters))
+				if err != nil {
+					line += fmt.Sprintf(" error=%q", err.Error())
+				}
+				fmt.Fprintln(w, line)
+
+				return out, metadata, err
+			}), middleware.After)
+	}
+}
+
+// requestID returns the request ID of an API call from its metadata or error, or "-" if unknown
+func requestID(metadata middleware.Metadata, err error) string {
+	if id, ok := awsmiddleware.GetRequestIDMetadata(metadata); ok && id != "" {
+		return id
+	}
+
+	var respErr *awshttp.ResponseError
+	if errors.As(err, &respErr) && respErr.ServiceRequestID() != "" {
+		return respErr.ServiceRequestID()
+	}
+
+	return "-"
+}
+
+// summarizeParams encodes the set input parameters of an API call as JSON, truncated to maxParamsLength
+func summarizeParams(params interface{}) string {
+	data, err := json.Marshal(params)
+	if err != nil {
+		return fmt.Sprintf("%T", params)
+	}
+
+	// Leave out parameters that are not set
+	var fields map[string]interface{}
+	if json.Unmarshal(data, &fields) == nil {
+		for name, value := range fields {
+			if value == nil {
+				delete(fields, name)
+			}
+		}
+		if compact, err := json.Marshal(fields); err == nil {
+			data = compact
+		}
+	}
+
+	if len(data) > maxParamsLength {
+		return string(data[:maxParamsLength]) + "..."
+	}
+	return string(data)
+}
@@ -6,8 +6,10 @@ package awserrors
 import (
 	"errors"
 	"fmt"
+	"os"
 	"strings"
 
+	"github.com/aws/aws-sdk-go-v2/credentials/ssocreds"
 	"github.com/aws/smithy-go"
 )
 
@@ -22,6 +24,9 @@ var (
 	// ErrAccessDenied indicates the operation was denied due to insufficient permissions
 	ErrAccessDenied = errors.New("access denied")
 
+	// ErrMFATokenRequired indicates a role requires MFA but no token code could be obtained
+	ErrMFATokenRequired = errors.New("MFA token code required")
+
 	// ErrInvalidCredentials indicates the provided credentials are invalid
 	ErrInvalidCredentials = errors.New("invalid AWS credentials")
 
@@ -85,6 +90,19 @@ func ParseAWSError(err error, service string) *AWSError {
 	errMsg := err.Error()
 	errMsgLower := strings.ToLower(errMsg)
 
+	// Check for expired SSO sessions first: the SSO portal reports them as API errors
+	// that would otherwise be treated like any other service error
+	if isSSOSessionError(err, errMsgLower) {
+		return parseSSOSessionError(awsErr)
+	}
+
+	if errors.Is(err, ErrMFATokenRequired) {
+		awsErr.ErrorType = "Credential Error"
+		awsErr.Message = fmt.Sprintf("The role of profile '%s' requires MFA, but no token code was given", ActiveProfile())
+		awsErr.Suggestion = "Run the analyzer in a terminal to be prompted for the code, or pass the current code with --token-code (and --mfa-serial if the profile has no mfa_serial)."
+		return awsErr
+	}
+
 	// Check for Smithy API errors (AWS SDK Go v2)
 	var apiErr smithy.APIError
 	if errors.As(err, &apiErr) {
@@ -97,6 +115,14 @@ func ParseAWSError(err error, service string) *AWSError {
 		return parseCredentialError(awsErr, errMsg)
 	}
 
+	// Check for profiles missing from the shared configuration
+	if strings.Contains(errMsgLower, "failed to get shared config profile") {
+		awsErr.ErrorType = "Configuration Error"
+		awsErr.Message = fmt.Sprintf("AWS profile '%s' was not found", ActiveProfile())
+		awsErr.Suggestion = "Check the profile name against ~/.aws/config (run 'aws configure list-profiles'), or set it up with 'aws configure --profile <name>'."
+		return awsErr
+	}
+
 	// Check for region configuration errors
 	if isRegionError(errMsgLower) {
 		return parseRegionError(awsErr)
@@ -117,6 +143,12 @@ func parseAPIError(awsErr *AWSError, apiErr smithy.APIError) *AWSError {
 
 	switch code {
 	case "AccessDenied", "AccessDeniedException":
+		if strings.Contains(strings.ToLower(message), "multifactorauthentication failed") {
+			awsErr.ErrorType = "Credential Error"
+			awsErr.Message = "The MFA token code was rejected when assuming the role"
+			awsErr.Suggestion = "Wait for the next code of your MFA device and try again. Check that --mfa-serial or mfa_serial of the profile names the right device."
+			break
+		}
 		awsErr.ErrorType = "Permission Error"
 		awsErr.Message = fmt.Sprintf("Access denied: %s", message)
 		awsErr.Suggestion = formatPermissionSuggestion(awsErr.Service)
@@ -208,6 +240,67 @@ For more information, see: https://docs.aws.amazon.com/cli/latest/userguide/cli-
 	return awsErr
 }
 
+// isSSOSessionError checks if the error indicates an expired or missing AWS SSO session
+func isSSOSessionError(err error, errMsgLower string) bool {
+	var tokenErr *ssocreds.InvalidTokenError
+	if errors.As(err, &tokenErr) {
+		return true
+	}
+
+	ssoPatterns := []string{
+		"sso session has expired",
+		"refresh cached sso token failed",
+		"cached sso token is expired",
+		"failed to read cached sso token",
+		"unable to refresh sso token",
+	}
+
+	for _, pattern := range ssoPatterns {
+		if strings.Contains(errMsgLower, pattern) {
+			return true
+		}
+	}
+
+	// The SSO portal rejects an expired access token when exchanging it for role credentials
+	return strings.Contains(errMsgLower, "getrolecredentials") && strings.Contains(errMsgLower, "unauthorizedexception")
+}
+
+// parseSSOSessionError creates an AWSError for an expired AWS SSO session
+func parseSSOSessionError(awsErr *AWSError) *AWSError {
+	awsErr.ErrorType = "SSO Session Error"
+	awsErr.Message = fmt.Sprintf("AWS SSO session for profile '%s' has expired or is invalid", ActiveProfile())
+	awsErr.Suggestion = fmt.Sprintf("Sign in again: run '%s'", SSOLoginCommand())
+
+	return awsErr
+}
+
+// profileOverride is the profile selected on the command line; it takes precedence over AWS_PROFILE
+var profileOverride string
+
+// SetActiveProfile records the profile selected on the command line for error messages and suggestions
+func SetActiveProfile(profile string) {
+	profileOverride = profile
+}
+
+// ActiveProfile returns the name of the AWS profile used for the shared configuration
+func ActiveProfile() string {
+	if profileOverride != "" {
+		return profileOverride
+	}
+	if profile := os.Getenv("AWS_PROFILE"); profile != "" {
+		return profile
+	}
+	if profile := os.Getenv("AWS_DEFAULT_PROFILE"); profile != "" {
+		return profile
+	}
+	return "default"
+}
+
+// SSOLoginCommand returns the AWS CLI command that refreshes the SSO session of the active profile
+func SSOLoginCommand() string {
+	return "aws sso login --profile " + ActiveProfile()
+}
+
 // isRegionError checks if the error message indicates a region configuration issue
 func isRegionError(errMsgLower string) bool {
 	regionPatterns := []string{
@@ -267,11 +360,25 @@ func IsCredentialError(err error) bool {
 
 	var awsErr *AWSError
 	if errors.As(err, &awsErr) {
-		return awsErr.ErrorType == "Credential Error"
+		return awsErr.ErrorType == "Credential Error" || awsErr.ErrorType == "SSO Session Error"
 	}
 
 	errMsgLower := strings.ToLower(err.Error())
-	return isCredentialError(errMsgLower)
+	return isSSOSessionError(err, errMsgLower) || isCredentialError(errMsgLower)
+}
+
+// IsSSOSessionExpired checks if the error is caused by an expired or missing AWS SSO session
+func IsSSOSessionExpired(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	var awsErr *AWSError
+	if errors.As(err, &awsErr) {
+		return awsErr.ErrorType == "SSO Session Error"
+	}
+
+	return isSSOSessionError(err, strings.ToLower(err.Error()))
 }
 
 // IsPermissionError checks if the error is related to AWS permissions
@@ -0,0 +1,134 @@
+// Package baseline records the errors of a previous run so that only new errors are reported as failures
+package baseline
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"cfn-root-cause/analyzer"
+)
+
+// Version is the format version of baseline files written by this package
+const Version = 1
+
+// Comment marks errors set aside because they are part of the baseline
+const Comment = "known from baseline"
+
+// volatilePatterns match parts of failure reasons that change between otherwise identical failures,
+// such as request IDs and tokens
+var volatilePatterns = []*regexp.Regexp{
+	regexp.MustCompile(`(?i)[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}`),
+	regexp.MustCompile(`(?i)\b[0-9a-f]{16,}\b`),
+}
+
+// Baseline is the content of a baseline file
+type Baseline struct {
+	Version   int       `json:"version"`
+	CreatedAt time.Time `json:"createdAt"`
+	Errors    []Entry   `json:"errors"`
+}
+
+// Entry identifies a known error. Only the fingerprint is used for matching;
+// the other fields make the file reviewable.
+type Entry struct {
+	Fingerprint       string `json:"fingerprint"`
+	StackName         string `json:"stackName"`
+	LogicalResourceId string `json:"logicalResourceId"`
+	ResourceStatus    string `json:"resourceStatus"`
+	Reason            string `json:"reason"`
+}
+
+// New creates a baseline containing all errors of the given analyses
+func New(analyses ...*analyzer.StackAnalysis) *Baseline {
+	b := &Baseline{Version: Version, CreatedAt: time.Now().UTC(), Errors: []Entry{}}
+
+	for _, analysis := range analyses {
+		for _, err := range analysis.Errors {
+			b.Errors = append(b.Errors, Entry{
+				Fingerprint:       Fingerprint(analysis.StackName, err),
+				StackName:         analysis.StackName,
+				LogicalResourceId: err.StackError.LogicalResourceId,
+				ResourceStatus:    err.StackError.ResourceStatus,
+				Reason:            err.StackError.ResourceStatusReason,
+			})
+		}
+	}
+
+	return b
+}
+
+// Load reads the baseline file at path
+func Load(path string) (*Baseline, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read baseline '%s': %w", path, err)
+	}
+
+	b := &Baseline{}
+	if err := json.Unmarshal(data, b); err != nil {
+		return nil, fmt.Errorf("failed to parse baseline '%s': %w", path, err)
+	}
+	if b.Version > Version {
+		return nil, fmt.Errorf("baseline '%s' has unsupported version %d", path, b.Version)
+	}
+
+	return b, nil
+}
+
+// Write stores the baseline at path
+func Write(path string, b *Baseline) error {
+	data, err := json.MarshalIndent(b, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode baseline: %w", err)
+	}
+
+	if err := os.WriteFile(path, append(data, '\n'), 0o644); err != nil {
+		return fmt.Errorf("failed to write baseline to '%s': %w", path, err)
+	}
+
+	return nil
+}
+
+// Apply moves errors contained in the baseline from Errors to Ignored
+func Apply(analysis *analyzer.StackAnalysis, b *Baseline) {
+	if b == nil {
+		return
+	}
+
+	known := make(map[string]bool, len(b.Errors))
+	for _, entry := range b.Errors {
+		known[entry.Fingerprint] = true
+	}
+
+	kept := make([]analyzer.CorrelatedError, 0, len(analysis.Errors))
+	for _, err := range analysis.Errors {
+		if known[Fingerprint(analysis.StackName, err)] {
+			analysis.Ignored = append(analysis.Ignored, analyzer.IgnoredError{Error: err, Comment: Comment})
+			continue
+		}
+		kept = append(kept, err)
+	}
+
+	analysis.ReplaceErrors(kept)
+}
+
+// Fingerprint identifies an error independently of when it occurred.
+// Volatile parts of the failure reason such as request IDs are ignored.
+func Fingerprint(stackName string, err analyzer.CorrelatedError) string {
+	reason := err.StackError.ResourceStatusReason
+	for _, pattern := range volatilePatterns {
+		reason = pattern.ReplaceAllString(reason, "*")
+	}
+
+	parts := []string{stackName, err.StackError.LogicalResourceId, err.StackError.ResourceType,
+		err.StackError.ResourceStatus, reason}
+	sum := sha256.Sum256([]byte(strings.Join(parts, "\x00")))
+
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,56 @@
+// Command benchmark is a CI gate for the analysis of very large stacks: it runs error extraction,
+// correlation and pattern detection on a synthetic stack history and fails if they take longer than
+// a second. The benchmarks of the extractor and correlator packages measure the steps in detail:
+//
+//	go run ./benchmark -stack-events 20000 -trail-events 20000
+//	go test -bench . ./extractor ./correlator
+package main
+
+import (
+	"flag"
+	"fmt"
+	"math/rand"
+	"os"
+	"time"
+
+	"cfn-root-cause/correlator"
+	"cfn-root-cause/extractor"
+	"cfn-root-cause/patterns"
+	"cfn-root-cause/synthetic"
+)
+
+// target is the duration a full analysis of the generated history should stay below
+const target = time.Second
+
+func main() {
+	stackEvents := flag.Int("stack-events", 10000, "number of stack events")
+	trailEvents := flag.Int("trail-events", 10000, "number of CloudTrail events")
+	failureRate := flag.Float64("failure-rate", 0.1, "share of stack events that are failures")
+	iterations := flag.Int("iterations", 5, "number of runs; the fastest is reported")
+	flag.Parse()
+
+	if *stackEvents <= 0 || *trailEvents < 0 || *iterations <= 0 || *failureRate < 0 || *failureRate > 1 {
+		fmt.Fprintln(os.Stderr, "Error: event counts and iterations must be positive, the failure rate between 0 and 1")
+		os.Exit(1)
+	}
+
+	random := rand.New(rand.NewSource(1))
+	events := synthetic.StackEvents(random, synthetic.Start, *stackEvents, *failureRate)
+	trail := synthetic.TrailEvents(random, synthetic.Start, *trailEvents, *stackEvents)
+
+	// The fastest run counts, so a busy CI machine does not fail the gate
+	var fastest time.Duration
+	for i := 0; i < *iterations; i++ {
+		start := time.Now()
+		correlated := correlator.CorrelateErrors(extractor.ExtractErrors(events), trail)
+		patterns.Detect(events, correlated, nil)
+		if elapsed := time.Since(start); i == 0 || elapsed < fastest {
+			fastest = elapsed
+		}
+	}
+
+	fmt.Printf("%d stack events, %d CloudTrail events: %s (target %s)\n", len(events), len(trail), fastest, target)
+	if fastest > target {
+		os.Exit(2)
+	}
+}
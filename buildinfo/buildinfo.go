@@ -0,0 +1,86 @@
+// Package buildinfo describes the build of the analyzer and checks GitHub for newer releases.
+// Version, Commit and Date are set at build time with ldflags, e.g.
+//
+//	go build -ldflags "-X cfn-root-cause/buildinfo.Version=v1.2.0 -X cfn-root-cause/buildinfo.Commit=$(git rev-parse HEAD)" ./main
+//
+// Builds without ldflags fall back to the VCS information the Go toolchain embeds.
+package buildinfo
+
+import (
+	"fmt"
+	"runtime"
+	"runtime/debug"
+	"strings"
+)
+
+// Version, Commit and Date are set with -ldflags "-X cfn-root-cause/buildinfo.Version=..."
+var (
+	Version = "dev"
+	Commit  = ""
+	Date    = ""
+)
+
+// Info describes a build
+type Info struct {
+	Version   string
+	Commit    string
+	Date      string
+	GoVersion string
+	Platform  string
+
+	// Modified is set for builds from a working tree with uncommitted changes
+	Modified bool
+}
+
+// Current returns the information of the running binary
+func Current() Info {
+	info := Info{
+		Version:   Version,
+		Commit:    Commit,
+		Date:      Date,
+		GoVersion: runtime.Version(),
+		Platform:  runtime.GOOS + "/" + runtime.GOARCH,
+	}
+
+	build, ok := debug.ReadBuildInfo()
+	if !ok {
+		return info
+	}
+	if info.Version == "dev" && build.Main.Version != "" && build.Main.Version != "(devel)" {
+		info.Version = build.Main.Version
+	}
+	for _, setting := range build.Settings {
+		switch setting.Key {
+		case "vcs.revision":
+			if info.Commit == "" {
+				info.Commit = setting.Value
+			}
+		case "vcs.time":
+			if info.Date == "" {
+				info.Date = setting.Value
+			}
+		case "vcs.modified":
+			info.Modified = setting.Value == "true"
+		}
+	}
+	return info
+}
+
+// String describes the build on several lines
+func (i Info) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "cfn-analyzer %s\n", i.Version)
+	if i.Commit != "" {
+		commit := i.Commit
+		if i.Modified {
+			commit += " (modified)"
+		}
+		fmt.Fprintf(&b, "Commit:     %s\n", commit)
+	}
+	if i.Date != "" {
+		fmt.Fprintf(&b, "Build date: %s\n", i.Date)
+	}
+	fmt.Fprintf(&b, "Go version: %s\n", i.GoVersion)
+	fmt.Fprintf(&b, "Platform:   %s\n", i.Platform)
+	return b.String()
+}
@@ -0,0 +1,102 @@
+package buildinfo
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// LatestReleaseURL is the GitHub API endpoint of the latest release
+const LatestReleaseURL = "https://api.github.com/repos/megaproaktiv/cfnrc/releases/latest"
+
+// requestTimeout limits how long the update check may take
+const requestTimeout = 10 * time.Second
+
+// Release is a published release of the analyzer
+type Release struct {
+	Tag         string    `json:"tag_name"`
+	URL         string    `json:"html_url"`
+	PublishedAt time.Time `json:"published_at"`
+}
+
+// LatestRelease returns the latest release published on GitHub
+func LatestRelease(ctx context.Context, endpoint string) (*Release, error) {
+	ctx, cancel := context.WithTimeout(ctx, requestTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create update check request: %w", err)
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("User-Agent", "cfn-analyzer/"+Version)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check for updates: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read update check response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to check for updates: %s returned %s", endpoint, resp.Status)
+	}
+
+	var release Release
+	if err := json.Unmarshal(body, &release); err != nil {
+		return nil, fmt.Errorf("failed to parse update check response: %w", err)
+	}
+	if release.Tag == "" {
+		return nil, fmt.Errorf("failed to check for updates: the latest release has no tag")
+	}
+	return &release, nil
+}
+
+// IsNewer reports whether version latest is newer than version current, both given as
+// semantic versions like v1.2.3. ok is false if either version cannot be parsed, e.g. for dev builds.
+func IsNewer(latest, current string) (newer bool, ok bool) {
+	l, ok := parseVersion(latest)
+	if !ok {
+		return false, false
+	}
+	c, ok := parseVersion(current)
+	if !ok {
+		return false, false
+	}
+	for i := range l {
+		if l[i] != c[i] {
+			return l[i] > c[i], true
+		}
+	}
+	return false, true
+}
+
+// parseVersion parses the major, minor and patch number of a version like v1.2.3 or 1.2.3-rc.1;
+// pre-release and build suffixes are ignored
+func parseVersion(version string) ([3]int, bool) {
+	var parsed [3]int
+	version = strings.TrimPrefix(strings.TrimSpace(version), "v")
+	if i := strings.IndexAny(version, "-+"); i >= 0 {
+		version = version[:i]
+	}
+	parts := strings.Split(version, ".")
+	if len(parts) == 0 || len(parts) > 3 {
+		return parsed, false
+	}
+	for i, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 {
+			return parsed, false
+		}
+		parsed[i] = n
+	}
+	return parsed, true
+}
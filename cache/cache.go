@@ -0,0 +1,90 @@
+// Package cache stores completed analyses so a failed stack operation that was analyzed before is
+// reported again without querying AWS, and the stack events of earlier analyses so only newer
+// events are fetched
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"cfn-root-cause/analyzer"
+)
+
+// dirName is the name of the cache directory inside the user cache directory
+const dirName = "analyses"
+
+// DefaultDir returns the default cache directory, e.g. ~/.cache/cfnrc/analyses on Linux
+func DefaultDir() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to determine cache directory: %w", err)
+	}
+	return filepath.Join(dir, "cfnrc", dirName), nil
+}
+
+// Key identifies the analysis of a stack operation by the stack ARN and the token of the operation,
+// so a stack that is deleted and created again under the same name gets a new key
+func Key(stackId, operationToken string) string {
+	sum := sha256.Sum256([]byte(stackId + "\n" + operationToken))
+	return hex.EncodeToString(sum[:])
+}
+
+// Load returns the cached analysis with the given key from dir; nil if there is none
+func Load(dir, key string) (*analyzer.StackAnalysis, error) {
+	path := filepath.Join(dir, key+".json")
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read cached analysis '%s': %w", path, err)
+	}
+
+	var analysis analyzer.StackAnalysis
+	if err := json.Unmarshal(data, &analysis); err != nil {
+		return nil, fmt.Errorf("failed to parse cached analysis '%s': %w", path, err)
+	}
+	return &analysis, nil
+}
+
+// Save stores the analysis under the given key in dir, creating the directory if needed
+func Save(dir, key string, analysis *analyzer.StackAnalysis) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("failed to create cache directory: %w", err)
+	}
+
+	data, err := json.Marshal(analysis)
+	if err != nil {
+		return fmt.Errorf("failed to encode analysis of %s: %w", analysis.StackName, err)
+	}
+
+	return writeFile(dir, key, data)
+}
+
+// writeFile writes the data to <dir>/<key>.json through a temporary file, so parallel runs never
+// read a partial entry
+func writeFile(dir, key string, data []byte) error {
+	path := filepath.Join(dir, key+".json")
+	tmp, err := os.CreateTemp(dir, key+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to write cache entry: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write cache entry '%s': %w", tmp.Name(), err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write cache entry '%s': %w", tmp.Name(), err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to write cache entry '%s': %w", path, err)
+	}
+	return nil
+}
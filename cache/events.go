@@ -0,0 +1,64 @@
+package cache
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation/types"
+)
+
+// eventsDirName is the name of the stack event cache directory inside the user cache directory
+const eventsDirName = "events"
+
+// DefaultEventsDir returns the default stack event cache directory, e.g. ~/.cache/cfnrc/events on Linux
+func DefaultEventsDir() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to determine cache directory: %w", err)
+	}
+	return filepath.Join(dir, "cfnrc", eventsDirName), nil
+}
+
+// LoadEvents returns the stack events stored under the given key in dir, newest first; nil if there are none
+func LoadEvents(dir, key string) ([]types.StackEvent, error) {
+	path := filepath.Join(dir, key+".json")
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read cached stack events '%s': %w", path, err)
+	}
+
+	var events []types.StackEvent
+	if err := json.Unmarshal(data, &events); err != nil {
+		return nil, fmt.Errorf("failed to parse cached stack events '%s': %w", path, err)
+	}
+	return events, nil
+}
+
+// SaveEvents stores the stack events under the given key in dir, creating the directory if needed
+func SaveEvents(dir, key string, events []types.StackEvent) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("failed to create cache directory: %w", err)
+	}
+
+	data, err := json.Marshal(events)
+	if err != nil {
+		return fmt.Errorf("failed to encode stack events: %w", err)
+	}
+	return writeFile(dir, key, data)
+}
+
+// RemoveEvents deletes the stack events stored under the given key in dir, if any
+func RemoveEvents(dir, key string) error {
+	path := filepath.Join(dir, key+".json")
+	if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("failed to remove cached stack events '%s': %w", path, err)
+	}
+	return nil
+}
@@ -4,18 +4,42 @@ package cfnclient
 import (
 	"context"
 	"fmt"
+	"strings"
+	"sync"
 
+	"cfn-root-cause/analyzer"
 	"cfn-root-cause/awserrors"
+	"cfn-root-cause/metrics"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
-	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/service/cloudformation"
 	"github.com/aws/aws-sdk-go-v2/service/cloudformation/types"
 )
 
+// FailedStackStatuses are the stack statuses left behind by a failed or rolled back operation
+var FailedStackStatuses = []types.StackStatus{
+	types.StackStatusCreateFailed,
+	types.StackStatusRollbackInProgress,
+	types.StackStatusRollbackFailed,
+	types.StackStatusRollbackComplete,
+	types.StackStatusDeleteFailed,
+	types.StackStatusUpdateFailed,
+	types.StackStatusUpdateRollbackInProgress,
+	types.StackStatusUpdateRollbackFailed,
+	types.StackStatusUpdateRollbackCompleteCleanupInProgress,
+	types.StackStatusUpdateRollbackComplete,
+	types.StackStatusImportRollbackInProgress,
+	types.StackStatusImportRollbackFailed,
+	types.StackStatusImportRollbackComplete,
+}
+
 // Client wraps the AWS CloudFormation client with additional functionality
 type Client struct {
 	cfn *cloudformation.Client
+
+	// stacks are the descriptions of the stacks described so far by name and ARN, reused by DescribeStack
+	mu     sync.Mutex
+	stacks map[string]*types.Stack
 }
 
 // CloudFormationAPI defines the interface for CloudFormation operations
@@ -23,22 +47,10 @@ type CloudFormationAPI interface {
 	DescribeStacks(ctx context.Context, params *cloudformation.DescribeStacksInput, optFns ...func(*cloudformation.Options)) (*cloudformation.DescribeStacksOutput, error)
 	DescribeStackEvents(ctx context.Context, params *cloudformation.DescribeStackEventsInput, optFns ...func(*cloudformation.Options)) (*cloudformation.DescribeStackEventsOutput, error)
 	ListStacks(ctx context.Context, params *cloudformation.ListStacksInput, optFns ...func(*cloudformation.Options)) (*cloudformation.ListStacksOutput, error)
-}
-
-// NewClient creates a new CloudFormation client using default AWS configuration
-// It uses standard AWS credential resolution (environment variables, profiles, IAM roles)
-// Requirements: 6.2, 6.4
-func NewClient(ctx context.Context) (*Client, error) {
-	cfg, err := config.LoadDefaultConfig(ctx)
-	if err != nil {
-		// Parse and return user-friendly error message for credential/config issues
-		awsErr := awserrors.ParseAWSError(err, "CloudFormation")
-		return nil, awsErr
-	}
-
-	return &Client{
-		cfn: cloudformation.NewFromConfig(cfg),
-	}, nil
+	GetTemplate(ctx context.Context, params *cloudformation.GetTemplateInput, optFns ...func(*cloudformation.Options)) (*cloudformation.GetTemplateOutput, error)
+	ContinueUpdateRollback(ctx context.Context, params *cloudformation.ContinueUpdateRollbackInput, optFns ...func(*cloudformation.Options)) (*cloudformation.ContinueUpdateRollbackOutput, error)
+	DeleteStack(ctx context.Context, params *cloudformation.DeleteStackInput, optFns ...func(*cloudformation.Options)) (*cloudformation.DeleteStackOutput, error)
+	DescribeType(ctx context.Context, params *cloudformation.DescribeTypeInput, optFns ...func(*cloudformation.Options)) (*cloudformation.DescribeTypeOutput, error)
 }
 
 // NewClientWithConfig creates a new CloudFormation client with a custom AWS config
@@ -48,6 +60,16 @@ func NewClientWithConfig(cfg aws.Config) *Client {
 	}
 }
 
+// StackName returns the name of a stack given by ARN, e.g. my-stack for
+// arn:aws:cloudformation:us-east-1:123456789012:stack/my-stack/<uuid>; other values are returned unchanged
+func StackName(stackId string) string {
+	parts := strings.Split(stackId, "/")
+	if len(parts) >= 2 && strings.HasPrefix(stackId, "arn:") {
+		return parts[1]
+	}
+	return stackId
+}
+
 // GetStackEvents retrieves all stack events for the specified stack name
 // It handles pagination to retrieve all events
 // Requirements: 6.4
@@ -63,6 +85,9 @@ func (c *Client) GetStackEvents(ctx context.Context, stackName string) ([]types.
 
 		output, err := c.cfn.DescribeStackEvents(ctx, input)
 		if err != nil {
+			if awserrors.IsThrottlingError(err) {
+				metrics.ThrottlesTotal.Inc("CloudFormation")
+			}
 			// Parse and return user-friendly error message
 			awsErr := awserrors.ParseAWSError(err, "CloudFormation")
 			return nil, fmt.Errorf("failed to describe stack events for '%s': %w", stackName, awsErr)
@@ -79,11 +104,442 @@ func (c *Client) GetStackEvents(ctx context.Context, stackName string) ([]types.
 	return allEvents, nil
 }
 
+// GetStackEventsSince retrieves the stack events newer than the event with the given ID, newest
+// first. Pagination stops at that event; found reports whether it was reached, otherwise all
+// events of the stack are returned.
+func (c *Client) GetStackEventsSince(ctx context.Context, stackName, eventId string) (events []types.StackEvent, found bool, err error) {
+	var nextToken *string
+
+	for {
+		output, err := c.cfn.DescribeStackEvents(ctx, &cloudformation.DescribeStackEventsInput{
+			StackName: aws.String(stackName),
+			NextToken: nextToken,
+		})
+		if err != nil {
+			if awserrors.IsThrottlingError(err) {
+				metrics.ThrottlesTotal.Inc("CloudFormation")
+			}
+			awsErr := awserrors.ParseAWSError(err, "CloudFormation")
+			return nil, false, fmt.Errorf("failed to describe stack events for '%s': %w", stackName, awsErr)
+		}
+
+		for _, event := range output.StackEvents {
+			if aws.ToString(event.EventId) == eventId {
+				return events, true, nil
+			}
+			events = append(events, event)
+		}
+
+		if output.NextToken == nil {
+			break
+		}
+		nextToken = output.NextToken
+	}
+
+	return events, false, nil
+}
+
+// ListStacksWithStatus retrieves the summaries of all stacks in one of the given statuses
+// It handles pagination to retrieve all stacks
+func (c *Client) ListStacksWithStatus(ctx context.Context, statuses []types.StackStatus) ([]types.StackSummary, error) {
+	var summaries []types.StackSummary
+	var nextToken *string
+
+	for {
+		output, err := c.cfn.ListStacks(ctx, &cloudformation.ListStacksInput{
+			StackStatusFilter: statuses,
+			NextToken:         nextToken,
+		})
+		if err != nil {
+			if awserrors.IsThrottlingError(err) {
+				metrics.ThrottlesTotal.Inc("CloudFormation")
+			}
+			awsErr := awserrors.ParseAWSError(err, "CloudFormation")
+			return nil, fmt.Errorf("failed to list CloudFormation stacks: %w", awsErr)
+		}
+
+		summaries = append(summaries, output.StackSummaries...)
+
+		if output.NextToken == nil {
+			break
+		}
+		nextToken = output.NextToken
+	}
+
+	return summaries, nil
+}
+
+// GetLatestOperationEvents retrieves the stack events of the most recent stack operation,
+// newest first. Pagination stops at the event that started the operation, so only the
+// pages needed are fetched.
+func (c *Client) GetLatestOperationEvents(ctx context.Context, stackName string) ([]types.StackEvent, error) {
+	var events []types.StackEvent
+	var nextToken *string
+
+	for {
+		output, err := c.cfn.DescribeStackEvents(ctx, &cloudformation.DescribeStackEventsInput{
+			StackName: aws.String(stackName),
+			NextToken: nextToken,
+		})
+		if err != nil {
+			if awserrors.IsThrottlingError(err) {
+				metrics.ThrottlesTotal.Inc("CloudFormation")
+			}
+			awsErr := awserrors.ParseAWSError(err, "CloudFormation")
+			return nil, fmt.Errorf("failed to describe stack events for '%s': %w", stackName, awsErr)
+		}
+
+		for _, event := range output.StackEvents {
+			events = append(events, event)
+			if IsOperationStart(event) {
+				return events, nil
+			}
+		}
+
+		if output.NextToken == nil {
+			break
+		}
+		nextToken = output.NextToken
+	}
+
+	return events, nil
+}
+
+// IsOperationStart reports whether the event is the stack-level event that started an operation
+func IsOperationStart(event types.StackEvent) bool {
+	if aws.ToString(event.ResourceType) != "AWS::CloudFormation::Stack" ||
+		aws.ToString(event.PhysicalResourceId) != aws.ToString(event.StackId) {
+		return false
+	}
+
+	switch event.ResourceStatus {
+	case types.ResourceStatusCreateInProgress, types.ResourceStatusUpdateInProgress,
+		types.ResourceStatusDeleteInProgress, types.ResourceStatusImportInProgress:
+		return true
+	}
+	return false
+}
+
+// Operation is a stack operation with its events, newest first
+type Operation struct {
+	// Token is the client request token of the operation, or the event ID of its start if it has none
+	Token string
+
+	Events []types.StackEvent
+}
+
+// Start returns the event that started the operation
+func (o Operation) Start() types.StackEvent {
+	return o.Events[len(o.Events)-1]
+}
+
+// Status returns the latest status of the stack in the operation
+func (o Operation) Status() types.ResourceStatus {
+	stackId := aws.ToString(o.Start().StackId)
+	for _, event := range o.Events {
+		if aws.ToString(event.PhysicalResourceId) == stackId && aws.ToString(event.ResourceType) == "AWS::CloudFormation::Stack" {
+			return event.ResourceStatus
+		}
+	}
+	return o.Start().ResourceStatus
+}
+
+// SplitOperations splits stack events, newest first as returned by GetStackEvents, into the
+// operations that produced them, newest first. Events older than the earliest operation start
+// still in the event history belong to no operation and are dropped.
+func SplitOperations(events []types.StackEvent) []Operation {
+	var operations []Operation
+	var current []types.StackEvent
+	for _, event := range events {
+		current = append(current, event)
+		if !IsOperationStart(event) {
+			continue
+		}
+
+		token := aws.ToString(event.ClientRequestToken)
+		if token == "" {
+			token = aws.ToString(event.EventId)
+		}
+		operations = append(operations, Operation{Token: token, Events: current})
+		current = nil
+	}
+	return operations
+}
+
+// GetTemplateBody retrieves the original template of the stack as submitted by the user.
+// If changeSetName is set, the template of that change set is returned instead of the
+// template currently associated with the stack.
+func (c *Client) GetTemplateBody(ctx context.Context, stackName, changeSetName string) (string, error) {
+	input := &cloudformation.GetTemplateInput{
+		StackName:     aws.String(stackName),
+		TemplateStage: types.TemplateStageOriginal,
+	}
+	if changeSetName != "" {
+		input.ChangeSetName = aws.String(changeSetName)
+	}
+
+	output, err := c.cfn.GetTemplate(ctx, input)
+	if err != nil {
+		if awserrors.IsThrottlingError(err) {
+			metrics.ThrottlesTotal.Inc("CloudFormation")
+		}
+		awsErr := awserrors.ParseAWSError(err, "CloudFormation")
+		return "", fmt.Errorf("failed to get template for '%s': %w", stackName, awsErr)
+	}
+
+	return aws.ToString(output.TemplateBody), nil
+}
+
+// DescribeType retrieves the registration of a resource or module type as used in the account,
+// such as its version, deprecation status and execution role
+func (c *Client) DescribeType(ctx context.Context, typeName string) (*analyzer.RegistryType, error) {
+	registryType := types.RegistryTypeResource
+	if strings.HasSuffix(typeName, "::MODULE") {
+		registryType = types.RegistryTypeModule
+	}
+
+	output, err := c.cfn.DescribeType(ctx, &cloudformation.DescribeTypeInput{
+		Type:     registryType,
+		TypeName: aws.String(typeName),
+	})
+	if err != nil {
+		if awserrors.IsThrottlingError(err) {
+			metrics.ThrottlesTotal.Inc("CloudFormation")
+		}
+		awsErr := awserrors.ParseAWSError(err, "CloudFormation")
+		return nil, fmt.Errorf("failed to describe type '%s': %w", typeName, awsErr)
+	}
+
+	registration := &analyzer.RegistryType{
+		TypeName:            aws.ToString(output.TypeName),
+		Arn:                 aws.ToString(output.Arn),
+		Visibility:          string(output.Visibility),
+		DefaultVersionId:    aws.ToString(output.DefaultVersionId),
+		PublicVersionNumber: aws.ToString(output.PublicVersionNumber),
+		LatestPublicVersion: aws.ToString(output.LatestPublicVersion),
+		DeprecatedStatus:    string(output.DeprecatedStatus),
+		ExecutionRoleArn:    aws.ToString(output.ExecutionRoleArn),
+		PublisherId:         aws.ToString(output.PublisherId),
+		AutoUpdate:          aws.ToBool(output.AutoUpdate),
+	}
+	if output.LoggingConfig != nil {
+		registration.HandlerLogGroup = aws.ToString(output.LoggingConfig.LogGroupName)
+	}
+	return registration, nil
+}
+
+// GetChangeSetParameters retrieves the parameter values a change set of the stack was created with
+func (c *Client) GetChangeSetParameters(ctx context.Context, stackName, changeSetName string) ([]types.Parameter, error) {
+	output, err := c.cfn.DescribeChangeSet(ctx, &cloudformation.DescribeChangeSetInput{
+		StackName:     aws.String(stackName),
+		ChangeSetName: aws.String(changeSetName),
+	})
+	if err != nil {
+		if awserrors.IsThrottlingError(err) {
+			metrics.ThrottlesTotal.Inc("CloudFormation")
+		}
+		awsErr := awserrors.ParseAWSError(err, "CloudFormation")
+		return nil, fmt.Errorf("failed to describe change set of '%s': %w", stackName, awsErr)
+	}
+
+	return output.Parameters, nil
+}
+
+// GetStackPolicy retrieves the stack policy body of a stack, or "" if the stack has no policy
+func (c *Client) GetStackPolicy(ctx context.Context, stackName string) (string, error) {
+	output, err := c.cfn.GetStackPolicy(ctx, &cloudformation.GetStackPolicyInput{StackName: aws.String(stackName)})
+	if err != nil {
+		if awserrors.IsThrottlingError(err) {
+			metrics.ThrottlesTotal.Inc("CloudFormation")
+		}
+		awsErr := awserrors.ParseAWSError(err, "CloudFormation")
+		return "", fmt.Errorf("failed to get stack policy of '%s': %w", stackName, awsErr)
+	}
+
+	return aws.ToString(output.StackPolicyBody), nil
+}
+
+// DescribeStackResource returns the current state of a resource of the stack by its logical ID,
+// or nil if the stack has no such resource, e.g. because it was removed from the template
+func (c *Client) DescribeStackResource(ctx context.Context, stackName, logicalId string) (*types.StackResourceDetail, error) {
+	output, err := c.cfn.DescribeStackResource(ctx, &cloudformation.DescribeStackResourceInput{
+		StackName:         aws.String(stackName),
+		LogicalResourceId: aws.String(logicalId),
+	})
+	if err != nil {
+		if awserrors.IsThrottlingError(err) {
+			metrics.ThrottlesTotal.Inc("CloudFormation")
+		}
+		awsErr := awserrors.ParseAWSError(err, "CloudFormation")
+		// CloudFormation answers "Resource <id> does not exist for stack <name>"
+		if awsErr.AWSErrorCode == "ValidationError" && strings.Contains(awsErr.Message, "does not exist for stack") {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to describe resource '%s' of '%s': %w", logicalId, stackName, awsErr)
+	}
+
+	return output.StackResourceDetail, nil
+}
+
+// FindResourceStack returns the stack managing the resource with the given physical ID and its
+// logical ID, or "" if no stack in the account and region manages it
+func (c *Client) FindResourceStack(ctx context.Context, physicalId string) (stackName, logicalId string, err error) {
+	output, err := c.cfn.DescribeStackResources(ctx, &cloudformation.DescribeStackResourcesInput{
+		PhysicalResourceId: aws.String(physicalId),
+	})
+	if err != nil {
+		if awserrors.IsThrottlingError(err) {
+			metrics.ThrottlesTotal.Inc("CloudFormation")
+		}
+		awsErr := awserrors.ParseAWSError(err, "CloudFormation")
+		// CloudFormation answers "Stack for <id> does not exist" for unmanaged resources
+		if awsErr.AWSErrorCode == "ValidationError" && strings.Contains(awsErr.Message, "does not exist") {
+			return "", "", nil
+		}
+		return "", "", fmt.Errorf("failed to find the stack of resource '%s': %w", physicalId, awsErr)
+	}
+
+	for _, resource := range output.StackResources {
+		if aws.ToString(resource.PhysicalResourceId) == physicalId {
+			return aws.ToString(resource.StackName), aws.ToString(resource.LogicalResourceId), nil
+		}
+	}
+	return "", "", nil
+}
+
+// FindExport returns the export of the given name in the account and region, or nil if no stack exports it
+// It handles pagination to search all exports
+func (c *Client) FindExport(ctx context.Context, exportName string) (*types.Export, error) {
+	var nextToken *string
+
+	for {
+		output, err := c.cfn.ListExports(ctx, &cloudformation.ListExportsInput{NextToken: nextToken})
+		if err != nil {
+			if awserrors.IsThrottlingError(err) {
+				metrics.ThrottlesTotal.Inc("CloudFormation")
+			}
+			awsErr := awserrors.ParseAWSError(err, "CloudFormation")
+			return nil, fmt.Errorf("failed to list CloudFormation exports: %w", awsErr)
+		}
+
+		for i := range output.Exports {
+			if aws.ToString(output.Exports[i].Name) == exportName {
+				return &output.Exports[i], nil
+			}
+		}
+
+		if output.NextToken == nil {
+			return nil, nil
+		}
+		nextToken = output.NextToken
+	}
+}
+
+// ListImports returns the names of the stacks importing the given export, or none if it is not imported
+// It handles pagination to retrieve all importing stacks
+func (c *Client) ListImports(ctx context.Context, exportName string) ([]string, error) {
+	var stackNames []string
+	var nextToken *string
+
+	for {
+		output, err := c.cfn.ListImports(ctx, &cloudformation.ListImportsInput{
+			ExportName: aws.String(exportName),
+			NextToken:  nextToken,
+		})
+		if err != nil {
+			// CloudFormation reports exports without importers as a validation error
+			if strings.Contains(err.Error(), "is not imported by any stack") {
+				return nil, nil
+			}
+			if awserrors.IsThrottlingError(err) {
+				metrics.ThrottlesTotal.Inc("CloudFormation")
+			}
+			awsErr := awserrors.ParseAWSError(err, "CloudFormation")
+			return nil, fmt.Errorf("failed to list the imports of export '%s': %w", exportName, awsErr)
+		}
+
+		stackNames = append(stackNames, output.Imports...)
+
+		if output.NextToken == nil {
+			break
+		}
+		nextToken = output.NextToken
+	}
+
+	return stackNames, nil
+}
+
+// ContinueUpdateRollback resumes the failed update rollback of a stack, skipping the given resources.
+// This modifies the stack and must only be called after explicit confirmation by the user.
+func (c *Client) ContinueUpdateRollback(ctx context.Context, stackName string, resourcesToSkip []string) error {
+	_, err := c.cfn.ContinueUpdateRollback(ctx, &cloudformation.ContinueUpdateRollbackInput{
+		StackName:       aws.String(stackName),
+		ResourcesToSkip: resourcesToSkip,
+	})
+	if err != nil {
+		awsErr := awserrors.ParseAWSError(err, "CloudFormation")
+		return fmt.Errorf("failed to continue update rollback of '%s': %w", stackName, awsErr)
+	}
+	return nil
+}
+
+// DeleteStack deletes a stack.
+// This modifies the account and must only be called after explicit confirmation by the user.
+func (c *Client) DeleteStack(ctx context.Context, stackName string) error {
+	_, err := c.cfn.DeleteStack(ctx, &cloudformation.DeleteStackInput{
+		StackName: aws.String(stackName),
+	})
+	if err != nil {
+		awsErr := awserrors.ParseAWSError(err, "CloudFormation")
+		return fmt.Errorf("failed to delete stack '%s': %w", stackName, awsErr)
+	}
+	return nil
+}
+
 // DescribeStacks retrieves stack information for the specified stack name
 func (c *Client) DescribeStacks(ctx context.Context, params *cloudformation.DescribeStacksInput, optFns ...func(*cloudformation.Options)) (*cloudformation.DescribeStacksOutput, error) {
 	return c.cfn.DescribeStacks(ctx, params, optFns...)
 }
 
+// DescribeStack returns the description of a stack given by name or ARN. The description recorded
+// by RememberStack or an earlier call is reused, so the phases of an analysis describe each stack
+// only once; use DescribeStacks for the current status of a stack that is still changing.
+func (c *Client) DescribeStack(ctx context.Context, stackName string) (*types.Stack, error) {
+	c.mu.Lock()
+	stack := c.stacks[stackName]
+	c.mu.Unlock()
+	if stack != nil {
+		return stack, nil
+	}
+
+	output, err := c.cfn.DescribeStacks(ctx, &cloudformation.DescribeStacksInput{StackName: aws.String(stackName)})
+	if err != nil {
+		if awserrors.IsThrottlingError(err) {
+			metrics.ThrottlesTotal.Inc("CloudFormation")
+		}
+		awsErr := awserrors.ParseAWSError(err, "CloudFormation")
+		return nil, fmt.Errorf("failed to describe stack '%s': %w", stackName, awsErr)
+	}
+	if len(output.Stacks) == 0 {
+		return nil, fmt.Errorf("stack '%s' not found", stackName)
+	}
+
+	stack = &output.Stacks[0]
+	c.RememberStack(stack)
+	return stack, nil
+}
+
+// RememberStack records the description of a stack, e.g. from validating its name, for DescribeStack
+func (c *Client) RememberStack(stack *types.Stack) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.stacks == nil {
+		c.stacks = make(map[string]*types.Stack)
+	}
+	c.stacks[aws.ToString(stack.StackName)] = stack
+	c.stacks[aws.ToString(stack.StackId)] = stack
+}
+
 // ListStacks lists all stacks with the specified status filters
 func (c *Client) ListStacks(ctx context.Context, params *cloudformation.ListStacksInput, optFns ...func(*cloudformation.Options)) (*cloudformation.ListStacksOutput, error) {
 	return c.cfn.ListStacks(ctx, params, optFns...)
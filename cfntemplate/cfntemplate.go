@@ -0,0 +1,238 @@
+// Package cfntemplate parses CloudFormation templates in JSON or YAML, including the YAML short
+// forms of intrinsic functions such as !Ref and !GetAtt
+package cfntemplate
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Template is the part of a CloudFormation template the analyzer inspects
+type Template struct {
+	Parameters map[string]Parameter
+	Resources  map[string]Resource
+	Outputs    map[string]interface{}
+	Mappings   map[string]interface{}
+}
+
+// Parameter is the definition of a template parameter. Numeric constraints are kept as written.
+type Parameter struct {
+	Type                  string
+	Default               string
+	HasDefault            bool
+	AllowedValues         []string
+	AllowedPattern        string
+	MinLength             string
+	MaxLength             string
+	MinValue              string
+	MaxValue              string
+	NoEcho                bool
+	ConstraintDescription string
+}
+
+// Resource is a resource of a template with its properties as parsed JSON values
+type Resource struct {
+	Type       string
+	Properties map[string]interface{}
+	DependsOn  []string
+	Condition  string
+}
+
+// Parse parses a template body in JSON or YAML
+func Parse(body string) (*Template, error) {
+	var raw map[string]interface{}
+	trimmed := strings.TrimSpace(body)
+	if strings.HasPrefix(trimmed, "{") {
+		if err := json.Unmarshal([]byte(trimmed), &raw); err != nil {
+			return nil, fmt.Errorf("failed to parse JSON template: %w", err)
+		}
+	} else {
+		var node yaml.Node
+		if err := yaml.Unmarshal([]byte(body), &node); err != nil {
+			return nil, fmt.Errorf("failed to parse YAML template: %w", err)
+		}
+		value, err := convert(&node)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse YAML template: %w", err)
+		}
+		var ok bool
+		if raw, ok = value.(map[string]interface{}); !ok {
+			return nil, fmt.Errorf("failed to parse YAML template: not a mapping")
+		}
+	}
+
+	template := &Template{
+		Parameters: map[string]Parameter{},
+		Resources:  map[string]Resource{},
+		Outputs:    map[string]interface{}{},
+		Mappings:   map[string]interface{}{},
+	}
+	for name, value := range mapOf(raw["Parameters"]) {
+		template.Parameters[name] = parseParameter(mapOf(value))
+	}
+	for name, value := range mapOf(raw["Resources"]) {
+		template.Resources[name] = parseResource(mapOf(value))
+	}
+	for name, value := range mapOf(raw["Outputs"]) {
+		template.Outputs[name] = value
+	}
+	for name, value := range mapOf(raw["Mappings"]) {
+		template.Mappings[name] = value
+	}
+
+	return template, nil
+}
+
+// parseParameter reads a parameter definition
+func parseParameter(definition map[string]interface{}) Parameter {
+	parameter := Parameter{
+		Type:                  scalar(definition["Type"]),
+		AllowedPattern:        scalar(definition["AllowedPattern"]),
+		MinLength:             scalar(definition["MinLength"]),
+		MaxLength:             scalar(definition["MaxLength"]),
+		MinValue:              scalar(definition["MinValue"]),
+		MaxValue:              scalar(definition["MaxValue"]),
+		NoEcho:                strings.EqualFold(scalar(definition["NoEcho"]), "true"),
+		ConstraintDescription: scalar(definition["ConstraintDescription"]),
+	}
+	if value, ok := definition["Default"]; ok {
+		parameter.Default = scalar(value)
+		parameter.HasDefault = true
+	}
+	if values, ok := definition["AllowedValues"].([]interface{}); ok {
+		for _, value := range values {
+			parameter.AllowedValues = append(parameter.AllowedValues, scalar(value))
+		}
+	}
+	return parameter
+}
+
+// parseResource reads a resource definition
+func parseResource(definition map[string]interface{}) Resource {
+	resource := Resource{
+		Type:       scalar(definition["Type"]),
+		Properties: mapOf(definition["Properties"]),
+		Condition:  scalar(definition["Condition"]),
+	}
+	switch dependsOn := definition["DependsOn"].(type) {
+	case string:
+		resource.DependsOn = []string{dependsOn}
+	case []interface{}:
+		for _, value := range dependsOn {
+			resource.DependsOn = append(resource.DependsOn, scalar(value))
+		}
+	}
+	return resource
+}
+
+// mapOf returns the value as a mapping, or nil if it is none
+func mapOf(value interface{}) map[string]interface{} {
+	m, _ := value.(map[string]interface{})
+	return m
+}
+
+// scalar formats a scalar value as written in the template; other values yield ""
+func scalar(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case float64:
+		return strings.TrimSuffix(strings.TrimRight(fmt.Sprintf("%f", v), "0"), ".")
+	case bool, int:
+		return fmt.Sprint(v)
+	default:
+		return ""
+	}
+}
+
+// convert turns a YAML node into JSON-like values. Short-form intrinsic functions become their
+// long form, e.g. "!Ref Bucket" becomes {"Ref": "Bucket"} and "!GetAtt Role.Arn" becomes
+// {"Fn::GetAtt": ["Role", "Arn"]}.
+func convert(node *yaml.Node) (interface{}, error) {
+	switch node.Kind {
+	case yaml.DocumentNode:
+		if len(node.Content) == 0 {
+			return nil, nil
+		}
+		return convert(node.Content[0])
+	case yaml.AliasNode:
+		return convert(node.Alias)
+	}
+
+	value, err := convertUntagged(node)
+	if err != nil {
+		return nil, err
+	}
+
+	if !strings.HasPrefix(node.Tag, "!") || strings.HasPrefix(node.Tag, "!!") {
+		return value, nil
+	}
+	function := strings.TrimPrefix(node.Tag, "!")
+	switch function {
+	case "Ref", "Condition":
+		return map[string]interface{}{function: value}, nil
+	case "GetAtt":
+		if s, ok := value.(string); ok {
+			resource, attribute, _ := strings.Cut(s, ".")
+			value = []interface{}{resource, attribute}
+		}
+	}
+	return map[string]interface{}{"Fn::" + function: value}, nil
+}
+
+// convertUntagged converts a node without regard to a custom tag
+func convertUntagged(node *yaml.Node) (interface{}, error) {
+	switch node.Kind {
+	case yaml.MappingNode:
+		m := make(map[string]interface{}, len(node.Content)/2)
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			value, err := convert(node.Content[i+1])
+			if err != nil {
+				return nil, err
+			}
+			m[node.Content[i].Value] = value
+		}
+		return m, nil
+	case yaml.SequenceNode:
+		s := make([]interface{}, 0, len(node.Content))
+		for _, item := range node.Content {
+			value, err := convert(item)
+			if err != nil {
+				return nil, err
+			}
+			s = append(s, value)
+		}
+		return s, nil
+	case yaml.ScalarNode:
+		if strings.HasPrefix(node.Tag, "!") && !strings.HasPrefix(node.Tag, "!!") {
+			// Arguments of short-form functions are strings, e.g. !Sub '${AWS::StackName}-bucket'
+			return node.Value, nil
+		}
+		var value interface{}
+		if err := node.Decode(&value); err != nil {
+			return nil, fmt.Errorf("line %d: %w", node.Line, err)
+		}
+		return normalize(value), nil
+	default:
+		return nil, fmt.Errorf("line %d: unsupported YAML node", node.Line)
+	}
+}
+
+// normalize converts decoded YAML scalars to the types encoding/json produces
+func normalize(value interface{}) interface{} {
+	switch v := value.(type) {
+	case int:
+		return float64(v)
+	case int64:
+		return float64(v)
+	case uint64:
+		return float64(v)
+	default:
+		return v
+	}
+}
@@ -0,0 +1,146 @@
+package cfntemplate
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// subVariablePattern matches the variables of Fn::Sub strings, e.g. ${Bucket} or ${Role.Arn}
+var subVariablePattern = regexp.MustCompile(`\$\{([^!}][^}]*)\}`)
+
+// Dependency is a reference from one resource to another
+type Dependency struct {
+	From string
+	To   string
+
+	// Path is where the reference is made, e.g. "Properties.Role" or "DependsOn"
+	Path string
+
+	// Via is how the reference is made, e.g. "DependsOn", "Ref Role" or "Fn::GetAtt Role.Arn"
+	Via string
+}
+
+// String describes the dependency, e.g. "Function -> Role (Properties.Role: Fn::GetAtt Role.Arn)"
+func (d Dependency) String() string {
+	if d.Path == d.Via {
+		return fmt.Sprintf("%s -> %s (%s)", d.From, d.To, d.Via)
+	}
+	return fmt.Sprintf("%s -> %s (%s: %s)", d.From, d.To, d.Path, d.Via)
+}
+
+// Dependencies returns the references between the resources of the template through DependsOn,
+// Ref, Fn::GetAtt and Fn::Sub, ordered by resource and path
+func (t *Template) Dependencies() []Dependency {
+	var dependencies []Dependency
+
+	for name, resource := range t.Resources {
+		for _, target := range resource.DependsOn {
+			if _, ok := t.Resources[target]; ok {
+				dependencies = append(dependencies, Dependency{From: name, To: target, Path: "DependsOn", Via: "DependsOn"})
+			}
+		}
+		for property, value := range resource.Properties {
+			t.collectReferences(name, "Properties."+property, value, &dependencies)
+		}
+	}
+
+	sort.Slice(dependencies, func(i, j int) bool {
+		a, b := dependencies[i], dependencies[j]
+		if a.From != b.From {
+			return a.From < b.From
+		}
+		if a.Path != b.Path {
+			return a.Path < b.Path
+		}
+		return a.To < b.To
+	})
+	return dependencies
+}
+
+// collectReferences adds the references to resources made in a property value
+func (t *Template) collectReferences(from, path string, value interface{}, dependencies *[]Dependency) {
+	add := func(target, via string) {
+		if _, ok := t.Resources[target]; ok {
+			*dependencies = append(*dependencies, Dependency{From: from, To: target, Path: path, Via: via})
+		}
+	}
+
+	switch v := value.(type) {
+	case map[string]interface{}:
+		if len(v) == 1 {
+			if target, ok := v["Ref"].(string); ok {
+				add(target, "Ref "+target)
+				return
+			}
+			if args, ok := v["Fn::GetAtt"]; ok {
+				if target, attribute := getAttTarget(args); target != "" {
+					add(target, "Fn::GetAtt "+target+"."+attribute)
+				}
+				return
+			}
+			if args, ok := v["Fn::Sub"]; ok {
+				t.collectSubReferences(args, add)
+				if list, ok := args.([]interface{}); ok && len(list) == 2 {
+					t.collectReferences(from, path, list[1], dependencies)
+				}
+				return
+			}
+		}
+		for key, item := range v {
+			itemPath := path + "." + key
+			if strings.HasPrefix(key, "Fn::") {
+				itemPath = path
+			}
+			t.collectReferences(from, itemPath, item, dependencies)
+		}
+	case []interface{}:
+		for i, item := range v {
+			t.collectReferences(from, fmt.Sprintf("%s[%d]", path, i), item, dependencies)
+		}
+	}
+}
+
+// collectSubReferences adds the resources referenced by the variables of a Fn::Sub string.
+// Variables defined in the variable map of the long form are not references.
+func (t *Template) collectSubReferences(args interface{}, add func(target, via string)) {
+	var text string
+	var local map[string]interface{}
+	switch v := args.(type) {
+	case string:
+		text = v
+	case []interface{}:
+		if len(v) > 0 {
+			text, _ = v[0].(string)
+		}
+		if len(v) > 1 {
+			local, _ = v[1].(map[string]interface{})
+		}
+	}
+
+	for _, match := range subVariablePattern.FindAllStringSubmatch(text, -1) {
+		target, _, _ := strings.Cut(match[1], ".")
+		if _, ok := local[match[1]]; ok {
+			continue
+		}
+		add(target, "Fn::Sub ${"+match[1]+"}")
+	}
+}
+
+// getAttTarget returns the resource and attribute of Fn::GetAtt arguments, given as a list or
+// as "Resource.Attribute"
+func getAttTarget(args interface{}) (string, string) {
+	switch v := args.(type) {
+	case string:
+		resource, attribute, _ := strings.Cut(v, ".")
+		return resource, attribute
+	case []interface{}:
+		if len(v) == 2 {
+			resource, _ := v[0].(string)
+			attribute, _ := v[1].(string)
+			return resource, attribute
+		}
+	}
+	return "", ""
+}
@@ -0,0 +1,218 @@
+// Package classify derives error codes, request IDs, failure categories and retry advice from correlated errors
+package classify
+
+import (
+	"regexp"
+	"strings"
+
+	"cfn-root-cause/analyzer"
+)
+
+// Failure categories returned by Category
+const (
+	CategoryPermissions = "permissions"
+	CategoryValidation  = "validation"
+	CategoryLimit       = "limit"
+	CategoryConflict    = "conflict"
+	CategoryNotFound    = "not-found"
+	CategoryThrottling  = "throttling"
+	CategoryTimeout     = "timeout"
+	CategoryInternal    = "internal"
+	CategoryCancelled   = "cancelled"
+	CategoryOther       = "other"
+)
+
+// categories lists the failure categories in the order of the constants
+var categories = []string{
+	CategoryPermissions, CategoryValidation, CategoryLimit, CategoryConflict, CategoryNotFound,
+	CategoryThrottling, CategoryTimeout, CategoryInternal, CategoryCancelled, CategoryOther,
+}
+
+// Categories returns the failure categories returned by Category
+func Categories() []string {
+	return append([]string(nil), categories...)
+}
+
+// categoryRule assigns a category when the error code or message contains one of the patterns
+type categoryRule struct {
+	category string
+	patterns []string
+}
+
+// categoryRules are checked in order; the first matching rule wins.
+// Patterns are compared against the lower-cased error code and message.
+var categoryRules = []categoryRule{
+	{CategoryCancelled, []string{"resource creation cancelled", "resource update cancelled", "resource deletion cancelled"}},
+	{CategoryThrottling, []string{"throttl", "rate exceeded", "too many requests", "requestlimitexceeded"}},
+	{CategoryPermissions, []string{"accessdenied", "access denied", "unauthorized", "not authorized", "forbidden", "cannot be assumed"}},
+	{CategoryLimit, []string{"limitexceeded", "limit exceeded", "quota", "maximum number"}},
+	{CategoryConflict, []string{"alreadyexists", "already exists", "already in use", "conflict", "resourceinuse"}},
+	{CategoryNotFound, []string{"notfound", "not found", "does not exist", "nosuch"}},
+	{CategoryValidation, []string{"validation", "invalid", "malformed", "must be", "not supported", "unsupported"}},
+	{CategoryTimeout, []string{"timed out", "timeout", "did not stabilize", "failed to stabilize"}},
+	{CategoryInternal, []string{"internalfailure", "internal failure", "internalerror", "internal error", "serviceunavailable", "generalserviceexception", "handler returned message: \"null\""}},
+}
+
+// errorCodeRegex extracts error codes embedded in CloudFormation status reasons, e.g.
+// "(Service: Lambda, Status Code: 400, Request ID: ..., HandlerErrorCode: InvalidRequest)"
+var errorCodeRegex = regexp.MustCompile(`(?:HandlerErrorCode|Error Code|ErrorCode):\s*([A-Za-z][A-Za-z0-9.]*)`)
+
+// ErrorCode returns the error code of the failed API call: the CloudTrail error code if
+// available, otherwise a code embedded in the status reason, or "" if none is known
+func ErrorCode(err analyzer.CorrelatedError) string {
+	if err.CloudTrailEvent != nil && err.CloudTrailEvent.ErrorCode != "" {
+		return err.CloudTrailEvent.ErrorCode
+	}
+
+	if match := errorCodeRegex.FindStringSubmatch(err.StackError.ResourceStatusReason); match != nil {
+		return match[1]
+	}
+
+	return ""
+}
+
+// requestIDRegex extracts request IDs embedded in CloudFormation status reasons, e.g.
+// "(Service: Lambda, Status Code: 400, Request ID: 3f1c...)" or "RequestId: 3f1c..."
+var requestIDRegex = regexp.MustCompile(`(?i)Request ?ID:\s*([A-Za-z0-9][A-Za-z0-9-]*)`)
+
+// RequestID returns the AWS request ID of the failed API call: the CloudTrail request ID if
+// available, otherwise a request ID embedded in the status reason, or "" if none is known
+func RequestID(err analyzer.CorrelatedError) string {
+	if err.CloudTrailEvent != nil && err.CloudTrailEvent.RequestID != "" {
+		return err.CloudTrailEvent.RequestID
+	}
+
+	return ReasonRequestID(err.StackError.ResourceStatusReason)
+}
+
+// ReasonRequestID returns the request ID embedded in a CloudFormation status reason, or "" if it has none
+func ReasonRequestID(reason string) string {
+	if match := requestIDRegex.FindStringSubmatch(reason); match != nil {
+		return match[1]
+	}
+	return ""
+}
+
+// Category classifies the error by its error code and messages, unless a classification rule
+// assigned a category
+func Category(err analyzer.CorrelatedError) string {
+	if err.Classification != nil && err.Classification.Category != "" {
+		return err.Classification.Category
+	}
+	return BuiltinCategory(err)
+}
+
+// BuiltinCategory classifies the error by its error code and messages, ignoring classification rules
+func BuiltinCategory(err analyzer.CorrelatedError) string {
+	text := strings.ToLower(strings.Join([]string{
+		ErrorCode(err),
+		err.DetailedMessage,
+		err.StackError.ResourceStatusReason,
+	}, " "))
+
+	for _, rule := range categoryRules {
+		for _, pattern := range rule.patterns {
+			if strings.Contains(text, pattern) {
+				return rule.category
+			}
+		}
+	}
+
+	return CategoryOther
+}
+
+// eventSources maps resource type namespaces to the CloudTrail event sources of their services,
+// without ".amazonaws.com", where they differ from the lower-case namespace
+var eventSources = map[string]string{
+	"ApiGatewayV2":           "apigateway",
+	"Cognito":                "cognito-idp",
+	"ElasticLoadBalancingV2": "elasticloadbalancing",
+	"Wisdom":                 "qconnect",
+}
+
+// typeEventSources maps resource types whose calls CloudTrail records under another event source
+// than those of the rest of their namespace
+var typeEventSources = map[string]string{
+	"AWS::Cognito::IdentityPool":               "cognito-identity",
+	"AWS::Cognito::IdentityPoolPrincipalTag":   "cognito-identity",
+	"AWS::Cognito::IdentityPoolRoleAttachment": "cognito-identity",
+}
+
+// EventSource returns the CloudTrail event source, without ".amazonaws.com", that records the calls
+// CloudFormation makes for a resource type, e.g. "lambda" for AWS::Lambda::Function, "qconnect" for
+// AWS::Wisdom::AIPrompt and "elasticloadbalancing" for AWS::ElasticLoadBalancingV2::LoadBalancer;
+// "" if the type has no namespace
+func EventSource(resourceType string) string {
+	if source, ok := typeEventSources[resourceType]; ok {
+		return source
+	}
+	parts := strings.Split(resourceType, "::")
+	if len(parts) < 2 {
+		return ""
+	}
+	if source, ok := eventSources[parts[1]]; ok {
+		return source
+	}
+	return strings.ToLower(parts[1])
+}
+
+// Service returns the service of the failed resource, such as "Lambda" for AWS::Lambda::Function or
+// "Custom" for custom resources. Third-party types are named by their organization and service.
+// Without a resource type, the event source of the CloudTrail event is used; "" if neither is known.
+func Service(err analyzer.CorrelatedError) string {
+	if parts := strings.Split(err.StackError.ResourceType, "::"); len(parts) >= 2 {
+		switch parts[0] {
+		case "AWS", "Alexa":
+			return parts[1]
+		case "Custom":
+			return "Custom"
+		default:
+			return parts[0] + "::" + parts[1]
+		}
+	}
+
+	if err.CloudTrailEvent != nil && err.CloudTrailEvent.EventSource != "" {
+		return strings.TrimSuffix(err.CloudTrailEvent.EventSource, ".amazonaws.com")
+	}
+
+	return ""
+}
+
+// retryableCategories contains the categories of failures that usually disappear on retry
+var retryableCategories = map[string]bool{
+	CategoryThrottling: true,
+	CategoryInternal:   true,
+	CategoryTimeout:    true,
+}
+
+// Retryable reports whether the error is transient, such as throttling or an internal failure
+// of the service, as opposed to permanent errors like validation failures or denied access
+func Retryable(err analyzer.CorrelatedError) bool {
+	return retryableCategories[Category(err)]
+}
+
+// SafeToRetry reports whether all root causes of the analysis are transient, so retrying the
+// deployment without changes is likely to succeed. Cancelled resources are consequences of other
+// failures and not considered. A root cause also counts as transient if a transient finding, such
+// as an IAM propagation delay, explains it.
+func SafeToRetry(analysis *analyzer.StackAnalysis) bool {
+	transient := make(map[string]bool)
+	for _, finding := range analysis.Findings {
+		if finding.Transient {
+			transient[finding.LogicalResourceId] = true
+		}
+	}
+
+	rootCauses := 0
+	for _, err := range analysis.Errors {
+		if Category(err) == CategoryCancelled {
+			continue
+		}
+		rootCauses++
+		if !Retryable(err) && !transient[err.StackError.LogicalResourceId] {
+			return false
+		}
+	}
+
+	return rootCauses > 0
+}
@@ -0,0 +1,38 @@
+package classify_test
+
+import (
+	"testing"
+
+	"cfn-root-cause/classify"
+)
+
+// eventSourceCases are resource types with the CloudTrail event source of their service
+var eventSourceCases = []struct {
+	resourceType string
+	want         string
+}{
+	{"AWS::ElasticLoadBalancingV2::LoadBalancer", "elasticloadbalancing"},
+	{"AWS::ElasticLoadBalancingV2::TargetGroup", "elasticloadbalancing"},
+	{"AWS::ApiGatewayV2::Api", "apigateway"},
+	{"AWS::ApiGatewayV2::Stage", "apigateway"},
+	{"AWS::Cognito::UserPool", "cognito-idp"},
+	{"AWS::Cognito::UserPoolClient", "cognito-idp"},
+	{"AWS::Cognito::IdentityPool", "cognito-identity"},
+	{"AWS::Cognito::IdentityPoolRoleAttachment", "cognito-identity"},
+	{"AWS::Wisdom::AIPrompt", "qconnect"},
+	{"AWS::Lambda::Function", "lambda"},
+	{"MongoDB::Atlas::Cluster", "atlas"},
+	{"Custom::Provisioner", "provisioner"},
+	{"", ""},
+	{"NotAResourceType", ""},
+}
+
+func TestEventSource(t *testing.T) {
+	for _, tc := range eventSourceCases {
+		t.Run(tc.resourceType, func(t *testing.T) {
+			if got := classify.EventSource(tc.resourceType); got != tc.want {
+				t.Errorf("EventSource(%q) = %q, want %q", tc.resourceType, got, tc.want)
+			}
+		})
+	}
+}
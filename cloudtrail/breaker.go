@@ -0,0 +1,130 @@
+package cloudtrail
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"cfn-root-cause/awserrors"
+
+	"github.com/aws/smithy-go"
+)
+
+// DefaultBreakerThreshold is the number of consecutive lookups failing with the same error
+// after which no further lookups are made
+const DefaultBreakerThreshold = 3
+
+// ErrBreakerOpen indicates that lookups were stopped after repeated failures
+var ErrBreakerOpen = errors.New("CloudTrail lookups stopped")
+
+// Breaker stops CloudTrail lookups once they keep failing with the same error, so an analysis
+// without CloudTrail access falls back to the stack events quickly. Permission errors open the
+// breaker at once, since retrying them cannot succeed. A nil Breaker never opens.
+type Breaker struct {
+	mu        sync.Mutex
+	threshold int
+	failures  int
+	lastCode  string
+	cause     error
+	open      bool
+
+	// lookupFailures counts all failed lookups, consecutive or not
+	lookupFailures int
+}
+
+// NewBreaker creates a breaker that opens after threshold consecutive failures with the same error
+func NewBreaker(threshold int) *Breaker {
+	return &Breaker{threshold: threshold}
+}
+
+// Allow returns an error wrapping ErrBreakerOpen if lookups were stopped
+func (b *Breaker) Allow() error {
+	if b == nil {
+		return nil
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.open {
+		return b.err()
+	}
+	return nil
+}
+
+// Record records the outcome of a lookup. It returns an error wrapping ErrBreakerOpen instead
+// of err if this failure opened the breaker.
+func (b *Breaker) Record(err error) error {
+	if b == nil {
+		return err
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if err == nil {
+		b.failures = 0
+		b.lastCode = ""
+		return nil
+	}
+	if errors.Is(err, context.Canceled) {
+		// An interrupted run is not a CloudTrail failure
+		return err
+	}
+
+	b.lookupFailures++
+	code := failureCode(err)
+	if code == b.lastCode {
+		b.failures++
+	} else {
+		b.lastCode = code
+		b.failures = 1
+	}
+
+	if b.failures >= b.threshold || awserrors.IsPermissionError(err) {
+		b.open = true
+		b.cause = err
+		return b.err()
+	}
+	return err
+}
+
+// Err returns the error that stopped the lookups, or nil if the breaker is closed
+func (b *Breaker) Err() error {
+	if b == nil {
+		return nil
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !b.open {
+		return nil
+	}
+	return b.err()
+}
+
+// LookupFailures returns the number of failed lookups recorded, including the one that opened the breaker
+func (b *Breaker) LookupFailures() int {
+	if b == nil {
+		return 0
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.lookupFailures
+}
+
+// err describes why the breaker opened; the caller must hold the lock
+func (b *Breaker) err() error {
+	return fmt.Errorf("%w after %d consecutive failure(s): %w",
+		ErrBreakerOpen, b.failures, awserrors.ParseAWSError(b.cause, "CloudTrail"))
+}
+
+// failureCode identifies the kind of a failure: the AWS error code, or the message of other errors
+func failureCode(err error) string {
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.ErrorCode()
+	}
+	return err.Error()
+}
@@ -5,14 +5,19 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"sort"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"cfn-root-cause/analyzer"
 	"cfn-root-cause/awserrors"
+	"cfn-root-cause/classify"
+	"cfn-root-cause/correlator"
+	"cfn-root-cause/metrics"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
-	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/service/cloudtrail"
 	"github.com/aws/aws-sdk-go-v2/service/cloudtrail/types"
 )
@@ -26,36 +31,82 @@ type TimeRange struct {
 // Client wraps the AWS CloudTrail client with additional functionality
 type Client struct {
 	ct *cloudtrail.Client
+
+	// home queries the region in which global services record their events; nil if that is the
+	// region of ct
+	home *cloudtrail.Client
+
+	// breaker stops lookups after repeated failures; nil means lookups are never stopped
+	breaker *Breaker
+
+	// limiter spaces lookups of parallel analyses; nil means lookups are not spaced
+	limiter *Limiter
+
+	// calls and eventsParsed count API calls and parsed events for performance statistics
+	calls        atomic.Int64
+	eventsParsed atomic.Int64
+
+	// windows are the time ranges of the lookups, for the coverage of the analysis
+	windowsMu sync.Mutex
+	windows   []analyzer.TimeWindow
+
+	// deliveryWait is how long the client may wait in total for CloudTrail to deliver the events
+	// of recent failures, and waited how long it did; 0 means lookups do not wait
+	deliveryWait time.Duration
+	waited       atomic.Int64
+
+	// stackRole is the service role of the analyzed stack; "" means the calls of any role CloudFormation
+	// used are searched
+	stackRole string
 }
 
 // CloudTrailAPI defines the interface for CloudTrail operations
 type CloudTrailAPI interface {
 	LookupEvents(ctx context.Context, params *cloudtrail.LookupEventsInput, optFns ...func(*cloudtrail.Options)) (*cloudtrail.LookupEventsOutput, error)
+	DescribeTrails(ctx context.Context, params *cloudtrail.DescribeTrailsInput, optFns ...func(*cloudtrail.Options)) (*cloudtrail.DescribeTrailsOutput, error)
 }
 
-// NewClient creates a new CloudTrail client using default AWS configuration
-// It uses standard AWS credential resolution (environment variables, profiles, IAM roles)
-// Requirements: 6.2, 6.4
-func NewClient(ctx context.Context) (*Client, error) {
-	cfg, err := config.LoadDefaultConfig(ctx)
-	if err != nil {
-		// Parse and return user-friendly error message for credential/config issues
-		awsErr := awserrors.ParseAWSError(err, "CloudTrail")
-		return nil, awsErr
+// NewClientWithConfig creates a new CloudTrail client with a custom AWS config
+func NewClientWithConfig(cfg aws.Config) *Client {
+	client := &Client{
+		ct: cloudtrail.NewFromConfig(cfg),
+	}
+	if region := homeRegion(cfg.Region); region != cfg.Region {
+		homeCfg := cfg.Copy()
+		homeCfg.Region = region
+		client.home = cloudtrail.NewFromConfig(homeCfg)
 	}
+	return client
+}
 
-	return &Client{
-		ct: cloudtrail.NewFromConfig(cfg),
-	}, nil
+// globalServices are the services that record their CloudTrail events in the home region of the
+// partition, whatever the region of the stack
+var globalServices = map[string]bool{
+	"cloudfront": true,
+	"iam":        true,
+	"route53":    true,
 }
 
-// NewClientWithConfig creates a new CloudTrail client with a custom AWS config
-func NewClientWithConfig(cfg aws.Config) *Client {
-	return &Client{
-		ct: cloudtrail.NewFromConfig(cfg),
+// homeRegion returns the region in which global services of the partition of a region record
+// their CloudTrail events
+func homeRegion(region string) string {
+	switch {
+	case strings.HasPrefix(region, "cn-"):
+		return "cn-north-1"
+	case strings.HasPrefix(region, "us-gov-"):
+		return "us-gov-west-1"
+	default:
+		return "us-east-1"
 	}
 }
 
+// trailOf returns the client that queries the events of a service
+func (c *Client) trailOf(serviceName string) *cloudtrail.Client {
+	if c.home != nil && globalServices[serviceName] {
+		return c.home
+	}
+	return c.ct
+}
 
 // SearchCloudTrailEvents queries CloudTrail logs for events in the specified time range.
 // It searches for events related to CloudFormation operations and returns matching events.
@@ -75,7 +126,7 @@ func (c *Client) SearchCloudTrailEvents(ctx context.Context, timeRange TimeRange
 				MaxResults: aws.Int32(50),
 			}
 
-			output, err := c.ct.LookupEvents(ctx, input)
+			output, err := c.lookupEvents(ctx, input)
 			if err != nil {
 				awsErr := awserrors.ParseAWSError(err, "CloudTrail")
 				return nil, fmt.Errorf("failed to lookup CloudTrail events: %w", awsErr)
@@ -111,16 +162,16 @@ func (c *Client) SearchCloudTrailEvents(ctx context.Context, timeRange TimeRange
 	// If we have filters, we need to make separate calls for each
 	for {
 		input := &cloudtrail.LookupEventsInput{
-			StartTime: aws.Time(timeRange.StartTime),
-			EndTime:   aws.Time(timeRange.EndTime),
-			NextToken: nextToken,
+			StartTime:  aws.Time(timeRange.StartTime),
+			EndTime:    aws.Time(timeRange.EndTime),
+			NextToken:  nextToken,
 			MaxResults: aws.Int32(50),
 		}
 
 		// Use the first filter for this query
 		input.LookupAttributes = []types.LookupAttribute{lookupAttributes[0]}
 
-		output, err := c.ct.LookupEvents(ctx, input)
+		output, err := c.lookupEvents(ctx, input)
 		if err != nil {
 			// Parse and return user-friendly error message
 			awsErr := awserrors.ParseAWSError(err, "CloudTrail")
@@ -146,6 +197,91 @@ func (c *Client) SearchCloudTrailEvents(ctx context.Context, timeRange TimeRange
 	return allEvents, nil
 }
 
+// UseBreaker makes the client stop lookups once the breaker opens. A breaker can be shared
+// by several clients, so repeated failures are counted across an entire run.
+func (c *Client) UseBreaker(breaker *Breaker) {
+	c.breaker = breaker
+}
+
+// UseLimiter makes the client wait for the limiter before each lookup. A limiter can be shared
+// by several clients, so parallel analyses respect the rate limit of the account together.
+func (c *Client) UseLimiter(limiter *Limiter) {
+	c.limiter = limiter
+}
+
+// UseDeliveryWait sets how long the client may wait in total for CloudTrail to deliver the events
+// of failures more recent than DeliveryLag; see RequeryDelay. 0 means it does not wait.
+func (c *Client) UseDeliveryWait(wait time.Duration) {
+	c.deliveryWait = wait
+}
+
+// UseStackRole makes SearchForStackErrors skip the calls CloudFormation made with a service role
+// other than the one of the analyzed stack, such as those of other stacks deployed at the same time.
+// "" keeps the calls of all roles.
+func (c *Client) UseStackRole(roleArn string) {
+	c.stackRole = roleArn
+}
+
+// lookupEvents performs a single LookupEvents call in the region of the client and records it in the metrics
+func (c *Client) lookupEvents(ctx context.Context, input *cloudtrail.LookupEventsInput) (*cloudtrail.LookupEventsOutput, error) {
+	return c.lookupEventsIn(ctx, c.ct, input)
+}
+
+// lookupEventsIn performs a single LookupEvents call with a regional client and records it in the metrics
+func (c *Client) lookupEventsIn(ctx context.Context, ct *cloudtrail.Client, input *cloudtrail.LookupEventsInput) (*cloudtrail.LookupEventsOutput, error) {
+	if err := c.breaker.Allow(); err != nil {
+		return nil, err
+	}
+	if err := c.limiter.Wait(ctx); err != nil {
+		return nil, err
+	}
+
+	metrics.CloudTrailQueriesTotal.Inc()
+	c.calls.Add(1)
+	if input.NextToken == nil && input.StartTime != nil && input.EndTime != nil {
+		c.windowsMu.Lock()
+		c.windows = append(c.windows, analyzer.TimeWindow{Start: *input.StartTime, End: *input.EndTime})
+		c.windowsMu.Unlock()
+	}
+
+	output, err := ct.LookupEvents(ctx, input)
+	if err != nil && awserrors.IsThrottlingError(err) {
+		metrics.ThrottlesTotal.Inc("CloudTrail")
+	}
+
+	if output != nil {
+		c.eventsParsed.Add(int64(len(output.Events)))
+	}
+
+	return output, c.breaker.Record(err)
+}
+
+// Stats returns the number of LookupEvents calls made and CloudTrail events received by this client
+func (c *Client) Stats() (calls, eventsParsed int) {
+	return int(c.calls.Load()), int(c.eventsParsed.Load())
+}
+
+// Windows returns the time ranges this client looked up events in, the earliest first;
+// overlapping ranges are merged
+func (c *Client) Windows() []analyzer.TimeWindow {
+	c.windowsMu.Lock()
+	windows := append([]analyzer.TimeWindow(nil), c.windows...)
+	c.windowsMu.Unlock()
+
+	sort.Slice(windows, func(i, j int) bool { return windows[i].Start.Before(windows[j].Start) })
+	var merged []analyzer.TimeWindow
+	for _, window := range windows {
+		if last := len(merged) - 1; last >= 0 && !window.Start.After(merged[last].End) {
+			if window.End.After(merged[last].End) {
+				merged[last].End = window.End
+			}
+			continue
+		}
+		merged = append(merged, window)
+	}
+	return merged
+}
+
 // SearchByEventName queries CloudTrail logs for events with a specific event name
 func (c *Client) SearchByEventName(ctx context.Context, timeRange TimeRange, eventName string) ([]analyzer.CloudTrailEvent, error) {
 	var allEvents []analyzer.CloudTrailEvent
@@ -153,9 +289,9 @@ func (c *Client) SearchByEventName(ctx context.Context, timeRange TimeRange, eve
 
 	for {
 		input := &cloudtrail.LookupEventsInput{
-			StartTime: aws.Time(timeRange.StartTime),
-			EndTime:   aws.Time(timeRange.EndTime),
-			NextToken: nextToken,
+			StartTime:  aws.Time(timeRange.StartTime),
+			EndTime:    aws.Time(timeRange.EndTime),
+			NextToken:  nextToken,
 			MaxResults: aws.Int32(50),
 			LookupAttributes: []types.LookupAttribute{
 				{
@@ -165,7 +301,7 @@ func (c *Client) SearchByEventName(ctx context.Context, timeRange TimeRange, eve
 			},
 		}
 
-		output, err := c.ct.LookupEvents(ctx, input)
+		output, err := c.lookupEvents(ctx, input)
 		if err != nil {
 			// Parse and return user-friendly error message
 			awsErr := awserrors.ParseAWSError(err, "CloudTrail")
@@ -191,14 +327,24 @@ func (c *Client) SearchByEventName(ctx context.Context, timeRange TimeRange, eve
 
 // SearchByUsername queries CloudTrail logs for events by a specific username
 func (c *Client) SearchByUsername(ctx context.Context, timeRange TimeRange, username string) ([]analyzer.CloudTrailEvent, error) {
-	var allEvents []analyzer.CloudTrailEvent
+	events, err := c.lookupByUsername(ctx, c.ct, timeRange, username)
+	if err != nil {
+		return nil, err
+	}
+	return parseCloudTrailEvents(events), nil
+}
+
+// lookupByUsername returns the unparsed CloudTrail events of a username in the region of ct, so
+// callers can discard events by their header fields before the event JSON is parsed
+func (c *Client) lookupByUsername(ctx context.Context, ct *cloudtrail.Client, timeRange TimeRange, username string) ([]types.Event, error) {
+	var allEvents []types.Event
 	var nextToken *string
 
 	for {
 		input := &cloudtrail.LookupEventsInput{
-			StartTime: aws.Time(timeRange.StartTime),
-			EndTime:   aws.Time(timeRange.EndTime),
-			NextToken: nextToken,
+			StartTime:  aws.Time(timeRange.StartTime),
+			EndTime:    aws.Time(timeRange.EndTime),
+			NextToken:  nextToken,
 			MaxResults: aws.Int32(50),
 			LookupAttributes: []types.LookupAttribute{
 				{
@@ -208,20 +354,14 @@ func (c *Client) SearchByUsername(ctx context.Context, timeRange TimeRange, user
 			},
 		}
 
-		output, err := c.ct.LookupEvents(ctx, input)
+		output, err := c.lookupEventsIn(ctx, ct, input)
 		if err != nil {
 			// Parse and return user-friendly error message
 			awsErr := awserrors.ParseAWSError(err, "CloudTrail")
 			return nil, fmt.Errorf("failed to lookup CloudTrail events by username: %w", awsErr)
 		}
 
-		for _, event := range output.Events {
-			ctEvent, err := parseCloudTrailEvent(event)
-			if err != nil {
-				continue
-			}
-			allEvents = append(allEvents, ctEvent)
-		}
+		allEvents = append(allEvents, output.Events...)
 
 		if output.NextToken == nil {
 			break
@@ -235,67 +375,120 @@ func (c *Client) SearchByUsername(ctx context.Context, timeRange TimeRange, user
 // SearchForStackErrors queries CloudTrail for events related to CloudFormation stack errors.
 // It searches around the error timestamp with a buffer to find related API calls.
 // For better correlation, it searches by service type and CloudFormation user rather than logical resource ID,
-// since CloudTrail records physical AWS API calls, not CloudFormation logical IDs.
+// since CloudTrail records physical AWS API calls, not CloudFormation logical IDs. With UseStackRole,
+// calls made with the service roles of other stacks are skipped.
+// Errors of global services such as IAM, CloudFront and Route 53 are searched in the home region
+// of the partition (us-east-1), where these services record their events.
 func (c *Client) SearchForStackErrors(ctx context.Context, stackError analyzer.StackError) ([]analyzer.CloudTrailEvent, error) {
 	// Create a time range around the error timestamp
-	// Search 10 minutes before and after the error for better coverage
+	// Search as far before and after the error as the correlation widens its window
 	timeRange := TimeRange{
-		StartTime: stackError.Timestamp.Add(-10 * time.Minute),
-		EndTime:   stackError.Timestamp.Add(10 * time.Minute),
+		StartTime: stackError.Timestamp.Add(-correlator.DefaultMaxTimeWindow),
+		EndTime:   stackError.Timestamp.Add(correlator.DefaultMaxTimeWindow),
 	}
 
 	// Extract service name from resource type (e.g., "AWS::Wisdom::AIPrompt" -> "qconnect")
-	serviceName := extractServiceName(stackError.ResourceType)
-	
+	serviceName := classify.EventSource(stackError.ResourceType)
+
 	// Search for events by username (CloudFormation) to narrow down results
 	// CloudFormation makes API calls on behalf of the stack
-	events, err := c.SearchByUsername(ctx, timeRange, "AWSCloudFormation")
+	events, err := c.lookupByUsername(ctx, c.trailOf(serviceName), timeRange, "AWSCloudFormation")
 	if err != nil {
 		return nil, err
 	}
-	
-	// Filter events to match the service type
-	var allEvents []analyzer.CloudTrailEvent
+
+	// Filter events to match the service type before parsing them, since most calls in the
+	// time range belong to other resources
 	if serviceName != "" {
+		var matching []types.Event
 		for _, event := range events {
-			if matchesService(event, serviceName) {
-				allEvents = append(allEvents, event)
+			if matchesEventSource(safeString(event.EventSource), serviceName) {
+				matching = append(matching, event)
 			}
 		}
-	} else {
-		// If we can't extract service name, return all CloudFormation events in time range
-		allEvents = events
+		events = matching
 	}
+	// If we can't extract service name, return all CloudFormation events in time range
+
+	parsed := parseCloudTrailEvents(events)
+	if c.stackRole != "" {
+		var ofStack []analyzer.CloudTrailEvent
+		for _, event := range parsed {
+			if issuer := sessionIssuer(event); issuer == "" || issuer == c.stackRole {
+				ofStack = append(ofStack, event)
+			}
+		}
+		parsed = ofStack
+	}
+	return parsed, nil
+}
 
-	return allEvents, nil
+// sessionIssuer returns the ARN of the role whose session made the call, or "" if the call was not
+// made with a role session
+func sessionIssuer(event analyzer.CloudTrailEvent) string {
+	sessionContext, _ := event.UserIdentity["sessionContext"].(map[string]interface{})
+	issuer, _ := sessionContext["sessionIssuer"].(map[string]interface{})
+	arn, _ := issuer["arn"].(string)
+	return arn
 }
 
-// extractServiceName extracts the service name from a CloudFormation resource type
-// e.g., "AWS::Wisdom::AIPrompt" -> "qconnect" (Wisdom service is called qconnect in CloudTrail)
-// e.g., "AWS::Lambda::Function" -> "lambda"
-func extractServiceName(resourceType string) string {
-	parts := strings.Split(resourceType, "::")
-	if len(parts) >= 2 {
-		serviceName := strings.ToLower(parts[1])
-		
-		// Handle special cases where CloudFormation name differs from CloudTrail event source
-		switch serviceName {
-		case "wisdom":
-			return "qconnect" // AWS Wisdom is called qconnect in CloudTrail
-		default:
-			return serviceName
-		}
+// RequeryDelay returns how long to wait before looking up the events of failures again that no
+// event matched, so CloudTrail can deliver them: until DeliveryLag passed since the newest failure,
+// within the remaining delivery wait of the client. The delay is deducted from the remaining wait;
+// 0 means the failures are not looked up again.
+func (c *Client) RequeryDelay(newestFailure time.Time) time.Duration {
+	delay := min(time.Until(newestFailure.Add(DeliveryLag)), c.deliveryWait-time.Duration(c.waited.Load()))
+	if delay <= 0 {
+		return 0
 	}
-	return ""
+	c.waited.Add(int64(delay))
+	return delay
+}
+
+// MatchesResourceType checks if a CloudTrail event is from the AWS service of a CloudFormation resource type
+func MatchesResourceType(event analyzer.CloudTrailEvent, resourceType string) bool {
+	serviceName := classify.EventSource(resourceType)
+	return serviceName != "" && matchesService(event, serviceName)
 }
 
 // matchesService checks if a CloudTrail event is from the specified AWS service
 func matchesService(event analyzer.CloudTrailEvent, serviceName string) bool {
+	return matchesEventSource(event.EventSource, serviceName)
+}
+
+// matchesEventSource checks if an event source belongs to the specified AWS service
+func matchesEventSource(eventSource, serviceName string) bool {
 	// CloudTrail event sources are like "wisdom.amazonaws.com"
-	eventSource := strings.ToLower(event.EventSource)
-	return strings.Contains(eventSource, strings.ToLower(serviceName))
+	return strings.Contains(strings.ToLower(eventSource), strings.ToLower(serviceName))
+}
+
+// eventRecord is the part of the CloudTrailEvent JSON the analyzer uses: the error code and
+// message, the event and request IDs, and userIdentity, responseElements and requestParameters,
+// which are only decoded if they are objects. Other fields are skipped without being decoded, such
+// as resources, which LookupEvents returns with the event.
+type eventRecord struct {
+	ErrorCode         string          `json:"errorCode"`
+	ErrorMessage      string          `json:"errorMessage"`
+	EventID           string          `json:"eventID"`
+	RequestID         string          `json:"requestID"`
+	UserIdentity      json.RawMessage `json:"userIdentity"`
+	ResponseElements  json.RawMessage `json:"responseElements"`
+	RequestParameters json.RawMessage `json:"requestParameters"`
 }
 
+// parseCloudTrailEvents converts AWS CloudTrail events to our internal format, skipping events
+// whose JSON cannot be parsed
+func parseCloudTrailEvents(events []types.Event) []analyzer.CloudTrailEvent {
+	var parsed []analyzer.CloudTrailEvent
+	for _, event := range events {
+		ctEvent, err := parseCloudTrailEvent(event)
+		if err != nil {
+			continue
+		}
+		parsed = append(parsed, ctEvent)
+	}
+	return parsed
+}
 
 // parseCloudTrailEvent converts an AWS CloudTrail event to our internal format
 func parseCloudTrailEvent(event types.Event) (analyzer.CloudTrailEvent, error) {
@@ -304,36 +497,49 @@ func parseCloudTrailEvent(event types.Event) (analyzer.CloudTrailEvent, error) {
 		EventName:   safeString(event.EventName),
 		EventSource: safeString(event.EventSource),
 	}
+	for _, resource := range event.Resources {
+		if name := safeString(resource.ResourceName); name != "" {
+			ctEvent.Resources = append(ctEvent.Resources, analyzer.TrailResource{
+				Type: safeString(resource.ResourceType),
+				Name: name,
+			})
+		}
+	}
 
 	// Parse the CloudTrailEvent JSON to extract detailed information
 	if event.CloudTrailEvent != nil {
-		var eventData map[string]interface{}
-		if err := json.Unmarshal([]byte(*event.CloudTrailEvent), &eventData); err != nil {
+		var record eventRecord
+		if err := json.Unmarshal([]byte(*event.CloudTrailEvent), &record); err != nil {
 			return ctEvent, fmt.Errorf("failed to parse CloudTrail event JSON: %w", err)
 		}
 
-		// Extract userIdentity
-		if userIdentity, ok := eventData["userIdentity"].(map[string]interface{}); ok {
-			ctEvent.UserIdentity = userIdentity
-		}
-
-		// Extract responseElements
-		if responseElements, ok := eventData["responseElements"].(map[string]interface{}); ok {
-			ctEvent.ResponseElements = responseElements
-		}
+		// Extract error information and the identifiers AWS Support asks for
+		ctEvent.ErrorCode = record.ErrorCode
+		ctEvent.ErrorMessage = record.ErrorMessage
+		ctEvent.EventID = record.EventID
+		ctEvent.RequestID = record.RequestID
 
-		// Extract error information
-		if errorCode, ok := eventData["errorCode"].(string); ok {
-			ctEvent.ErrorCode = errorCode
-		}
-		if errorMessage, ok := eventData["errorMessage"].(string); ok {
-			ctEvent.ErrorMessage = errorMessage
-		}
+		// Extract userIdentity, responseElements and requestParameters
+		ctEvent.UserIdentity = decodeObject(record.UserIdentity)
+		ctEvent.ResponseElements = decodeObject(record.ResponseElements)
+		ctEvent.RequestParameters = decodeObject(record.RequestParameters)
 	}
 
 	return ctEvent, nil
 }
 
+// decodeObject decodes a raw JSON object; null, other values and invalid JSON yield nil
+func decodeObject(raw json.RawMessage) map[string]interface{} {
+	if len(raw) == 0 || raw[0] != '{' {
+		return nil
+	}
+	var object map[string]interface{}
+	if err := json.Unmarshal(raw, &object); err != nil {
+		return nil
+	}
+	return object
+}
+
 // safeString safely dereferences a string pointer, returning empty string if nil
 func safeString(s *string) string {
 	if s == nil {
@@ -356,7 +562,6 @@ func (c *Client) GetUnderlyingClient() *cloudtrail.Client {
 	return c.ct
 }
 
-
 // ExtractResponseElements parses responseElements from a CloudTrail event.
 // It returns the responseElements map if present, or an empty map if not available.
 func ExtractResponseElements(event analyzer.CloudTrailEvent) (map[string]interface{}, error) {
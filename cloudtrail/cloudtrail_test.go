@@ -0,0 +1,31 @@
+package cloudtrail
+
+import (
+	"testing"
+
+	"cfn-root-cause/analyzer"
+)
+
+func TestMatchesResourceType(t *testing.T) {
+	tests := []struct {
+		resourceType string
+		eventSource  string
+		want         bool
+	}{
+		{"AWS::ElasticLoadBalancingV2::LoadBalancer", "elasticloadbalancing.amazonaws.com", true},
+		{"AWS::ElasticLoadBalancingV2::LoadBalancer", "ec2.amazonaws.com", false},
+		{"AWS::ApiGatewayV2::Api", "apigateway.amazonaws.com", true},
+		{"AWS::Cognito::UserPool", "cognito-idp.amazonaws.com", true},
+		{"AWS::Cognito::IdentityPool", "cognito-identity.amazonaws.com", true},
+		{"AWS::Cognito::IdentityPool", "cognito-idp.amazonaws.com", false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.resourceType+"/"+tc.eventSource, func(t *testing.T) {
+			event := analyzer.CloudTrailEvent{EventSource: tc.eventSource}
+			if got := MatchesResourceType(event, tc.resourceType); got != tc.want {
+				t.Errorf("MatchesResourceType(%s, %s) = %v, want %v", tc.eventSource, tc.resourceType, got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,54 @@
+package cloudtrail
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// DefaultLookupRate is the number of LookupEvents calls per second CloudTrail allows per account and region
+const DefaultLookupRate = 2
+
+// Limiter spaces LookupEvents calls, so analyses running in parallel stay within the CloudTrail
+// rate limit of the account instead of being throttled. A limiter can be shared by several
+// clients. A nil Limiter never waits.
+type Limiter struct {
+	mu       sync.Mutex
+	interval time.Duration
+	next     time.Time
+}
+
+// NewLimiter creates a limiter allowing rate calls per second
+func NewLimiter(rate int) *Limiter {
+	return &Limiter{interval: time.Second / time.Duration(rate)}
+}
+
+// Wait blocks until the next call is allowed. It returns the error of the context if the context
+// ends first.
+func (l *Limiter) Wait(ctx context.Context) error {
+	if l == nil {
+		return nil
+	}
+
+	l.mu.Lock()
+	slot := l.next
+	if now := time.Now(); slot.Before(now) {
+		slot = now
+	}
+	l.next = slot.Add(l.interval)
+	l.mu.Unlock()
+
+	delay := time.Until(slot)
+	if delay <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
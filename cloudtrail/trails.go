@@ -0,0 +1,110 @@
+package cloudtrail
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"cfn-root-cause/awserrors"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudtrail"
+)
+
+// DeliveryLag is how long CloudTrail may take to deliver an event after the API call; lookups
+// of more recent failures may miss their events
+const DeliveryLag = 15 * time.Minute
+
+// DefaultDeliveryWait is how long an analysis waits by default for the events of recent failures;
+// CloudTrail delivers most events within about five minutes
+const DefaultDeliveryWait = 5 * time.Minute
+
+// Trail is a trail that applies to the region of the client, including multi-region and
+// organization trails created in other regions or accounts
+type Trail struct {
+	Name         string
+	HomeRegion   string
+	MultiRegion  bool
+	Organization bool
+
+	// Logging reports whether the trail currently delivers events, and ManagementEvents whether
+	// its event selectors include management events
+	Logging          bool
+	ManagementEvents bool
+
+	// Unverified is set if the status or the event selectors of the trail could not be read, as
+	// for organization trails seen from member accounts; the trail is then assumed to record them
+	Unverified bool
+}
+
+// Trails lists the trails that apply to the region of the client with their logging status and
+// whether they record management events. The event history searched by the analysis records the
+// management events of the last 90 days independently of trails.
+func (c *Client) Trails(ctx context.Context) ([]Trail, error) {
+	output, err := c.ct.DescribeTrails(ctx, &cloudtrail.DescribeTrailsInput{IncludeShadowTrails: aws.Bool(true)})
+	if err != nil {
+		return nil, fmt.Errorf("failed to describe trails: %w", awserrors.ParseAWSError(err, "CloudTrail"))
+	}
+
+	trails := make([]Trail, 0, len(output.TrailList))
+	for _, trail := range output.TrailList {
+		info := Trail{
+			Name:         aws.ToString(trail.Name),
+			HomeRegion:   aws.ToString(trail.HomeRegion),
+			MultiRegion:  aws.ToBool(trail.IsMultiRegionTrail),
+			Organization: aws.ToBool(trail.IsOrganizationTrail),
+		}
+
+		status, err := c.ct.GetTrailStatus(ctx, &cloudtrail.GetTrailStatusInput{Name: trail.TrailARN})
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			info.Unverified = true
+			info.Logging = true
+		} else {
+			info.Logging = aws.ToBool(status.IsLogging)
+		}
+
+		selectors, err := c.ct.GetEventSelectors(ctx, &cloudtrail.GetEventSelectorsInput{TrailName: trail.TrailARN})
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			info.Unverified = true
+			info.ManagementEvents = true
+		} else {
+			info.ManagementEvents = includesManagementEvents(selectors)
+		}
+
+		trails = append(trails, info)
+	}
+	return trails, nil
+}
+
+// RecordsManagementEvents reports whether one of the trails delivers the management events of the region
+func RecordsManagementEvents(trails []Trail) bool {
+	return slices.ContainsFunc(trails, func(trail Trail) bool {
+		return trail.Logging && trail.ManagementEvents
+	})
+}
+
+// includesManagementEvents reports whether the basic or advanced event selectors of a trail
+// include management events
+func includesManagementEvents(selectors *cloudtrail.GetEventSelectorsOutput) bool {
+	for _, selector := range selectors.AdvancedEventSelectors {
+		for _, field := range selector.FieldSelectors {
+			if aws.ToString(field.Field) == "eventCategory" && slices.Contains(field.Equals, "Management") {
+				return true
+			}
+		}
+	}
+	for _, selector := range selectors.EventSelectors {
+		// Management events are included unless disabled
+		if selector.IncludeManagementEvents == nil || *selector.IncludeManagementEvents {
+			return true
+		}
+	}
+	return false
+}
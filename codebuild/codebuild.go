@@ -0,0 +1,232 @@
+// Package codebuild provides AWS CodeBuild and CodePipeline integration:
+// build environment detection and lookup of the stack deployed by a pipeline
+package codebuild
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"cfn-root-cause/awserrors"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/codepipeline"
+	"github.com/aws/aws-sdk-go-v2/service/codepipeline/types"
+)
+
+var (
+	// ErrNotInCodeBuild indicates the CodeBuild environment variables are missing
+	ErrNotInCodeBuild = errors.New("not running in AWS CodeBuild: CODEBUILD_BUILD_ID is not set")
+
+	// ErrNoPipeline indicates the build was not started by CodePipeline
+	ErrNoPipeline = errors.New("build was not started by CodePipeline")
+
+	// ErrNoStackAction indicates the pipeline execution has no CloudFormation deploy action
+	ErrNoStackAction = errors.New("no CloudFormation action found in the pipeline execution")
+)
+
+// pipelineInitiatorPrefix prefixes CODEBUILD_INITIATOR when CodePipeline started the build
+const pipelineInitiatorPrefix = "codepipeline/"
+
+// cloudFormationProvider is the action provider name of CloudFormation deploy actions
+const cloudFormationProvider = "CloudFormation"
+
+// DefaultArtifactsDirName is the directory, relative to the source directory, reports are written to
+const DefaultArtifactsDirName = "cfnrc-report"
+
+// Environment describes the CodeBuild build the analyzer runs in
+type Environment struct {
+	// BuildID is the CodeBuild build ID (CODEBUILD_BUILD_ID)
+	BuildID string
+
+	// Initiator is the entity that started the build (CODEBUILD_INITIATOR)
+	Initiator string
+
+	// SourceDir is the primary source directory (CODEBUILD_SRC_DIR)
+	SourceDir string
+
+	// Region is the region the build runs in (AWS_REGION)
+	Region string
+
+	// Succeeding reports whether the build is currently succeeding (CODEBUILD_BUILD_SUCCEEDING)
+	Succeeding bool
+
+	// PipelineName is the name of the pipeline that started the build, if any
+	PipelineName string
+
+	// PipelineExecutionID is the pipeline execution, if exported to the build
+	// as CODEPIPELINE_EXECUTION_ID (e.g. from #{codepipeline.PipelineExecutionId})
+	PipelineExecutionID string
+}
+
+// DeployedStack describes a CloudFormation stack deployed by a pipeline action
+type DeployedStack struct {
+	StackName  string
+	Region     string
+	ActionName string
+	StageName  string
+	Status     string
+}
+
+// Failed reports whether the pipeline action deploying the stack failed
+func (s *DeployedStack) Failed() bool {
+	return s.Status == string(types.ActionExecutionStatusFailed)
+}
+
+// DetectEnvironment reads the CodeBuild environment variables.
+// Returns ErrNotInCodeBuild if the process does not run in CodeBuild.
+func DetectEnvironment() (*Environment, error) {
+	env := &Environment{
+		BuildID:             os.Getenv("CODEBUILD_BUILD_ID"),
+		Initiator:           os.Getenv("CODEBUILD_INITIATOR"),
+		SourceDir:           os.Getenv("CODEBUILD_SRC_DIR"),
+		Region:              os.Getenv("AWS_REGION"),
+		Succeeding:          os.Getenv("CODEBUILD_BUILD_SUCCEEDING") != "0",
+		PipelineExecutionID: os.Getenv("CODEPIPELINE_EXECUTION_ID"),
+	}
+
+	if env.BuildID == "" {
+		return nil, ErrNotInCodeBuild
+	}
+
+	if strings.HasPrefix(env.Initiator, pipelineInitiatorPrefix) {
+		env.PipelineName = strings.TrimPrefix(env.Initiator, pipelineInitiatorPrefix)
+	}
+
+	return env, nil
+}
+
+// ArtifactsDir returns the directory reports should be written to so they
+// can be collected as build artifacts
+func (e *Environment) ArtifactsDir() string {
+	if e.SourceDir == "" {
+		return DefaultArtifactsDirName
+	}
+	return filepath.Join(e.SourceDir, DefaultArtifactsDirName)
+}
+
+// Client wraps the AWS CodePipeline client with additional functionality
+type Client struct {
+	cp CodePipelineAPI
+}
+
+// CodePipelineAPI defines the interface for CodePipeline operations
+type CodePipelineAPI interface {
+	ListPipelineExecutions(ctx context.Context, params *codepipeline.ListPipelineExecutionsInput, optFns ...func(*codepipeline.Options)) (*codepipeline.ListPipelineExecutionsOutput, error)
+	ListActionExecutions(ctx context.Context, params *codepipeline.ListActionExecutionsInput, optFns ...func(*codepipeline.Options)) (*codepipeline.ListActionExecutionsOutput, error)
+}
+
+// NewClientWithConfig creates a new CodePipeline client with a custom AWS config
+func NewClientWithConfig(cfg aws.Config) *Client {
+	return &Client{
+		cp: codepipeline.NewFromConfig(cfg),
+	}
+}
+
+// FindDeployedStack locates the CloudFormation stack deployed by the pipeline that started the build.
+// It uses the pipeline execution from the environment, or the most recent in-progress execution,
+// and prefers a failed CloudFormation action over a successful one.
+func (c *Client) FindDeployedStack(ctx context.Context, env *Environment) (*DeployedStack, error) {
+	if env.PipelineName == "" {
+		return nil, ErrNoPipeline
+	}
+
+	executionID := env.PipelineExecutionID
+	if executionID == "" {
+		var err error
+		executionID, err = c.currentExecutionID(ctx, env.PipelineName)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var found *DeployedStack
+	var nextToken *string
+
+	for {
+		output, err := c.cp.ListActionExecutions(ctx, &codepipeline.ListActionExecutionsInput{
+			PipelineName: aws.String(env.PipelineName),
+			Filter:       &types.ActionExecutionFilter{PipelineExecutionId: aws.String(executionID)},
+			NextToken:    nextToken,
+		})
+		if err != nil {
+			awsErr := awserrors.ParseAWSError(err, "CodePipeline")
+			return nil, fmt.Errorf("failed to list action executions of pipeline '%s': %w", env.PipelineName, awsErr)
+		}
+
+		for _, action := range output.ActionExecutionDetails {
+			stack := stackFromAction(action)
+			if stack == nil {
+				continue
+			}
+			if found == nil || (stack.Failed() && !found.Failed()) {
+				found = stack
+			}
+		}
+
+		if output.NextToken == nil {
+			break
+		}
+		nextToken = output.NextToken
+	}
+
+	if found == nil {
+		return nil, fmt.Errorf("%w: pipeline '%s', execution %s", ErrNoStackAction, env.PipelineName, executionID)
+	}
+
+	return found, nil
+}
+
+// currentExecutionID returns the most recent in-progress execution of the pipeline,
+// falling back to the most recent execution
+func (c *Client) currentExecutionID(ctx context.Context, pipelineName string) (string, error) {
+	output, err := c.cp.ListPipelineExecutions(ctx, &codepipeline.ListPipelineExecutionsInput{
+		PipelineName: aws.String(pipelineName),
+		MaxResults:   aws.Int32(10),
+	})
+	if err != nil {
+		awsErr := awserrors.ParseAWSError(err, "CodePipeline")
+		return "", fmt.Errorf("failed to list executions of pipeline '%s': %w", pipelineName, awsErr)
+	}
+
+	if len(output.PipelineExecutionSummaries) == 0 {
+		return "", fmt.Errorf("no executions found for pipeline '%s'", pipelineName)
+	}
+
+	for _, summary := range output.PipelineExecutionSummaries {
+		if summary.Status == types.PipelineExecutionStatusInProgress {
+			return aws.ToString(summary.PipelineExecutionId), nil
+		}
+	}
+
+	return aws.ToString(output.PipelineExecutionSummaries[0].PipelineExecutionId), nil
+}
+
+// stackFromAction returns the deployed stack of a CloudFormation action, or nil for other actions
+func stackFromAction(action types.ActionExecutionDetail) *DeployedStack {
+	if action.Input == nil || action.Input.ActionTypeId == nil {
+		return nil
+	}
+	if aws.ToString(action.Input.ActionTypeId.Provider) != cloudFormationProvider {
+		return nil
+	}
+
+	stackName := action.Input.ResolvedConfiguration["StackName"]
+	if stackName == "" {
+		stackName = action.Input.Configuration["StackName"]
+	}
+	if stackName == "" {
+		return nil
+	}
+
+	return &DeployedStack{
+		StackName:  stackName,
+		Region:     aws.ToString(action.Input.Region),
+		ActionName: aws.ToString(action.ActionName),
+		StageName:  aws.ToString(action.StageName),
+		Status:     string(action.Status),
+	}
+}
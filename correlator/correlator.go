@@ -2,32 +2,68 @@
 package correlator
 
 import (
+	"sort"
 	"strings"
 	"time"
 
 	"cfn-root-cause/analyzer"
+	"cfn-root-cause/classify"
 )
 
 // DefaultTimeWindow is the default time window for correlating events (5 minutes)
 const DefaultTimeWindow = 5 * time.Minute
 
+// DefaultMaxTimeWindow is the largest time window the default configuration widens to (20 minutes)
+const DefaultMaxTimeWindow = 20 * time.Minute
+
+// cdkHashLength is the length of the hash CDK appends to the logical IDs it generates, e.g.
+// MyBucketF68F3FF0 for the construct MyBucket
+const cdkHashLength = 8
+
+// minFuzzyIdLength is the length logical IDs and name fragments need, stripped of their hash,
+// to match fuzzily, so short IDs such as "Role" do not match unrelated names
+const minFuzzyIdLength = 6
+
+// maxGeneratedNameLength is the length of the longest parameter or response value compared as a
+// generated name; longer values are documents such as policies or templates that mention many resources
+const maxGeneratedNameLength = 512
+
+// widenedMinScore is the score events need to match within a widened time window: they must
+// mention the resource or come from its service, since a time match alone is likely coincidental
+const widenedMinScore = 2
+
 // CorrelationConfig holds configuration for error correlation
 type CorrelationConfig struct {
 	// TimeWindow is the maximum time difference between CloudFormation and CloudTrail events
 	// for them to be considered correlated
 	TimeWindow time.Duration
+
+	// MaxTimeWindow is the largest time window the correlation widens to, doubling TimeWindow each
+	// time, when no event matches an error, since resource providers retrying internally can call
+	// the service long before CloudFormation records the failure. No widening if it is not larger
+	// than TimeWindow.
+	MaxTimeWindow time.Duration
 }
 
 // DefaultConfig returns the default correlation configuration
 func DefaultConfig() CorrelationConfig {
 	return CorrelationConfig{
-		TimeWindow: DefaultTimeWindow,
+		TimeWindow:    DefaultTimeWindow,
+		MaxTimeWindow: DefaultMaxTimeWindow,
 	}
 }
 
+// Correlation confidences returned by Confidence
+const (
+	ConfidenceHigh   = "high"
+	ConfidenceMedium = "medium"
+	ConfidenceLow    = "low"
+)
+
 // CorrelateErrors matches CloudFormation errors with CloudTrail events.
 // It returns a slice of CorrelatedError containing the original CloudFormation error,
-// any matching CloudTrail event, and a detailed message extracted from CloudTrail.
+// any matching CloudTrail event, and a detailed message extracted from CloudTrail. Calls the
+// resource provider retried with the same error are summarized with their count and time span.
 // Uses the default time window for correlation.
 func CorrelateErrors(cfnErrors []analyzer.StackError, trailEvents []analyzer.CloudTrailEvent) []analyzer.CorrelatedError {
 	return CorrelateErrorsWithConfig(cfnErrors, trailEvents, DefaultConfig())
@@ -42,6 +78,9 @@ func CorrelateErrorsWithConfig(cfnErrors []analyzer.StackError, trailEvents []an
 
 	correlatedErrors := make([]analyzer.CorrelatedError, 0, len(cfnErrors))
 
+	// Index the CloudTrail events once instead of scanning them for each error
+	index := NewIndex(trailEvents)
+
 	for _, cfnError := range cfnErrors {
 		correlated := analyzer.CorrelatedError{
 			StackError:      cfnError,
@@ -49,9 +88,11 @@ func CorrelateErrorsWithConfig(cfnErrors []analyzer.StackError, trailEvents []an
 		}
 
 		// Find matching CloudTrail event
-		matchingEvent := FindMatchingTrailEventWithConfig(cfnError, trailEvents, config)
+		matchingEvent, window := index.FindMatchWidening(cfnError, config)
 		if matchingEvent != nil {
 			correlated.CloudTrailEvent = matchingEvent
+			correlated.CorrelationWindow = window
+			correlated.Retries = index.RetriesOf(matchingEvent, cfnError.Timestamp, window)
 			// Extract detailed message from CloudTrail if available
 			detailedMsg := extractDetailedMessage(*matchingEvent)
 			if detailedMsg != "" {
@@ -74,96 +115,433 @@ func FindMatchingTrailEvent(cfnError analyzer.StackError, trailEvents []analyzer
 // FindMatchingTrailEventWithConfig finds a CloudTrail event that matches a CloudFormation error
 // using the provided configuration.
 // Matching is based on:
-// 1. Timestamp proximity (within the configured time window)
-// 2. Resource identifier matching (logical resource ID in event source/name)
+// 1. Timestamp proximity (within the configured time window, widened up to the maximum window)
+// 2. Resource identifier matching (logical resource ID in event source/name, physical resource ID
+// among the resources of the event)
 // 3. Presence of error information in the CloudTrail event
 func FindMatchingTrailEventWithConfig(cfnError analyzer.StackError, trailEvents []analyzer.CloudTrailEvent, config CorrelationConfig) *analyzer.CloudTrailEvent {
 	if len(trailEvents) == 0 {
 		return nil
 	}
+	event, _ := NewIndex(trailEvents).FindMatchWidening(cfnError, config)
+	return event
+}
 
-	var bestMatch *analyzer.CloudTrailEvent
-	var bestScore int
-	var bestTimeDiff time.Duration = config.TimeWindow + 1 // Initialize to beyond window
+// Index holds the CloudTrail events with error information, bucketed by event source and sorted
+// by event time, so the candidates for an error are found by binary search. Events without error
+// information never match and are left out.
+type Index struct {
+	buckets []sourceBucket
+}
+
+// sourceBucket holds the events of one event source, sorted by event time
+type sourceBucket struct {
+	// source is the lowercase event source, e.g. "lambda.amazonaws.com"
+	source string
+	events []preparedEvent
+}
+
+// preparedEvent holds the lowercase fields of a CloudTrail event that matching compares, so they
+// are computed once per event instead of once per error and event
+type preparedEvent struct {
+	event          *analyzer.CloudTrailEvent
+	position       int
+	eventName      string
+	errorMessage   string
+	responseValues []string
+
+	// resourceNames are the lowercase ARNs and names of the resources CloudTrail lists for the event
+	resourceNames []string
+
+	// names are the string values of the request parameters and response elements, including
+	// nested ones, that may be names CloudFormation or CDK generated from a logical ID
+	names []generatedName
+}
+
+// generatedName is a request parameter or response element value prepared for fuzzy matching
+// with logical IDs
+type generatedName struct {
+	// normalized is the lowercase value without separators, e.g. "mystackmybucketf68f3ff01abc2def"
+	normalized string
 
+	// segments are the lowercase parts of the value between separators, e.g. "mystack",
+	// "mybucketf68f3ff0" and "1abc2def" for the bucket name mystack-mybucketf68f3ff0-1abc2def
+	segments []string
+}
+
+// preparedError holds the lowercase logical and physical resource IDs and service name of a
+// CloudFormation error
+type preparedError struct {
+	resourceId string
+	physicalId string
+
+	// fuzzyId is the lowercase logical ID without separators and CDK hash, "" if too short to
+	// match fuzzily
+	fuzzyId string
+
+	service    string
+	hasService bool
+}
+
+// NewIndex indexes CloudTrail events for matching. The events are referenced, not copied.
+func NewIndex(trailEvents []analyzer.CloudTrailEvent) *Index {
+	bySource := make(map[string][]preparedEvent)
 	for i := range trailEvents {
 		event := &trailEvents[i]
-
-		// Check timestamp proximity
-		timeDiff := absTimeDiff(cfnError.Timestamp, event.EventTime)
-		if timeDiff > config.TimeWindow {
+		if !hasErrorInformation(*event) {
 			continue
 		}
 
-		// Calculate match score
-		score := calculateMatchScore(cfnError, *event)
-		if score == 0 {
+		source := strings.ToLower(event.EventSource)
+		bySource[source] = append(bySource[source], prepareEvent(event, i))
+	}
+
+	index := &Index{buckets: make([]sourceBucket, 0, len(bySource))}
+	for source, events := range bySource {
+		sort.SliceStable(events, func(i, j int) bool {
+			return events[i].event.EventTime.Before(events[j].event.EventTime)
+		})
+		index.buckets = append(index.buckets, sourceBucket{source: source, events: events})
+	}
+	sort.Slice(index.buckets, func(i, j int) bool {
+		return index.buckets[i].source < index.buckets[j].source
+	})
+	return index
+}
+
+// FindMatch returns the indexed event that best matches a CloudFormation error within the time
+// window of the configuration, or nil if none does. The best match has the highest score; ties go
+// to the closer event, then the earlier one, then the lower event ID, then the one indexed first,
+// so the result does not depend on map or bucket order.
+func (idx *Index) FindMatch(cfnError analyzer.StackError, config CorrelationConfig) *analyzer.CloudTrailEvent {
+	return idx.findMatch(prepareError(cfnError), cfnError.Timestamp, config.TimeWindow, 0)
+}
+
+// FindMatchWidening returns the indexed event that best matches a CloudFormation error, and the
+// time window it matched in. If no event matches within the time window of the configuration, the
+// window is doubled up to its maximum; events matching in a widened window must mention the
+// resource or come from its service. It returns nil and 0 if no event matches.
+func (idx *Index) FindMatchWidening(cfnError analyzer.StackError, config CorrelationConfig) (*analyzer.CloudTrailEvent, time.Duration) {
+	prepared := prepareError(cfnError)
+	if event := idx.findMatch(prepared, cfnError.Timestamp, config.TimeWindow, 0); event != nil {
+		return event, config.TimeWindow
+	}
+
+	for window := config.TimeWindow; window > 0 && window < config.MaxTimeWindow; {
+		window = min(window*2, config.MaxTimeWindow)
+		if event := idx.findMatch(prepared, cfnError.Timestamp, window, widenedMinScore); event != nil {
+			return event, window
+		}
+	}
+	return nil, 0
+}
+
+// findMatch returns the best match with at least minScore within window around the timestamp
+func (idx *Index) findMatch(prepared preparedError, timestamp time.Time, window time.Duration, minScore int) *analyzer.CloudTrailEvent {
+	windowStart := timestamp.Add(-window)
+	windowEnd := timestamp.Add(window)
+
+	var best *preparedEvent
+	var bestScore int
+	var bestTimeDiff time.Duration
+
+	for b := range idx.buckets {
+		bucket := &idx.buckets[b]
+
+		// Check resource type match once per bucket (event source often contains service name)
+		typeMatch := matchesResourceType(prepared, bucket.source)
+
+		// Check timestamp proximity: only the events within the window are scored
+		first := sort.Search(len(bucket.events), func(i int) bool {
+			return !bucket.events[i].event.EventTime.Before(windowStart)
+		})
+		for i := first; i < len(bucket.events) && !bucket.events[i].event.EventTime.After(windowEnd); i++ {
+			candidate := &bucket.events[i]
+			score := calculateMatchScore(prepared, candidate, typeMatch)
+			if score < minScore {
+				continue
+			}
+			timeDiff := absTimeDiff(timestamp, candidate.event.EventTime)
+			if best == nil || isBetterMatch(score, timeDiff, candidate, bestScore, bestTimeDiff, best) {
+				best, bestScore, bestTimeDiff = candidate, score, timeDiff
+			}
+		}
+	}
+
+	if best == nil {
+		return nil
+	}
+	return best.event
+}
+
+// RetriesOf summarizes the indexed events within the time window around the timestamp that
+// repeat the call of the matched event with the same error, as resource providers do when they
+// retry a failing call; nil if the call failed only once
+func (idx *Index) RetriesOf(match *analyzer.CloudTrailEvent, timestamp time.Time, window time.Duration) *analyzer.RetrySummary {
+	source := strings.ToLower(match.EventSource)
+	b := sort.Search(len(idx.buckets), func(i int) bool { return idx.buckets[i].source >= source })
+	if b == len(idx.buckets) || idx.buckets[b].source != source {
+		return nil
+	}
+	bucket := &idx.buckets[b]
+
+	windowStart := timestamp.Add(-window)
+	windowEnd := timestamp.Add(window)
+	first := sort.Search(len(bucket.events), func(i int) bool {
+		return !bucket.events[i].event.EventTime.Before(windowStart)
+	})
+
+	var summary analyzer.RetrySummary
+	for i := first; i < len(bucket.events) && !bucket.events[i].event.EventTime.After(windowEnd); i++ {
+		event := bucket.events[i].event
+		if event.EventName != match.EventName || event.ErrorCode != match.ErrorCode || event.ErrorMessage != match.ErrorMessage {
 			continue
 		}
+		if summary.Count == 0 {
+			summary.First = event.EventTime
+		}
+		summary.Last = event.EventTime
+		summary.Count++
+	}
+
+	if summary.Count < 2 {
+		return nil
+	}
+	return &summary
+}
+
+// isBetterMatch reports whether a candidate beats the best match so far
+func isBetterMatch(score int, timeDiff time.Duration, candidate *preparedEvent, bestScore int, bestTimeDiff time.Duration, best *preparedEvent) bool {
+	switch {
+	case score != bestScore:
+		return score > bestScore
+	case timeDiff != bestTimeDiff:
+		return timeDiff < bestTimeDiff
+	case !candidate.event.EventTime.Equal(best.event.EventTime):
+		return candidate.event.EventTime.Before(best.event.EventTime)
+	case candidate.event.EventID != best.event.EventID:
+		return candidate.event.EventID < best.event.EventID
+	default:
+		return candidate.position < best.position
+	}
+}
 
-		// Prefer higher score, or closer timestamp if scores are equal
-		if score > bestScore || (score == bestScore && timeDiff < bestTimeDiff) {
-			bestMatch = event
-			bestScore = score
-			bestTimeDiff = timeDiff
+// prepareEvent prepares a CloudTrail event at the given position of the indexed events for matching
+func prepareEvent(event *analyzer.CloudTrailEvent, position int) preparedEvent {
+	candidate := preparedEvent{
+		event:        event,
+		position:     position,
+		eventName:    strings.ToLower(event.EventName),
+		errorMessage: strings.ToLower(event.ErrorMessage),
+	}
+	for _, value := range event.ResponseElements {
+		if strVal, ok := value.(string); ok {
+			candidate.responseValues = append(candidate.responseValues, strings.ToLower(strVal))
 		}
 	}
+	for _, resource := range event.Resources {
+		candidate.resourceNames = append(candidate.resourceNames, strings.ToLower(resource.Name))
+	}
+	candidate.names = collectNames(candidate.names, event.RequestParameters)
+	candidate.names = collectNames(candidate.names, event.ResponseElements)
+	return candidate
+}
 
-	return bestMatch
+// collectNames appends the string values of request parameters or response elements, including
+// those of nested objects and lists, prepared for fuzzy matching
+func collectNames(names []generatedName, value interface{}) []generatedName {
+	switch v := value.(type) {
+	case string:
+		if v == "" || len(v) > maxGeneratedNameLength {
+			return names
+		}
+		lower := strings.ToLower(v)
+		return append(names, generatedName{
+			normalized: normalizeIdentifier(lower),
+			segments:   strings.FieldsFunc(lower, isSeparator),
+		})
+	case map[string]interface{}:
+		for _, nested := range v {
+			names = collectNames(names, nested)
+		}
+	case []interface{}:
+		for _, nested := range v {
+			names = collectNames(names, nested)
+		}
+	}
+	return names
 }
 
-// calculateMatchScore calculates a score indicating how well a CloudTrail event
-// matches a CloudFormation error. Higher scores indicate better matches.
-func calculateMatchScore(cfnError analyzer.StackError, trailEvent analyzer.CloudTrailEvent) int {
-	score := 0
+// prepareError prepares a CloudFormation error for matching
+func prepareError(cfnError analyzer.StackError) preparedError {
+	prepared := preparedError{
+		resourceId: strings.ToLower(cfnError.LogicalResourceId),
+		physicalId: strings.ToLower(cfnError.PhysicalResourceId),
+		fuzzyId:    fuzzyIdentifier(cfnError.LogicalResourceId),
+	}
 
-	// Must have error information to be a valid match
-	if !hasErrorInformation(trailEvent) {
-		return 0
+	// Extract the event source of the service from the resource type (e.g., "AWS::Lambda::Function" -> "lambda",
+	// "AWS::ElasticLoadBalancingV2::LoadBalancer" -> "elasticloadbalancing")
+	if service := classify.EventSource(cfnError.ResourceType); service != "" {
+		prepared.service = service
+		prepared.hasService = true
 	}
+	return prepared
+}
 
+// calculateMatchScore calculates a score indicating how well a CloudTrail event
+// with error information matches a CloudFormation error. Higher scores indicate better matches.
+func calculateMatchScore(cfnError preparedError, candidate *preparedEvent, typeMatch bool) int {
 	// Base score for having error information
-	score += 1
+	score := 1
+
+	// An event naming the physical resource is the strongest evidence
+	if matchesPhysicalResource(cfnError, candidate) {
+		score += 4
+	}
 
 	// Check resource identifier match
-	if matchesResourceIdentifier(cfnError, trailEvent) {
+	if matchesResourceIdentifier(cfnError, candidate) {
 		score += 3
 	}
 
-	// Check resource type match (event source often contains service name)
-	if matchesResourceType(cfnError, trailEvent) {
+	// Resource type match, determined per event source
+	if typeMatch {
 		score += 2
 	}
 
 	return score
 }
 
+// Confidence rates how reliably the CloudTrail event of a correlated error belongs to the error:
+// high if CloudTrail lists the physical resource for the event, or if the event mentions the
+// resource and comes from the service of its resource type, medium if one of both holds and low if
+// only the time matches. It returns "" without a CloudTrail event.
+func Confidence(err analyzer.CorrelatedError) string {
+	if err.CloudTrailEvent == nil {
+		return ""
+	}
+
+	prepared := prepareError(err.StackError)
+	candidate := prepareEvent(err.CloudTrailEvent, 0)
+	identifierMatch := matchesResourceIdentifier(prepared, &candidate)
+	typeMatch := matchesResourceType(prepared, strings.ToLower(err.CloudTrailEvent.EventSource))
+	switch {
+	case matchesPhysicalResource(prepared, &candidate):
+		return ConfidenceHigh
+	case identifierMatch && typeMatch:
+		return ConfidenceHigh
+	case identifierMatch || typeMatch:
+		return ConfidenceMedium
+	default:
+		return ConfidenceLow
+	}
+}
+
+// Signals tying the CloudTrail event of a correlated error to the error, reported by Evidence
+const (
+	// SignalPhysicalID is set if CloudTrail lists the physical resource for the event
+	SignalPhysicalID = "physical-id"
+
+	// SignalRequestID is set if the status reason names the request ID of the event
+	SignalRequestID = "request-id"
+
+	// SignalResourceID is set if the event name, error message or response mentions the logical ID,
+	// or a request parameter or response element is a name generated from it
+	SignalResourceID = "resource-id"
+
+	// SignalService is set if the event comes from the service of the resource type
+	SignalService = "service"
+
+	// SignalTime is the time of the event relative to the error; always set
+	SignalTime = "time"
+)
+
+// Signal is a piece of evidence tying the CloudTrail event of a correlated error to the error
+type Signal struct {
+	// Kind is one of the Signal constants
+	Kind string
+
+	// Value is what matched: the physical ID, request ID, logical ID or event source; empty for SignalTime
+	Value string
+
+	// Offset is the time of the event relative to the error, negative if the event came first
+	Offset time.Duration
+}
+
+// Evidence lists the signals tying the CloudTrail event of a correlated error to the error, the
+// strongest first, so users can judge the correlation: the physical resource CloudTrail lists for
+// the event, the request ID named by the status reason, the logical ID mentioned by the event, the
+// service of the event and the time offset. It returns nil without a CloudTrail event.
+func Evidence(err analyzer.CorrelatedError) []Signal {
+	event := err.CloudTrailEvent
+	if event == nil {
+		return nil
+	}
+
+	prepared := prepareError(err.StackError)
+	candidate := prepareEvent(event, 0)
+	var signals []Signal
+	if matchesPhysicalResource(prepared, &candidate) {
+		signals = append(signals, Signal{Kind: SignalPhysicalID, Value: err.StackError.PhysicalResourceId})
+	}
+	if requestId := classify.ReasonRequestID(err.StackError.ResourceStatusReason); requestId != "" && strings.EqualFold(requestId, event.RequestID) {
+		signals = append(signals, Signal{Kind: SignalRequestID, Value: event.RequestID})
+	}
+	if matchesResourceIdentifier(prepared, &candidate) {
+		signals = append(signals, Signal{Kind: SignalResourceID, Value: err.StackError.LogicalResourceId})
+	}
+	if matchesResourceType(prepared, strings.ToLower(event.EventSource)) {
+		signals = append(signals, Signal{Kind: SignalService, Value: event.EventSource})
+	}
+	return append(signals, Signal{Kind: SignalTime, Offset: event.EventTime.Sub(err.StackError.Timestamp)})
+}
+
 // matchesResourceIdentifier checks if the CloudTrail event is related to the
 // CloudFormation resource by comparing identifiers
-func matchesResourceIdentifier(cfnError analyzer.StackError, trailEvent analyzer.CloudTrailEvent) bool {
-	if cfnError.LogicalResourceId == "" {
+func matchesResourceIdentifier(cfnError preparedError, candidate *preparedEvent) bool {
+	if cfnError.resourceId == "" {
 		return false
 	}
 
-	resourceId := strings.ToLower(cfnError.LogicalResourceId)
-
 	// Check if resource ID appears in event name
-	if strings.Contains(strings.ToLower(trailEvent.EventName), resourceId) {
+	if strings.Contains(candidate.eventName, cfnError.resourceId) {
 		return true
 	}
 
 	// Check if resource ID appears in error message
-	if strings.Contains(strings.ToLower(trailEvent.ErrorMessage), resourceId) {
+	if strings.Contains(candidate.errorMessage, cfnError.resourceId) {
 		return true
 	}
 
 	// Check responseElements for resource references
-	if trailEvent.ResponseElements != nil {
-		for _, value := range trailEvent.ResponseElements {
-			if strVal, ok := value.(string); ok {
-				if strings.Contains(strings.ToLower(strVal), resourceId) {
-					return true
-				}
+	for _, value := range candidate.responseValues {
+		if strings.Contains(value, cfnError.resourceId) {
+			return true
+		}
+	}
+
+	// Check request parameters and response elements for names generated from the logical ID
+	return matchesGeneratedName(cfnError, candidate)
+}
+
+// matchesGeneratedName checks if a request parameter or response element is a name generated
+// from the logical ID, as CloudFormation does for resources without explicit names, e.g.
+// MyStack-MyBucketF68F3FF0-1ABC2DEF: the name contains the logical ID without its CDK hash, or a
+// part of the name is the start of the logical ID, as when CloudFormation truncated it to fit the
+// name length limit of the service
+func matchesGeneratedName(cfnError preparedError, candidate *preparedEvent) bool {
+	if cfnError.fuzzyId == "" {
+		return false
+	}
+
+	for _, name := range candidate.names {
+		if strings.Contains(name.normalized, cfnError.fuzzyId) {
+			return true
+		}
+		for _, segment := range name.segments {
+			if len(segment) >= minFuzzyIdLength && strings.HasPrefix(cfnError.resourceId, segment) {
+				return true
 			}
 		}
 	}
@@ -171,30 +549,80 @@ func matchesResourceIdentifier(cfnError analyzer.StackError, trailEvent analyzer
 	return false
 }
 
-// matchesResourceType checks if the CloudTrail event source matches the
-// CloudFormation resource type
-func matchesResourceType(cfnError analyzer.StackError, trailEvent analyzer.CloudTrailEvent) bool {
-	if cfnError.ResourceType == "" || trailEvent.EventSource == "" {
-		return false
+// fuzzyIdentifier returns the lowercase logical ID without separators and without the hash CDK
+// appends to the logical IDs it generates, or "" if the result is too short to match fuzzily
+func fuzzyIdentifier(logicalId string) string {
+	id := logicalId
+	if len(id) > cdkHashLength && isCDKHash(id[len(id)-cdkHashLength:]) {
+		id = id[:len(id)-cdkHashLength]
+	}
+	id = normalizeIdentifier(strings.ToLower(id))
+	if len(id) < minFuzzyIdLength {
+		return ""
 	}
+	return id
+}
 
-	// Extract service name from CloudFormation resource type (e.g., "AWS::Lambda::Function" -> "lambda")
-	resourceType := strings.ToLower(cfnError.ResourceType)
-	eventSource := strings.ToLower(trailEvent.EventSource)
+// isCDKHash reports whether s looks like the hash of a CDK logical ID: uppercase hexadecimal digits
+func isCDKHash(s string) bool {
+	for _, c := range s {
+		if (c < '0' || c > '9') && (c < 'A' || c > 'F') {
+			return false
+		}
+	}
+	return true
+}
 
-	// CloudFormation resource types are like "AWS::ServiceName::ResourceType"
-	parts := strings.Split(resourceType, "::")
-	if len(parts) >= 2 {
-		serviceName := strings.ToLower(parts[1])
-		// CloudTrail event sources are like "servicename.amazonaws.com"
-		if strings.Contains(eventSource, serviceName) {
+// normalizeIdentifier removes the separators from a lowercase identifier
+func normalizeIdentifier(s string) string {
+	return strings.Map(func(c rune) rune {
+		if isSeparator(c) {
+			return -1
+		}
+		return c
+	}, s)
+}
+
+// isSeparator reports whether c separates the parts of a generated name, i.e. is not a lowercase
+// letter or digit
+func isSeparator(c rune) bool {
+	return (c < 'a' || c > 'z') && (c < '0' || c > '9')
+}
+
+// matchesPhysicalResource checks if CloudTrail lists the physical resource of the CloudFormation
+// error among the resources of the event: by the same name, or by an ARN ending with the physical
+// ID after a ':' or '/', e.g. arn:aws:iam::123456789012:role/service/MyRole for MyRole
+func matchesPhysicalResource(cfnError preparedError, candidate *preparedEvent) bool {
+	if cfnError.physicalId == "" {
+		return false
+	}
+
+	for _, name := range candidate.resourceNames {
+		if name == cfnError.physicalId {
 			return true
 		}
+		if strings.HasPrefix(name, "arn:") && strings.HasSuffix(name, cfnError.physicalId) {
+			separator := name[len(name)-len(cfnError.physicalId)-1]
+			if separator == ':' || separator == '/' {
+				return true
+			}
+		}
 	}
 
 	return false
 }
 
+// matchesResourceType checks if the lowercase CloudTrail event source matches the
+// CloudFormation resource type
+func matchesResourceType(cfnError preparedError, eventSource string) bool {
+	if !cfnError.hasService || eventSource == "" {
+		return false
+	}
+
+	// CloudTrail event sources are like "servicename.amazonaws.com"
+	return strings.Contains(eventSource, cfnError.service)
+}
+
 // hasErrorInformation checks if a CloudTrail event contains error information
 func hasErrorInformation(event analyzer.CloudTrailEvent) bool {
 	if event.ErrorCode != "" || event.ErrorMessage != "" {
@@ -302,4 +730,4 @@ func GetCorrelationSummary(correlatedErrors []analyzer.CorrelatedError) (total,
 		}
 	}
 	return
-}
\ No newline at end of file
+}
@@ -0,0 +1,71 @@
+package correlator
+
+import (
+	"math/rand"
+	"testing"
+	"time"
+
+	"cfn-root-cause/analyzer"
+	"cfn-root-cause/extractor"
+	"cfn-root-cause/synthetic"
+)
+
+func BenchmarkCorrelateErrors(b *testing.B) {
+	random := rand.New(rand.NewSource(1))
+	events := synthetic.StackEvents(random, synthetic.Start, 10000, 0.1)
+	trail := synthetic.TrailEvents(random, synthetic.Start, 10000, len(events))
+	stackErrors := extractor.ExtractErrors(events)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		CorrelateErrors(stackErrors, trail)
+	}
+}
+
+func TestEvidenceMatchesEventSource(t *testing.T) {
+	tests := []struct {
+		resourceType string
+		eventSource  string
+		want         bool
+	}{
+		{"AWS::ElasticLoadBalancingV2::LoadBalancer", "elasticloadbalancing.amazonaws.com", true},
+		{"AWS::ApiGatewayV2::Api", "apigateway.amazonaws.com", true},
+		{"AWS::Cognito::UserPool", "cognito-idp.amazonaws.com", true},
+		{"AWS::Cognito::UserPool", "cognito-identity.amazonaws.com", false},
+		{"AWS::Cognito::IdentityPool", "cognito-identity.amazonaws.com", true},
+		{"AWS::Cognito::IdentityPool", "cognito-idp.amazonaws.com", false},
+		{"AWS::Lambda::Function", "lambda.amazonaws.com", true},
+		{"AWS::Lambda::Function", "s3.amazonaws.com", false},
+	}
+
+	timestamp := time.Date(2026, 1, 8, 9, 38, 59, 0, time.UTC)
+	for _, tc := range tests {
+		t.Run(tc.resourceType+"/"+tc.eventSource, func(t *testing.T) {
+			err := analyzer.CorrelatedError{
+				StackError: analyzer.StackError{
+					Timestamp:         timestamp,
+					ResourceType:      tc.resourceType,
+					LogicalResourceId: "Resource",
+					ResourceStatus:    "CREATE_FAILED",
+				},
+				CloudTrailEvent: &analyzer.CloudTrailEvent{
+					EventTime:   timestamp,
+					EventName:   "Create",
+					EventSource: tc.eventSource,
+					ErrorCode:   "ValidationException",
+				},
+			}
+
+			got := false
+			for _, signal := range Evidence(err) {
+				if signal.Kind == SignalService {
+					got = true
+				}
+			}
+			if got != tc.want {
+				t.Errorf("service signal for %s from %s = %v, want %v", tc.resourceType, tc.eventSource, got, tc.want)
+			}
+		})
+	}
+}
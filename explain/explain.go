@@ -0,0 +1,181 @@
+// Package explain describes the root causes of a failed deployment in plain English, for readers
+// who do not know CloudFormation internals
+package explain
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	"cfn-root-cause/analyzer"
+	"cfn-root-cause/classify"
+)
+
+// Explanation is the narrative of one failed resource
+type Explanation struct {
+	LogicalResourceId string
+	Text              string
+}
+
+// resourceNames are the plain names of common resource types; other types are named after their
+// service and type name, e.g. "EC2 security group" for AWS::EC2::SecurityGroup
+var resourceNames = map[string]string{
+	"AWS::CloudFormation::CustomResource": "custom resource",
+	"AWS::CloudFormation::Stack":          "nested stack",
+	"AWS::DynamoDB::Table":                "DynamoDB table",
+	"AWS::EC2::Instance":                  "EC2 instance",
+	"AWS::IAM::Policy":                    "IAM policy",
+	"AWS::IAM::Role":                      "IAM role",
+	"AWS::Lambda::Function":               "Lambda function",
+	"AWS::RDS::DBInstance":                "RDS database instance",
+	"AWS::S3::Bucket":                     "S3 bucket",
+	"AWS::SNS::Topic":                     "SNS topic",
+	"AWS::SQS::Queue":                     "SQS queue",
+}
+
+// operations describe the failed operation by resource status
+var operations = map[string]string{
+	"CREATE_FAILED": "could not be created",
+	"UPDATE_FAILED": "could not be updated",
+	"DELETE_FAILED": "could not be deleted",
+	"IMPORT_FAILED": "could not be imported",
+}
+
+// causes describe the failure category in words
+var causes = map[string]string{
+	classify.CategoryPermissions: "because AWS denied a request made on its behalf",
+	classify.CategoryValidation:  "because AWS rejected one of its properties as invalid",
+	classify.CategoryLimit:       "because an account quota or service limit was reached",
+	classify.CategoryConflict:    "because a resource with the same name or identity already exists",
+	classify.CategoryNotFound:    "because something it refers to does not exist",
+	classify.CategoryThrottling:  "because AWS throttled the requests of the deployment",
+	classify.CategoryTimeout:     "because it did not become ready in time",
+	classify.CategoryInternal:    "because of an internal error of the AWS service",
+}
+
+// Explain returns a narrative for each root cause of the analysis, in the order of the errors.
+// Cancelled resources are consequences of other failures and not explained; a resource that
+// failed several times is explained once.
+func Explain(analysis *analyzer.StackAnalysis) []Explanation {
+	findings := make(map[string]analyzer.Finding)
+	for _, finding := range analysis.Findings {
+		if _, seen := findings[finding.LogicalResourceId]; !seen {
+			findings[finding.LogicalResourceId] = finding
+		}
+	}
+
+	var explanations []Explanation
+	explained := make(map[string]bool)
+	for _, err := range analysis.Errors {
+		logicalId := err.StackError.LogicalResourceId
+		if explained[logicalId] || classify.Category(err) == classify.CategoryCancelled {
+			continue
+		}
+		explained[logicalId] = true
+
+		var finding *analyzer.Finding
+		if f, ok := findings[logicalId]; ok {
+			finding = &f
+		}
+		explanations = append(explanations, Explanation{LogicalResourceId: logicalId, Text: Error(err, finding)})
+	}
+
+	return explanations
+}
+
+// Error returns the narrative of one error. The finding recognized for the resource, if any, is
+// given as the most likely cause.
+func Error(err analyzer.CorrelatedError, finding *analyzer.Finding) string {
+	var sentences []string
+
+	category := classify.Category(err)
+	sentence := fmt.Sprintf("The %s %s %s", ResourceName(err.StackError.ResourceType),
+		err.StackError.LogicalResourceId, operation(err.StackError.ResourceStatus))
+	if cause := causes[category]; cause != "" {
+		sentence += " " + cause
+	}
+	sentences = append(sentences, sentence+".")
+
+	if event := err.CloudTrailEvent; event != nil && event.ErrorCode != "" {
+		sentences = append(sentences, fmt.Sprintf("CloudTrail shows that the %s call to %s failed with %s.",
+			event.EventName, strings.TrimSuffix(event.EventSource, ".amazonaws.com"), event.ErrorCode))
+	}
+
+	if message := message(err); message != "" {
+		sentences = append(sentences, fmt.Sprintf("AWS reported: \"%s\".", message))
+	}
+
+	if finding != nil {
+		sentences = append(sentences, fmt.Sprintf("Most likely cause: %s. %s", finding.Title, finding.Explanation))
+		if finding.Suggestion != "" {
+			sentences = append(sentences, finding.Suggestion)
+		}
+	} else if classify.Retryable(err) {
+		sentences = append(sentences, "This kind of failure is usually transient, so retrying the deployment is likely to succeed.")
+	}
+
+	return strings.Join(sentences, " ")
+}
+
+// ResourceName returns the plain name of a resource type, e.g. "Lambda function"
+func ResourceName(resourceType string) string {
+	if name, ok := resourceNames[resourceType]; ok {
+		return name
+	}
+
+	parts := strings.Split(resourceType, "::")
+	switch {
+	case len(parts) > 0 && parts[0] == "Custom":
+		return "custom resource"
+	case len(parts) == 3 && parts[0] == "AWS":
+		return parts[1] + " " + splitWords(parts[2])
+	case len(parts) == 3:
+		return parts[0] + " " + parts[1] + " " + splitWords(parts[2])
+	case resourceType == "":
+		return "resource"
+	default:
+		return resourceType + " resource"
+	}
+}
+
+// splitWords splits a type name into lower-case words; abbreviations are kept, e.g.
+// "SecurityGroup" becomes "security group" and "DBCluster" "DB cluster"
+func splitWords(name string) string {
+	runes := []rune(name)
+
+	var words []string
+	start := 0
+	for i := 1; i <= len(runes); i++ {
+		boundary := i == len(runes) ||
+			(unicode.IsUpper(runes[i]) && !unicode.IsUpper(runes[i-1])) ||
+			(unicode.IsUpper(runes[i]) && i+1 < len(runes) && unicode.IsLower(runes[i+1]) && unicode.IsUpper(runes[i-1]))
+		if !boundary {
+			continue
+		}
+		word := string(runes[start:i])
+		if strings.ToUpper(word) != word {
+			word = strings.ToLower(word)
+		}
+		words = append(words, word)
+		start = i
+	}
+
+	return strings.Join(words, " ")
+}
+
+// operation describes the failed operation, e.g. "could not be created"
+func operation(status string) string {
+	if text, ok := operations[status]; ok {
+		return text
+	}
+	return "failed (" + status + ")"
+}
+
+// message returns the most specific error message, without trailing punctuation
+func message(err analyzer.CorrelatedError) string {
+	text := err.DetailedMessage
+	if text == "" {
+		text = err.StackError.ResourceStatusReason
+	}
+	return strings.TrimRight(strings.TrimSpace(text), ".")
+}
@@ -2,6 +2,8 @@
 package extractor
 
 import (
+	"iter"
+	"slices"
 	"strings"
 	"time"
 
@@ -19,41 +21,51 @@ var failedStatuses = map[types.ResourceStatus]bool{
 	types.ResourceStatusRollbackFailed:       true,
 }
 
-// generalServiceExceptionPatterns contains patterns that indicate a GeneralServiceException
+// generalServiceExceptionPatterns contains lowercase patterns that indicate a GeneralServiceException
 var generalServiceExceptionPatterns = []string{
-	"GeneralServiceException",
-	"General Service Exception",
-	"Internal Failure",
-	"InternalFailure",
-	"Service returned error",
+	"generalserviceexception",
+	"general service exception",
+	"internal failure",
+	"internalfailure",
+	"service returned error",
+	"handler returned message: \"null\"",
+	"handler returned message: null",
 }
 
 // ExtractErrors extracts and categorizes errors from CloudFormation stack events.
 // It identifies all events with failed statuses and flags GeneralServiceException errors.
 func ExtractErrors(events []types.StackEvent) []analyzer.StackError {
-	var errors []analyzer.StackError
-
-	for _, event := range events {
-		if !isFailedStatus(event.ResourceStatus) {
-			continue
-		}
+	return slices.Collect(Errors(events))
+}
 
-		stackError := analyzer.StackError{
-			Timestamp:            safeTime(event.Timestamp),
-			ResourceType:         safeString(event.ResourceType),
-			LogicalResourceId:    safeString(event.LogicalResourceId),
-			ResourceStatus:       string(event.ResourceStatus),
-			ResourceStatusReason: safeString(event.ResourceStatusReason),
-			EventId:              safeString(event.EventId),
+// Errors yields the errors of the stack events in order, without collecting them, so large
+// event histories can be filtered in a single pass
+func Errors(events []types.StackEvent) iter.Seq[analyzer.StackError] {
+	return func(yield func(analyzer.StackError) bool) {
+		for i := range events {
+			event := &events[i]
+			if !isFailedStatus(event.ResourceStatus) {
+				continue
+			}
+
+			stackError := analyzer.StackError{
+				Timestamp:            safeTime(event.Timestamp),
+				ResourceType:         safeString(event.ResourceType),
+				LogicalResourceId:    safeString(event.LogicalResourceId),
+				PhysicalResourceId:   safeString(event.PhysicalResourceId),
+				ResourceStatus:       string(event.ResourceStatus),
+				ResourceStatusReason: safeString(event.ResourceStatusReason),
+				EventId:              safeString(event.EventId),
+			}
+
+			// Check if this is a GeneralServiceException that needs CloudTrail investigation
+			stackError.IsGeneralServiceException = IsGeneralServiceException(stackError)
+
+			if !yield(stackError) {
+				return
+			}
 		}
-
-		// Check if this is a GeneralServiceException that needs CloudTrail investigation
-		stackError.IsGeneralServiceException = IsGeneralServiceException(stackError)
-
-		errors = append(errors, stackError)
 	}
-
-	return errors
 }
 
 // IsGeneralServiceException identifies generic errors that need CloudTrail investigation.
@@ -67,7 +79,7 @@ func IsGeneralServiceException(err analyzer.StackError) bool {
 
 	reasonLower := strings.ToLower(reason)
 	for _, pattern := range generalServiceExceptionPatterns {
-		if strings.Contains(reasonLower, strings.ToLower(pattern)) {
+		if strings.Contains(reasonLower, pattern) {
 			return true
 		}
 	}
@@ -94,4 +106,4 @@ func safeTime(t *time.Time) time.Time {
 		return time.Time{}
 	}
 	return *t
-}
\ No newline at end of file
+}
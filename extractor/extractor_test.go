@@ -0,0 +1,18 @@
+package extractor
+
+import (
+	"math/rand"
+	"testing"
+
+	"cfn-root-cause/synthetic"
+)
+
+func BenchmarkExtractErrors(b *testing.B) {
+	events := synthetic.StackEvents(rand.New(rand.NewSource(1)), synthetic.Start, 10000, 0.1)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		ExtractErrors(events)
+	}
+}
@@ -0,0 +1,81 @@
+// Package filter narrows an analysis down to the resources a user is interested in
+package filter
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"cfn-root-cause/analyzer"
+)
+
+// Criteria selects errors by glob patterns such as "AWS::IAM::*".
+// Within a field an error must match any pattern; all non-empty fields must match.
+type Criteria struct {
+	ResourceTypes []string
+	LogicalIds    []string
+	Statuses      []string
+}
+
+// IsEmpty reports whether no criteria are set
+func (c Criteria) IsEmpty() bool {
+	return len(c.ResourceTypes) == 0 && len(c.LogicalIds) == 0 && len(c.Statuses) == 0
+}
+
+// Validate checks that all patterns are well-formed
+func (c Criteria) Validate() error {
+	for _, patterns := range [][]string{c.ResourceTypes, c.LogicalIds, c.Statuses} {
+		for _, pattern := range patterns {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return fmt.Errorf("invalid filter pattern '%s': %w", pattern, err)
+			}
+		}
+	}
+	return nil
+}
+
+// Matches reports whether the error satisfies the criteria.
+// Statuses are compared case-insensitively.
+func (c Criteria) Matches(err analyzer.StackError) bool {
+	return matchAny(c.ResourceTypes, err.ResourceType) &&
+		matchAny(c.LogicalIds, err.LogicalResourceId) &&
+		matchAny(upper(c.Statuses), strings.ToUpper(err.ResourceStatus))
+}
+
+// Apply removes errors not matching the criteria from the analysis
+func Apply(analysis *analyzer.StackAnalysis, c Criteria) {
+	if c.IsEmpty() {
+		return
+	}
+
+	kept := make([]analyzer.CorrelatedError, 0, len(analysis.Errors))
+	for _, err := range analysis.Errors {
+		if c.Matches(err.StackError) {
+			kept = append(kept, err)
+		}
+	}
+
+	analysis.ReplaceErrors(kept)
+}
+
+// matchAny reports whether value matches any of the patterns; no patterns match everything
+func matchAny(patterns []string, value string) bool {
+	if len(patterns) == 0 {
+		return true
+	}
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, value); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// upper returns the patterns converted to upper case
+func upper(patterns []string) []string {
+	result := make([]string, len(patterns))
+	for i, pattern := range patterns {
+		result[i] = strings.ToUpper(pattern)
+	}
+	return result
+}
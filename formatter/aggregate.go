@@ -0,0 +1,146 @@
+package formatter
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"cfn-root-cause/aggregate"
+	"cfn-root-cause/analyzer"
+)
+
+// FormatAggregateAs formats the analyses of several stacks according to the given options.
+// The human-readable formats start with an aggregate section followed by the report of each stack;
+// the CI formats combine the issues and test suites of all stacks.
+func FormatAggregateAs(analyses []*analyzer.StackAnalysis, opts Options) (string, error) {
+	r := newRenderer(opts)
+	summary := aggregate.Summarize(analyses)
+
+	var sb strings.Builder
+
+	switch opts.Format {
+	case ReportText:
+		sb.WriteString(r.aggregateSection(analyses, summary, r.theme.Heading, r.theme.Reset, separator))
+		for _, analysis := range analyses {
+			sb.WriteString(r.analysisResults(analysis))
+			sb.WriteString(r.optionalStats(analysis))
+		}
+	case ReportPlain:
+		sb.WriteString(r.aggregateSection(analyses, summary, "", "", "="))
+		for _, analysis := range analyses {
+			sb.WriteString(r.plainText(analysis))
+			sb.WriteString(r.optionalStats(analysis))
+		}
+	case ReportCompact:
+		if !r.opts.Sections.HideSummary {
+			sb.WriteString(r.msg.format(msgAggregateCompactHeader,
+				summary.TotalStacks, summary.StacksWithErrors, summary.TotalErrors) + "\n")
+		}
+		for _, analysis := range analyses {
+			sb.WriteString(r.compact(analysis))
+		}
+	case ReportGitLab:
+		issues := []gitLabIssue{}
+		for _, analysis := range analyses {
+			issues = append(issues, gitLabIssues(analysis, opts.TemplatePath)...)
+		}
+		return encodeGitLabIssues(issues)
+	case ReportJUnit:
+		return r.junitReport(analyses...)
+	case ReportJSON:
+		return r.jsonAggregateDocument(analyses, summary)
+	case ReportMarkdown:
+		return r.markdownAggregate(analyses, summary), nil
+	default:
+		return "", fmt.Errorf("unknown format '%s'", opts.Format)
+	}
+
+	return sb.String(), nil
+}
+
+// aggregateSection formats the totals, failure categories, common error codes and the list of
+// stacks; the reports of the individual stacks follow it
+func (r *renderer) aggregateSection(analyses []*analyzer.StackAnalysis, summary aggregate.Summary, heading, reset, rule string) string {
+	var sb strings.Builder
+
+	sb.WriteString("\n")
+	sb.WriteString(strings.Repeat(rule, separatorWidth))
+	sb.WriteString("\n")
+	sb.WriteString(fmt.Sprintf("%s%s%s\n", heading, r.msg.get(msgAggregateTitle), reset))
+	sb.WriteString(strings.Repeat(rule, separatorWidth))
+	sb.WriteString("\n\n")
+
+	width := r.msg.labelWidth(2, msgStacksAnalyzed, msgStacksWithErrors, msgTotalErrors, msgIgnored)
+	sb.WriteString(fmt.Sprintf("%s%d\n", r.msg.label(msgStacksAnalyzed, width), summary.TotalStacks))
+	sb.WriteString(fmt.Sprintf("%s%d\n", r.msg.label(msgStacksWithErrors, width), summary.StacksWithErrors))
+	sb.WriteString(fmt.Sprintf("%s%d\n", r.msg.label(msgTotalErrors, width), summary.TotalErrors))
+	if summary.Ignored > 0 {
+		sb.WriteString(fmt.Sprintf("%s%d\n", r.msg.label(msgIgnored, width), summary.Ignored))
+	}
+
+	if len(summary.Categories) > 0 {
+		sb.WriteString(fmt.Sprintf("\n%s%s%s\n", heading, r.msg.get(msgFailuresByCategory), reset))
+		sb.WriteString(countTable(summary.Categories))
+	}
+
+	if len(summary.Services) > 0 {
+		sb.WriteString(fmt.Sprintf("\n%s%s%s\n", heading, r.msg.get(msgErrorsByService), reset))
+		sb.WriteString(countTable(summary.Services))
+	}
+
+	if len(summary.ErrorCodes) > 0 {
+		sb.WriteString(fmt.Sprintf("\n%s%s%s\n", heading, r.msg.get(msgCommonErrorCodes), reset))
+		sb.WriteString(countTable(summary.ErrorCodes))
+	}
+
+	sb.WriteString(fmt.Sprintf("\n%s%s%s\n", heading, r.msg.get(msgStacks), reset))
+	nameWidth := 0
+	for _, analysis := range analyses {
+		if n := len(analysis.StackName); n > nameWidth {
+			nameWidth = n
+		}
+	}
+	for _, analysis := range analyses {
+		sb.WriteString(fmt.Sprintf("  %s%s\n", pad(analysis.StackName, nameWidth+2),
+			r.msg.format(msgStackErrorCount, len(analysis.Errors))))
+	}
+
+	return sb.String()
+}
+
+// optionalStats returns the statistics footer of an analysis if statistics are enabled
+func (r *renderer) optionalStats(analysis *analyzer.StackAnalysis) string {
+	if !r.opts.ShowStats || analysis.Stats == nil {
+		return ""
+	}
+	return r.stats(analysis.Stats)
+}
+
+// countTable formats counts as an indented two-column list
+func countTable(counts []aggregate.Count) string {
+	var sb strings.Builder
+
+	width := 0
+	for _, count := range counts {
+		if n := len(count.Name); n > width {
+			width = n
+		}
+	}
+
+	for _, count := range counts {
+		sb.WriteString(fmt.Sprintf("  %s%d\n", pad(count.Name, width+2), count.Count))
+	}
+
+	return sb.String()
+}
+
+// latestAnalysisTime returns the most recent analysis time of the given analyses
+func latestAnalysisTime(analyses []*analyzer.StackAnalysis) time.Time {
+	var latest time.Time
+	for _, analysis := range analyses {
+		if analysis.AnalysisTime.After(latest) {
+			latest = analysis.AnalysisTime
+		}
+	}
+	return latest
+}
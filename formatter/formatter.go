@@ -5,8 +5,14 @@ import (
 	"fmt"
 	"strings"
 	"time"
+	"unicode/utf8"
 
+	"cfn-root-cause/aggregate"
 	"cfn-root-cause/analyzer"
+	"cfn-root-cause/classify"
+	"cfn-root-cause/correlator"
+	"cfn-root-cause/explain"
+	"cfn-root-cause/rootcause"
 )
 
 const (
@@ -22,29 +28,212 @@ const (
 	separator      = "─"
 	separatorWidth = 80
 	indentWidth    = 2
+
+	// DefaultMaxMessageLength is the message length after which the compact format truncates
+	DefaultMaxMessageLength = 100
+)
+
+// Report formats supported by FormatAs
+const (
+	ReportText     = "text"
+	ReportPlain    = "plain"
+	ReportCompact  = "compact"
+	ReportGitLab   = "gitlab"
+	ReportJUnit    = "junit"
+	ReportJSON     = "json"
+	ReportMarkdown = "markdown"
 )
 
+// Formats returns the names of all supported report formats
+func Formats() []string {
+	return []string{ReportText, ReportPlain, ReportCompact, ReportGitLab, ReportJUnit, ReportJSON, ReportMarkdown}
+}
+
+// IsValidFormat checks if the given name is a supported report format
+func IsValidFormat(format string) bool {
+	for _, f := range Formats() {
+		if f == format {
+			return true
+		}
+	}
+	return false
+}
+
+// Options controls how FormatAs renders a report
+type Options struct {
+	// Format is the report format, one of Formats()
+	Format string
+
+	// TemplatePath is referenced by the CI formats (gitlab, junit) so issues
+	// are attached to the template file in merge requests
+	TemplatePath string
+
+	// ShowStats appends the performance statistics footer to the human-readable formats
+	ShowStats bool
+
+	// Language selects the report language, one of Languages(); empty means English
+	Language string
+
+	// MaxMessageLength truncates messages in the compact format; 0 disables truncation
+	MaxMessageLength int
+
+	// Width wraps long messages of the text and plain formats to this many columns; 0 disables wrapping
+	Width int
+
+	// Theme colors the text format; nil means the default theme
+	Theme *Theme
+
+	// Explain adds a plain-English narrative of each root cause to the text, plain and json formats
+	Explain bool
+
+	// Sections selects which report sections are rendered
+	Sections Sections
+}
+
+// Sections hides individual report sections; the zero value renders all sections
+type Sections struct {
+	HideRootCause         bool
+	HideHeader            bool
+	HideSummary           bool
+	HideErrors            bool
+	HideCloudTrailDetails bool
+	HideFindings          bool
+	HideTemplateDiff      bool
+	HideStackContext      bool
+	HideAISummary         bool
+	HideWarnings          bool
+}
+
+// renderer formats reports using the message catalog of the selected language and the color theme
+type renderer struct {
+	opts  Options
+	msg   messages
+	theme Theme
+}
+
+// newRenderer creates a renderer for the given options
+func newRenderer(opts Options) *renderer {
+	theme := themes[DefaultThemeName]
+	if opts.Theme != nil {
+		theme = *opts.Theme
+	}
+
+	return &renderer{
+		opts:  opts,
+		msg:   catalogFor(opts.Language),
+		theme: theme,
+	}
+}
+
+// FormatAs formats the analysis results according to the given options
+func FormatAs(analysis *analyzer.StackAnalysis, opts Options) (string, error) {
+	r := newRenderer(opts)
+
+	var output string
+
+	switch opts.Format {
+	case ReportText:
+		output = r.analysisResults(analysis)
+	case ReportPlain:
+		output = r.plainText(analysis)
+	case ReportCompact:
+		output = r.compact(analysis)
+	case ReportGitLab:
+		return FormatGitLabCodeQuality(analysis, opts.TemplatePath)
+	case ReportJUnit:
+		return r.junitReport(analysis)
+	case ReportJSON:
+		return r.jsonDocument(analysis)
+	case ReportMarkdown:
+		return r.markdown(analysis), nil
+	default:
+		return "", fmt.Errorf("unknown format '%s'", opts.Format)
+	}
+
+	if opts.ShowStats && analysis != nil && analysis.Stats != nil {
+		output += r.stats(analysis.Stats)
+	}
+
+	return output, nil
+}
+
 // FormatAnalysisResults formats the complete analysis results for display.
 // It combines CloudFormation errors with CloudTrail details in a unified report.
 // Requirements: 5.1, 5.2, 5.4
 func FormatAnalysisResults(analysis *analyzer.StackAnalysis) string {
+	return newRenderer(Options{}).analysisResults(analysis)
+}
+
+// analysisResults formats the complete colored report
+func (r *renderer) analysisResults(analysis *analyzer.StackAnalysis) string {
 	if analysis == nil {
-		return "No analysis results available."
+		return r.msg.get(msgNoResults)
 	}
 
 	var sb strings.Builder
+	sections := r.opts.Sections
+
+	// Most likely root cause, so readers need not scroll through all errors
+	if !sections.HideRootCause {
+		sb.WriteString(r.rootCauseSection(analysis, r.theme.Heading, r.theme.Reset))
+	}
 
 	// Header section
-	sb.WriteString(formatHeader(analysis))
+	if !sections.HideHeader {
+		sb.WriteString(r.header(analysis))
+	}
 
 	// Summary section
-	sb.WriteString(formatSummary(analysis))
+	if !sections.HideSummary {
+		sb.WriteString(r.summary(analysis))
+	}
+
+	// Warnings section, so readers know which parts of the report to trust less
+	if !sections.HideWarnings && len(analysis.Warnings) > 0 {
+		sb.WriteString(r.warningsSection(analysis.Warnings, r.theme.Warning, r.theme.Reset, separator))
+	}
+
+	// Probable causes section
+	if !sections.HideRootCause {
+		sb.WriteString(r.probableCausesSection(analysis, r.theme.Heading, r.theme.Reset, separator))
+	}
+
+	// Explanation section
+	if r.opts.Explain {
+		sb.WriteString(r.explanationSection(analysis, r.theme.Heading, r.theme.Reset, separator))
+	}
 
 	// Errors section
-	if len(analysis.Errors) == 0 {
-		sb.WriteString("\nNo errors found in stack events.\n")
-	} else {
-		sb.WriteString(formatErrorsSection(analysis.Errors))
+	if !sections.HideErrors {
+		if len(analysis.Errors) == 0 {
+			sb.WriteString("\n" + r.msg.get(msgNoErrors) + "\n")
+		} else {
+			sb.WriteString(r.errorsSection(analysis.Errors))
+		}
+
+		if len(analysis.Ignored) > 0 {
+			sb.WriteString(r.ignoredSection(analysis.Ignored))
+		}
+	}
+
+	// Findings section
+	if !sections.HideFindings && len(analysis.Findings) > 0 {
+		sb.WriteString(r.findingsSection(analysis.Findings))
+	}
+
+	// Stack parameters and outputs section
+	if !sections.HideStackContext && analysis.StackContext != nil {
+		sb.WriteString(r.stackContextSection(analysis.StackContext, r.theme.Heading, r.theme.Reset, separator))
+	}
+
+	// Template changes section
+	if !sections.HideTemplateDiff && analysis.TemplateDiff != nil {
+		sb.WriteString(r.templateDiffSection(analysis.TemplateDiff, r.theme.Heading, r.theme.Reset, separator))
+	}
+
+	// AI summary section
+	if !sections.HideAISummary && analysis.AISummary != "" {
+		sb.WriteString(r.aiSummarySection(analysis.AISummary, r.theme.Heading, r.theme.Reset, separator))
 	}
 
 	return sb.String()
@@ -55,144 +244,709 @@ func FormatAnalysisResults(analysis *analyzer.StackAnalysis) string {
 // and includes CloudTrail details when available.
 // Requirements: 2.4, 5.1, 5.2
 func FormatError(err analyzer.CorrelatedError) string {
+	return newRenderer(Options{}).error(err)
+}
+
+// error formats an individual correlated error with colors
+func (r *renderer) error(err analyzer.CorrelatedError) string {
 	var sb strings.Builder
 
 	// CloudFormation error details
-	sb.WriteString(formatStackError(err.StackError))
+	sb.WriteString(r.stackError(err))
 
 	// CloudTrail details if available
-	if err.CloudTrailEvent != nil {
-		sb.WriteString(formatCloudTrailDetails(err.CloudTrailEvent))
+	if err.CloudTrailEvent != nil && !r.opts.Sections.HideCloudTrailDetails {
+		sb.WriteString(r.cloudTrailDetails(err))
 	}
 
 	// Detailed message (from CloudTrail or original)
 	if err.DetailedMessage != "" {
-		sb.WriteString(formatDetailedMessage(err.DetailedMessage, err.CloudTrailEvent != nil))
+		sb.WriteString(r.detailedMessage(err.DetailedMessage, err.CloudTrailEvent != nil))
 	}
 
 	return sb.String()
 }
 
-// formatHeader creates the report header with stack name and analysis time
-func formatHeader(analysis *analyzer.StackAnalysis) string {
+// header creates the report header with stack name and analysis time
+func (r *renderer) header(analysis *analyzer.StackAnalysis) string {
 	var sb strings.Builder
 
+	width := r.headerLabelWidth(analysis)
+
 	sb.WriteString("\n")
 	sb.WriteString(strings.Repeat(separator, separatorWidth))
 	sb.WriteString("\n")
-	sb.WriteString(fmt.Sprintf("%sCloudFormation Error Analysis Report%s\n", colorBold, colorReset))
+	sb.WriteString(fmt.Sprintf("%s%s%s\n", r.theme.Heading, r.msg.get(msgReportTitle), r.theme.Reset))
 	sb.WriteString(strings.Repeat(separator, separatorWidth))
 	sb.WriteString("\n\n")
 
-	sb.WriteString(fmt.Sprintf("Stack Name:    %s%s%s\n", colorCyan, analysis.StackName, colorReset))
-	sb.WriteString(fmt.Sprintf("Analysis Time: %s\n", formatTimestamp(analysis.AnalysisTime)))
+	sb.WriteString(fmt.Sprintf("%s%s%s%s\n", r.msg.label(msgStackName, width), r.theme.Highlight, analysis.StackName, r.theme.Reset))
+	sb.WriteString(fmt.Sprintf("%s%s\n", r.msg.label(msgAnalysisTime, width), formatTimestamp(analysis.AnalysisTime)))
+	sb.WriteString(r.stackStatus(analysis.StackStatus, width, r.theme.Error, r.theme.Reset))
+	sb.WriteString(r.identity(analysis.Identity, width))
+	sb.WriteString(r.provisionedProduct(analysis.ProvisionedProduct, width))
+	sb.WriteString(r.inProgressNotice(analysis.StackStatus, r.theme.Warning, r.theme.Reset))
+	sb.WriteString(r.partialNotice(analysis, r.theme.Error, r.theme.Reset))
+
+	return sb.String()
+}
+
+// rootCauseSection formats the paragraph on the most likely root cause, or returns "" if no
+// resource failed
+func (r *renderer) rootCauseSection(analysis *analyzer.StackAnalysis, heading, reset string) string {
+	text := rootCauseText(analysis)
+	if text == "" {
+		return ""
+	}
+	return fmt.Sprintf("\n%s%s%s\n  %s\n", heading, r.msg.get(msgRootCause), reset, r.wrap(text, indentWidth))
+}
+
+// rootCauseText describes the most likely root cause of the analysis in plain English, or returns
+// "" if no resource failed
+func rootCauseText(analysis *analyzer.StackAnalysis) string {
+	candidate := rootcause.MostLikely(analysis)
+	if candidate == nil {
+		return ""
+	}
+	return explain.Error(candidate.Error, candidate.Finding)
+}
+
+// partialNotice warns that an interrupted analysis is incomplete, or returns "" for complete analyses
+func (r *renderer) partialNotice(analysis *analyzer.StackAnalysis, color, reset string) string {
+	if !analysis.Partial {
+		return ""
+	}
+	return fmt.Sprintf("\n%s%s%s\n", color, r.msg.get(msgPartial), reset)
+}
+
+// inProgressNotice warns that the stack operation is still running, or returns "" if it has finished
+func (r *renderer) inProgressNotice(status *analyzer.StackStatus, color, reset string) string {
+	if status == nil || !status.InProgress {
+		return ""
+	}
+	return fmt.Sprintf("\n%s%s%s\n", color, r.msg.get(msgInProgress), reset)
+}
+
+// headerLabelWidth returns the label width of the header, including the stack status, identity
+// and provisioned product labels if present
+func (r *renderer) headerLabelWidth(analysis *analyzer.StackAnalysis) int {
+	keys := []string{msgStackName, msgAnalysisTime}
+	if analysis.StackStatus != nil {
+		keys = append(keys, msgStackStatus, msgStatusReason)
+	}
+	if analysis.Identity != nil {
+		keys = append(keys, msgAccount, msgRegion, msgPrincipal)
+	}
+	if analysis.ProvisionedProduct != nil {
+		keys = append(keys, msgProvisionedProduct, msgProduct, msgProvisioningArtifact, msgProductStatus, msgRecordError)
+	}
+	return r.msg.labelWidth(1, keys...)
+}
+
+// stackStatus formats the status of the stack and its reason, coloring failed and rolled back
+// statuses
+func (r *renderer) stackStatus(status *analyzer.StackStatus, width int, color, reset string) string {
+	if status == nil {
+		return ""
+	}
+
+	if !strings.Contains(status.Status, "FAILED") && !strings.Contains(status.Status, "ROLLBACK") {
+		color, reset = "", ""
+	}
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("%s%s%s%s\n", r.msg.label(msgStackStatus, width), color, status.Status, reset))
+	if status.Reason != "" {
+		sb.WriteString(fmt.Sprintf("%s%s\n", r.msg.label(msgStatusReason, width), r.wrap(status.Reason, width)))
+	}
 
 	return sb.String()
 }
 
-// formatSummary creates the summary section with error counts
-func formatSummary(analysis *analyzer.StackAnalysis) string {
+// identity formats the account, region and principal the analysis ran as
+func (r *renderer) identity(identity *analyzer.CallerIdentity, width int) string {
+	if identity == nil {
+		return ""
+	}
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("%s%s\n", r.msg.label(msgAccount, width), identity.AccountID))
+	sb.WriteString(fmt.Sprintf("%s%s\n", r.msg.label(msgRegion, width), identity.Region))
+	sb.WriteString(fmt.Sprintf("%s%s\n", r.msg.label(msgPrincipal, width), identity.Principal))
+
+	return sb.String()
+}
+
+// provisionedProduct formats the Service Catalog provisioned product the stack belongs to
+func (r *renderer) provisionedProduct(product *analyzer.ProvisionedProduct, width int) string {
+	if product == nil {
+		return ""
+	}
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("%s%s\n", r.msg.label(msgProvisionedProduct, width), nameAndId(product.Name, product.Id)))
+	sb.WriteString(fmt.Sprintf("%s%s\n", r.msg.label(msgProduct, width), nameAndId(product.ProductName, product.ProductId)))
+	sb.WriteString(fmt.Sprintf("%s%s\n", r.msg.label(msgProvisioningArtifact, width),
+		nameAndId(product.ProvisioningArtifactName, product.ProvisioningArtifactId)))
+	status := product.Status
+	if product.StatusMessage != "" {
+		status += " - " + product.StatusMessage
+	}
+	sb.WriteString(fmt.Sprintf("%s%s\n", r.msg.label(msgProductStatus, width), status))
+	for _, recordErr := range product.RecordErrors {
+		sb.WriteString(fmt.Sprintf("%s%s\n", r.msg.label(msgRecordError, width), recordErr))
+	}
+
+	return sb.String()
+}
+
+// nameAndId formats a name with its ID, e.g. "web-app (prod-abc123)", or the ID alone if the name is unknown
+func nameAndId(name, id string) string {
+	if name == "" || name == id {
+		return id
+	}
+	return fmt.Sprintf("%s (%s)", name, id)
+}
+
+// summary creates the summary section with error counts
+func (r *renderer) summary(analysis *analyzer.StackAnalysis) string {
 	var sb strings.Builder
 
 	sb.WriteString("\n")
-	sb.WriteString(fmt.Sprintf("%sSummary%s\n", colorBold, colorReset))
+	sb.WriteString(fmt.Sprintf("%s%s%s\n", r.theme.Heading, r.msg.get(msgSummary), r.theme.Reset))
 	sb.WriteString(strings.Repeat(separator, 40))
 	sb.WriteString("\n")
+	sb.WriteString(r.summaryCounts(analysis))
+	sb.WriteString(r.serviceCounts(analysis))
+	sb.WriteString(r.safeToRetry(analysis, "✓ ", r.theme.Highlight, r.theme.Reset))
+
+	return sb.String()
+}
+
+// summaryCounts formats the error counts of the summary section
+func (r *renderer) summaryCounts(analysis *analyzer.StackAnalysis) string {
+	var sb strings.Builder
+
+	keys := []string{msgTotalErrors, msgGeneralServiceExceptions, msgWithCloudTrail}
+	if len(analysis.Ignored) > 0 {
+		keys = append(keys, msgIgnored)
+	}
+	if analysis.Health != nil {
+		keys = append(keys, msgHealth)
+	}
+	width := r.msg.labelWidth(2, keys...)
 
 	totalErrors := len(analysis.Errors)
-	sb.WriteString(fmt.Sprintf("Total Errors:              %d\n", totalErrors))
-	sb.WriteString(fmt.Sprintf("GeneralServiceExceptions:  %d\n", analysis.GeneralErrors))
-	sb.WriteString(fmt.Sprintf("With CloudTrail Details:   %d\n", analysis.DetailedErrors))
+	sb.WriteString(fmt.Sprintf("%s%d\n", r.msg.label(msgTotalErrors, width), totalErrors))
+	sb.WriteString(fmt.Sprintf("%s%d\n", r.msg.label(msgGeneralServiceExceptions, width), analysis.GeneralErrors))
+	sb.WriteString(fmt.Sprintf("%s%d\n", r.msg.label(msgWithCloudTrail, width), analysis.DetailedErrors))
+	if len(analysis.Ignored) > 0 {
+		sb.WriteString(fmt.Sprintf("%s%d\n", r.msg.label(msgIgnored, width), len(analysis.Ignored)))
+	}
+	if health := analysis.Health; health != nil {
+		sb.WriteString(fmt.Sprintf("%s%d/100\n", r.msg.label(msgHealth, width), health.Score))
+		sb.WriteString(fmt.Sprintf("%s%s\n", strings.Repeat(" ", width), r.wrap(r.healthDetails(health), width)))
+	}
 
 	return sb.String()
 }
 
-// formatErrorsSection formats all errors in the analysis
-func formatErrorsSection(errors []analyzer.CorrelatedError) string {
+// healthDetails describes the stack events behind the health score of an operation
+func (r *renderer) healthDetails(health *analyzer.OperationHealth) string {
+	details := r.msg.format(msgHealthDetails, health.Events, health.Failures, health.Retries)
+	if health.Rollback {
+		details += ", " + r.msg.get(msgRolledBack)
+	}
+	if health.LongestResource != "" {
+		details += ", " + r.msg.format(msgLongestResource, health.LongestResource, health.LongestDuration.Round(time.Second))
+	}
+	return details
+}
+
+// errorsSection formats all errors in the analysis
+func (r *renderer) errorsSection(errors []analyzer.CorrelatedError) string {
 	var sb strings.Builder
 
 	sb.WriteString("\n")
-	sb.WriteString(fmt.Sprintf("%sErrors%s\n", colorBold, colorReset))
+	sb.WriteString(fmt.Sprintf("%s%s%s\n", r.theme.Heading, r.msg.get(msgErrors), r.theme.Reset))
 	sb.WriteString(strings.Repeat(separator, separatorWidth))
 	sb.WriteString("\n")
 
 	for i, err := range errors {
-		sb.WriteString(fmt.Sprintf("\n%s[Error %d]%s\n", colorRed, i+1, colorReset))
-		sb.WriteString(FormatError(err))
+		sb.WriteString(fmt.Sprintf("\n%s%s%s\n", r.theme.Error, r.msg.format(msgErrorHeading, i+1), r.theme.Reset))
+		sb.WriteString(r.error(err))
+	}
+
+	return sb.String()
+}
+
+// ignoredSection formats the errors excluded by ignore rules
+func (r *renderer) ignoredSection(ignored []analyzer.IgnoredError) string {
+	var sb strings.Builder
+
+	sb.WriteString("\n")
+	sb.WriteString(fmt.Sprintf("%s%s%s\n", r.theme.Heading, r.msg.get(msgIgnoredErrors), r.theme.Reset))
+	sb.WriteString(strings.Repeat(separator, separatorWidth))
+	sb.WriteString("\n\n")
+
+	for _, item := range ignored {
+		sb.WriteString(r.ignoredError(item))
+	}
+
+	return sb.String()
+}
+
+// ignoredError formats an ignored error as a single list entry
+func (r *renderer) ignoredError(item analyzer.IgnoredError) string {
+	line := fmt.Sprintf("  - %s %s: %s", item.Error.StackError.LogicalResourceId,
+		item.Error.StackError.ResourceStatus, errorDescription(item.Error))
+	if item.Comment != "" {
+		line += fmt.Sprintf(" (%s)", item.Comment)
+	}
+	return r.wrap(line, 4) + "\n"
+}
+
+// templateDiffSection formats the changes between the previously deployed and the failed template.
+// In the text format removed lines are colored as errors and added lines highlighted.
+func (r *renderer) templateDiffSection(diff *analyzer.TemplateDiff, heading, reset, rule string) string {
+	var sb strings.Builder
+
+	sb.WriteString("\n")
+	sb.WriteString(fmt.Sprintf("%s%s%s\n", heading, r.msg.get(msgTemplateChanges), reset))
+	sb.WriteString(strings.Repeat(rule, separatorWidth))
+	sb.WriteString("\n\n")
+
+	if diff.ChangeSetId != "" {
+		sb.WriteString(fmt.Sprintf("%s%s\n\n", r.msg.label(msgChangeSet, r.msg.labelWidth(1, msgChangeSet)), diff.ChangeSetId))
+	}
+
+	if diff.Diff == "" {
+		sb.WriteString(r.msg.get(msgTemplateUnchanged) + "\n")
+		return sb.String()
+	}
+
+	for _, line := range strings.Split(strings.TrimSuffix(diff.Diff, "\n"), "\n") {
+		if reset != "" {
+			switch {
+			case strings.HasPrefix(line, "-"):
+				line = r.theme.Error + line + reset
+			case strings.HasPrefix(line, "+"):
+				line = r.theme.Highlight + line + reset
+			}
+		}
+		sb.WriteString("  " + line + "\n")
+	}
+
+	return sb.String()
+}
+
+// stackContextSection formats the parameters and outputs of the stack, one per line aligned by key.
+// NoEcho parameter values are never shown.
+func (r *renderer) stackContextSection(stackContext *analyzer.StackContext, heading, reset, rule string) string {
+	var sb strings.Builder
+
+	sb.WriteString("\n")
+	sb.WriteString(fmt.Sprintf("%s%s%s\n", heading, r.msg.get(msgStackContext), reset))
+	sb.WriteString(strings.Repeat(rule, separatorWidth))
+	sb.WriteString("\n\n")
+
+	width := 0
+	for _, parameter := range stackContext.Parameters {
+		width = max(width, utf8.RuneCountInString(parameter.Key))
+	}
+	for _, output := range stackContext.Outputs {
+		width = max(width, utf8.RuneCountInString(output.Key))
+	}
+	indent := strings.Repeat(" ", indentWidth)
+
+	sb.WriteString(fmt.Sprintf("%s:\n", r.msg.get(msgParameters)))
+	if len(stackContext.Parameters) == 0 {
+		sb.WriteString(fmt.Sprintf("%s%s\n", indent, r.msg.get(msgNone)))
+	}
+	for _, parameter := range stackContext.Parameters {
+		value := parameter.Value
+		switch {
+		case parameter.NoEcho:
+			value = r.msg.get(msgNoEchoValue)
+		case parameter.ResolvedValue != "":
+			value += " (" + r.msg.format(msgResolvedValue, parameter.ResolvedValue) + ")"
+		}
+		sb.WriteString(fmt.Sprintf("%s%-*s  %s\n", indent, width, parameter.Key, value))
+	}
+
+	sb.WriteString(fmt.Sprintf("\n%s:\n", r.msg.get(msgOutputs)))
+	if len(stackContext.Outputs) == 0 {
+		sb.WriteString(fmt.Sprintf("%s%s\n", indent, r.msg.get(msgNone)))
+	}
+	for _, output := range stackContext.Outputs {
+		value := output.Value
+		if output.ExportName != "" {
+			value += " (" + r.msg.format(msgExportName, output.ExportName) + ")"
+		}
+		sb.WriteString(fmt.Sprintf("%s%-*s  %s\n", indent, width, output.Key, value))
+	}
+
+	return sb.String()
+}
+
+// aiSummarySection formats the summary written by the AI provider
+func (r *renderer) aiSummarySection(summary, heading, reset, rule string) string {
+	var sb strings.Builder
+
+	sb.WriteString("\n")
+	sb.WriteString(fmt.Sprintf("%s%s%s\n", heading, r.msg.get(msgAISummary), reset))
+	sb.WriteString(strings.Repeat(rule, separatorWidth))
+	sb.WriteString("\n\n")
+
+	for _, paragraph := range strings.Split(strings.TrimSpace(summary), "\n") {
+		sb.WriteString("  " + r.wrap(paragraph, indentWidth) + "\n")
+	}
+
+	return sb.String()
+}
+
+// warningsSection formats the conditions that make the analysis less reliable, one per line
+func (r *renderer) warningsSection(warnings []analyzer.Warning, heading, reset, rule string) string {
+	var sb strings.Builder
+
+	sb.WriteString("\n")
+	sb.WriteString(fmt.Sprintf("%s%s%s\n", heading, r.msg.get(msgWarnings), reset))
+	sb.WriteString(strings.Repeat(rule, separatorWidth))
+	sb.WriteString("\n\n")
+
+	for _, warning := range warnings {
+		sb.WriteString("  - " + r.wrap(warning.Message, indentWidth+2) + "\n")
+	}
+
+	return sb.String()
+}
+
+// probableCausesSection formats the failed resources ranked by how likely they caused the failure,
+// with the evidence of each. It returns "" unless several root causes are plausible.
+func (r *renderer) probableCausesSection(analysis *analyzer.StackAnalysis, heading, reset, rule string) string {
+	candidates := rootcause.Rank(analysis)
+	if len(candidates) < 2 {
+		return ""
+	}
+
+	var sb strings.Builder
+
+	sb.WriteString("\n")
+	sb.WriteString(fmt.Sprintf("%s%s%s\n", heading, r.msg.get(msgProbableCauses), reset))
+	sb.WriteString(strings.Repeat(rule, separatorWidth))
+	sb.WriteString("\n\n")
+
+	for i, candidate := range candidates {
+		stackErr := candidate.Error.StackError
+		sb.WriteString(fmt.Sprintf("  %s\n", r.msg.format(msgProbableCauseHeading, i+1, stackErr.LogicalResourceId,
+			stackErr.ResourceType, stackErr.ResourceStatus, formatTimestamp(stackErr.Timestamp), candidate.Score)))
+		for _, reason := range candidate.Reasons {
+			sb.WriteString(fmt.Sprintf("     - %s\n", reason))
+		}
+	}
+
+	return sb.String()
+}
+
+// explanationSection formats the plain-English narratives of the root causes, or returns "" if
+// there are none
+func (r *renderer) explanationSection(analysis *analyzer.StackAnalysis, heading, reset, rule string) string {
+	explanations := explain.Explain(analysis)
+	if len(explanations) == 0 {
+		return ""
+	}
+
+	var sb strings.Builder
+
+	sb.WriteString("\n")
+	sb.WriteString(fmt.Sprintf("%s%s%s\n", heading, r.msg.get(msgExplanation), reset))
+	sb.WriteString(strings.Repeat(rule, separatorWidth))
+	sb.WriteString("\n")
+
+	for _, explanation := range explanations {
+		sb.WriteString("\n  " + r.wrap(explanation.Text, indentWidth) + "\n")
 	}
 
 	return sb.String()
 }
 
-// formatStackError formats the CloudFormation stack error details
+// findingsSection formats the recognized failure patterns
+func (r *renderer) findingsSection(findings []analyzer.Finding) string {
+	var sb strings.Builder
+
+	sb.WriteString("\n")
+	sb.WriteString(fmt.Sprintf("%s%s%s\n", r.theme.Heading, r.msg.get(msgFindings), r.theme.Reset))
+	sb.WriteString(strings.Repeat(separator, separatorWidth))
+	sb.WriteString("\n")
+
+	for i, finding := range findings {
+		sb.WriteString(fmt.Sprintf("\n%s%s %s%s\n", r.theme.Warning, r.msg.format(msgFindingHeading, i+1), finding.Title, r.theme.Reset))
+		sb.WriteString(r.finding(finding))
+	}
+
+	return sb.String()
+}
+
+// FormatFinding formats an individual finding with its explanation, evidence and suggestion
+func FormatFinding(finding analyzer.Finding) string {
+	return newRenderer(Options{}).finding(finding)
+}
+
+// finding formats an individual finding
+func (r *renderer) finding(finding analyzer.Finding) string {
+	var sb strings.Builder
+
+	indent := strings.Repeat(" ", indentWidth)
+	innerIndent := strings.Repeat(" ", indentWidth*2)
+
+	if finding.LogicalResourceId != "" {
+		width := r.msg.labelWidth(1, stackErrorLabels...)
+		sb.WriteString(fmt.Sprintf("%s%s%s\n", indent, r.msg.label(msgResource, width), finding.LogicalResourceId))
+	}
+	sb.WriteString(fmt.Sprintf("%s%s\n", indent, r.wrap(finding.Explanation, indentWidth)))
+
+	if len(finding.Evidence) > 0 {
+		sb.WriteString(fmt.Sprintf("\n%s%s:\n", indent, r.msg.get(msgEvidence)))
+		for _, evidence := range finding.Evidence {
+			sb.WriteString(fmt.Sprintf("%s- %s\n", innerIndent, r.wrap(evidence, indentWidth*2+2)))
+		}
+	}
+
+	if finding.Suggestion != "" {
+		sb.WriteString(fmt.Sprintf("\n%s%s:\n", indent, r.msg.get(msgSuggestion)))
+		sb.WriteString(fmt.Sprintf("%s%s\n", innerIndent, r.wrap(finding.Suggestion, indentWidth*2)))
+	}
+
+	return sb.String()
+}
+
+// resourceFindings formats the findings that belong to the given logical resource
+func (r *renderer) resourceFindings(findings []analyzer.Finding, logicalResourceId string) string {
+	var sb strings.Builder
+
+	for _, finding := range findings {
+		if finding.LogicalResourceId != logicalResourceId {
+			continue
+		}
+		sb.WriteString(fmt.Sprintf("\n%s\n", finding.Title))
+		sb.WriteString(r.finding(finding))
+	}
+
+	return sb.String()
+}
+
+// stackErrorLabels are the labels of the stack error block, used to align its values
+var stackErrorLabels = []string{msgTimestamp, msgResource, msgResourceType, msgStatus, msgReason, msgRequestID, msgRetryable}
+
+// cloudTrailLabels are the labels of the CloudTrail details block, used to align its values
+var cloudTrailLabels = []string{msgEventTime, msgEventName, msgEventSource, msgEventID, msgRequestID, msgErrorCode, msgErrorMsg, msgTimeWindow, msgRetries, msgEvidence}
+
+// stackError formats the CloudFormation stack error details, highlighting the request ID
+// of the failed API call when known, since AWS Support asks for it
 // Requirements: 2.4, 5.1
-func formatStackError(err analyzer.StackError) string {
+func (r *renderer) stackError(correlated analyzer.CorrelatedError) string {
 	var sb strings.Builder
 
+	err := correlated.StackError
+
 	indent := strings.Repeat(" ", indentWidth)
+	width := r.msg.labelWidth(1, stackErrorLabels...)
 
-	sb.WriteString(fmt.Sprintf("%sTimestamp:     %s\n", indent, formatTimestamp(err.Timestamp)))
-	sb.WriteString(fmt.Sprintf("%sResource:      %s%s%s\n", indent, colorCyan, err.LogicalResourceId, colorReset))
-	sb.WriteString(fmt.Sprintf("%sResource Type: %s\n", indent, err.ResourceType))
-	sb.WriteString(fmt.Sprintf("%sStatus:        %s%s%s\n", indent, colorRed, err.ResourceStatus, colorReset))
+	sb.WriteString(fmt.Sprintf("%s%s%s\n", indent, r.msg.label(msgTimestamp, width), formatTimestamp(err.Timestamp)))
+	sb.WriteString(fmt.Sprintf("%s%s%s%s%s\n", indent, r.msg.label(msgResource, width), r.theme.Highlight, err.LogicalResourceId, r.theme.Reset))
+	sb.WriteString(fmt.Sprintf("%s%s%s\n", indent, r.msg.label(msgResourceType, width), err.ResourceType))
+	sb.WriteString(fmt.Sprintf("%s%s%s%s%s\n", indent, r.msg.label(msgStatus, width), r.theme.Error, err.ResourceStatus, r.theme.Reset))
 
 	if err.ResourceStatusReason != "" {
-		sb.WriteString(fmt.Sprintf("%sReason:        %s\n", indent, err.ResourceStatusReason))
+		sb.WriteString(fmt.Sprintf("%s%s%s\n", indent, r.msg.label(msgReason, width), r.wrap(err.ResourceStatusReason, indentWidth+width)))
+	}
+
+	if requestID := classify.RequestID(correlated); requestID != "" {
+		sb.WriteString(fmt.Sprintf("%s%s%s%s%s\n", indent, r.msg.label(msgRequestID, width), r.theme.Highlight, requestID, r.theme.Reset))
+	}
+
+	if classify.Retryable(correlated) {
+		sb.WriteString(fmt.Sprintf("%s%s%s\n", indent, r.msg.label(msgRetryable, width), r.msg.get(msgRetryableYes)))
 	}
 
 	if err.IsGeneralServiceException {
-		sb.WriteString(fmt.Sprintf("%s%s⚠ GeneralServiceException - CloudTrail investigation required%s\n",
-			indent, colorYellow, colorReset))
+		sb.WriteString(fmt.Sprintf("%s%s⚠ %s%s\n",
+			indent, r.theme.Warning, r.msg.get(msgGeneralServiceExceptionHint), r.theme.Reset))
 	}
 
 	return sb.String()
 }
 
-// formatCloudTrailDetails formats the CloudTrail event details
+// serviceCounts lists the error counts per service when the errors involve more than one service
+func (r *renderer) serviceCounts(analysis *analyzer.StackAnalysis) string {
+	services := aggregate.ByService(analysis.Errors)
+	if len(services) < 2 {
+		return ""
+	}
+	return "\n" + r.msg.get(msgErrorsByService) + ":\n" + countTable(services)
+}
+
+// safeToRetry returns the retry guidance of the summary if all root causes are transient
+func (r *renderer) safeToRetry(analysis *analyzer.StackAnalysis, marker, color, reset string) string {
+	if !classify.SafeToRetry(analysis) {
+		return ""
+	}
+	return fmt.Sprintf("\n%s%s%s%s\n", color, marker, r.msg.get(msgSafeToRetry), reset)
+}
+
+// cloudTrailDetails formats the CloudTrail event details
 // Requirements: 5.2
-func formatCloudTrailDetails(event *analyzer.CloudTrailEvent) string {
+func (r *renderer) cloudTrailDetails(err analyzer.CorrelatedError) string {
 	var sb strings.Builder
+	event := err.CloudTrailEvent
 
 	indent := strings.Repeat(" ", indentWidth)
 
-	sb.WriteString(fmt.Sprintf("\n%s%sCloudTrail Details:%s\n", indent, colorBold, colorReset))
+	sb.WriteString(fmt.Sprintf("\n%s%s%s:%s\n", indent, r.theme.Heading, r.msg.get(msgCloudTrailDetails), r.theme.Reset))
 
 	innerIndent := strings.Repeat(" ", indentWidth*2)
+	width := r.msg.labelWidth(1, cloudTrailLabels...)
+
+	sb.WriteString(fmt.Sprintf("%s%s%s\n", innerIndent, r.msg.label(msgEventTime, width), formatTimestamp(event.EventTime)))
+	sb.WriteString(fmt.Sprintf("%s%s%s\n", innerIndent, r.msg.label(msgEventName, width), event.EventName))
+	sb.WriteString(fmt.Sprintf("%s%s%s\n", innerIndent, r.msg.label(msgEventSource, width), event.EventSource))
 
-	sb.WriteString(fmt.Sprintf("%sEvent Time:   %s\n", innerIndent, formatTimestamp(event.EventTime)))
-	sb.WriteString(fmt.Sprintf("%sEvent Name:   %s\n", innerIndent, event.EventName))
-	sb.WriteString(fmt.Sprintf("%sEvent Source: %s\n", innerIndent, event.EventSource))
+	if event.EventID != "" {
+		sb.WriteString(fmt.Sprintf("%s%s%s\n", innerIndent, r.msg.label(msgEventID, width), event.EventID))
+	}
+
+	if event.RequestID != "" {
+		sb.WriteString(fmt.Sprintf("%s%s%s\n", innerIndent, r.msg.label(msgRequestID, width), event.RequestID))
+	}
 
 	if event.ErrorCode != "" {
-		sb.WriteString(fmt.Sprintf("%sError Code:   %s%s%s\n", innerIndent, colorRed, event.ErrorCode, colorReset))
+		sb.WriteString(fmt.Sprintf("%s%s%s%s%s\n", innerIndent, r.msg.label(msgErrorCode, width), r.theme.Error, event.ErrorCode, r.theme.Reset))
 	}
 
 	if event.ErrorMessage != "" {
-		sb.WriteString(fmt.Sprintf("%sError Msg:    %s\n", innerIndent, event.ErrorMessage))
+		sb.WriteString(fmt.Sprintf("%s%s%s\n", innerIndent, r.msg.label(msgErrorMsg, width), r.wrap(event.ErrorMessage, indentWidth*2+width)))
 	}
 
+	if widened := r.widenedWindow(err.CorrelationWindow); widened != "" {
+		sb.WriteString(fmt.Sprintf("%s%s%s\n", innerIndent, r.msg.label(msgTimeWindow, width), widened))
+	}
+
+	if err.Retries != nil {
+		sb.WriteString(fmt.Sprintf("%s%s%s\n", innerIndent, r.msg.label(msgRetries, width), r.retries(err.Retries)))
+	}
+
+	sb.WriteString(fmt.Sprintf("%s%s%s\n", innerIndent, r.msg.label(msgEvidence, width), r.wrap(r.evidence(err), indentWidth*2+width)))
+
 	return sb.String()
 }
 
-// formatDetailedMessage formats the detailed error message
-func formatDetailedMessage(message string, hasCloudTrail bool) string {
+// evidence describes the signals tying the CloudTrail event of a correlated error to the error
+func (r *renderer) evidence(err analyzer.CorrelatedError) string {
+	signals := correlator.Evidence(err)
+	descriptions := make([]string, 0, len(signals))
+	for _, signal := range signals {
+		switch signal.Kind {
+		case correlator.SignalPhysicalID:
+			descriptions = append(descriptions, fmt.Sprintf(r.msg.get(msgSignalPhysicalID), signal.Value))
+		case correlator.SignalRequestID:
+			descriptions = append(descriptions, fmt.Sprintf(r.msg.get(msgSignalRequestID), signal.Value))
+		case correlator.SignalResourceID:
+			descriptions = append(descriptions, fmt.Sprintf(r.msg.get(msgSignalResourceID), signal.Value))
+		case correlator.SignalService:
+			descriptions = append(descriptions, fmt.Sprintf(r.msg.get(msgSignalService), signal.Value))
+		case correlator.SignalTime:
+			if signal.Offset < 0 {
+				descriptions = append(descriptions, fmt.Sprintf(r.msg.get(msgSignalBefore), (-signal.Offset).Round(time.Second)))
+			} else {
+				descriptions = append(descriptions, fmt.Sprintf(r.msg.get(msgSignalAfter), signal.Offset.Round(time.Second)))
+			}
+		}
+	}
+	return strings.Join(descriptions, "; ")
+}
+
+// widenedWindow describes the time window a CloudTrail event matched in if the correlation had
+// to widen it beyond the default window, "" otherwise
+func (r *renderer) widenedWindow(window time.Duration) string {
+	if window <= correlator.DefaultTimeWindow {
+		return ""
+	}
+	return fmt.Sprintf(r.msg.get(msgTimeWindowWidened), formatWindow(window), formatWindow(correlator.DefaultTimeWindow))
+}
+
+// retries describes a call the resource provider retried with the same error
+func (r *renderer) retries(retries *analyzer.RetrySummary) string {
+	return fmt.Sprintf(r.msg.get(msgRetriesValue), retries.Count, formatTimestamp(retries.First), formatTimestamp(retries.Last))
+}
+
+// formatWindow formats a correlation time window in minutes, e.g. ±20m
+func formatWindow(window time.Duration) string {
+	return fmt.Sprintf("±%dm", int(window.Minutes()))
+}
+
+// detailedMessage formats the detailed error message
+func (r *renderer) detailedMessage(message string, hasCloudTrail bool) string {
 	var sb strings.Builder
 
 	indent := strings.Repeat(" ", indentWidth)
 
 	sb.WriteString("\n")
 	if hasCloudTrail {
-		sb.WriteString(fmt.Sprintf("%s%sDetailed Message (from CloudTrail):%s\n", indent, colorBold, colorReset))
+		sb.WriteString(fmt.Sprintf("%s%s%s:%s\n", indent, r.theme.Heading, r.msg.get(msgDetailedMessageCloudTrail), r.theme.Reset))
 	} else {
-		sb.WriteString(fmt.Sprintf("%s%sDetailed Message:%s\n", indent, colorBold, colorReset))
+		sb.WriteString(fmt.Sprintf("%s%s%s:%s\n", indent, r.theme.Heading, r.msg.get(msgDetailedMessage), r.theme.Reset))
 	}
 
 	innerIndent := strings.Repeat(" ", indentWidth*2)
-	sb.WriteString(fmt.Sprintf("%s%s\n", innerIndent, message))
+	sb.WriteString(fmt.Sprintf("%s%s\n", innerIndent, r.wrap(message, indentWidth*2)))
 
 	return sb.String()
 }
 
+// FormatStats formats the performance statistics footer
+func FormatStats(stats *analyzer.AnalysisStats) string {
+	return newRenderer(Options{}).stats(stats)
+}
+
+// stats formats the performance statistics footer
+func (r *renderer) stats(stats *analyzer.AnalysisStats) string {
+	var sb strings.Builder
+
+	indent := strings.Repeat(" ", indentWidth)
+
+	labels := []string{msgTotal, msgStackEventsScanned, msgCloudTrailCalls, msgCloudTrailEventsParsed}
+	width := r.msg.labelWidth(1, labels...)
+	for _, phase := range stats.Phases {
+		if w := utf8.RuneCountInString(r.msg.phase(phase.Name)) + 2; w > width {
+			width = w
+		}
+	}
+
+	sb.WriteString("\n")
+	sb.WriteString(r.msg.get(msgPerformanceStatistics) + "\n")
+	sb.WriteString(strings.Repeat("-", 40))
+	sb.WriteString("\n")
+
+	for _, phase := range stats.Phases {
+		sb.WriteString(fmt.Sprintf("%s%s%s\n", indent, pad(r.msg.phase(phase.Name)+":", width), formatDuration(phase.Duration)))
+	}
+	sb.WriteString(fmt.Sprintf("%s%s%s\n", indent, r.msg.label(msgTotal, width), formatDuration(stats.TotalDuration())))
+	sb.WriteString("\n")
+	sb.WriteString(fmt.Sprintf("%s%s%d\n", indent, r.msg.label(msgStackEventsScanned, width), stats.StackEventsScanned))
+	sb.WriteString(fmt.Sprintf("%s%s%d\n", indent, r.msg.label(msgCloudTrailCalls, width), stats.CloudTrailCalls))
+	sb.WriteString(fmt.Sprintf("%s%s%d\n", indent, r.msg.label(msgCloudTrailEventsParsed, width), stats.CloudTrailEventsParsed))
+
+	return sb.String()
+}
+
+// formatDuration formats a phase duration with millisecond precision
+func formatDuration(d time.Duration) string {
+	if d < time.Millisecond {
+		return d.Round(time.Microsecond).String()
+	}
+	return d.Round(time.Millisecond).String()
+}
+
 // formatTimestamp formats a time.Time for display
 func formatTimestamp(t time.Time) string {
 	if t.IsZero() {
@@ -204,98 +958,205 @@ func formatTimestamp(t time.Time) string {
 // FormatPlainText formats analysis results without ANSI color codes
 // Useful for file output or non-terminal environments
 func FormatPlainText(analysis *analyzer.StackAnalysis) string {
+	return newRenderer(Options{}).plainText(analysis)
+}
+
+// plainText formats the complete report without ANSI color codes
+func (r *renderer) plainText(analysis *analyzer.StackAnalysis) string {
 	if analysis == nil {
-		return "No analysis results available."
+		return r.msg.get(msgNoResults)
 	}
 
 	var sb strings.Builder
+	sections := r.opts.Sections
+
+	// Most likely root cause
+	if !sections.HideRootCause {
+		sb.WriteString(r.rootCauseSection(analysis, "", ""))
+	}
 
 	// Header
-	sb.WriteString("\n")
-	sb.WriteString(strings.Repeat("=", separatorWidth))
-	sb.WriteString("\n")
-	sb.WriteString("CloudFormation Error Analysis Report\n")
-	sb.WriteString(strings.Repeat("=", separatorWidth))
-	sb.WriteString("\n\n")
+	if !sections.HideHeader {
+		width := r.headerLabelWidth(analysis)
 
-	sb.WriteString(fmt.Sprintf("Stack Name:    %s\n", analysis.StackName))
-	sb.WriteString(fmt.Sprintf("Analysis Time: %s\n", formatTimestamp(analysis.AnalysisTime)))
+		sb.WriteString("\n")
+		sb.WriteString(strings.Repeat("=", separatorWidth))
+		sb.WriteString("\n")
+		sb.WriteString(r.msg.get(msgReportTitle) + "\n")
+		sb.WriteString(strings.Repeat("=", separatorWidth))
+		sb.WriteString("\n\n")
+
+		sb.WriteString(fmt.Sprintf("%s%s\n", r.msg.label(msgStackName, width), analysis.StackName))
+		sb.WriteString(fmt.Sprintf("%s%s\n", r.msg.label(msgAnalysisTime, width), formatTimestamp(analysis.AnalysisTime)))
+		sb.WriteString(r.stackStatus(analysis.StackStatus, width, "", ""))
+		sb.WriteString(r.identity(analysis.Identity, width))
+		sb.WriteString(r.provisionedProduct(analysis.ProvisionedProduct, width))
+		sb.WriteString(r.inProgressNotice(analysis.StackStatus, "", ""))
+		sb.WriteString(r.partialNotice(analysis, "", ""))
+	}
 
 	// Summary
-	sb.WriteString("\nSummary\n")
-	sb.WriteString(strings.Repeat("-", 40))
-	sb.WriteString("\n")
+	if !sections.HideSummary {
+		sb.WriteString("\n" + r.msg.get(msgSummary) + "\n")
+		sb.WriteString(strings.Repeat("-", 40))
+		sb.WriteString("\n")
+		sb.WriteString(r.summaryCounts(analysis))
+		sb.WriteString(r.serviceCounts(analysis))
+		sb.WriteString(r.safeToRetry(analysis, "", "", ""))
+	}
 
-	totalErrors := len(analysis.Errors)
-	sb.WriteString(fmt.Sprintf("Total Errors:              %d\n", totalErrors))
-	sb.WriteString(fmt.Sprintf("GeneralServiceExceptions:  %d\n", analysis.GeneralErrors))
-	sb.WriteString(fmt.Sprintf("With CloudTrail Details:   %d\n", analysis.DetailedErrors))
+	// Warnings
+	if !sections.HideWarnings && len(analysis.Warnings) > 0 {
+		sb.WriteString(r.warningsSection(analysis.Warnings, "", "", "="))
+	}
+
+	// Probable causes
+	if !sections.HideRootCause {
+		sb.WriteString(r.probableCausesSection(analysis, "", "", "="))
+	}
+
+	// Explanation
+	if r.opts.Explain {
+		sb.WriteString(r.explanationSection(analysis, "", "", "="))
+	}
 
 	// Errors
-	if len(analysis.Errors) == 0 {
-		sb.WriteString("\nNo errors found in stack events.\n")
-	} else {
-		sb.WriteString("\nErrors\n")
+	if !sections.HideErrors {
+		if len(analysis.Errors) == 0 {
+			sb.WriteString("\n" + r.msg.get(msgNoErrors) + "\n")
+		} else {
+			sb.WriteString("\n" + r.msg.get(msgErrors) + "\n")
+			sb.WriteString(strings.Repeat("=", separatorWidth))
+			sb.WriteString("\n")
+
+			for i, err := range analysis.Errors {
+				sb.WriteString(fmt.Sprintf("\n%s\n", r.msg.format(msgErrorHeading, i+1)))
+				sb.WriteString(r.errorPlainText(err))
+			}
+		}
+
+		if len(analysis.Ignored) > 0 {
+			sb.WriteString("\n" + r.msg.get(msgIgnoredErrors) + "\n")
+			sb.WriteString(strings.Repeat("=", separatorWidth))
+			sb.WriteString("\n\n")
+
+			for _, item := range analysis.Ignored {
+				sb.WriteString(r.ignoredError(item))
+			}
+		}
+	}
+
+	// Findings
+	if !sections.HideFindings && len(analysis.Findings) > 0 {
+		sb.WriteString("\n" + r.msg.get(msgFindings) + "\n")
 		sb.WriteString(strings.Repeat("=", separatorWidth))
 		sb.WriteString("\n")
 
-		for i, err := range analysis.Errors {
-			sb.WriteString(fmt.Sprintf("\n[Error %d]\n", i+1))
-			sb.WriteString(FormatErrorPlainText(err))
+		for i, finding := range analysis.Findings {
+			sb.WriteString(fmt.Sprintf("\n%s %s\n", r.msg.format(msgFindingHeading, i+1), finding.Title))
+			sb.WriteString(r.finding(finding))
 		}
 	}
 
+	// Stack parameters and outputs
+	if !sections.HideStackContext && analysis.StackContext != nil {
+		sb.WriteString(r.stackContextSection(analysis.StackContext, "", "", "="))
+	}
+
+	// Template changes
+	if !sections.HideTemplateDiff && analysis.TemplateDiff != nil {
+		sb.WriteString(r.templateDiffSection(analysis.TemplateDiff, "", "", "="))
+	}
+
+	// AI summary
+	if !sections.HideAISummary && analysis.AISummary != "" {
+		sb.WriteString(r.aiSummarySection(analysis.AISummary, "", "", "="))
+	}
+
 	return sb.String()
 }
 
 // FormatErrorPlainText formats an individual error without ANSI color codes
 func FormatErrorPlainText(err analyzer.CorrelatedError) string {
+	return newRenderer(Options{}).errorPlainText(err)
+}
+
+// errorPlainText formats an individual error without ANSI color codes
+func (r *renderer) errorPlainText(err analyzer.CorrelatedError) string {
 	var sb strings.Builder
 
 	indent := strings.Repeat(" ", indentWidth)
+	width := r.msg.labelWidth(1, stackErrorLabels...)
 
 	// CloudFormation error details
-	sb.WriteString(fmt.Sprintf("%sTimestamp:     %s\n", indent, formatTimestamp(err.StackError.Timestamp)))
-	sb.WriteString(fmt.Sprintf("%sResource:      %s\n", indent, err.StackError.LogicalResourceId))
-	sb.WriteString(fmt.Sprintf("%sResource Type: %s\n", indent, err.StackError.ResourceType))
-	sb.WriteString(fmt.Sprintf("%sStatus:        %s\n", indent, err.StackError.ResourceStatus))
+	sb.WriteString(fmt.Sprintf("%s%s%s\n", indent, r.msg.label(msgTimestamp, width), formatTimestamp(err.StackError.Timestamp)))
+	sb.WriteString(fmt.Sprintf("%s%s%s\n", indent, r.msg.label(msgResource, width), err.StackError.LogicalResourceId))
+	sb.WriteString(fmt.Sprintf("%s%s%s\n", indent, r.msg.label(msgResourceType, width), err.StackError.ResourceType))
+	sb.WriteString(fmt.Sprintf("%s%s%s\n", indent, r.msg.label(msgStatus, width), err.StackError.ResourceStatus))
 
 	if err.StackError.ResourceStatusReason != "" {
-		sb.WriteString(fmt.Sprintf("%sReason:        %s\n", indent, err.StackError.ResourceStatusReason))
+		sb.WriteString(fmt.Sprintf("%s%s%s\n", indent, r.msg.label(msgReason, width), r.wrap(err.StackError.ResourceStatusReason, indentWidth+width)))
+	}
+
+	if requestID := classify.RequestID(err); requestID != "" {
+		sb.WriteString(fmt.Sprintf("%s%s%s\n", indent, r.msg.label(msgRequestID, width), requestID))
+	}
+
+	if classify.Retryable(err) {
+		sb.WriteString(fmt.Sprintf("%s%s%s\n", indent, r.msg.label(msgRetryable, width), r.msg.get(msgRetryableYes)))
 	}
 
 	if err.StackError.IsGeneralServiceException {
-		sb.WriteString(fmt.Sprintf("%s[!] GeneralServiceException - CloudTrail investigation required\n", indent))
+		sb.WriteString(fmt.Sprintf("%s[!] %s\n", indent, r.msg.get(msgGeneralServiceExceptionHint)))
 	}
 
 	// CloudTrail details if available
-	if err.CloudTrailEvent != nil {
-		sb.WriteString(fmt.Sprintf("\n%sCloudTrail Details:\n", indent))
+	if err.CloudTrailEvent != nil && !r.opts.Sections.HideCloudTrailDetails {
+		sb.WriteString(fmt.Sprintf("\n%s%s:\n", indent, r.msg.get(msgCloudTrailDetails)))
 
 		innerIndent := strings.Repeat(" ", indentWidth*2)
-		sb.WriteString(fmt.Sprintf("%sEvent Time:   %s\n", innerIndent, formatTimestamp(err.CloudTrailEvent.EventTime)))
-		sb.WriteString(fmt.Sprintf("%sEvent Name:   %s\n", innerIndent, err.CloudTrailEvent.EventName))
-		sb.WriteString(fmt.Sprintf("%sEvent Source: %s\n", innerIndent, err.CloudTrailEvent.EventSource))
+		ctWidth := r.msg.labelWidth(1, cloudTrailLabels...)
+		sb.WriteString(fmt.Sprintf("%s%s%s\n", innerIndent, r.msg.label(msgEventTime, ctWidth), formatTimestamp(err.CloudTrailEvent.EventTime)))
+		sb.WriteString(fmt.Sprintf("%s%s%s\n", innerIndent, r.msg.label(msgEventName, ctWidth), err.CloudTrailEvent.EventName))
+		sb.WriteString(fmt.Sprintf("%s%s%s\n", innerIndent, r.msg.label(msgEventSource, ctWidth), err.CloudTrailEvent.EventSource))
+
+		if err.CloudTrailEvent.EventID != "" {
+			sb.WriteString(fmt.Sprintf("%s%s%s\n", innerIndent, r.msg.label(msgEventID, ctWidth), err.CloudTrailEvent.EventID))
+		}
+
+		if err.CloudTrailEvent.RequestID != "" {
+			sb.WriteString(fmt.Sprintf("%s%s%s\n", innerIndent, r.msg.label(msgRequestID, ctWidth), err.CloudTrailEvent.RequestID))
+		}
 
 		if err.CloudTrailEvent.ErrorCode != "" {
-			sb.WriteString(fmt.Sprintf("%sError Code:   %s\n", innerIndent, err.CloudTrailEvent.ErrorCode))
+			sb.WriteString(fmt.Sprintf("%s%s%s\n", innerIndent, r.msg.label(msgErrorCode, ctWidth), err.CloudTrailEvent.ErrorCode))
 		}
 
 		if err.CloudTrailEvent.ErrorMessage != "" {
-			sb.WriteString(fmt.Sprintf("%sError Msg:    %s\n", innerIndent, err.CloudTrailEvent.ErrorMessage))
+			sb.WriteString(fmt.Sprintf("%s%s%s\n", innerIndent, r.msg.label(msgErrorMsg, ctWidth), r.wrap(err.CloudTrailEvent.ErrorMessage, indentWidth*2+ctWidth)))
 		}
+
+		if widened := r.widenedWindow(err.CorrelationWindow); widened != "" {
+			sb.WriteString(fmt.Sprintf("%s%s%s\n", innerIndent, r.msg.label(msgTimeWindow, ctWidth), widened))
+		}
+
+		if err.Retries != nil {
+			sb.WriteString(fmt.Sprintf("%s%s%s\n", innerIndent, r.msg.label(msgRetries, ctWidth), r.retries(err.Retries)))
+		}
+
+		sb.WriteString(fmt.Sprintf("%s%s%s\n", innerIndent, r.msg.label(msgEvidence, ctWidth), r.wrap(r.evidence(err), indentWidth*2+ctWidth)))
 	}
 
 	// Detailed message
 	if err.DetailedMessage != "" {
 		sb.WriteString("\n")
 		if err.CloudTrailEvent != nil {
-			sb.WriteString(fmt.Sprintf("%sDetailed Message (from CloudTrail):\n", indent))
+			sb.WriteString(fmt.Sprintf("%s%s:\n", indent, r.msg.get(msgDetailedMessageCloudTrail)))
 		} else {
-			sb.WriteString(fmt.Sprintf("%sDetailed Message:\n", indent))
+			sb.WriteString(fmt.Sprintf("%s%s:\n", indent, r.msg.get(msgDetailedMessage)))
 		}
 		innerIndent := strings.Repeat(" ", indentWidth*2)
-		sb.WriteString(fmt.Sprintf("%s%s\n", innerIndent, err.DetailedMessage))
+		sb.WriteString(fmt.Sprintf("%s%s\n", innerIndent, r.wrap(err.DetailedMessage, indentWidth*2)))
 	}
 
 	return sb.String()
@@ -304,17 +1165,38 @@ func FormatErrorPlainText(err analyzer.CorrelatedError) string {
 // FormatCompact formats analysis results in a compact single-line-per-error format
 // Useful for quick scanning or piping to other tools
 func FormatCompact(analysis *analyzer.StackAnalysis) string {
+	return newRenderer(Options{}).compact(analysis)
+}
+
+// compact formats analysis results in a compact single-line-per-error format
+func (r *renderer) compact(analysis *analyzer.StackAnalysis) string {
 	if analysis == nil {
-		return "No analysis results available."
+		return r.msg.get(msgNoResults)
 	}
 
 	var sb strings.Builder
 
-	sb.WriteString(fmt.Sprintf("Stack: %s | Errors: %d | GeneralServiceExceptions: %d | With CloudTrail: %d\n",
-		analysis.StackName, len(analysis.Errors), analysis.GeneralErrors, analysis.DetailedErrors))
+	if !r.opts.Sections.HideRootCause {
+		if text := rootCauseText(analysis); text != "" {
+			sb.WriteString(r.msg.get(msgRootCause) + ": " + Truncate(text, r.opts.MaxMessageLength) + "\n")
+		}
+	}
 
-	for _, err := range analysis.Errors {
-		sb.WriteString(FormatErrorCompact(err))
+	if !r.opts.Sections.HideSummary {
+		sb.WriteString(r.msg.format(msgCompactHeader,
+			analysis.StackName, len(analysis.Errors), analysis.GeneralErrors, analysis.DetailedErrors) + "\n")
+		if analysis.Partial {
+			sb.WriteString(r.msg.get(msgPartial) + "\n")
+		}
+	}
+
+	if !r.opts.Sections.HideErrors {
+		for _, err := range analysis.Errors {
+			sb.WriteString(r.errorCompact(err))
+		}
+		for _, item := range analysis.Ignored {
+			sb.WriteString(r.ignoredCompact(item))
+		}
 	}
 
 	return sb.String()
@@ -322,18 +1204,17 @@ func FormatCompact(analysis *analyzer.StackAnalysis) string {
 
 // FormatErrorCompact formats an individual error in compact format
 func FormatErrorCompact(err analyzer.CorrelatedError) string {
+	return newRenderer(Options{MaxMessageLength: DefaultMaxMessageLength}).errorCompact(err)
+}
+
+// errorCompact formats an individual error in compact format
+func (r *renderer) errorCompact(err analyzer.CorrelatedError) string {
 	timestamp := formatTimestamp(err.StackError.Timestamp)
 	resource := err.StackError.LogicalResourceId
 	status := err.StackError.ResourceStatus
 
-	var detail string
-	if err.DetailedMessage != "" {
-		// Truncate long messages for compact format
-		detail = err.DetailedMessage
-		if len(detail) > 100 {
-			detail = detail[:97] + "..."
-		}
-	}
+	// Truncate long messages for compact format
+	detail := Truncate(err.DetailedMessage, r.opts.MaxMessageLength)
 
 	gseFlag := ""
 	if err.StackError.IsGeneralServiceException {
@@ -344,6 +1225,84 @@ func FormatErrorCompact(err analyzer.CorrelatedError) string {
 	if err.CloudTrailEvent != nil {
 		ctFlag = " [CT]"
 	}
+	if err.Retries != nil {
+		ctFlag += fmt.Sprintf(" [x%d]", err.Retries.Count)
+	}
+
+	retryFlag := ""
+	if classify.Retryable(err) {
+		retryFlag = " [RETRYABLE]"
+	}
+
+	return fmt.Sprintf("%s | %s | %s%s%s%s | %s\n", timestamp, resource, status, gseFlag, ctFlag, retryFlag, detail)
+}
+
+// ignoredCompact formats an ignored error in compact format, marked with [IGNORED]
+func (r *renderer) ignoredCompact(item analyzer.IgnoredError) string {
+	err := item.Error
+	detail := Truncate(errorDescription(err), r.opts.MaxMessageLength)
+
+	return fmt.Sprintf("%s | %s | %s [IGNORED] | %s\n", formatTimestamp(err.StackError.Timestamp),
+		err.StackError.LogicalResourceId, err.StackError.ResourceStatus, detail)
+}
+
+// Truncate shortens a message to at most maxLength characters, marking the cut with "...".
+// A maxLength of 0 disables truncation.
+func Truncate(message string, maxLength int) string {
+	if maxLength <= 0 || utf8.RuneCountInString(message) <= maxLength {
+		return message
+	}
+
+	runes := []rune(message)
+	if maxLength <= 3 {
+		return string(runes[:maxLength])
+	}
+	return string(runes[:maxLength-3]) + "..."
+}
+
+// wrap word-wraps a value that starts at the given column to the configured width.
+// Continuation lines are indented to the same column so they align with the first line.
+func (r *renderer) wrap(text string, column int) string {
+	available := r.opts.Width - column
+	if r.opts.Width <= 0 || available < minWrapWidth || utf8.RuneCountInString(text) <= available {
+		return text
+	}
 
-	return fmt.Sprintf("%s | %s | %s%s%s | %s\n", timestamp, resource, status, gseFlag, ctFlag, detail)
+	var sb strings.Builder
+	continuation := "\n" + strings.Repeat(" ", column)
+
+	for i, line := range strings.Split(text, "\n") {
+		if i > 0 {
+			sb.WriteString(continuation)
+		}
+
+		lineLength := 0
+		for _, word := range strings.Fields(line) {
+			wordLength := utf8.RuneCountInString(word)
+			if lineLength > 0 && lineLength+1+wordLength > available {
+				sb.WriteString(continuation)
+				lineLength = 0
+			} else if lineLength > 0 {
+				sb.WriteString(" ")
+				lineLength++
+			}
+			sb.WriteString(word)
+			lineLength += wordLength
+		}
+	}
+
+	return sb.String()
+}
+
+// minWrapWidth is the narrowest column range messages are wrapped into;
+// narrower terminals get unwrapped output rather than one word per line
+const minWrapWidth = 20
+
+// pad right-pads a label with spaces to the given display width
+func pad(label string, width int) string {
+	n := utf8.RuneCountInString(label)
+	if n >= width {
+		return label + " "
+	}
+	return label + strings.Repeat(" ", width-n)
 }
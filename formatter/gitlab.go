@@ -0,0 +1,114 @@
+package formatter
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"cfn-root-cause/analyzer"
+	"cfn-root-cause/explain"
+	"cfn-root-cause/rootcause"
+)
+
+// gitLabIssue is a single entry of a GitLab Code Quality report
+// See https://docs.gitlab.com/ci/testing/code_quality/#code-quality-report-format
+type gitLabIssue struct {
+	Description string         `json:"description"`
+	CheckName   string         `json:"check_name"`
+	Fingerprint string         `json:"fingerprint"`
+	Severity    string         `json:"severity"`
+	Location    gitLabLocation `json:"location"`
+}
+
+// gitLabLocation points a Code Quality issue at a file and line
+type gitLabLocation struct {
+	Path  string      `json:"path"`
+	Lines gitLabLines `json:"lines"`
+}
+
+// gitLabLines holds the line range of a Code Quality issue
+type gitLabLines struct {
+	Begin int `json:"begin"`
+}
+
+// FormatGitLabCodeQuality formats analysis results as a GitLab Code Quality report.
+// Each error and finding becomes an issue attached to the template path, so root causes
+// appear in the merge request widget when the report is uploaded as a codequality artifact.
+func FormatGitLabCodeQuality(analysis *analyzer.StackAnalysis, templatePath string) (string, error) {
+	issues := []gitLabIssue{}
+	if analysis != nil {
+		issues = gitLabIssues(analysis, templatePath)
+	}
+
+	return encodeGitLabIssues(issues)
+}
+
+// gitLabIssues converts the errors and findings of an analysis to Code Quality issues
+func gitLabIssues(analysis *analyzer.StackAnalysis, templatePath string) []gitLabIssue {
+	issues := []gitLabIssue{}
+
+	// The most likely root cause comes first, so it leads the merge request widget
+	if candidate := rootcause.MostLikely(analysis); candidate != nil {
+		issues = append(issues, gitLabIssue{
+			Description: "Most likely root cause: " + explain.Error(candidate.Error, candidate.Finding),
+			CheckName:   "most-likely-root-cause",
+			Fingerprint: fingerprint(analysis.StackName, candidate.Error.StackError.LogicalResourceId, "most-likely-root-cause"),
+			Severity:    "critical",
+			Location:    gitLabLocation{Path: templatePath, Lines: gitLabLines{Begin: 1}},
+		})
+	}
+
+	for _, err := range analysis.Errors {
+		issues = append(issues, gitLabIssue{
+			Description: fmt.Sprintf("%s %s (%s): %s", err.StackError.LogicalResourceId,
+				err.StackError.ResourceStatus, err.StackError.ResourceType, errorDescription(err)),
+			CheckName:   err.StackError.ResourceStatus,
+			Fingerprint: fingerprint(analysis.StackName, err.StackError.LogicalResourceId, err.StackError.ResourceStatus, errorDescription(err)),
+			Severity:    "major",
+			Location:    gitLabLocation{Path: templatePath, Lines: gitLabLines{Begin: 1}},
+		})
+	}
+
+	for _, finding := range analysis.Findings {
+		issues = append(issues, gitLabIssue{
+			Description: fmt.Sprintf("%s: %s %s", finding.Title, finding.Explanation, finding.Suggestion),
+			CheckName:   finding.Pattern,
+			Fingerprint: fingerprint(analysis.StackName, finding.LogicalResourceId, finding.Pattern),
+			Severity:    "info",
+			Location:    gitLabLocation{Path: templatePath, Lines: gitLabLines{Begin: 1}},
+		})
+	}
+
+	return issues
+}
+
+// encodeGitLabIssues encodes Code Quality issues as an indented JSON array
+func encodeGitLabIssues(issues []gitLabIssue) (string, error) {
+	var sb strings.Builder
+	encoder := json.NewEncoder(&sb)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(issues); err != nil {
+		return "", fmt.Errorf("failed to encode GitLab Code Quality report: %w", err)
+	}
+
+	return sb.String(), nil
+}
+
+// errorDescription returns the most detailed description of an error,
+// falling back to the CloudFormation status reason
+func errorDescription(err analyzer.CorrelatedError) string {
+	if err.DetailedMessage != "" {
+		return err.DetailedMessage
+	}
+	return err.StackError.ResourceStatusReason
+}
+
+// fingerprint builds a stable identifier from the given parts so the same
+// root cause keeps its identity across pipeline runs
+func fingerprint(parts ...string) string {
+	sum := md5.Sum([]byte(strings.Join(parts, "|")))
+	return hex.EncodeToString(sum[:])
+}
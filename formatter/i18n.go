@@ -0,0 +1,423 @@
+package formatter
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// DefaultLanguage is used when no supported language is selected
+const DefaultLanguage = "en"
+
+// Message keys of the report text
+const (
+	msgNoResults                   = "noResults"
+	msgNoErrors                    = "noErrors"
+	msgReportTitle                 = "reportTitle"
+	msgStackName                   = "stackName"
+	msgAnalysisTime                = "analysisTime"
+	msgSummary                     = "summary"
+	msgTotalErrors                 = "totalErrors"
+	msgGeneralServiceExceptions    = "generalServiceExceptions"
+	msgWithCloudTrail              = "withCloudTrail"
+	msgErrors                      = "errors"
+	msgErrorHeading                = "errorHeading"
+	msgFindings                    = "findings"
+	msgFindingHeading              = "findingHeading"
+	msgEvidence                    = "evidence"
+	msgSuggestion                  = "suggestion"
+	msgTimestamp                   = "timestamp"
+	msgResource                    = "resource"
+	msgResourceType                = "resourceType"
+	msgStatus                      = "status"
+	msgReason                      = "reason"
+	msgGeneralServiceExceptionHint = "generalServiceExceptionHint"
+	msgCloudTrailDetails           = "cloudTrailDetails"
+	msgEventTime                   = "eventTime"
+	msgEventName                   = "eventName"
+	msgEventSource                 = "eventSource"
+	msgErrorCode                   = "errorCode"
+	msgErrorMsg                    = "errorMsg"
+	msgTimeWindow                  = "timeWindow"
+	msgTimeWindowWidened           = "timeWindowWidened"
+	msgRetries                     = "retries"
+	msgRetriesValue                = "retriesValue"
+	msgSignalPhysicalID            = "signalPhysicalId"
+	msgSignalRequestID             = "signalRequestId"
+	msgSignalResourceID            = "signalResourceId"
+	msgSignalService               = "signalService"
+	msgSignalBefore                = "signalBefore"
+	msgSignalAfter                 = "signalAfter"
+	msgDetailedMessage             = "detailedMessage"
+	msgDetailedMessageCloudTrail   = "detailedMessageCloudTrail"
+	msgPerformanceStatistics       = "performanceStatistics"
+	msgTotal                       = "total"
+	msgStackEventsScanned          = "stackEventsScanned"
+	msgCloudTrailCalls             = "cloudTrailCalls"
+	msgCloudTrailEventsParsed      = "cloudTrailEventsParsed"
+	msgCompactHeader               = "compactHeader"
+	msgIgnored                     = "ignored"
+	msgIgnoredErrors               = "ignoredErrors"
+	msgAggregateTitle              = "aggregateTitle"
+	msgStacksAnalyzed              = "stacksAnalyzed"
+	msgStacksWithErrors            = "stacksWithErrors"
+	msgFailuresByCategory          = "failuresByCategory"
+	msgCommonErrorCodes            = "commonErrorCodes"
+	msgStacks                      = "stacks"
+	msgStackErrorCount             = "stackErrorCount"
+	msgAggregateCompactHeader      = "aggregateCompactHeader"
+	msgAccount                     = "account"
+	msgRegion                      = "region"
+	msgPrincipal                   = "principal"
+	msgTemplateChanges             = "templateChanges"
+	msgChangeSet                   = "changeSet"
+	msgTemplateUnchanged           = "templateUnchanged"
+	msgStackContext                = "stackContext"
+	msgParameters                  = "parameters"
+	msgOutputs                     = "outputs"
+	msgNone                        = "none"
+	msgNoEchoValue                 = "noEchoValue"
+	msgResolvedValue               = "resolvedValue"
+	msgExportName                  = "exportName"
+	msgAISummary                   = "aiSummary"
+	msgExplanation                 = "explanation"
+	msgRootCause                   = "rootCause"
+	msgProbableCauses              = "probableCauses"
+	msgProbableCauseHeading        = "probableCauseHeading"
+	msgProvisionedProduct          = "provisionedProduct"
+	msgProduct                     = "product"
+	msgProvisioningArtifact        = "provisioningArtifact"
+	msgProductStatus               = "productStatus"
+	msgRecordError                 = "provisioningError"
+	msgRequestID                   = "requestId"
+	msgEventID                     = "eventId"
+	msgRetryable                   = "retryable"
+	msgRetryableYes                = "retryableYes"
+	msgSafeToRetry                 = "safeToRetry"
+	msgPartial                     = "partial"
+	msgErrorsByService             = "errorsByService"
+	msgWarnings                    = "warnings"
+	msgHealth                      = "health"
+	msgHealthDetails               = "healthDetails"
+	msgRolledBack                  = "rolledBack"
+	msgLongestResource             = "longestResource"
+	msgStackStatus                 = "stackStatus"
+	msgStatusReason                = "statusReason"
+	msgInProgress                  = "inProgress"
+	msgErrorLink                   = "errorLink"
+	msgTimeline                    = "timeline"
+	msgEvent                       = "event"
+	msgDetails                     = "details"
+)
+
+// phaseKeyPrefix prefixes message keys of analysis phase names
+const phaseKeyPrefix = "phase:"
+
+// messages is a catalog of report text keyed by message key
+type messages map[string]string
+
+// catalogs contains the message catalogs of all supported languages.
+// Keys missing from a catalog fall back to English.
+var catalogs = map[string]messages{
+	"en": {
+		msgNoResults:                   "No analysis results available.",
+		msgNoErrors:                    "No errors found in stack events.",
+		msgReportTitle:                 "CloudFormation Error Analysis Report",
+		msgStackName:                   "Stack Name",
+		msgAnalysisTime:                "Analysis Time",
+		msgSummary:                     "Summary",
+		msgTotalErrors:                 "Total Errors",
+		msgGeneralServiceExceptions:    "GeneralServiceExceptions",
+		msgWithCloudTrail:              "With CloudTrail Details",
+		msgErrors:                      "Errors",
+		msgErrorHeading:                "[Error %d]",
+		msgFindings:                    "Root Cause Findings",
+		msgFindingHeading:              "[Finding %d]",
+		msgEvidence:                    "Evidence",
+		msgSuggestion:                  "Suggestion",
+		msgTimestamp:                   "Timestamp",
+		msgResource:                    "Resource",
+		msgResourceType:                "Resource Type",
+		msgStatus:                      "Status",
+		msgReason:                      "Reason",
+		msgGeneralServiceExceptionHint: "GeneralServiceException - CloudTrail investigation required",
+		msgCloudTrailDetails:           "CloudTrail Details",
+		msgEventTime:                   "Event Time",
+		msgEventName:                   "Event Name",
+		msgEventSource:                 "Event Source",
+		msgErrorCode:                   "Error Code",
+		msgErrorMsg:                    "Error Msg",
+		msgTimeWindow:                  "Time Window",
+		msgTimeWindowWidened:           "%s (widened, no match within %s)",
+		msgRetries:                     "Retries",
+		msgRetriesValue:                "%d identical failed calls from %s to %s",
+		msgSignalPhysicalID:            "CloudTrail lists the physical resource %s",
+		msgSignalRequestID:             "status reason names request ID %s",
+		msgSignalResourceID:            "event mentions %s",
+		msgSignalService:               "%s is the service of the resource type",
+		msgSignalBefore:                "%s before the failure",
+		msgSignalAfter:                 "%s after the failure",
+		msgDetailedMessage:             "Detailed Message",
+		msgDetailedMessageCloudTrail:   "Detailed Message (from CloudTrail)",
+		msgPerformanceStatistics:       "Performance Statistics",
+		msgTotal:                       "Total",
+		msgStackEventsScanned:          "Stack events scanned",
+		msgCloudTrailCalls:             "CloudTrail API calls",
+		msgCloudTrailEventsParsed:      "CloudTrail events parsed",
+		msgCompactHeader:               "Stack: %s | Errors: %d | GeneralServiceExceptions: %d | With CloudTrail: %d",
+		msgIgnored:                     "Ignored",
+		msgIgnoredErrors:               "Ignored Errors",
+		msgAggregateTitle:              "CloudFormation Multi-Stack Error Analysis",
+		msgStacksAnalyzed:              "Stacks Analyzed",
+		msgStacksWithErrors:            "Stacks With Errors",
+		msgFailuresByCategory:          "Failures by Category",
+		msgCommonErrorCodes:            "Most Common Error Codes",
+		msgStacks:                      "Stacks",
+		msgStackErrorCount:             "%d error(s)",
+		msgAggregateCompactHeader:      "Stacks: %d | With errors: %d | Errors: %d",
+		msgAccount:                     "Account",
+		msgRegion:                      "Region",
+		msgPrincipal:                   "Principal",
+		msgTemplateChanges:             "What Changed in This Deployment",
+		msgChangeSet:                   "Change Set",
+		msgTemplateUnchanged:           "The template is unchanged; the failure is not caused by a template change.",
+		msgStackContext:                "Stack Parameters and Outputs",
+		msgParameters:                  "Parameters",
+		msgOutputs:                     "Outputs",
+		msgNone:                        "none",
+		msgNoEchoValue:                 "**** (NoEcho)",
+		msgResolvedValue:               "resolved: %s",
+		msgExportName:                  "export: %s",
+		msgAISummary:                   "AI Summary",
+		msgExplanation:                 "What Happened",
+		msgRootCause:                   "Most Likely Root Cause",
+		msgProbableCauses:              "Probable Causes",
+		msgProbableCauseHeading:        "%d. %s (%s) %s at %s, score %d",
+		msgProvisionedProduct:          "Provisioned Product",
+		msgProduct:                     "Product",
+		msgProvisioningArtifact:        "Provisioning Artifact",
+		msgProductStatus:               "Product Status",
+		msgRecordError:                 "Provisioning Error",
+		msgRequestID:                   "Request ID",
+		msgEventID:                     "Event ID",
+		msgRetryable:                   "Retryable",
+		msgRetryableYes:                "yes (transient)",
+		msgSafeToRetry:                 "All root causes are transient; it is safe to retry the deployment.",
+		msgPartial:                     "PARTIAL RESULTS: the analysis was interrupted; CloudTrail details may be incomplete.",
+		msgErrorsByService:             "Errors by Service",
+		msgWarnings:                    "Warnings",
+		msgHealth:                      "Deployment Health",
+		msgHealthDetails:               "%d events, %d failures, %d retries",
+		msgRolledBack:                  "rolled back",
+		msgLongestResource:             "longest: %s (%s)",
+		msgStackStatus:                 "Stack Status",
+		msgStatusReason:                "Status Reason",
+		msgInProgress:                  "OPERATION IN PROGRESS: the stack is still changing; more errors may follow.",
+		msgErrorLink:                   "Error %d",
+		msgTimeline:                    "Resource Timeline",
+		msgEvent:                       "Event",
+		msgDetails:                     "Details",
+	},
+	"de": {
+		msgNoResults:                   "Keine Analyseergebnisse verfügbar.",
+		msgNoErrors:                    "Keine Fehler in den Stack-Events gefunden.",
+		msgReportTitle:                 "CloudFormation-Fehleranalyse",
+		msgStackName:                   "Stack-Name",
+		msgAnalysisTime:                "Analysezeit",
+		msgSummary:                     "Zusammenfassung",
+		msgTotalErrors:                 "Fehler gesamt",
+		msgGeneralServiceExceptions:    "GeneralServiceExceptions",
+		msgWithCloudTrail:              "Mit CloudTrail-Details",
+		msgErrors:                      "Fehler",
+		msgErrorHeading:                "[Fehler %d]",
+		msgFindings:                    "Ermittelte Ursachen",
+		msgFindingHeading:              "[Befund %d]",
+		msgEvidence:                    "Belege",
+		msgSuggestion:                  "Empfehlung",
+		msgTimestamp:                   "Zeitpunkt",
+		msgResource:                    "Ressource",
+		msgResourceType:                "Ressourcentyp",
+		msgStatus:                      "Status",
+		msgReason:                      "Grund",
+		msgGeneralServiceExceptionHint: "GeneralServiceException - Untersuchung in CloudTrail erforderlich",
+		msgCloudTrailDetails:           "CloudTrail-Details",
+		msgEventTime:                   "Zeitpunkt",
+		msgEventName:                   "Aufruf",
+		msgEventSource:                 "Quelle",
+		msgErrorCode:                   "Fehlercode",
+		msgErrorMsg:                    "Fehlermeldung",
+		msgTimeWindow:                  "Zeitfenster",
+		msgTimeWindowWidened:           "%s (erweitert, kein Treffer innerhalb %s)",
+		msgRetries:                     "Versuche",
+		msgRetriesValue:                "%d gleiche fehlgeschlagene Aufrufe von %s bis %s",
+		msgSignalPhysicalID:            "CloudTrail nennt die physische Ressource %s",
+		msgSignalRequestID:             "Statusgrund nennt Request-ID %s",
+		msgSignalResourceID:            "Aufruf erwähnt %s",
+		msgSignalService:               "%s ist der Dienst des Ressourcentyps",
+		msgSignalBefore:                "%s vor dem Fehler",
+		msgSignalAfter:                 "%s nach dem Fehler",
+		msgDetailedMessage:             "Detaillierte Meldung",
+		msgDetailedMessageCloudTrail:   "Detaillierte Meldung (aus CloudTrail)",
+		msgPerformanceStatistics:       "Laufzeitstatistik",
+		msgTotal:                       "Gesamt",
+		msgStackEventsScanned:          "Gelesene Stack-Events",
+		msgCloudTrailCalls:             "CloudTrail-API-Aufrufe",
+		msgCloudTrailEventsParsed:      "Verarbeitete CloudTrail-Events",
+		msgCompactHeader:               "Stack: %s | Fehler: %d | GeneralServiceExceptions: %d | Mit CloudTrail: %d",
+		msgIgnored:                     "Ignoriert",
+		msgIgnoredErrors:               "Ignorierte Fehler",
+		msgAggregateTitle:              "CloudFormation-Fehleranalyse mehrerer Stacks",
+		msgStacksAnalyzed:              "Analysierte Stacks",
+		msgStacksWithErrors:            "Stacks mit Fehlern",
+		msgFailuresByCategory:          "Fehler nach Kategorie",
+		msgCommonErrorCodes:            "Häufigste Fehlercodes",
+		msgStacks:                      "Stacks",
+		msgStackErrorCount:             "%d Fehler",
+		msgAggregateCompactHeader:      "Stacks: %d | Mit Fehlern: %d | Fehler: %d",
+		msgAccount:                     "Konto",
+		msgRegion:                      "Region",
+		msgPrincipal:                   "Prinzipal",
+		msgTemplateChanges:             "Änderungen in diesem Deployment",
+		msgChangeSet:                   "Change Set",
+		msgTemplateUnchanged:           "Das Template ist unverändert; der Fehler wird nicht durch eine Template-Änderung verursacht.",
+		msgStackContext:                "Stack-Parameter und -Outputs",
+		msgParameters:                  "Parameter",
+		msgOutputs:                     "Outputs",
+		msgNone:                        "keine",
+		msgNoEchoValue:                 "**** (NoEcho)",
+		msgResolvedValue:               "aufgelöst: %s",
+		msgExportName:                  "Export: %s",
+		msgAISummary:                   "KI-Zusammenfassung",
+		msgExplanation:                 "Was ist passiert",
+		msgRootCause:                   "Wahrscheinlichste Ursache",
+		msgProbableCauses:              "Mögliche Ursachen",
+		msgProbableCauseHeading:        "%d. %s (%s) %s um %s, Bewertung %d",
+		msgProvisionedProduct:          "Bereitgestelltes Produkt",
+		msgProduct:                     "Produkt",
+		msgProvisioningArtifact:        "Bereitstellungsartefakt",
+		msgProductStatus:               "Produktstatus",
+		msgRecordError:                 "Bereitstellungsfehler",
+		msgRequestID:                   "Request-ID",
+		msgEventID:                     "Event-ID",
+		msgRetryable:                   "Wiederholbar",
+		msgRetryableYes:                "ja (vorübergehend)",
+		msgSafeToRetry:                 "Alle Ursachen sind vorübergehend; das Deployment kann gefahrlos wiederholt werden.",
+		msgPartial:                     "TEILERGEBNIS: Die Analyse wurde abgebrochen; CloudTrail-Details sind möglicherweise unvollständig.",
+		msgErrorsByService:             "Fehler nach Service",
+		msgWarnings:                    "Warnungen",
+		msgHealth:                      "Deployment-Zustand",
+		msgHealthDetails:               "%d Events, %d Fehler, %d Wiederholungen",
+		msgRolledBack:                  "zurückgesetzt",
+		msgLongestResource:             "am längsten: %s (%s)",
+		msgStackStatus:                 "Stack-Status",
+		msgStatusReason:                "Statusgrund",
+		msgInProgress:                  "VORGANG LÄUFT: Der Stack wird noch geändert; weitere Fehler können folgen.",
+		msgErrorLink:                   "Fehler %d",
+		msgTimeline:                    "Zeitleiste der Ressourcen",
+		msgEvent:                       "Ereignis",
+		msgDetails:                     "Details",
+
+		phaseKeyPrefix + "Retrieve stack events":     "Stack-Events abrufen",
+		phaseKeyPrefix + "Extract errors":            "Fehler extrahieren",
+		phaseKeyPrefix + "Query CloudTrail":          "CloudTrail abfragen",
+		phaseKeyPrefix + "Query import verification": "Import-Prüfung abfragen",
+		phaseKeyPrefix + "Correlate errors":          "Fehler korrelieren",
+		phaseKeyPrefix + "Detect patterns":           "Muster erkennen",
+	},
+}
+
+// Languages returns the codes of all supported report languages
+func Languages() []string {
+	languages := make([]string, 0, len(catalogs))
+	for language := range catalogs {
+		languages = append(languages, language)
+	}
+	sort.Strings(languages)
+	return languages
+}
+
+// IsValidLanguage checks if the given code is a supported report language
+func IsValidLanguage(language string) bool {
+	_, ok := catalogs[language]
+	return ok
+}
+
+// ResolveLanguage determines the report language.
+// An explicitly requested language wins; otherwise the locale environment
+// (LC_ALL, LC_MESSAGES, LANG) is consulted, falling back to English.
+func ResolveLanguage(requested string) string {
+	if requested != "" {
+		return requested
+	}
+
+	for _, name := range []string{"LC_ALL", "LC_MESSAGES", "LANG"} {
+		value := os.Getenv(name)
+		if value == "" {
+			continue
+		}
+		if language := languageFromLocale(value); IsValidLanguage(language) {
+			return language
+		}
+		// The first non-empty variable determines the locale, even if unsupported
+		break
+	}
+
+	return DefaultLanguage
+}
+
+// languageFromLocale extracts the language code from a POSIX locale such as "de_DE.UTF-8"
+func languageFromLocale(locale string) string {
+	language := strings.ToLower(locale)
+	if i := strings.IndexAny(language, "_.@-"); i >= 0 {
+		language = language[:i]
+	}
+	return language
+}
+
+// catalogFor returns the message catalog of the given language, falling back to English
+func catalogFor(language string) messages {
+	if catalog, ok := catalogs[language]; ok {
+		return catalog
+	}
+	return catalogs[DefaultLanguage]
+}
+
+// get returns the message for the key, falling back to the English text
+func (m messages) get(key string) string {
+	if msg, ok := m[key]; ok {
+		return msg
+	}
+	return catalogs[DefaultLanguage][key]
+}
+
+// format returns the message for the key formatted with the given arguments
+func (m messages) format(key string, a ...interface{}) string {
+	return fmt.Sprintf(m.get(key), a...)
+}
+
+// phase returns the translated name of an analysis phase
+func (m messages) phase(name string) string {
+	if msg, ok := m[phaseKeyPrefix+name]; ok {
+		return msg
+	}
+	return name
+}
+
+// label returns the message for the key followed by a colon, padded to the given width
+func (m messages) label(key string, width int) string {
+	return pad(m.get(key)+":", width)
+}
+
+// labelWidth returns the width needed to align the values of the given labels,
+// leaving gap spaces after the longest label and its colon
+func (m messages) labelWidth(gap int, keys ...string) int {
+	width := 0
+	for _, key := range keys {
+		if n := utf8.RuneCountInString(m.get(key)) + 1 + gap; n > width {
+			width = n
+		}
+	}
+	return width
+}
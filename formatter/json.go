@@ -0,0 +1,613 @@
+package formatter
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"cfn-root-cause/aggregate"
+	"cfn-root-cause/analyzer"
+	"cfn-root-cause/classify"
+	"cfn-root-cause/correlator"
+	"cfn-root-cause/explain"
+	"cfn-root-cause/rootcause"
+)
+
+// JSONSchemaVersion is the version of the JSON report schema.
+// The major version changes only on incompatible changes; new optional fields bump the minor version.
+const JSONSchemaVersion = "1.21"
+
+// jsonSchema is the JSON Schema describing the json report format
+//
+//go:embed report.schema.json
+var jsonSchema string
+
+// JSONSchema returns the JSON Schema of the json report format
+func JSONSchema() string {
+	return jsonSchema
+}
+
+// jsonReport is the root object of the json report format
+type jsonReport struct {
+	SchemaVersion string            `json:"schemaVersion"`
+	StackName     string            `json:"stackName"`
+	AnalysisTime  time.Time         `json:"analysisTime"`
+	Partial       bool              `json:"partial,omitempty"`
+	StackStatus   *jsonStackStatus  `json:"stackStatus,omitempty"`
+	RootCause     *jsonRootCause    `json:"rootCause,omitempty"`
+	Causes        []jsonCause       `json:"probableCauses,omitempty"`
+	Identity      *jsonIdentity     `json:"identity,omitempty"`
+	Metadata      *jsonMetadata     `json:"metadata,omitempty"`
+	Product       *jsonProduct      `json:"provisionedProduct,omitempty"`
+	Summary       jsonSummary       `json:"summary"`
+	Errors        []jsonError       `json:"errors"`
+	Ignored       []jsonIgnored     `json:"ignored"`
+	Findings      []jsonFinding     `json:"findings"`
+	Warnings      []jsonWarning     `json:"warnings,omitempty"`
+	TemplateDiff  *jsonTemplateDiff `json:"templateDiff,omitempty"`
+	StackContext  *jsonStackContext `json:"stackContext,omitempty"`
+	AISummary     string            `json:"aiSummary,omitempty"`
+	Explanations  []jsonExplanation `json:"explanations,omitempty"`
+	Stats         *jsonStatistics   `json:"stats,omitempty"`
+}
+
+// jsonAggregateReport is the root object of the json report format when several stacks are analyzed
+type jsonAggregateReport struct {
+	SchemaVersion string        `json:"schemaVersion"`
+	AnalysisTime  time.Time     `json:"analysisTime"`
+	Aggregate     jsonAggregate `json:"aggregate"`
+	Stacks        []jsonReport  `json:"stacks"`
+}
+
+// jsonAggregate holds the counts across all analyzed stacks
+type jsonAggregate struct {
+	TotalStacks      int         `json:"totalStacks"`
+	StacksWithErrors int         `json:"stacksWithErrors"`
+	TotalErrors      int         `json:"totalErrors"`
+	Ignored          int         `json:"ignored"`
+	Categories       []jsonCount `json:"categories"`
+	ErrorCodes       []jsonCount `json:"errorCodes"`
+	Services         []jsonCount `json:"services"`
+}
+
+// jsonCount is the number of errors sharing a category or error code
+type jsonCount struct {
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+}
+
+// jsonRootCause holds the most likely root cause of the failure
+type jsonRootCause struct {
+	LogicalResourceId string `json:"logicalResourceId"`
+	ResourceType      string `json:"resourceType"`
+	Text              string `json:"text"`
+}
+
+// jsonCause is a failed resource ranked by how likely it caused the failure
+type jsonCause struct {
+	Rank                  int       `json:"rank"`
+	LogicalResourceId     string    `json:"logicalResourceId"`
+	ResourceType          string    `json:"resourceType"`
+	ResourceStatus        string    `json:"resourceStatus"`
+	Timestamp             time.Time `json:"timestamp"`
+	Category              string    `json:"category"`
+	CorrelationConfidence string    `json:"correlationConfidence,omitempty"`
+	Score                 int       `json:"score"`
+	Reasons               []string  `json:"reasons"`
+}
+
+// jsonIdentity holds the account, region and principal the analysis ran as
+type jsonIdentity struct {
+	AccountID string `json:"accountId"`
+	Region    string `json:"region"`
+	Principal string `json:"principal"`
+}
+
+// jsonStackStatus holds the status of the stack when it was analyzed
+type jsonStackStatus struct {
+	Status     string `json:"status"`
+	Reason     string `json:"reason,omitempty"`
+	InProgress bool   `json:"inProgress"`
+}
+
+// jsonProduct holds the Service Catalog provisioned product the stack was analyzed as
+type jsonProduct struct {
+	Id                       string   `json:"id"`
+	Name                     string   `json:"name,omitempty"`
+	Status                   string   `json:"status"`
+	StatusMessage            string   `json:"statusMessage,omitempty"`
+	ProductId                string   `json:"productId"`
+	ProductName              string   `json:"productName,omitempty"`
+	ProvisioningArtifactId   string   `json:"provisioningArtifactId"`
+	ProvisioningArtifactName string   `json:"provisioningArtifactName,omitempty"`
+	RecordId                 string   `json:"recordId,omitempty"`
+	RecordErrors             []string `json:"recordErrors,omitempty"`
+}
+
+// jsonExplanation holds the plain-English narrative of a root cause
+type jsonExplanation struct {
+	LogicalResourceId string `json:"logicalResourceId"`
+	Text              string `json:"text"`
+}
+
+// jsonStackContext holds the parameters and outputs of the stack
+type jsonStackContext struct {
+	Parameters []jsonStackParameter `json:"parameters"`
+	Outputs    []jsonStackOutput    `json:"outputs"`
+}
+
+// jsonStackParameter is a parameter of the stack; the values of NoEcho parameters are omitted
+type jsonStackParameter struct {
+	Key           string `json:"key"`
+	Value         string `json:"value,omitempty"`
+	ResolvedValue string `json:"resolvedValue,omitempty"`
+	NoEcho        bool   `json:"noEcho,omitempty"`
+}
+
+// jsonStackOutput is an output of the stack
+type jsonStackOutput struct {
+	Key         string `json:"key"`
+	Value       string `json:"value"`
+	Description string `json:"description,omitempty"`
+	ExportName  string `json:"exportName,omitempty"`
+}
+
+// jsonTemplateDiff holds the changes between the previously deployed and the failed template
+type jsonTemplateDiff struct {
+	ChangeSetId string `json:"changeSetId,omitempty"`
+	Diff        string `json:"diff"`
+}
+
+// jsonSummary holds the error counts
+type jsonSummary struct {
+	TotalErrors              int         `json:"totalErrors"`
+	GeneralServiceExceptions int         `json:"generalServiceExceptions"`
+	WithCloudTrailDetails    int         `json:"withCloudTrailDetails"`
+	Ignored                  int         `json:"ignored"`
+	SafeToRetry              bool        `json:"safeToRetry"`
+	Services                 []jsonCount `json:"services"`
+	Health                   *jsonHealth `json:"health,omitempty"`
+}
+
+// jsonHealth summarizes the stack events of the analyzed operation
+type jsonHealth struct {
+	Operation         string `json:"operation,omitempty"`
+	Score             int    `json:"score"`
+	Events            int    `json:"events"`
+	Failures          int    `json:"failures"`
+	Retries           int    `json:"retries"`
+	Rollback          bool   `json:"rollback"`
+	LongestResource   string `json:"longestResource,omitempty"`
+	LongestDurationMs int64  `json:"longestDurationMs,omitempty"`
+}
+
+// jsonError is a single correlated error
+type jsonError struct {
+	Timestamp                 time.Time           `json:"timestamp"`
+	LogicalResourceId         string              `json:"logicalResourceId"`
+	ResourceType              string              `json:"resourceType"`
+	ResourceStatus            string              `json:"resourceStatus"`
+	ResourceStatusReason      string              `json:"resourceStatusReason"`
+	EventId                   string              `json:"eventId"`
+	IsGeneralServiceException bool                `json:"isGeneralServiceException"`
+	Category                  string              `json:"category"`
+	ErrorCode                 string              `json:"errorCode,omitempty"`
+	RequestID                 string              `json:"requestId,omitempty"`
+	Retryable                 bool                `json:"retryable"`
+	DetailedMessage           string              `json:"detailedMessage,omitempty"`
+	CloudTrail                *jsonCloudTrailInfo `json:"cloudTrail,omitempty"`
+	Rule                      string              `json:"rule,omitempty"`
+	Owner                     string              `json:"owner,omitempty"`
+	Severity                  string              `json:"severity,omitempty"`
+}
+
+// jsonCloudTrailInfo holds the CloudTrail event correlated with an error
+type jsonCloudTrailInfo struct {
+	EventTime    time.Time `json:"eventTime"`
+	EventName    string    `json:"eventName"`
+	EventSource  string    `json:"eventSource"`
+	ErrorCode    string    `json:"errorCode,omitempty"`
+	ErrorMessage string    `json:"errorMessage,omitempty"`
+	EventID      string    `json:"eventId,omitempty"`
+	RequestID    string    `json:"requestId,omitempty"`
+
+	// CorrelationWindowMs is the time window the event matched in, if it was widened beyond the default
+	CorrelationWindowMs int64 `json:"correlationWindowMs,omitempty"`
+
+	// Retries summarizes the identical failed calls, if the call failed more than once
+	Retries *jsonRetries `json:"retries,omitempty"`
+
+	// Evidence lists the signals tying the event to the error, the strongest first
+	Evidence []jsonSignal `json:"evidence"`
+}
+
+// jsonSignal is a piece of evidence tying a CloudTrail event to an error
+type jsonSignal struct {
+	Signal   string `json:"signal"`
+	Value    string `json:"value,omitempty"`
+	OffsetMs *int64 `json:"offsetMs,omitempty"`
+}
+
+// jsonRetries is a call the resource provider retried with the same error
+type jsonRetries struct {
+	Count     int       `json:"count"`
+	FirstTime time.Time `json:"firstTime"`
+	LastTime  time.Time `json:"lastTime"`
+}
+
+// jsonIgnored is an error excluded by an ignore rule or the baseline
+type jsonIgnored struct {
+	Error   jsonError `json:"error"`
+	Comment string    `json:"comment,omitempty"`
+}
+
+// jsonFinding is a recognized failure pattern
+type jsonFinding struct {
+	Pattern           string   `json:"pattern"`
+	LogicalResourceId string   `json:"logicalResourceId"`
+	Title             string   `json:"title"`
+	Explanation       string   `json:"explanation"`
+	Evidence          []string `json:"evidence"`
+	Suggestion        string   `json:"suggestion"`
+	Transient         bool     `json:"transient"`
+}
+
+// jsonWarning is a condition that makes the analysis less reliable
+type jsonWarning struct {
+	Kind              string `json:"kind"`
+	LogicalResourceId string `json:"logicalResourceId,omitempty"`
+	Message           string `json:"message"`
+}
+
+// jsonMetadata records where and how the stack was analyzed
+type jsonMetadata struct {
+	Partition          string            `json:"partition,omitempty"`
+	Region             string            `json:"region,omitempty"`
+	AccountID          string            `json:"accountId,omitempty"`
+	ToolVersion        string            `json:"toolVersion"`
+	DurationMs         int64             `json:"durationMs"`
+	Sources            []string          `json:"sources"`
+	StackEventsScanned int               `json:"stackEventsScanned"`
+	CloudTrail         jsonTrailCoverage `json:"cloudTrail"`
+}
+
+// jsonTrailCoverage describes which CloudTrail events the analysis looked at
+type jsonTrailCoverage struct {
+	Calls         int          `json:"calls"`
+	EventsScanned int          `json:"eventsScanned"`
+	Windows       []jsonWindow `json:"windows"`
+}
+
+// jsonWindow is a time range CloudTrail was queried in
+type jsonWindow struct {
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+}
+
+// jsonStatistics holds the performance statistics
+type jsonStatistics struct {
+	Phases                 []jsonPhase `json:"phases"`
+	TotalDurationMs        int64       `json:"totalDurationMs"`
+	StackEventsScanned     int         `json:"stackEventsScanned"`
+	CloudTrailCalls        int         `json:"cloudTrailCalls"`
+	CloudTrailEventsParsed int         `json:"cloudTrailEventsParsed"`
+}
+
+// jsonPhase is the duration of an analysis phase
+type jsonPhase struct {
+	Name       string `json:"name"`
+	DurationMs int64  `json:"durationMs"`
+}
+
+// FormatJSON formats analysis results as a JSON document following JSONSchema()
+func FormatJSON(analysis *analyzer.StackAnalysis) (string, error) {
+	return newRenderer(Options{}).jsonDocument(analysis)
+}
+
+// jsonDocument formats analysis results as a JSON document; statistics are included with ShowStats
+func (r *renderer) jsonDocument(analysis *analyzer.StackAnalysis) (string, error) {
+	if analysis == nil {
+		return "", fmt.Errorf("no analysis results available")
+	}
+
+	return encodeJSON(r.jsonReport(analysis))
+}
+
+// jsonAggregateDocument formats the analyses of several stacks as a JSON document
+func (r *renderer) jsonAggregateDocument(analyses []*analyzer.StackAnalysis, summary aggregate.Summary) (string, error) {
+	report := jsonAggregateReport{
+		SchemaVersion: JSONSchemaVersion,
+		AnalysisTime:  latestAnalysisTime(analyses).UTC(),
+		Aggregate: jsonAggregate{
+			TotalStacks:      summary.TotalStacks,
+			StacksWithErrors: summary.StacksWithErrors,
+			TotalErrors:      summary.TotalErrors,
+			Ignored:          summary.Ignored,
+			Categories:       toJSONCounts(summary.Categories),
+			ErrorCodes:       toJSONCounts(summary.ErrorCodes),
+			Services:         toJSONCounts(summary.Services),
+		},
+		Stacks: []jsonReport{},
+	}
+
+	for _, analysis := range analyses {
+		report.Stacks = append(report.Stacks, r.jsonReport(analysis))
+	}
+
+	return encodeJSON(report)
+}
+
+// jsonReport converts a stack analysis to its JSON representation
+func (r *renderer) jsonReport(analysis *analyzer.StackAnalysis) jsonReport {
+	report := jsonReport{
+		SchemaVersion: JSONSchemaVersion,
+		StackName:     analysis.StackName,
+		AnalysisTime:  analysis.AnalysisTime.UTC(),
+		Partial:       analysis.Partial,
+		Summary: jsonSummary{
+			TotalErrors:              len(analysis.Errors),
+			GeneralServiceExceptions: analysis.GeneralErrors,
+			WithCloudTrailDetails:    analysis.DetailedErrors,
+			Ignored:                  len(analysis.Ignored),
+			SafeToRetry:              classify.SafeToRetry(analysis),
+			Services:                 toJSONCounts(aggregate.ByService(analysis.Errors)),
+		},
+		Errors:   []jsonError{},
+		Ignored:  []jsonIgnored{},
+		Findings: []jsonFinding{},
+	}
+
+	if health := analysis.Health; health != nil {
+		report.Summary.Health = &jsonHealth{
+			Operation:         health.Operation,
+			Score:             health.Score,
+			Events:            health.Events,
+			Failures:          health.Failures,
+			Retries:           health.Retries,
+			Rollback:          health.Rollback,
+			LongestResource:   health.LongestResource,
+			LongestDurationMs: health.LongestDuration.Milliseconds(),
+		}
+	}
+
+	candidates := rootcause.Rank(analysis)
+	if len(candidates) > 0 {
+		report.RootCause = &jsonRootCause{
+			LogicalResourceId: candidates[0].Error.StackError.LogicalResourceId,
+			ResourceType:      candidates[0].Error.StackError.ResourceType,
+			Text:              explain.Error(candidates[0].Error, candidates[0].Finding),
+		}
+	}
+	for i, candidate := range candidates {
+		stackErr := candidate.Error.StackError
+		report.Causes = append(report.Causes, jsonCause{
+			Rank:                  i + 1,
+			LogicalResourceId:     stackErr.LogicalResourceId,
+			ResourceType:          stackErr.ResourceType,
+			ResourceStatus:        stackErr.ResourceStatus,
+			Timestamp:             stackErr.Timestamp,
+			Category:              candidate.Category,
+			CorrelationConfidence: candidate.Confidence,
+			Score:                 candidate.Score,
+			Reasons:               candidate.Reasons,
+		})
+	}
+
+	if identity := analysis.Identity; identity != nil {
+		report.Identity = &jsonIdentity{
+			AccountID: identity.AccountID,
+			Region:    identity.Region,
+			Principal: identity.Principal,
+		}
+	}
+
+	if metadata := analysis.Metadata; metadata != nil {
+		report.Metadata = toJSONMetadata(metadata, analysis.Stats)
+	}
+
+	if status := analysis.StackStatus; status != nil {
+		report.StackStatus = &jsonStackStatus{Status: status.Status, Reason: status.Reason, InProgress: status.InProgress}
+	}
+
+	if product := analysis.ProvisionedProduct; product != nil {
+		report.Product = &jsonProduct{
+			Id:                       product.Id,
+			Name:                     product.Name,
+			Status:                   product.Status,
+			StatusMessage:            product.StatusMessage,
+			ProductId:                product.ProductId,
+			ProductName:              product.ProductName,
+			ProvisioningArtifactId:   product.ProvisioningArtifactId,
+			ProvisioningArtifactName: product.ProvisioningArtifactName,
+			RecordId:                 product.RecordId,
+			RecordErrors:             product.RecordErrors,
+		}
+	}
+
+	for _, err := range analysis.Errors {
+		report.Errors = append(report.Errors, toJSONError(err))
+	}
+
+	for _, item := range analysis.Ignored {
+		report.Ignored = append(report.Ignored, jsonIgnored{Error: toJSONError(item.Error), Comment: item.Comment})
+	}
+
+	for _, finding := range analysis.Findings {
+		evidence := finding.Evidence
+		if evidence == nil {
+			evidence = []string{}
+		}
+		report.Findings = append(report.Findings, jsonFinding{
+			Pattern:           finding.Pattern,
+			LogicalResourceId: finding.LogicalResourceId,
+			Title:             finding.Title,
+			Explanation:       finding.Explanation,
+			Evidence:          evidence,
+			Suggestion:        finding.Suggestion,
+			Transient:         finding.Transient,
+		})
+	}
+
+	for _, warning := range analysis.Warnings {
+		report.Warnings = append(report.Warnings, jsonWarning{
+			Kind:              warning.Kind,
+			LogicalResourceId: warning.LogicalResourceId,
+			Message:           warning.Message,
+		})
+	}
+
+	if diff := analysis.TemplateDiff; diff != nil {
+		report.TemplateDiff = &jsonTemplateDiff{ChangeSetId: diff.ChangeSetId, Diff: diff.Diff}
+	}
+
+	if stackContext := analysis.StackContext; stackContext != nil {
+		report.StackContext = &jsonStackContext{Parameters: []jsonStackParameter{}, Outputs: []jsonStackOutput{}}
+		for _, parameter := range stackContext.Parameters {
+			report.StackContext.Parameters = append(report.StackContext.Parameters, jsonStackParameter{
+				Key:           parameter.Key,
+				Value:         parameter.Value,
+				ResolvedValue: parameter.ResolvedValue,
+				NoEcho:        parameter.NoEcho,
+			})
+		}
+		for _, output := range stackContext.Outputs {
+			report.StackContext.Outputs = append(report.StackContext.Outputs, jsonStackOutput{
+				Key:         output.Key,
+				Value:       output.Value,
+				Description: output.Description,
+				ExportName:  output.ExportName,
+			})
+		}
+	}
+	report.AISummary = analysis.AISummary
+
+	if r.opts.Explain {
+		for _, explanation := range explain.Explain(analysis) {
+			report.Explanations = append(report.Explanations, jsonExplanation{
+				LogicalResourceId: explanation.LogicalResourceId,
+				Text:              explanation.Text,
+			})
+		}
+	}
+
+	if r.opts.ShowStats && analysis.Stats != nil {
+		stats := &jsonStatistics{
+			Phases:                 []jsonPhase{},
+			TotalDurationMs:        analysis.Stats.TotalDuration().Milliseconds(),
+			StackEventsScanned:     analysis.Stats.StackEventsScanned,
+			CloudTrailCalls:        analysis.Stats.CloudTrailCalls,
+			CloudTrailEventsParsed: analysis.Stats.CloudTrailEventsParsed,
+		}
+		for _, phase := range analysis.Stats.Phases {
+			stats.Phases = append(stats.Phases, jsonPhase{Name: phase.Name, DurationMs: phase.Duration.Milliseconds()})
+		}
+		report.Stats = stats
+	}
+
+	return report
+}
+
+// encodeJSON encodes a JSON report as an indented document
+func encodeJSON(report interface{}) (string, error) {
+	var sb strings.Builder
+	encoder := json.NewEncoder(&sb)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(report); err != nil {
+		return "", fmt.Errorf("failed to encode JSON report: %w", err)
+	}
+
+	return sb.String(), nil
+}
+
+// toJSONError converts a correlated error to its JSON representation
+func toJSONError(err analyzer.CorrelatedError) jsonError {
+	result := jsonError{
+		Timestamp:                 err.StackError.Timestamp.UTC(),
+		LogicalResourceId:         err.StackError.LogicalResourceId,
+		ResourceType:              err.StackError.ResourceType,
+		ResourceStatus:            err.StackError.ResourceStatus,
+		ResourceStatusReason:      err.StackError.ResourceStatusReason,
+		EventId:                   err.StackError.EventId,
+		IsGeneralServiceException: err.StackError.IsGeneralServiceException,
+		Category:                  classify.Category(err),
+		ErrorCode:                 classify.ErrorCode(err),
+		RequestID:                 classify.RequestID(err),
+		Retryable:                 classify.Retryable(err),
+		DetailedMessage:           err.DetailedMessage,
+	}
+
+	if classification := err.Classification; classification != nil {
+		result.Rule = classification.Rule
+		result.Owner = classification.Owner
+		result.Severity = classification.Severity
+	}
+
+	if event := err.CloudTrailEvent; event != nil {
+		result.CloudTrail = &jsonCloudTrailInfo{
+			EventTime:    event.EventTime.UTC(),
+			EventName:    event.EventName,
+			EventSource:  event.EventSource,
+			ErrorCode:    event.ErrorCode,
+			ErrorMessage: event.ErrorMessage,
+			EventID:      event.EventID,
+			RequestID:    event.RequestID,
+		}
+		if err.CorrelationWindow > correlator.DefaultTimeWindow {
+			result.CloudTrail.CorrelationWindowMs = err.CorrelationWindow.Milliseconds()
+		}
+		if retries := err.Retries; retries != nil {
+			result.CloudTrail.Retries = &jsonRetries{
+				Count:     retries.Count,
+				FirstTime: retries.First.UTC(),
+				LastTime:  retries.Last.UTC(),
+			}
+		}
+		for _, signal := range correlator.Evidence(err) {
+			jsonSignal := jsonSignal{Signal: signal.Kind, Value: signal.Value}
+			if signal.Kind == correlator.SignalTime {
+				offset := signal.Offset.Milliseconds()
+				jsonSignal.OffsetMs = &offset
+			}
+			result.CloudTrail.Evidence = append(result.CloudTrail.Evidence, jsonSignal)
+		}
+	}
+
+	return result
+}
+
+// toJSONMetadata converts the metadata and the CloudTrail coverage of an analysis to their JSON representation
+func toJSONMetadata(metadata *analyzer.AnalysisMetadata, stats *analyzer.AnalysisStats) *jsonMetadata {
+	result := &jsonMetadata{
+		Partition:   metadata.Partition,
+		Region:      metadata.Region,
+		AccountID:   metadata.AccountID,
+		ToolVersion: metadata.ToolVersion,
+		DurationMs:  metadata.Duration.Milliseconds(),
+		Sources:     metadata.Sources,
+		CloudTrail:  jsonTrailCoverage{Windows: []jsonWindow{}},
+	}
+	if result.Sources == nil {
+		result.Sources = []string{}
+	}
+	if stats != nil {
+		result.StackEventsScanned = stats.StackEventsScanned
+		result.CloudTrail.Calls = stats.CloudTrailCalls
+		result.CloudTrail.EventsScanned = stats.CloudTrailEventsParsed
+		for _, window := range stats.CloudTrailWindows {
+			result.CloudTrail.Windows = append(result.CloudTrail.Windows, jsonWindow{Start: window.Start.UTC(), End: window.End.UTC()})
+		}
+	}
+	return result
+}
+
+// toJSONCounts converts aggregated counts to their JSON representation
+func toJSONCounts(counts []aggregate.Count) []jsonCount {
+	result := make([]jsonCount, 0, len(counts))
+	for _, count := range counts {
+		result = append(result, jsonCount{Name: count.Name, Count: count.Count})
+	}
+	return result
+}
@@ -0,0 +1,177 @@
+package formatter
+
+import (
+	"encoding/xml"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"cfn-root-cause/analyzer"
+)
+
+// junitTestSuites is the root element of a JUnit XML report
+type junitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Name     string           `xml:"name,attr"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Skipped  int              `xml:"skipped,attr,omitempty"`
+	Suites   []junitTestSuite `xml:"testsuite"`
+}
+
+// junitTestSuite groups the test cases of one stack
+type junitTestSuite struct {
+	Name       string          `xml:"name,attr"`
+	Tests      int             `xml:"tests,attr"`
+	Failures   int             `xml:"failures,attr"`
+	Skipped    int             `xml:"skipped,attr,omitempty"`
+	Timestamp  string          `xml:"timestamp,attr"`
+	Properties []junitProperty `xml:"properties>property,omitempty"`
+	Cases      []junitTestCase `xml:"testcase"`
+}
+
+// junitProperty is a name/value pair attached to a test suite
+type junitProperty struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:"value,attr"`
+}
+
+// junitTestCase is a single failed or ignored resource
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	File      string        `xml:"file,attr,omitempty"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+	Skipped   *junitSkipped `xml:"skipped,omitempty"`
+}
+
+// junitFailure holds the failure details of a test case
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// junitSkipped marks a test case whose error is ignored
+type junitSkipped struct {
+	Message string `xml:"message,attr,omitempty"`
+}
+
+// FormatJUnitReport formats analysis results as a JUnit XML report.
+// Each error becomes a failed test case named after the logical resource, which
+// GitLab and most other CI systems render in their test report views.
+func FormatJUnitReport(analysis *analyzer.StackAnalysis, templatePath string) (string, error) {
+	return newRenderer(Options{TemplatePath: templatePath}).junitReport(analysis)
+}
+
+// junitReport formats analysis results as a JUnit XML report
+func (r *renderer) junitReport(analyses ...*analyzer.StackAnalysis) (string, error) {
+	report := junitTestSuites{Name: "CloudFormation Error Analysis"}
+
+	for _, analysis := range analyses {
+		if analysis == nil {
+			continue
+		}
+
+		suite := r.junitSuite(analysis)
+		report.Suites = append(report.Suites, suite)
+		report.Tests += suite.Tests
+		report.Failures += suite.Failures
+		report.Skipped += suite.Skipped
+	}
+
+	data, err := xml.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("failed to encode JUnit report: %w", err)
+	}
+
+	return xml.Header + string(data) + "\n", nil
+}
+
+// junitMetadata returns the metadata of an analysis as test suite properties; none if it is unknown
+func junitMetadata(analysis *analyzer.StackAnalysis) []junitProperty {
+	metadata := analysis.Metadata
+	if metadata == nil {
+		return nil
+	}
+
+	properties := []junitProperty{
+		{Name: "partition", Value: metadata.Partition},
+		{Name: "region", Value: metadata.Region},
+		{Name: "accountId", Value: metadata.AccountID},
+		{Name: "toolVersion", Value: metadata.ToolVersion},
+		{Name: "analysisDurationMs", Value: strconv.FormatInt(metadata.Duration.Milliseconds(), 10)},
+		{Name: "sources", Value: strings.Join(metadata.Sources, ",")},
+	}
+	if stats := analysis.Stats; stats != nil {
+		properties = append(properties,
+			junitProperty{Name: "stackEventsScanned", Value: strconv.Itoa(stats.StackEventsScanned)},
+			junitProperty{Name: "cloudTrailCalls", Value: strconv.Itoa(stats.CloudTrailCalls)},
+			junitProperty{Name: "cloudTrailEventsScanned", Value: strconv.Itoa(stats.CloudTrailEventsParsed)})
+		var windows []string
+		for _, window := range stats.CloudTrailWindows {
+			windows = append(windows, window.Start.UTC().Format(time.RFC3339)+"/"+window.End.UTC().Format(time.RFC3339))
+		}
+		if len(windows) > 0 {
+			properties = append(properties, junitProperty{Name: "cloudTrailWindows", Value: strings.Join(windows, ",")})
+		}
+	}
+
+	// Unknown values are left out
+	kept := properties[:0]
+	for _, property := range properties {
+		if property.Value != "" {
+			kept = append(kept, property)
+		}
+	}
+	return kept
+}
+
+// junitSuite converts the errors of one stack to a test suite
+func (r *renderer) junitSuite(analysis *analyzer.StackAnalysis) junitTestSuite {
+	suite := junitTestSuite{
+		Name:      analysis.StackName,
+		Timestamp: analysis.AnalysisTime.UTC().Format("2006-01-02T15:04:05"),
+	}
+
+	if text := rootCauseText(analysis); text != "" {
+		suite.Properties = append(suite.Properties, junitProperty{Name: "mostLikelyRootCause", Value: text})
+	}
+	suite.Properties = append(suite.Properties, junitMetadata(analysis)...)
+
+	for _, err := range analysis.Errors {
+		suite.Cases = append(suite.Cases, junitTestCase{
+			Name:      fmt.Sprintf("%s %s", err.StackError.LogicalResourceId, err.StackError.ResourceStatus),
+			ClassName: err.StackError.ResourceType,
+			File:      r.opts.TemplatePath,
+			Failure: &junitFailure{
+				Message: errorDescription(err),
+				Type:    err.StackError.ResourceStatus,
+				Text:    r.errorPlainText(err) + r.resourceFindings(analysis.Findings, err.StackError.LogicalResourceId),
+			},
+		})
+	}
+
+	suite.Failures = len(suite.Cases)
+
+	// Ignored errors are reported as skipped test cases
+	for _, item := range analysis.Ignored {
+		err := item.Error
+		message := errorDescription(err)
+		if item.Comment != "" {
+			message = item.Comment + ": " + message
+		}
+		suite.Cases = append(suite.Cases, junitTestCase{
+			Name:      fmt.Sprintf("%s %s", err.StackError.LogicalResourceId, err.StackError.ResourceStatus),
+			ClassName: err.StackError.ResourceType,
+			File:      r.opts.TemplatePath,
+			Skipped:   &junitSkipped{Message: message},
+		})
+	}
+
+	suite.Tests = len(suite.Cases)
+	suite.Skipped = len(analysis.Ignored)
+
+	return suite
+}
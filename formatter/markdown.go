@@ -0,0 +1,591 @@
+package formatter
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"cfn-root-cause/aggregate"
+	"cfn-root-cause/analyzer"
+	"cfn-root-cause/classify"
+	"cfn-root-cause/explain"
+	"cfn-root-cause/rootcause"
+)
+
+// markdownEscaper escapes the characters Markdown would interpret in report values, so names,
+// messages and redaction placeholders such as <account-1> render literally
+var markdownEscaper = strings.NewReplacer(
+	`\`, `\\`, "`", "\\`", "*", `\*`, "_", `\_`, "[", `\[`, "]", `\]`,
+	"<", `\<`, ">", `\>`, "|", `\|`, "#", `\#`,
+)
+
+// markdownText escapes a value for Markdown and joins its lines, so it fits in a list item or
+// table cell
+func markdownText(text string) string {
+	return markdownEscaper.Replace(strings.Join(strings.Fields(text), " "))
+}
+
+// markdownAnchors names the link targets of a report. Errors and their CloudTrail details are
+// numbered like the errors, and each failed resource has one timeline entry. The prefix keeps the
+// anchors of the stacks of an aggregate report apart.
+type markdownAnchors struct {
+	prefix    string
+	resources map[string]int
+}
+
+// newMarkdownAnchors numbers the timeline entries of the failed resources in the order of their
+// first error
+func newMarkdownAnchors(prefix string, errors []analyzer.CorrelatedError) markdownAnchors {
+	anchors := markdownAnchors{prefix: prefix, resources: make(map[string]int)}
+	for _, err := range errors {
+		if _, ok := anchors.resources[err.StackError.LogicalResourceId]; !ok {
+			anchors.resources[err.StackError.LogicalResourceId] = len(anchors.resources) + 1
+		}
+	}
+	return anchors
+}
+
+// report is the anchor of the report title
+func (a markdownAnchors) report() string {
+	return a.prefix + "report"
+}
+
+// error is the anchor of the error with the given number
+func (a markdownAnchors) error(number int) string {
+	return a.prefix + "error-" + strconv.Itoa(number)
+}
+
+// cloudTrail is the anchor of the CloudTrail details of the error with the given number
+func (a markdownAnchors) cloudTrail(number int) string {
+	return a.prefix + "cloudtrail-" + strconv.Itoa(number)
+}
+
+// timeline is the anchor of the timeline entry of a resource, "" if the resource did not fail
+func (a markdownAnchors) timeline(logicalResourceId string) string {
+	number, ok := a.resources[logicalResourceId]
+	if !ok {
+		return ""
+	}
+	return a.prefix + "timeline-" + strconv.Itoa(number)
+}
+
+// markdownLink formats a link to an anchor of the report; the text is escaped by the caller
+func markdownLink(text, anchor string) string {
+	return fmt.Sprintf("[%s](#%s)", text, anchor)
+}
+
+// markdownTarget formats the anchor a heading or list item is linked by
+func markdownTarget(anchor string) string {
+	return fmt.Sprintf(`<a id="%s"></a>`, anchor)
+}
+
+// markdownField formats a labeled value as a list item; the value is escaped by the caller
+func (r *renderer) markdownField(key, value string) string {
+	return fmt.Sprintf("- **%s:** %s\n", markdownText(r.msg.get(key)), value)
+}
+
+// markdown formats the report as Markdown. Each error links to its CloudTrail details and to the
+// timeline entry of its resource, which link back to the error, so large reports can be navigated.
+func (r *renderer) markdown(analysis *analyzer.StackAnalysis) string {
+	return r.markdownReport(analysis, "")
+}
+
+// markdownReport formats the Markdown report of a stack with anchors starting with the prefix
+func (r *renderer) markdownReport(analysis *analyzer.StackAnalysis, prefix string) string {
+	if analysis == nil {
+		return r.msg.get(msgNoResults) + "\n"
+	}
+
+	var sb strings.Builder
+	sections := r.opts.Sections
+	anchors := newMarkdownAnchors(prefix, analysis.Errors)
+	showErrors := !sections.HideErrors && len(analysis.Errors) > 0
+	showCloudTrail := showErrors && !sections.HideCloudTrailDetails
+
+	sb.WriteString(fmt.Sprintf("# %s%s: %s\n", markdownTarget(anchors.report()),
+		markdownText(r.msg.get(msgReportTitle)), markdownText(analysis.StackName)))
+
+	// Most likely root cause, linked to its error
+	if !sections.HideRootCause {
+		if candidate := rootcause.MostLikely(analysis); candidate != nil {
+			text := markdownText(explain.Error(candidate.Error, candidate.Finding))
+			if number := errorNumber(analysis.Errors, candidate.Error); showErrors && number > 0 {
+				text += " (" + markdownLink(markdownText(r.msg.format(msgErrorLink, number)), anchors.error(number)) + ")"
+			}
+			sb.WriteString(fmt.Sprintf("\n**%s:** %s\n", markdownText(r.msg.get(msgRootCause)), text))
+		}
+	}
+
+	if !sections.HideHeader {
+		sb.WriteString(r.markdownHeader(analysis))
+	}
+
+	if !sections.HideSummary {
+		sb.WriteString(fmt.Sprintf("\n## %s\n\n", markdownText(r.msg.get(msgSummary))))
+		sb.WriteString(r.markdownField(msgTotalErrors, strconv.Itoa(len(analysis.Errors))))
+		sb.WriteString(r.markdownField(msgGeneralServiceExceptions, strconv.Itoa(analysis.GeneralErrors)))
+		sb.WriteString(r.markdownField(msgWithCloudTrail, strconv.Itoa(analysis.DetailedErrors)))
+		if len(analysis.Ignored) > 0 {
+			sb.WriteString(r.markdownField(msgIgnored, strconv.Itoa(len(analysis.Ignored))))
+		}
+		if health := analysis.Health; health != nil {
+			sb.WriteString(r.markdownField(msgHealth, fmt.Sprintf("%d/100 (%s)", health.Score, markdownText(r.healthDetails(health)))))
+		}
+		if classify.SafeToRetry(analysis) {
+			sb.WriteString("\n" + markdownText(r.msg.get(msgSafeToRetry)) + "\n")
+		}
+	}
+
+	if !sections.HideWarnings && len(analysis.Warnings) > 0 {
+		sb.WriteString(fmt.Sprintf("\n## %s\n\n", markdownText(r.msg.get(msgWarnings))))
+		for _, warning := range analysis.Warnings {
+			sb.WriteString("- " + markdownText(warning.Message) + "\n")
+		}
+	}
+
+	if !sections.HideRootCause {
+		sb.WriteString(r.markdownProbableCauses(analysis, anchors, showErrors))
+	}
+
+	if r.opts.Explain {
+		if explanations := explain.Explain(analysis); len(explanations) > 0 {
+			sb.WriteString(fmt.Sprintf("\n## %s\n", markdownText(r.msg.get(msgExplanation))))
+			for _, explanation := range explanations {
+				sb.WriteString("\n" + markdownText(explanation.Text) + "\n")
+			}
+		}
+	}
+
+	if !sections.HideErrors {
+		if len(analysis.Errors) == 0 {
+			sb.WriteString("\n" + markdownText(r.msg.get(msgNoErrors)) + "\n")
+		} else {
+			sb.WriteString(fmt.Sprintf("\n## %s\n", markdownText(r.msg.get(msgErrors))))
+			for i, err := range analysis.Errors {
+				sb.WriteString(r.markdownError(err, i+1, anchors, showCloudTrail))
+			}
+		}
+
+		if len(analysis.Ignored) > 0 {
+			sb.WriteString(fmt.Sprintf("\n## %s\n\n", markdownText(r.msg.get(msgIgnoredErrors))))
+			for _, item := range analysis.Ignored {
+				line := fmt.Sprintf("%s %s: %s", item.Error.StackError.LogicalResourceId,
+					item.Error.StackError.ResourceStatus, errorDescription(item.Error))
+				if item.Comment != "" {
+					line += fmt.Sprintf(" (%s)", item.Comment)
+				}
+				sb.WriteString("- " + markdownText(line) + "\n")
+			}
+		}
+	}
+
+	if !sections.HideFindings && len(analysis.Findings) > 0 {
+		sb.WriteString(fmt.Sprintf("\n## %s\n", markdownText(r.msg.get(msgFindings))))
+		for i, finding := range analysis.Findings {
+			sb.WriteString(r.markdownFinding(finding, i+1, anchors, showErrors))
+		}
+	}
+
+	if showCloudTrail && analysis.DetailedErrors > 0 {
+		sb.WriteString(fmt.Sprintf("\n## %s\n", markdownText(r.msg.get(msgCloudTrailDetails))))
+		for i, err := range analysis.Errors {
+			if err.CloudTrailEvent != nil {
+				sb.WriteString(r.markdownCloudTrail(err, i+1, anchors))
+			}
+		}
+	}
+
+	if showErrors {
+		sb.WriteString(r.markdownTimeline(analysis.Errors, anchors, showCloudTrail))
+	}
+
+	if !sections.HideStackContext && analysis.StackContext != nil {
+		sb.WriteString(r.markdownStackContext(analysis.StackContext))
+	}
+
+	if !sections.HideTemplateDiff && analysis.TemplateDiff != nil {
+		sb.WriteString(r.markdownTemplateDiff(analysis.TemplateDiff))
+	}
+
+	if !sections.HideAISummary && analysis.AISummary != "" {
+		sb.WriteString(fmt.Sprintf("\n## %s\n", markdownText(r.msg.get(msgAISummary))))
+		for _, paragraph := range strings.Split(strings.TrimSpace(analysis.AISummary), "\n") {
+			if paragraph = markdownText(paragraph); paragraph != "" {
+				sb.WriteString("\n" + paragraph + "\n")
+			}
+		}
+	}
+
+	// The statistics are aligned with spaces, so they keep their layout in a code block
+	if r.opts.ShowStats && analysis.Stats != nil {
+		sb.WriteString("\n```" + r.stats(analysis.Stats) + "```\n")
+	}
+
+	return sb.String()
+}
+
+// errorNumber returns the number of an error in the report, 0 if it is not listed
+func errorNumber(errors []analyzer.CorrelatedError, err analyzer.CorrelatedError) int {
+	for i, listed := range errors {
+		if listed.StackError.LogicalResourceId == err.StackError.LogicalResourceId &&
+			listed.StackError.Timestamp.Equal(err.StackError.Timestamp) {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+// markdownHeader formats the stack, its status, identity and provisioned product as a list
+func (r *renderer) markdownHeader(analysis *analyzer.StackAnalysis) string {
+	var sb strings.Builder
+
+	sb.WriteString("\n")
+	sb.WriteString(r.markdownField(msgStackName, markdownText(analysis.StackName)))
+	sb.WriteString(r.markdownField(msgAnalysisTime, formatTimestamp(analysis.AnalysisTime)))
+	if status := analysis.StackStatus; status != nil {
+		sb.WriteString(r.markdownField(msgStackStatus, markdownText(status.Status)))
+		if status.Reason != "" {
+			sb.WriteString(r.markdownField(msgStatusReason, markdownText(status.Reason)))
+		}
+	}
+	if identity := analysis.Identity; identity != nil {
+		sb.WriteString(r.markdownField(msgAccount, markdownText(identity.AccountID)))
+		sb.WriteString(r.markdownField(msgRegion, markdownText(identity.Region)))
+		sb.WriteString(r.markdownField(msgPrincipal, markdownText(identity.Principal)))
+	}
+	if product := analysis.ProvisionedProduct; product != nil {
+		sb.WriteString(r.markdownField(msgProvisionedProduct, markdownText(nameAndId(product.Name, product.Id))))
+		sb.WriteString(r.markdownField(msgProduct, markdownText(nameAndId(product.ProductName, product.ProductId))))
+		sb.WriteString(r.markdownField(msgProvisioningArtifact,
+			markdownText(nameAndId(product.ProvisioningArtifactName, product.ProvisioningArtifactId))))
+		status := product.Status
+		if product.StatusMessage != "" {
+			status += " - " + product.StatusMessage
+		}
+		sb.WriteString(r.markdownField(msgProductStatus, markdownText(status)))
+		for _, recordErr := range product.RecordErrors {
+			sb.WriteString(r.markdownField(msgRecordError, markdownText(recordErr)))
+		}
+	}
+	if analysis.StackStatus != nil && analysis.StackStatus.InProgress {
+		sb.WriteString("\n> **" + markdownText(r.msg.get(msgInProgress)) + "**\n")
+	}
+	if analysis.Partial {
+		sb.WriteString("\n> **" + markdownText(r.msg.get(msgPartial)) + "**\n")
+	}
+
+	return sb.String()
+}
+
+// markdownProbableCauses formats the ranked root causes as a numbered list linked to their errors,
+// or returns "" unless several root causes are plausible
+func (r *renderer) markdownProbableCauses(analysis *analyzer.StackAnalysis, anchors markdownAnchors, linkErrors bool) string {
+	candidates := rootcause.Rank(analysis)
+	if len(candidates) < 2 {
+		return ""
+	}
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("\n## %s\n\n", markdownText(r.msg.get(msgProbableCauses))))
+	for i, candidate := range candidates {
+		stackErr := candidate.Error.StackError
+		resource := markdownText(stackErr.LogicalResourceId)
+		if number := errorNumber(analysis.Errors, candidate.Error); linkErrors && number > 0 {
+			resource = markdownLink(resource, anchors.error(number))
+		}
+		sb.WriteString(r.msg.format(msgProbableCauseHeading, i+1, resource, markdownText(stackErr.ResourceType),
+			markdownText(stackErr.ResourceStatus), formatTimestamp(stackErr.Timestamp), candidate.Score) + "\n")
+		for _, reason := range candidate.Reasons {
+			sb.WriteString("   - " + markdownText(reason) + "\n")
+		}
+	}
+
+	return sb.String()
+}
+
+// markdownError formats an error with links to its CloudTrail details and resource timeline
+func (r *renderer) markdownError(correlated analyzer.CorrelatedError, number int, anchors markdownAnchors, linkCloudTrail bool) string {
+	var sb strings.Builder
+	err := correlated.StackError
+
+	sb.WriteString(fmt.Sprintf("\n### %s%s %s\n\n", markdownTarget(anchors.error(number)),
+		markdownText(r.msg.format(msgErrorHeading, number)), markdownText(err.LogicalResourceId)))
+	sb.WriteString(r.markdownField(msgTimestamp, formatTimestamp(err.Timestamp)))
+	sb.WriteString(r.markdownField(msgResource, markdownText(err.LogicalResourceId)))
+	sb.WriteString(r.markdownField(msgResourceType, markdownText(err.ResourceType)))
+	sb.WriteString(r.markdownField(msgStatus, markdownText(err.ResourceStatus)))
+	if err.ResourceStatusReason != "" {
+		sb.WriteString(r.markdownField(msgReason, markdownText(err.ResourceStatusReason)))
+	}
+	if requestID := classify.RequestID(correlated); requestID != "" {
+		sb.WriteString(r.markdownField(msgRequestID, markdownText(requestID)))
+	}
+	if classify.Retryable(correlated) {
+		sb.WriteString(r.markdownField(msgRetryable, markdownText(r.msg.get(msgRetryableYes))))
+	}
+	if correlated.DetailedMessage != "" {
+		key := msgDetailedMessage
+		if correlated.CloudTrailEvent != nil {
+			key = msgDetailedMessageCloudTrail
+		}
+		sb.WriteString(r.markdownField(key, markdownText(correlated.DetailedMessage)))
+	}
+	if err.IsGeneralServiceException {
+		sb.WriteString("\n> " + markdownText(r.msg.get(msgGeneralServiceExceptionHint)) + "\n")
+	}
+
+	links := []string{markdownLink(markdownText(r.msg.get(msgTimeline)), anchors.timeline(err.LogicalResourceId))}
+	if linkCloudTrail && correlated.CloudTrailEvent != nil {
+		links = append([]string{markdownLink(markdownText(r.msg.get(msgCloudTrailDetails)), anchors.cloudTrail(number))}, links...)
+	}
+	sb.WriteString("\n" + strings.Join(links, " · ") + "\n")
+
+	return sb.String()
+}
+
+// markdownFinding formats a finding, linking its resource to the timeline entry of the resource
+func (r *renderer) markdownFinding(finding analyzer.Finding, number int, anchors markdownAnchors, linkTimeline bool) string {
+	var sb strings.Builder
+
+	sb.WriteString(fmt.Sprintf("\n### %s %s\n\n", markdownText(r.msg.format(msgFindingHeading, number)), markdownText(finding.Title)))
+	if finding.LogicalResourceId != "" {
+		resource := markdownText(finding.LogicalResourceId)
+		if anchor := anchors.timeline(finding.LogicalResourceId); linkTimeline && anchor != "" {
+			resource = markdownLink(resource, anchor)
+		}
+		sb.WriteString(r.markdownField(msgResource, resource) + "\n")
+	}
+	sb.WriteString(markdownText(finding.Explanation) + "\n")
+
+	if len(finding.Evidence) > 0 {
+		sb.WriteString(fmt.Sprintf("\n**%s:**\n\n", markdownText(r.msg.get(msgEvidence))))
+		for _, evidence := range finding.Evidence {
+			sb.WriteString("- " + markdownText(evidence) + "\n")
+		}
+	}
+
+	if finding.Suggestion != "" {
+		sb.WriteString(fmt.Sprintf("\n**%s:** %s\n", markdownText(r.msg.get(msgSuggestion)), markdownText(finding.Suggestion)))
+	}
+
+	return sb.String()
+}
+
+// markdownCloudTrail formats the CloudTrail details of an error with links back to the error and
+// to the timeline entry of its resource
+func (r *renderer) markdownCloudTrail(err analyzer.CorrelatedError, number int, anchors markdownAnchors) string {
+	var sb strings.Builder
+	event := err.CloudTrailEvent
+
+	sb.WriteString(fmt.Sprintf("\n### %s%s %s\n\n", markdownTarget(anchors.cloudTrail(number)),
+		markdownText(r.msg.format(msgErrorHeading, number)), markdownText(event.EventName)))
+	sb.WriteString(r.markdownField(msgEventTime, formatTimestamp(event.EventTime)))
+	sb.WriteString(r.markdownField(msgEventName, markdownText(event.EventName)))
+	sb.WriteString(r.markdownField(msgEventSource, markdownText(event.EventSource)))
+	if event.EventID != "" {
+		sb.WriteString(r.markdownField(msgEventID, markdownText(event.EventID)))
+	}
+	if event.RequestID != "" {
+		sb.WriteString(r.markdownField(msgRequestID, markdownText(event.RequestID)))
+	}
+	if event.ErrorCode != "" {
+		sb.WriteString(r.markdownField(msgErrorCode, markdownText(event.ErrorCode)))
+	}
+	if event.ErrorMessage != "" {
+		sb.WriteString(r.markdownField(msgErrorMsg, markdownText(event.ErrorMessage)))
+	}
+	if widened := r.widenedWindow(err.CorrelationWindow); widened != "" {
+		sb.WriteString(r.markdownField(msgTimeWindow, markdownText(widened)))
+	}
+	if err.Retries != nil {
+		sb.WriteString(r.markdownField(msgRetries, markdownText(r.retries(err.Retries))))
+	}
+	sb.WriteString(r.markdownField(msgEvidence, markdownText(r.evidence(err))))
+
+	sb.WriteString("\n" + markdownLink(markdownText(r.msg.format(msgErrorLink, number)), anchors.error(number)) +
+		" · " + markdownLink(markdownText(r.msg.get(msgTimeline)), anchors.timeline(err.StackError.LogicalResourceId)) + "\n")
+
+	return sb.String()
+}
+
+// markdownTimelineEntry is a row of the timeline of a resource: a failure reported by
+// CloudFormation or the CloudTrail event correlated with it
+type markdownTimelineEntry struct {
+	time    time.Time
+	event   string
+	details string
+}
+
+// markdownTimeline formats the failures of each resource and their CloudTrail events in time
+// order, each linked to its error or CloudTrail details
+func (r *renderer) markdownTimeline(errors []analyzer.CorrelatedError, anchors markdownAnchors, linkCloudTrail bool) string {
+	var sb strings.Builder
+
+	sb.WriteString(fmt.Sprintf("\n## %s\n", markdownText(r.msg.get(msgTimeline))))
+
+	var resources []string
+	entries := make(map[string][]markdownTimelineEntry)
+	types := make(map[string]string)
+	for i, err := range errors {
+		logicalId := err.StackError.LogicalResourceId
+		if _, ok := entries[logicalId]; !ok {
+			resources = append(resources, logicalId)
+			types[logicalId] = err.StackError.ResourceType
+		}
+		entries[logicalId] = append(entries[logicalId], markdownTimelineEntry{
+			time:    err.StackError.Timestamp,
+			event:   markdownLink(markdownText(err.StackError.ResourceStatus), anchors.error(i+1)),
+			details: markdownText(err.StackError.ResourceStatusReason),
+		})
+		if event := err.CloudTrailEvent; linkCloudTrail && event != nil {
+			entries[logicalId] = append(entries[logicalId], markdownTimelineEntry{
+				time:    event.EventTime,
+				event:   markdownLink(markdownText(event.EventName+" ("+event.EventSource+")"), anchors.cloudTrail(i+1)),
+				details: markdownText(trailError(*event)),
+			})
+		}
+	}
+
+	for _, logicalId := range resources {
+		sb.WriteString(fmt.Sprintf("\n### %s%s (%s)\n\n", markdownTarget(anchors.timeline(logicalId)),
+			markdownText(logicalId), markdownText(types[logicalId])))
+		sb.WriteString(fmt.Sprintf("| %s | %s | %s |\n| --- | --- | --- |\n",
+			markdownText(r.msg.get(msgTimestamp)), markdownText(r.msg.get(msgEvent)), markdownText(r.msg.get(msgDetails))))
+
+		resourceEntries := entries[logicalId]
+		sort.SliceStable(resourceEntries, func(i, j int) bool {
+			return resourceEntries[i].time.Before(resourceEntries[j].time)
+		})
+		for _, entry := range resourceEntries {
+			sb.WriteString(fmt.Sprintf("| %s | %s | %s |\n", formatTimestamp(entry.time), entry.event, entry.details))
+		}
+	}
+
+	return sb.String()
+}
+
+// trailError describes the error of a CloudTrail event by its code and message
+func trailError(event analyzer.CloudTrailEvent) string {
+	if event.ErrorCode != "" && event.ErrorMessage != "" {
+		return event.ErrorCode + ": " + event.ErrorMessage
+	}
+	return event.ErrorCode + event.ErrorMessage
+}
+
+// markdownStackContext formats the parameters and outputs of the stack as tables. NoEcho
+// parameter values are never shown.
+func (r *renderer) markdownStackContext(stackContext *analyzer.StackContext) string {
+	var sb strings.Builder
+
+	sb.WriteString(fmt.Sprintf("\n## %s\n", markdownText(r.msg.get(msgStackContext))))
+
+	sb.WriteString(fmt.Sprintf("\n### %s\n\n", markdownText(r.msg.get(msgParameters))))
+	if len(stackContext.Parameters) == 0 {
+		sb.WriteString(markdownText(r.msg.get(msgNone)) + "\n")
+	} else {
+		sb.WriteString("| | |\n| --- | --- |\n")
+	}
+	for _, parameter := range stackContext.Parameters {
+		value := parameter.Value
+		switch {
+		case parameter.NoEcho:
+			value = r.msg.get(msgNoEchoValue)
+		case parameter.ResolvedValue != "":
+			value += " (" + r.msg.format(msgResolvedValue, parameter.ResolvedValue) + ")"
+		}
+		sb.WriteString(fmt.Sprintf("| %s | %s |\n", markdownText(parameter.Key), markdownText(value)))
+	}
+
+	sb.WriteString(fmt.Sprintf("\n### %s\n\n", markdownText(r.msg.get(msgOutputs))))
+	if len(stackContext.Outputs) == 0 {
+		sb.WriteString(markdownText(r.msg.get(msgNone)) + "\n")
+	} else {
+		sb.WriteString("| | |\n| --- | --- |\n")
+	}
+	for _, output := range stackContext.Outputs {
+		value := output.Value
+		if output.ExportName != "" {
+			value += " (" + r.msg.format(msgExportName, output.ExportName) + ")"
+		}
+		sb.WriteString(fmt.Sprintf("| %s | %s |\n", markdownText(output.Key), markdownText(value)))
+	}
+
+	return sb.String()
+}
+
+// markdownTemplateDiff formats the template changes of the deployment as a diff code block
+func (r *renderer) markdownTemplateDiff(diff *analyzer.TemplateDiff) string {
+	var sb strings.Builder
+
+	sb.WriteString(fmt.Sprintf("\n## %s\n\n", markdownText(r.msg.get(msgTemplateChanges))))
+	if diff.ChangeSetId != "" {
+		sb.WriteString(r.markdownField(msgChangeSet, markdownText(diff.ChangeSetId)) + "\n")
+	}
+
+	if diff.Diff == "" {
+		sb.WriteString(markdownText(r.msg.get(msgTemplateUnchanged)) + "\n")
+		return sb.String()
+	}
+
+	// A fence longer than any backtick run of the diff keeps the block closed
+	fence := "```"
+	for strings.Contains(diff.Diff, fence) {
+		fence += "`"
+	}
+	sb.WriteString(fence + "diff\n" + strings.TrimSuffix(diff.Diff, "\n") + "\n" + fence + "\n")
+
+	return sb.String()
+}
+
+// markdownAggregate formats the totals of several stacks, linked to the reports of the stacks,
+// followed by those reports
+func (r *renderer) markdownAggregate(analyses []*analyzer.StackAnalysis, summary aggregate.Summary) string {
+	var sb strings.Builder
+
+	sb.WriteString(fmt.Sprintf("# %s\n\n", markdownText(r.msg.get(msgAggregateTitle))))
+	sb.WriteString(r.markdownField(msgStacksAnalyzed, strconv.Itoa(summary.TotalStacks)))
+	sb.WriteString(r.markdownField(msgStacksWithErrors, strconv.Itoa(summary.StacksWithErrors)))
+	sb.WriteString(r.markdownField(msgTotalErrors, strconv.Itoa(summary.TotalErrors)))
+	if summary.Ignored > 0 {
+		sb.WriteString(r.markdownField(msgIgnored, strconv.Itoa(summary.Ignored)))
+	}
+
+	for _, counts := range []struct {
+		key    string
+		counts []aggregate.Count
+	}{
+		{msgFailuresByCategory, summary.Categories},
+		{msgErrorsByService, summary.Services},
+		{msgCommonErrorCodes, summary.ErrorCodes},
+	} {
+		if len(counts.counts) == 0 {
+			continue
+		}
+		sb.WriteString(fmt.Sprintf("\n## %s\n\n", markdownText(r.msg.get(counts.key))))
+		for _, count := range counts.counts {
+			sb.WriteString(fmt.Sprintf("- %s: %d\n", markdownText(count.Name), count.Count))
+		}
+	}
+
+	sb.WriteString(fmt.Sprintf("\n## %s\n\n", markdownText(r.msg.get(msgStacks))))
+	for i, analysis := range analyses {
+		anchors := markdownAnchors{prefix: markdownStackPrefix(i)}
+		sb.WriteString(fmt.Sprintf("- %s: %s\n", markdownLink(markdownText(analysis.StackName), anchors.report()),
+			markdownText(r.msg.format(msgStackErrorCount, len(analysis.Errors)))))
+	}
+
+	for i, analysis := range analyses {
+		sb.WriteString("\n")
+		sb.WriteString(r.markdownReport(analysis, markdownStackPrefix(i)))
+	}
+
+	return sb.String()
+}
+
+// markdownStackPrefix is the anchor prefix of the report of the stack with the given index in an
+// aggregate report
+func markdownStackPrefix(index int) string {
+	return fmt.Sprintf("stack-%d-", index+1)
+}
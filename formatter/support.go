@@ -0,0 +1,136 @@
+package formatter
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"cfn-root-cause/analyzer"
+	"cfn-root-cause/classify"
+)
+
+// redactedValue replaces credentials and secrets in support case text
+const redactedValue = "[REDACTED]"
+
+// secretPatterns match credentials and secrets that must not be pasted into a support case.
+// Each pattern keeps its first group, e.g. the name of a "password=..." assignment.
+var secretPatterns = []*regexp.Regexp{
+	// Access key IDs of long-term and temporary credentials
+	regexp.MustCompile(`()\b(?:AKIA|ASIA)[A-Z0-9]{16}\b`),
+	// Values assigned to secret-like keys, e.g. "password": "...", SecretAccessKey=...
+	regexp.MustCompile(`(?i)((?:secret|password|passwd|pwd|session[_-]?token|access[_-]?token|auth[_-]?token|api[_-]?key|private[_-]?key|credential)[A-Za-z_-]*["']?\s*[:=]\s*["']?)[^\s"',;}]+`),
+	// Session tokens and secret access keys outside of an assignment
+	regexp.MustCompile(`()\b[A-Za-z0-9/+]{100,}={0,2}`),
+	regexp.MustCompile(`()\b[A-Za-z0-9/+]{40}\b`),
+}
+
+// Redact replaces credentials and secrets such as access keys, session tokens and
+// password assignments in text with a placeholder
+func Redact(text string) string {
+	for _, pattern := range secretPatterns {
+		text = pattern.ReplaceAllString(text, "${1}"+redactedValue)
+	}
+	return text
+}
+
+// FormatSupportText formats the analyses as a ready-to-paste AWS Support case body: the stack,
+// account and region, followed by each failed resource with the failing API call, its request ID,
+// error code and the exact CloudTrail message. Credentials and secrets are redacted.
+// Support cases are written in English regardless of the report language.
+func FormatSupportText(analyses []*analyzer.StackAnalysis) string {
+	var sb strings.Builder
+
+	names := make([]string, 0, len(analyses))
+	for _, analysis := range analyses {
+		names = append(names, analysis.StackName)
+	}
+	sb.WriteString(fmt.Sprintf("Subject: CloudFormation deployment failure in %s\n\n", strings.Join(names, ", ")))
+	sb.WriteString("Hello AWS Support,\n\n")
+	sb.WriteString("a CloudFormation stack operation failed. Please help us identify the cause of the failed API call(s) below.\n")
+
+	for _, analysis := range analyses {
+		sb.WriteString(supportStack(analysis))
+	}
+
+	sb.WriteString("\nThank you.\n")
+
+	return Redact(sb.String())
+}
+
+// supportStack formats the stack details and failed resources of one analysis
+func supportStack(analysis *analyzer.StackAnalysis) string {
+	var sb strings.Builder
+
+	sb.WriteString("\n")
+	sb.WriteString(fmt.Sprintf("Stack name:  %s\n", analysis.StackName))
+	if analysis.StackId != "" {
+		sb.WriteString(fmt.Sprintf("Stack ARN:   %s\n", analysis.StackId))
+	}
+	if status := analysis.StackStatus; status != nil {
+		sb.WriteString(fmt.Sprintf("Status:      %s\n", status.Status))
+		if status.Reason != "" {
+			sb.WriteString(fmt.Sprintf("Reason:      %s\n", status.Reason))
+		}
+	}
+	if identity := analysis.Identity; identity != nil {
+		sb.WriteString(fmt.Sprintf("Account:     %s\n", identity.AccountID))
+		sb.WriteString(fmt.Sprintf("Region:      %s\n", identity.Region))
+	}
+	if product := analysis.ProvisionedProduct; product != nil {
+		sb.WriteString(fmt.Sprintf("Provisioned product:   %s\n", nameAndId(product.Name, product.Id)))
+		sb.WriteString(fmt.Sprintf("Product:               %s\n", nameAndId(product.ProductName, product.ProductId)))
+		sb.WriteString(fmt.Sprintf("Provisioning artifact: %s\n", nameAndId(product.ProvisioningArtifactName, product.ProvisioningArtifactId)))
+		if product.RecordId != "" {
+			sb.WriteString(fmt.Sprintf("Provisioning record:   %s\n", product.RecordId))
+		}
+	}
+	sb.WriteString(fmt.Sprintf("Analyzed at: %s\n", formatTimestamp(analysis.AnalysisTime)))
+
+	if len(analysis.Errors) == 0 {
+		sb.WriteString("\nNo failed resources were found.\n")
+		return sb.String()
+	}
+
+	if text := rootCauseText(analysis); text != "" {
+		sb.WriteString(fmt.Sprintf("\nMost likely root cause:\n  %s\n", text))
+	}
+
+	sb.WriteString("\nFailed resources:\n")
+	for i, err := range analysis.Errors {
+		sb.WriteString(supportError(i+1, err))
+	}
+
+	return sb.String()
+}
+
+// supportError formats a failed resource with the details AWS Support asks for
+func supportError(number int, err analyzer.CorrelatedError) string {
+	var sb strings.Builder
+
+	stackErr := err.StackError
+	sb.WriteString(fmt.Sprintf("\n%d. %s (%s) %s at %s\n", number, stackErr.LogicalResourceId,
+		stackErr.ResourceType, stackErr.ResourceStatus, formatTimestamp(stackErr.Timestamp)))
+	if stackErr.ResourceStatusReason != "" {
+		sb.WriteString(fmt.Sprintf("   Status reason:      %s\n", stackErr.ResourceStatusReason))
+	}
+	sb.WriteString(fmt.Sprintf("   Stack event ID:     %s\n", stackErr.EventId))
+
+	if event := err.CloudTrailEvent; event != nil {
+		sb.WriteString(fmt.Sprintf("   Failing API call:   %s (%s) at %s\n", event.EventName, event.EventSource,
+			formatTimestamp(event.EventTime)))
+		if event.EventID != "" {
+			sb.WriteString(fmt.Sprintf("   CloudTrail event:   %s\n", event.EventID))
+		}
+	}
+	if requestID := classify.RequestID(err); requestID != "" {
+		sb.WriteString(fmt.Sprintf("   Request ID:         %s\n", requestID))
+	}
+	if errorCode := classify.ErrorCode(err); errorCode != "" {
+		sb.WriteString(fmt.Sprintf("   Error code:         %s\n", errorCode))
+	}
+	if event := err.CloudTrailEvent; event != nil && event.ErrorMessage != "" {
+		sb.WriteString(fmt.Sprintf("   CloudTrail message: %s\n", event.ErrorMessage))
+	}
+
+	return sb.String()
+}
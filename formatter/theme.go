@@ -0,0 +1,167 @@
+package formatter
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Theme holds the ANSI escape sequences used by the colored text format
+type Theme struct {
+	// Error colors failed statuses, error headings and error codes
+	Error string
+
+	// Warning colors GeneralServiceException hints and finding headings
+	Warning string
+
+	// Highlight colors stack and resource names
+	Highlight string
+
+	// Heading colors section headings
+	Heading string
+
+	// Reset ends a colored sequence
+	Reset string
+}
+
+// DefaultThemeName is the theme used when no theme is configured
+const DefaultThemeName = "default"
+
+// themes contains the built-in color themes
+var themes = map[string]Theme{
+	DefaultThemeName: {
+		Error:     colorRed,
+		Warning:   colorYellow,
+		Highlight: colorCyan,
+		Heading:   colorBold,
+		Reset:     colorReset,
+	},
+	"dark": {
+		Error:     "\033[91m",
+		Warning:   "\033[93m",
+		Highlight: "\033[96m",
+		Heading:   colorBold,
+		Reset:     colorReset,
+	},
+	"light": {
+		Error:     "\033[31m",
+		Warning:   "\033[35m",
+		Highlight: "\033[34m",
+		Heading:   colorBold,
+		Reset:     colorReset,
+	},
+	"high-contrast": {
+		Error:     "\033[1;97;41m",
+		Warning:   "\033[1;30;103m",
+		Highlight: "\033[1;4m",
+		Heading:   "\033[1;4m",
+		Reset:     colorReset,
+	},
+	// colorblind uses an orange/blue palette that stays distinguishable with red-green deficiencies
+	"colorblind": {
+		Error:     "\033[38;5;208m",
+		Warning:   "\033[38;5;220m",
+		Highlight: "\033[38;5;33m",
+		Heading:   colorBold,
+		Reset:     colorReset,
+	},
+	"none": {},
+}
+
+// colorNames maps color names usable in custom palettes to SGR parameters
+var colorNames = map[string]string{
+	"black":          "30",
+	"red":            "31",
+	"green":          "32",
+	"yellow":         "33",
+	"blue":           "34",
+	"magenta":        "35",
+	"cyan":           "36",
+	"white":          "37",
+	"gray":           "90",
+	"bright-red":     "91",
+	"bright-green":   "92",
+	"bright-yellow":  "93",
+	"bright-blue":    "94",
+	"bright-magenta": "95",
+	"bright-cyan":    "96",
+	"bright-white":   "97",
+	"bold":           "1",
+	"underline":      "4",
+	"reverse":        "7",
+}
+
+// sgrParamsRegex matches raw SGR parameter lists such as "1;31" or "38;5;208"
+var sgrParamsRegex = regexp.MustCompile(`^[0-9]{1,3}(;[0-9]{1,3})*$`)
+
+// ThemeNames returns the names of all built-in themes
+func ThemeNames() []string {
+	names := make([]string, 0, len(themes))
+	for name := range themes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// LookupTheme returns the built-in theme with the given name
+func LookupTheme(name string) (Theme, bool) {
+	theme, ok := themes[name]
+	return theme, ok
+}
+
+// WithColors returns a copy of the theme with the given roles overridden.
+// Roles are error, warning, highlight and heading; values are space-separated
+// color names (e.g. "bold bright-red") or raw SGR parameters (e.g. "38;5;208").
+func (t Theme) WithColors(colors map[string]string) (Theme, error) {
+	for role, spec := range colors {
+		sequence, err := parseColorSpec(spec)
+		if err != nil {
+			return t, fmt.Errorf("invalid color for '%s': %w", role, err)
+		}
+
+		switch strings.ToLower(role) {
+		case "error":
+			t.Error = sequence
+		case "warning":
+			t.Warning = sequence
+		case "highlight":
+			t.Highlight = sequence
+		case "heading":
+			t.Heading = sequence
+		default:
+			return t, fmt.Errorf("unknown color role '%s': must be one of error, warning, highlight, heading", role)
+		}
+	}
+
+	if t.Reset == "" {
+		t.Reset = colorReset
+	}
+
+	return t, nil
+}
+
+// parseColorSpec converts a color specification into an ANSI escape sequence.
+// An empty specification disables coloring for the role.
+func parseColorSpec(spec string) (string, error) {
+	var params []string
+
+	for _, part := range strings.Fields(strings.ToLower(spec)) {
+		if code, ok := colorNames[part]; ok {
+			params = append(params, code)
+			continue
+		}
+		if sgrParamsRegex.MatchString(part) {
+			params = append(params, part)
+			continue
+		}
+		return "", fmt.Errorf("unknown color '%s'", part)
+	}
+
+	if len(params) == 0 {
+		return "", nil
+	}
+
+	return "\033[" + strings.Join(params, ";") + "m", nil
+}
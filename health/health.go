@@ -0,0 +1,151 @@
+// Package health looks up AWS Health issues of the services involved in a failed deployment
+package health
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"cfn-root-cause/analyzer"
+	"cfn-root-cause/awserrors"
+	"cfn-root-cause/metrics"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/health"
+	"github.com/aws/aws-sdk-go-v2/service/health/types"
+	"github.com/aws/smithy-go"
+)
+
+// Limits of the AWS Health API
+const (
+	maxServicesPerFilter = 10
+	maxArnsPerDetails    = 10
+)
+
+// lookback is how long before a deployment an issue may have started and still be ongoing
+const lookback = 24 * time.Hour
+
+// ErrSubscriptionRequired indicates that the account cannot use the AWS Health API
+var ErrSubscriptionRequired = errors.New("the AWS Health API requires a Business, Enterprise On-Ramp or Enterprise Support plan")
+
+// Client wraps the AWS Health client
+type Client struct {
+	health *health.Client
+}
+
+// NewClientWithConfig creates a new AWS Health client with a custom AWS config. AWS Health is a
+// global service, so the client uses the endpoint of the partition of the configured region.
+func NewClientWithConfig(cfg aws.Config) *Client {
+	cfg = cfg.Copy()
+	switch {
+	case strings.HasPrefix(cfg.Region, "cn-"):
+		cfg.Region = "cn-northwest-1"
+	case strings.HasPrefix(cfg.Region, "us-gov-"):
+		cfg.Region = "us-gov-west-1"
+	default:
+		cfg.Region = "us-east-1"
+	}
+	return &Client{health: health.NewFromConfig(cfg)}
+}
+
+// ServiceIssues returns the issues AWS Health reported for the services in the region, or for the
+// services globally, that were open at some time between start and end, the oldest first.
+// Up to 10 services are checked. Accounts without AWS Health API access get ErrSubscriptionRequired.
+func (c *Client) ServiceIssues(ctx context.Context, region string, services []string, start, end time.Time) ([]analyzer.ServiceEvent, error) {
+	if len(services) > maxServicesPerFilter {
+		services = services[:maxServicesPerFilter]
+	}
+
+	filter := &types.EventFilter{
+		Services:            services,
+		Regions:             []string{region, "global"},
+		EventTypeCategories: []types.EventTypeCategory{types.EventTypeCategoryIssue},
+		StartTimes:          []types.DateTimeRange{{From: aws.Time(start.Add(-lookback)), To: aws.Time(end)}},
+	}
+
+	var events []analyzer.ServiceEvent
+	var nextToken *string
+	for {
+		output, err := c.health.DescribeEvents(ctx, &health.DescribeEventsInput{Filter: filter, NextToken: nextToken})
+		if err != nil {
+			return nil, describeError(err, "failed to describe AWS Health events")
+		}
+
+		for _, event := range output.Events {
+			serviceEvent := analyzer.ServiceEvent{
+				Arn:           aws.ToString(event.Arn),
+				Service:       aws.ToString(event.Service),
+				EventTypeCode: aws.ToString(event.EventTypeCode),
+				Region:        aws.ToString(event.Region),
+				Status:        string(event.StatusCode),
+				StartTime:     aws.ToTime(event.StartTime),
+				EndTime:       aws.ToTime(event.EndTime),
+			}
+			// Issues resolved before the deployment started did not affect it
+			if !serviceEvent.EndTime.IsZero() && serviceEvent.EndTime.Before(start) {
+				continue
+			}
+			events = append(events, serviceEvent)
+		}
+
+		if output.NextToken == nil {
+			break
+		}
+		nextToken = output.NextToken
+	}
+
+	if err := c.describeDetails(ctx, events); err != nil {
+		return events, err
+	}
+
+	// Events are returned newest first
+	for i, j := 0, len(events)-1; i < j; i, j = i+1, j-1 {
+		events[i], events[j] = events[j], events[i]
+	}
+	return events, nil
+}
+
+// describeDetails adds the latest description to each event
+func (c *Client) describeDetails(ctx context.Context, events []analyzer.ServiceEvent) error {
+	index := make(map[string]int, len(events))
+	for i, event := range events {
+		index[event.Arn] = i
+	}
+
+	for start := 0; start < len(events); start += maxArnsPerDetails {
+		end := min(start+maxArnsPerDetails, len(events))
+		arns := make([]string, 0, end-start)
+		for _, event := range events[start:end] {
+			arns = append(arns, event.Arn)
+		}
+
+		output, err := c.health.DescribeEventDetails(ctx, &health.DescribeEventDetailsInput{EventArns: arns})
+		if err != nil {
+			return describeError(err, "failed to describe AWS Health event details")
+		}
+		for _, details := range output.SuccessfulSet {
+			if details.Event == nil || details.EventDescription == nil {
+				continue
+			}
+			if i, ok := index[aws.ToString(details.Event.Arn)]; ok {
+				events[i].Description = aws.ToString(details.EventDescription.LatestDescription)
+			}
+		}
+	}
+	return nil
+}
+
+// describeError converts a failed AWS Health call into an error, recognizing accounts without
+// AWS Health API access
+func describeError(err error, message string) error {
+	if awserrors.IsThrottlingError(err) {
+		metrics.ThrottlesTotal.Inc("AWS Health")
+	}
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) && apiErr.ErrorCode() == "SubscriptionRequiredException" {
+		return ErrSubscriptionRequired
+	}
+	return fmt.Errorf("%s: %w", message, awserrors.ParseAWSError(err, "AWS Health"))
+}
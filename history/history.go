@@ -0,0 +1,156 @@
+// Package history records the errors of past analyses so failures can be compared over time
+package history
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+
+	"cfn-root-cause/analyzer"
+	"cfn-root-cause/classify"
+)
+
+// fileName is the name of the history file inside the config directory
+const fileName = "history.jsonl"
+
+// Record is one analysis of a stack as stored in the history file
+type Record struct {
+	Time      time.Time `json:"time"`
+	StackName string    `json:"stackName"`
+	StackId   string    `json:"stackId,omitempty"`
+	AccountID string    `json:"accountId,omitempty"`
+	Region    string    `json:"region,omitempty"`
+	Errors    []Entry   `json:"errors"`
+
+	// LastEventId is the newest stack event of the analysis, if its events were stored for an
+	// incremental analysis
+	LastEventId string `json:"lastEventId,omitempty"`
+
+	// Operation identifies the analyzed stack operation and HealthScore rates it; both are omitted
+	// if the analysis had no stack events
+	Operation   string `json:"operation,omitempty"`
+	HealthScore *int   `json:"healthScore,omitempty"`
+}
+
+// Entry is an error found by an analysis
+type Entry struct {
+	Timestamp         time.Time `json:"timestamp"`
+	EventId           string    `json:"eventId"`
+	LogicalResourceId string    `json:"logicalResourceId"`
+	ResourceType      string    `json:"resourceType"`
+	ResourceStatus    string    `json:"resourceStatus"`
+	Category          string    `json:"category"`
+	ErrorCode         string    `json:"errorCode,omitempty"`
+}
+
+// DefaultPath returns the default history file location,
+// e.g. ~/.config/cfnrc/history.jsonl on Linux
+func DefaultPath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to determine config directory: %w", err)
+	}
+	return filepath.Join(dir, "cfnrc", fileName), nil
+}
+
+// NewRecord creates a history record of the errors currently contained in the analysis
+func NewRecord(analysis *analyzer.StackAnalysis) Record {
+	record := Record{
+		Time:        analysis.AnalysisTime.UTC(),
+		StackName:   analysis.StackName,
+		StackId:     analysis.StackId,
+		Errors:      make([]Entry, 0, len(analysis.Errors)),
+		LastEventId: analysis.LastEventId,
+	}
+	if analysis.Identity != nil {
+		record.AccountID = analysis.Identity.AccountID
+		record.Region = analysis.Identity.Region
+	}
+	if health := analysis.Health; health != nil {
+		record.Operation = health.Operation
+		record.HealthScore = &health.Score
+	}
+
+	for _, err := range analysis.Errors {
+		record.Errors = append(record.Errors, Entry{
+			Timestamp:         err.StackError.Timestamp.UTC(),
+			EventId:           err.StackError.EventId,
+			LogicalResourceId: err.StackError.LogicalResourceId,
+			ResourceType:      err.StackError.ResourceType,
+			ResourceStatus:    err.StackError.ResourceStatus,
+			Category:          classify.Category(err),
+			ErrorCode:         classify.ErrorCode(err),
+		})
+	}
+
+	return record
+}
+
+// LastAnalyzedEvent returns the stack ARN and the newest stack event of the latest analysis of the
+// stack whose events were stored; empty if there is none
+func LastAnalyzedEvent(records []Record, stackName string) (stackId, eventId string) {
+	for i := len(records) - 1; i >= 0; i-- {
+		if records[i].StackName == stackName && records[i].LastEventId != "" {
+			return records[i].StackId, records[i].LastEventId
+		}
+	}
+	return "", ""
+}
+
+// Append adds the records to the history file at path, creating the file and its directory if needed
+func Append(path string, records ...Record) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("failed to create history directory: %w", err)
+	}
+
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to open history '%s': %w", path, err)
+	}
+	defer file.Close()
+
+	encoder := json.NewEncoder(file)
+	for _, record := range records {
+		if err := encoder.Encode(record); err != nil {
+			return fmt.Errorf("failed to write history '%s': %w", path, err)
+		}
+	}
+
+	return nil
+}
+
+// Load reads all records of the history file at path. A missing file yields no records.
+func Load(path string) ([]Record, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read history '%s': %w", path, err)
+	}
+	defer file.Close()
+
+	var records []Record
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var record Record
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			return nil, fmt.Errorf("failed to parse history '%s' line %d: %w", path, line, err)
+		}
+		records = append(records, record)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read history '%s': %w", path, err)
+	}
+
+	return records, nil
+}
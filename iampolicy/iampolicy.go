@@ -0,0 +1,238 @@
+// Package iampolicy builds the minimal IAM policy needed to run the analyzer
+package iampolicy
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// policyVersion is the IAM policy language version
+const policyVersion = "2012-10-17"
+
+// CoreActions are needed for every analysis
+var CoreActions = []string{
+	"cloudformation:DescribeStackEvents",
+	"cloudformation:DescribeStacks",
+	"cloudformation:ListStacks",
+	"cloudtrail:LookupEvents",
+}
+
+// Feature is an optional feature that needs additional permissions
+type Feature struct {
+	Name        string
+	Description string
+	Actions     []string
+
+	// Mutating features get their own statement so they can be granted separately
+	Mutating bool
+}
+
+// Features lists the optional features by name
+var Features = []Feature{
+	{
+		Name:        "template-diff",
+		Description: "--template-diff compares the failed and the previous template",
+		Actions:     []string{"cloudformation:GetTemplate"},
+	},
+	{
+		Name:        "remediation",
+		Description: "remediate --allow-mutations continues rollbacks and deletes stacks",
+		Actions:     []string{"cloudformation:ContinueUpdateRollback", "cloudformation:DeleteStack"},
+		Mutating:    true,
+	},
+	{
+		Name:        "registry-types",
+		Description: "diagnostics of failed private and third-party resource types",
+		Actions:     []string{"cloudformation:DescribeType"},
+	},
+	{
+		Name:        "parameter-constraints",
+		Description: "constraints of parameters that failed validation",
+		Actions:     []string{"cloudformation:DescribeChangeSet", "cloudformation:GetTemplate"},
+	},
+	{
+		Name:        "custom-resources",
+		Description: "invocations and logs of the functions of timed out custom resources",
+		Actions:     []string{"cloudformation:GetTemplate", "cloudwatch:GetMetricData", "logs:FilterLogEvents"},
+	},
+	{
+		Name:        "null-handler-messages",
+		Description: "failed calls and handler logs of resources whose handler returned the message \"null\"",
+		Actions:     []string{"cloudtrail:LookupEvents", "cloudformation:DescribeType", "logs:FilterLogEvents"},
+	},
+	{
+		Name:        "cross-stack-exports",
+		Description: "exporting and importing stacks of failed cross-stack references",
+		Actions:     []string{"cloudformation:ListExports", "cloudformation:ListImports"},
+	},
+	{
+		Name:        "stack-policy",
+		Description: "stack policy statements of updates denied by the stack policy",
+		Actions:     []string{"cloudformation:GetStackPolicy"},
+	},
+	{
+		Name:        "ops-center",
+		Description: "--ops-item OpsCenter OpsItems for failed stacks",
+		Actions:     []string{"ssm:CreateOpsItem"},
+	},
+	{
+		Name:        "health",
+		Description: "AWS Health issues of the failed services (Business or Enterprise Support)",
+		Actions:     []string{"health:DescribeEvents", "health:DescribeEventDetails"},
+	},
+	{
+		Name:        "rds-events",
+		Description: "RDS events of failed DB instances, clusters and parameter groups",
+		Actions:     []string{"rds:DescribeEvents"},
+	},
+	{
+		Name:        "lambda-quotas",
+		Description: "Lambda quotas of functions failing on code size or concurrency",
+		Actions:     []string{"lambda:GetAccountSettings"},
+	},
+	{
+		Name:        "bucket-name-conflicts",
+		Description: "owners of the names of S3 buckets that already exist",
+		Actions:     []string{"cloudformation:DescribeStackResources", "s3:ListBucket"},
+	},
+	{
+		Name:        "certificate-validation",
+		Description: "DNS validation records of ACM certificates in Route 53",
+		Actions:     []string{"acm:DescribeCertificate", "route53:ListHostedZones", "route53:ListResourceRecordSets"},
+	},
+	{
+		Name:        "vpc-limits",
+		Description: "VPC resources in use and their quotas for VPC quota failures",
+		Actions: []string{"ec2:DescribeAddresses", "ec2:DescribeNatGateways", "ec2:DescribeSubnets", "ec2:DescribeVpcs",
+			"ec2:DescribeInternetGateways", "ec2:DescribeNetworkInterfaces", "servicequotas:GetServiceQuota"},
+	},
+	{
+		Name:        "resource-state",
+		Description: "current state of resources that did not stabilize, through the Cloud Control API (plus the read permissions of their types)",
+		Actions:     []string{"cloudformation:GetResource"},
+	},
+	{
+		Name:        "resource",
+		Description: "resource subcommand shows the current state of a single resource",
+		Actions:     []string{"cloudformation:DescribeStackResource"},
+	},
+	{
+		Name:        "ai-summary",
+		Description: "--ai-summary with the bedrock provider",
+		Actions:     []string{"bedrock:InvokeModel"},
+	},
+	{
+		Name:        "archive",
+		Description: "archive and --from-archive store and read stack snapshots in S3",
+		Actions:     []string{"s3:GetObject", "s3:ListBucket", "s3:PutObject"},
+	},
+	{
+		Name:        "service-catalog",
+		Description: "--provisioned-product resolves Service Catalog provisioned products to their stacks",
+		Actions: []string{
+			"servicecatalog:DescribeProduct",
+			"servicecatalog:DescribeProvisionedProduct",
+			"servicecatalog:DescribeRecord",
+		},
+	},
+	{
+		Name:        "sinks",
+		Description: "--sink delivers reports to S3 objects and SNS topics",
+		Actions:     []string{"s3:PutObject", "sns:Publish"},
+	},
+	{
+		Name:        "trail-discovery",
+		Description: "check that a trail records the management events of the region",
+		Actions:     []string{"cloudtrail:DescribeTrails", "cloudtrail:GetEventSelectors", "cloudtrail:GetTrailStatus"},
+	},
+	{
+		Name:        "codebuild",
+		Description: "--codebuild locates the stack deployed by the pipeline execution that started the build",
+		Actions:     []string{"codepipeline:ListActionExecutions", "codepipeline:ListPipelineExecutions"},
+	},
+}
+
+// FeatureNames returns the names of all optional features
+func FeatureNames() []string {
+	names := make([]string, 0, len(Features))
+	for _, feature := range Features {
+		names = append(names, feature.Name)
+	}
+	return names
+}
+
+// document is an IAM policy document
+type document struct {
+	Version   string      `json:"Version"`
+	Statement []statement `json:"Statement"`
+}
+
+// statement is a statement of an IAM policy document
+type statement struct {
+	Sid      string   `json:"Sid"`
+	Effect   string   `json:"Effect"`
+	Action   []string `json:"Action"`
+	Resource string   `json:"Resource"`
+}
+
+// Policy returns the IAM policy document granting the core actions and the actions of the named
+// features as indented JSON. Read-only and mutating actions are granted in separate statements.
+func Policy(featureNames []string) (string, error) {
+	readActions := append([]string{}, CoreActions...)
+	var mutatingActions []string
+
+	for _, name := range featureNames {
+		feature, ok := lookup(name)
+		if !ok {
+			return "", fmt.Errorf("unknown feature '%s': must be one of %s", name, strings.Join(FeatureNames(), ", "))
+		}
+		if feature.Mutating {
+			mutatingActions = append(mutatingActions, feature.Actions...)
+		} else {
+			readActions = append(readActions, feature.Actions...)
+		}
+	}
+
+	doc := document{
+		Version:   policyVersion,
+		Statement: []statement{{Sid: "CfnrcReadOnly", Effect: "Allow", Action: unique(readActions), Resource: "*"}},
+	}
+	if len(mutatingActions) > 0 {
+		doc.Statement = append(doc.Statement, statement{
+			Sid: "CfnrcRemediation", Effect: "Allow", Action: unique(mutatingActions), Resource: "*",
+		})
+	}
+
+	data, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("failed to encode IAM policy: %w", err)
+	}
+
+	return string(data) + "\n", nil
+}
+
+// lookup returns the feature with the given name
+func lookup(name string) (Feature, bool) {
+	for _, feature := range Features {
+		if feature.Name == name {
+			return feature, true
+		}
+	}
+	return Feature{}, false
+}
+
+// unique returns the sorted actions without duplicates
+func unique(actions []string) []string {
+	seen := make(map[string]bool, len(actions))
+	var result []string
+	for _, action := range actions {
+		if !seen[action] {
+			seen[action] = true
+			result = append(result, action)
+		}
+	}
+	sort.Strings(result)
+	return result
+}
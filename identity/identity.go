@@ -0,0 +1,40 @@
+// Package identity determines the AWS account, region and principal an analysis runs as
+package identity
+
+import (
+	"context"
+
+	"cfn-root-cause/analyzer"
+	"cfn-root-cause/awserrors"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+)
+
+// Client wraps the AWS STS client
+type Client struct {
+	sts    *sts.Client
+	region string
+}
+
+// NewClientWithConfig creates a new STS client with a custom AWS config
+func NewClientWithConfig(cfg aws.Config) *Client {
+	return &Client{
+		sts:    sts.NewFromConfig(cfg),
+		region: cfg.Region,
+	}
+}
+
+// CallerIdentity returns the account, region and principal of the current credentials
+func (c *Client) CallerIdentity(ctx context.Context) (*analyzer.CallerIdentity, error) {
+	output, err := c.sts.GetCallerIdentity(ctx, &sts.GetCallerIdentityInput{})
+	if err != nil {
+		return nil, awserrors.ParseAWSError(err, "STS")
+	}
+
+	return &analyzer.CallerIdentity{
+		AccountID: aws.ToString(output.Account),
+		Region:    c.region,
+		Principal: aws.ToString(output.Arn),
+	}, nil
+}
@@ -0,0 +1,131 @@
+// Package ignore excludes known acceptable errors from an analysis
+package ignore
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"regexp"
+
+	"cfn-root-cause/analyzer"
+)
+
+// DefaultFileName is the ignore file picked up from the working directory when no file is given
+const DefaultFileName = ".cfnrc-ignore.json"
+
+// Rule ignores errors whose logical resource ID and status reason match the regular expressions.
+// An empty expression matches everything, but a rule needs at least one expression.
+type Rule struct {
+	LogicalId string `json:"logicalId,omitempty"`
+	Reason    string `json:"reason,omitempty"`
+
+	// Comment explains why the error is acceptable and is shown in the report
+	Comment string `json:"comment,omitempty"`
+
+	logicalId *regexp.Regexp
+	reason    *regexp.Regexp
+}
+
+// File is the content of an ignore file
+type File struct {
+	Rules []*Rule `json:"rules"`
+}
+
+// Load reads and compiles the ignore file at path.
+// If path is empty, DefaultFileName is used and a missing file yields no rules.
+// An explicitly given path must exist.
+func Load(path string) ([]*Rule, error) {
+	explicit := path != ""
+	if !explicit {
+		path = DefaultFileName
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if !explicit && errors.Is(err, fs.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read ignore file '%s': %w", path, err)
+	}
+
+	file := &File{}
+	if err := json.Unmarshal(data, file); err != nil {
+		return nil, fmt.Errorf("failed to parse ignore file '%s': %w", path, err)
+	}
+
+	for i, rule := range file.Rules {
+		if err := rule.compile(); err != nil {
+			return nil, fmt.Errorf("invalid rule %d in ignore file '%s': %w", i+1, path, err)
+		}
+	}
+
+	return file.Rules, nil
+}
+
+// compile compiles the regular expressions of the rule
+func (r *Rule) compile() error {
+	if r.LogicalId == "" && r.Reason == "" {
+		return fmt.Errorf("rule must set logicalId, reason or both")
+	}
+
+	var err error
+	if r.logicalId, err = compileOptional(r.LogicalId); err != nil {
+		return fmt.Errorf("invalid logicalId expression: %w", err)
+	}
+	if r.reason, err = compileOptional(r.Reason); err != nil {
+		return fmt.Errorf("invalid reason expression: %w", err)
+	}
+
+	return nil
+}
+
+// Matches reports whether the rule ignores the error.
+// The reason expression is matched against the status reason and the CloudTrail message.
+func (r *Rule) Matches(err analyzer.CorrelatedError) bool {
+	if r.logicalId != nil && !r.logicalId.MatchString(err.StackError.LogicalResourceId) {
+		return false
+	}
+	if r.reason != nil && !r.reason.MatchString(err.StackError.ResourceStatusReason) &&
+		!r.reason.MatchString(err.DetailedMessage) {
+		return false
+	}
+	return true
+}
+
+// Apply moves errors matched by any rule from Errors to Ignored
+func Apply(analysis *analyzer.StackAnalysis, rules []*Rule) {
+	if len(rules) == 0 {
+		return
+	}
+
+	kept := make([]analyzer.CorrelatedError, 0, len(analysis.Errors))
+	for _, err := range analysis.Errors {
+		if rule := firstMatch(rules, err); rule != nil {
+			analysis.Ignored = append(analysis.Ignored, analyzer.IgnoredError{Error: err, Comment: rule.Comment})
+			continue
+		}
+		kept = append(kept, err)
+	}
+
+	analysis.ReplaceErrors(kept)
+}
+
+// firstMatch returns the first rule ignoring the error, or nil
+func firstMatch(rules []*Rule, err analyzer.CorrelatedError) *Rule {
+	for _, rule := range rules {
+		if rule.Matches(err) {
+			return rule
+		}
+	}
+	return nil
+}
+
+// compileOptional compiles a regular expression, returning nil for an empty expression
+func compileOptional(expr string) (*regexp.Regexp, error) {
+	if expr == "" {
+		return nil, nil
+	}
+	return regexp.Compile(expr)
+}
@@ -0,0 +1,45 @@
+// Package lambdaaccount reads the Lambda quotas of the account and their current usage
+package lambdaaccount
+
+import (
+	"context"
+	"fmt"
+
+	"cfn-root-cause/analyzer"
+	"cfn-root-cause/awserrors"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/lambda"
+)
+
+// Client wraps the Lambda client
+type Client struct {
+	lambda *lambda.Client
+}
+
+// NewClientWithConfig creates a new Lambda account client with a custom AWS config
+func NewClientWithConfig(cfg aws.Config) *Client {
+	return &Client{lambda: lambda.NewFromConfig(cfg)}
+}
+
+// Settings returns the current Lambda quotas and usage of the account in the region
+func (c *Client) Settings(ctx context.Context) (*analyzer.LambdaAccountSettings, error) {
+	output, err := c.lambda.GetAccountSettings(ctx, &lambda.GetAccountSettingsInput{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get Lambda account settings: %w", awserrors.ParseAWSError(err, "Lambda"))
+	}
+
+	settings := &analyzer.LambdaAccountSettings{}
+	if limit := output.AccountLimit; limit != nil {
+		settings.ConcurrentExecutions = int(limit.ConcurrentExecutions)
+		settings.UnreservedConcurrentExecutions = int(aws.ToInt32(limit.UnreservedConcurrentExecutions))
+		settings.CodeSizeZipped = limit.CodeSizeZipped
+		settings.CodeSizeUnzipped = limit.CodeSizeUnzipped
+		settings.TotalCodeSize = limit.TotalCodeSize
+	}
+	if usage := output.AccountUsage; usage != nil {
+		settings.TotalCodeSizeUsed = usage.TotalCodeSize
+		settings.FunctionCount = usage.FunctionCount
+	}
+	return settings, nil
+}
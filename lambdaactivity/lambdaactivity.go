@@ -0,0 +1,140 @@
+// Package lambdaactivity reports the invocations, errors and log output of the Lambda functions
+// backing custom resources, from CloudWatch metrics and CloudWatch Logs, and the log output of
+// resource type handlers
+package lambdaactivity
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"cfn-root-cause/analyzer"
+	"cfn-root-cause/awserrors"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch"
+	cwtypes "github.com/aws/aws-sdk-go-v2/service/cloudwatch/types"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs"
+	logtypes "github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs/types"
+)
+
+// MaxLogMessages limits the number of log messages read for a function
+const MaxLogMessages = 500
+
+// metricPeriodSeconds is the granularity of CloudWatch metric periods
+const metricPeriodSeconds = 60
+
+// Client wraps the CloudWatch and CloudWatch Logs clients
+type Client struct {
+	cloudwatch *cloudwatch.Client
+	logs       *cloudwatchlogs.Client
+}
+
+// NewClientWithConfig creates a new client with a custom AWS config
+func NewClientWithConfig(cfg aws.Config) *Client {
+	return &Client{
+		cloudwatch: cloudwatch.NewFromConfig(cfg),
+		logs:       cloudwatchlogs.NewFromConfig(cfg),
+	}
+}
+
+// Activity returns the invocations and log messages of a function between start and end. Only the
+// default log group /aws/lambda/<function> is read. If one of the sources fails, the activity of
+// the other is returned together with the error.
+func (c *Client) Activity(ctx context.Context, functionName string, start, end time.Time) (*analyzer.LambdaActivity, error) {
+	activity := &analyzer.LambdaActivity{FunctionName: functionName}
+
+	metricsErr := c.readMetrics(ctx, activity, start, end)
+	logsErr := c.readLogs(ctx, activity, start, end)
+	return activity, errors.Join(metricsErr, logsErr)
+}
+
+// readMetrics reads the AWS/Lambda metrics of the function as one period covering the time window
+func (c *Client) readMetrics(ctx context.Context, activity *analyzer.LambdaActivity, start, end time.Time) error {
+	start = start.Truncate(metricPeriodSeconds * time.Second)
+	period := int32((end.Sub(start)/time.Second)/metricPeriodSeconds+1) * metricPeriodSeconds
+	end = start.Add(time.Duration(period) * time.Second)
+
+	query := func(id, metricName string, stat string) cwtypes.MetricDataQuery {
+		return cwtypes.MetricDataQuery{
+			Id: aws.String(id),
+			MetricStat: &cwtypes.MetricStat{
+				Metric: &cwtypes.Metric{
+					Namespace:  aws.String("AWS/Lambda"),
+					MetricName: aws.String(metricName),
+					Dimensions: []cwtypes.Dimension{{Name: aws.String("FunctionName"), Value: aws.String(activity.FunctionName)}},
+				},
+				Period: aws.Int32(period),
+				Stat:   aws.String(stat),
+			},
+		}
+	}
+
+	output, err := c.cloudwatch.GetMetricData(ctx, &cloudwatch.GetMetricDataInput{
+		StartTime: aws.Time(start),
+		EndTime:   aws.Time(end),
+		MetricDataQueries: []cwtypes.MetricDataQuery{
+			query("invocations", "Invocations", "Sum"),
+			query("errors", "Errors", "Sum"),
+			query("throttles", "Throttles", "Sum"),
+			query("duration", "Duration", "Maximum"),
+		},
+	})
+	if err != nil {
+		return awserrors.ParseAWSError(err, "CloudWatch")
+	}
+
+	for _, result := range output.MetricDataResults {
+		var total, maximum float64
+		for _, value := range result.Values {
+			total += value
+			maximum = max(maximum, value)
+		}
+		switch aws.ToString(result.Id) {
+		case "invocations":
+			activity.Invocations = int(total)
+		case "errors":
+			activity.Errors = int(total)
+		case "throttles":
+			activity.Throttles = int(total)
+		case "duration":
+			activity.MaxDuration = time.Duration(maximum * float64(time.Millisecond))
+		}
+	}
+	return nil
+}
+
+// readLogs reads up to MaxLogMessages log messages of the function in the time window
+func (c *Client) readLogs(ctx context.Context, activity *analyzer.LambdaActivity, start, end time.Time) error {
+	var err error
+	activity.LogMessages, activity.LogGroupFound, err = c.LogMessages(ctx, "/aws/lambda/"+activity.FunctionName, start, end)
+	return err
+}
+
+// LogMessages reads up to MaxLogMessages messages of a log group in the time window, oldest first,
+// such as the handler log group of a resource type. found is false if the log group does not exist.
+func (c *Client) LogMessages(ctx context.Context, logGroupName string, start, end time.Time) (messages []string, found bool, err error) {
+	paginator := cloudwatchlogs.NewFilterLogEventsPaginator(c.logs, &cloudwatchlogs.FilterLogEventsInput{
+		LogGroupName: aws.String(logGroupName),
+		StartTime:    aws.Int64(start.UnixMilli()),
+		EndTime:      aws.Int64(end.UnixMilli()),
+	})
+
+	for paginator.HasMorePages() && len(messages) < MaxLogMessages {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			var notFound *logtypes.ResourceNotFoundException
+			if errors.As(err, &notFound) {
+				return nil, false, nil
+			}
+			return messages, true, awserrors.ParseAWSError(err, "CloudWatch Logs")
+		}
+		for _, event := range page.Events {
+			if len(messages) == MaxLogMessages {
+				break
+			}
+			messages = append(messages, aws.ToString(event.Message))
+		}
+	}
+	return messages, true, nil
+}
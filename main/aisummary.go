@@ -0,0 +1,28 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"cfn-root-cause/aisummary"
+	"cfn-root-cause/analyzer"
+)
+
+// summarizeAnalyses adds the summary of the AI provider to each analysis with errors. Failures are
+// reported as a warning, since the report is complete without the summary.
+func summarizeAnalyses(ctx context.Context, provider aisummary.Provider, analyses []*analyzer.StackAnalysis) {
+	for _, analysis := range analyses {
+		if len(analysis.Errors) == 0 || ctx.Err() != nil {
+			continue
+		}
+
+		progressf("Summarizing stack %s with %s...\n", analysis.StackName, provider.Name())
+		summary, err := aisummary.Summarize(ctx, provider, analysis)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+			continue
+		}
+		analysis.AISummary = summary
+	}
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"cfn-root-cause/analyzer"
+	"cfn-root-cause/archive"
+	"cfn-root-cause/awsconfig"
+	"cfn-root-cause/cfnclient"
+	"cfn-root-cause/cloudtrail"
+	"cfn-root-cause/extractor"
+	"cfn-root-cause/validator"
+
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation/types"
+)
+
+// runArchive stores a snapshot of the events and matching CloudTrail events of each stack in the
+// archive, so it can be analyzed with --from-archive after CloudTrail forgot the events or the
+// stack was deleted. Stacks without new events since their latest snapshot are skipped, so the
+// command can run on a schedule.
+func runArchive(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("archive", flag.ContinueOnError)
+	location := fs.String("to", "", "archive location: s3://bucket/prefix or a directory (required)")
+	allFailed := fs.Bool("all-failed", false, "archive all stacks with a failure status")
+	var awsOpts awsconfig.Options
+	addAWSFlags(fs, &awsOpts)
+
+	var stackNames []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return err
+		}
+		if fs.NArg() == 0 {
+			break
+		}
+		stackNames = append(stackNames, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+	if *location == "" || (len(stackNames) == 0) == !*allFailed {
+		return fmt.Errorf("usage: archive --to LOCATION (<stack-name>... | --all-failed)")
+	}
+	for _, stackName := range stackNames {
+		if err := validator.ValidateStackName(stackName); err != nil {
+			return err
+		}
+	}
+
+	awsCfg, err := loadAWSConfig(ctx, awsOpts)
+	if err != nil {
+		return err
+	}
+	store, err := archive.Open(awsCfg, *location)
+	if err != nil {
+		return err
+	}
+	cfnClient := cfnclient.NewClientWithConfig(awsCfg)
+	ctClient := cloudtrail.NewClientWithConfig(awsCfg)
+	breaker := cloudtrail.NewBreaker(cloudtrail.DefaultBreakerThreshold)
+	ctClient.UseBreaker(breaker)
+
+	if *allFailed {
+		summaries, err := cfnClient.ListStacksWithStatus(ctx, cfnclient.FailedStackStatuses)
+		if err != nil {
+			return err
+		}
+		stackNames = stackNamesOf(summaries)
+		progressf("Found %d stack(s) with failure status\n", len(stackNames))
+	}
+
+	failed := 0
+	for _, stackName := range stackNames {
+		stored, err := archive.Update(ctx, store, cfnClient, ctClient, stackName)
+		if ctx.Err() != nil {
+			return &exitError{code: exitCodeInterrupted}
+		}
+		switch {
+		case stored:
+			fmt.Printf("Archived stack %s to %s\n", stackName, *location)
+		case err == nil:
+			fmt.Printf("Stack %s is unchanged since its latest snapshot\n", stackName)
+		default:
+			failed++
+		}
+		if err != nil && !errors.Is(err, cloudtrail.ErrBreakerOpen) {
+			fmt.Fprintf(os.Stderr, "Warning: Stack %s: %v\n", stackName, err)
+		}
+	}
+	reportBreaker(breaker)
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d stack(s) could not be archived", failed, len(stackNames))
+	}
+	return nil
+}
+
+// analyzeArchivedStack analyzes the latest archived snapshot of a stack. The errors of the day of
+// the newest archived error are analyzed, just like a live analysis on the day of the failure.
+func analyzeArchivedStack(ctx context.Context, store archive.Store, stackName string) (*analyzer.StackAnalysis, error) {
+	stats := &analyzer.AnalysisStats{}
+
+	progressf("Reading archived snapshot...\n")
+	phaseStart := time.Now()
+	snapshot, err := store.Latest(ctx, stackName)
+	if err != nil {
+		return nil, err
+	}
+	stats.RecordPhase("Read archived snapshot", phaseStart)
+	progressf("Using snapshot archived at %s\n", snapshot.ArchivedAt.UTC().Format("2006-01-02 15:04:05 UTC"))
+
+	return analyzeEvents(ctx, stackName, snapshot.Events, newestErrorDate(snapshot.Events, snapshot.ArchivedAt), snapshot, stats), nil
+}
+
+// newestErrorDate returns the time of the newest error of the events, or the fallback if none failed
+func newestErrorDate(events []types.StackEvent, fallback time.Time) time.Time {
+	stackErrors := extractor.ExtractErrors(events)
+	if len(stackErrors) == 0 {
+		return fallback
+	}
+
+	referenceDate := stackErrors[0].Timestamp
+	for _, stackErr := range stackErrors {
+		if stackErr.Timestamp.After(referenceDate) {
+			referenceDate = stackErr.Timestamp
+		}
+	}
+	return referenceDate
+}
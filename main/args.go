@@ -0,0 +1,542 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"slices"
+	"strings"
+	"time"
+
+	"cfn-root-cause/awsconfig"
+	"cfn-root-cause/awserrors"
+	"cfn-root-cause/cloudtrail"
+	"cfn-root-cause/codebuild"
+	"cfn-root-cause/filter"
+	"cfn-root-cause/formatter"
+	"cfn-root-cause/ignore"
+	"cfn-root-cause/rules"
+	"cfn-root-cause/sink"
+	"cfn-root-cause/sorter"
+	"cfn-root-cause/validator"
+	"cfn-root-cause/webhook"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation/types"
+)
+
+// options holds the parsed command line options
+type options struct {
+	// StackNames are the stacks to analyze, each a stack name or a glob pattern selecting several
+	// stacks; empty means the most recently updated stack
+	StackNames []string
+
+	// AllFailed analyzes all stacks with a failure status
+	AllFailed bool
+
+	// LatestFailed analyzes the most recently updated stack with a failure status when no stack
+	// name is given, instead of the most recently updated stack
+	LatestFailed bool
+
+	// IncludeStatuses and ExcludeStatuses add stack statuses to, or remove them from, the statuses of
+	// the stacks considered when no stack name is given or with --all-failed
+	IncludeStatuses []types.StackStatus
+	ExcludeStatuses []types.StackStatus
+
+	// Yes analyzes the most recently updated stack without asking for confirmation when no stack
+	// name is given
+	Yes bool
+
+	// Format selects the report format (text, plain, compact, gitlab, junit, json, markdown)
+	Format string
+
+	// Output is the file the report is written to; empty means stdout
+	Output string
+
+	// Sinks are further destinations of the report, each in its own format; they replace stdout
+	Sinks []sink.Spec
+
+	// TemplatePath is the template file referenced by CI report formats
+	TemplatePath string
+
+	// CodeBuild enables the CodeBuild/CodePipeline integration mode
+	CodeBuild bool
+
+	// ShowStats appends performance statistics to the report
+	ShowStats bool
+
+	// Explain adds a plain-English narrative of each root cause to the report
+	Explain bool
+
+	// Language selects the report language; empty means LANG or English
+	Language string
+
+	// MaxMessageLength truncates messages in the compact format; 0 disables truncation
+	MaxMessageLength int
+
+	// ConfigPath is the configuration file; empty means the default location
+	ConfigPath string
+
+	// Theme selects the color theme, overriding the configuration file
+	Theme string
+
+	// ArtifactsDir overrides the directory CodeBuild mode writes reports to
+	ArtifactsDir string
+
+	// Sections selects which report sections are rendered
+	Sections formatter.Sections
+
+	// Sort orders the errors in the report; empty keeps the extraction order
+	Sort string
+
+	// Filter narrows the report to matching errors
+	Filter filter.Criteria
+
+	// IgnoreFile lists known acceptable errors; empty means the default file if present
+	IgnoreFile string
+
+	// ExitCode makes the run exit with a distinct status when errors remain in the report
+	ExitCode bool
+
+	// Strict makes the run exit with a distinct status when CloudTrail lookups failed
+	Strict bool
+
+	// CloudTrailWait is how long lookups wait for CloudTrail to deliver the events of recent failures
+	CloudTrailWait time.Duration
+
+	// FailOn limits the errors that make the run exit with a distinct status to permanent or
+	// transient errors, rule severities or failure categories; it implies ExitCode
+	FailOn []string
+
+	// BaselinePath is a baseline of known errors that do not count as failures
+	BaselinePath string
+
+	// WriteBaselinePath is the file the errors of this run are recorded to as a new baseline
+	WriteBaselinePath string
+
+	// WebhookURL is an HTTPS endpoint the JSON report is posted to
+	WebhookURL string
+
+	// HistoryFile is the file analyses are recorded to; empty means the default location
+	HistoryFile string
+
+	// NoHistory disables recording the analysis in the history file
+	NoHistory bool
+
+	// NoCache analyzes the stack again even if its latest operation was analyzed before, and fetches all its events
+	NoCache bool
+
+	// SupportText prints an AWS Support case body instead of the report
+	SupportText bool
+
+	// TemplateDiff adds the changes between the previously deployed and the failed template to the report
+	TemplateDiff bool
+
+	// StackContext adds the parameters and outputs of the stack to the report
+	StackContext bool
+
+	// Operation selects an earlier operation of the stack to analyze, by its number in the operations
+	// subcommand (1 is the latest) or its client request token; empty means the latest operation
+	Operation string
+
+	// RulesFile contains the classification rules; empty means the default file if present
+	RulesFile string
+
+	// PluginsDir is the directory enricher plugins are run from; empty means the default location
+	PluginsDir string
+
+	// NoPlugins disables enricher plugins
+	NoPlugins bool
+
+	// Redact masks account IDs, IP addresses and generated name suffixes in all output
+	Redact bool
+
+	// AISummary adds a summary of the root cause written by the AI provider of the config file
+	AISummary bool
+
+	// NotifyDesktop shows a desktop notification when the analysis finished
+	NotifyDesktop bool
+
+	// OpsItem creates an OpsCenter OpsItem for each failed production stack of the config file
+	OpsItem bool
+
+	// FromArchive analyzes the latest archived snapshot of the stack at this location
+	// instead of the live stack events and CloudTrail
+	FromArchive string
+
+	// Stdin analyzes the output of 'aws cloudformation describe-stack-events' piped to stdin
+	// instead of the live stack events and CloudTrail
+	Stdin bool
+
+	// ProvisionedProduct analyzes the stack of this Service Catalog provisioned product, given by ID or name
+	ProvisionedProduct string
+
+	// Regions analyzes the stacks of several regions: --all-failed and stack patterns discover the
+	// stacks of each region, and stack names are analyzed in each region they exist in
+	Regions []string
+
+	// Concurrency is the number of stacks analyzed in parallel
+	Concurrency int
+
+	// AWS configures credentials and retries of the AWS clients
+	AWS awsconfig.Options
+}
+
+// stringList is a flag value collecting repeated or comma-separated values
+type stringList []string
+
+// String returns the collected values separated by commas
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+// Set adds the comma-separated values to the list
+func (l *stringList) Set(value string) error {
+	for _, v := range strings.Split(value, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			*l = append(*l, v)
+		}
+	}
+	return nil
+}
+
+// sinkList is a flag value collecting repeated sink specifications
+type sinkList []sink.Spec
+
+// String returns the destinations of the sinks separated by commas
+func (l *sinkList) String() string {
+	destinations := make([]string, 0, len(*l))
+	for _, spec := range *l {
+		destinations = append(destinations, spec.Destination)
+	}
+	return strings.Join(destinations, ",")
+}
+
+// Set adds the sink of a [format=]destination specification
+func (l *sinkList) Set(value string) error {
+	spec, err := sink.ParseSpec(value)
+	if err != nil {
+		return err
+	}
+	*l = append(*l, spec)
+	return nil
+}
+
+// addAWSFlags registers the flags configuring the AWS clients: region, profile, MFA and retries
+func addAWSFlags(fs *flag.FlagSet, opts *awsconfig.Options) {
+	fs.StringVar(&opts.Region, "region", "", "AWS region (default from AWS_REGION or the profile)")
+	fs.StringVar(&opts.Profile, "profile", "", "AWS shared configuration profile (default from AWS_PROFILE)")
+	fs.StringVar(&opts.MFA.SerialNumber, "mfa-serial", "",
+		"MFA device for assuming the role of the profile (default mfa_serial of the profile)")
+	fs.StringVar(&opts.MFA.TokenCode, "token-code", "",
+		"current MFA code for non-interactive use; prompted for in a terminal if the role requires MFA")
+	fs.IntVar(&opts.MaxAttempts, "max-attempts", 0,
+		"maximum attempts per AWS API call including retries (default from the profile or the SDK)")
+	fs.StringVar(&opts.RetryMode, "retry-mode", "",
+		"AWS SDK retry mode: standard, adaptive (default from the profile or the SDK)")
+	fs.BoolVar(&opts.Debug, "debug-aws", false,
+		"log each AWS API call with operation, parameters, latency, attempts and request ID to stderr")
+}
+
+// loadAWSConfig loads the AWS configuration shared by all clients of a run
+func loadAWSConfig(ctx context.Context, opts awsconfig.Options) (aws.Config, error) {
+	cfg, err := awsconfig.Load(ctx, opts)
+	if err != nil {
+		return aws.Config{}, fmt.Errorf("failed to load AWS configuration: %w", awserrors.ParseAWSError(err, "AWS"))
+	}
+	return cfg, nil
+}
+
+// parseArgs parses command line arguments into options.
+// Flags may appear before, between or after the stack names.
+// No stack name indicates the default behavior (most recent stack).
+func parseArgs(args []string) (*options, error) {
+	opts := &options{}
+
+	fs := flag.NewFlagSet(os.Args[0], flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	fs.StringVar(&opts.Format, "format", formatter.ReportText,
+		"report format: "+strings.Join(formatter.Formats(), ", "))
+	fs.StringVar(&opts.Output, "output", "", "write the report to this file instead of stdout")
+	fs.Var((*sinkList)(&opts.Sinks), "sink",
+		"deliver the report to [format=]destination: - (stdout), a file, s3://bucket/key, an https:// webhook, slack:<webhook URL> or an SNS topic ARN (repeatable)")
+	fs.StringVar(&opts.TemplatePath, "template-path", "template.yaml",
+		"template file path referenced by the gitlab and junit formats")
+	fs.BoolVar(&opts.ShowStats, "stats", false,
+		"append performance statistics (phase durations, events scanned, CloudTrail calls) to the report")
+	fs.BoolVar(&opts.Explain, "explain", false,
+		"add a plain-English narrative of each root cause for readers unfamiliar with CloudFormation (text, plain and json formats)")
+	fs.StringVar(&opts.Language, "lang", "",
+		"report language: "+strings.Join(formatter.Languages(), ", ")+" (default from LANG)")
+	fs.IntVar(&opts.MaxMessageLength, "max-message-length", formatter.DefaultMaxMessageLength,
+		"truncate messages in the compact format after this many characters")
+	noTruncate := fs.Bool("no-truncate", false, "never truncate messages in the compact format")
+	fs.StringVar(&opts.ConfigPath, "config", "", "configuration file (default ~/.config/cfnrc/config.json)")
+	fs.StringVar(&opts.Theme, "theme", "",
+		"color theme of the text format: "+strings.Join(formatter.ThemeNames(), ", "))
+	fs.BoolVar(&opts.AllFailed, "all-failed", false,
+		"analyze all stacks with a failure status and add an aggregate section")
+	fs.Var((*stringList)(&opts.Regions), "regions",
+		"analyze the stacks of these regions with --all-failed, stack names or patterns, e.g. eu-west-1,us-east-1 (repeatable)")
+	fs.BoolVar(&opts.LatestFailed, "latest-failed", false,
+		"analyze the most recently updated stack with a failure status when no stack name is given")
+	var includeStatuses, excludeStatuses stringList
+	fs.Var(&includeStatuses, "include-status",
+		"also consider stacks with these statuses when no stack name is given or with --all-failed, e.g. REVIEW_IN_PROGRESS (repeatable)")
+	fs.Var(&excludeStatuses, "exclude-status",
+		"do not consider stacks with these statuses when no stack name is given or with --all-failed, e.g. DELETE_IN_PROGRESS (repeatable)")
+	fs.BoolVar(&opts.Yes, "yes", false,
+		"analyze the most recently updated stack without confirmation when no stack name is given")
+	fs.BoolVar(&opts.CodeBuild, "codebuild", false,
+		"CodeBuild mode: locate the stack deployed by the pipeline, analyze only on failure and write reports to the artifacts directory")
+	fs.StringVar(&opts.ArtifactsDir, "artifacts-dir", "",
+		"directory CodeBuild mode writes reports to (default $CODEBUILD_SRC_DIR/"+codebuild.DefaultArtifactsDirName+")")
+	errorsOnly := fs.Bool("errors-only", false, "show only the errors, without header, summary and findings")
+	summaryOnly := fs.Bool("summary-only", false, "show only the header and summary, without errors and findings")
+	fs.BoolVar(&opts.Sections.HideSummary, "no-summary", false, "omit the summary section")
+	fs.BoolVar(&opts.Sections.HideRootCause, "no-root-cause", false, "omit the most likely root cause at the top of the report")
+	fs.BoolVar(&opts.Sections.HideCloudTrailDetails, "no-cloudtrail-details", false,
+		"omit the CloudTrail details of each error")
+
+	fs.Var((*stringList)(&opts.Filter.ResourceTypes), "filter-resource-type",
+		"only report errors of matching resource types, e.g. AWS::IAM::* (repeatable)")
+	fs.Var((*stringList)(&opts.Filter.LogicalIds), "filter-logical-id",
+		"only report errors of matching logical resource IDs (repeatable)")
+	fs.Var((*stringList)(&opts.Filter.Statuses), "filter-status",
+		"only report errors with matching statuses, e.g. CREATE_FAILED (repeatable)")
+	fs.StringVar(&opts.IgnoreFile, "ignore-file", "",
+		"file with rules for known acceptable errors (default "+ignore.DefaultFileName+" if present)")
+	fs.BoolVar(&opts.ExitCode, "exit-code", false,
+		"exit with status 2 when the report contains errors that are not ignored")
+	fs.BoolVar(&opts.Strict, "strict", false,
+		"exit with status 3 when CloudTrail could not be queried, so the report lacks CloudTrail details")
+	fs.DurationVar(&opts.CloudTrailWait, "cloudtrail-wait", cloudtrail.DefaultDeliveryWait,
+		"wait up to this long for CloudTrail to deliver the events of failures of the last 15 minutes no event matched, and look them up again; 0 reports them without details")
+	fs.Var((*stringList)(&opts.FailOn), "fail-on",
+		"exit with status 2 only for errors of these kinds: permanent, transient, a rule severity or a category (repeatable)")
+	fs.StringVar(&opts.BaselinePath, "baseline", "",
+		"baseline file of a previous run; errors it contains are listed as ignored")
+	fs.StringVar(&opts.WriteBaselinePath, "write-baseline", "",
+		"record the errors of this run as a baseline file")
+	fs.StringVar(&opts.WebhookURL, "webhook-url", "",
+		"POST the JSON report to this HTTPS endpoint, signed with $"+webhook.SecretEnvVar+" if set")
+	fs.StringVar(&opts.HistoryFile, "history-file", "",
+		"file the analysis is recorded to for the stats subcommand (default ~/.config/cfnrc/history.jsonl)")
+	fs.BoolVar(&opts.NoHistory, "no-history", false, "do not record the analysis in the history file")
+	fs.BoolVar(&opts.NoCache, "no-cache", false,
+		"analyze again even if the latest operation of the stack was analyzed before (cached in ~/.cache/cfnrc/analyses), fetching all stack events")
+	fs.BoolVar(&opts.SupportText, "support-text", false,
+		"print a ready-to-paste AWS Support case body with request IDs and error messages instead of the report; secrets are redacted")
+	fs.BoolVar(&opts.TemplateDiff, "template-diff", false,
+		"show what changed between the previously deployed and the failed template (change set deployments only)")
+	fs.BoolVar(&opts.StackContext, "stack-context", false,
+		"show the parameters (NoEcho values masked) and outputs of the stack next to the failure")
+	fs.StringVar(&opts.Operation, "operation", "",
+		"analyze an earlier operation of the stack: its number in the operations subcommand (1 is the latest) or its client request token")
+	fs.StringVar(&opts.RulesFile, "rules-file", "",
+		"file with CEL rules assigning categories, owners, severities and remediation (default "+rules.DefaultFileName+" if present)")
+	fs.StringVar(&opts.PluginsDir, "plugins-dir", "",
+		"directory of enricher plugins run for each error (default ~/.config/cfnrc/plugins if present)")
+	fs.BoolVar(&opts.NoPlugins, "no-plugins", false, "do not run enricher plugins")
+	fs.BoolVar(&opts.Redact, "redact", false,
+		"mask account IDs, ARN account segments, IP addresses and generated resource name suffixes in all output")
+	fs.BoolVar(&opts.AISummary, "ai-summary", false,
+		"add a summary of the root cause written by the AI provider configured in the config file (sends the errors to the provider)")
+	fs.BoolVar(&opts.NotifyDesktop, "notify-desktop", false,
+		"show a desktop notification stating whether a root cause was found when the analysis finished")
+	fs.BoolVar(&opts.OpsItem, "ops-item", false,
+		"create a Systems Manager OpsCenter OpsItem for each failed stack matching opsCenter.stacks of the config file")
+	addAWSFlags(fs, &opts.AWS)
+	fs.StringVar(&opts.FromArchive, "from-archive", "",
+		"analyze the latest snapshot archived at this location (s3://bucket/prefix or a directory) instead of the live stack")
+	fs.BoolVar(&opts.Stdin, "stdin", false,
+		"analyze the output of 'aws cloudformation describe-stack-events' piped to stdin instead of the live stack (no CloudTrail lookups)")
+	fs.StringVar(&opts.ProvisionedProduct, "provisioned-product", "",
+		"analyze the stack of this Service Catalog provisioned product (ID pp-... or name) instead of a stack name")
+	fs.IntVar(&opts.Concurrency, "concurrency", 1,
+		"number of stacks analyzed in parallel; CloudTrail lookups stay within the rate limit of the account")
+	fs.StringVar(&opts.Sort, "sort", "", "order errors by: "+strings.Join(sorter.SortKeys(), ", "))
+
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, usageError(fs, err)
+		}
+		if fs.NArg() == 0 {
+			break
+		}
+		positional = append(positional, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+
+	if err := opts.AWS.Validate(); err != nil {
+		return nil, err
+	}
+
+	if !formatter.IsValidFormat(opts.Format) {
+		return nil, fmt.Errorf("unknown format '%s': must be one of %s",
+			opts.Format, strings.Join(formatter.Formats(), ", "))
+	}
+
+	if opts.WebhookURL != "" {
+		if err := webhook.ValidateURL(opts.WebhookURL); err != nil {
+			return nil, err
+		}
+	}
+	for _, spec := range opts.Sinks {
+		if err := validateSinkFormat(spec); err != nil {
+			return nil, err
+		}
+	}
+	if opts.SupportText && len(opts.Sinks) > 0 {
+		return nil, fmt.Errorf("--support-text cannot be combined with --sink")
+	}
+
+	if err := opts.Filter.Validate(); err != nil {
+		return nil, err
+	}
+
+	if err := validateFailOn(opts.FailOn); err != nil {
+		return nil, err
+	}
+	if len(opts.FailOn) > 0 {
+		opts.ExitCode = true
+	}
+
+	if !sorter.IsValidSortKey(opts.Sort) {
+		return nil, fmt.Errorf("unknown sort key '%s': must be one of %s",
+			opts.Sort, strings.Join(sorter.SortKeys(), ", "))
+	}
+
+	if opts.Concurrency < 1 {
+		return nil, fmt.Errorf("invalid concurrency %d: must be at least 1", opts.Concurrency)
+	}
+
+	if opts.MaxMessageLength < 0 {
+		return nil, fmt.Errorf("invalid max message length %d: must not be negative", opts.MaxMessageLength)
+	}
+	if *noTruncate {
+		opts.MaxMessageLength = 0
+	}
+
+	if *errorsOnly && *summaryOnly {
+		return nil, fmt.Errorf("--errors-only and --summary-only cannot be combined")
+	}
+	if *errorsOnly {
+		opts.Sections.HideRootCause = true
+		opts.Sections.HideHeader = true
+		opts.Sections.HideSummary = true
+		opts.Sections.HideFindings = true
+		opts.Sections.HideTemplateDiff = true
+		opts.Sections.HideStackContext = true
+		opts.Sections.HideAISummary = true
+		opts.Sections.HideWarnings = true
+	}
+	if *summaryOnly {
+		opts.Sections.HideErrors = true
+		opts.Sections.HideFindings = true
+		opts.Sections.HideTemplateDiff = true
+		opts.Sections.HideStackContext = true
+	}
+
+	if opts.Language != "" && !formatter.IsValidLanguage(opts.Language) {
+		return nil, fmt.Errorf("unknown language '%s': must be one of %s",
+			opts.Language, strings.Join(formatter.Languages(), ", "))
+	}
+	opts.Language = formatter.ResolveLanguage(opts.Language)
+
+	// Validate stack name or pattern format before processing; no stack name means the most recent stack
+	hasPattern := false
+	for _, stackName := range positional {
+		if isStackPattern(stackName) {
+			if _, err := path.Match(stackName, ""); err != nil {
+				return nil, fmt.Errorf("invalid stack pattern '%s': %w", stackName, err)
+			}
+			hasPattern = true
+		} else if err := validator.ValidateStackName(stackName); err != nil {
+			return nil, err
+		}
+	}
+	opts.StackNames = positional
+
+	if opts.AllFailed && len(opts.StackNames) > 0 {
+		return nil, fmt.Errorf("--all-failed cannot be combined with a stack name")
+	}
+	var err error
+	if opts.IncludeStatuses, err = validator.ParseStackStatuses(includeStatuses); err != nil {
+		return nil, fmt.Errorf("invalid --include-status: %w", err)
+	}
+	if opts.ExcludeStatuses, err = validator.ParseStackStatuses(excludeStatuses); err != nil {
+		return nil, fmt.Errorf("invalid --exclude-status: %w", err)
+	}
+	if (len(includeStatuses) > 0 || len(excludeStatuses) > 0) && (len(opts.StackNames) > 0 || opts.CodeBuild || opts.Stdin || opts.ProvisionedProduct != "") {
+		return nil, fmt.Errorf("--include-status and --exclude-status select the stacks to discover and cannot be combined with a stack name, --codebuild, --stdin or --provisioned-product")
+	}
+	if opts.LatestFailed && (len(opts.StackNames) > 0 || opts.AllFailed || opts.CodeBuild || opts.Stdin || opts.ProvisionedProduct != "") {
+		return nil, fmt.Errorf("--latest-failed cannot be combined with a stack name, --all-failed, --codebuild, --stdin or --provisioned-product")
+	}
+	if len(opts.Regions) > 0 {
+		if !opts.AllFailed && len(opts.StackNames) == 0 {
+			return nil, fmt.Errorf("--regions requires --all-failed, stack names or stack patterns")
+		}
+		if opts.CodeBuild || opts.Stdin || opts.FromArchive != "" || opts.ProvisionedProduct != "" || opts.Operation != "" {
+			return nil, fmt.Errorf("--regions cannot be combined with --codebuild, --stdin, --from-archive, --provisioned-product or --operation")
+		}
+		// A region given several times is analyzed once
+		var regions []string
+		for _, region := range opts.Regions {
+			if !slices.Contains(regions, region) {
+				regions = append(regions, region)
+			}
+		}
+		opts.Regions = regions
+	}
+	if opts.CodeBuild && (opts.AllFailed || hasPattern || len(opts.StackNames) > 1) {
+		return nil, fmt.Errorf("--codebuild analyzes a single stack and cannot be combined with --all-failed, several stacks or a stack pattern")
+	}
+	if opts.FromArchive != "" {
+		if len(opts.StackNames) == 0 || hasPattern {
+			return nil, fmt.Errorf("--from-archive requires the names of the archived stacks")
+		}
+		if opts.CodeBuild || opts.TemplateDiff || opts.StackContext {
+			return nil, fmt.Errorf("--from-archive cannot be combined with --codebuild, --template-diff or --stack-context")
+		}
+	}
+	if opts.Stdin && (opts.AllFailed || hasPattern || len(opts.StackNames) > 1 || opts.CodeBuild || opts.FromArchive != "" || opts.TemplateDiff || opts.StackContext) {
+		return nil, fmt.Errorf("--stdin cannot be combined with --all-failed, several stacks, a stack pattern, --codebuild, --from-archive, --template-diff or --stack-context")
+	}
+	if opts.CloudTrailWait < 0 {
+		return nil, fmt.Errorf("--cloudtrail-wait must not be negative")
+	}
+	if opts.Strict && (opts.Stdin || opts.FromArchive != "") {
+		return nil, fmt.Errorf("--strict cannot be combined with --stdin or --from-archive, which do not query CloudTrail")
+	}
+	if opts.Operation != "" && (len(opts.StackNames) != 1 || hasPattern || opts.FromArchive != "" || opts.Stdin || opts.TemplateDiff) {
+		return nil, fmt.Errorf("--operation requires a single stack name and cannot be combined with --from-archive, --stdin or --template-diff")
+	}
+	if opts.ProvisionedProduct != "" && (len(opts.StackNames) > 0 || opts.AllFailed || opts.CodeBuild || opts.FromArchive != "" || opts.Stdin) {
+		return nil, fmt.Errorf("--provisioned-product cannot be combined with a stack name, --all-failed, --codebuild, --from-archive or --stdin")
+	}
+
+	return opts, nil
+}
+
+// usageError builds an error containing the usage text and, if present, the parse error
+func usageError(fs *flag.FlagSet, err error) error {
+	var sb strings.Builder
+
+	if err != nil && err != flag.ErrHelp {
+		sb.WriteString(err.Error())
+		sb.WriteString("\n")
+	}
+	sb.WriteString(fmt.Sprintf("usage: %s [flags] [stack-name | stack-pattern]...\n\nFlags:\n", os.Args[0]))
+
+	fs.SetOutput(&sb)
+	fs.PrintDefaults()
+	fs.SetOutput(io.Discard)
+
+	return fmt.Errorf("%s", strings.TrimRight(sb.String(), "\n"))
+}
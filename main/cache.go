@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"cfn-root-cause/analyzer"
+	"cfn-root-cause/cache"
+	"cfn-root-cause/cfnclient"
+	"cfn-root-cause/cloudtrail"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation/types"
+)
+
+// analyzeStackCached returns the cached analysis of the latest operation of the stack if that
+// operation has completed and was analyzed before. Otherwise the stack is analyzed, and the analysis
+// is cached once the operation has completed, unless CloudTrail lookups were cut short or CloudTrail
+// may still deliver the events of recent failures.
+func analyzeStackCached(ctx context.Context, cfnClient *cfnclient.Client, breaker *cloudtrail.Breaker, stackName string,
+	analyze func(stackName string) (*analyzer.StackAnalysis, error)) (*analyzer.StackAnalysis, error) {
+	dir, err := cache.DefaultDir()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+		return analyze(stackName)
+	}
+
+	operationEvents, err := cfnClient.GetLatestOperationEvents(ctx, stackName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve stack events: %w", err)
+	}
+	stackId, token, completed := latestOperation(operationEvents)
+	if !completed {
+		return analyze(stackName)
+	}
+
+	key := cache.Key(stackId, token)
+	cached, err := cache.Load(dir, key)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+	}
+	if cached != nil {
+		cached.Metadata = &analyzer.AnalysisMetadata{Sources: []string{analyzer.SourceCache}}
+		progressf("Using the analysis of %s from %s (--no-cache to analyze again)\n", stackName,
+			cached.AnalysisTime.Local().Format("2006-01-02 15:04:05"))
+		return cached, nil
+	}
+
+	analysis, err := analyze(stackName)
+	if err != nil || analysis.Partial || breaker.Err() != nil || awaitsDelivery(analysis) {
+		return analysis, err
+	}
+	if err := cache.Save(dir, key, analysis); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: Failed to cache analysis: %v\n", err)
+	}
+	return analysis, nil
+}
+
+// latestOperation returns the stack ARN and the token of the latest operation of a stack from its
+// events, newest first as returned by GetLatestOperationEvents, and whether the operation has
+// completed. Operations started without a client request token are identified by the event ID of
+// their start.
+func latestOperation(operationEvents []types.StackEvent) (stackId, token string, completed bool) {
+	if len(operationEvents) == 0 {
+		return "", "", false
+	}
+
+	start := operationEvents[len(operationEvents)-1]
+	stackId = aws.ToString(start.StackId)
+	if aws.ToString(start.PhysicalResourceId) != stackId || !strings.HasSuffix(string(start.ResourceStatus), "_IN_PROGRESS") {
+		// The events do not reach back to the start of the operation
+		return "", "", false
+	}
+	token = aws.ToString(start.ClientRequestToken)
+	if token == "" {
+		token = aws.ToString(start.EventId)
+	}
+
+	// The newest event of the stack itself tells whether the operation is still running
+	for _, event := range operationEvents {
+		if aws.ToString(event.PhysicalResourceId) == stackId && aws.ToString(event.ResourceType) == "AWS::CloudFormation::Stack" {
+			completed = !strings.HasSuffix(string(event.ResourceStatus), "_IN_PROGRESS")
+			break
+		}
+	}
+	return stackId, token, completed
+}
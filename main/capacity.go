@@ -0,0 +1,57 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"cfn-root-cause/analyzer"
+	"cfn-root-cause/cloudtrail"
+	"cfn-root-cause/patterns"
+)
+
+// Window around an EC2 launch failure in which its RunInstances call is searched; Auto Scaling
+// groups retry launches for several minutes before CloudFormation reports the failure
+const (
+	launchCallLookback  = 15 * time.Minute
+	launchCallLookahead = time.Minute
+)
+
+// detectCapacityFailures explains EC2 launches that failed for lack of capacity or an instance type
+// the Availability Zone does not offer. The instance type, zone and subnet the error messages do
+// not name are taken from the request parameters of the failed RunInstances call in CloudTrail.
+// A failed CloudTrail search is reported as a warning and ends the search; the findings are then
+// based on the error messages alone.
+func detectCapacityFailures(ctx context.Context, ctClient *cloudtrail.Client, errors []analyzer.CorrelatedError, stats *analyzer.AnalysisStats) []analyzer.Finding {
+	failures := patterns.CapacityFailures(errors)
+	if len(failures) == 0 {
+		return nil
+	}
+
+	progressf("Looking up %d failed EC2 launch(es) in CloudTrail...\n", len(failures))
+	phaseStart := time.Now()
+	var findings []analyzer.Finding
+	searchTrail := true
+	for _, failure := range failures {
+		if failure.LaunchCall == nil && searchTrail && ctx.Err() == nil {
+			failedAt := failure.Error.StackError.Timestamp
+			timeRange := cloudtrail.TimeRange{
+				StartTime: failedAt.Add(-launchCallLookback),
+				EndTime:   failedAt.Add(launchCallLookahead),
+			}
+			events, err := ctClient.SearchByEventName(ctx, timeRange, "RunInstances")
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+				searchTrail = false
+			}
+			if call := patterns.ClosestLaunchCall(failedAt.Add(launchCallLookahead), failure.InstanceType, events); call != nil {
+				failure.UseLaunchCall(*call)
+			}
+		}
+		findings = append(findings, patterns.DescribeCapacityFailure(failure))
+	}
+	stats.RecordPhase("Look up EC2 launches", phaseStart)
+
+	return findings
+}
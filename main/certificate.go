@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"cfn-root-cause/acmvalidation"
+	"cfn-root-cause/analyzer"
+	"cfn-root-cause/patterns"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation/types"
+)
+
+// detectCertificateValidation explains ACM certificates that failed or timed out waiting for DNS
+// validation. The validation records come from the certificate or, if the rollback deleted it,
+// from the stack events; each is looked up in the public hosted zones of the account. Failures to
+// read ACM or Route 53 are reported as a warning; the finding then lists the expected records only.
+func detectCertificateValidation(ctx context.Context, cfg aws.Config, events []types.StackEvent, errors []analyzer.CorrelatedError, stats *analyzer.AnalysisStats) []analyzer.Finding {
+	var findings []analyzer.Finding
+	var client *acmvalidation.Client
+	phaseStart := time.Now()
+
+	for _, err := range errors {
+		stackErr := err.StackError
+		if !patterns.IsCertificateFailure(stackErr) {
+			continue
+		}
+		if client == nil {
+			client = acmvalidation.NewClientWithConfig(cfg)
+		}
+		progressf("Checking DNS validation of certificate %s...\n", stackErr.LogicalResourceId)
+
+		var validations []analyzer.DNSValidation
+		if certificateArn := physicalResourceId(events, stackErr.LogicalResourceId); strings.HasPrefix(certificateArn, "arn:") {
+			var lookupErr error
+			validations, lookupErr = client.Validations(ctx, certificateArn)
+			if lookupErr != nil {
+				fmt.Fprintf(os.Stderr, "Warning: %v\n", lookupErr)
+			}
+		}
+		if len(validations) == 0 {
+			validations = patterns.ValidationRecords(events, stackErr.LogicalResourceId)
+		}
+		if len(validations) == 0 {
+			continue
+		}
+
+		zonesChecked := true
+		for i := range validations {
+			if checkErr := client.CheckRecord(ctx, &validations[i]); checkErr != nil {
+				fmt.Fprintf(os.Stderr, "Warning: %v\n", checkErr)
+				zonesChecked = false
+				break
+			}
+		}
+
+		if finding, ok := patterns.DescribeCertificateValidation(stackErr, validations, zonesChecked); ok {
+			findings = append(findings, finding)
+		}
+	}
+
+	if client != nil {
+		stats.RecordPhase("Check certificate validation", phaseStart)
+	}
+	return findings
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"cfn-root-cause/analyzer"
+	"cfn-root-cause/cfnclient"
+	"cfn-root-cause/cfntemplate"
+	"cfn-root-cause/patterns"
+
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation/types"
+)
+
+// detectCircularDependencies explains circular dependency failures with the cycle computed from
+// the dependencies in the template. Failures to fetch the template are reported as a warning; the
+// finding then lists the resources of the failure reason without the cycle.
+func detectCircularDependencies(ctx context.Context, cfnClient *cfnclient.Client, stackName string, events []types.StackEvent, errors []analyzer.CorrelatedError, stats *analyzer.AnalysisStats) []analyzer.Finding {
+	var findings []analyzer.Finding
+	templateDependencies := make(map[string][]cfntemplate.Dependency)
+	phaseStart := time.Now()
+
+	for _, err := range errors {
+		resources, ok := patterns.CircularDependency(err.StackError.ResourceStatusReason)
+		if !ok {
+			continue
+		}
+
+		target := templateOwner(stackName, events, err.StackError)
+		dependencies, seen := templateDependencies[target]
+		if !seen && target != "" {
+			progressf("Computing resource dependencies...\n")
+			var lookupErr error
+			dependencies, lookupErr = lookupDependencies(ctx, cfnClient, target)
+			if lookupErr != nil {
+				fmt.Fprintf(os.Stderr, "Warning: Failed to compute resource dependencies: %v\n", lookupErr)
+			}
+			templateDependencies[target] = dependencies
+		}
+
+		findings = append(findings, patterns.DescribeCircularDependency(err.StackError, resources,
+			patterns.FindCycle(resources, dependencies)))
+	}
+
+	if len(templateDependencies) > 0 {
+		stats.RecordPhase("Compute resource dependencies", phaseStart)
+	}
+	return findings
+}
+
+// lookupDependencies returns the dependencies between the resources of the template of the latest
+// deployment of a stack
+func lookupDependencies(ctx context.Context, cfnClient *cfnclient.Client, stackName string) ([]cfntemplate.Dependency, error) {
+	template, err := lookupTemplate(ctx, cfnClient, stackName)
+	if err != nil {
+		return nil, err
+	}
+	return template.Dependencies(), nil
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"cfn-root-cause/analyzer"
+	"cfn-root-cause/codebuild"
+	"cfn-root-cause/formatter"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+// codeBuildPlan describes what the CodeBuild integration mode decided to do
+type codeBuildPlan struct {
+	// StackName is the stack to analyze
+	StackName string
+
+	// Region is the region of the cross-region deploy action that deployed the stack, "" if the
+	// stack is in the region of the build
+	Region string
+
+	// ArtifactsDir is the directory the reports are written to
+	ArtifactsDir string
+
+	// Skip is set when there is no failure to analyze
+	Skip bool
+}
+
+// planCodeBuild detects the CodeBuild environment, locates the stack deployed by the
+// pipeline when no stack name was given, and decides whether an analysis is needed.
+// The analysis is skipped when the build is succeeding and the deploy action did not fail.
+func planCodeBuild(ctx context.Context, cfg aws.Config, opts *options) (*codeBuildPlan, error) {
+	env, err := codebuild.DetectEnvironment()
+	if err != nil {
+		return nil, err
+	}
+
+	plan := &codeBuildPlan{ArtifactsDir: opts.ArtifactsDir}
+	if len(opts.StackNames) > 0 {
+		plan.StackName = opts.StackNames[0]
+	}
+	if plan.ArtifactsDir == "" {
+		plan.ArtifactsDir = env.ArtifactsDir()
+	}
+
+	progressf("CodeBuild build %s (initiator: %s)\n", env.BuildID, env.Initiator)
+
+	deployFailed := false
+	if plan.StackName == "" {
+		stack, err := codebuild.NewClientWithConfig(cfg).FindDeployedStack(ctx, env)
+		if err != nil {
+			return nil, fmt.Errorf("failed to locate the stack deployed by the pipeline: %w", err)
+		}
+
+		progressf("Pipeline action %s/%s deployed stack %s (%s)\n",
+			stack.StageName, stack.ActionName, stack.StackName, stack.Status)
+
+		plan.StackName = stack.StackName
+		if stack.Region != "" && stack.Region != cfg.Region {
+			plan.Region = stack.Region
+		}
+		deployFailed = stack.Failed()
+	}
+
+	if env.Succeeding && !deployFailed {
+		progressf("Build is succeeding and the deployment did not fail, nothing to analyze\n")
+		plan.Skip = true
+	}
+
+	return plan, nil
+}
+
+// writeCodeBuildArtifacts writes the plain text and JUnit reports to the artifacts directory.
+// The JUnit file can be referenced from a CodeBuild report group.
+func writeCodeBuildArtifacts(dir string, analysis *analyzer.StackAnalysis, opts *options) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("failed to create artifacts directory '%s': %w", dir, err)
+	}
+
+	reports := map[string]string{
+		"report.txt": formatter.ReportPlain,
+		"report.xml": formatter.ReportJUnit,
+	}
+
+	for name, format := range reports {
+		output, err := formatter.FormatAs(analysis, formatter.Options{
+			Format:       format,
+			TemplatePath: opts.TemplatePath,
+			Language:     opts.Language,
+			Explain:      opts.Explain,
+		})
+		if err != nil {
+			return err
+		}
+
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(output), 0o644); err != nil {
+			return fmt.Errorf("failed to write report to '%s': %w", path, err)
+		}
+	}
+
+	progressf("Reports written to %s\n", dir)
+
+	return nil
+}
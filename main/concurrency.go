@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+
+	"cfn-root-cause/analyzer"
+)
+
+// stackResult is the outcome of the analysis of one stack
+type stackResult struct {
+	StackName string
+	Analysis  *analyzer.StackAnalysis
+	Err       error
+}
+
+// analyzeStacks analyzes the stacks, up to concurrency of them at a time. Related stacks found by
+// an analysis, such as exporting stacks, are queued as well. The results are returned in the order
+// the stacks were queued, so reports do not depend on which analysis finished first. Once the
+// context ends no further analysis is started; the stacks not analyzed report the context error.
+func analyzeStacks(ctx context.Context, stackNames []string, concurrency int, analyze func(stackName string) (*analyzer.StackAnalysis, error)) []stackResult {
+	type completion struct {
+		index    int
+		analysis *analyzer.StackAnalysis
+		err      error
+	}
+
+	queued := make(map[string]bool)
+	results := make([]stackResult, 0, len(stackNames))
+	for _, stackName := range stackNames {
+		if !queued[stackName] {
+			queued[stackName] = true
+			results = append(results, stackResult{StackName: stackName})
+		}
+	}
+
+	completions := make(chan completion)
+	next, running := 0, 0
+	for {
+		for running < concurrency && next < len(results) && ctx.Err() == nil {
+			index, stackName := next, results[next].StackName
+			next++
+			running++
+			go func() {
+				progressf("Analyzing stack: %s\n\n", stackName)
+				analysis, err := analyze(stackName)
+				completions <- completion{index: index, analysis: analysis, err: err}
+			}()
+		}
+		if running == 0 {
+			break
+		}
+
+		done := <-completions
+		running--
+		results[done.index].Analysis = done.analysis
+		results[done.index].Err = done.err
+		if done.err != nil {
+			continue
+		}
+		for _, related := range done.analysis.RelatedStacks {
+			if !queued[related] {
+				queued[related] = true
+				results = append(results, stackResult{StackName: related})
+			}
+		}
+	}
+
+	for i := next; i < len(results); i++ {
+		results[i].Err = ctx.Err()
+	}
+	return results
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"cfn-root-cause/formatter"
+	"cfn-root-cause/settings"
+)
+
+// resolveTheme determines the color theme of the text format.
+// The --theme flag wins over NO_COLOR, which wins over the config file;
+// custom colors from the config file are applied on top of the selected theme.
+func resolveTheme(flagTheme string, cfg *settings.Config) (*formatter.Theme, error) {
+	name := cfg.Theme
+	if os.Getenv("NO_COLOR") != "" {
+		name = "none"
+	}
+	if flagTheme != "" {
+		name = flagTheme
+	}
+	if name == "" {
+		name = formatter.DefaultThemeName
+	}
+
+	theme, ok := formatter.LookupTheme(name)
+	if !ok {
+		return nil, fmt.Errorf("unknown theme '%s': must be one of %s",
+			name, strings.Join(formatter.ThemeNames(), ", "))
+	}
+
+	if len(cfg.Colors) > 0 && name != "none" {
+		var err error
+		theme, err = theme.WithColors(cfg.Colors)
+		if err != nil {
+			return nil, fmt.Errorf("invalid colors in config file: %w", err)
+		}
+	}
+
+	return &theme, nil
+}
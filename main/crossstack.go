@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"cfn-root-cause/analyzer"
+	"cfn-root-cause/cfnclient"
+	"cfn-root-cause/patterns"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation/types"
+)
+
+// detectCrossStackDependencies explains failures caused by exports of other stacks, such as a
+// Fn::ImportValue of a missing export, with the current exporting and importing stacks. It also
+// returns the exporting stacks, so they are analyzed as well.
+func detectCrossStackDependencies(ctx context.Context, cfnClient *cfnclient.Client, stackName string, events []types.StackEvent, errors []analyzer.CorrelatedError, stats *analyzer.AnalysisStats) ([]analyzer.Finding, []string) {
+	references := patterns.ExportReferences(events, errors)
+	if len(references) == 0 {
+		return nil, nil
+	}
+
+	progressf("Looking up %d cross-stack export(s)...\n", len(references))
+	phaseStart := time.Now()
+	var findings []analyzer.Finding
+	var relatedStacks []string
+	for _, reference := range references {
+		export, err := lookupExport(ctx, cfnClient, reference.ExportName)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+		}
+		if export != nil && export.ExportingStack != "" && export.ExportingStack != stackName {
+			relatedStacks = append(relatedStacks, export.ExportingStack)
+		}
+		findings = append(findings, patterns.DescribeCrossStackDependency(stackName, reference, export))
+	}
+	stats.RecordPhase("Look up cross-stack exports", phaseStart)
+
+	return findings, relatedStacks
+}
+
+// lookupExport returns the current state of an export and the stacks importing it
+func lookupExport(ctx context.Context, cfnClient *cfnclient.Client, exportName string) (*patterns.Export, error) {
+	found, err := cfnClient.FindExport(ctx, exportName)
+	if err != nil {
+		return nil, err
+	}
+
+	export := &patterns.Export{Name: exportName}
+	if found == nil {
+		return export, nil
+	}
+	export.Value = aws.ToString(found.Value)
+	export.ExportingStack = cfnclient.StackName(aws.ToString(found.ExportingStackId))
+
+	if export.Importers, err = cfnClient.ListImports(ctx, exportName); err != nil {
+		return export, err
+	}
+	return export, nil
+}
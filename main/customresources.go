@@ -0,0 +1,135 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"cfn-root-cause/analyzer"
+	"cfn-root-cause/cfnclient"
+	"cfn-root-cause/cfntemplate"
+	"cfn-root-cause/lambdaactivity"
+	"cfn-root-cause/patterns"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation/types"
+)
+
+// customResourceTimeout is how long CloudFormation waits for a custom resource response by default
+const customResourceTimeout = time.Hour
+
+// detectCustomResourceTimeouts explains custom resources that did not respond in time with the
+// invocations, errors and log output of the backing Lambda function. Failures to read the template,
+// metrics or logs are reported as a warning; the finding then contains what could be read.
+func detectCustomResourceTimeouts(ctx context.Context, cfg aws.Config, cfnClient *cfnclient.Client, stackName string, events []types.StackEvent, errors []analyzer.CorrelatedError, stats *analyzer.AnalysisStats) []analyzer.Finding {
+	var findings []analyzer.Finding
+	templates := make(map[string]*cfntemplate.Template)
+	var activityClient *lambdaactivity.Client
+	phaseStart := time.Now()
+
+	for _, err := range errors {
+		stackErr := err.StackError
+		if !patterns.IsCustomResourceTimeout(stackErr) {
+			continue
+		}
+
+		target := templateOwner(stackName, events, stackErr)
+		template, seen := templates[target]
+		if !seen && target != "" {
+			progressf("Inspecting custom resource %s...\n", stackErr.LogicalResourceId)
+			var lookupErr error
+			template, lookupErr = lookupTemplate(ctx, cfnClient, target)
+			if lookupErr != nil {
+				fmt.Fprintf(os.Stderr, "Warning: Failed to retrieve the template of custom resource %s: %v\n",
+					stackErr.LogicalResourceId, lookupErr)
+			}
+			templates[target] = template
+		}
+
+		functionName := ""
+		if template != nil {
+			functionName = serviceTokenFunction(template, events, stackErr.LogicalResourceId)
+		}
+
+		var activity *analyzer.LambdaActivity
+		if functionName != "" {
+			if activityClient == nil {
+				activityClient = lambdaactivity.NewClientWithConfig(cfg)
+			}
+			start := operationStart(events, stackErr)
+			var lookupErr error
+			activity, lookupErr = activityClient.Activity(ctx, functionName, start, stackErr.Timestamp.Add(time.Minute))
+			if lookupErr != nil {
+				fmt.Fprintf(os.Stderr, "Warning: Failed to read the activity of function %s: %v\n", functionName, lookupErr)
+			}
+		}
+
+		findings = append(findings, patterns.DescribeCustomResourceTimeout(stackErr, functionName, activity))
+	}
+
+	if len(templates) > 0 {
+		stats.RecordPhase("Inspect custom resources", phaseStart)
+	}
+	return findings
+}
+
+// lookupTemplate fetches and parses the template of the latest deployment of a stack
+func lookupTemplate(ctx context.Context, cfnClient *cfnclient.Client, stackName string) (*cfntemplate.Template, error) {
+	body, _, err := latestDeployment(ctx, cfnClient, stackName)
+	if err != nil {
+		return nil, err
+	}
+	return cfntemplate.Parse(body)
+}
+
+// serviceTokenFunction returns the name of the Lambda function the ServiceToken of a custom
+// resource points to, given as an ARN or as Ref or Fn::GetAtt of a function, alias or version of
+// the same stack. Other tokens, such as SNS topics and imported values, yield "".
+func serviceTokenFunction(template *cfntemplate.Template, events []types.StackEvent, logicalId string) string {
+	var target string
+	switch token := template.Resources[logicalId].Properties["ServiceToken"].(type) {
+	case string:
+		name, _ := patterns.ServiceTokenFunction(token)
+		return name
+	case map[string]interface{}:
+		switch args := token["Fn::GetAtt"].(type) {
+		case []interface{}:
+			if len(args) > 0 {
+				target, _ = args[0].(string)
+			}
+		case string:
+			target, _, _ = strings.Cut(args, ".")
+		}
+		if ref, ok := token["Ref"].(string); ok {
+			target = ref
+		}
+	}
+	if target == "" {
+		return ""
+	}
+
+	physicalId := physicalResourceId(events, target)
+	if name, ok := patterns.ServiceTokenFunction(physicalId); ok {
+		return name
+	}
+	if template.Resources[target].Type == "AWS::Lambda::Function" {
+		return physicalId
+	}
+	return ""
+}
+
+// operationStart returns when CloudFormation started the failed operation of a resource, or
+// the default custom resource timeout before its failure if the start is not recorded
+func operationStart(events []types.StackEvent, stackErr analyzer.StackError) time.Time {
+	for _, event := range events {
+		if aws.ToString(event.LogicalResourceId) != stackErr.LogicalResourceId || event.Timestamp == nil {
+			continue
+		}
+		if strings.HasSuffix(string(event.ResourceStatus), "_IN_PROGRESS") && !event.Timestamp.After(stackErr.Timestamp) {
+			return *event.Timestamp
+		}
+	}
+	return stackErr.Timestamp.Add(-customResourceTimeout)
+}
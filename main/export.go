@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"cfn-root-cause/anonymize"
+	"cfn-root-cause/archive"
+	"cfn-root-cause/awsconfig"
+	"cfn-root-cause/cfnclient"
+	"cfn-root-cause/cloudtrail"
+	"cfn-root-cause/validator"
+)
+
+// runExport writes an anonymized snapshot of the events and matching CloudTrail events of a stack,
+// so a failure the analyzer handles badly can be reported without leaking infrastructure details.
+// The snapshot is taken from the live stack, or from the latest snapshot in an archive.
+func runExport(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("export", flag.ContinueOnError)
+	location := fs.String("to", "", "location to write the anonymized snapshot to: s3://bucket/prefix or a directory (required)")
+	fromArchive := fs.String("from-archive", "", "read the latest snapshot from this archive instead of the live stack")
+	var awsOpts awsconfig.Options
+	addAWSFlags(fs, &awsOpts)
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *location == "" || fs.NArg() != 1 {
+		return fmt.Errorf("usage: export --to LOCATION [--from-archive LOCATION] <stack-name>")
+	}
+	stackName := fs.Arg(0)
+	if err := validator.ValidateStackName(stackName); err != nil {
+		return err
+	}
+
+	awsCfg, err := loadAWSConfig(ctx, awsOpts)
+	if err != nil {
+		return err
+	}
+	store, err := archive.Open(awsCfg, *location)
+	if err != nil {
+		return err
+	}
+
+	var snapshot *archive.Snapshot
+	if *fromArchive != "" {
+		source, err := archive.Open(awsCfg, *fromArchive)
+		if err != nil {
+			return err
+		}
+		if snapshot, err = source.Latest(ctx, stackName); err != nil {
+			return err
+		}
+	} else {
+		ctClient := cloudtrail.NewClientWithConfig(awsCfg)
+		breaker := cloudtrail.NewBreaker(cloudtrail.DefaultBreakerThreshold)
+		ctClient.UseBreaker(breaker)
+
+		progressf("Capturing stack events and CloudTrail events...\n")
+		var captureErr error
+		snapshot, captureErr = archive.Capture(ctx, cfnclient.NewClientWithConfig(awsCfg), ctClient, stackName, nil)
+		if ctx.Err() != nil {
+			return &exitError{code: exitCodeInterrupted}
+		}
+		if snapshot == nil {
+			return captureErr
+		}
+		if captureErr != nil && !errors.Is(captureErr, cloudtrail.ErrBreakerOpen) {
+			fmt.Fprintf(os.Stderr, "Warning: %v\n", captureErr)
+		}
+		reportBreaker(breaker)
+	}
+
+	anonymized := anonymize.New().Snapshot(snapshot)
+	if err := store.Put(ctx, anonymized); err != nil {
+		return err
+	}
+
+	fmt.Printf("Exported anonymized stack %s as %s to %s\n", stackName, anonymized.StackName, *location)
+	fmt.Printf("Review the status reasons and CloudTrail messages before sharing; reproduce with:\n")
+	fmt.Printf("  cfn-analyzer --from-archive %s %s\n", *location, anonymized.StackName)
+	return nil
+}
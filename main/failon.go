@@ -0,0 +1,67 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"cfn-root-cause/analyzer"
+	"cfn-root-cause/classify"
+	"cfn-root-cause/rules"
+)
+
+// Kinds of errors --fail-on selects besides failure categories and rule severities
+const (
+	// failOnAny selects all errors, like --exit-code
+	failOnAny = "any"
+
+	// failOnPermanent selects errors that persist on retry, such as permissions and validation errors
+	failOnPermanent = "permanent"
+
+	// failOnTransient selects errors that usually disappear on retry, such as throttling
+	failOnTransient = "transient"
+)
+
+// failOnValues returns the values --fail-on accepts
+func failOnValues() []string {
+	values := []string{failOnAny, failOnPermanent, failOnTransient}
+	values = append(values, rules.Severities...)
+	return append(values, classify.Categories()...)
+}
+
+// validateFailOn checks the values of --fail-on
+func validateFailOn(values []string) error {
+	valid := failOnValues()
+	for _, value := range values {
+		if !slices.Contains(valid, value) {
+			return fmt.Errorf("unknown --fail-on value '%s': must be one of %s", value, strings.Join(valid, ", "))
+		}
+	}
+	return nil
+}
+
+// failsOn reports whether an error is selected by one of the --fail-on values. A severity selects
+// errors a classification rule gave that or a higher severity. Cancelled resources are consequences
+// of other failures and only selected by any or their category.
+func failsOn(values []string, err analyzer.CorrelatedError) bool {
+	category := classify.Category(err)
+	for _, value := range values {
+		switch {
+		case value == failOnAny || value == category:
+			return true
+		case value == failOnPermanent:
+			if category != classify.CategoryCancelled && !classify.Retryable(err) {
+				return true
+			}
+		case value == failOnTransient:
+			if category != classify.CategoryCancelled && classify.Retryable(err) {
+				return true
+			}
+		case slices.Contains(rules.Severities, value):
+			if err.Classification != nil && slices.Index(rules.Severities, err.Classification.Severity) >= slices.Index(rules.Severities, value) {
+				return true
+			}
+		}
+	}
+	return false
+}
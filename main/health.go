@@ -0,0 +1,54 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"cfn-root-cause/analyzer"
+	"cfn-root-cause/health"
+	"cfn-root-cause/patterns"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/arn"
+)
+
+// detectServiceIssues looks up the AWS Health issues of the services of the failed resources in
+// the region of the stack while they failed, and flags failures that coincided with one. Accounts
+// without AWS Health API access are skipped with a note.
+func detectServiceIssues(ctx context.Context, cfg aws.Config, stackId string, errors []analyzer.CorrelatedError, stats *analyzer.AnalysisStats) []analyzer.Finding {
+	if len(errors) == 0 {
+		return nil
+	}
+
+	region := cfg.Region
+	if parsed, err := arn.Parse(stackId); err == nil {
+		region = parsed.Region
+	}
+	start, end := errors[0].StackError.Timestamp, errors[0].StackError.Timestamp
+	for _, err := range errors {
+		if err.StackError.Timestamp.Before(start) {
+			start = err.StackError.Timestamp
+		}
+		if err.StackError.Timestamp.After(end) {
+			end = err.StackError.Timestamp
+		}
+	}
+	services := patterns.HealthServices(errors)
+
+	progressf("Checking AWS Health for issues of %s...\n", strings.Join(services, ", "))
+	phaseStart := time.Now()
+	events, err := health.NewClientWithConfig(cfg).ServiceIssues(ctx, region, services, start, end)
+	stats.RecordPhase("Check AWS Health", phaseStart)
+	if err == health.ErrSubscriptionRequired {
+		progressf("Skipping AWS Health check: %v\n", err)
+		return nil
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+	}
+
+	return patterns.DetectServiceIssues(errors, events)
+}
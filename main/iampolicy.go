@@ -0,0 +1,37 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"strings"
+
+	"cfn-root-cause/iampolicy"
+)
+
+// runIAMPolicy prints the minimal IAM policy needed to run the analyzer with the selected features
+func runIAMPolicy(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("iam-policy", flag.ContinueOnError)
+	var features []string
+	fs.Var((*stringList)(&features), "feature",
+		"include the permissions of an optional feature: "+strings.Join(iampolicy.FeatureNames(), ", ")+" (repeatable)")
+	all := fs.Bool("all", false, "include the permissions of all optional features")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() > 0 {
+		return fmt.Errorf("usage: iam-policy [--feature NAME] [--all]")
+	}
+
+	if *all {
+		features = iampolicy.FeatureNames()
+	}
+
+	policy, err := iampolicy.Policy(features)
+	if err != nil {
+		return err
+	}
+
+	fmt.Print(policy)
+	return nil
+}
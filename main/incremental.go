@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"cfn-root-cause/analyzer"
+	"cfn-root-cause/cache"
+	"cfn-root-cause/cfnclient"
+	"cfn-root-cause/cloudtrail"
+	"cfn-root-cause/history"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation/types"
+)
+
+// analyzeStackIncremental analyzes a stack like analyzeStack, but when the stack was analyzed
+// before it only fetches the stack events newer than the last analyzed event recorded in the
+// history file, and merges them with the events stored by that analysis
+func analyzeStackIncremental(ctx context.Context, cfg aws.Config, cfnClient *cfnclient.Client, breaker *cloudtrail.Breaker, limiter *cloudtrail.Limiter,
+	deliveryWait time.Duration, historyFile, stackName string) (*analyzer.StackAnalysis, error) {
+	stats := &analyzer.AnalysisStats{}
+
+	progressf("Retrieving stack events...\n")
+	phaseStart := time.Now()
+	events, stored, err := incrementalStackEvents(ctx, cfnClient, historyFile, stackName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve stack events: %w", err)
+	}
+	stats.RecordPhase("Retrieve stack events", phaseStart)
+
+	// Only include errors from today
+	analysis := analyzeStackEvents(ctx, cfg, cfnClient, breaker, limiter, deliveryWait, stackName, events, time.Now(), stats)
+	if stored {
+		analysis.LastEventId = aws.ToString(events[0].EventId)
+	}
+	return analysis, nil
+}
+
+// incrementalStackEvents returns the events of a stack, newest first, and stores them for the next
+// analysis; stored reports whether that succeeded. Stack events never change once written, so the
+// events of an earlier analysis only need the events recorded since. Unless the last analyzed event
+// is found, all events are fetched, e.g. for a stack deleted and created again under the same name.
+func incrementalStackEvents(ctx context.Context, cfnClient *cfnclient.Client, historyFile, stackName string) (events []types.StackEvent, stored bool, err error) {
+	dir, err := cache.DefaultEventsDir()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+		events, err := cfnClient.GetStackEvents(ctx, stackName)
+		return events, false, err
+	}
+
+	var previous []types.StackEvent
+	var previousKey string
+	stackId, lastEventId := lastAnalyzedEvent(historyFile, stackName)
+	if lastEventId != "" {
+		previousKey = cache.Key(stackId, lastEventId)
+		previous, err = cache.LoadEvents(dir, previousKey)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+		}
+	}
+
+	if len(previous) == 0 || aws.ToString(previous[0].EventId) != lastEventId {
+		events, err = cfnClient.GetStackEvents(ctx, stackName)
+		if err != nil {
+			return nil, false, err
+		}
+	} else {
+		newEvents, found, err := cfnClient.GetStackEventsSince(ctx, stackName, lastEventId)
+		if err != nil {
+			return nil, false, err
+		}
+		events = newEvents
+		if found {
+			progressf("Fetched %d stack event(s) since the last analysis\n", len(newEvents))
+			events = append(newEvents, previous...)
+		}
+	}
+	if len(events) == 0 {
+		return events, false, nil
+	}
+
+	key := cache.Key(aws.ToString(events[0].StackId), aws.ToString(events[0].EventId))
+	if key == previousKey {
+		return events, true, nil
+	}
+	if err := cache.SaveEvents(dir, key, events); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: Failed to store stack events: %v\n", err)
+		return events, false, nil
+	}
+	if previousKey != "" {
+		if err := cache.RemoveEvents(dir, previousKey); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+		}
+	}
+	return events, true, nil
+}
+
+// lastAnalyzedEvent returns the stack ARN and the newest stack event of the latest analysis of the
+// stack in the history file whose events were stored; empty if there is none
+func lastAnalyzedEvent(historyFile, stackName string) (stackId, eventId string) {
+	path, err := historyPath(historyFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+		return "", ""
+	}
+	records, err := history.Load(path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+		return "", ""
+	}
+	return history.LastAnalyzedEvent(records, stackName)
+}
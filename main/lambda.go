@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"cfn-root-cause/analyzer"
+	"cfn-root-cause/cloudtrail"
+	"cfn-root-cause/lambdaaccount"
+	"cfn-root-cause/patterns"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation/types"
+)
+
+// lambdaCallLookback is how long before a Lambda function failure its failed call is searched in CloudTrail
+const lambdaCallLookback = 10 * time.Minute
+
+// detectLambdaFailures explains Lambda functions that failed on code size, concurrency or VPC
+// network interfaces, with the current Lambda quotas of the account and the request parameters
+// of the failed call in CloudTrail. A failed CloudTrail search is reported as a warning and ends
+// the search; failures to read the quotas are reported as a warning as well.
+func detectLambdaFailures(ctx context.Context, cfg aws.Config, ctClient *cloudtrail.Client, events []types.StackEvent, errors []analyzer.CorrelatedError, stats *analyzer.AnalysisStats) []analyzer.Finding {
+	failures := patterns.LambdaFailures(errors)
+	if len(failures) == 0 {
+		return nil
+	}
+
+	progressf("Inspecting %d failed Lambda function(s)...\n", len(failures))
+	phaseStart := time.Now()
+	var settings *analyzer.LambdaAccountSettings
+	settingsRead := false
+	var findings []analyzer.Finding
+	searchTrail := true
+	for _, failure := range failures {
+		// Network failures do not depend on the quotas of the account
+		if failure.Kind != patterns.LambdaNetwork && !settingsRead {
+			settingsRead = true
+			var err error
+			if settings, err = lambdaaccount.NewClientWithConfig(cfg).Settings(ctx); err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+			}
+		}
+		if failure.Call == nil && searchTrail && ctx.Err() == nil {
+			failedAt := failure.Error.StackError.Timestamp
+			timeRange := cloudtrail.TimeRange{StartTime: failedAt.Add(-lambdaCallLookback), EndTime: failedAt.Add(time.Minute)}
+			var calls []analyzer.CloudTrailEvent
+			for _, eventName := range patterns.LambdaCallNames(failure.Kind) {
+				found, searchErr := ctClient.SearchByEventName(ctx, timeRange, eventName)
+				if searchErr != nil {
+					fmt.Fprintf(os.Stderr, "Warning: %v\n", searchErr)
+					searchTrail = false
+					break
+				}
+				calls = append(calls, found...)
+			}
+			functionName := physicalResourceId(events, failure.Error.StackError.LogicalResourceId)
+			failure.Call = patterns.ClosestLambdaCall(timeRange.EndTime, functionName, calls)
+		}
+		findings = append(findings, patterns.DescribeLambdaFailure(failure, settings))
+	}
+	stats.RecordPhase("Inspect Lambda functions", phaseStart)
+
+	return findings
+}
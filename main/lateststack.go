@@ -0,0 +1,78 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"cfn-root-cause/cfnclient"
+	"cfn-root-cause/validator"
+
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation/types"
+	"golang.org/x/term"
+)
+
+// latestStackCandidates is the number of most recently updated stacks offered when no stack name is given
+const latestStackCandidates = 5
+
+// pickLatestStack returns the most recently updated stack in one of the statuses, such as the failure
+// statuses for --latest-failed, or of all stacks without statuses. The most recently updated stack is
+// often not the failed one, so in an interactive terminal the user confirms it or picks one of the
+// stacks updated before it, unless skipConfirmation is set; pipelines analyze the most recent stack as
+// before and only see the alternatives.
+func pickLatestStack(ctx context.Context, cfnClient *cfnclient.Client, statuses []types.StackStatus, skipConfirmation bool) (string, error) {
+	candidates, err := validator.LatestStacks(ctx, cfnClient, statuses, latestStackCandidates)
+	if err != nil {
+		return "", fmt.Errorf("failed to find latest stack: %w", err)
+	}
+	latest := candidates[0]
+
+	interactive := term.IsTerminal(int(os.Stdin.Fd())) && term.IsTerminal(int(os.Stderr.Fd()))
+	if skipConfirmation || !interactive || len(candidates) == 1 {
+		progressf("Analyzing %s (%s, updated %s)\n", latest.StackName, latest.Status, formatCandidateTime(latest))
+		if len(candidates) > 1 && !skipConfirmation {
+			names := make([]string, 0, len(candidates)-1)
+			for _, candidate := range candidates[1:] {
+				names = append(names, candidate.StackName)
+			}
+			progressf("Other recently updated stacks: %s\n", strings.Join(names, ", "))
+		}
+		return latest.StackName, nil
+	}
+
+	fmt.Fprintf(os.Stderr, "\nMost recently updated stacks:\n")
+	w := tabwriter.NewWriter(os.Stderr, 0, 0, 2, ' ', 0)
+	for i, candidate := range candidates {
+		fmt.Fprintf(w, "  %d\t%s\t%s\t%s\n", i+1, candidate.StackName, candidate.Status, formatCandidateTime(candidate))
+	}
+	if err := w.Flush(); err != nil {
+		return "", err
+	}
+
+	fmt.Fprintf(os.Stderr, "Analyze %s? [Y/n or 1-%d] ", latest.StackName, len(candidates))
+	answer, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil {
+		return "", fmt.Errorf("failed to read confirmation: %w", err)
+	}
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	switch answer {
+	case "", "y", "yes":
+		return latest.StackName, nil
+	case "n", "no":
+		return "", fmt.Errorf("no stack selected; give the name of the stack to analyze")
+	}
+	choice, err := strconv.Atoi(answer)
+	if err != nil || choice < 1 || choice > len(candidates) {
+		return "", fmt.Errorf("invalid choice '%s': must be y, n or a number from 1 to %d", answer, len(candidates))
+	}
+	return candidates[choice-1].StackName, nil
+}
+
+// formatCandidateTime formats when a stack was last updated in local time
+func formatCandidateTime(candidate validator.StackCandidate) string {
+	return candidate.UpdatedTime.Local().Format("2006-01-02 15:04:05")
+}
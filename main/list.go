@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"cfn-root-cause/awsconfig"
+	"cfn-root-cause/cfnclient"
+	"cfn-root-cause/extractor"
+	"cfn-root-cause/formatter"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation/types"
+)
+
+// failedStack is a row of the list subcommand
+type failedStack struct {
+	Name        string
+	Status      string
+	LastUpdate  time.Time
+	FirstReason string
+}
+
+// runList prints a table of stacks with failure statuses, most recently updated first,
+// together with the reason of the first failed resource of the latest operation
+func runList(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("list", flag.ContinueOnError)
+	maxLength := fs.Int("max-message-length", formatter.DefaultMaxMessageLength,
+		"truncate reasons after this many characters; 0 disables truncation")
+	var awsOpts awsconfig.Options
+	addAWSFlags(fs, &awsOpts)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() > 0 {
+		return fmt.Errorf("usage: list [--max-message-length N]")
+	}
+	if *maxLength < 0 {
+		return fmt.Errorf("invalid max message length %d: must not be negative", *maxLength)
+	}
+
+	awsCfg, err := loadAWSConfig(ctx, awsOpts)
+	if err != nil {
+		return err
+	}
+	cfnClient := cfnclient.NewClientWithConfig(awsCfg)
+
+	summaries, err := cfnClient.ListStacksWithStatus(ctx, cfnclient.FailedStackStatuses)
+	if err != nil {
+		return err
+	}
+
+	progressf("Found %d stack(s) with failure status\n", len(summaries))
+
+	stacks := make([]failedStack, 0, len(summaries))
+	for _, summary := range summaries {
+		stack := failedStack{
+			Name:       aws.ToString(summary.StackName),
+			Status:     string(summary.StackStatus),
+			LastUpdate: lastUpdate(summary),
+		}
+
+		reason, err := firstFailureReason(ctx, cfnClient, aws.ToString(summary.StackId))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+		}
+		stack.FirstReason = formatter.Truncate(reason, *maxLength)
+
+		stacks = append(stacks, stack)
+	}
+
+	sort.SliceStable(stacks, func(i, j int) bool {
+		return stacks[i].LastUpdate.After(stacks[j].LastUpdate)
+	})
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tSTATUS\tLAST UPDATE\tFIRST ERROR")
+	for _, stack := range stacks {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", stack.Name, stack.Status,
+			stack.LastUpdate.UTC().Format("2006-01-02 15:04:05 UTC"), stack.FirstReason)
+	}
+
+	return w.Flush()
+}
+
+// firstFailureReason returns the reason of the earliest failed resource in the latest stack operation
+func firstFailureReason(ctx context.Context, cfnClient *cfnclient.Client, stackID string) (string, error) {
+	events, err := cfnClient.GetLatestOperationEvents(ctx, stackID)
+	if err != nil {
+		return "", err
+	}
+
+	// Events are ordered newest first, so the last error is the first failure
+	stackErrors := extractor.ExtractErrors(events)
+	if len(stackErrors) == 0 {
+		return "", nil
+	}
+
+	reason := stackErrors[len(stackErrors)-1].ResourceStatusReason
+	return strings.Join(strings.Fields(reason), " "), nil
+}
+
+// lastUpdate returns the time a stack was last updated, or created if it was never updated
+func lastUpdate(summary types.StackSummary) time.Time {
+	if summary.LastUpdatedTime != nil {
+		return *summary.LastUpdatedTime
+	}
+	return aws.ToTime(summary.CreationTime)
+}
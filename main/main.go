@@ -2,116 +2,722 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"iter"
 	"os"
+	"os/signal"
+	"path"
+	"sort"
+	"strings"
 	"time"
 
+	"cfn-root-cause/aisummary"
 	"cfn-root-cause/analyzer"
+	"cfn-root-cause/archive"
+	"cfn-root-cause/awserrors"
+	"cfn-root-cause/baseline"
 	"cfn-root-cause/cfnclient"
 	"cfn-root-cause/cloudtrail"
 	"cfn-root-cause/correlator"
 	"cfn-root-cause/extractor"
+	"cfn-root-cause/filter"
 	"cfn-root-cause/formatter"
+	"cfn-root-cause/history"
+	"cfn-root-cause/identity"
+	"cfn-root-cause/ignore"
+	"cfn-root-cause/ophealth"
+	"cfn-root-cause/opscenter"
+	"cfn-root-cause/patterns"
+	"cfn-root-cause/plugins"
+	"cfn-root-cause/redact"
+	"cfn-root-cause/rules"
+	"cfn-root-cause/settings"
+	"cfn-root-cause/sorter"
 	"cfn-root-cause/validator"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation/types"
+	"golang.org/x/term"
 )
 
+// exitCodeErrorsFound is the exit status used with --exit-code when errors remain in the report
+const exitCodeErrorsFound = 2
+
+// exitCodeCloudTrailFailed is the exit status used with --strict when CloudTrail lookups failed
+const exitCodeCloudTrailFailed = 3
+
+// exitCodeInterrupted is the exit status of a run interrupted with Ctrl+C, following the shell convention
+const exitCodeInterrupted = 130
+
+// exitError ends the program with a specific exit status without printing an error message
+type exitError struct {
+	code int
+}
+
+// Error returns a description of the exit status
+func (e *exitError) Error() string {
+	return fmt.Sprintf("exit status %d", e.code)
+}
+
 func main() {
-	ctx := context.Background()
+	// Ctrl+C cancels the context, so the results gathered so far are still reported.
+	// A second Ctrl+C terminates immediately.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+
+	err := run(ctx, os.Args[1:])
+	if err != nil && awserrors.IsSSOSessionExpired(err) && offerSSOLogin() {
+		err = run(ctx, os.Args[1:])
+	}
 
-	if err := run(ctx); err != nil {
+	if err != nil {
+		var exitErr *exitError
+		if errors.As(err, &exitErr) {
+			os.Exit(exitErr.code)
+		}
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 		os.Exit(1)
 	}
 }
 
-// run executes the main analysis workflow
-func run(ctx context.Context) error {
+// subcommands maps subcommand names to their implementation
+var subcommands = map[string]func(ctx context.Context, args []string) error{
+	"serve":      runServe,
+	"schema":     runSchema,
+	"list":       runList,
+	"stats":      runStats,
+	"remediate":  runRemediate,
+	"stuck":      runStuck,
+	"resource":   runResource,
+	"operations": runOperations,
+	"watch":      runWatch,
+	"preflight":  runPreflight,
+	"iam-policy": runIAMPolicy,
+	"archive":    runArchive,
+	"export":     runExport,
+	"version":    runVersion,
+}
+
+// run executes the subcommand named by the first argument, or the main analysis workflow
+func run(ctx context.Context, args []string) error {
+	if len(args) > 0 {
+		if subcommand, ok := subcommands[args[0]]; ok {
+			return subcommand(ctx, args[1:])
+		}
+	}
+
 	// Parse command line arguments
-	stackName, err := parseArgs()
+	opts, err := parseArgs(args)
+	if err != nil {
+		return err
+	}
+
+	// Load user preferences such as the color theme
+	cfg, err := settings.Load(opts.ConfigPath)
+	if err != nil {
+		return err
+	}
+
+	theme, err := resolveTheme(opts.Theme, cfg)
 	if err != nil {
 		return err
 	}
 
-	fmt.Println("CloudFormation Error Analyzer")
-	fmt.Println()
+	// The config file can make the latest failed stack the default when no stack is given
+	if cfg.LatestFailed && len(opts.StackNames) == 0 && !opts.AllFailed && !opts.CodeBuild && !opts.Stdin && opts.ProvisionedProduct == "" {
+		opts.LatestFailed = true
+	}
 
-	// Initialize CloudFormation client
-	cfnClient, err := cfnclient.NewClient(ctx)
+	// Load rules for known acceptable errors
+	ignoreRules, err := ignore.Load(opts.IgnoreFile)
 	if err != nil {
-		return fmt.Errorf("failed to initialize CloudFormation client: %w", err)
+		return err
 	}
 
-	// Determine which stack to analyze
-	stackName, err = resolveStackName(ctx, cfnClient, stackName)
+	// Load the classification rules
+	classificationRules, err := rules.Load(opts.RulesFile)
 	if err != nil {
 		return err
 	}
 
-	fmt.Printf("Analyzing stack: %s\n", stackName)
-	fmt.Println()
+	// Find the enricher plugins, so a wrong directory stops the run before the analysis
+	var enrichers []plugins.Plugin
+	if !opts.NoPlugins {
+		enrichers, err = plugins.Discover(opts.PluginsDir)
+		if err != nil {
+			return err
+		}
+	}
 
-	// Validate the stack exists
-	if err := validator.ValidateStackExists(ctx, cfnClient, stackName); err != nil {
+	var knownErrors *baseline.Baseline
+	if opts.BaselinePath != "" {
+		knownErrors, err = baseline.Load(opts.BaselinePath)
+		if err != nil {
+			return err
+		}
+	}
+
+	progressf("CloudFormation Error Analyzer\n\n")
+
+	// Load the AWS configuration once, so credentials are resolved once for all clients
+	awsCfg, err := loadAWSConfig(ctx, opts.AWS)
+	if err != nil {
+		return err
+	}
+
+	// Create the AI provider up front, so configuration errors stop the run before the analysis
+	var summarizer aisummary.Provider
+	if opts.AISummary {
+		summarizer, err = aisummary.New(cfg.AI, awsCfg)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Create the OpsCenter client up front, so configuration errors stop the run before the analysis
+	var opsItems *opscenter.Client
+	if opts.OpsItem {
+		opsItems, err = opscenter.New(cfg.OpsCenter, awsCfg)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Create the sinks up front, so invalid destinations stop the run before the analysis
+	sinks, err := newReportSinks(opts, cfg, awsCfg)
+	if err != nil {
 		return err
 	}
 
-	// Perform the analysis
-	analysis, err := analyzeStack(ctx, cfnClient, stackName)
+	// In CodeBuild mode, locate the stack deployed by the pipeline and only analyze failures
+	var buildPlan *codeBuildPlan
+	if opts.CodeBuild {
+		buildPlan, err = planCodeBuild(ctx, awsCfg, opts)
+		if err != nil {
+			return err
+		}
+		if buildPlan.Skip {
+			return nil
+		}
+		opts.StackNames = []string{buildPlan.StackName}
+		if buildPlan.Region != "" {
+			// Cross-region deploy actions deploy the stack outside the region of the build
+			progressf("Analyzing stack %s in %s, the region of the deploy action\n", buildPlan.StackName, buildPlan.Region)
+			awsCfg = awsCfg.Copy()
+			awsCfg.Region = buildPlan.Region
+		}
+	}
+
+	// Service Catalog provisioned products are analyzed through the stack provisioning them
+	var provisionedProduct *analyzer.ProvisionedProduct
+	if opts.ProvisionedProduct != "" {
+		provisionedProduct, err = resolveProvisionedProduct(ctx, awsCfg, opts.ProvisionedProduct)
+		if err != nil {
+			return err
+		}
+		opts.StackNames = []string{cfnclient.StackName(provisionedProduct.StackId)}
+	}
+
+	breaker := cloudtrail.NewBreaker(cloudtrail.DefaultBreakerThreshold)
+	regional := newRegionalAnalyzer(ctx, awsCfg, breaker, opts)
+	cfnClient, analyze := regional.cfnClient, regional.analyze
+
+	var callerIdentity *analyzer.CallerIdentity
+	var stackNames []string
+	var multi *multiRegion
+	source := analyzer.SourceStackEvents
+	if opts.FromArchive != "" {
+		// Archived stacks may no longer exist, so neither the stack nor CloudTrail is queried
+		store, err := archive.Open(awsCfg, opts.FromArchive)
+		if err != nil {
+			return err
+		}
+		analyze = func(stackName string) (*analyzer.StackAnalysis, error) {
+			return analyzeArchivedStack(ctx, store, stackName)
+		}
+		source = analyzer.SourceArchive
+		stackNames = opts.StackNames
+	} else if opts.Stdin {
+		// Piped events come from an environment without access to the stack, so it is not queried
+		if term.IsTerminal(int(os.Stdin.Fd())) {
+			return fmt.Errorf("--stdin requires the output of 'aws cloudformation describe-stack-events' piped to stdin")
+		}
+		events, err := readStackEvents(os.Stdin)
+		if err != nil {
+			return err
+		}
+		analyze = func(stackName string) (*analyzer.StackAnalysis, error) {
+			return analyzeStdinEvents(ctx, stackName, events), nil
+		}
+		source = analyzer.SourceStdin
+		stackNames = opts.StackNames
+		if len(stackNames) == 0 {
+			stackNames = []string{aws.ToString(events[0].StackName)}
+		}
+	} else {
+		// Record where the analysis runs, so shared reports are unambiguous
+		callerIdentity = lookupCallerIdentity(ctx, awsCfg)
+
+		// Determine which stacks to analyze
+		if len(opts.Regions) > 0 {
+			// Each region is searched and analyzed with its own configuration and CloudFormation client
+			multi = newMultiRegion(ctx, awsCfg, breaker, opts)
+			stackNames, err = multi.resolveStackNames(ctx, opts)
+		} else {
+			checkTrailRecording(ctx, awsCfg)
+			stackNames, err = resolveStackNames(ctx, cfnClient, opts)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	// Analyze each stack, narrow the reports to the requested errors and set known acceptable ones aside
+	var analyses []*analyzer.StackAnalysis
+	var records []history.Record
+	analyzeQueued := withMetadata(analyze, source, awsCfg.Region)
+	if multi != nil {
+		analyzeQueued = multi.analyzer(source)
+	}
+	results := analyzeStacks(ctx, stackNames, opts.Concurrency, analyzeQueued)
+	for i, result := range results {
+		analysis, err := result.Analysis, result.Err
+		if err != nil && ctx.Err() != nil {
+			continue
+		}
+		if err != nil {
+			if len(results) == 1 {
+				return err
+			}
+			fmt.Fprintf(os.Stderr, "Warning: Skipping stack %s: %v\n", result.StackName, err)
+			continue
+		}
+
+		stackClient, stackName := cfnClient, result.StackName
+		analysis.Identity = callerIdentity
+		if multi != nil {
+			var region string
+			region, stackClient, stackName = multi.target(result.StackName)
+			if callerIdentity != nil {
+				identity := *callerIdentity
+				identity.Region = region
+				analysis.Identity = &identity
+			}
+		}
+		if callerIdentity != nil && analysis.Metadata.AccountID == "" {
+			analysis.Metadata.AccountID = callerIdentity.AccountID
+		}
+		if i == 0 {
+			analysis.ProvisionedProduct = provisionedProduct
+		}
+		if err := rules.Apply(analysis, classificationRules); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+		}
+		records = append(records, history.NewRecord(analysis))
+		if opts.TemplateDiff && ctx.Err() == nil {
+			analysis.TemplateDiff = lookupTemplateDiff(ctx, stackClient, stackName)
+		}
+		if opts.StackContext && ctx.Err() == nil {
+			analysis.StackContext = lookupStackContext(ctx, stackClient, stackName)
+		}
+		filter.Apply(analysis, opts.Filter)
+		ignore.Apply(analysis, ignoreRules)
+		analyses = append(analyses, analysis)
+	}
+	reportBreaker(breaker)
+
+	interrupted := ctx.Err() != nil
+	if interrupted {
+		if len(analyses) == 0 {
+			return fmt.Errorf("interrupted before any stack was analyzed")
+		}
+		fmt.Fprintf(os.Stderr, "Warning: Interrupted; reporting partial results of %d of %d stack(s)\n",
+			len(analyses), len(results))
+	}
+
+	if len(analyses) == 0 {
+		return fmt.Errorf("none of the %d stack(s) could be analyzed", len(results))
+	}
+
+	// Record all errors, before filtering and ignoring, for the stats subcommand
+	if !opts.NoHistory {
+		recordHistory(opts.HistoryFile, records)
+	}
+
+	// Record the new baseline before applying the old one, so it covers all current errors
+	if opts.WriteBaselinePath != "" {
+		newBaseline := baseline.New(analyses...)
+		if err := baseline.Write(opts.WriteBaselinePath, newBaseline); err != nil {
+			return err
+		}
+		progressf("Baseline with %d error(s) written to %s\n", len(newBaseline.Errors), opts.WriteBaselinePath)
+	}
+
+	failingErrors := 0
+	for _, analysis := range analyses {
+		baseline.Apply(analysis, knownErrors)
+		if err := sorter.SortErrors(analysis.Errors, opts.Sort); err != nil {
+			return err
+		}
+		for _, err := range analysis.Errors {
+			if len(opts.FailOn) == 0 || failsOn(opts.FailOn, err) {
+				failingErrors++
+			}
+		}
+	}
+
+	if len(enrichers) > 0 && !interrupted {
+		enrichWithPlugins(ctx, enrichers, analyses)
+	}
+
+	// OpsItems stay in the account, so they are built from the unredacted analyses
+	var pendingOpsItems []*opscenter.OpsItem
+	if opsItems != nil {
+		pendingOpsItems = prepareOpsItems(opsItems, analyses)
+	}
+
+	// Redact after the plugins, which may need the real identifiers, and before anything leaves the machine
+	if opts.Redact {
+		redactor := redact.New()
+		for _, analysis := range analyses {
+			redactor.Analysis(analysis)
+		}
+	}
+
+	if summarizer != nil && !interrupted {
+		summarizeAnalyses(ctx, summarizer, analyses)
+	}
+
+	if buildPlan != nil {
+		if err := writeCodeBuildArtifacts(buildPlan.ArtifactsDir, analyses[0], opts); err != nil {
+			return err
+		}
+	}
+
+	// Format the results and deliver them to each sink
+	err = deliverReport(ctx, sinks, analyses, formatter.Options{
+		TemplatePath:     opts.TemplatePath,
+		ShowStats:        opts.ShowStats,
+		Explain:          opts.Explain,
+		Language:         opts.Language,
+		MaxMessageLength: opts.MaxMessageLength,
+		Theme:            theme,
+		Sections:         opts.Sections,
+	}, interrupted)
 	if err != nil {
 		return err
 	}
 
-	// Format and display results
-	output := formatter.FormatAnalysisResults(analysis)
-	fmt.Print(output)
+	if opts.NotifyDesktop {
+		notifyCompletion(ctx, analyses, interrupted)
+	}
+
+	if interrupted {
+		return &exitError{code: exitCodeInterrupted}
+	}
+
+	if opsItems != nil {
+		if err := createOpsItems(ctx, opsItems, pendingOpsItems); err != nil {
+			return err
+		}
+	}
+
+	if opts.Strict && breaker.LookupFailures() > 0 {
+		fmt.Fprintf(os.Stderr, "Error: %d CloudTrail lookup(s) failed, so the report lacks CloudTrail details (--strict)\n",
+			breaker.LookupFailures())
+		return &exitError{code: exitCodeCloudTrailFailed}
+	}
+
+	if opts.ExitCode && failingErrors > 0 {
+		return &exitError{code: exitCodeErrorsFound}
+	}
 
 	return nil
 }
 
-// resolveStackName determines the stack name to analyze.
-// If a stack name is provided, it returns that name.
-// Otherwise, it finds the most recently updated stack.
-func resolveStackName(ctx context.Context, cfnClient *cfnclient.Client, providedName string) (string, error) {
-	if providedName != "" {
-		return providedName, nil
+// lookupCallerIdentity returns the account, region and principal of the current credentials.
+// Failures are reported as a warning, since the identity is informational only.
+func lookupCallerIdentity(ctx context.Context, cfg aws.Config) *analyzer.CallerIdentity {
+	callerIdentity, err := identity.NewClientWithConfig(cfg).CallerIdentity(ctx)
+	if err == nil {
+		return callerIdentity
 	}
 
-	fmt.Println("No stack name provided, finding most recently updated stack...")
+	fmt.Fprintf(os.Stderr, "Warning: Failed to determine caller identity: %v\n", err)
+	return nil
+}
 
-	stackName, err := validator.GetLatestStack(ctx, cfnClient)
+// recordHistory appends the records to the history file.
+// Failures are reported as a warning, since the history does not affect the current report.
+func recordHistory(path string, records []history.Record) {
+	path, err := historyPath(path)
+	if err == nil {
+		err = history.Append(path, records...)
+	}
 	if err != nil {
-		return "", fmt.Errorf("failed to find latest stack: %w", err)
+		fmt.Fprintf(os.Stderr, "Warning: Failed to record analysis history: %v\n", err)
 	}
+}
 
-	return stackName, nil
+// formatReport formats a single stack report, or an aggregate report when several stacks were analyzed
+func formatReport(analyses []*analyzer.StackAnalysis, opts formatter.Options) (string, error) {
+	if len(analyses) == 1 {
+		return formatter.FormatAs(analyses[0], opts)
+	}
+	return formatter.FormatAggregateAs(analyses, opts)
+}
+
+// progressf prints progress information to stderr so stdout only contains the report
+func progressf(format string, a ...interface{}) {
+	fmt.Fprintf(os.Stderr, format, a...)
+}
+
+// resolveStackNames determines the stacks to analyze: all stacks with a failure status for
+// --all-failed, the given stacks and the stacks matching the given glob patterns, in the order of
+// the arguments, or the most recently updated stack, with a failure status for --latest-failed,
+// after confirmation
+func resolveStackNames(ctx context.Context, cfnClient *cfnclient.Client, opts *options) ([]string, error) {
+	if opts.AllFailed {
+		progressf("Finding stacks with failure status...\n")
+
+		statuses, err := discoveryStatuses(cfnclient.FailedStackStatuses, opts)
+		if err != nil {
+			return nil, err
+		}
+		summaries, err := cfnClient.ListStacksWithStatus(ctx, statuses)
+		if err != nil {
+			return nil, err
+		}
+		if len(summaries) == 0 {
+			return nil, fmt.Errorf("no stacks with failure status found")
+		}
+
+		return stackNamesOf(summaries), nil
+	}
+
+	if len(opts.StackNames) > 0 {
+		var stackNames []string
+		var summaries []types.StackSummary
+		listed := false
+		seen := make(map[string]bool)
+		for _, name := range opts.StackNames {
+			matching := []string{name}
+			if !isStackPattern(name) {
+				// Validate the stack exists
+				if err := validateStack(ctx, cfnClient, name); err != nil {
+					return nil, err
+				}
+			} else {
+				progressf("Finding stacks matching %s...\n", name)
+
+				// The stacks are listed once for all patterns
+				if !listed {
+					var err error
+					summaries, err = cfnClient.ListStacksWithStatus(ctx, nil)
+					if err != nil {
+						return nil, err
+					}
+					listed = true
+				}
+				matching = matchingStackNames(summaries, name)
+				if len(matching) == 0 {
+					return nil, fmt.Errorf("no stacks match '%s'", name)
+				}
+			}
+
+			// A stack given several times, or matched by several patterns, is analyzed once
+			for _, stackName := range matching {
+				if !seen[stackName] {
+					seen[stackName] = true
+					stackNames = append(stackNames, stackName)
+				}
+			}
+		}
+		return stackNames, nil
+	}
+
+	base := validator.ActiveStackStatuses
+	if opts.LatestFailed {
+		progressf("No stack name provided, finding most recently failed stack...\n")
+		base = cfnclient.FailedStackStatuses
+	} else {
+		progressf("No stack name provided, finding most recently updated stack...\n")
+	}
+	statuses, err := discoveryStatuses(base, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	stackName, err := pickLatestStack(ctx, cfnClient, statuses, opts.Yes)
+	if errors.Is(err, validator.ErrNoStacksFound) && opts.LatestFailed {
+		return nil, fmt.Errorf("no stacks with failure status found")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := validateStack(ctx, cfnClient, stackName); err != nil {
+		return nil, err
+	}
+
+	return []string{stackName}, nil
+}
+
+// validateStack checks that the stack exists and records its description, so the analysis and the
+// report sections reuse it instead of describing the stack again
+func validateStack(ctx context.Context, cfnClient *cfnclient.Client, stackName string) error {
+	stack, err := validator.DescribeStack(ctx, cfnClient, stackName)
+	if err != nil {
+		return err
+	}
+	cfnClient.RememberStack(stack)
+	return nil
+}
+
+// discoveryStatuses returns the statuses of the stacks considered for discovery: the base statuses
+// with the statuses of --include-status and without those of --exclude-status
+func discoveryStatuses(base []types.StackStatus, opts *options) ([]types.StackStatus, error) {
+	statuses := validator.SelectStackStatuses(base, opts.IncludeStatuses, opts.ExcludeStatuses)
+	if len(statuses) == 0 {
+		return nil, fmt.Errorf("--exclude-status excludes all stack statuses considered")
+	}
+	return statuses, nil
+}
+
+// stackNamesOf returns the names of the given stacks in alphabetical order
+func stackNamesOf(summaries []types.StackSummary) []string {
+	names := make([]string, 0, len(summaries))
+	for _, summary := range summaries {
+		names = append(names, aws.ToString(summary.StackName))
+	}
+	sort.Strings(names)
+	return names
+}
+
+// isStackPattern reports whether the stack argument is a glob pattern rather than a stack name
+func isStackPattern(name string) bool {
+	return strings.ContainsAny(name, "*?[")
+}
+
+// matchingStackNames returns the names of the stacks matching a glob pattern; deleted stacks are skipped
+func matchingStackNames(summaries []types.StackSummary, pattern string) []string {
+	var matching []types.StackSummary
+	for _, summary := range summaries {
+		if summary.StackStatus == types.StackStatusDeleteComplete {
+			continue
+		}
+		if ok, _ := path.Match(pattern, aws.ToString(summary.StackName)); ok {
+			matching = append(matching, summary)
+		}
+	}
+	return stackNamesOf(matching)
+}
+
+// trailSearcher finds the CloudTrail events around a stack error: the CloudTrail client, or an
+// archived snapshot of the stack
+type trailSearcher interface {
+	SearchForStackErrors(ctx context.Context, stackError analyzer.StackError) ([]analyzer.CloudTrailEvent, error)
 }
 
 // analyzeStack performs the complete analysis workflow for a CloudFormation stack.
 // It retrieves stack events, extracts errors, queries CloudTrail for GeneralServiceExceptions,
-// and correlates the results.
-func analyzeStack(ctx context.Context, cfnClient *cfnclient.Client, stackName string) (*analyzer.StackAnalysis, error) {
+// and correlates the results. Once the breaker opens, CloudTrail is no longer queried; the limiter,
+// if any, spaces the CloudTrail lookups of parallel analyses. Lookups of failures CloudTrail may not
+// have delivered yet are repeated after waiting up to deliveryWait, unless it is 0.
+func analyzeStack(ctx context.Context, cfg aws.Config, cfnClient *cfnclient.Client, breaker *cloudtrail.Breaker, limiter *cloudtrail.Limiter, deliveryWait time.Duration, stackName string) (*analyzer.StackAnalysis, error) {
+	stats := &analyzer.AnalysisStats{}
+
 	// Get stack events
-	fmt.Println("Retrieving stack events...")
+	progressf("Retrieving stack events...\n")
+	phaseStart := time.Now()
 	events, err := cfnClient.GetStackEvents(ctx, stackName)
 	if err != nil {
 		return nil, fmt.Errorf("failed to retrieve stack events: %w", err)
 	}
+	stats.RecordPhase("Retrieve stack events", phaseStart)
+
+	// Only include errors from today
+	return analyzeStackEvents(ctx, cfg, cfnClient, breaker, limiter, deliveryWait, stackName, events, time.Now(), stats), nil
+}
+
+// analyzeStackEvents analyzes the errors of the reference day in the events of a stack, and runs
+// the detectors on them. Lookups that only supplement the analysis, here and for the template diff
+// and stack context of the report, report failures as a warning; the analysis then relies on the
+// stack events alone.
+func analyzeStackEvents(ctx context.Context, cfg aws.Config, cfnClient *cfnclient.Client, breaker *cloudtrail.Breaker, limiter *cloudtrail.Limiter,
+	deliveryWait time.Duration, stackName string, events []types.StackEvent, referenceDate time.Time, stats *analyzer.AnalysisStats) *analyzer.StackAnalysis {
+	ctClient := cloudtrail.NewClientWithConfig(cfg)
+	ctClient.UseBreaker(breaker)
+	ctClient.UseLimiter(limiter)
+	ctClient.UseDeliveryWait(deliveryWait)
+	defer recordCloudTrailStats(ctClient, stats)
+
+	// The description recorded when the stack was validated tells which service role CloudFormation used
+	stack, err := cfnClient.DescribeStack(ctx, stackName)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+	} else {
+		ctClient.UseStackRole(aws.ToString(stack.RoleARN))
+	}
+
+	analysis := analyzeEvents(ctx, stackName, events, referenceDate, ctClient, stats)
+	if stack != nil {
+		analysis.StackStatus = stackStatusOf(stack)
+	}
+	if ctx.Err() == nil {
+		analysis.Findings = append(analysis.Findings, detectRegistryTypeIssues(ctx, cfnClient, analysis.Errors, stats)...)
+		analysis.Findings = append(analysis.Findings, detectParameterViolations(ctx, cfnClient, stackName, events, analysis.Errors, stats)...)
+		analysis.Findings = append(analysis.Findings, detectTemplateLimits(ctx, cfnClient, stackName, events, analysis.Errors, stats)...)
+		analysis.Findings = append(analysis.Findings, detectCircularDependencies(ctx, cfnClient, stackName, events, analysis.Errors, stats)...)
+		analysis.Findings = append(analysis.Findings, detectCustomResourceTimeouts(ctx, cfg, cfnClient, stackName, events, analysis.Errors, stats)...)
+		analysis.Findings = append(analysis.Findings, detectNullHandlerMessages(ctx, cfg, cfnClient, ctClient, events, analysis.Errors, stats)...)
+		analysis.Findings = append(analysis.Findings, detectStackProtection(ctx, cfnClient, stackName, analysis.Errors, stats)...)
+		analysis.Findings = append(analysis.Findings, detectServiceIssues(ctx, cfg, analysis.StackId, analysis.Errors, stats)...)
+		analysis.Findings = append(analysis.Findings, detectCapacityFailures(ctx, ctClient, analysis.Errors, stats)...)
+		analysis.Findings = append(analysis.Findings, detectDatabaseEvents(ctx, cfg, events, analysis.Errors, stats)...)
+		analysis.Findings = append(analysis.Findings, detectLambdaFailures(ctx, cfg, ctClient, events, analysis.Errors, stats)...)
+		analysis.Findings = append(analysis.Findings, detectBucketNameConflicts(ctx, cfg, cfnClient, ctClient, stackName, analysis.StackId, events, analysis.Errors, stats)...)
+		analysis.Findings = append(analysis.Findings, detectCertificateValidation(ctx, cfg, events, analysis.Errors, stats)...)
+		analysis.Findings = append(analysis.Findings, detectVPCLimits(ctx, cfg, analysis.Errors, stats)...)
+		analysis.Findings = append(analysis.Findings, detectStabilizationFailures(ctx, cfg, events, analysis.Errors, stats)...)
+
+		crossStackFindings, relatedStacks := detectCrossStackDependencies(ctx, cfnClient, stackName, events, analysis.Errors, stats)
+		analysis.Findings = append(analysis.Findings, crossStackFindings...)
+		analysis.RelatedStacks = relatedStacks
+	}
+	return analysis
+}
+
+// analyzeEvents extracts the errors of the reference day from the stack events, looks up the
+// CloudTrail events of GeneralServiceExceptions and failed imports, and correlates the results
+func analyzeEvents(ctx context.Context, stackName string, events []types.StackEvent, referenceDate time.Time, searcher trailSearcher, stats *analyzer.AnalysisStats) *analyzer.StackAnalysis {
+	stats.StackEventsScanned = len(events)
 
 	// Extract errors from events
-	stackErrors := extractor.ExtractErrors(events)
-	
-	// Filter to only include errors from today
-	stackErrors = filterErrorsByDate(stackErrors, time.Now())
+	phaseStart := time.Now()
+	// Only include errors from the reference day
+	stackErrors := filterErrorsByDate(extractor.Errors(events), referenceDate)
+	stats.RecordPhase("Extract errors", phaseStart)
 
 	if len(stackErrors) == 0 {
+		// Rejected deployments such as missing capabilities only leave stack-level events
 		return &analyzer.StackAnalysis{
 			StackName:    stackName,
+			StackId:      stackIdOf(events),
 			AnalysisTime: time.Now(),
 			Errors:       []analyzer.CorrelatedError{},
-		}, nil
+			Findings:     patterns.DetectMissingCapabilities(events, nil),
+			Stats:        stats,
+			Health:       ophealth.Summarize(events),
+			StackStatus:  stackStatusFromEvents(events),
+		}
 	}
 
-	fmt.Printf("Found %d error(s) in stack events\n", len(stackErrors))
+	progressf("Found %d error(s) in stack events\n", len(stackErrors))
 
 	// Count GeneralServiceExceptions
 	generalServiceExceptions := 0
@@ -121,20 +727,44 @@ func analyzeStack(ctx context.Context, cfnClient *cfnclient.Client, stackName st
 		}
 	}
 
+	// Stack events cut off before the operation started lack its earlier errors
+	warnings := truncationWarnings(events, stackErrors)
+
 	// Query CloudTrail for GeneralServiceException errors
 	var trailEvents []analyzer.CloudTrailEvent
+	var lookupWarnings []analyzer.Warning
 	if generalServiceExceptions > 0 {
-		fmt.Printf("Found %d GeneralServiceException(s), querying CloudTrail for details...\n", generalServiceExceptions)
+		progressf("Found %d GeneralServiceException(s), querying CloudTrail for details...\n", generalServiceExceptions)
 
-		trailEvents, err = queryCloudTrailForErrors(ctx, stackErrors)
-		if err != nil {
-			// Log warning but continue - CloudTrail data is supplementary
-			fmt.Fprintf(os.Stderr, "Warning: Failed to query CloudTrail: %v\n", err)
-		}
+		phaseStart = time.Now()
+		trailEvents, lookupWarnings = queryCloudTrailForErrors(ctx, searcher, stackErrors)
+		warnings = append(warnings, lookupWarnings...)
+		stats.RecordPhase("Query CloudTrail", phaseStart)
+	}
+
+	// Query CloudTrail for the calls CloudFormation made to verify resources that failed to import
+	var verificationEvents []analyzer.CloudTrailEvent
+	if hasImportFailure(stackErrors) {
+		progressf("Found failed resource import(s), querying CloudTrail for verification calls...\n")
+
+		phaseStart = time.Now()
+		verificationEvents, lookupWarnings = queryImportVerificationCalls(ctx, searcher, stackErrors)
+		warnings = append(warnings, lookupWarnings...)
+		stats.RecordPhase("Query import verification", phaseStart)
 	}
 
 	// Correlate CloudFormation errors with CloudTrail events
+	phaseStart = time.Now()
 	correlatedErrors := correlator.CorrelateErrors(stackErrors, trailEvents)
+	stats.RecordPhase("Correlate errors", phaseStart)
+
+	// CloudTrail may not have delivered the events of recent failures yet
+	correlatedErrors, lookupWarnings = requeryRecentFailures(ctx, searcher, stackErrors, trailEvents, correlatedErrors, stats)
+	warnings = append(warnings, lookupWarnings...)
+	if _, live := searcher.(requerySearcher); live && ctx.Err() == nil {
+		warnings = append(warnings, deliveryLagWarnings(correlatedErrors)...)
+	}
+	warnings = append(warnings, lowConfidenceWarnings(correlatedErrors)...)
 
 	// Count errors with CloudTrail details
 	detailedErrors := 0
@@ -144,25 +774,43 @@ func analyzeStack(ctx context.Context, cfnClient *cfnclient.Client, stackName st
 		}
 	}
 
+	// Recognize known failure patterns such as IAM propagation delays
+	phaseStart = time.Now()
+	findings := patterns.Detect(events, correlatedErrors, verificationEvents)
+	stats.RecordPhase("Detect patterns", phaseStart)
+
 	return &analyzer.StackAnalysis{
 		StackName:      stackName,
+		StackId:        stackIdOf(events),
 		AnalysisTime:   time.Now(),
 		Errors:         correlatedErrors,
 		GeneralErrors:  generalServiceExceptions,
 		DetailedErrors: detailedErrors,
-	}, nil
+		Findings:       findings,
+		Stats:          stats,
+		Partial:        ctx.Err() != nil,
+		Warnings:       warnings,
+		Health:         ophealth.Summarize(events),
+		StackStatus:    stackStatusFromEvents(events),
+	}
 }
 
-// queryCloudTrailForErrors queries CloudTrail for events related to stack errors.
-// It focuses on GeneralServiceException errors that need CloudTrail investigation.
-func queryCloudTrailForErrors(ctx context.Context, stackErrors []analyzer.StackError) ([]analyzer.CloudTrailEvent, error) {
-	// Initialize CloudTrail client
-	ctClient, err := cloudtrail.NewClient(ctx)
-	if err != nil {
-		return nil, fmt.Errorf("failed to initialize CloudTrail client: %w", err)
+// stackIdOf returns the stack ARN recorded in the stack events, or "" if there are none
+func stackIdOf(events []types.StackEvent) string {
+	for _, event := range events {
+		if event.StackId != nil {
+			return *event.StackId
+		}
 	}
+	return ""
+}
 
+// queryCloudTrailForErrors queries CloudTrail for events related to stack errors.
+// It focuses on GeneralServiceException errors that need CloudTrail investigation,
+// and returns a warning for each error that could not be looked up.
+func queryCloudTrailForErrors(ctx context.Context, searcher trailSearcher, stackErrors []analyzer.StackError) ([]analyzer.CloudTrailEvent, []analyzer.Warning) {
 	var allTrailEvents []analyzer.CloudTrailEvent
+	var warnings []analyzer.Warning
 
 	// Query CloudTrail for each GeneralServiceException error
 	for _, stackErr := range stackErrors {
@@ -170,11 +818,19 @@ func queryCloudTrailForErrors(ctx context.Context, stackErrors []analyzer.StackE
 			continue
 		}
 
-		events, err := ctClient.SearchForStackErrors(ctx, stackErr)
+		events, err := searcher.SearchForStackErrors(ctx, stackErr)
+		if errors.Is(err, cloudtrail.ErrBreakerOpen) {
+			// A stopped breaker is printed once when the analysis is complete
+			warnings = append(warnings, breakerWarning())
+			break
+		}
+		if ctx.Err() != nil {
+			break
+		}
 		if err != nil {
-			// Log warning but continue with other errors
-			fmt.Fprintf(os.Stderr, "Warning: Failed to query CloudTrail for resource %s: %v\n",
-				stackErr.LogicalResourceId, err)
+			// Warn but continue with other errors
+			warnings = append(warnings, warn(analyzer.WarningCloudTrailSkipped, stackErr.LogicalResourceId,
+				"Failed to query CloudTrail for resource %s: %v", stackErr.LogicalResourceId, err))
 			continue
 		}
 
@@ -183,48 +839,95 @@ func queryCloudTrailForErrors(ctx context.Context, stackErrors []analyzer.StackE
 		allTrailEvents = append(allTrailEvents, errorEvents...)
 	}
 
-	return allTrailEvents, nil
+	return allTrailEvents, warnings
+}
+
+// queryImportVerificationCalls queries CloudTrail for the calls CloudFormation made around failed
+// resource imports. Successful calls are kept, since they show which resources CloudFormation found.
+func queryImportVerificationCalls(ctx context.Context, searcher trailSearcher, stackErrors []analyzer.StackError) ([]analyzer.CloudTrailEvent, []analyzer.Warning) {
+	var allTrailEvents []analyzer.CloudTrailEvent
+	var warnings []analyzer.Warning
+
+	for _, stackErr := range stackErrors {
+		if !isImportFailure(stackErr) {
+			continue
+		}
+
+		events, err := searcher.SearchForStackErrors(ctx, stackErr)
+		if errors.Is(err, cloudtrail.ErrBreakerOpen) {
+			// A stopped breaker is printed once when the analysis is complete
+			warnings = append(warnings, breakerWarning())
+			break
+		}
+		if ctx.Err() != nil {
+			break
+		}
+		if err != nil {
+			warnings = append(warnings, warn(analyzer.WarningCloudTrailSkipped, stackErr.LogicalResourceId,
+				"Failed to query CloudTrail for resource %s: %v", stackErr.LogicalResourceId, err))
+			continue
+		}
+		allTrailEvents = append(allTrailEvents, events...)
+	}
+
+	return allTrailEvents, warnings
 }
 
-// parseArgs parses command line arguments and returns the stack name.
-// Returns empty string if no stack name provided (indicating default behavior).
-func parseArgs() (string, error) {
-	args := os.Args[1:] // Skip program name
+// breakerWarning records in an analysis that CloudTrail lookups were stopped; reportBreaker prints
+// the cause once for all analyses
+func breakerWarning() analyzer.Warning {
+	return analyzer.Warning{
+		Kind:    analyzer.WarningCloudTrailSkipped,
+		Message: "CloudTrail lookups were stopped after repeated failures; the remaining errors were not looked up",
+	}
+}
 
-	if len(args) == 0 {
-		// No arguments provided - use default behavior (most recent stack)
-		return "", nil
+// reportBreaker warns once if CloudTrail lookups were stopped after repeated failures
+func reportBreaker(breaker *cloudtrail.Breaker) {
+	if err := breaker.Err(); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v\nContinuing with the stack events only; CloudTrail details are missing from the report.\n", err)
 	}
+}
 
-	if len(args) == 1 {
-		stackName := args[0]
+// recordCloudTrailStats adds the API calls, parsed events and queried time ranges of a CloudTrail
+// client to the statistics
+func recordCloudTrailStats(ctClient *cloudtrail.Client, stats *analyzer.AnalysisStats) {
+	calls, eventsParsed := ctClient.Stats()
+	stats.CloudTrailCalls += calls
+	stats.CloudTrailEventsParsed += eventsParsed
+	stats.CloudTrailWindows = append(stats.CloudTrailWindows, ctClient.Windows()...)
+}
 
-		// Validate stack name format before processing
-		if err := validator.ValidateStackName(stackName); err != nil {
-			return "", err
+// hasImportFailure checks if any of the errors is a failed resource import
+func hasImportFailure(stackErrors []analyzer.StackError) bool {
+	for _, stackErr := range stackErrors {
+		if isImportFailure(stackErr) {
+			return true
 		}
-
-		return stackName, nil
 	}
+	return false
+}
 
-	// Too many arguments
-	return "", fmt.Errorf("usage: %s [stack-name]", os.Args[0])
+// isImportFailure checks if the error is a failed resource import or import rollback
+func isImportFailure(stackErr analyzer.StackError) bool {
+	return stackErr.ResourceStatus == string(types.ResourceStatusImportFailed) ||
+		stackErr.ResourceStatus == string(types.ResourceStatusImportRollbackFailed)
 }
 
 // filterErrorsByDate filters stack errors to only include those from the same day as the reference date
-func filterErrorsByDate(errors []analyzer.StackError, referenceDate time.Time) []analyzer.StackError {
+func filterErrorsByDate(errors iter.Seq[analyzer.StackError], referenceDate time.Time) []analyzer.StackError {
 	// Get the start and end of the reference day (in UTC)
 	year, month, day := referenceDate.UTC().Date()
 	startOfDay := time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
 	endOfDay := startOfDay.Add(24 * time.Hour)
-	
+
 	var filtered []analyzer.StackError
-	for _, err := range errors {
+	for err := range errors {
 		// Check if error timestamp is within the same day
 		if err.Timestamp.After(startOfDay) && err.Timestamp.Before(endOfDay) {
 			filtered = append(filtered, err)
 		}
 	}
-	
+
 	return filtered
 }
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"strings"
+	"time"
+
+	"cfn-root-cause/analyzer"
+	"cfn-root-cause/buildinfo"
+)
+
+// withMetadata wraps an analysis function so each analysis records its metadata: where the stack
+// is, the version of the analyzer, how long the analysis took and the data it is based on. source
+// is the origin of the stack events, and region the configured region used for stacks whose ARN
+// is unknown.
+func withMetadata(analyze func(stackName string) (*analyzer.StackAnalysis, error), source, region string) func(stackName string) (*analyzer.StackAnalysis, error) {
+	return func(stackName string) (*analyzer.StackAnalysis, error) {
+		start := time.Now()
+		analysis, err := analyze(stackName)
+		if err != nil {
+			return analysis, err
+		}
+
+		metadata := &analyzer.AnalysisMetadata{Region: region}
+		if analysis.Metadata != nil {
+			// A cached analysis is marked as such by the cache
+			metadata = analysis.Metadata
+		}
+		if partition, arnRegion, account, ok := stackArnParts(analysis.StackId); ok {
+			metadata.Partition, metadata.Region, metadata.AccountID = partition, arnRegion, account
+		}
+		metadata.ToolVersion = buildinfo.Current().Version
+		metadata.Duration = time.Since(start)
+		metadata.Sources = append([]string{source}, metadata.Sources...)
+		if analysis.Stats != nil && analysis.Stats.CloudTrailCalls > 0 {
+			metadata.Sources = append(metadata.Sources, analyzer.SourceCloudTrail)
+		}
+		analysis.Metadata = metadata
+		return analysis, nil
+	}
+}
+
+// stackArnParts returns the partition, region and account of a stack ARN, e.g.
+// arn:aws:cloudformation:eu-central-1:123456789012:stack/my-stack/<uuid>
+func stackArnParts(stackId string) (partition, region, account string, ok bool) {
+	parts := strings.SplitN(stackId, ":", 6)
+	if len(parts) < 6 || parts[0] != "arn" {
+		return "", "", "", false
+	}
+	return parts[1], parts[3], parts[4], true
+}
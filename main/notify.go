@@ -0,0 +1,57 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"cfn-root-cause/analyzer"
+	"cfn-root-cause/notify"
+)
+
+// notifyCompletion shows a desktop notification stating whether a root cause was found, so
+// engineers who switched windows during a long analysis see that it finished. A root cause counts
+// as found when a failure pattern was recognized or CloudTrail explained an error. Failures are
+// reported as a warning, since the report has been written already.
+func notifyCompletion(ctx context.Context, analyses []*analyzer.StackAnalysis, interrupted bool) {
+	title := "CloudFormation analysis finished"
+	if len(analyses) == 1 {
+		title = fmt.Sprintf("Analysis of %s finished", analyses[0].StackName)
+	}
+
+	errorCount, rootCause := 0, ""
+	for _, analysis := range analyses {
+		errorCount += len(analysis.Errors)
+		if rootCause != "" {
+			continue
+		}
+		if len(analysis.Findings) > 0 {
+			rootCause = analysis.Findings[0].Title
+			continue
+		}
+		for _, err := range analysis.Errors {
+			if err.DetailedMessage != "" {
+				rootCause = err.StackError.LogicalResourceId + ": " + err.DetailedMessage
+				break
+			}
+		}
+	}
+
+	var message string
+	switch {
+	case errorCount == 0:
+		message = "No errors found"
+	case rootCause != "":
+		message = fmt.Sprintf("Root cause found (%d error(s)): %s", errorCount, rootCause)
+	default:
+		message = fmt.Sprintf("No root cause found for %d error(s); see the report", errorCount)
+	}
+	if interrupted {
+		message = "Interrupted, partial results. " + message
+	}
+
+	// The notification is shown even if the analysis was interrupted by a signal
+	if err := notify.Desktop(context.WithoutCancel(ctx), title, message); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+	}
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"cfn-root-cause/analyzer"
+	"cfn-root-cause/cfnclient"
+	"cfn-root-cause/cloudtrail"
+	"cfn-root-cause/lambdaactivity"
+	"cfn-root-cause/patterns"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation/types"
+)
+
+// handlerCallLookback limits how long before a handler failure the failed calls of the operation are searched
+const handlerCallLookback = 15 * time.Minute
+
+// detectNullHandlerMessages investigates handlers that failed with the message "null": the calls
+// CloudFormation made on behalf of the stack that failed during the operation, of any service, and
+// the handler log of private and third-party types. Failed CloudTrail searches, descriptions and
+// log reads are reported as a warning; the finding then contains what could be read.
+func detectNullHandlerMessages(ctx context.Context, cfg aws.Config, cfnClient *cfnclient.Client, ctClient *cloudtrail.Client, events []types.StackEvent, errors []analyzer.CorrelatedError, stats *analyzer.AnalysisStats) []analyzer.Finding {
+	var failures []analyzer.CorrelatedError
+	for _, err := range errors {
+		if patterns.IsNullHandlerMessage(err.StackError) {
+			failures = append(failures, err)
+		}
+	}
+	if len(failures) == 0 {
+		return nil
+	}
+
+	progressf("Investigating %d handler failure(s) without a message...\n", len(failures))
+	phaseStart := time.Now()
+	var findings []analyzer.Finding
+	var logClient *lambdaactivity.Client
+	registryTypes := make(map[string]*analyzer.RegistryType)
+	searchTrail := true
+	for _, failure := range failures {
+		stackErr := failure.StackError
+		investigation := patterns.NullHandlerInvestigation{Error: failure}
+		start := operationStart(events, stackErr)
+		if start.Before(stackErr.Timestamp.Add(-handlerCallLookback)) {
+			start = stackErr.Timestamp.Add(-handlerCallLookback)
+		}
+		end := stackErr.Timestamp.Add(time.Minute)
+
+		if searchTrail && ctx.Err() == nil {
+			calls, err := ctClient.SearchByUsername(ctx, cloudtrail.TimeRange{StartTime: start, EndTime: end}, "AWSCloudFormation")
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+				searchTrail = false
+			}
+			investigation.FailedCalls = cloudtrail.FilterErrorEvents(calls)
+		}
+
+		if patterns.IsRegistryType(stackErr.ResourceType) {
+			registryType, seen := registryTypes[stackErr.ResourceType]
+			if !seen {
+				var err error
+				if registryType, err = cfnClient.DescribeType(ctx, stackErr.ResourceType); err != nil {
+					fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+				}
+				registryTypes[stackErr.ResourceType] = registryType
+			}
+			investigation.RegistryType = registryType
+
+			if registryType != nil && registryType.HandlerLogGroup != "" {
+				if logClient == nil {
+					logClient = lambdaactivity.NewClientWithConfig(cfg)
+				}
+				var err error
+				investigation.HandlerLog, investigation.HandlerLogFound, err = logClient.LogMessages(ctx, registryType.HandlerLogGroup, start, end)
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "Warning: Failed to read the handler log of %s: %v\n", stackErr.ResourceType, err)
+				}
+			}
+		}
+
+		findings = append(findings, patterns.DescribeNullHandlerMessage(investigation))
+	}
+	stats.RecordPhase("Investigate handler failures", phaseStart)
+
+	return findings
+}
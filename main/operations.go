@@ -0,0 +1,132 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"cfn-root-cause/analyzer"
+	"cfn-root-cause/awsconfig"
+	"cfn-root-cause/cfnclient"
+	"cfn-root-cause/cloudtrail"
+	"cfn-root-cause/extractor"
+	"cfn-root-cause/validator"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation/types"
+)
+
+// runOperations lists the operations of a stack still in its event history, newest first, with
+// their status, duration and number of errors. An earlier operation is analyzed with --operation.
+func runOperations(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("operations", flag.ContinueOnError)
+	var awsOpts awsconfig.Options
+	addAWSFlags(fs, &awsOpts)
+
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return err
+		}
+		if fs.NArg() == 0 {
+			break
+		}
+		positional = append(positional, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+	if len(positional) != 1 {
+		return fmt.Errorf("usage: operations <stack-name>")
+	}
+	stackName := positional[0]
+	if err := validator.ValidateStackName(stackName); err != nil {
+		return err
+	}
+
+	awsCfg, err := loadAWSConfig(ctx, awsOpts)
+	if err != nil {
+		return err
+	}
+	cfnClient := cfnclient.NewClientWithConfig(awsCfg)
+
+	events, err := cfnClient.GetStackEvents(ctx, stackName)
+	if err != nil {
+		return err
+	}
+	operations := cfnclient.SplitOperations(events)
+	if len(operations) == 0 {
+		fmt.Printf("No operation of stack %s is in its event history.\n", stackName)
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "#\tSTARTED\tOPERATION\tSTATUS\tDURATION\tERRORS\tTOKEN")
+	for i, operation := range operations {
+		start := operation.Start()
+		started := aws.ToTime(start.Timestamp)
+		duration := aws.ToTime(operation.Events[0].Timestamp).Sub(started)
+		if strings.HasSuffix(string(operation.Status()), "_IN_PROGRESS") {
+			duration = time.Since(started)
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\t%d\t%s\n", i+1, started.Local().Format("2006-01-02 15:04:05"), operationKind(start),
+			operation.Status(), duration.Round(time.Second), len(extractor.ExtractErrors(operation.Events)), operation.Token)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	fmt.Printf("\nAnalyze an operation with: %s --operation <#> %s\n", os.Args[0], stackName)
+	return nil
+}
+
+// analyzeOperation analyzes an earlier operation of a stack, selected by its number in the
+// operations subcommand (1 is the latest) or its client request token. Only the events of the
+// operation are analyzed, so errors of later operations do not mix into the report.
+func analyzeOperation(ctx context.Context, cfg aws.Config, cfnClient *cfnclient.Client, breaker *cloudtrail.Breaker, limiter *cloudtrail.Limiter, deliveryWait time.Duration, stackName, selector string) (*analyzer.StackAnalysis, error) {
+	stats := &analyzer.AnalysisStats{}
+
+	progressf("Retrieving stack events...\n")
+	phaseStart := time.Now()
+	events, err := cfnClient.GetStackEvents(ctx, stackName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve stack events: %w", err)
+	}
+	stats.RecordPhase("Retrieve stack events", phaseStart)
+
+	operation, err := selectOperation(cfnclient.SplitOperations(events), selector)
+	if err != nil {
+		return nil, fmt.Errorf("stack '%s': %w", stackName, err)
+	}
+	start := operation.Start()
+	progressf("Analyzing the %s operation started at %s\n", operationKind(start),
+		aws.ToTime(start.Timestamp).Local().Format("2006-01-02 15:04:05"))
+
+	referenceDate := newestErrorDate(operation.Events, aws.ToTime(operation.Events[0].Timestamp))
+	return analyzeStackEvents(ctx, cfg, cfnClient, breaker, limiter, deliveryWait, stackName, operation.Events, referenceDate, stats), nil
+}
+
+// selectOperation returns the operation with the number or client request token of the selector
+func selectOperation(operations []cfnclient.Operation, selector string) (cfnclient.Operation, error) {
+	if number, err := strconv.Atoi(selector); err == nil {
+		if number < 1 || number > len(operations) {
+			return cfnclient.Operation{}, fmt.Errorf("no operation %d: %d operation(s) in the event history", number, len(operations))
+		}
+		return operations[number-1], nil
+	}
+
+	for _, operation := range operations {
+		if operation.Token == selector {
+			return operation, nil
+		}
+	}
+	return cfnclient.Operation{}, fmt.Errorf("no operation with client request token '%s' in the event history", selector)
+}
+
+// operationKind returns the kind of an operation from the status of its start event, e.g. UPDATE
+func operationKind(start types.StackEvent) string {
+	return strings.TrimSuffix(string(start.ResourceStatus), "_IN_PROGRESS")
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"cfn-root-cause/analyzer"
+	"cfn-root-cause/opscenter"
+)
+
+// prepareOpsItems builds the OpsItems of the failed production stacks. OpsItems never leave the
+// account, so they are built before the analyses are redacted and keep the real ARNs and IDs.
+func prepareOpsItems(client *opscenter.Client, analyses []*analyzer.StackAnalysis) []*opscenter.OpsItem {
+	var items []*opscenter.OpsItem
+	for _, analysis := range analyses {
+		if item := client.Prepare(analysis); item != nil {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// createOpsItems creates the prepared OpsItems. The OpsItems of failures that were recorded
+// already are reused. Failures are returned after all stacks were tried, so one rejected OpsItem
+// does not hide the others.
+func createOpsItems(ctx context.Context, client *opscenter.Client, items []*opscenter.OpsItem) error {
+	failed := 0
+	var lastErr error
+	for _, item := range items {
+		id, created, err := client.CreateOpsItem(ctx, item)
+		switch {
+		case err != nil:
+			failed++
+			lastErr = err
+		case created:
+			progressf("Created OpsItem %s for stack %s\n", id, item.StackName)
+		default:
+			progressf("OpsItem %s already records the failure of stack %s\n", id, item.StackName)
+		}
+	}
+
+	if failed > 1 {
+		return fmt.Errorf("%d OpsItem(s) could not be created, the last: %w", failed, lastErr)
+	}
+	return lastErr
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"cfn-root-cause/analyzer"
+	"cfn-root-cause/cfnclient"
+	"cfn-root-cause/cfntemplate"
+	"cfn-root-cause/patterns"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation/types"
+)
+
+// deployedParameters are the parameter definitions and values of a stack deployment
+type deployedParameters struct {
+	definitions map[string]cfntemplate.Parameter
+	values      map[string]string
+}
+
+// detectParameterViolations explains parameter validation failures with the constraints of the
+// parameter definitions and the provided values. Nested stacks are inspected through their own
+// template.
+func detectParameterViolations(ctx context.Context, cfnClient *cfnclient.Client, stackName string, events []types.StackEvent, errors []analyzer.CorrelatedError, stats *analyzer.AnalysisStats) []analyzer.Finding {
+	var findings []analyzer.Finding
+	deployments := make(map[string]*deployedParameters)
+	phaseStart := time.Now()
+
+	for _, err := range errors {
+		name, ok := patterns.ParameterViolation(err.StackError.ResourceStatusReason)
+		if !ok {
+			continue
+		}
+
+		target := templateOwner(stackName, events, err.StackError)
+		if target == "" {
+			continue
+		}
+
+		deployment, seen := deployments[target]
+		if !seen {
+			progressf("Retrieving parameter definitions...\n")
+			var lookupErr error
+			deployment, lookupErr = lookupParameters(ctx, cfnClient, target)
+			if lookupErr != nil {
+				fmt.Fprintf(os.Stderr, "Warning: Failed to retrieve parameter definitions: %v\n", lookupErr)
+			}
+			deployments[target] = deployment
+		}
+		if deployment == nil {
+			continue
+		}
+
+		definition, ok := deployment.definitions[name]
+		if !ok {
+			continue
+		}
+		value, provided := deployment.values[name]
+		findings = append(findings, patterns.DescribeParameterViolation(err.StackError, name, definition,
+			patterns.ParameterValue{Value: value, Provided: provided}))
+	}
+
+	if len(deployments) > 0 {
+		stats.RecordPhase("Retrieve parameter definitions", phaseStart)
+	}
+	return findings
+}
+
+// lookupParameters fetches the parameter definitions and values of the latest deployment of a stack
+func lookupParameters(ctx context.Context, cfnClient *cfnclient.Client, stackName string) (*deployedParameters, error) {
+	body, parameters, err := latestDeployment(ctx, cfnClient, stackName)
+	if err != nil {
+		return nil, err
+	}
+	template, err := cfntemplate.Parse(body)
+	if err != nil {
+		return nil, err
+	}
+
+	deployment := &deployedParameters{
+		definitions: template.Parameters,
+		values:      make(map[string]string, len(parameters)),
+	}
+	for _, parameter := range parameters {
+		deployment.values[aws.ToString(parameter.ParameterKey)] = aws.ToString(parameter.ParameterValue)
+	}
+	return deployment, nil
+}
+
+// latestDeployment fetches the template body and parameter values of the latest deployment of a
+// stack. After a rolled back update the stack only has the previous template and values, so those
+// of the failed change set are used instead if the update was deployed with one.
+func latestDeployment(ctx context.Context, cfnClient *cfnclient.Client, stackName string) (string, []types.Parameter, error) {
+	stack, err := cfnClient.DescribeStack(ctx, stackName)
+	if err != nil {
+		return "", nil, err
+	}
+
+	changeSetId := ""
+	parameters := stack.Parameters
+	if strings.HasPrefix(string(stack.StackStatus), "UPDATE_ROLLBACK") && aws.ToString(stack.ChangeSetId) != "" {
+		changeSetId = aws.ToString(stack.ChangeSetId)
+		parameters, err = cfnClient.GetChangeSetParameters(ctx, stackName, changeSetId)
+		if err != nil {
+			return "", nil, err
+		}
+	}
+
+	body, err := cfnClient.GetTemplateBody(ctx, stackName, changeSetId)
+	if err != nil {
+		return "", nil, err
+	}
+	return body, parameters, nil
+}
+
+// templateOwner returns the stack whose template caused an error: the nested stack for failed
+// nested stack resources, or "" if the nested stack was not created at all
+func templateOwner(stackName string, events []types.StackEvent, stackErr analyzer.StackError) string {
+	if stackErr.ResourceType != "AWS::CloudFormation::Stack" || stackErr.LogicalResourceId == stackName {
+		return stackName
+	}
+	return physicalResourceId(events, stackErr.LogicalResourceId)
+}
+
+// physicalResourceId returns the physical ID of a resource recorded in the stack events, or ""
+func physicalResourceId(events []types.StackEvent, logicalId string) string {
+	for _, event := range events {
+		if aws.ToString(event.LogicalResourceId) == logicalId && aws.ToString(event.PhysicalResourceId) != "" {
+			return aws.ToString(event.PhysicalResourceId)
+		}
+	}
+	return ""
+}
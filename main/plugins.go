@@ -0,0 +1,41 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"cfn-root-cause/analyzer"
+	"cfn-root-cause/plugins"
+)
+
+// enrichWithPlugins runs each plugin for each reported error and adds the returned findings.
+// Failing plugins are reported as a warning, since their enrichment is optional.
+func enrichWithPlugins(ctx context.Context, enrichers []plugins.Plugin, analyses []*analyzer.StackAnalysis) {
+	for _, analysis := range analyses {
+		if len(analysis.Errors) == 0 {
+			continue
+		}
+
+		progressf("Running %d plugin(s) for stack %s...\n", len(enrichers), analysis.StackName)
+		phaseStart := time.Now()
+		for _, err := range analysis.Errors {
+			request := plugins.NewRequest(analysis, err)
+			for _, plugin := range enrichers {
+				if ctx.Err() != nil {
+					return
+				}
+				findings, runErr := plugin.Run(ctx, request)
+				if runErr != nil {
+					fmt.Fprintf(os.Stderr, "Warning: %v\n", runErr)
+					continue
+				}
+				analysis.Findings = append(analysis.Findings, findings...)
+			}
+		}
+		if analysis.Stats != nil {
+			analysis.Stats.RecordPhase("Run plugins", phaseStart)
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"cfn-root-cause/awsconfig"
+	"cfn-root-cause/cfnclient"
+	"cfn-root-cause/cloudtrail"
+	"cfn-root-cause/preflight"
+)
+
+// runPreflight checks the permissions of the current credentials and prints one line per permission,
+// followed by the trails recording the events of the region. It fails if a required permission is
+// missing, so it can gate a pipeline before a long analysis starts.
+func runPreflight(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("preflight", flag.ContinueOnError)
+	var awsOpts awsconfig.Options
+	addAWSFlags(fs, &awsOpts)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() > 0 {
+		return fmt.Errorf("usage: preflight [--region REGION] [--profile PROFILE]")
+	}
+
+	awsCfg, err := loadAWSConfig(ctx, awsOpts)
+	if err != nil {
+		return err
+	}
+	cfnClient := cfnclient.NewClientWithConfig(awsCfg)
+	ctClient := cloudtrail.NewClientWithConfig(awsCfg)
+
+	results := preflight.Run(ctx, cfnClient.GetUnderlyingClient(), ctClient.GetUnderlyingClient())
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "STATUS\tPERMISSION\tUSED FOR")
+	for _, result := range results {
+		purpose := result.Purpose
+		if !result.Required {
+			purpose += " (optional)"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", result.Status, result.Permission, purpose)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	for _, result := range results {
+		if result.Err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: Could not check %s: %v\n", result.Permission, result.Err)
+		}
+	}
+
+	if err := printTrails(ctx, ctClient, awsCfg.Region); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+	}
+
+	if !preflight.Passed(results) {
+		return fmt.Errorf("required permissions are missing or could not be verified")
+	}
+
+	return nil
+}
+
+// printTrails lists the trails that apply to the region with their logging status, and notes
+// when none of them records management events
+func printTrails(ctx context.Context, ctClient *cloudtrail.Client, region string) error {
+	trails, err := ctClient.Trails(ctx)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("\nTrails of %s:\n", region)
+	if len(trails) > 0 {
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "NAME\tHOME REGION\tSCOPE\tLOGGING\tMANAGEMENT EVENTS")
+		for _, trail := range trails {
+			scope := "region"
+			if trail.Organization {
+				scope = "organization"
+			} else if trail.MultiRegion {
+				scope = "all regions"
+			}
+			logging, management := yesNo(trail.Logging), yesNo(trail.ManagementEvents)
+			if trail.Unverified {
+				logging, management = "unknown", "unknown"
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", trail.Name, trail.HomeRegion, scope, logging, management)
+		}
+		if err := w.Flush(); err != nil {
+			return err
+		}
+	}
+	if !cloudtrail.RecordsManagementEvents(trails) {
+		fmt.Printf("No trail records management events; the CloudTrail event history only covers the last 90 days.\n")
+	}
+	fmt.Printf("CloudTrail delivers events up to %d minutes after the call; recent failures are looked up again (--cloudtrail-wait).\n",
+		int(cloudtrail.DeliveryLag.Minutes()))
+	return nil
+}
+
+// yesNo formats a flag as yes or no
+func yesNo(value bool) string {
+	if value {
+		return "yes"
+	}
+	return "no"
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"cfn-root-cause/analyzer"
+	"cfn-root-cause/patterns"
+	"cfn-root-cause/rdsevents"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation/types"
+)
+
+// rdsEventsLookahead is how long after a failure RDS events are still read, since RDS records the
+// outcome of an operation shortly after CloudFormation gave up on it
+const rdsEventsLookahead = 5 * time.Minute
+
+// detectDatabaseEvents explains failed RDS resources with the events RDS recorded for the DB
+// instance, cluster or parameter group while CloudFormation deployed it. Resources without a
+// physical ID were never created and are skipped. Failures to read the events are reported as a
+// warning.
+func detectDatabaseEvents(ctx context.Context, cfg aws.Config, events []types.StackEvent, errors []analyzer.CorrelatedError, stats *analyzer.AnalysisStats) []analyzer.Finding {
+	var findings []analyzer.Finding
+	var client *rdsevents.Client
+	phaseStart := time.Now()
+
+	for _, err := range errors {
+		stackErr := err.StackError
+		sourceType := patterns.RDSSourceType(stackErr.ResourceType)
+		if sourceType == "" {
+			continue
+		}
+		identifier := physicalResourceId(events, stackErr.LogicalResourceId)
+		if identifier == "" {
+			continue
+		}
+
+		if client == nil {
+			client = rdsevents.NewClientWithConfig(cfg)
+		}
+		progressf("Reading RDS events of %s...\n", identifier)
+		start := operationStart(events, stackErr)
+		dbEvents, lookupErr := client.Events(ctx, sourceType, identifier, start, stackErr.Timestamp.Add(rdsEventsLookahead))
+		if lookupErr != nil {
+			fmt.Fprintf(os.Stderr, "Warning: %v\n", lookupErr)
+		}
+
+		source := patterns.DatabaseSource{Error: stackErr, SourceType: sourceType, Identifier: identifier}
+		if finding, ok := patterns.DescribeDatabaseEvents(source, dbEvents); ok {
+			findings = append(findings, finding)
+		}
+	}
+
+	if client != nil {
+		stats.RecordPhase("Read RDS events", phaseStart)
+	}
+	return findings
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"cfn-root-cause/analyzer"
+	"cfn-root-cause/cfnclient"
+	"cfn-root-cause/cloudtrail"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+// regionalAnalyzer analyzes the stacks of one region
+type regionalAnalyzer struct {
+	cfg       aws.Config
+	cfnClient *cfnclient.Client
+	analyze   func(stackName string) (*analyzer.StackAnalysis, error)
+}
+
+// newRegionalAnalyzer creates the CloudFormation client and the analysis of the stacks in the
+// region of the configuration: incremental if the history is kept, of an earlier operation with
+// --operation and cached unless disabled
+func newRegionalAnalyzer(ctx context.Context, cfg aws.Config, breaker *cloudtrail.Breaker, opts *options) *regionalAnalyzer {
+	cfnClient := cfnclient.NewClientWithConfig(cfg)
+	// Parallel analyses share the CloudTrail rate limit of the account
+	var limiter *cloudtrail.Limiter
+	if opts.Concurrency > 1 {
+		limiter = cloudtrail.NewLimiter(cloudtrail.DefaultLookupRate)
+	}
+	analyze := func(stackName string) (*analyzer.StackAnalysis, error) {
+		return analyzeStack(ctx, cfg, cfnClient, breaker, limiter, opts.CloudTrailWait, stackName)
+	}
+	if !opts.NoHistory && !opts.NoCache {
+		// The history file records the last analyzed event, so only newer events are fetched
+		analyze = func(stackName string) (*analyzer.StackAnalysis, error) {
+			return analyzeStackIncremental(ctx, cfg, cfnClient, breaker, limiter, opts.CloudTrailWait, opts.HistoryFile, stackName)
+		}
+	}
+	if opts.Operation != "" {
+		// The cache only holds analyses of the latest operation
+		analyze = func(stackName string) (*analyzer.StackAnalysis, error) {
+			return analyzeOperation(ctx, cfg, cfnClient, breaker, limiter, opts.CloudTrailWait, stackName, opts.Operation)
+		}
+	} else if !opts.NoCache {
+		// Completed operations do not change, so their analysis is only done once
+		analyzeUncached := analyze
+		analyze = func(stackName string) (*analyzer.StackAnalysis, error) {
+			return analyzeStackCached(ctx, cfnClient, breaker, stackName, analyzeUncached)
+		}
+	}
+
+	return &regionalAnalyzer{cfg: cfg, cfnClient: cfnClient, analyze: analyze}
+}
+
+// multiRegion analyzes the stacks of several regions with --regions. Its stacks are queued as
+// region/stack-name, which is unambiguous since stack names cannot contain a slash.
+type multiRegion struct {
+	regions map[string]*regionalAnalyzer
+}
+
+// newMultiRegion creates the analyzers of the regions, each with a copy of the configuration set
+// to its region. The CloudTrail breaker is shared, so a failing CloudTrail stops the lookups of all
+// regions; each region has its own CloudTrail rate limit.
+func newMultiRegion(ctx context.Context, cfg aws.Config, breaker *cloudtrail.Breaker, opts *options) *multiRegion {
+	m := &multiRegion{regions: make(map[string]*regionalAnalyzer, len(opts.Regions))}
+	for _, region := range opts.Regions {
+		regionCfg := cfg.Copy()
+		regionCfg.Region = region
+		m.regions[region] = newRegionalAnalyzer(ctx, regionCfg, breaker, opts)
+	}
+	return m
+}
+
+// regionalKey returns the key a stack of a region is queued by
+func regionalKey(region, stackName string) string {
+	return region + "/" + stackName
+}
+
+// splitRegionalKey returns the region and stack name of a queued stack
+func splitRegionalKey(key string) (region, stackName string) {
+	region, stackName, _ = strings.Cut(key, "/")
+	return region, stackName
+}
+
+// resolveStackNames finds the stacks to analyze in each region and returns their keys. Regions
+// without matching stacks are skipped with a warning, as are stack names missing in a region; it
+// fails only if no region has a stack to analyze.
+func (m *multiRegion) resolveStackNames(ctx context.Context, opts *options) ([]string, error) {
+	var keys []string
+	for _, region := range opts.Regions {
+		regional := m.regions[region]
+		progressf("Region %s:\n", region)
+		checkTrailRecording(ctx, regional.cfg)
+
+		// Each stack name is resolved on its own, so a stack missing in this region does not hide the others
+		queries := [][]string{opts.StackNames}
+		if len(opts.StackNames) > 1 {
+			queries = queries[:0]
+			for _, name := range opts.StackNames {
+				queries = append(queries, []string{name})
+			}
+		}
+		for _, names := range queries {
+			regionOpts := *opts
+			regionOpts.StackNames = names
+			stackNames, err := resolveStackNames(ctx, regional.cfnClient, &regionOpts)
+			if err != nil {
+				if ctx.Err() != nil {
+					return nil, ctx.Err()
+				}
+				fmt.Fprintf(os.Stderr, "Warning: Skipping region %s: %v\n", region, err)
+				continue
+			}
+			for _, stackName := range stackNames {
+				keys = append(keys, regionalKey(region, stackName))
+			}
+		}
+	}
+
+	if len(keys) == 0 {
+		return nil, errors.New("no stacks to analyze in any of the regions " + strings.Join(opts.Regions, ", "))
+	}
+	return keys, nil
+}
+
+// analyzer returns the function analyzing a queued stack in its region. Related stacks found by an
+// analysis, such as exporting stacks, are queued in the region of the stack.
+func (m *multiRegion) analyzer(source string) func(key string) (*analyzer.StackAnalysis, error) {
+	analyzers := make(map[string]func(stackName string) (*analyzer.StackAnalysis, error), len(m.regions))
+	for region, regional := range m.regions {
+		analyzers[region] = withMetadata(regional.analyze, source, region)
+	}
+
+	return func(key string) (*analyzer.StackAnalysis, error) {
+		region, stackName := splitRegionalKey(key)
+		analyze, ok := analyzers[region]
+		if !ok {
+			return nil, fmt.Errorf("stack '%s' is not in one of the analyzed regions", key)
+		}
+		analysis, err := analyze(stackName)
+		if err != nil {
+			return analysis, err
+		}
+		for i, related := range analysis.RelatedStacks {
+			analysis.RelatedStacks[i] = regionalKey(region, related)
+		}
+		return analysis, nil
+	}
+}
+
+// target returns the region, the CloudFormation client of the region and the stack name of a
+// queued stack
+func (m *multiRegion) target(key string) (region string, cfnClient *cfnclient.Client, stackName string) {
+	region, stackName = splitRegionalKey(key)
+	if regional, ok := m.regions[region]; ok {
+		cfnClient = regional.cfnClient
+	}
+	return region, cfnClient, stackName
+}
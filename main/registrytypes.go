@@ -0,0 +1,42 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"cfn-root-cause/analyzer"
+	"cfn-root-cause/cfnclient"
+	"cfn-root-cause/patterns"
+)
+
+// detectRegistryTypeIssues describes the private and third-party types of the failed resources with
+// DescribeType and explains failures related to their version.
+func detectRegistryTypeIssues(ctx context.Context, cfnClient *cfnclient.Client, errors []analyzer.CorrelatedError, stats *analyzer.AnalysisStats) []analyzer.Finding {
+	registryTypes := make(map[string]*analyzer.RegistryType)
+	for _, err := range errors {
+		typeName := err.StackError.ResourceType
+		if !patterns.IsRegistryType(typeName) {
+			continue
+		}
+		registryTypes[typeName] = nil
+	}
+	if len(registryTypes) == 0 {
+		return nil
+	}
+
+	progressf("Describing %d private or third-party resource type(s)...\n", len(registryTypes))
+	phaseStart := time.Now()
+	for typeName := range registryTypes {
+		registryType, err := cfnClient.DescribeType(ctx, typeName)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+			continue
+		}
+		registryTypes[typeName] = registryType
+	}
+	stats.RecordPhase("Describe resource types", phaseStart)
+
+	return patterns.DetectRegistryTypeIssues(errors, registryTypes)
+}
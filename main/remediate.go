@@ -0,0 +1,125 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"cfn-root-cause/awsconfig"
+	"cfn-root-cause/cfnclient"
+	"cfn-root-cause/remediation"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation"
+	"golang.org/x/term"
+)
+
+// runRemediate plans the remediation of a stuck stack and prints it. The action is only executed
+// with --allow-mutations and after the user confirms it interactively; by default nothing is changed.
+func runRemediate(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("remediate", flag.ContinueOnError)
+	allowMutations := fs.Bool("allow-mutations", false, "execute the planned action after confirmation")
+	var skip []string
+	fs.Var((*stringList)(&skip), "skip",
+		"resources to skip when continuing an update rollback (default: the resources that failed to roll back)")
+	var awsOpts awsconfig.Options
+	addAWSFlags(fs, &awsOpts)
+
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return err
+		}
+		if fs.NArg() == 0 {
+			break
+		}
+		positional = append(positional, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+	if len(positional) != 1 {
+		return fmt.Errorf("usage: remediate <stack-name> [--skip RESOURCE] [--allow-mutations]")
+	}
+	stackName := positional[0]
+
+	awsCfg, err := loadAWSConfig(ctx, awsOpts)
+	if err != nil {
+		return err
+	}
+	cfnClient := cfnclient.NewClientWithConfig(awsCfg)
+
+	output, err := cfnClient.DescribeStacks(ctx, &cloudformation.DescribeStacksInput{StackName: aws.String(stackName)})
+	if err != nil {
+		return fmt.Errorf("failed to describe stack '%s': %w", stackName, err)
+	}
+	if len(output.Stacks) == 0 {
+		return fmt.Errorf("stack '%s' not found", stackName)
+	}
+
+	events, err := cfnClient.GetLatestOperationEvents(ctx, stackName)
+	if err != nil {
+		return err
+	}
+
+	action, err := remediation.Plan(stackName, output.Stacks[0].StackStatus, events, skip)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Planned action: %s\n", action.Description)
+	fmt.Printf("Warning: %s\n", action.Warning)
+
+	protected := action.Kind == remediation.ActionDeleteStack && aws.ToBool(output.Stacks[0].EnableTerminationProtection)
+	if protected {
+		fmt.Printf("Termination protection is enabled; disable it first: aws cloudformation update-termination-protection "+
+			"--no-enable-termination-protection --stack-name %s\n", stackName)
+	}
+
+	if !*allowMutations {
+		fmt.Println("\nNothing was changed. Re-run with --allow-mutations to execute the action.")
+		return nil
+	}
+	if protected {
+		return fmt.Errorf("stack '%s' has termination protection enabled; nothing was changed", stackName)
+	}
+
+	if err := confirm(stackName); err != nil {
+		return err
+	}
+
+	switch action.Kind {
+	case remediation.ActionContinueUpdateRollback:
+		err = cfnClient.ContinueUpdateRollback(ctx, stackName, action.ResourcesToSkip)
+	case remediation.ActionDeleteStack:
+		err = cfnClient.DeleteStack(ctx, stackName)
+	default:
+		err = fmt.Errorf("unknown remediation action '%s'", action.Kind)
+	}
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Started: %s. Follow the progress in the stack events.\n", action.Description)
+	return nil
+}
+
+// confirm asks the user to type the stack name before a mutating action is executed.
+// Confirmation must be interactive, so the action is refused when stdin is not a terminal.
+func confirm(stackName string) error {
+	if !term.IsTerminal(int(os.Stdin.Fd())) {
+		return fmt.Errorf("--allow-mutations requires interactive confirmation, but stdin is not a terminal")
+	}
+
+	fmt.Fprintf(os.Stderr, "\nType the stack name '%s' to confirm: ", stackName)
+	answer, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil {
+		return fmt.Errorf("failed to read confirmation: %w", err)
+	}
+	if strings.TrimSpace(answer) != stackName {
+		return fmt.Errorf("confirmation did not match the stack name; nothing was changed")
+	}
+
+	return nil
+}
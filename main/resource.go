@@ -0,0 +1,216 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"text/tabwriter"
+
+	"cfn-root-cause/analyzer"
+	"cfn-root-cause/awsconfig"
+	"cfn-root-cause/cfnclient"
+	"cfn-root-cause/classify"
+	"cfn-root-cause/cloudtrail"
+	"cfn-root-cause/correlator"
+	"cfn-root-cause/formatter"
+	"cfn-root-cause/validator"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation/types"
+)
+
+// resourceTimeFormat is the format of the event times listed by the resource subcommand
+const resourceTimeFormat = "2006-01-02 15:04:05"
+
+// runResource shows everything known about one resource of a stack: its current state, its events
+// across all operations, and its errors in the analysis of the latest operation with their CloudTrail
+// calls and the findings about the resource.
+func runResource(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("resource", flag.ContinueOnError)
+	noCache := fs.Bool("no-cache", false, "analyze again even if the latest operation of the stack was analyzed before")
+	var awsOpts awsconfig.Options
+	addAWSFlags(fs, &awsOpts)
+
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return err
+		}
+		if fs.NArg() == 0 {
+			break
+		}
+		positional = append(positional, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+	if len(positional) != 2 {
+		return fmt.Errorf("usage: resource <stack-name> <logical-id> [--no-cache]")
+	}
+	stackName, logicalId := positional[0], positional[1]
+	if err := validator.ValidateStackName(stackName); err != nil {
+		return err
+	}
+
+	awsCfg, err := loadAWSConfig(ctx, awsOpts)
+	if err != nil {
+		return err
+	}
+	cfnClient := cfnclient.NewClientWithConfig(awsCfg)
+	if err := validateStack(ctx, cfnClient, stackName); err != nil {
+		return err
+	}
+
+	detail, err := cfnClient.DescribeStackResource(ctx, stackName, logicalId)
+	if err != nil {
+		return err
+	}
+	events, err := cfnClient.GetStackEvents(ctx, stackName)
+	if err != nil {
+		return err
+	}
+	resourceEvents := eventsOfResource(events, logicalId)
+	if detail == nil && len(resourceEvents) == 0 {
+		return fmt.Errorf("stack '%s' has no resource '%s'", stackName, logicalId)
+	}
+
+	breaker := cloudtrail.NewBreaker(cloudtrail.DefaultBreakerThreshold)
+	analyze := func(stackName string) (*analyzer.StackAnalysis, error) {
+		return analyzeStack(ctx, awsCfg, cfnClient, breaker, nil, 0, stackName)
+	}
+	if !*noCache {
+		analyzeUncached := analyze
+		analyze = func(stackName string) (*analyzer.StackAnalysis, error) {
+			return analyzeStackCached(ctx, cfnClient, breaker, stackName, analyzeUncached)
+		}
+	}
+	analysis, err := analyze(stackName)
+	if err != nil {
+		return err
+	}
+	reportBreaker(breaker)
+
+	printResourceState(stackName, logicalId, detail, resourceEvents)
+	if err := printResourceEvents(resourceEvents); err != nil {
+		return err
+	}
+	printResourceErrors(analysis, logicalId)
+	return nil
+}
+
+// eventsOfResource returns the stack events of a resource, the oldest first
+func eventsOfResource(events []types.StackEvent, logicalId string) []types.StackEvent {
+	var resourceEvents []types.StackEvent
+	for _, event := range events {
+		if aws.ToString(event.LogicalResourceId) == logicalId {
+			resourceEvents = append(resourceEvents, event)
+		}
+	}
+	sort.SliceStable(resourceEvents, func(i, j int) bool {
+		return aws.ToTime(resourceEvents[i].Timestamp).Before(aws.ToTime(resourceEvents[j].Timestamp))
+	})
+	return resourceEvents
+}
+
+// printResourceState prints the current state of a resource; resources no longer in the stack
+// are described by their latest event
+func printResourceState(stackName, logicalId string, detail *types.StackResourceDetail, resourceEvents []types.StackEvent) {
+	fmt.Printf("Resource %s of stack %s\n\n", logicalId, stackName)
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	if detail == nil {
+		latest := resourceEvents[len(resourceEvents)-1]
+		fmt.Fprintf(w, "Type:\t%s\n", aws.ToString(latest.ResourceType))
+		fmt.Fprintf(w, "Physical ID:\t%s\n", valueOrNone(aws.ToString(latest.PhysicalResourceId)))
+		fmt.Fprintf(w, "Status:\tno longer part of the stack; last %s\n", latest.ResourceStatus)
+	} else {
+		fmt.Fprintf(w, "Type:\t%s\n", aws.ToString(detail.ResourceType))
+		fmt.Fprintf(w, "Physical ID:\t%s\n", valueOrNone(aws.ToString(detail.PhysicalResourceId)))
+		fmt.Fprintf(w, "Status:\t%s\n", detail.ResourceStatus)
+		if reason := aws.ToString(detail.ResourceStatusReason); reason != "" {
+			fmt.Fprintf(w, "Reason:\t%s\n", reason)
+		}
+		fmt.Fprintf(w, "Last updated:\t%s\n", aws.ToTime(detail.LastUpdatedTimestamp).Local().Format(resourceTimeFormat))
+		if drift := detail.DriftInformation; drift != nil && drift.StackResourceDriftStatus != "" {
+			fmt.Fprintf(w, "Drift:\t%s\n", drift.StackResourceDriftStatus)
+		}
+		if description := aws.ToString(detail.Description); description != "" {
+			fmt.Fprintf(w, "Description:\t%s\n", description)
+		}
+	}
+	w.Flush()
+}
+
+// printResourceEvents lists the events of a resource across all operations of the stack, with a
+// blank line between operations
+func printResourceEvents(resourceEvents []types.StackEvent) error {
+	fmt.Printf("\nEvents (%d):\n", len(resourceEvents))
+	if len(resourceEvents) == 0 {
+		fmt.Println("  none")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "  TIME\tSTATUS\tPHYSICAL ID\tREASON")
+	token := aws.ToString(resourceEvents[0].ClientRequestToken)
+	for _, event := range resourceEvents {
+		if next := aws.ToString(event.ClientRequestToken); next != token {
+			fmt.Fprintln(w, "\t\t\t")
+			token = next
+		}
+		fmt.Fprintf(w, "  %s\t%s\t%s\t%s\n", aws.ToTime(event.Timestamp).Local().Format(resourceTimeFormat),
+			event.ResourceStatus, valueOrNone(aws.ToString(event.PhysicalResourceId)),
+			formatter.Truncate(aws.ToString(event.ResourceStatusReason), formatter.DefaultMaxMessageLength))
+	}
+	return w.Flush()
+}
+
+// printResourceErrors prints the errors of a resource found by the analysis with their CloudTrail
+// calls, and the findings about the resource
+func printResourceErrors(analysis *analyzer.StackAnalysis, logicalId string) {
+	var errors []analyzer.CorrelatedError
+	for _, err := range analysis.Errors {
+		if err.StackError.LogicalResourceId == logicalId {
+			errors = append(errors, err)
+		}
+	}
+
+	fmt.Printf("\nErrors in the latest operation (%d):\n", len(errors))
+	if len(errors) == 0 {
+		fmt.Println("  none")
+	}
+	for _, err := range errors {
+		fmt.Printf("  %s %s [%s]: %s\n", err.StackError.Timestamp.Local().Format(resourceTimeFormat),
+			err.StackError.ResourceStatus, classify.Category(err), err.DetailedMessage)
+		if event := err.CloudTrailEvent; event != nil {
+			fmt.Printf("    CloudTrail: %s (%s) %s at %s, confidence %s\n", event.EventName, event.EventSource,
+				valueOrNone(event.ErrorCode), event.EventTime.Local().Format(resourceTimeFormat), correlator.Confidence(err))
+		}
+		if requestId := classify.RequestID(err); requestId != "" {
+			fmt.Printf("    Request ID: %s\n", requestId)
+		}
+		if retries := err.Retries; retries != nil {
+			fmt.Printf("    Retried: %d identical failed calls from %s to %s\n", retries.Count,
+				retries.First.Local().Format(resourceTimeFormat), retries.Last.Local().Format(resourceTimeFormat))
+		}
+	}
+
+	for _, finding := range analysis.Findings {
+		if finding.LogicalResourceId != logicalId {
+			continue
+		}
+		fmt.Printf("\n%s: %s\n%s\n", finding.Title, finding.LogicalResourceId, finding.Explanation)
+		for _, evidence := range finding.Evidence {
+			fmt.Printf("  - %s\n", evidence)
+		}
+		fmt.Printf("Suggestion: %s\n", finding.Suggestion)
+	}
+}
+
+// valueOrNone returns the value, or "-" if it is empty
+func valueOrNone(value string) string {
+	if value == "" {
+		return "-"
+	}
+	return value
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"cfn-root-cause/analyzer"
+	"cfn-root-cause/cfnclient"
+	"cfn-root-cause/cloudtrail"
+	"cfn-root-cause/patterns"
+	"cfn-root-cause/s3bucket"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/arn"
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation/types"
+)
+
+// bucketCreationLookback is how far back CloudTrail is searched for the creation of a bucket; it
+// keeps management events for 90 days
+const bucketCreationLookback = 90 * 24 * time.Hour
+
+// detectBucketNameConflicts explains S3 buckets that failed because the name is taken. HeadBucket
+// tells whether the bucket belongs to the account of the stack or to another account; buckets of
+// the account are looked up in CloudFormation for the stack managing them and, if none does, in
+// CloudTrail for their creator.
+func detectBucketNameConflicts(ctx context.Context, cfg aws.Config, cfnClient *cfnclient.Client, ctClient *cloudtrail.Client, stackName, stackId string, events []types.StackEvent, errors []analyzer.CorrelatedError, stats *analyzer.AnalysisStats) []analyzer.Finding {
+	conflicts := patterns.BucketConflicts(errors)
+	if len(conflicts) == 0 {
+		return nil
+	}
+
+	accountID := ""
+	if parsed, err := arn.Parse(stackId); err == nil {
+		accountID = parsed.AccountID
+	}
+
+	progressf("Looking up the owners of %d taken bucket name(s)...\n", len(conflicts))
+	phaseStart := time.Now()
+	client := s3bucket.NewClientWithConfig(cfg)
+	var findings []analyzer.Finding
+	for _, conflict := range conflicts {
+		if conflict.BucketName == "" {
+			conflict.BucketName = physicalResourceId(events, conflict.Error.LogicalResourceId)
+		}
+		var ownership *patterns.BucketOwnership
+		if conflict.BucketName != "" {
+			ownership = lookupBucketOwnership(ctx, client, cfnClient, ctClient, conflict, accountID)
+		}
+		findings = append(findings, patterns.DescribeBucketConflict(stackName, conflict, ownership))
+	}
+	stats.RecordPhase("Look up bucket owners", phaseStart)
+
+	return findings
+}
+
+// lookupBucketOwnership determines the current owner of a taken bucket name, nil if HeadBucket failed
+func lookupBucketOwnership(ctx context.Context, client *s3bucket.Client, cfnClient *cfnclient.Client, ctClient *cloudtrail.Client, conflict patterns.BucketConflict, accountID string) *patterns.BucketOwnership {
+	status, err := client.Head(ctx, conflict.BucketName, accountID)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+		return nil
+	}
+
+	ownership := &patterns.BucketOwnership{Owner: patterns.BucketOwnerNone, Region: status.Region}
+	switch {
+	case !status.Exists:
+		return ownership
+	case !status.OwnedByAccount:
+		ownership.Owner = patterns.BucketOwnerOtherAccount
+		return ownership
+	}
+	ownership.Owner = patterns.BucketOwnerAccount
+
+	ownership.Stack, ownership.LogicalResourceId, err = cfnClient.FindResourceStack(ctx, conflict.BucketName)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+	}
+	if ownership.Stack != "" || ctx.Err() != nil {
+		return ownership
+	}
+
+	failedAt := conflict.Error.Timestamp
+	trailEvents, err := ctClient.SearchCloudTrailEvents(ctx, cloudtrail.TimeRange{
+		StartTime: failedAt.Add(-bucketCreationLookback),
+		EndTime:   failedAt,
+	}, []string{conflict.BucketName})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+	}
+	if creator, created, ok := patterns.BucketCreation(trailEvents, failedAt); ok {
+		ownership.Creator, ownership.Created = creator, created
+	}
+	return ownership
+}
@@ -0,0 +1,18 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"cfn-root-cause/formatter"
+)
+
+// runSchema prints the JSON Schema of the json report format
+func runSchema(ctx context.Context, args []string) error {
+	if len(args) > 0 {
+		return fmt.Errorf("usage: schema")
+	}
+
+	fmt.Print(formatter.JSONSchema())
+	return nil
+}
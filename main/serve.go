@@ -0,0 +1,204 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"cfn-root-cause/analyzer"
+	"cfn-root-cause/awsconfig"
+	"cfn-root-cause/cfnclient"
+	"cfn-root-cause/cloudtrail"
+	"cfn-root-cause/formatter"
+	"cfn-root-cause/metrics"
+	"cfn-root-cause/sorter"
+	"cfn-root-cause/validator"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+// shutdownTimeout is how long running analyses may take to finish when the server is stopped
+const shutdownTimeout = 30 * time.Second
+
+// contentTypes maps report formats to HTTP content types
+var contentTypes = map[string]string{
+	formatter.ReportGitLab:   "application/json",
+	formatter.ReportJUnit:    "application/xml",
+	formatter.ReportJSON:     "application/json",
+	formatter.ReportMarkdown: "text/markdown; charset=utf-8",
+}
+
+// runServe runs the analyzer as an HTTP server.
+// GET /analyze?stack=<name>&format=<format> runs an analysis and returns the report,
+// GET /metrics exposes Prometheus metrics and GET /healthz reports liveness.
+func runServe(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("serve", flag.ContinueOnError)
+	listen := fs.String("listen", ":8080", "address to listen on")
+	var awsOpts awsconfig.Options
+	addAWSFlags(fs, &awsOpts)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	awsCfg, err := loadAWSConfig(ctx, awsOpts)
+	if err != nil {
+		return err
+	}
+	cfnClient := cfnclient.NewClientWithConfig(awsCfg)
+
+	callerIdentity := lookupCallerIdentity(ctx, awsCfg)
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", metrics.Handler())
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, "ok")
+	})
+	mux.HandleFunc("/analyze", func(w http.ResponseWriter, r *http.Request) {
+		handleAnalyze(w, r, awsCfg, cfnClient, callerIdentity)
+	})
+
+	server := &http.Server{
+		Addr:              *listen,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	// Ctrl+C stops accepting requests and lets running analyses finish
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		if err := server.Shutdown(shutdownCtx); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: Failed to shut down the server: %v\n", err)
+		}
+	}()
+
+	progressf("Listening on %s\n", *listen)
+
+	if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("server failed: %w", err)
+	}
+
+	return nil
+}
+
+// handleAnalyze analyzes the requested stack and writes the formatted report
+func handleAnalyze(w http.ResponseWriter, r *http.Request, cfg aws.Config, cfnClient *cfnclient.Client, callerIdentity *analyzer.CallerIdentity) {
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	stackName := r.URL.Query().Get("stack")
+	if err := validator.ValidateStackName(stackName); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = formatter.ReportPlain
+	}
+	if !formatter.IsValidFormat(format) {
+		http.Error(w, fmt.Sprintf("unknown format '%s': must be one of %s",
+			format, strings.Join(formatter.Formats(), ", ")), http.StatusBadRequest)
+		return
+	}
+
+	language := r.URL.Query().Get("lang")
+	if language != "" && !formatter.IsValidLanguage(language) {
+		http.Error(w, fmt.Sprintf("unknown language '%s': must be one of %s",
+			language, strings.Join(formatter.Languages(), ", ")), http.StatusBadRequest)
+		return
+	}
+
+	sortKey := r.URL.Query().Get("sort")
+	if !sorter.IsValidSortKey(sortKey) {
+		http.Error(w, fmt.Sprintf("unknown sort key '%s': must be one of %s",
+			sortKey, strings.Join(sorter.SortKeys(), ", ")), http.StatusBadRequest)
+		return
+	}
+
+	start := time.Now()
+	analysis, err := analyzeExistingStack(r.Context(), cfg, cfnClient, stackName)
+	recordAnalysis(analysis, err, time.Since(start))
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, validator.ErrStackNotFound) {
+			status = http.StatusNotFound
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	analysis.Identity = callerIdentity
+	if err := sorter.SortErrors(analysis.Errors, sortKey); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	templatePath := r.URL.Query().Get("template-path")
+	if templatePath == "" {
+		templatePath = "template.yaml"
+	}
+
+	output, err := formatter.FormatAs(analysis, formatter.Options{
+		Format:           format,
+		TemplatePath:     templatePath,
+		ShowStats:        r.URL.Query().Get("stats") == "true",
+		Explain:          r.URL.Query().Get("explain") == "true",
+		Language:         language,
+		MaxMessageLength: formatter.DefaultMaxMessageLength,
+	})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	contentType, ok := contentTypes[format]
+	if !ok {
+		contentType = "text/plain; charset=utf-8"
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Length", strconv.Itoa(len(output)))
+	fmt.Fprint(w, output)
+}
+
+// analyzeExistingStack validates that the stack exists and analyzes it
+func analyzeExistingStack(ctx context.Context, cfg aws.Config, cfnClient *cfnclient.Client, stackName string) (*analyzer.StackAnalysis, error) {
+	if err := validateStack(ctx, cfnClient, stackName); err != nil {
+		return nil, err
+	}
+	breaker := cloudtrail.NewBreaker(cloudtrail.DefaultBreakerThreshold)
+	defer reportBreaker(breaker)
+	analyze := func(stackName string) (*analyzer.StackAnalysis, error) {
+		return analyzeStack(ctx, cfg, cfnClient, breaker, nil, 0, stackName)
+	}
+	return withMetadata(analyze, analyzer.SourceStackEvents, cfg.Region)(stackName)
+}
+
+// recordAnalysis updates the analysis metrics with the outcome of one analysis
+func recordAnalysis(analysis *analyzer.StackAnalysis, err error, duration time.Duration) {
+	metrics.AnalysisDuration.Observe(duration.Seconds())
+
+	if err != nil {
+		metrics.AnalysesTotal.Inc("error")
+		fmt.Fprintf(os.Stderr, "Warning: analysis failed: %v\n", err)
+		return
+	}
+	metrics.AnalysesTotal.Inc("success")
+
+	for _, stackErr := range analysis.Errors {
+		metrics.ErrorsFoundTotal.Inc(stackErr.StackError.ResourceStatus,
+			strconv.FormatBool(stackErr.StackError.IsGeneralServiceException))
+	}
+	for _, finding := range analysis.Findings {
+		metrics.FindingsTotal.Inc(finding.Pattern)
+	}
+}
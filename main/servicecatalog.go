@@ -0,0 +1,28 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"cfn-root-cause/analyzer"
+	"cfn-root-cause/servicecatalog"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+// resolveProvisionedProduct looks up a Service Catalog provisioned product and the stack provisioning
+// it. Failures to look up the product and artifact names are reported as a warning, since the stack
+// can be analyzed without them.
+func resolveProvisionedProduct(ctx context.Context, cfg aws.Config, idOrName string) (*analyzer.ProvisionedProduct, error) {
+	progressf("Resolving provisioned product: %s\n", idOrName)
+
+	product, err := servicecatalog.NewClientWithConfig(cfg).ProvisionedProduct(ctx, idOrName)
+	if product == nil {
+		return nil, err
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+	}
+	return product, nil
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"cfn-root-cause/analyzer"
+	"cfn-root-cause/formatter"
+	"cfn-root-cause/settings"
+	"cfn-root-cause/sink"
+	"cfn-root-cause/webhook"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+// supportTextFormat is the pseudo format of the sink receiving the --support-text case body
+const supportTextFormat = "support-text"
+
+// reportSink is a destination of the report together with its specification
+type reportSink struct {
+	spec sink.Spec
+	sink sink.Sink
+}
+
+// newReportSinks creates the destinations of the report: the --sink flags, else the sinks of the
+// config file. --output, or stdout if no sink is configured, receives the --format report, and
+// --webhook-url the JSON report.
+func newReportSinks(opts *options, cfg *settings.Config, awsCfg aws.Config) ([]reportSink, error) {
+	specs := opts.Sinks
+	if len(specs) == 0 && !opts.SupportText {
+		for _, sinkConfig := range cfg.Sinks {
+			spec := sink.Spec{Format: sinkConfig.Format, Destination: sinkConfig.Destination}
+			if _, err := spec.Kind(); err != nil {
+				return nil, fmt.Errorf("invalid sink in config file: %w", err)
+			}
+			if err := validateSinkFormat(spec); err != nil {
+				return nil, fmt.Errorf("invalid sink in config file: %w", err)
+			}
+			specs = append(specs, spec)
+		}
+	}
+
+	if len(specs) == 0 || opts.Output != "" {
+		spec := sink.Spec{Format: opts.Format, Destination: "-"}
+		if opts.Output != "" {
+			spec.Destination = opts.Output
+		}
+		if opts.SupportText {
+			spec.Format = supportTextFormat
+		}
+		specs = append([]sink.Spec{spec}, specs...)
+	}
+	if opts.WebhookURL != "" {
+		specs = append(specs, sink.Spec{Format: formatter.ReportJSON, Destination: opts.WebhookURL})
+	}
+
+	sinks := make([]reportSink, 0, len(specs))
+	for _, spec := range specs {
+		kind, err := spec.Kind()
+		if err != nil {
+			return nil, err
+		}
+		if spec.Format == "" {
+			spec.Format = defaultSinkFormat(kind, opts.Format)
+		}
+
+		s, err := sink.New(awsCfg, spec, os.Getenv(webhook.SecretEnvVar))
+		if err != nil {
+			return nil, err
+		}
+		sinks = append(sinks, reportSink{spec: spec, sink: s})
+	}
+	return sinks, nil
+}
+
+// defaultSinkFormat returns the format of a sink without one: webhooks receive the JSON report,
+// Slack and SNS the compact one, and other sinks the --format report
+func defaultSinkFormat(kind, format string) string {
+	switch kind {
+	case sink.KindWebhook:
+		return formatter.ReportJSON
+	case sink.KindSlack, sink.KindSNS:
+		return formatter.ReportCompact
+	}
+	return format
+}
+
+// validateSinkFormat checks the format of a sink specification, if it has one
+func validateSinkFormat(spec sink.Spec) error {
+	if spec.Format != "" && !formatter.IsValidFormat(spec.Format) {
+		return fmt.Errorf("unknown format '%s' of sink '%s': must be one of %s",
+			spec.Format, spec.Destination, strings.Join(formatter.Formats(), ", "))
+	}
+	return nil
+}
+
+// deliverReport formats the report for each sink and delivers it; each report is formatted once.
+// The terminal wraps long messages to its width, and reports leaving the machine are not colored.
+// Only local sinks receive the report of an interrupted run. All sinks are tried, even if one fails.
+func deliverReport(ctx context.Context, sinks []reportSink, analyses []*analyzer.StackAnalysis, opts formatter.Options, interrupted bool) error {
+	plain, _ := formatter.LookupTheme("none")
+
+	reports := map[formatter.Options]string{}
+	var errs []error
+	for _, reportSink := range sinks {
+		kind, _ := reportSink.spec.Kind()
+		if interrupted && !reportSink.spec.Local() {
+			continue
+		}
+
+		sinkOpts := opts
+		sinkOpts.Format = reportSink.spec.Format
+		if kind == sink.KindTerminal {
+			sinkOpts.Width = terminalWidth()
+		} else if !reportSink.spec.Local() {
+			sinkOpts.Theme = &plain
+		}
+
+		report, ok := reports[sinkOpts]
+		if !ok {
+			if sinkOpts.Format == supportTextFormat {
+				report = formatter.FormatSupportText(analyses)
+			} else {
+				var err error
+				report, err = formatReport(analyses, sinkOpts)
+				if err != nil {
+					return err
+				}
+			}
+			reports[sinkOpts] = report
+		}
+
+		if err := reportSink.sink.Write(ctx, report); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		switch kind {
+		case sink.KindFile, sink.KindS3:
+			progressf("Report written to %s\n", reportSink.sink)
+		case sink.KindWebhook, sink.KindSlack:
+			progressf("Report posted to %s\n", reportSink.sink)
+		case sink.KindSNS:
+			progressf("Report published to %s\n", reportSink.sink)
+		}
+	}
+	return errors.Join(errs...)
+}
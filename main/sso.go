@@ -0,0 +1,47 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"cfn-root-cause/awserrors"
+
+	"golang.org/x/term"
+)
+
+// offerSSOLogin offers to refresh an expired AWS SSO session with the AWS CLI and reports whether
+// the login succeeded. It only asks in interactive terminals, so pipelines fail with the error as before.
+func offerSSOLogin() bool {
+	if !term.IsTerminal(int(os.Stdin.Fd())) || !term.IsTerminal(int(os.Stderr.Fd())) {
+		return false
+	}
+	if _, err := exec.LookPath("aws"); err != nil {
+		return false
+	}
+
+	command := awserrors.SSOLoginCommand()
+	fmt.Fprintf(os.Stderr, "The AWS SSO session for profile '%s' has expired. Run '%s' now? [y/N] ",
+		awserrors.ActiveProfile(), command)
+	answer, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil {
+		return false
+	}
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	if answer != "y" && answer != "yes" {
+		return false
+	}
+
+	login := exec.Command("aws", "sso", "login", "--profile", awserrors.ActiveProfile())
+	login.Stdin = os.Stdin
+	login.Stdout = os.Stderr
+	login.Stderr = os.Stderr
+	if err := login.Run(); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: '%s' failed: %v\n", command, err)
+		return false
+	}
+
+	return true
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"cfn-root-cause/analyzer"
+	"cfn-root-cause/patterns"
+	"cfn-root-cause/resourcestate"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation/types"
+)
+
+// detectStabilizationFailures explains resources that did not stabilize with their stack events
+// and their current state, read through the Cloud Control API. Types the API cannot read are
+// explained with the stack events only; other failures to read the state are reported as a warning.
+func detectStabilizationFailures(ctx context.Context, cfg aws.Config, events []types.StackEvent, errors []analyzer.CorrelatedError, stats *analyzer.AnalysisStats) []analyzer.Finding {
+	var findings []analyzer.Finding
+	var client *resourcestate.Client
+	phaseStart := time.Now()
+
+	for _, err := range errors {
+		stackErr := err.StackError
+		if !patterns.IsStabilizationFailure(stackErr) {
+			continue
+		}
+		if client == nil {
+			client = resourcestate.NewClientWithConfig(cfg)
+		}
+		progressf("Reading the current state of %s...\n", stackErr.LogicalResourceId)
+
+		var state *analyzer.ResourceState
+		stateRead := false
+		if identifier := physicalResourceId(events, stackErr.LogicalResourceId); identifier != "" {
+			var getErr error
+			state, getErr = client.Get(ctx, stackErr.ResourceType, identifier)
+			switch {
+			case getErr == resourcestate.ErrUnsupported:
+				progressf("Skipping the state of %s: %v\n", stackErr.LogicalResourceId, getErr)
+			case getErr != nil:
+				fmt.Fprintf(os.Stderr, "Warning: %v\n", getErr)
+			default:
+				stateRead = true
+			}
+		}
+
+		transitions := patterns.ResourceTransitions(events, stackErr)
+		findings = append(findings, patterns.DescribeStabilization(stackErr, transitions, state, stateRead))
+	}
+
+	if client != nil {
+		stats.RecordPhase("Read resource states", phaseStart)
+	}
+	return findings
+}
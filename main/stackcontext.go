@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"cfn-root-cause/analyzer"
+	"cfn-root-cause/cfnclient"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+// lookupStackContext returns the parameters and outputs of the stack
+func lookupStackContext(ctx context.Context, cfnClient *cfnclient.Client, stackName string) *analyzer.StackContext {
+	progressf("Retrieving stack parameters and outputs...\n")
+
+	stackContext, err := stackContextOf(ctx, cfnClient, stackName)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: Failed to retrieve stack parameters and outputs: %v\n", err)
+		return nil
+	}
+	return stackContext
+}
+
+// stackContextOf describes the stack and returns its parameters and outputs sorted by key.
+// CloudFormation returns the values of NoEcho parameters as asterisks, so they are never shown.
+func stackContextOf(ctx context.Context, cfnClient *cfnclient.Client, stackName string) (*analyzer.StackContext, error) {
+	stack, err := cfnClient.DescribeStack(ctx, stackName)
+	if err != nil {
+		return nil, err
+	}
+
+	stackContext := &analyzer.StackContext{}
+	for _, parameter := range stack.Parameters {
+		value := aws.ToString(parameter.ParameterValue)
+		noEcho := value != "" && strings.Trim(value, "*") == ""
+		if noEcho {
+			value = ""
+		}
+		stackContext.Parameters = append(stackContext.Parameters, analyzer.StackParameter{
+			Key:           aws.ToString(parameter.ParameterKey),
+			Value:         value,
+			ResolvedValue: aws.ToString(parameter.ResolvedValue),
+			NoEcho:        noEcho,
+		})
+	}
+	for _, stackOutput := range stack.Outputs {
+		stackContext.Outputs = append(stackContext.Outputs, analyzer.StackOutput{
+			Key:         aws.ToString(stackOutput.OutputKey),
+			Value:       aws.ToString(stackOutput.OutputValue),
+			Description: aws.ToString(stackOutput.Description),
+			ExportName:  aws.ToString(stackOutput.ExportName),
+		})
+	}
+
+	sort.Slice(stackContext.Parameters, func(i, j int) bool {
+		return stackContext.Parameters[i].Key < stackContext.Parameters[j].Key
+	})
+	sort.Slice(stackContext.Outputs, func(i, j int) bool {
+		return stackContext.Outputs[i].Key < stackContext.Outputs[j].Key
+	})
+	return stackContext, nil
+}
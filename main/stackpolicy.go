@@ -0,0 +1,58 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"cfn-root-cause/analyzer"
+	"cfn-root-cause/cfnclient"
+	"cfn-root-cause/patterns"
+)
+
+// detectStackProtection explains updates denied by the stack policy, with the denying statements
+// of the policy retrieved with GetStackPolicy, and deletions blocked by termination protection.
+func detectStackProtection(ctx context.Context, cfnClient *cfnclient.Client, stackName string, errors []analyzer.CorrelatedError, stats *analyzer.AnalysisStats) []analyzer.Finding {
+	var findings []analyzer.Finding
+	var statements []patterns.PolicyStatement
+	policyRetrieved := false
+	phaseStart := time.Now()
+
+	for _, err := range errors {
+		if protectedStack, ok := patterns.TerminationProtectedStack(err.StackError.ResourceStatusReason); ok {
+			if protectedStack == "" {
+				protectedStack = stackName
+			}
+			findings = append(findings, patterns.DescribeTerminationProtection(err.StackError, cfnclient.StackName(protectedStack)))
+			continue
+		}
+
+		denial, ok := patterns.StackPolicyViolation(err.StackError.ResourceStatusReason)
+		if !ok {
+			continue
+		}
+		if !policyRetrieved {
+			policyRetrieved = true
+			progressf("Retrieving stack policy...\n")
+			var lookupErr error
+			statements, lookupErr = stackPolicyStatements(ctx, cfnClient, stackName)
+			if lookupErr != nil {
+				fmt.Fprintf(os.Stderr, "Warning: %v\n", lookupErr)
+			}
+			stats.RecordPhase("Retrieve stack policy", phaseStart)
+		}
+		findings = append(findings, patterns.DescribeStackPolicyDenial(stackName, err.StackError, denial, statements))
+	}
+
+	return findings
+}
+
+// stackPolicyStatements returns the statements of the current stack policy of a stack
+func stackPolicyStatements(ctx context.Context, cfnClient *cfnclient.Client, stackName string) ([]patterns.PolicyStatement, error) {
+	body, err := cfnClient.GetStackPolicy(ctx, stackName)
+	if err != nil || body == "" {
+		return nil, err
+	}
+	return patterns.ParseStackPolicy(body)
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"strings"
+
+	"cfn-root-cause/analyzer"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation/types"
+)
+
+// stackStatusOf returns the current status of a described stack
+func stackStatusOf(stack *types.Stack) *analyzer.StackStatus {
+	status := string(stack.StackStatus)
+	return &analyzer.StackStatus{
+		Status:     status,
+		Reason:     strings.TrimSpace(aws.ToString(stack.StackStatusReason)),
+		InProgress: strings.HasSuffix(status, "_IN_PROGRESS"),
+	}
+}
+
+// stackStatusFromEvents returns the status of the stack recorded by its newest stack-level event,
+// for stacks that could not be described, as for stack events read from stdin; nil if there is none
+func stackStatusFromEvents(events []types.StackEvent) *analyzer.StackStatus {
+	for _, event := range events {
+		if aws.ToString(event.ResourceType) != "AWS::CloudFormation::Stack" ||
+			aws.ToString(event.PhysicalResourceId) != aws.ToString(event.StackId) {
+			continue
+		}
+		status := string(event.ResourceStatus)
+		return &analyzer.StackStatus{
+			Status:     status,
+			Reason:     strings.TrimSpace(aws.ToString(event.ResourceStatusReason)),
+			InProgress: strings.HasSuffix(status, "_IN_PROGRESS"),
+		}
+	}
+	return nil
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"cfn-root-cause/aggregate"
+	"cfn-root-cause/history"
+)
+
+// runStats prints error frequencies by stack, resource type and error code from the analysis
+// history, followed by the number of errors and the average deployment health per week
+func runStats(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("stats", flag.ContinueOnError)
+	historyFile := fs.String("history-file", "", "history file (default ~/.config/cfnrc/history.jsonl)")
+	since := fs.Duration("since", 30*24*time.Hour, "only count errors that occurred within this period")
+	top := fs.Int("top", 10, "list at most this many stacks, resource types and error codes; 0 lists all")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() > 0 {
+		return fmt.Errorf("usage: stats [--history-file FILE] [--since DURATION] [--top N]")
+	}
+	if *since <= 0 {
+		return fmt.Errorf("invalid period %s: must be positive", *since)
+	}
+	if *top < 0 {
+		return fmt.Errorf("invalid top %d: must not be negative", *top)
+	}
+
+	path, err := historyPath(*historyFile)
+	if err != nil {
+		return err
+	}
+
+	records, err := history.Load(path)
+	if err != nil {
+		return err
+	}
+
+	start := time.Now().UTC().Add(-*since)
+	trends := aggregate.SummarizeHistory(records, start)
+	if trends.Analyses == 0 {
+		fmt.Printf("No analyses recorded since %s in %s\n", start.Format("2006-01-02"), path)
+		return nil
+	}
+
+	fmt.Printf("%d error(s) in %d analyses since %s\n", trends.TotalErrors, trends.Analyses, start.Format("2006-01-02"))
+	if trends.Operations > 0 {
+		fmt.Printf("Deployment health: %d/100 on average over %d operation(s)\n", trends.HealthScore, trends.Operations)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	printCounts(w, "STACK", trends.ByStack, *top)
+	printCounts(w, "RESOURCE TYPE", trends.ByResourceType, *top)
+	printCounts(w, "ERROR CODE", trends.ByErrorCode, *top)
+
+	fmt.Fprintln(w, "\nWEEK\tERRORS\tHEALTH\tTOP ERROR CODE")
+	for _, period := range trends.Weekly {
+		health := "-"
+		if period.HealthScore >= 0 {
+			health = strconv.Itoa(period.HealthScore)
+		}
+		fmt.Fprintf(w, "%s\t%d\t%s\t%s\n", period.Start.Format("2006-01-02"), period.Errors, health, period.TopErrorCode)
+	}
+
+	return w.Flush()
+}
+
+// printCounts writes a two-column table of counts, limited to the top entries
+func printCounts(w io.Writer, title string, counts []aggregate.Count, top int) {
+	if top > 0 && len(counts) > top {
+		counts = counts[:top]
+	}
+
+	fmt.Fprintf(w, "\n%s\tERRORS\n", title)
+	for _, count := range counts {
+		fmt.Fprintf(w, "%s\t%d\n", count.Name, count.Count)
+	}
+}
+
+// historyPath returns the given history file, or the default location if none is given
+func historyPath(path string) (string, error) {
+	if path != "" {
+		return path, nil
+	}
+	return history.DefaultPath()
+}
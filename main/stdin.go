@@ -0,0 +1,77 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"cfn-root-cause/analyzer"
+
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation/types"
+)
+
+// readStackEvents decodes the output of 'aws cloudformation describe-stack-events': an object
+// with a StackEvents list, or the bare list produced with --query StackEvents. The outputs of
+// several calls may follow each other.
+func readStackEvents(r io.Reader) ([]types.StackEvent, error) {
+	decoder := json.NewDecoder(r)
+
+	var events []types.StackEvent
+	for {
+		var raw json.RawMessage
+		if err := decoder.Decode(&raw); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("failed to parse stack events from stdin: %w", err)
+		}
+
+		var page []types.StackEvent
+		if trimmed := bytes.TrimSpace(raw); len(trimmed) > 0 && trimmed[0] == '[' {
+			if err := json.Unmarshal(raw, &page); err != nil {
+				return nil, fmt.Errorf("failed to parse stack events from stdin: %w", err)
+			}
+		} else {
+			var output struct {
+				StackEvents []types.StackEvent
+			}
+			if err := json.Unmarshal(raw, &output); err != nil {
+				return nil, fmt.Errorf("failed to parse stack events from stdin: %w", err)
+			}
+			page = output.StackEvents
+		}
+		events = append(events, page...)
+	}
+
+	if len(events) == 0 {
+		return nil, fmt.Errorf("no stack events on stdin; pipe the output of 'aws cloudformation describe-stack-events --stack-name NAME'")
+	}
+	return events, nil
+}
+
+// analyzeStdinEvents analyzes stack events read from stdin. Neither the stack nor CloudTrail is
+// queried, so GeneralServiceExceptions keep the message of their stack event. The errors of the
+// day of the newest error are analyzed, just like a live analysis on the day of the failure.
+// The report notes the GeneralServiceExceptions left without CloudTrail details.
+func analyzeStdinEvents(ctx context.Context, stackName string, events []types.StackEvent) *analyzer.StackAnalysis {
+	stats := &analyzer.AnalysisStats{}
+	analysis := analyzeEvents(ctx, stackName, events, newestErrorDate(events, time.Now()), offlineTrail{}, stats)
+	if analysis.GeneralErrors > 0 {
+		analysis.Warnings = append(analysis.Warnings, analyzer.Warning{
+			Kind: analyzer.WarningCloudTrailSkipped,
+			Message: fmt.Sprintf("CloudTrail is not queried for stack events read from stdin; %d GeneralServiceException(s) lack CloudTrail details",
+				analysis.GeneralErrors),
+		})
+	}
+	return analysis
+}
+
+// offlineTrail is the trail searcher of stack events read from stdin; it finds no CloudTrail events
+type offlineTrail struct{}
+
+// SearchForStackErrors returns no events
+func (offlineTrail) SearchForStackErrors(ctx context.Context, stackError analyzer.StackError) ([]analyzer.CloudTrailEvent, error) {
+	return nil, nil
+}
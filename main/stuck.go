@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"cfn-root-cause/awsconfig"
+	"cfn-root-cause/cfnclient"
+	"cfn-root-cause/patterns"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation"
+)
+
+// runStuck lists the resources of a stack in progress with how long they have been in progress,
+// compared with their durations in earlier deployments of the stack or the usual duration of their
+// type, and explains the resources that are likely hung.
+func runStuck(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("stuck", flag.ContinueOnError)
+	factor := fs.Float64("factor", patterns.DefaultStuckFactor,
+		"flag resources in progress longer than this many times their usual duration")
+	exitCode := fs.Bool("exit-code", false, fmt.Sprintf("exit with status %d when a resource is likely hung", exitCodeErrorsFound))
+	var awsOpts awsconfig.Options
+	addAWSFlags(fs, &awsOpts)
+
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return err
+		}
+		if fs.NArg() == 0 {
+			break
+		}
+		positional = append(positional, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+	if len(positional) != 1 || *factor <= 1 {
+		return fmt.Errorf("usage: stuck <stack-name> [--factor N (> 1)] [--exit-code]")
+	}
+	stackName := positional[0]
+
+	awsCfg, err := loadAWSConfig(ctx, awsOpts)
+	if err != nil {
+		return err
+	}
+	cfnClient := cfnclient.NewClientWithConfig(awsCfg)
+
+	output, err := cfnClient.DescribeStacks(ctx, &cloudformation.DescribeStacksInput{StackName: aws.String(stackName)})
+	if err != nil {
+		return fmt.Errorf("failed to describe stack '%s': %w", stackName, err)
+	}
+	if len(output.Stacks) == 0 {
+		return fmt.Errorf("stack '%s' not found", stackName)
+	}
+	stackStatus := string(output.Stacks[0].StackStatus)
+	if !strings.HasSuffix(stackStatus, "_IN_PROGRESS") {
+		fmt.Printf("Stack %s is not in progress (%s)\n", stackName, stackStatus)
+		return nil
+	}
+
+	// All events include the earlier deployments the usual durations are taken from
+	events, err := cfnClient.GetStackEvents(ctx, stackName)
+	if err != nil {
+		return err
+	}
+	operationEvents, err := cfnClient.GetLatestOperationEvents(ctx, stackName)
+	if err != nil {
+		return err
+	}
+
+	resources := patterns.InProgressResources(operationEvents, patterns.PastDurations(events), time.Now(), *factor)
+	fmt.Printf("Stack %s is %s\n\n", stackName, stackStatus)
+	if len(resources) == 0 {
+		fmt.Println("No resource is in progress.")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "RESOURCE\tTYPE\tSTATUS\tELAPSED\tUSUALLY\tSTATE")
+	hung := 0
+	for _, resource := range resources {
+		state := "ok"
+		if resource.Hung {
+			state = "LIKELY HUNG"
+			hung++
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", resource.LogicalResourceId, resource.ResourceType, resource.Status,
+			resource.Elapsed.Round(time.Second), resource.Expected.Round(time.Second), state)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	for _, resource := range resources {
+		if !resource.Hung {
+			continue
+		}
+		finding := patterns.DescribeStuckResource(stackName, stackStatus, resource)
+		fmt.Printf("\n%s: %s\n%s\n", finding.Title, finding.LogicalResourceId, finding.Explanation)
+		for _, evidence := range finding.Evidence {
+			fmt.Printf("  - %s\n", evidence)
+		}
+		fmt.Printf("Suggestion: %s\n", finding.Suggestion)
+	}
+
+	if hung > 0 && *exitCode {
+		return &exitError{code: exitCodeErrorsFound}
+	}
+	return nil
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"cfn-root-cause/analyzer"
+	"cfn-root-cause/cfnclient"
+	"cfn-root-cause/templatediff"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation/types"
+)
+
+// lookupTemplateDiff compares the template of the failed deployment with the previously deployed one
+func lookupTemplateDiff(ctx context.Context, cfnClient *cfnclient.Client, stackName string) *analyzer.TemplateDiff {
+	progressf("Comparing templates...\n")
+
+	diff, err := templateDiff(ctx, cfnClient, stackName)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: Failed to compare templates: %v\n", err)
+		return nil
+	}
+	return diff
+}
+
+// templateDiff fetches the template of the change set used by the last update and the template
+// the stack was rolled back to, and diffs them. Only rolled back updates deployed with a change
+// set keep both templates.
+func templateDiff(ctx context.Context, cfnClient *cfnclient.Client, stackName string) (*analyzer.TemplateDiff, error) {
+	stack, err := cfnClient.DescribeStack(ctx, stackName)
+	if err != nil {
+		return nil, err
+	}
+
+	switch stack.StackStatus {
+	case types.StackStatusUpdateRollbackComplete, types.StackStatusUpdateRollbackFailed,
+		types.StackStatusUpdateRollbackInProgress, types.StackStatusUpdateRollbackCompleteCleanupInProgress:
+	default:
+		return nil, fmt.Errorf("stack status %s has no previous deployment to compare with", stack.StackStatus)
+	}
+
+	changeSetId := aws.ToString(stack.ChangeSetId)
+	if changeSetId == "" {
+		return nil, fmt.Errorf("the last update was not deployed with a change set, so its template is not retained")
+	}
+
+	// After the rollback the stack is associated with the previous template again
+	previous, err := cfnClient.GetTemplateBody(ctx, stackName, "")
+	if err != nil {
+		return nil, err
+	}
+
+	failed, err := cfnClient.GetTemplateBody(ctx, stackName, changeSetId)
+	if err != nil {
+		return nil, err
+	}
+
+	return &analyzer.TemplateDiff{
+		ChangeSetId: changeSetId,
+		Diff:        templatediff.Unified("previous", "failed", previous, failed, templatediff.DefaultContext),
+	}, nil
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"cfn-root-cause/analyzer"
+	"cfn-root-cause/cfnclient"
+	"cfn-root-cause/cfntemplate"
+	"cfn-root-cause/patterns"
+
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation/types"
+)
+
+// detectTemplateLimits explains deployments that exceeded a template quota, such as the 500
+// resource limit, with the current counts of the template.
+func detectTemplateLimits(ctx context.Context, cfnClient *cfnclient.Client, stackName string, events []types.StackEvent, errors []analyzer.CorrelatedError, stats *analyzer.AnalysisStats) []analyzer.Finding {
+	var findings []analyzer.Finding
+	templateCounts := make(map[string]*patterns.TemplateCounts)
+	phaseStart := time.Now()
+
+	for _, err := range errors {
+		limit, ok := patterns.TemplateLimitViolation(err.StackError.ResourceStatusReason)
+		if !ok {
+			continue
+		}
+
+		target := templateOwner(stackName, events, err.StackError)
+		counts, seen := templateCounts[target]
+		if !seen && target != "" {
+			progressf("Counting template resources...\n")
+			var lookupErr error
+			counts, lookupErr = countTemplate(ctx, cfnClient, target)
+			if lookupErr != nil {
+				fmt.Fprintf(os.Stderr, "Warning: Failed to count template resources: %v\n", lookupErr)
+			}
+			templateCounts[target] = counts
+		}
+
+		findings = append(findings, patterns.DescribeTemplateLimit(err.StackError, limit, counts))
+	}
+
+	if len(templateCounts) > 0 {
+		stats.RecordPhase("Count template resources", phaseStart)
+	}
+	return findings
+}
+
+// countTemplate returns the sizes of the template of the latest deployment of a stack
+func countTemplate(ctx context.Context, cfnClient *cfnclient.Client, stackName string) (*patterns.TemplateCounts, error) {
+	body, _, err := latestDeployment(ctx, cfnClient, stackName)
+	if err != nil {
+		return nil, err
+	}
+	template, err := cfntemplate.Parse(body)
+	if err != nil {
+		return nil, err
+	}
+
+	counts := patterns.CountTemplate(body, template)
+	return &counts, nil
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"cfn-root-cause/analyzer"
+	"cfn-root-cause/awserrors"
+	"cfn-root-cause/cloudtrail"
+	"cfn-root-cause/correlator"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+// checkTrailRecording warns if no trail records the management events of the region. The event
+// history searched by the analysis covers the last 90 days without a trail, but failures older
+// than that cannot be looked up. Missing permissions to describe trails skip the check silently,
+// since it is optional; other failures are reported as a warning.
+func checkTrailRecording(ctx context.Context, cfg aws.Config) {
+	trails, err := cloudtrail.NewClientWithConfig(cfg).Trails(ctx)
+	if err != nil {
+		if !awserrors.IsPermissionError(err) && ctx.Err() == nil {
+			fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+		}
+		return
+	}
+	if !cloudtrail.RecordsManagementEvents(trails) {
+		fmt.Fprintf(os.Stderr, "Warning: No trail records the management events of %s; the CloudTrail event history "+
+			"only covers the last 90 days.\n", cfg.Region)
+	}
+}
+
+// requerySearcher is a trailSearcher that can look up the events of recent failures again once
+// CloudTrail delivered them: the CloudTrail client
+type requerySearcher interface {
+	trailSearcher
+	RequeryDelay(newestFailure time.Time) time.Duration
+}
+
+// requeryRecentFailures looks up the GeneralServiceExceptions more recent than the delivery lag of
+// CloudTrail that no event matched again after waiting for their events, instead of reporting them
+// without details, and correlates the errors again, with a warning for each failed lookup. An
+// interrupted wait keeps the first correlation.
+func requeryRecentFailures(ctx context.Context, searcher trailSearcher, stackErrors []analyzer.StackError, trailEvents []analyzer.CloudTrailEvent,
+	correlatedErrors []analyzer.CorrelatedError, stats *analyzer.AnalysisStats) ([]analyzer.CorrelatedError, []analyzer.Warning) {
+	requery, ok := searcher.(requerySearcher)
+	if !ok || ctx.Err() != nil {
+		return correlatedErrors, nil
+	}
+
+	var pending []analyzer.StackError
+	var newest time.Time
+	for _, err := range correlatedErrors {
+		stackErr := err.StackError
+		if stackErr.IsGeneralServiceException && err.CloudTrailEvent == nil && time.Since(stackErr.Timestamp) < cloudtrail.DeliveryLag {
+			pending = append(pending, stackErr)
+			if stackErr.Timestamp.After(newest) {
+				newest = stackErr.Timestamp
+			}
+		}
+	}
+	if len(pending) == 0 {
+		return correlatedErrors, nil
+	}
+	delay := requery.RequeryDelay(newest)
+	if delay == 0 {
+		return correlatedErrors, nil
+	}
+
+	progressf("No CloudTrail event matches %d recent failure(s) yet; looking them up again in %s, once CloudTrail delivered them (--cloudtrail-wait)\n",
+		len(pending), delay.Round(time.Second))
+	select {
+	case <-ctx.Done():
+		return correlatedErrors, nil
+	case <-time.After(delay):
+	}
+
+	phaseStart := time.Now()
+	seen := make(map[string]bool, len(trailEvents))
+	for _, event := range trailEvents {
+		seen[event.EventID] = true
+	}
+	events, warnings := queryCloudTrailForErrors(ctx, searcher, pending)
+	for _, event := range events {
+		if !seen[event.EventID] {
+			seen[event.EventID] = true
+			trailEvents = append(trailEvents, event)
+		}
+	}
+	correlatedErrors = correlator.CorrelateErrors(stackErrors, trailEvents)
+	stats.RecordPhase("Query CloudTrail again", phaseStart)
+	return correlatedErrors, warnings
+}
+
+// awaitsDelivery reports whether an analysis has GeneralServiceExceptions without CloudTrail
+// details that are recent enough for CloudTrail to deliver their events later
+func awaitsDelivery(analysis *analyzer.StackAnalysis) bool {
+	for _, err := range analysis.Errors {
+		if err.StackError.IsGeneralServiceException && err.CloudTrailEvent == nil &&
+			time.Since(err.StackError.Timestamp) < cloudtrail.DeliveryLag {
+			return true
+		}
+	}
+	return false
+}
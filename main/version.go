@@ -0,0 +1,46 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+
+	"cfn-root-cause/buildinfo"
+)
+
+// runVersion prints the version, commit and build date of the binary. With --check-update it
+// asks GitHub for the latest release; newer releases recognize more services and failure patterns.
+// The check is opt-in, so the analyzer never contacts GitHub on its own.
+func runVersion(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("version", flag.ContinueOnError)
+	checkUpdate := fs.Bool("check-update", false, "check GitHub for a newer release")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() > 0 {
+		return fmt.Errorf("usage: version [--check-update]")
+	}
+
+	info := buildinfo.Current()
+	fmt.Print(info.String())
+	if !*checkUpdate {
+		return nil
+	}
+
+	release, err := buildinfo.LatestRelease(ctx, buildinfo.LatestReleaseURL)
+	if err != nil {
+		return err
+	}
+	newer, ok := buildinfo.IsNewer(release.Tag, info.Version)
+	switch {
+	case !ok:
+		fmt.Printf("\nThe latest release is %s (%s); this build's version %s cannot be compared.\n",
+			release.Tag, release.URL, info.Version)
+	case newer:
+		fmt.Printf("\nA newer version is available: %s, published %s.\n", release.Tag, release.PublishedAt.Format("2006-01-02"))
+		fmt.Printf("It may recognize more services and failure patterns: %s\n", release.URL)
+	default:
+		fmt.Printf("\nThis is the latest version.\n")
+	}
+	return nil
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"cfn-root-cause/analyzer"
+	"cfn-root-cause/patterns"
+	"cfn-root-cause/vpcquota"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+// detectVPCLimits explains failures on a reached quota of VPC resources with the resources in use
+// and the quota of the account. Each quota is read once; failures to count the resources are
+// reported as a warning, and the finding then names the quota only.
+func detectVPCLimits(ctx context.Context, cfg aws.Config, errors []analyzer.CorrelatedError, stats *analyzer.AnalysisStats) []analyzer.Finding {
+	failures := patterns.VPCLimitFailures(errors)
+	if len(failures) == 0 {
+		return nil
+	}
+
+	progressf("Counting VPC resources for %d quota failure(s)...\n", len(failures))
+	phaseStart := time.Now()
+	client := vpcquota.NewClientWithConfig(cfg)
+	usages := make(map[string]*analyzer.ResourceUsage)
+	var findings []analyzer.Finding
+	for _, failure := range failures {
+		usage, read := usages[failure.Code]
+		if !read {
+			var err error
+			if usage, err = client.Usage(ctx, failure.Code); err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+			}
+			usages[failure.Code] = usage
+		}
+		findings = append(findings, patterns.DescribeVPCLimit(failure, usage))
+	}
+	stats.RecordPhase("Count VPC resources", phaseStart)
+
+	return findings
+}
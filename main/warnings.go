@@ -0,0 +1,74 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"cfn-root-cause/analyzer"
+	"cfn-root-cause/cfnclient"
+	"cfn-root-cause/cloudtrail"
+	"cfn-root-cause/correlator"
+
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation/types"
+)
+
+// warn prints a warning to stderr as it is found and returns it for the report
+func warn(kind, logicalResourceId, format string, args ...any) analyzer.Warning {
+	message := fmt.Sprintf(format, args...)
+	fmt.Fprintf(os.Stderr, "Warning: %s\n", message)
+	return analyzer.Warning{Kind: kind, LogicalResourceId: logicalResourceId, Message: message}
+}
+
+// truncationWarnings warns if no operation start precedes the oldest error, so errors the
+// operation reported earlier are missing from the stack events, as for truncated input
+func truncationWarnings(events []types.StackEvent, stackErrors []analyzer.StackError) []analyzer.Warning {
+	if len(stackErrors) == 0 {
+		return nil
+	}
+	oldest := stackErrors[0].Timestamp
+	for _, stackErr := range stackErrors {
+		if stackErr.Timestamp.Before(oldest) {
+			oldest = stackErr.Timestamp
+		}
+	}
+	for _, event := range events {
+		if cfnclient.IsOperationStart(event) && event.Timestamp != nil && !event.Timestamp.After(oldest) {
+			return nil
+		}
+	}
+	return []analyzer.Warning{warn(analyzer.WarningEventsTruncated, "",
+		"The stack events do not reach back to the start of the failed operation; earlier errors may be missing")}
+}
+
+// lowConfidenceWarnings warns about CloudTrail events that match their error only by time, so
+// they may belong to another resource
+func lowConfidenceWarnings(errors []analyzer.CorrelatedError) []analyzer.Warning {
+	var warnings []analyzer.Warning
+	for _, err := range errors {
+		if correlator.Confidence(err) != correlator.ConfidenceLow {
+			continue
+		}
+		warnings = append(warnings, warn(analyzer.WarningLowConfidence, err.StackError.LogicalResourceId,
+			"The CloudTrail event %s (%s) matches %s only by time and may belong to another resource",
+			err.CloudTrailEvent.EventName, err.CloudTrailEvent.EventSource, err.StackError.LogicalResourceId))
+	}
+	return warnings
+}
+
+// deliveryLagWarnings warns about GeneralServiceExceptions without CloudTrail details that are more
+// recent than the delivery lag of CloudTrail, so their events may not have been delivered yet
+func deliveryLagWarnings(errors []analyzer.CorrelatedError) []analyzer.Warning {
+	var warnings []analyzer.Warning
+	for _, err := range errors {
+		stackErr := err.StackError
+		age := time.Since(stackErr.Timestamp)
+		if !stackErr.IsGeneralServiceException || err.CloudTrailEvent != nil || age >= cloudtrail.DeliveryLag {
+			continue
+		}
+		warnings = append(warnings, warn(analyzer.WarningDeliveryLag, stackErr.LogicalResourceId,
+			"The failure of %s is %s old; CloudTrail delivers events up to %d minutes after the call, so its details may be missing",
+			stackErr.LogicalResourceId, age.Round(time.Second), int(cloudtrail.DeliveryLag.Minutes())))
+	}
+	return warnings
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"cfn-root-cause/awsconfig"
+	"cfn-root-cause/cfnclient"
+	"cfn-root-cause/patterns"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation"
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation/types"
+	"golang.org/x/term"
+)
+
+// defaultWatchInterval is how often watch polls the stack events
+const defaultWatchInterval = 10 * time.Second
+
+// defaultHistoryStacks is the number of recently deployed stacks of the account whose resource
+// durations inform the estimates of watch
+const defaultHistoryStacks = 5
+
+// runWatch follows a stack operation until it ends, printing the stack events as they arrive and
+// an ETA line for the resources in progress, estimated from the earlier durations of the same
+// resources and resource types. On a terminal, the ETA line is updated in place.
+func runWatch(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("watch", flag.ContinueOnError)
+	interval := fs.Duration("interval", defaultWatchInterval, "how often the stack events are polled")
+	historyStacks := fs.Int("history-stacks", defaultHistoryStacks,
+		"number of recently deployed stacks of the account whose resource durations inform the ETA")
+	var awsOpts awsconfig.Options
+	addAWSFlags(fs, &awsOpts)
+
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return err
+		}
+		if fs.NArg() == 0 {
+			break
+		}
+		positional = append(positional, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+	if len(positional) != 1 || *interval < time.Second {
+		return fmt.Errorf("usage: watch <stack-name> [--interval DURATION (>= 1s)] [--history-stacks N]")
+	}
+	stackName := positional[0]
+
+	awsCfg, err := loadAWSConfig(ctx, awsOpts)
+	if err != nil {
+		return err
+	}
+	cfnClient := cfnclient.NewClientWithConfig(awsCfg)
+
+	progressf("Reading earlier deployments...\n")
+	events, err := cfnClient.GetStackEvents(ctx, stackName)
+	if err != nil {
+		return err
+	}
+	past := patterns.PastDurations(events)
+	typeDurations := accountTypeDurations(ctx, cfnClient, stackName, *historyStacks)
+
+	interactive := term.IsTerminal(int(os.Stdout.Fd()))
+	seen := make(map[string]bool)
+	etaLine := ""
+	for {
+		output, err := cfnClient.DescribeStacks(ctx, &cloudformation.DescribeStacksInput{StackName: aws.String(stackName)})
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return fmt.Errorf("failed to describe stack '%s': %w", stackName, err)
+		}
+		if len(output.Stacks) == 0 {
+			return fmt.Errorf("stack '%s' not found", stackName)
+		}
+		stackStatus := string(output.Stacks[0].StackStatus)
+
+		operationEvents, err := cfnClient.GetLatestOperationEvents(ctx, stackName)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+
+		var newEvents []types.StackEvent
+		for _, event := range operationEvents {
+			if id := aws.ToString(event.EventId); !seen[id] {
+				seen[id] = true
+				newEvents = append(newEvents, event)
+			}
+		}
+		if len(newEvents) > 0 && interactive && etaLine != "" {
+			fmt.Print("\r\033[K")
+		}
+		// Events are returned newest first
+		for _, event := range slices.Backward(newEvents) {
+			fmt.Printf("%s  %-30s %-40s %s  %s\n", aws.ToTime(event.Timestamp).Local().Format("15:04:05"),
+				aws.ToString(event.LogicalResourceId), aws.ToString(event.ResourceType), event.ResourceStatus,
+				strings.Join(strings.Fields(aws.ToString(event.ResourceStatusReason)), " "))
+		}
+
+		if !strings.HasSuffix(stackStatus, "_IN_PROGRESS") {
+			if interactive && etaLine != "" && len(newEvents) == 0 {
+				fmt.Print("\r\033[K")
+			}
+			fmt.Printf("\nStack %s finished with %s\n", stackName, stackStatus)
+			if strings.Contains(stackStatus, "FAILED") || strings.Contains(stackStatus, "ROLLBACK") {
+				fmt.Printf("Analyze the failure with: %s %s\n", os.Args[0], stackName)
+			}
+			return nil
+		}
+
+		now := time.Now()
+		line := patterns.FormatETA(patterns.EstimateDeployment(operationEvents, past, typeDurations, now), now)
+		switch {
+		case interactive:
+			// A wrapped line could not be updated in place
+			if width := terminalWidth(); width > 0 && len(line) >= width {
+				line = line[:width-1]
+			}
+			fmt.Print("\r\033[K" + line)
+		case line != "" && (line != etaLine || len(newEvents) > 0):
+			fmt.Println(line)
+		}
+		etaLine = line
+
+		select {
+		case <-ctx.Done():
+			if interactive {
+				fmt.Println()
+			}
+			return nil
+		case <-time.After(*interval):
+		}
+	}
+}
+
+// accountTypeDurations returns the durations of completed resource operations by resource type in
+// the most recently deployed stacks of the account other than stackName. Failures to read the
+// stacks are reported as a warning; the estimates then rely on the stack itself.
+func accountTypeDurations(ctx context.Context, cfnClient *cfnclient.Client, stackName string, count int) map[string][]time.Duration {
+	if count <= 0 {
+		return nil
+	}
+	summaries, err := cfnClient.ListStacksWithStatus(ctx, []types.StackStatus{
+		types.StackStatusCreateComplete, types.StackStatusUpdateComplete,
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+		return nil
+	}
+	slices.SortFunc(summaries, func(a, b types.StackSummary) int { return lastUpdate(b).Compare(lastUpdate(a)) })
+
+	durations := make(map[string][]time.Duration)
+	for _, summary := range summaries {
+		if count == 0 {
+			break
+		}
+		if aws.ToString(summary.StackName) == stackName {
+			continue
+		}
+		count--
+		events, err := cfnClient.GetStackEvents(ctx, aws.ToString(summary.StackId))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+			return durations
+		}
+		for resourceType, typeDurations := range patterns.TypeDurations(events) {
+			durations[resourceType] = append(durations[resourceType], typeDurations...)
+		}
+	}
+	return durations
+}
@@ -0,0 +1,224 @@
+// Package metrics provides counters and histograms exposed in the Prometheus text format
+// when the analyzer runs as a server
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Default metrics recorded by the analyzer
+var (
+	// AnalysesTotal counts completed analyses by result (success, error)
+	AnalysesTotal = NewCounter("cfnrc_analyses_total",
+		"Number of stack analyses run.", "result")
+
+	// ErrorsFoundTotal counts stack errors found by resource status and GeneralServiceException flag
+	ErrorsFoundTotal = NewCounter("cfnrc_errors_found_total",
+		"Number of CloudFormation errors found by category.", "status", "general_service_exception")
+
+	// FindingsTotal counts recognized failure patterns
+	FindingsTotal = NewCounter("cfnrc_findings_total",
+		"Number of root cause findings by pattern.", "pattern")
+
+	// CloudTrailQueriesTotal counts CloudTrail LookupEvents calls
+	CloudTrailQueriesTotal = NewCounter("cfnrc_cloudtrail_queries_total",
+		"Number of CloudTrail LookupEvents API calls.")
+
+	// ThrottlesTotal counts throttled AWS API calls by service
+	ThrottlesTotal = NewCounter("cfnrc_aws_throttles_total",
+		"Number of throttled AWS API calls.", "service")
+
+	// AnalysisDuration observes the analysis latency in seconds
+	AnalysisDuration = NewHistogram("cfnrc_analysis_duration_seconds",
+		"Latency of stack analyses in seconds.", []float64{0.5, 1, 2.5, 5, 10, 30, 60, 120, 300})
+)
+
+// collector is a metric that can be written in the Prometheus text format
+type collector interface {
+	writeText(w io.Writer)
+}
+
+// defaultRegistry holds all metrics created with NewCounter and NewHistogram
+var defaultRegistry = &registry{}
+
+// registry is an ordered list of collectors
+type registry struct {
+	mu         sync.Mutex
+	collectors []collector
+}
+
+// register adds a collector to the registry
+func (r *registry) register(c collector) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.collectors = append(r.collectors, c)
+}
+
+// WriteText writes all registered metrics in the Prometheus text exposition format
+func WriteText(w io.Writer) {
+	defaultRegistry.mu.Lock()
+	collectors := append([]collector(nil), defaultRegistry.collectors...)
+	defaultRegistry.mu.Unlock()
+
+	for _, c := range collectors {
+		c.writeText(w)
+	}
+}
+
+// Handler returns an HTTP handler serving the registered metrics
+func Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		WriteText(w)
+	})
+}
+
+// Counter is a monotonically increasing value partitioned by label values
+type Counter struct {
+	name       string
+	help       string
+	labelNames []string
+
+	mu     sync.Mutex
+	values map[string]float64
+}
+
+// NewCounter creates and registers a counter with the given label names
+func NewCounter(name, help string, labelNames ...string) *Counter {
+	c := &Counter{
+		name:       name,
+		help:       help,
+		labelNames: labelNames,
+		values:     make(map[string]float64),
+	}
+	defaultRegistry.register(c)
+	return c
+}
+
+// Inc increments the counter for the given label values by one
+func (c *Counter) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+// Add increments the counter for the given label values by delta
+func (c *Counter) Add(delta float64, labelValues ...string) {
+	key := formatLabels(c.labelNames, labelValues)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.values[key] += delta
+}
+
+// writeText writes the counter in the Prometheus text format
+func (c *Counter) writeText(w io.Writer) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	fmt.Fprintf(w, "# HELP %s %s\n", c.name, c.help)
+	fmt.Fprintf(w, "# TYPE %s counter\n", c.name)
+
+	if len(c.labelNames) == 0 && len(c.values) == 0 {
+		fmt.Fprintf(w, "%s 0\n", c.name)
+		return
+	}
+
+	for _, key := range sortedKeys(c.values) {
+		fmt.Fprintf(w, "%s%s %s\n", c.name, key, formatValue(c.values[key]))
+	}
+}
+
+// Histogram counts observations in cumulative buckets
+type Histogram struct {
+	name    string
+	help    string
+	buckets []float64
+
+	mu     sync.Mutex
+	counts []uint64
+	sum    float64
+	count  uint64
+}
+
+// NewHistogram creates and registers a histogram with the given upper bucket bounds
+func NewHistogram(name, help string, buckets []float64) *Histogram {
+	h := &Histogram{
+		name:    name,
+		help:    help,
+		buckets: buckets,
+		counts:  make([]uint64, len(buckets)),
+	}
+	defaultRegistry.register(h)
+	return h
+}
+
+// Observe records a single observation
+func (h *Histogram) Observe(value float64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for i, bound := range h.buckets {
+		if value <= bound {
+			h.counts[i]++
+		}
+	}
+	h.sum += value
+	h.count++
+}
+
+// writeText writes the histogram in the Prometheus text format
+func (h *Histogram) writeText(w io.Writer) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	fmt.Fprintf(w, "# HELP %s %s\n", h.name, h.help)
+	fmt.Fprintf(w, "# TYPE %s histogram\n", h.name)
+
+	for i, bound := range h.buckets {
+		fmt.Fprintf(w, "%s_bucket{le=\"%s\"} %d\n", h.name, formatValue(bound), h.counts[i])
+	}
+	fmt.Fprintf(w, "%s_bucket{le=\"+Inf\"} %d\n", h.name, h.count)
+	fmt.Fprintf(w, "%s_sum %s\n", h.name, formatValue(h.sum))
+	fmt.Fprintf(w, "%s_count %d\n", h.name, h.count)
+}
+
+// formatLabels renders label names and values as a Prometheus label set, e.g. {result="success"}
+func formatLabels(names, values []string) string {
+	if len(names) == 0 {
+		return ""
+	}
+
+	pairs := make([]string, len(names))
+	for i, name := range names {
+		value := ""
+		if i < len(values) {
+			value = values[i]
+		}
+		pairs[i] = fmt.Sprintf("%s=%q", name, value)
+	}
+
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+// formatValue formats a sample value, using integer notation where possible
+func formatValue(v float64) string {
+	if v == math.Trunc(v) && math.Abs(v) < 1e15 {
+		return fmt.Sprintf("%d", int64(v))
+	}
+	return fmt.Sprintf("%g", v)
+}
+
+// sortedKeys returns the keys of a map in sorted order for stable output
+func sortedKeys(m map[string]float64) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
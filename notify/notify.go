@@ -0,0 +1,62 @@
+// Package notify shows native desktop notifications on macOS, Linux and Windows, using the
+// notification tool each platform ships with
+package notify
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// Timeout limits how long showing a notification may take
+const Timeout = 10 * time.Second
+
+// appleScript shows a notification with osascript; title and message are passed as arguments, so
+// they need no quoting
+var appleScript = []string{
+	"-e", "on run argv",
+	"-e", "display notification (item 2 of argv) with title (item 1 of argv)",
+	"-e", "end run",
+}
+
+// powerShellScript shows a toast notification on Windows; title and message are read from the
+// environment, so they need no quoting
+const powerShellScript = `[Windows.UI.Notifications.ToastNotificationManager, Windows.UI.Notifications, ContentType = WindowsRuntime] > $null
+$template = [Windows.UI.Notifications.ToastNotificationManager]::GetTemplateContent([Windows.UI.Notifications.ToastTemplateType]::ToastText02)
+$text = $template.GetElementsByTagName('text')
+$text.Item(0).AppendChild($template.CreateTextNode($env:CFNRC_NOTIFY_TITLE)) > $null
+$text.Item(1).AppendChild($template.CreateTextNode($env:CFNRC_NOTIFY_MESSAGE)) > $null
+[Windows.UI.Notifications.ToastNotificationManager]::CreateToastNotifier('cfn-analyzer').Show([Windows.UI.Notifications.ToastNotification]::new($template))`
+
+// Desktop shows a desktop notification: with osascript on macOS, notify-send on Linux and
+// PowerShell on Windows
+func Desktop(ctx context.Context, title, message string) error {
+	ctx, cancel := context.WithTimeout(ctx, Timeout)
+	defer cancel()
+
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.CommandContext(ctx, "osascript", append(appleScript, title, message)...)
+	case "windows":
+		cmd = exec.CommandContext(ctx, "powershell", "-NoProfile", "-NonInteractive", "-Command", powerShellScript)
+		cmd.Env = append(os.Environ(), "CFNRC_NOTIFY_TITLE="+title, "CFNRC_NOTIFY_MESSAGE="+message)
+	case "linux", "freebsd", "openbsd", "netbsd":
+		cmd = exec.CommandContext(ctx, "notify-send", "--app-name=cfn-analyzer", title, message)
+	default:
+		return fmt.Errorf("desktop notifications are not supported on %s", runtime.GOOS)
+	}
+
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		if details := strings.TrimSpace(string(output)); details != "" {
+			return fmt.Errorf("failed to show desktop notification with %s: %w: %s", cmd.Path, err, details)
+		}
+		return fmt.Errorf("failed to show desktop notification with %s: %w", cmd.Path, err)
+	}
+	return nil
+}
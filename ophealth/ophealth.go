@@ -0,0 +1,121 @@
+// Package ophealth summarizes how a stack operation went, from its stack events, as a score that
+// can be compared across deployments
+package ophealth
+
+import (
+	"strings"
+	"time"
+
+	"cfn-root-cause/analyzer"
+	"cfn-root-cause/cfnclient"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation/types"
+)
+
+// Penalties subtracted from the score of 100, each kind capped at its maximum
+const (
+	failurePenalty    = 25
+	maxFailurePenalty = 60
+	retryPenalty      = 5
+	maxRetryPenalty   = 10
+	rollbackPenalty   = 30
+)
+
+// Summarize summarizes the latest operation in the stack events, newest first as returned by
+// DescribeStackEvents. All events are summarized if none of them started an operation, as for
+// truncated events. Retries and durations are only taken from the events before a rollback started,
+// since rolling back repeats the actions of the resources. It returns nil without events.
+func Summarize(events []types.StackEvent) *analyzer.OperationHealth {
+	if len(events) == 0 {
+		return nil
+	}
+
+	health := &analyzer.OperationHealth{}
+	if operations := cfnclient.SplitOperations(events); len(operations) > 0 {
+		health.Operation = operations[0].Token
+		events = operations[0].Events
+	}
+	health.Events = len(events)
+
+	// Terminal actions of each resource, and when each resource was first and last seen
+	finished := make(map[string]map[string]bool)
+	first := make(map[string]time.Time)
+	last := make(map[string]time.Time)
+	var order []string
+
+	for i := len(events) - 1; i >= 0; i-- {
+		event := events[i]
+		status := string(event.ResourceStatus)
+		logicalId := aws.ToString(event.LogicalResourceId)
+
+		if isStackEvent(event) {
+			if strings.Contains(status, "ROLLBACK") {
+				health.Rollback = true
+			}
+			continue
+		}
+		if strings.HasSuffix(status, "_FAILED") {
+			health.Failures++
+		}
+		if health.Rollback {
+			continue
+		}
+
+		action, phase := splitStatus(status)
+		if phase == "IN_PROGRESS" && finished[logicalId][action] {
+			health.Retries++
+			delete(finished[logicalId], action)
+		}
+		if phase == "COMPLETE" || phase == "FAILED" {
+			if finished[logicalId] == nil {
+				finished[logicalId] = make(map[string]bool)
+			}
+			finished[logicalId][action] = true
+		}
+
+		if event.Timestamp == nil {
+			continue
+		}
+		if _, seen := first[logicalId]; !seen {
+			first[logicalId] = *event.Timestamp
+			order = append(order, logicalId)
+		}
+		last[logicalId] = *event.Timestamp
+	}
+
+	for _, logicalId := range order {
+		if duration := last[logicalId].Sub(first[logicalId]); duration > health.LongestDuration {
+			health.LongestResource = logicalId
+			health.LongestDuration = duration
+		}
+	}
+
+	health.Score = score(health)
+	return health
+}
+
+// score rates the operation from 0 to 100
+func score(health *analyzer.OperationHealth) int {
+	score := 100 - min(health.Failures*failurePenalty, maxFailurePenalty) - min(health.Retries*retryPenalty, maxRetryPenalty)
+	if health.Rollback {
+		score -= rollbackPenalty
+	}
+	return max(score, 0)
+}
+
+// isStackEvent reports whether the event is about the stack itself rather than one of its resources
+func isStackEvent(event types.StackEvent) bool {
+	return aws.ToString(event.ResourceType) == "AWS::CloudFormation::Stack" &&
+		aws.ToString(event.PhysicalResourceId) == aws.ToString(event.StackId)
+}
+
+// splitStatus splits a resource status such as CREATE_IN_PROGRESS into its action and phase
+func splitStatus(status string) (action, phase string) {
+	for _, suffix := range []string{"IN_PROGRESS", "COMPLETE", "FAILED"} {
+		if action, found := strings.CutSuffix(status, "_"+suffix); found {
+			return action, suffix
+		}
+	}
+	return status, ""
+}
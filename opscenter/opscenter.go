@@ -0,0 +1,302 @@
+// Package opscenter records failed deployments as AWS Systems Manager OpsCenter OpsItems, so they
+// land in the operational workflow of the account
+package opscenter
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"cfn-root-cause/analyzer"
+	"cfn-root-cause/awserrors"
+	"cfn-root-cause/classify"
+	"cfn-root-cause/explain"
+	"cfn-root-cause/rootcause"
+	"cfn-root-cause/settings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	"github.com/aws/aws-sdk-go-v2/service/ssm/types"
+)
+
+// Source is the source of the OpsItems created by the analyzer
+const Source = "cfnrc"
+
+// Defaults of the OpsItems when the config file does not set them
+const (
+	DefaultSeverity = "2"
+	DefaultCategory = "Availability"
+)
+
+// Limits of the OpsItem fields; the operational data of an OpsItem is limited to 20 KB in total
+const (
+	maxTitleLength       = 1024
+	maxDescriptionLength = 2048
+	maxRelatedResources  = 25
+	maxErrorsDataLength  = 12000
+)
+
+// categories are the OpsItem categories OpsCenter accepts
+var categories = []string{"Availability", "Cost", "Performance", "Recovery", "Security"}
+
+// arnPattern finds the ARNs mentioned in error messages
+var arnPattern = regexp.MustCompile(`arn:aws[a-z-]*:[a-z0-9-]+:[a-z0-9-]*:\d*:[^\s"',;\])]+`)
+
+// Client creates OpsItems for failed stacks
+type Client struct {
+	ssm *ssm.Client
+	cfg settings.OpsCenterConfig
+}
+
+// New validates the OpsCenter configuration and creates a client. Severity and category default
+// to DefaultSeverity and DefaultCategory.
+func New(cfg settings.OpsCenterConfig, awsCfg aws.Config) (*Client, error) {
+	if cfg.Severity == "" {
+		cfg.Severity = DefaultSeverity
+	}
+	if cfg.Category == "" {
+		cfg.Category = DefaultCategory
+	}
+	if len(cfg.Severity) != 1 || cfg.Severity < "1" || cfg.Severity > "4" {
+		return nil, fmt.Errorf("invalid opsCenter.severity '%s' in the config file: must be 1 (critical) to 4 (low)", cfg.Severity)
+	}
+	if !slices.Contains(categories, cfg.Category) {
+		return nil, fmt.Errorf("invalid opsCenter.category '%s' in the config file: must be one of %s",
+			cfg.Category, strings.Join(categories, ", "))
+	}
+	for _, pattern := range cfg.Stacks {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid opsCenter.stacks pattern '%s' in the config file: %w", pattern, err)
+		}
+	}
+
+	return &Client{ssm: ssm.NewFromConfig(awsCfg), cfg: cfg}, nil
+}
+
+// Applies reports whether OpsItems are created for the stack: it failed and is one of the
+// configured production stacks
+func (c *Client) Applies(analysis *analyzer.StackAnalysis) bool {
+	if len(analysis.Errors) == 0 {
+		return false
+	}
+	if len(c.cfg.Stacks) == 0 {
+		return true
+	}
+	for _, pattern := range c.cfg.Stacks {
+		if ok, _ := path.Match(pattern, analysis.StackName); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// OpsItem is the OpsItem of a failed stack, built before it is created
+type OpsItem struct {
+	StackName string
+	Input     *ssm.CreateOpsItemInput
+}
+
+// Prepare builds the OpsItem of a failed stack, or returns nil if no OpsItem is created for the
+// stack. OpsItems stay in the account, so they are built from the analysis before it is redacted.
+func (c *Client) Prepare(analysis *analyzer.StackAnalysis) *OpsItem {
+	if !c.Applies(analysis) {
+		return nil
+	}
+	return &OpsItem{StackName: analysis.StackName, Input: NewOpsItem(analysis, c.cfg)}
+}
+
+// CreateOpsItem creates a prepared OpsItem and returns its ID. OpsItems are deduplicated by stack
+// and failure, so repeated analyses of the same failure return the ID of the existing OpsItem with
+// created set to false.
+func (c *Client) CreateOpsItem(ctx context.Context, item *OpsItem) (id string, created bool, err error) {
+	output, err := c.ssm.CreateOpsItem(ctx, item.Input)
+	if err != nil {
+		var exists *types.OpsItemAlreadyExistsException
+		if errors.As(err, &exists) && exists.OpsItemId != nil {
+			return aws.ToString(exists.OpsItemId), false, nil
+		}
+		return "", false, fmt.Errorf("failed to create OpsItem for stack '%s': %w", item.StackName,
+			awserrors.ParseAWSError(err, "Systems Manager"))
+	}
+	return aws.ToString(output.OpsItemId), true, nil
+}
+
+// NewOpsItem builds the OpsItem of a failed stack: the most likely root cause as title and
+// description, the stack and the ARNs mentioned by the errors as related resources, and the errors
+// as operational data
+func NewOpsItem(analysis *analyzer.StackAnalysis, cfg settings.OpsCenterConfig) *ssm.CreateOpsItemInput {
+	title := fmt.Sprintf("CloudFormation stack %s failed", analysis.StackName)
+	description := fmt.Sprintf("%d error(s) in stack %s.", len(analysis.Errors), analysis.StackName)
+	rootCauseId := ""
+	if candidate := rootcause.MostLikely(analysis); candidate != nil {
+		rootCauseId = candidate.Error.StackError.LogicalResourceId
+		cause := fmt.Sprintf("%s %s", rootCauseId, candidate.Error.StackError.ResourceStatus)
+		if candidate.Finding != nil {
+			cause = fmt.Sprintf("%s (%s)", candidate.Finding.Title, rootCauseId)
+		}
+		title += ": " + cause
+		description = explain.Error(candidate.Error, candidate.Finding) + " " + description
+	}
+
+	operationalData := map[string]types.OpsItemDataValue{
+		"/aws/dedup":      searchableData(dedup(analysis)),
+		"cfnrc:stackName": searchableData(analysis.StackName),
+		"cfnrc:errors":    stringData(errorsData(analysis)),
+	}
+	if resources := relatedResources(analysis); resources != "" {
+		operationalData["/aws/resources"] = searchableData(resources)
+	}
+	if rootCauseId != "" {
+		operationalData["cfnrc:rootCause"] = searchableData(rootCauseId)
+	}
+
+	input := &ssm.CreateOpsItemInput{
+		Source:          aws.String(Source),
+		Title:           aws.String(truncate(title, maxTitleLength)),
+		Description:     aws.String(truncate(description, maxDescriptionLength)),
+		Severity:        aws.String(cfg.Severity),
+		Category:        aws.String(cfg.Category),
+		OperationalData: operationalData,
+	}
+	for _, topic := range cfg.NotificationTopics {
+		input.Notifications = append(input.Notifications, types.OpsItemNotification{Arn: aws.String(topic)})
+	}
+	return input
+}
+
+// dedup returns the deduplication string of a failure: the stack and the time of its first error
+func dedup(analysis *analyzer.StackAnalysis) string {
+	first := analysis.Errors[0].StackError.Timestamp
+	for _, err := range analysis.Errors {
+		if err.StackError.Timestamp.Before(first) {
+			first = err.StackError.Timestamp
+		}
+	}
+
+	stack := analysis.StackId
+	if stack == "" {
+		stack = analysis.StackName
+	}
+	dedupString := fmt.Sprintf("%s:%s:%s", Source, stack, first.UTC().Format(time.RFC3339))
+	data, _ := json.Marshal(map[string]string{"dedupString": dedupString})
+	return string(data)
+}
+
+// relatedResources returns the related resources of the OpsItem as JSON: the stack and the ARNs
+// the error messages mention
+func relatedResources(analysis *analyzer.StackAnalysis) string {
+	arns := []string{}
+	seen := make(map[string]bool)
+	add := func(arn string) {
+		if arn != "" && !seen[arn] && len(arns) < maxRelatedResources {
+			seen[arn] = true
+			arns = append(arns, arn)
+		}
+	}
+
+	add(analysis.StackId)
+	for _, err := range analysis.Errors {
+		for _, text := range []string{err.StackError.ResourceStatusReason, err.DetailedMessage} {
+			for _, arn := range arnPattern.FindAllString(text, -1) {
+				add(strings.TrimRight(arn, "."))
+			}
+		}
+	}
+	if len(arns) == 0 {
+		return ""
+	}
+
+	resources := make([]map[string]string, 0, len(arns))
+	for _, arn := range arns {
+		resources = append(resources, map[string]string{"arn": arn})
+	}
+	data, _ := json.Marshal(resources)
+	return string(data)
+}
+
+// opsItemError is an error of the stack in the operational data
+type opsItemError struct {
+	Timestamp            time.Time `json:"timestamp"`
+	LogicalResourceId    string    `json:"logicalResourceId"`
+	ResourceType         string    `json:"resourceType"`
+	ResourceStatus       string    `json:"resourceStatus"`
+	ResourceStatusReason string    `json:"resourceStatusReason"`
+	Category             string    `json:"category"`
+	DetailedMessage      string    `json:"detailedMessage,omitempty"`
+	CloudTrailEventName  string    `json:"cloudTrailEventName,omitempty"`
+	CloudTrailErrorCode  string    `json:"cloudTrailErrorCode,omitempty"`
+}
+
+// errorsData returns the errors of the stack as JSON; the last errors are left out when the
+// operational data would exceed its size limit, and the messages of a single error are shortened
+func errorsData(analysis *analyzer.StackAnalysis) string {
+	items := make([]opsItemError, 0, len(analysis.Errors))
+	for _, err := range analysis.Errors {
+		item := opsItemError{
+			Timestamp:            err.StackError.Timestamp,
+			LogicalResourceId:    err.StackError.LogicalResourceId,
+			ResourceType:         err.StackError.ResourceType,
+			ResourceStatus:       err.StackError.ResourceStatus,
+			ResourceStatusReason: err.StackError.ResourceStatusReason,
+			Category:             classify.Category(err),
+			DetailedMessage:      err.DetailedMessage,
+		}
+		if event := err.CloudTrailEvent; event != nil {
+			item.CloudTrailEventName = event.EventName
+			item.CloudTrailErrorCode = event.ErrorCode
+		}
+		items = append(items, item)
+	}
+
+	for {
+		data, _ := json.Marshal(items)
+		if len(data) <= maxErrorsDataLength {
+			return string(data)
+		}
+		if len(items) > 1 {
+			items = items[:len(items)-1]
+			continue
+		}
+
+		// Shorten the longer message of the remaining error by the excess and marshal it again,
+		// since cutting the JSON would leave it invalid
+		item := &items[0]
+		message := &item.DetailedMessage
+		if len(item.ResourceStatusReason) > len(*message) {
+			message = &item.ResourceStatusReason
+		}
+		if len(*message) <= len("...") {
+			return "[]"
+		}
+		*message = truncate(*message, max(len(*message)-(len(data)-maxErrorsDataLength), len("...")))
+	}
+}
+
+// stringData returns an operational data value that is not searchable
+func stringData(value string) types.OpsItemDataValue {
+	return types.OpsItemDataValue{Type: types.OpsItemDataTypeString, Value: aws.String(value)}
+}
+
+// searchableData returns an operational data value OpsCenter can search for
+func searchableData(value string) types.OpsItemDataValue {
+	return types.OpsItemDataValue{Type: types.OpsItemDataTypeSearchableString, Value: aws.String(value)}
+}
+
+// truncate shortens text to at most limit bytes, marking the cut with an ellipsis
+func truncate(text string, limit int) string {
+	if len(text) <= limit {
+		return text
+	}
+	cut := limit - len("...")
+	for cut > 0 && !utf8.RuneStart(text[cut]) {
+		cut--
+	}
+	return text[:cut] + "..."
+}
@@ -0,0 +1,110 @@
+package patterns
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"cfn-root-cause/analyzer"
+
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation/types"
+)
+
+// PatternMissingCapabilities identifies findings about deployments that did not acknowledge required capabilities
+const PatternMissingCapabilities = "missing-capabilities"
+
+// capabilitiesPattern extracts the capabilities listed in "Requires capabilities : [CAPABILITY_IAM]"
+var capabilitiesPattern = regexp.MustCompile(`(?i)requires capabilities\s*:\s*\[([^\]]*)\]`)
+
+// capabilityReasons explains why a template requires a capability
+var capabilityReasons = map[string]string{
+	string(types.CapabilityCapabilityIam):        "CAPABILITY_IAM: the template creates or changes IAM resources",
+	string(types.CapabilityCapabilityNamedIam):   "CAPABILITY_NAMED_IAM: the template creates IAM resources with custom names",
+	string(types.CapabilityCapabilityAutoExpand): "CAPABILITY_AUTO_EXPAND: the template uses macros or transforms such as AWS::Serverless",
+}
+
+// DetectMissingCapabilities recognizes deployments rejected with "Requires capabilities : [...]"
+// and builds the deploy command acknowledging them. Besides failed resources such as nested
+// stacks, stack-level events are checked, since the stack itself reports the rejection.
+func DetectMissingCapabilities(events []types.StackEvent, errors []analyzer.CorrelatedError) []analyzer.Finding {
+	capabilities := make(map[string]bool)
+	var evidence []string
+	var logicalId string
+
+	record := func(id, resourceType, status, reason string) {
+		match := capabilitiesPattern.FindStringSubmatch(reason)
+		if match == nil {
+			return
+		}
+		for _, capability := range strings.FieldsFunc(match[1], func(r rune) bool { return r == ',' || r == ' ' }) {
+			capabilities[strings.ToUpper(capability)] = true
+		}
+		if logicalId == "" {
+			logicalId = id
+		}
+		evidence = append(evidence, fmt.Sprintf("%s (%s) %s: %s", id, resourceType, status, reason))
+	}
+
+	for _, err := range errors {
+		record(err.StackError.LogicalResourceId, err.StackError.ResourceType, err.StackError.ResourceStatus,
+			err.StackError.ResourceStatusReason)
+	}
+	// Events are ordered newest first; only the stack-level events of the latest operation count
+	for _, event := range events {
+		if len(evidence) > 0 {
+			break
+		}
+		if safeString(event.PhysicalResourceId) != safeString(event.StackId) {
+			continue
+		}
+		record(safeString(event.LogicalResourceId), safeString(event.ResourceType), string(event.ResourceStatus),
+			safeString(event.ResourceStatusReason))
+		if strings.HasSuffix(string(event.ResourceStatus), "_IN_PROGRESS") && safeString(event.ResourceStatusReason) == "User Initiated" {
+			break
+		}
+	}
+	if len(capabilities) == 0 {
+		return nil
+	}
+
+	// CAPABILITY_NAMED_IAM covers the IAM resources acknowledged by CAPABILITY_IAM
+	if capabilities[string(types.CapabilityCapabilityNamedIam)] {
+		delete(capabilities, string(types.CapabilityCapabilityIam))
+	}
+	required := make([]string, 0, len(capabilities))
+	for capability := range capabilities {
+		required = append(required, capability)
+	}
+	sort.Strings(required)
+
+	var reasons []string
+	for _, capability := range required {
+		if reason, ok := capabilityReasons[capability]; ok {
+			reasons = append(reasons, reason)
+		}
+	}
+	explanation := fmt.Sprintf("CloudFormation rejected the deployment because the template requires %s, which was "+
+		"not acknowledged with --capabilities.", strings.Join(required, " and "))
+	if len(reasons) > 0 {
+		explanation += " " + strings.Join(reasons, "; ") + "."
+	}
+
+	stackName := "<stack-name>"
+	if len(events) > 0 && events[0].StackName != nil {
+		stackName = *events[0].StackName
+	}
+	capabilitiesFlag := strings.Join(required, " ")
+
+	return []analyzer.Finding{{
+		Pattern:           PatternMissingCapabilities,
+		LogicalResourceId: logicalId,
+		Title:             "Missing capabilities",
+		Explanation:       explanation,
+		Evidence:          evidence,
+		Suggestion: fmt.Sprintf("Deploy again acknowledging the capabilities: aws cloudformation deploy --stack-name %s "+
+			"--template-file <template-file> --capabilities %s (sam deploy --capabilities %s; in CodePipeline set "+
+			"Capabilities to %s in the CloudFormation action configuration)",
+			stackName, capabilitiesFlag, capabilitiesFlag, strings.Join(required, ",")),
+	}}
+}
@@ -0,0 +1,307 @@
+package patterns
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"cfn-root-cause/analyzer"
+)
+
+// PatternInstanceCapacity identifies findings about EC2 launches failing for lack of capacity or
+// an instance type unavailable in the Availability Zone
+const PatternInstanceCapacity = "instance-capacity"
+
+// Kinds of capacity failures
+const (
+	// CapacityInsufficient is a launch EC2 had no capacity of the instance type for
+	CapacityInsufficient = "insufficient-capacity"
+
+	// CapacityUnsupported is a launch of an instance type the Availability Zone does not offer
+	CapacityUnsupported = "unsupported-instance-type"
+)
+
+// capacityResourceTypes are the resource types that launch EC2 instances
+var capacityResourceTypes = map[string]bool{
+	"AWS::EC2::Instance":                 true,
+	"AWS::EC2::EC2Fleet":                 true,
+	"AWS::EC2::SpotFleet":                true,
+	"AWS::AutoScaling::AutoScalingGroup": true,
+	"AWS::EKS::Nodegroup":                true,
+}
+
+// insufficientCapacityPattern recognizes capacity shortages, e.g. "We currently do not have
+// sufficient m5.large capacity in the Availability Zone you requested (us-east-1a)"; the groups
+// are the instance type and the Availability Zone
+var insufficientCapacityPattern = regexp.MustCompile(`(?i)do not have sufficient (\S+) capacity in the Availability Zone you requested \(([a-z0-9-]+)\)`)
+
+// insufficientCapacityCodes are the EC2 error codes of capacity shortages
+var insufficientCapacityCodes = regexp.MustCompile(`Insufficient(?:Instance|Host|ReservedInstance)?Capacity`)
+
+// unsupportedTypePattern recognizes instance types the Availability Zone does not offer, e.g.
+// "Your requested instance type (m5.large) is not supported in your requested Availability Zone
+// (us-east-1e)"; the groups are the instance type and the Availability Zone
+var unsupportedTypePattern = regexp.MustCompile(`(?i)requested instance type \(([^)]+)\) is not supported in your requested Availability Zone \(([a-z0-9-]+)\)`)
+
+// alternativeZonesPattern extracts the list of Availability Zones EC2 recommends, which follows
+// "choosing" in the error message
+var alternativeZonesPattern = regexp.MustCompile(`(?i)choosing ([a-z0-9-]+(?:,? (?:or |and )?[a-z0-9-]+)*)`)
+
+// availabilityZonePattern recognizes Availability Zone names, e.g. us-east-1a or us-gov-west-1b
+var availabilityZonePattern = regexp.MustCompile(`^[a-z]{2}(?:-[a-z]+)+-\d+[a-z]$`)
+
+// instanceFamilies are groups of instance families with similar characteristics, by architecture,
+// the preferred alternatives first
+var instanceFamilies = []struct {
+	x86 []string
+	arm []string
+}{
+	{[]string{"m6i", "m6a", "m7i", "m7a", "m5", "m5a", "m5n"}, []string{"m6g", "m7g", "m8g"}},
+	{[]string{"c6i", "c6a", "c7i", "c7a", "c5", "c5a", "c5n"}, []string{"c6g", "c7g", "c8g"}},
+	{[]string{"r6i", "r6a", "r7i", "r7a", "r5", "r5a", "r5n"}, []string{"r6g", "r7g", "r8g"}},
+	{[]string{"t3", "t3a", "t2"}, []string{"t4g"}},
+	{[]string{"g5", "g6", "g4dn"}, []string{"g5g"}},
+	{[]string{"i4i", "i3en", "i3"}, []string{"i4g"}},
+}
+
+// maxAlternativeTypes limits the number of instance types suggested instead of the failed one
+const maxAlternativeTypes = 3
+
+// CapacityFailure is a failed EC2 launch for lack of capacity or an unsupported instance type
+type CapacityFailure struct {
+	Error analyzer.CorrelatedError
+	Kind  string
+
+	// InstanceType and AvailabilityZone are the requested instance type and zone, "" if neither
+	// the error nor the launch call names them
+	InstanceType     string
+	AvailabilityZone string
+
+	// SubnetId is the subnet of the launch, if the launch call names it
+	SubnetId string
+
+	// AlternativeZones are the Availability Zones EC2 recommends instead
+	AlternativeZones []string
+
+	// LaunchCall is the failed RunInstances call, nil if it was not found in CloudTrail
+	LaunchCall *analyzer.CloudTrailEvent
+}
+
+// CapacityFailures returns the failures of instance-launching resources caused by a lack of EC2
+// capacity or an instance type the Availability Zone does not offer, with the instance type and
+// zones the error messages name
+func CapacityFailures(errors []analyzer.CorrelatedError) []CapacityFailure {
+	var failures []CapacityFailure
+	for _, err := range errors {
+		if !capacityResourceTypes[err.StackError.ResourceType] {
+			continue
+		}
+		if failure, ok := capacityFailure(err); ok {
+			failures = append(failures, failure)
+		}
+	}
+	return failures
+}
+
+// capacityFailure recognizes a capacity failure in the failure reason, the detailed message and
+// the correlated CloudTrail event
+func capacityFailure(err analyzer.CorrelatedError) (CapacityFailure, bool) {
+	texts := []string{err.StackError.ResourceStatusReason, err.DetailedMessage}
+	errorCode := ""
+	if err.CloudTrailEvent != nil {
+		texts = append(texts, err.CloudTrailEvent.ErrorMessage)
+		errorCode = err.CloudTrailEvent.ErrorCode
+	}
+
+	failure := CapacityFailure{Error: err}
+	for _, text := range texts {
+		if match := insufficientCapacityPattern.FindStringSubmatch(text); match != nil {
+			failure.Kind = CapacityInsufficient
+			failure.InstanceType, failure.AvailabilityZone = match[1], match[2]
+		} else if match := unsupportedTypePattern.FindStringSubmatch(text); match != nil {
+			failure.Kind = CapacityUnsupported
+			failure.InstanceType, failure.AvailabilityZone = match[1], match[2]
+		} else if insufficientCapacityCodes.MatchString(text) {
+			failure.Kind = CapacityInsufficient
+		} else {
+			continue
+		}
+		failure.AlternativeZones = alternativeZones(text, failure.AvailabilityZone)
+		break
+	}
+	if failure.Kind == "" && insufficientCapacityCodes.MatchString(errorCode) {
+		failure.Kind = CapacityInsufficient
+	}
+	if failure.Kind == "" {
+		return CapacityFailure{}, false
+	}
+
+	if err.CloudTrailEvent != nil && err.CloudTrailEvent.EventName == "RunInstances" {
+		failure.UseLaunchCall(*err.CloudTrailEvent)
+	}
+	return failure, true
+}
+
+// alternativeZones returns the Availability Zones an error message recommends, except the failed one
+func alternativeZones(text, failedZone string) []string {
+	match := alternativeZonesPattern.FindStringSubmatch(text)
+	if match == nil {
+		return nil
+	}
+
+	var zones []string
+	for _, word := range strings.FieldsFunc(match[1], func(r rune) bool { return r == ',' || r == ' ' }) {
+		if availabilityZonePattern.MatchString(word) && word != failedZone {
+			zones = append(zones, word)
+		}
+	}
+	return zones
+}
+
+// IsCapacityError reports whether a CloudTrail error code reports a capacity shortage or an
+// unsupported instance type
+func IsCapacityError(errorCode string) bool {
+	return errorCode == "Unsupported" || insufficientCapacityCodes.MatchString(errorCode)
+}
+
+// UseLaunchCall records the failed RunInstances call and fills in the instance type, the
+// Availability Zone and the subnet the error messages do not name from its request parameters
+func (f *CapacityFailure) UseLaunchCall(event analyzer.CloudTrailEvent) {
+	f.LaunchCall = &event
+	parameters := event.RequestParameters
+	if f.InstanceType == "" {
+		f.InstanceType = stringParameter(parameters, "instanceType")
+	}
+	if f.AvailabilityZone == "" {
+		if placement, ok := parameters["placement"].(map[string]interface{}); ok {
+			f.AvailabilityZone = stringParameter(placement, "availabilityZone")
+		}
+	}
+	if f.SubnetId == "" {
+		f.SubnetId = stringParameter(parameters, "subnetId")
+	}
+}
+
+// stringParameter returns a string request parameter, "" if it is missing or not a string
+func stringParameter(parameters map[string]interface{}, name string) string {
+	value, _ := parameters[name].(string)
+	return value
+}
+
+// ClosestLaunchCall returns the failed launch call that most closely precedes the failure, nil if
+// none does. Calls of other instance types are skipped if the instance type is known.
+func ClosestLaunchCall(failure time.Time, instanceType string, events []analyzer.CloudTrailEvent) *analyzer.CloudTrailEvent {
+	var closest *analyzer.CloudTrailEvent
+	for i, event := range events {
+		if !IsCapacityError(event.ErrorCode) || event.EventTime.After(failure) {
+			continue
+		}
+		if instanceType != "" && stringParameter(event.RequestParameters, "instanceType") != instanceType {
+			continue
+		}
+		if closest == nil || event.EventTime.After(closest.EventTime) {
+			closest = &events[i]
+		}
+	}
+	return closest
+}
+
+// AlternativeInstanceTypes returns instance types of the same size and architecture in similar
+// families, e.g. m6a.large and m7i.large for m6i.large; nil for families without known alternatives
+func AlternativeInstanceTypes(instanceType string) []string {
+	family, size, ok := strings.Cut(instanceType, ".")
+	if !ok {
+		return nil
+	}
+
+	for _, group := range instanceFamilies {
+		for _, families := range [][]string{group.x86, group.arm} {
+			if !slices.Contains(families, family) {
+				continue
+			}
+			var alternatives []string
+			for _, candidate := range families {
+				if candidate != family && len(alternatives) < maxAlternativeTypes {
+					alternatives = append(alternatives, candidate+"."+size)
+				}
+			}
+			return alternatives
+		}
+	}
+	return nil
+}
+
+// DescribeCapacityFailure explains a launch that failed for lack of capacity or an unsupported
+// instance type, with alternative Availability Zones and instance types
+func DescribeCapacityFailure(failure CapacityFailure) analyzer.Finding {
+	stackError := failure.Error.StackError
+	evidence := []string{fmt.Sprintf("%s (%s) %s: %s", stackError.LogicalResourceId, stackError.ResourceType,
+		stackError.ResourceStatus, stackError.ResourceStatusReason)}
+	if call := failure.LaunchCall; call != nil {
+		evidence = append(evidence, fmt.Sprintf("CloudTrail %s at %s failed with %s: %s", call.EventName,
+			formatTimestamp(call.EventTime), call.ErrorCode, call.ErrorMessage))
+	}
+	var requested []string
+	if failure.InstanceType != "" {
+		requested = append(requested, "instance type "+failure.InstanceType)
+	}
+	if failure.AvailabilityZone != "" {
+		requested = append(requested, "Availability Zone "+failure.AvailabilityZone)
+	}
+	if failure.SubnetId != "" {
+		requested = append(requested, "subnet "+failure.SubnetId)
+	}
+	if len(requested) > 0 {
+		evidence = append(evidence, "Requested: "+strings.Join(requested, ", "))
+	}
+
+	instanceType := failure.InstanceType
+	if instanceType == "" {
+		instanceType = "the instance type"
+	}
+	zone := "the Availability Zone"
+	if failure.AvailabilityZone != "" {
+		zone = failure.AvailabilityZone
+	}
+
+	var title, explanation string
+	if failure.Kind == CapacityUnsupported {
+		title = "Instance type not offered in the Availability Zone"
+		explanation = fmt.Sprintf("%s is not offered in %s, so EC2 refused to launch the instance of %s.",
+			instanceType, zone, stackError.LogicalResourceId)
+	} else {
+		title = "Insufficient EC2 capacity"
+		explanation = fmt.Sprintf("EC2 had no capacity for %s in %s when %s launched its instance. Capacity shortages "+
+			"are specific to an instance type and Availability Zone, and usually temporary.",
+			instanceType, zone, stackError.LogicalResourceId)
+	}
+
+	var suggestions []string
+	if len(failure.AlternativeZones) > 0 {
+		suggestions = append(suggestions, fmt.Sprintf("Launch in another Availability Zone, such as %s, by choosing a "+
+			"subnet there or not specifying the zone.", strings.Join(failure.AlternativeZones, ", ")))
+	} else {
+		suggestions = append(suggestions, "Launch in another Availability Zone by choosing a subnet there or not specifying the zone.")
+	}
+	if alternatives := AlternativeInstanceTypes(failure.InstanceType); len(alternatives) > 0 {
+		suggestions = append(suggestions, fmt.Sprintf("Alternatively use a similar instance type, such as %s.",
+			strings.Join(alternatives, ", ")))
+	}
+	if stackError.ResourceType == "AWS::AutoScaling::AutoScalingGroup" {
+		suggestions = append(suggestions, "Auto Scaling groups are more resilient with subnets in several Availability "+
+			"Zones and a MixedInstancesPolicy listing several instance types.")
+	} else if failure.Kind == CapacityInsufficient {
+		suggestions = append(suggestions, "For capacity that must be available, reserve it with an On-Demand Capacity Reservation.")
+	}
+
+	return analyzer.Finding{
+		Pattern:           PatternInstanceCapacity,
+		LogicalResourceId: stackError.LogicalResourceId,
+		Title:             title,
+		Explanation:       explanation,
+		Evidence:          evidence,
+		Suggestion:        strings.Join(suggestions, " "),
+	}
+}
@@ -0,0 +1,150 @@
+package patterns
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"cfn-root-cause/analyzer"
+
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation/types"
+)
+
+// PatternCertificateValidation identifies findings about ACM certificates stuck in DNS validation
+const PatternCertificateValidation = "certificate-validation"
+
+// validationRecordPattern extracts the validation record CloudFormation reports while it waits for
+// a certificate, e.g. "Content of DNS Record is: {Name: _a1.example.com.,Type: CNAME,Value: _b2.acm-validations.aws.}"
+var validationRecordPattern = regexp.MustCompile(`Content of DNS Record is: \{Name: ([^,]+),\s*Type: ([^,]+),\s*Value: ([^}]+)\}`)
+
+// IsCertificateFailure reports whether a failed resource is an ACM certificate
+func IsCertificateFailure(stackErr analyzer.StackError) bool {
+	return stackErr.ResourceType == "AWS::CertificateManager::Certificate"
+}
+
+// ValidationRecords returns the DNS validation records CloudFormation reported for a certificate
+// in the stack events, one per record name
+func ValidationRecords(events []types.StackEvent, logicalId string) []analyzer.DNSValidation {
+	var validations []analyzer.DNSValidation
+	seen := make(map[string]bool)
+	for _, event := range events {
+		if safeString(event.LogicalResourceId) != logicalId {
+			continue
+		}
+		for _, match := range validationRecordPattern.FindAllStringSubmatch(safeString(event.ResourceStatusReason), -1) {
+			name := strings.TrimSpace(match[1])
+			if seen[strings.ToLower(name)] {
+				continue
+			}
+			seen[strings.ToLower(name)] = true
+			validations = append(validations, analyzer.DNSValidation{
+				RecordName:  name,
+				RecordType:  strings.TrimSpace(match[2]),
+				RecordValue: strings.TrimSpace(match[3]),
+			})
+		}
+	}
+	return validations
+}
+
+// hasRecordValue reports whether the record in the hosted zone has the expected value
+func hasRecordValue(validation analyzer.DNSValidation) bool {
+	for _, value := range validation.RecordValues {
+		if strings.EqualFold(strings.TrimSuffix(value, "."), strings.TrimSuffix(validation.RecordValue, ".")) {
+			return true
+		}
+	}
+	return false
+}
+
+// DescribeCertificateValidation explains a certificate that failed or timed out waiting for DNS
+// validation, naming the validation records that are missing or wrong in Route 53. zonesChecked is
+// false if Route 53 could not be read. There is no finding if all domains are validated.
+func DescribeCertificateValidation(stackErr analyzer.StackError, validations []analyzer.DNSValidation, zonesChecked bool) (analyzer.Finding, bool) {
+	evidence := []string{fmt.Sprintf("%s (%s) %s: %s", stackErr.LogicalResourceId, stackErr.ResourceType,
+		stackErr.ResourceStatus, stackErr.ResourceStatusReason)}
+
+	var missing, wrong, present, outside []string
+	pending := 0
+	for _, validation := range validations {
+		if validation.Status == "SUCCESS" {
+			continue
+		}
+		pending++
+
+		record := fmt.Sprintf("%s %s %s", validation.RecordName, validation.RecordType, validation.RecordValue)
+		domain := validation.DomainName
+		if domain == "" {
+			domain = strings.TrimPrefix(strings.TrimSuffix(validation.RecordName, "."), "_")
+			if _, rest, ok := strings.Cut(domain, "."); ok {
+				domain = rest
+			}
+		}
+		status := validation.Status
+		if status == "" {
+			status = "not validated"
+		}
+		line := fmt.Sprintf("Domain %s (%s) expects %s", domain, status, record)
+
+		switch {
+		case !zonesChecked:
+		case validation.HostedZone == "":
+			outside = append(outside, record)
+			line += "; no hosted zone of the account contains the record"
+		case len(validation.RecordValues) == 0:
+			missing = append(missing, fmt.Sprintf("%s in hosted zone %s", record, validation.HostedZone))
+			line += fmt.Sprintf("; missing in hosted zone %s", validation.HostedZone)
+		case !hasRecordValue(validation):
+			wrong = append(wrong, fmt.Sprintf("%s in hosted zone %s (currently %s)", record, validation.HostedZone,
+				strings.Join(validation.RecordValues, ", ")))
+			line += fmt.Sprintf("; hosted zone %s has %s", validation.HostedZone, strings.Join(validation.RecordValues, ", "))
+		default:
+			present = append(present, validation.HostedZone)
+			line += fmt.Sprintf("; present in hosted zone %s", validation.HostedZone)
+		}
+		evidence = append(evidence, line)
+	}
+	if pending == 0 {
+		return analyzer.Finding{}, false
+	}
+
+	var explanation string
+	var suggestions []string
+	switch {
+	case len(missing) > 0:
+		explanation = fmt.Sprintf("ACM could not validate the certificate of %s because the DNS validation record is "+
+			"missing: %s.", stackErr.LogicalResourceId, strings.Join(missing, "; "))
+		suggestions = append(suggestions, "Create the missing CNAME record(s), or add DomainValidationOptions with the "+
+			"HostedZoneId to the certificate, so CloudFormation creates them.")
+	case len(wrong) > 0:
+		explanation = fmt.Sprintf("ACM could not validate the certificate of %s because the DNS validation record has "+
+			"a different value: %s.", stackErr.LogicalResourceId, strings.Join(wrong, "; "))
+		suggestions = append(suggestions, "Update the record(s) to the value ACM expects; a value left over from an "+
+			"earlier certificate does not validate a new one.")
+	case len(outside) > 0:
+		explanation = fmt.Sprintf("ACM waited for the DNS validation record(s) of %s, but no public hosted zone of this "+
+			"account contains them: %s.", stackErr.LogicalResourceId, strings.Join(outside, "; "))
+		suggestions = append(suggestions, "Create the record(s) with the DNS provider or in the account hosting the "+
+			"domain; CloudFormation only creates them for hosted zones of this account named in DomainValidationOptions.")
+	case len(present) > 0:
+		explanation = fmt.Sprintf("The validation records of %s are present in Route 53, but ACM did not see them in "+
+			"time.", stackErr.LogicalResourceId)
+		suggestions = append(suggestions, fmt.Sprintf("Check that the domain is delegated to hosted zone %s: the NS "+
+			"records at the registrar or in the parent zone must match the name servers of the zone.", present[0]))
+	default:
+		explanation = fmt.Sprintf("ACM waited for DNS validation of the certificate of %s, which did not complete.",
+			stackErr.LogicalResourceId)
+		suggestions = append(suggestions, "Create the CNAME record(s) above in the DNS zone of the domain.")
+	}
+	suggestions = append(suggestions, "CloudFormation waits for the validation until the stack times out, so deploy "+
+		"again once the records resolve.")
+
+	return analyzer.Finding{
+		Pattern:           PatternCertificateValidation,
+		LogicalResourceId: stackErr.LogicalResourceId,
+		Title:             "Certificate DNS validation did not complete",
+		Explanation:       explanation,
+		Evidence:          evidence,
+		Suggestion:        strings.Join(suggestions, " "),
+	}, true
+}
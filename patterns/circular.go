@@ -0,0 +1,128 @@
+package patterns
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"cfn-root-cause/analyzer"
+	"cfn-root-cause/cfntemplate"
+)
+
+// PatternCircularDependency identifies findings about templates with circular resource dependencies
+const PatternCircularDependency = "circular-dependency"
+
+// circularDependencyPattern extracts the resources listed in "Circular dependency between resources: [A, B]"
+var circularDependencyPattern = regexp.MustCompile(`(?i)circular dependency between resources:\s*\[([^\]]*)\]`)
+
+// CircularDependency returns the resources a circular dependency failure lists
+func CircularDependency(reason string) ([]string, bool) {
+	match := circularDependencyPattern.FindStringSubmatch(reason)
+	if match == nil {
+		return nil, false
+	}
+
+	var resources []string
+	for _, resource := range strings.Split(match[1], ",") {
+		if resource = strings.TrimSpace(resource); resource != "" {
+			resources = append(resources, resource)
+		}
+	}
+	return resources, true
+}
+
+// FindCycle returns the shortest cycle of dependencies between the listed resources, or nil if
+// the dependencies contain none. CloudFormation also lists resources that merely depend on the
+// cycle, so not every listed resource is part of it.
+func FindCycle(resources []string, dependencies []cfntemplate.Dependency) []cfntemplate.Dependency {
+	listed := make(map[string]bool, len(resources))
+	for _, resource := range resources {
+		listed[resource] = true
+	}
+	edges := make(map[string][]cfntemplate.Dependency)
+	for _, dependency := range dependencies {
+		if listed[dependency.From] && listed[dependency.To] {
+			edges[dependency.From] = append(edges[dependency.From], dependency)
+		}
+	}
+
+	starts := append([]string{}, resources...)
+	sort.Strings(starts)
+
+	var shortest []cfntemplate.Dependency
+	for _, start := range starts {
+		if cycle := shortestPathBack(start, edges); cycle != nil && (shortest == nil || len(cycle) < len(shortest)) {
+			shortest = cycle
+		}
+	}
+	return shortest
+}
+
+// shortestPathBack searches breadth-first for the shortest dependency path from start back to itself
+func shortestPathBack(start string, edges map[string][]cfntemplate.Dependency) []cfntemplate.Dependency {
+	type path struct {
+		node  string
+		edges []cfntemplate.Dependency
+	}
+
+	visited := map[string]bool{}
+	queue := []path{{node: start}}
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+
+		for _, edge := range edges[current.node] {
+			next := append(append([]cfntemplate.Dependency{}, current.edges...), edge)
+			if edge.To == start {
+				return next
+			}
+			if !visited[edge.To] {
+				visited[edge.To] = true
+				queue = append(queue, path{node: edge.To, edges: next})
+			}
+		}
+	}
+	return nil
+}
+
+// DescribeCircularDependency explains a circular dependency failure with the cycle computed from
+// the template and the properties that create it. Without a cycle, only the listed resources are shown.
+func DescribeCircularDependency(stackErr analyzer.StackError, resources []string, cycle []cfntemplate.Dependency) analyzer.Finding {
+	evidence := []string{
+		fmt.Sprintf("%s (%s) %s at %s: %s", stackErr.LogicalResourceId, stackErr.ResourceType,
+			stackErr.ResourceStatus, formatTimestamp(stackErr.Timestamp), stackErr.ResourceStatusReason),
+	}
+
+	explanation := fmt.Sprintf("CloudFormation cannot order the creation of %s, because they depend on each other.",
+		strings.Join(resources, ", "))
+	suggestion := "Break the cycle by removing one of the references: drop an unnecessary DependsOn, build names " +
+		"with Fn::Sub from parameters instead of referencing the resource, or move the referencing property into a " +
+		"separate resource such as AWS::EC2::SecurityGroupIngress, AWS::IAM::Policy or AWS::Lambda::Permission."
+
+	if len(cycle) > 0 {
+		chain := []string{cycle[0].From}
+		for _, dependency := range cycle {
+			chain = append(chain, dependency.To)
+			evidence = append(evidence, dependency.String())
+		}
+		explanation += " Cycle: " + strings.Join(chain, " -> ") + "."
+
+		for _, dependency := range cycle {
+			if dependency.Via == "DependsOn" {
+				suggestion = fmt.Sprintf("Check whether 'DependsOn: %s' of %s is needed; explicit dependencies are a "+
+					"frequent cause of cycles. ", dependency.To, dependency.From) + suggestion
+				break
+			}
+		}
+	}
+
+	return analyzer.Finding{
+		Pattern:           PatternCircularDependency,
+		LogicalResourceId: stackErr.LogicalResourceId,
+		Title:             "Circular dependency",
+		Explanation:       explanation,
+		Evidence:          evidence,
+		Suggestion:        suggestion,
+	}
+}
@@ -0,0 +1,151 @@
+package patterns
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"cfn-root-cause/analyzer"
+
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation/types"
+)
+
+// PatternCrossStackExport identifies findings about failures caused by exports of another stack
+const PatternCrossStackExport = "cross-stack-export"
+
+// Kinds of cross-stack export failures
+const (
+	// ExportMissing is a Fn::ImportValue of an export no stack provides
+	ExportMissing = "missing"
+
+	// ExportInUse is a change or removal of an export that other stacks import
+	ExportInUse = "in-use"
+)
+
+// exportPatterns recognize failure reasons about exports; the first group is the export name
+var exportPatterns = []struct {
+	kind    string
+	pattern *regexp.Regexp
+}{
+	{ExportMissing, regexp.MustCompile(`(?i)no export named (\S+?)\.? found`)},
+	{ExportInUse, regexp.MustCompile(`(?i)export (\S+) cannot be (?:updated|modified) as it is in use by`)},
+	{ExportInUse, regexp.MustCompile(`(?i)cannot delete export (\S+) as it is in use by`)},
+}
+
+// ExportReference is a failure of a stack caused by an export
+type ExportReference struct {
+	Kind       string
+	ExportName string
+
+	// LogicalResourceId, ResourceType, Status and Reason describe the event reporting the failure
+	LogicalResourceId string
+	ResourceType      string
+	Status            string
+	Reason            string
+}
+
+// Export describes an export and the stacks using it, as currently deployed
+type Export struct {
+	Name  string
+	Value string
+
+	// ExportingStack is the name of the stack providing the export, "" if no stack exports it
+	ExportingStack string
+
+	// Importers are the stacks importing the export
+	Importers []string
+}
+
+// ExportReferences returns the failures of the latest operation caused by missing exports or by
+// changes of exports other stacks import, one per export. Besides failed resources, stack-level
+// events are checked, since CloudFormation rejects unresolvable imports for the stack as a whole.
+func ExportReferences(events []types.StackEvent, errors []analyzer.CorrelatedError) []ExportReference {
+	var references []ExportReference
+	seen := make(map[string]bool)
+
+	record := func(id, resourceType, status, reason string) {
+		for _, candidate := range exportPatterns {
+			match := candidate.pattern.FindStringSubmatch(reason)
+			if match == nil || seen[match[1]] {
+				continue
+			}
+			seen[match[1]] = true
+			references = append(references, ExportReference{
+				Kind:              candidate.kind,
+				ExportName:        match[1],
+				LogicalResourceId: id,
+				ResourceType:      resourceType,
+				Status:            status,
+				Reason:            reason,
+			})
+		}
+	}
+
+	for _, err := range errors {
+		record(err.StackError.LogicalResourceId, err.StackError.ResourceType, err.StackError.ResourceStatus,
+			err.StackError.ResourceStatusReason)
+	}
+	// Events are ordered newest first; only the stack-level events of the latest operation count
+	for _, event := range events {
+		if safeString(event.PhysicalResourceId) != safeString(event.StackId) {
+			continue
+		}
+		record(safeString(event.LogicalResourceId), safeString(event.ResourceType), string(event.ResourceStatus),
+			safeString(event.ResourceStatusReason))
+		if strings.HasSuffix(string(event.ResourceStatus), "_IN_PROGRESS") && safeString(event.ResourceStatusReason) == "User Initiated" {
+			break
+		}
+	}
+
+	return references
+}
+
+// DescribeCrossStackDependency explains a failure caused by an export, using the current state of
+// the export; export is nil if it could not be looked up
+func DescribeCrossStackDependency(stackName string, reference ExportReference, export *Export) analyzer.Finding {
+	evidence := []string{fmt.Sprintf("%s (%s) %s: %s", reference.LogicalResourceId, reference.ResourceType,
+		reference.Status, reference.Reason)}
+	if export != nil && export.ExportingStack != "" {
+		evidence = append(evidence, fmt.Sprintf("Export %s is currently provided by stack %s with value %s",
+			reference.ExportName, export.ExportingStack, export.Value))
+	}
+	if export != nil && len(export.Importers) > 0 {
+		evidence = append(evidence, fmt.Sprintf("Export %s is imported by: %s", reference.ExportName,
+			strings.Join(export.Importers, ", ")))
+	}
+
+	var explanation, suggestion string
+	switch {
+	case reference.Kind == ExportInUse:
+		importers := "other stacks"
+		if export != nil && len(export.Importers) > 0 {
+			importers = strings.Join(export.Importers, ", ")
+		}
+		explanation = fmt.Sprintf("%s tried to change or remove the export %s, which %s import(s). CloudFormation does "+
+			"not allow changing an export while another stack imports it.", stackName, reference.ExportName, importers)
+		suggestion = fmt.Sprintf("Replace Fn::ImportValue %s in %s with the literal value or a parameter and deploy them "+
+			"first; then change the export in %s and move the importing stacks to the new value.",
+			reference.ExportName, importers, stackName)
+	case export != nil && export.ExportingStack != "":
+		explanation = fmt.Sprintf("%s imports %s with Fn::ImportValue, which did not exist when the deployment ran. "+
+			"The export is now provided by %s, so it was most likely created after the deployment started, for example "+
+			"because both stacks were deployed in parallel or %s was deployed first.",
+			stackName, reference.ExportName, export.ExportingStack, stackName)
+		suggestion = fmt.Sprintf("Retry the deployment, and make sure %s is deployed before %s.", export.ExportingStack, stackName)
+	default:
+		explanation = fmt.Sprintf("%s imports %s with Fn::ImportValue, but no stack in this account and region exports "+
+			"it. The exporting stack was not deployed yet, failed, was deployed to another region, or no longer "+
+			"exports the value under this name.", stackName, reference.ExportName)
+		suggestion = fmt.Sprintf("Check the export name with 'aws cloudformation list-exports', then deploy the stack "+
+			"exporting %s before %s or correct the name in Fn::ImportValue.", reference.ExportName, stackName)
+	}
+
+	return analyzer.Finding{
+		Pattern:           PatternCrossStackExport,
+		LogicalResourceId: reference.LogicalResourceId,
+		Title:             "Cross-stack export dependency",
+		Explanation:       explanation,
+		Evidence:          evidence,
+		Suggestion:        suggestion,
+	}
+}
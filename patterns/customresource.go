@@ -0,0 +1,168 @@
+package patterns
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"cfn-root-cause/analyzer"
+)
+
+// PatternCustomResourceTimeout identifies findings about custom resources that never sent a response
+const PatternCustomResourceTimeout = "custom-resource-timeout"
+
+// maxLogEvidence limits the number of log messages shown as evidence
+const maxLogEvidence = 5
+
+// maxLogMessageLength limits the length of a log message shown as evidence
+const maxLogMessageLength = 200
+
+// customResourceTimeoutPatterns contains fragments of failure reasons of custom resources that did
+// not respond in time
+var customResourceTimeoutPatterns = []string{
+	"did not receive a response from your custom resource",
+	"failed to stabilize in expected time",
+	"custom resource timed out",
+}
+
+// logPitfall is a common cfn-response mistake recognized in the log output of a function
+type logPitfall struct {
+	fragments   []string
+	explanation string
+}
+
+// logPitfalls lists the recognized cfn-response mistakes, most specific first
+var logPitfalls = []logPitfall{
+	{
+		[]string{"task timed out after"},
+		"The function timed out before it sent the response. Increase its Timeout, and send a FAILED " +
+			"response before the remaining time (context.getRemainingTimeInMillis) runs out.",
+	},
+	{
+		[]string{"cannot find module 'cfn-response'", "no module named 'cfnresponse'", "no module named cfnresponse"},
+		"The cfn-response module is only available to code inlined with ZipFile. Package the module with the " +
+			"function or send the response with an HTTPS PUT to the ResponseURL.",
+	},
+	{
+		[]string{"runtime.importmoduleerror", "runtime.handlernotfound", "runtime.usercodesyntaxerror"},
+		"The function could not load its handler, so it never ran the code that sends the response. Check the " +
+			"Handler setting and the deployment package.",
+	},
+	{
+		[]string{"enotfound", "etimedout", "getaddrinfo", "connecttimeouterror", "connection timed out", "max retries exceeded"},
+		"The function could not reach the response URL. Functions in a VPC need a NAT gateway or an S3 gateway " +
+			"endpoint to send the response to the pre-signed S3 URL.",
+	},
+	{
+		[]string{"signaturedoesnotmatch", "status code: 403", "statuscode: 403"},
+		"The response URL rejected the response. Send the response unmodified to the ResponseURL of the same " +
+			"request, with an empty Content-Type, before the URL expires.",
+	},
+	{
+		[]string{"traceback (most recent call last)", "unhandled promise rejection", "\"errortype\"", "[error]"},
+		"The function raised an exception before it sent the response. Catch errors in the handler and send a " +
+			"FAILED response from the exception handler (try/except or try/catch), so CloudFormation fails fast.",
+	},
+}
+
+// responseSentFragments are logged by cfn-response when it sends the response
+var responseSentFragments = []string{"status code: 200", "statuscode: 200", "response body:"}
+
+// IsCustomResourceTimeout reports whether a failed resource is a custom resource that did not
+// send a response before CloudFormation stopped waiting
+func IsCustomResourceTimeout(stackErr analyzer.StackError) bool {
+	if !strings.HasPrefix(stackErr.ResourceType, "Custom::") && stackErr.ResourceType != "AWS::CloudFormation::CustomResource" {
+		return false
+	}
+	return containsAny(stackErr.ResourceStatusReason, customResourceTimeoutPatterns)
+}
+
+// ServiceTokenFunction returns the function name of a Lambda ServiceToken ARN, e.g.
+// arn:aws:lambda:eu-central-1:123456789012:function:provider. SNS topics yield false.
+func ServiceTokenFunction(serviceToken string) (string, bool) {
+	parts := strings.Split(serviceToken, ":")
+	if len(parts) < 7 || parts[2] != "lambda" || parts[5] != "function" {
+		return "", false
+	}
+	return parts[6], true
+}
+
+// DescribeCustomResourceTimeout explains a custom resource timeout with the invocations and log
+// output of the backing function and the cfn-response mistakes found in the log. functionName is
+// "" if the ServiceToken could not be resolved, and activity nil if it could not be read.
+func DescribeCustomResourceTimeout(stackErr analyzer.StackError, functionName string, activity *analyzer.LambdaActivity) analyzer.Finding {
+	evidence := []string{
+		fmt.Sprintf("%s (%s) %s at %s: %s", stackErr.LogicalResourceId, stackErr.ResourceType,
+			stackErr.ResourceStatus, formatTimestamp(stackErr.Timestamp), stackErr.ResourceStatusReason),
+	}
+	explanation := fmt.Sprintf("CloudFormation did not receive a response for %s before the timeout.", stackErr.LogicalResourceId)
+	suggestion := "Make sure the provider sends a SUCCESS or FAILED response to the ResponseURL for every request " +
+		"type, including Delete, and set ServiceTimeout on the custom resource to fail faster than the default of one hour."
+
+	if functionName == "" {
+		return customResourceFinding(stackErr, explanation+" The backing function could not be determined from the "+
+			"ServiceToken.", evidence, suggestion)
+	}
+	evidence = append(evidence, "Function: "+functionName)
+	if activity == nil {
+		return customResourceFinding(stackErr, explanation, evidence, suggestion)
+	}
+
+	evidence = append(evidence, fmt.Sprintf("Invocations: %d, errors: %d, throttles: %d, longest duration: %s",
+		activity.Invocations, activity.Errors, activity.Throttles, activity.MaxDuration.Round(time.Millisecond)))
+
+	for _, pitfall := range logPitfalls {
+		if messages := matchingMessages(activity.LogMessages, pitfall.fragments); len(messages) > 0 {
+			evidence = append(evidence, messages...)
+			return customResourceFinding(stackErr, explanation+" "+pitfall.explanation, evidence, suggestion)
+		}
+	}
+
+	switch {
+	case activity.Invocations == 0 && activity.Throttles > 0:
+		explanation += fmt.Sprintf(" Lambda throttled all %d invocations of %s; check its reserved concurrency.",
+			activity.Throttles, functionName)
+	case activity.Invocations == 0 && len(activity.LogMessages) == 0:
+		explanation += fmt.Sprintf(" %s was not invoked during the operation. Check that the ServiceToken points to "+
+			"the function in the region of the stack.", functionName)
+	case len(matchingMessages(activity.LogMessages, responseSentFragments)) > 0:
+		evidence = append(evidence, matchingMessages(activity.LogMessages, responseSentFragments)...)
+		explanation += " The function sent a response, but CloudFormation did not accept it. The response must " +
+			"contain the RequestId, StackId and LogicalResourceId of the request it answers."
+	default:
+		explanation += fmt.Sprintf(" %s ran but did not log sending a response. With async Node.js handlers, await "+
+			"the response instead of returning before cfn-response's send completes.", functionName)
+	}
+	return customResourceFinding(stackErr, explanation, evidence, suggestion)
+}
+
+// customResourceFinding builds a custom resource timeout finding
+func customResourceFinding(stackErr analyzer.StackError, explanation string, evidence []string, suggestion string) analyzer.Finding {
+	return analyzer.Finding{
+		Pattern:           PatternCustomResourceTimeout,
+		LogicalResourceId: stackErr.LogicalResourceId,
+		Title:             "Custom resource timed out",
+		Explanation:       explanation,
+		Evidence:          evidence,
+		Suggestion:        suggestion,
+	}
+}
+
+// matchingMessages returns up to maxLogEvidence log messages containing one of the fragments
+func matchingMessages(messages []string, fragments []string) []string {
+	var matching []string
+	for _, message := range messages {
+		if len(matching) == maxLogEvidence {
+			break
+		}
+		if !containsAny(message, fragments) {
+			continue
+		}
+		message = strings.TrimSpace(message)
+		if len(message) > maxLogMessageLength {
+			message = message[:maxLogMessageLength] + "..."
+		}
+		matching = append(matching, "Log: "+message)
+	}
+	return matching
+}
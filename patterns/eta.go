@@ -0,0 +1,150 @@
+package patterns
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation/types"
+)
+
+// Sources of the duration estimate of a resource, besides DurationFromBaseline
+const (
+	// DurationFromResource is the median duration of the resource in earlier deployments of its stack
+	DurationFromResource = "this resource before"
+
+	// DurationFromAccount is the median duration of resources of the type in stacks of the account
+	DurationFromAccount = "this type in the account"
+)
+
+// maxETAResources limits the number of resources named in an ETA line
+const maxETAResources = 3
+
+// ResourceETA is the estimated remaining time of a resource in progress
+type ResourceETA struct {
+	LogicalResourceId string
+	ResourceType      string
+	Elapsed           time.Duration
+
+	// Remaining is the estimated time until the resource completes, 0 if it is overdue
+	Remaining time.Duration
+	Source    string
+
+	// Overdue is true if the resource is in progress longer than its estimated duration
+	Overdue bool
+}
+
+// DeploymentETA is the estimated remaining time of a stack operation
+type DeploymentETA struct {
+	// Resources are the resources in progress, the longest remaining first
+	Resources []ResourceETA
+
+	// Remaining is the longest estimated remaining time of the resources in progress; resources
+	// that have not started yet are not included
+	Remaining time.Duration
+}
+
+// TypeDurations returns the durations of the completed resource operations in the stack events,
+// by resource type
+func TypeDurations(events []types.StackEvent) map[string][]time.Duration {
+	return completedDurations(events, func(event types.StackEvent) string { return aws.ToString(event.ResourceType) })
+}
+
+// EstimateDeployment estimates the remaining time of the resources in progress in the operation
+// events. A resource is expected to take its median duration in earlier deployments of its stack
+// (past, by logical ID), else the median duration of its type in the account (typeDurations, by
+// resource type), else the usual duration of its type.
+func EstimateDeployment(operationEvents []types.StackEvent, past, typeDurations map[string][]time.Duration, now time.Time) DeploymentETA {
+	var eta DeploymentETA
+	for _, resource := range InProgressResources(operationEvents, past, now, DefaultStuckFactor) {
+		expected, source := estimatedDuration(resource.ResourceType, past[resource.LogicalResourceId], typeDurations[resource.ResourceType])
+		resourceETA := ResourceETA{
+			LogicalResourceId: resource.LogicalResourceId,
+			ResourceType:      resource.ResourceType,
+			Elapsed:           resource.Elapsed,
+			Source:            source,
+		}
+		if resource.Elapsed > expected {
+			resourceETA.Overdue = true
+		} else {
+			resourceETA.Remaining = expected - resource.Elapsed
+		}
+		eta.Remaining = max(eta.Remaining, resourceETA.Remaining)
+		eta.Resources = append(eta.Resources, resourceETA)
+	}
+	slices.SortStableFunc(eta.Resources, func(a, b ResourceETA) int {
+		switch {
+		case a.Overdue != b.Overdue:
+			// Overdue resources are the ones to watch
+			if a.Overdue {
+				return -1
+			}
+			return 1
+		case a.Remaining != b.Remaining:
+			return cmp.Compare(b.Remaining, a.Remaining)
+		}
+		return strings.Compare(a.LogicalResourceId, b.LogicalResourceId)
+	})
+	return eta
+}
+
+// estimatedDuration returns the median of the earlier durations of the resource, else of its type
+// in the account, else the usual duration of the type
+func estimatedDuration(resourceType string, resourceDurations, typeDurations []time.Duration) (time.Duration, string) {
+	if len(resourceDurations) > 0 {
+		return median(resourceDurations), DurationFromResource
+	}
+	if len(typeDurations) > 0 {
+		return median(typeDurations), DurationFromAccount
+	}
+	expected, _ := ExpectedDuration(resourceType, nil)
+	return expected, DurationFromBaseline
+}
+
+// median returns the median of durations, which must not be empty
+func median(durations []time.Duration) time.Duration {
+	sorted := slices.Clone(durations)
+	slices.Sort(sorted)
+	return sorted[len(sorted)/2]
+}
+
+// FormatETA describes the estimated remaining time of a stack operation in one line, e.g.
+// "ETA 10:42:10 (~12m): Database ~12m left (this type in the account), Cdn overdue by 3m";
+// "" if no resource is in progress
+func FormatETA(eta DeploymentETA, now time.Time) string {
+	if len(eta.Resources) == 0 {
+		return ""
+	}
+
+	var parts []string
+	for i, resource := range eta.Resources {
+		if i == maxETAResources {
+			parts = append(parts, fmt.Sprintf("%d more", len(eta.Resources)-maxETAResources))
+			break
+		}
+		if resource.Overdue {
+			parts = append(parts, fmt.Sprintf("%s in progress for %s, longer than usual (%s)", resource.LogicalResourceId,
+				roundETA(resource.Elapsed), resource.Source))
+		} else {
+			parts = append(parts, fmt.Sprintf("%s ~%s left (%s)", resource.LogicalResourceId, roundETA(resource.Remaining),
+				resource.Source))
+		}
+	}
+	return fmt.Sprintf("ETA %s (~%s): %s", now.Add(eta.Remaining).Format("15:04:05"), roundETA(eta.Remaining),
+		strings.Join(parts, ", "))
+}
+
+// roundETA formats an estimate in minutes, e.g. "12m" or "1h05m", or in seconds below a minute
+func roundETA(d time.Duration) string {
+	if d < time.Minute {
+		return d.Round(time.Second).String()
+	}
+	d = d.Round(time.Minute)
+	if d < time.Hour {
+		return fmt.Sprintf("%dm", int(d.Minutes()))
+	}
+	return fmt.Sprintf("%dh%02dm", int(d.Hours()), int(d.Minutes())%60)
+}
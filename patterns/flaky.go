@@ -0,0 +1,111 @@
+package patterns
+
+import (
+	"fmt"
+
+	"cfn-root-cause/analyzer"
+
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation/types"
+)
+
+// PatternFlakyResource identifies findings about resources that failed before and later succeeded
+const PatternFlakyResource = "flaky-resource"
+
+// attemptFailedStatuses contains resource statuses that end a failed create or update attempt
+var attemptFailedStatuses = map[types.ResourceStatus]bool{
+	types.ResourceStatusCreateFailed: true,
+	types.ResourceStatusUpdateFailed: true,
+}
+
+// attemptSucceededStatuses contains resource statuses that end a successful create or update attempt
+var attemptSucceededStatuses = map[types.ResourceStatus]bool{
+	types.ResourceStatusCreateComplete: true,
+	types.ResourceStatusUpdateComplete: true,
+}
+
+// DetectFlakyResources recognizes resources that fail intermittently: the failed resource has
+// failed in earlier operations of the stack and succeeded again afterwards. The finding reports
+// the historical failure rate, since a resource that usually succeeds is likely to succeed on retry.
+func DetectFlakyResources(events []types.StackEvent, errors []analyzer.CorrelatedError) []analyzer.Finding {
+	var findings []analyzer.Finding
+	reported := make(map[string]bool)
+	var history map[string][]*types.StackEvent
+
+	for _, err := range errors {
+		logicalId := err.StackError.LogicalResourceId
+		if reported[logicalId] || !attemptFailedStatuses[types.ResourceStatus(err.StackError.ResourceStatus)] {
+			continue
+		}
+
+		// The events are grouped by resource once, so large histories are not scanned per error
+		if history == nil {
+			history = resourceEvents(events)
+		}
+		failures, successes, recovered := attemptHistory(history[logicalId])
+		if !recovered {
+			continue
+		}
+		reported[logicalId] = true
+
+		attempts := failures + successes
+		rate := float64(failures) / float64(attempts) * 100
+
+		findings = append(findings, analyzer.Finding{
+			Pattern:           PatternFlakyResource,
+			LogicalResourceId: logicalId,
+			Title:             "Flaky resource (transient failure)",
+			Explanation: fmt.Sprintf("%s has failed before and succeeded in a later operation. It failed in %d of %d "+
+				"recorded attempts (%.0f%%), so the failure is likely transient rather than a template error.",
+				logicalId, failures, attempts, rate),
+			Evidence: []string{
+				fmt.Sprintf("%d failed and %d successful create/update attempts of %s in the stack history",
+					failures, successes, logicalId),
+			},
+			Suggestion: fmt.Sprintf("Retry the deployment. If %s keeps failing intermittently, look for a race "+
+				"with another resource and add a DependsOn, or ask the service owner about eventual consistency.", logicalId),
+			Transient: true,
+		})
+	}
+
+	return findings
+}
+
+// resourceEvents groups the events of resources, excluding the stack itself, by logical ID
+func resourceEvents(events []types.StackEvent) map[string][]*types.StackEvent {
+	byResource := make(map[string][]*types.StackEvent)
+	for i := range events {
+		event := &events[i]
+		if safeString(event.PhysicalResourceId) == safeString(event.StackId) {
+			continue
+		}
+		logicalId := safeString(event.LogicalResourceId)
+		byResource[logicalId] = append(byResource[logicalId], event)
+	}
+	return byResource
+}
+
+// attemptHistory counts the failed and successful create/update attempts in the events of a
+// resource, and reports whether a failure was followed by a later success
+func attemptHistory(events []*types.StackEvent) (failures, successes int, recovered bool) {
+	var firstFailure, lastSuccess *types.StackEvent
+
+	for _, event := range events {
+		switch {
+		case attemptFailedStatuses[event.ResourceStatus]:
+			failures++
+			if firstFailure == nil || safeTime(event.Timestamp).Before(safeTime(firstFailure.Timestamp)) {
+				firstFailure = event
+			}
+		case attemptSucceededStatuses[event.ResourceStatus]:
+			successes++
+			if lastSuccess == nil || safeTime(event.Timestamp).After(safeTime(lastSuccess.Timestamp)) {
+				lastSuccess = event
+			}
+		}
+	}
+
+	recovered = firstFailure != nil && lastSuccess != nil &&
+		safeTime(lastSuccess.Timestamp).After(safeTime(firstFailure.Timestamp))
+
+	return failures, successes, recovered
+}
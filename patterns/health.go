@@ -0,0 +1,141 @@
+package patterns
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"cfn-root-cause/analyzer"
+)
+
+// PatternServiceIssue identifies findings about AWS service issues during the deployment
+const PatternServiceIssue = "aws-service-issue"
+
+// CloudFormationService is the AWS Health service code of CloudFormation, whose issues affect every resource
+const CloudFormationService = "CLOUDFORMATION"
+
+// serviceIssueMargin is how long before and after a failure a service issue counts as overlapping it,
+// since AWS Health reports the start of an issue only approximately
+const serviceIssueMargin = 15 * time.Minute
+
+// maxIssueDescriptionLength truncates the AWS Health description in the evidence
+const maxIssueDescriptionLength = 500
+
+// healthServices maps resource type namespaces to AWS Health service codes where they differ from
+// the upper-case namespace
+var healthServices = map[string]string{
+	"ApiGatewayV2":           "APIGATEWAY",
+	"ElasticLoadBalancingV2": "ELASTICLOADBALANCING",
+}
+
+// HealthService returns the AWS Health service code of a resource type, e.g. LAMBDA for
+// AWS::Lambda::Function; "" for types not provided by AWS
+func HealthService(resourceType string) string {
+	parts := strings.Split(resourceType, "::")
+	if len(parts) != 3 || parts[0] != "AWS" {
+		return ""
+	}
+	if service, ok := healthServices[parts[1]]; ok {
+		return service
+	}
+	return strings.ToUpper(parts[1])
+}
+
+// HealthServices returns the AWS Health service codes of the failed resources and CloudFormation,
+// sorted by the number of failures, the most failures first
+func HealthServices(errors []analyzer.CorrelatedError) []string {
+	counts := map[string]int{CloudFormationService: 0}
+	for _, err := range errors {
+		if service := HealthService(err.StackError.ResourceType); service != "" {
+			counts[service]++
+		}
+	}
+
+	services := make([]string, 0, len(counts))
+	for service := range counts {
+		services = append(services, service)
+	}
+	sort.Slice(services, func(i, j int) bool {
+		if counts[services[i]] != counts[services[j]] {
+			return counts[services[i]] > counts[services[j]]
+		}
+		return services[i] < services[j]
+	})
+	return services
+}
+
+// DetectServiceIssues explains failures that coincided with an AWS Health issue of the service of the
+// failed resource, one finding per issue for the first failure it overlaps. CloudFormation issues
+// apply to every resource.
+func DetectServiceIssues(errors []analyzer.CorrelatedError, events []analyzer.ServiceEvent) []analyzer.Finding {
+	var findings []analyzer.Finding
+	for _, event := range events {
+		var affected *analyzer.CorrelatedError
+		for i, err := range errors {
+			if event.Service != CloudFormationService && HealthService(err.StackError.ResourceType) != event.Service {
+				continue
+			}
+			if !overlaps(event, err.StackError.Timestamp) {
+				continue
+			}
+			if affected == nil || err.StackError.Timestamp.Before(affected.StackError.Timestamp) {
+				affected = &errors[i]
+			}
+		}
+		if affected != nil {
+			findings = append(findings, describeServiceIssue(*affected, event))
+		}
+	}
+	return findings
+}
+
+// overlaps reports whether the service issue was open at the time of a failure
+func overlaps(event analyzer.ServiceEvent, failure time.Time) bool {
+	if event.StartTime.After(failure.Add(serviceIssueMargin)) {
+		return false
+	}
+	return event.EndTime.IsZero() || !event.EndTime.Before(failure.Add(-serviceIssueMargin))
+}
+
+// describeServiceIssue explains a failure that coincided with a service issue
+func describeServiceIssue(err analyzer.CorrelatedError, event analyzer.ServiceEvent) analyzer.Finding {
+	const layout = "2006-01-02 15:04 UTC"
+
+	period := fmt.Sprintf("since %s, still open", event.StartTime.UTC().Format(layout))
+	if !event.EndTime.IsZero() {
+		period = fmt.Sprintf("from %s to %s", event.StartTime.UTC().Format(layout), event.EndTime.UTC().Format(layout))
+	}
+	region := event.Region
+	if region == "" {
+		region = "global"
+	}
+
+	evidence := []string{
+		fmt.Sprintf("AWS Health event %s (%s, %s): %s", event.EventTypeCode, region, event.Status, period),
+		fmt.Sprintf("%s (%s) %s at %s: %s", err.StackError.LogicalResourceId, err.StackError.ResourceType,
+			err.StackError.ResourceStatus, err.StackError.Timestamp.UTC().Format(layout), err.StackError.ResourceStatusReason),
+	}
+	if description := strings.Join(strings.Fields(event.Description), " "); description != "" {
+		if len(description) > maxIssueDescriptionLength {
+			description = description[:maxIssueDescriptionLength] + "..."
+		}
+		evidence = append(evidence, "AWS: "+description)
+	}
+	if event.Arn != "" {
+		evidence = append(evidence, "Event: "+event.Arn)
+	}
+
+	return analyzer.Finding{
+		Pattern:           PatternServiceIssue,
+		LogicalResourceId: err.StackError.LogicalResourceId,
+		Title:             fmt.Sprintf("AWS was reporting an incident in %s during the deployment", event.Service),
+		Explanation: fmt.Sprintf("AWS Health reported an issue with %s in %s %s, while %s failed. The failure may be "+
+			"caused by the service issue rather than by the template or the permissions of the deployment.",
+			event.Service, region, period, err.StackError.LogicalResourceId),
+		Evidence: evidence,
+		Suggestion: "Follow the event in the AWS Health Dashboard and retry the deployment once AWS resolved the issue. " +
+			"If the failure persists afterwards, it has a different cause.",
+		Transient: true,
+	}
+}
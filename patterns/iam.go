@@ -0,0 +1,150 @@
+package patterns
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"cfn-root-cause/analyzer"
+
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation/types"
+)
+
+// PatternIAMPropagation identifies findings caused by IAM eventual consistency
+const PatternIAMPropagation = "iam-propagation"
+
+// PropagationWindow is the maximum time between an IAM change and a dependent failure
+// for the failure to be attributed to IAM propagation delay
+const PropagationWindow = 2 * time.Minute
+
+// iamResourceTypes contains IAM resource types whose changes propagate eventually
+var iamResourceTypes = map[string]bool{
+	"AWS::IAM::Role":            true,
+	"AWS::IAM::Policy":          true,
+	"AWS::IAM::RolePolicy":      true,
+	"AWS::IAM::ManagedPolicy":   true,
+	"AWS::IAM::InstanceProfile": true,
+}
+
+// iamCompletedStatuses contains resource statuses that mark an IAM change as applied
+var iamCompletedStatuses = map[types.ResourceStatus]bool{
+	types.ResourceStatusCreateComplete: true,
+	types.ResourceStatusUpdateComplete: true,
+}
+
+// propagationErrorPatterns contains error fragments caused by a role or policy not yet being visible
+var propagationErrorPatterns = []string{
+	"InvalidRoleArn",
+	"AccessDenied",
+	"Access Denied",
+	"cannot be assumed",
+	"not authorized to perform",
+	"not authorized to assume",
+	"role is invalid",
+	"invalid role",
+	"role defined for the function",
+}
+
+// DetectIAMPropagation recognizes failures caused by IAM propagation delay: a role or policy
+// was created or updated shortly before a dependent resource failed with an InvalidRoleArn or
+// AccessDenied style error. IAM is eventually consistent, so a just-created role can be
+// rejected by other services for several seconds.
+func DetectIAMPropagation(events []types.StackEvent, errors []analyzer.CorrelatedError) []analyzer.Finding {
+	var findings []analyzer.Finding
+	var changes []*types.StackEvent
+	collected := false
+
+	for _, err := range errors {
+		if iamResourceTypes[err.StackError.ResourceType] {
+			continue
+		}
+		if !isPropagationError(err) {
+			continue
+		}
+
+		// The IAM changes are collected once, so large histories are not scanned per error
+		if !collected {
+			changes, collected = iamChanges(events), true
+		}
+		iamEvent := findPrecedingIAMChange(changes, err.StackError.Timestamp)
+		if iamEvent == nil {
+			continue
+		}
+
+		iamTime := safeTime(iamEvent.Timestamp)
+		iamLogicalId := safeString(iamEvent.LogicalResourceId)
+		delay := err.StackError.Timestamp.Sub(iamTime)
+
+		findings = append(findings, analyzer.Finding{
+			Pattern:           PatternIAMPropagation,
+			LogicalResourceId: err.StackError.LogicalResourceId,
+			Title:             "IAM propagation delay",
+			Explanation: fmt.Sprintf("%s failed %s after %s was applied. IAM changes are eventually consistent, "+
+				"so the role or policy was most likely not yet visible to the service creating %s.",
+				err.StackError.LogicalResourceId, delay.Round(time.Second), iamLogicalId, err.StackError.LogicalResourceId),
+			Evidence: []string{
+				fmt.Sprintf("%s (%s) %s at %s", iamLogicalId, safeString(iamEvent.ResourceType),
+					iamEvent.ResourceStatus, formatTimestamp(iamTime)),
+				fmt.Sprintf("%s (%s) %s at %s", err.StackError.LogicalResourceId, err.StackError.ResourceType,
+					err.StackError.ResourceStatus, formatTimestamp(err.StackError.Timestamp)),
+			},
+			Suggestion: fmt.Sprintf("Retry the deployment; the failure is usually transient. If it recurs, add "+
+				"'DependsOn: %s' to %s or reference the role with Fn::GetAtt so CloudFormation waits for it.",
+				iamLogicalId, err.StackError.LogicalResourceId),
+			Transient: true,
+		})
+	}
+
+	return findings
+}
+
+// isPropagationError checks if the error or its CloudTrail details indicate an invalid or unauthorized role
+func isPropagationError(err analyzer.CorrelatedError) bool {
+	texts := []string{err.StackError.ResourceStatusReason, err.DetailedMessage}
+	if err.CloudTrailEvent != nil {
+		texts = append(texts, err.CloudTrailEvent.ErrorCode, err.CloudTrailEvent.ErrorMessage)
+	}
+
+	for _, text := range texts {
+		textLower := strings.ToLower(text)
+		for _, pattern := range propagationErrorPatterns {
+			if strings.Contains(textLower, strings.ToLower(pattern)) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// findPrecedingIAMChange returns the most recent completed IAM change within the
+// iamChanges returns the completed IAM changes among the stack events, in their original order
+func iamChanges(events []types.StackEvent) []*types.StackEvent {
+	var changes []*types.StackEvent
+	for i := range events {
+		event := &events[i]
+		if iamResourceTypes[safeString(event.ResourceType)] && iamCompletedStatuses[event.ResourceStatus] {
+			changes = append(changes, event)
+		}
+	}
+	return changes
+}
+
+// findPrecedingIAMChange returns the most recent of the completed IAM changes within the
+// propagation window before the failure, or nil if there is none
+func findPrecedingIAMChange(changes []*types.StackEvent, failureTime time.Time) *types.StackEvent {
+	var latest *types.StackEvent
+
+	for _, event := range changes {
+		eventTime := safeTime(event.Timestamp)
+		if eventTime.After(failureTime) || failureTime.Sub(eventTime) > PropagationWindow {
+			continue
+		}
+
+		if latest == nil || eventTime.After(safeTime(latest.Timestamp)) {
+			latest = event
+		}
+	}
+
+	return latest
+}
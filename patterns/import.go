@@ -0,0 +1,181 @@
+package patterns
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"cfn-root-cause/analyzer"
+	"cfn-root-cause/cloudtrail"
+
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation/types"
+)
+
+// PatternImportFailure identifies findings about resources that could not be imported into a stack
+const PatternImportFailure = "import-failure"
+
+// VerificationWindow is the time around an import failure in which read calls made by
+// CloudFormation are attributed to the verification of the imported resource
+const VerificationWindow = 5 * time.Minute
+
+// importIdentifierPattern extracts the resource identifier quoted in import failure reasons
+var importIdentifierPattern = regexp.MustCompile(`(?i)identifier[s]?\s*(?:value\s*)?[\['"]([^\]'"]+)[\]'"]`)
+
+// importCause is a known reason why CloudFormation rejects an imported resource
+type importCause struct {
+	fragments   []string
+	explanation string
+	suggestion  string
+}
+
+// importCauses are checked in order; the first cause with a matching fragment explains the failure
+var importCauses = []importCause{
+	{
+		fragments: []string{"already exists in stack", "already managed by", "belongs to another stack", "is already in a stack"},
+		explanation: "%s (%s) is already managed by another stack. A resource can only belong to one stack, " +
+			"so CloudFormation refused to import it.",
+		suggestion: "Remove the resource from the other stack first: set 'DeletionPolicy: Retain' on it, deploy, " +
+			"then delete it from that stack's template and deploy again before importing.",
+	},
+	{
+		fragments: []string{"not found", "does not exist", "could not be found", "notfound", "no such"},
+		explanation: "CloudFormation could not find the resource %s (%s) refers to. The identifier given for the " +
+			"import does not match an existing resource in this account and region.",
+		suggestion: "Check the resource identifier in the import for typos and make sure the stack is deployed " +
+			"to the account and region the resource lives in.",
+	},
+	{
+		fragments: []string{"does not match", "mismatch", "different from", "must match"},
+		explanation: "The identifier of %s (%s) in the import does not match the corresponding property in the " +
+			"template. CloudFormation requires both to name the same resource.",
+		suggestion: "Set the identifying property in the template (e.g. BucketName, TableName, FunctionName) to " +
+			"the exact value used as the resource identifier of the import.",
+	},
+}
+
+// importFailureStatuses contains resource statuses of failed imports and failed import rollbacks
+var importFailureStatuses = map[string]bool{
+	string(types.ResourceStatusImportFailed):         true,
+	string(types.ResourceStatusImportRollbackFailed): true,
+}
+
+// DetectImportFailure explains resources that failed to be imported into a stack. The failure
+// reason is matched against known causes such as identifier mismatches, and the Describe/Get/List
+// calls CloudFormation made to verify the resource are listed as evidence.
+func DetectImportFailure(events []types.StackEvent, errors []analyzer.CorrelatedError, trailEvents []analyzer.CloudTrailEvent) []analyzer.Finding {
+	var findings []analyzer.Finding
+
+	rolledBack := importRolledBack(events)
+
+	for _, err := range errors {
+		stackErr := err.StackError
+		if !importFailureStatuses[stackErr.ResourceStatus] {
+			continue
+		}
+
+		reason := stackErr.ResourceStatusReason
+		if err.DetailedMessage != "" {
+			reason = err.DetailedMessage
+		}
+
+		explanation := fmt.Sprintf("CloudFormation could not import %s (%s).", stackErr.LogicalResourceId, stackErr.ResourceType)
+		suggestion := "Verify that the resource exists, is not part of another stack and that the template " +
+			"describes it with the same identifier and properties."
+		if cause := matchImportCause(reason); cause != nil {
+			explanation = fmt.Sprintf(cause.explanation, stackErr.LogicalResourceId, stackErr.ResourceType)
+			suggestion = cause.suggestion
+		}
+		if rolledBack {
+			explanation += " The import was rolled back; resources imported in the same operation were released " +
+				"from the stack again but not deleted."
+		}
+
+		evidence := []string{
+			fmt.Sprintf("%s (%s) %s at %s: %s", stackErr.LogicalResourceId, stackErr.ResourceType,
+				stackErr.ResourceStatus, formatTimestamp(stackErr.Timestamp), stackErr.ResourceStatusReason),
+		}
+		if match := importIdentifierPattern.FindStringSubmatch(stackErr.ResourceStatusReason); match != nil {
+			evidence = append(evidence, fmt.Sprintf("Resource identifier: %s", match[1]))
+		}
+		for _, call := range verificationCalls(trailEvents, stackErr) {
+			evidence = append(evidence, describeVerificationCall(call))
+		}
+
+		findings = append(findings, analyzer.Finding{
+			Pattern:           PatternImportFailure,
+			LogicalResourceId: stackErr.LogicalResourceId,
+			Title:             "Resource import failed",
+			Explanation:       explanation,
+			Evidence:          evidence,
+			Suggestion:        suggestion,
+		})
+	}
+
+	return findings
+}
+
+// matchImportCause returns the first known import cause matching the failure reason, or nil
+func matchImportCause(reason string) *importCause {
+	reasonLower := strings.ToLower(reason)
+	for i := range importCauses {
+		for _, fragment := range importCauses[i].fragments {
+			if strings.Contains(reasonLower, fragment) {
+				return &importCauses[i]
+			}
+		}
+	}
+	return nil
+}
+
+// importRolledBack checks if the stack events contain the rollback of an import operation
+func importRolledBack(events []types.StackEvent) bool {
+	for _, event := range events {
+		switch event.ResourceStatus {
+		case types.ResourceStatusImportRollbackInProgress, types.ResourceStatusImportRollbackComplete,
+			types.ResourceStatusImportRollbackFailed:
+			return true
+		}
+	}
+	return false
+}
+
+// verificationCalls returns the read calls to the service of the failed resource made around the failure
+func verificationCalls(trailEvents []analyzer.CloudTrailEvent, stackErr analyzer.StackError) []analyzer.CloudTrailEvent {
+	var calls []analyzer.CloudTrailEvent
+
+	for _, event := range trailEvents {
+		if !isReadCall(event.EventName) || !cloudtrail.MatchesResourceType(event, stackErr.ResourceType) {
+			continue
+		}
+		delta := event.EventTime.Sub(stackErr.Timestamp)
+		if delta < -VerificationWindow || delta > VerificationWindow {
+			continue
+		}
+		calls = append(calls, event)
+	}
+
+	return calls
+}
+
+// isReadCall checks if an API call only reads resources, as CloudFormation does to verify imports
+func isReadCall(eventName string) bool {
+	for _, prefix := range []string{"Describe", "Get", "List", "Head"} {
+		if strings.HasPrefix(eventName, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// describeVerificationCall formats a verification call and its outcome as evidence
+func describeVerificationCall(event analyzer.CloudTrailEvent) string {
+	call := fmt.Sprintf("%s (%s) at %s", event.EventName, event.EventSource, formatTimestamp(event.EventTime))
+	if event.ErrorCode == "" {
+		return call + " succeeded"
+	}
+	if event.ErrorMessage != "" {
+		return fmt.Sprintf("%s failed with %s: %s", call, event.ErrorCode, event.ErrorMessage)
+	}
+	return fmt.Sprintf("%s failed with %s", call, event.ErrorCode)
+}
@@ -0,0 +1,259 @@
+package patterns
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"cfn-root-cause/analyzer"
+)
+
+// PatternLambdaFunction identifies findings about Lambda functions failing on quotas or their VPC configuration
+const PatternLambdaFunction = "lambda-function"
+
+// Kinds of Lambda function failures
+const (
+	// LambdaCodeSize is a deployment package or code storage over the Lambda quota
+	LambdaCodeSize = "code-size"
+
+	// LambdaConcurrency is a reserved concurrency the account cannot provide
+	LambdaConcurrency = "concurrency"
+
+	// LambdaNetwork is a function whose network interfaces in the VPC could not be created
+	LambdaNetwork = "vpc-network"
+)
+
+// lambdaFailurePatterns recognize Lambda function failures in failure reasons, detailed messages
+// and CloudTrail error codes and messages
+var lambdaFailurePatterns = []struct {
+	kind    string
+	pattern *regexp.Regexp
+}{
+	{LambdaCodeSize, regexp.MustCompile(`(?i)unzipped size must be smaller than|request must be smaller than \d+ bytes|RequestEntityTooLarge|CodeStorageExceeded|code storage limit exceeded`)},
+	{LambdaConcurrency, regexp.MustCompile(`(?i)UnreservedConcurrentExecution below its minimum|ReservedConcurrentExecutions`)},
+	{LambdaNetwork, regexp.MustCompile(`(?i)CreateNetworkInterface|\bENI\b|ENILimitReached|network interfaces?|SubnetIPAddressLimitReached|free (?:IP )?addresses|InvalidSubnetID|InvalidSecurityGroupID|EC2ThrottledException`)},
+}
+
+// lambdaCallNames are the CloudTrail event names of the Lambda calls CloudFormation makes for each
+// kind of failure
+var lambdaCallNames = map[string][]string{
+	LambdaCodeSize:    {"CreateFunction20150331", "UpdateFunctionCode20150331v2"},
+	LambdaConcurrency: {"PutFunctionConcurrency20171031", "CreateFunction20150331"},
+	LambdaNetwork:     {"CreateFunction20150331", "UpdateFunctionConfiguration20150331v2"},
+}
+
+// shownLambdaParameters are the request parameters of a Lambda call shown as evidence; the code
+// itself is never shown
+var shownLambdaParameters = []string{
+	"functionName", "runtime", "packageType", "memorySize", "timeout", "architectures",
+	"reservedConcurrentExecutions", "vpcConfig", "s3Bucket", "s3Key", "imageUri", "layers",
+}
+
+// LambdaFailure is a failed Lambda function caused by a Lambda quota or its VPC configuration
+type LambdaFailure struct {
+	Error analyzer.CorrelatedError
+	Kind  string
+
+	// Call is the failed Lambda call, nil if it was not found in CloudTrail
+	Call *analyzer.CloudTrailEvent
+}
+
+// LambdaFailures returns the failed Lambda functions whose errors report an exceeded code size or
+// code storage, unavailable concurrency or network interfaces that could not be created
+func LambdaFailures(errors []analyzer.CorrelatedError) []LambdaFailure {
+	var failures []LambdaFailure
+	for _, err := range errors {
+		if err.StackError.ResourceType != "AWS::Lambda::Function" {
+			continue
+		}
+		texts := []string{err.StackError.ResourceStatusReason, err.DetailedMessage}
+		if err.CloudTrailEvent != nil {
+			texts = append(texts, err.CloudTrailEvent.ErrorCode, err.CloudTrailEvent.ErrorMessage)
+		}
+		if kind := lambdaFailureKind(texts); kind != "" {
+			failure := LambdaFailure{Error: err, Kind: kind}
+			if isLambdaCall(err.CloudTrailEvent, kind) {
+				failure.Call = err.CloudTrailEvent
+			}
+			failures = append(failures, failure)
+		}
+	}
+	return failures
+}
+
+// lambdaFailureKind returns the kind of Lambda failure the first matching text reports, "" if none does
+func lambdaFailureKind(texts []string) string {
+	for _, text := range texts {
+		for _, candidate := range lambdaFailurePatterns {
+			if candidate.pattern.MatchString(text) {
+				return candidate.kind
+			}
+		}
+	}
+	return ""
+}
+
+// LambdaCallNames returns the CloudTrail event names of the calls that may have failed for a kind
+// of Lambda failure
+func LambdaCallNames(kind string) []string {
+	return lambdaCallNames[kind]
+}
+
+// isLambdaCall reports whether a CloudTrail event is one of the calls of a kind of Lambda failure
+func isLambdaCall(event *analyzer.CloudTrailEvent, kind string) bool {
+	if event == nil {
+		return false
+	}
+	for _, name := range lambdaCallNames[kind] {
+		if event.EventName == name {
+			return true
+		}
+	}
+	return false
+}
+
+// ClosestLambdaCall returns the failed Lambda call that most closely precedes the failure, nil if
+// none does. Calls for other functions are skipped if the function name is known.
+func ClosestLambdaCall(failure time.Time, functionName string, events []analyzer.CloudTrailEvent) *analyzer.CloudTrailEvent {
+	var closest *analyzer.CloudTrailEvent
+	for i, event := range events {
+		if event.ErrorCode == "" || event.EventTime.After(failure) {
+			continue
+		}
+		if functionName != "" && !sameFunction(stringParameter(event.RequestParameters, "functionName"), functionName) {
+			continue
+		}
+		if closest == nil || event.EventTime.After(closest.EventTime) {
+			closest = &events[i]
+		}
+	}
+	return closest
+}
+
+// sameFunction reports whether a function name parameter, which may be a name or an ARN, names the function
+func sameFunction(parameter, functionName string) bool {
+	return parameter == functionName || strings.HasSuffix(parameter, ":function:"+functionName)
+}
+
+// DescribeLambdaFailure explains a Lambda function that failed on a quota or its VPC configuration,
+// with the request parameters of the failed call and the Lambda quotas of the account; settings is
+// nil if they could not be read
+func DescribeLambdaFailure(failure LambdaFailure, settings *analyzer.LambdaAccountSettings) analyzer.Finding {
+	stackErr := failure.Error.StackError
+	logicalId := stackErr.LogicalResourceId
+	evidence := []string{fmt.Sprintf("%s (%s) %s: %s", logicalId, stackErr.ResourceType, stackErr.ResourceStatus,
+		stackErr.ResourceStatusReason)}
+	if call := failure.Call; call != nil {
+		evidence = append(evidence, fmt.Sprintf("CloudTrail %s at %s failed with %s: %s", call.EventName,
+			formatTimestamp(call.EventTime), call.ErrorCode, call.ErrorMessage))
+		if parameters := lambdaParameters(call.RequestParameters); parameters != "" {
+			evidence = append(evidence, "Request parameters: "+parameters)
+		}
+	}
+
+	var title, explanation, suggestion string
+	switch failure.Kind {
+	case LambdaCodeSize:
+		title = "Lambda deployment package too large"
+		explanation = fmt.Sprintf("The code of %s exceeds a Lambda size quota: the deployment package (50 MB zipped for "+
+			"direct uploads, 250 MB unzipped including layers) or the code storage of the account.", logicalId)
+		if settings != nil {
+			evidence = append(evidence, fmt.Sprintf("Lambda code size quotas: %s zipped, %s unzipped", formatSize(settings.CodeSizeZipped),
+				formatSize(settings.CodeSizeUnzipped)))
+			evidence = append(evidence, fmt.Sprintf("Code storage: %s used of %s by %d function(s)", formatSize(settings.TotalCodeSizeUsed),
+				formatSize(settings.TotalCodeSize), settings.FunctionCount))
+		}
+		suggestion = "Deploy packages over 50 MB from S3 (Code.S3Bucket/S3Key). Beyond 250 MB unzipped, remove unused " +
+			"dependencies or use a container image, which may be up to 10 GB. If the code storage is full, delete old " +
+			"function versions and unused layer versions or request a quota increase."
+	case LambdaConcurrency:
+		title = "Lambda concurrency not available"
+		explanation = fmt.Sprintf("The ReservedConcurrentExecutions of %s would leave less than the minimum of 10 "+
+			"unreserved concurrent executions in the account, so Lambda refused to reserve it.", logicalId)
+		if settings != nil {
+			evidence = append(evidence, fmt.Sprintf("Account concurrency: %d, of which %d unreserved",
+				settings.ConcurrentExecutions, settings.UnreservedConcurrentExecutions))
+			if settings.ConcurrentExecutions <= 10 {
+				explanation += fmt.Sprintf(" The account has a concurrency quota of only %d, as new accounts do, so no "+
+					"function can reserve concurrency.", settings.ConcurrentExecutions)
+			}
+		}
+		suggestion = "Lower or remove ReservedConcurrentExecutions, reduce the reserved concurrency of other functions, or " +
+			"request an increase of the Lambda 'Concurrent executions' quota in Service Quotas."
+	default:
+		title = "Lambda function could not join its VPC"
+		explanation = fmt.Sprintf("Lambda could not create the network interfaces of %s in the subnets of its VpcConfig. "+
+			"This happens when the execution role lacks the EC2 network interface permissions, the subnets have no free IP "+
+			"addresses, the network interface quota is reached, or a subnet or security group does not exist.", logicalId)
+		suggestion = "Attach the AWSLambdaVPCAccessExecutionRole managed policy to the execution role, check that the " +
+			"subnets and security groups exist and the subnets have free IP addresses, and request a higher network " +
+			"interface quota if it is reached."
+	}
+
+	return analyzer.Finding{
+		Pattern:           PatternLambdaFunction,
+		LogicalResourceId: logicalId,
+		Title:             title,
+		Explanation:       explanation,
+		Evidence:          evidence,
+		Suggestion:        suggestion,
+	}
+}
+
+// lambdaParameters describes the relevant request parameters of a Lambda call, e.g.
+// "functionName=api, runtime=nodejs20.x"; the code of CreateFunction is nested in code
+func lambdaParameters(parameters map[string]interface{}) string {
+	flat := make(map[string]interface{}, len(parameters))
+	for name, value := range parameters {
+		flat[name] = value
+	}
+	if code, ok := parameters["code"].(map[string]interface{}); ok {
+		for name, value := range code {
+			flat[name] = value
+		}
+	}
+
+	var parts []string
+	for _, name := range shownLambdaParameters {
+		if value, ok := flat[name]; ok && value != nil {
+			parts = append(parts, fmt.Sprintf("%s=%s", name, parameterValue(value)))
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+// parameterValue formats a request parameter; objects are shown with their keys sorted
+func parameterValue(value interface{}) string {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		parts := make([]string, 0, len(keys))
+		for _, key := range keys {
+			parts = append(parts, fmt.Sprintf("%s=%s", key, parameterValue(v[key])))
+		}
+		return "{" + strings.Join(parts, ", ") + "}"
+	case []interface{}:
+		parts := make([]string, 0, len(v))
+		for _, item := range v {
+			parts = append(parts, parameterValue(item))
+		}
+		return "[" + strings.Join(parts, ", ") + "]"
+	case float64:
+		return fmt.Sprintf("%g", v)
+	}
+	return fmt.Sprint(value)
+}
+
+// formatSize formats a size in bytes as megabytes, or as gigabytes from 1 GB
+func formatSize(size int64) string {
+	if size >= 1<<30 {
+		return fmt.Sprintf("%.1f GB", float64(size)/(1<<30))
+	}
+	return fmt.Sprintf("%.1f MB", float64(size)/(1<<20))
+}
@@ -0,0 +1,194 @@
+package patterns
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"cfn-root-cause/analyzer"
+	"cfn-root-cause/cfntemplate"
+)
+
+// PatternTemplateLimit identifies findings about templates exceeding a CloudFormation quota
+const PatternTemplateLimit = "template-limit"
+
+// Template quotas of CloudFormation
+const (
+	MaxResources        = 500
+	MaxParameters       = 200
+	MaxOutputs          = 200
+	MaxMappings         = 200
+	MaxTemplateBodySize = 51200
+	MaxTemplateURLSize  = 1000000
+)
+
+// TemplateLimit is a quota of a template that a deployment exceeded
+type TemplateLimit struct {
+	// Kind is what exceeded the quota: resources, parameters, outputs, mappings or size
+	Kind string
+
+	// Count is the number reported in the failure reason, 0 if not reported
+	Count int
+	Limit int
+}
+
+// countLimitPattern matches "Number of resources, 523, is greater than maximum allowed, 500"
+var countLimitPattern = regexp.MustCompile(`(?i)number of (resources|parameters|outputs|mappings),\s*(\d+),\s*is greater than (?:the )?maximum allowed,\s*(\d+)`)
+
+// sizeLimitPatterns match failures of templates larger than the maximum body or S3 template size
+var sizeLimitPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`(?i)template may not exceed (\d+) bytes`),
+	regexp.MustCompile(`(?i)templatebody'? failed to satisfy constraint: member must have length less than or equal to (\d+)`),
+}
+
+// TemplateLimitViolation returns the template quota a failure reason reports as exceeded
+func TemplateLimitViolation(reason string) (TemplateLimit, bool) {
+	if match := countLimitPattern.FindStringSubmatch(reason); match != nil {
+		count, _ := strconv.Atoi(match[2])
+		limit, _ := strconv.Atoi(match[3])
+		return TemplateLimit{Kind: strings.ToLower(match[1]), Count: count, Limit: limit}, true
+	}
+	for _, pattern := range sizeLimitPatterns {
+		if match := pattern.FindStringSubmatch(reason); match != nil {
+			limit, _ := strconv.Atoi(match[1])
+			return TemplateLimit{Kind: "size", Limit: limit}, true
+		}
+	}
+	return TemplateLimit{}, false
+}
+
+// TemplateCounts are the sizes of the template of the failed deployment
+type TemplateCounts struct {
+	Bytes      int
+	Resources  int
+	Parameters int
+	Outputs    int
+	Mappings   int
+
+	// ResourcesByService counts the resources by service, to suggest what to move to nested stacks
+	ResourcesByService map[string]int
+}
+
+// CountTemplate returns the sizes of a template body
+func CountTemplate(body string, template *cfntemplate.Template) TemplateCounts {
+	counts := TemplateCounts{
+		Bytes:              len(body),
+		Resources:          len(template.Resources),
+		Parameters:         len(template.Parameters),
+		Outputs:            len(template.Outputs),
+		Mappings:           len(template.Mappings),
+		ResourcesByService: map[string]int{},
+	}
+	for _, resource := range template.Resources {
+		if parts := strings.Split(resource.Type, "::"); len(parts) >= 2 {
+			counts.ResourcesByService[parts[0]+"::"+parts[1]]++
+		}
+	}
+	return counts
+}
+
+// DescribeTemplateLimit explains a deployment that exceeded a template quota with the current
+// counts of the template, if known, and suggests how to split it
+func DescribeTemplateLimit(stackErr analyzer.StackError, limit TemplateLimit, counts *TemplateCounts) analyzer.Finding {
+	evidence := []string{
+		fmt.Sprintf("%s (%s) %s at %s: %s", stackErr.LogicalResourceId, stackErr.ResourceType,
+			stackErr.ResourceStatus, formatTimestamp(stackErr.Timestamp), stackErr.ResourceStatusReason),
+	}
+	if counts != nil {
+		evidence = append(evidence,
+			fmt.Sprintf("Template size: %d bytes (limit %d as template body, %d from S3)", counts.Bytes, MaxTemplateBodySize, MaxTemplateURLSize),
+			fmt.Sprintf("Resources: %d of %d", counts.Resources, MaxResources),
+			fmt.Sprintf("Parameters: %d of %d", counts.Parameters, MaxParameters),
+			fmt.Sprintf("Outputs: %d of %d", counts.Outputs, MaxOutputs),
+			fmt.Sprintf("Mappings: %d of %d", counts.Mappings, MaxMappings))
+	}
+
+	var explanation, suggestion string
+	switch limit.Kind {
+	case "size":
+		explanation = fmt.Sprintf("The template is larger than the maximum of %d bytes.", limit.Limit)
+		if counts != nil && counts.Bytes > limit.Limit {
+			explanation = fmt.Sprintf("The template has %d bytes, more than the maximum of %d bytes.", counts.Bytes, limit.Limit)
+		}
+		suggestion = "Upload templates larger than 51,200 bytes to S3 and deploy with --s3-bucket (aws cloudformation deploy) " +
+			"or a template URL, which allows up to 1 MB. Beyond that, move resources to nested stacks and remove " +
+			"comments, long descriptions and inline code."
+		if limit.Limit >= MaxTemplateURLSize {
+			suggestion = "Move groups of resources into nested stacks (AWS::CloudFormation::Stack) and move inline code " +
+				"such as Lambda functions and policies to S3 or separate files."
+		}
+	default:
+		count := limit.Count
+		if count == 0 && counts != nil {
+			count = limitCount(limit.Kind, counts)
+		}
+		explanation = fmt.Sprintf("The template declares %d %s, more than the maximum of %d.", count, limit.Kind, limit.Limit)
+		suggestion = fmt.Sprintf("Reduce the number of %s below %d.", limit.Kind, limit.Limit)
+		switch limit.Kind {
+		case "resources":
+			suggestion = fmt.Sprintf("Split the stack: move related resources into nested stacks (AWS::CloudFormation::Stack) "+
+				"or separate stacks connected with exports, so each template stays below %d resources.", limit.Limit)
+			if counts != nil {
+				if services := largestServices(counts.ResourcesByService, 3); services != "" {
+					suggestion += " The largest groups are " + services + "."
+				}
+			}
+		case "parameters":
+			suggestion = "Combine related parameters into one CommaDelimitedList parameter, read values from SSM " +
+				"parameters (AWS::SSM::Parameter::Value<String>) or move resources with their parameters into nested stacks."
+		case "outputs":
+			suggestion = "Remove outputs that are not consumed, or move resources with their outputs into nested stacks."
+		case "mappings":
+			suggestion = "Merge mappings or move values to SSM parameters."
+		}
+	}
+
+	return analyzer.Finding{
+		Pattern:           PatternTemplateLimit,
+		LogicalResourceId: stackErr.LogicalResourceId,
+		Title:             "Template limit exceeded",
+		Explanation:       explanation,
+		Evidence:          evidence,
+		Suggestion:        suggestion,
+	}
+}
+
+// limitCount returns the template count of a quota kind
+func limitCount(kind string, counts *TemplateCounts) int {
+	switch kind {
+	case "resources":
+		return counts.Resources
+	case "parameters":
+		return counts.Parameters
+	case "outputs":
+		return counts.Outputs
+	case "mappings":
+		return counts.Mappings
+	}
+	return 0
+}
+
+// largestServices describes the n services with the most resources, e.g. "AWS::Lambda (120)"
+func largestServices(byService map[string]int, n int) string {
+	services := make([]string, 0, len(byService))
+	for service := range byService {
+		services = append(services, service)
+	}
+	sort.Slice(services, func(i, j int) bool {
+		if byService[services[i]] != byService[services[j]] {
+			return byService[services[i]] > byService[services[j]]
+		}
+		return services[i] < services[j]
+	})
+	if len(services) > n {
+		services = services[:n]
+	}
+
+	parts := make([]string, 0, len(services))
+	for _, service := range services {
+		parts = append(parts, fmt.Sprintf("%s (%d)", service, byService[service]))
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,127 @@
+package patterns
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"time"
+
+	"cfn-root-cause/analyzer"
+)
+
+// PatternNullHandlerMessage identifies findings about handlers that failed with the message "null"
+const PatternNullHandlerMessage = "null-handler-message"
+
+// maxFailedCallEvidence limits the number of failed CloudTrail calls shown as evidence
+const maxFailedCallEvidence = 5
+
+// nullHandlerMessageRegex matches the status reason of registry-based providers that caught an
+// exception without a message, e.g. Resource handler returned message: "null" (RequestToken: ...,
+// HandlerErrorCode: GeneralServiceException)
+var nullHandlerMessageRegex = regexp.MustCompile(`(?i)handler returned message: "?null"?(?:\s|\(|$)`)
+
+// requestTokenRegex extracts the request token of the handler invocation from a status reason
+var requestTokenRegex = regexp.MustCompile(`RequestToken:\s*([A-Za-z0-9-]+)`)
+
+// handlerLogErrorFragments are logged by resource type handlers when a call or the handler fails
+var handlerLogErrorFragments = []string{"exception", "error", "failed", "status code: 4", "status code: 5", "statuscode: 4", "statuscode: 5"}
+
+// IsNullHandlerMessage reports whether a resource failed with the opaque handler message "null"
+func IsNullHandlerMessage(stackErr analyzer.StackError) bool {
+	return nullHandlerMessageRegex.MatchString(stackErr.ResourceStatusReason)
+}
+
+// NullHandlerInvestigation collects what is known about a handler that failed with the message "null"
+type NullHandlerInvestigation struct {
+	Error analyzer.CorrelatedError
+
+	// FailedCalls are the calls CloudFormation made on behalf of the stack that failed during the
+	// operation of the resource, regardless of their service
+	FailedCalls []analyzer.CloudTrailEvent
+
+	// RegistryType is the registration of a private or third-party type, nil for AWS types or if
+	// it could not be described
+	RegistryType *analyzer.RegistryType
+
+	// HandlerLog are the messages of the handler log group during the operation; HandlerLogFound
+	// is false if the type has no log group or it was not read
+	HandlerLog      []string
+	HandlerLogFound bool
+}
+
+// DescribeNullHandlerMessage explains a handler failure without an error message with the failed
+// CloudTrail calls of the operation and the error messages of the handler log
+func DescribeNullHandlerMessage(investigation NullHandlerInvestigation) analyzer.Finding {
+	stackErr := investigation.Error.StackError
+	evidence := []string{
+		fmt.Sprintf("%s (%s) %s at %s: %s", stackErr.LogicalResourceId, stackErr.ResourceType,
+			stackErr.ResourceStatus, formatTimestamp(stackErr.Timestamp), stackErr.ResourceStatusReason),
+	}
+	if match := requestTokenRegex.FindStringSubmatch(stackErr.ResourceStatusReason); match != nil {
+		evidence = append(evidence, "Request token: "+match[1])
+	}
+
+	explanation := fmt.Sprintf("The handler of %s failed without an error message. Registry-based providers "+
+		"report \"null\" when they catch an exception that has no message, often from a call to another service.",
+		stackErr.ResourceType)
+	suggestion := fmt.Sprintf("Retry the operation. If it fails again, open a case with the provider of %s "+
+		"and include the request token.", stackErr.ResourceType)
+
+	calls := closestFailedCalls(stackErr.Timestamp, investigation.FailedCalls)
+	for _, call := range calls {
+		evidence = append(evidence, "CloudTrail "+describeVerificationCall(call))
+	}
+	if len(calls) > 0 {
+		closest := calls[0]
+		explanation += fmt.Sprintf(" %s (%s) failed with %s during the operation and is the likely cause.",
+			closest.EventName, closest.EventSource, closest.ErrorCode)
+		suggestion = fmt.Sprintf("Fix the cause of the failed %s call, e.g. a missing permission of the role "+
+			"CloudFormation uses or an invalid property value, and retry the operation.", closest.EventName)
+	}
+
+	if registryType := investigation.RegistryType; registryType != nil {
+		switch {
+		case registryType.HandlerLogGroup == "":
+			explanation += " The type does not log its handlers."
+			if len(calls) == 0 {
+				suggestion = fmt.Sprintf("Activate or register %s with a logging configuration and retry the "+
+					"operation to see the exception in the handler log.", stackErr.ResourceType)
+			}
+		case !investigation.HandlerLogFound:
+			evidence = append(evidence, fmt.Sprintf("Handler log group %s not found", registryType.HandlerLogGroup))
+		default:
+			messages := matchingMessages(investigation.HandlerLog, handlerLogErrorFragments)
+			evidence = append(evidence, fmt.Sprintf("Handler log group: %s", registryType.HandlerLogGroup))
+			evidence = append(evidence, messages...)
+			if len(messages) > 0 {
+				explanation += " The handler log shows the exception the handler did not report."
+			}
+		}
+	}
+
+	return analyzer.Finding{
+		Pattern:           PatternNullHandlerMessage,
+		LogicalResourceId: stackErr.LogicalResourceId,
+		Title:             "Resource handler returned no message",
+		Explanation:       explanation,
+		Evidence:          evidence,
+		Suggestion:        suggestion,
+	}
+}
+
+// closestFailedCalls returns up to maxFailedCallEvidence failed calls, closest to the failure first
+func closestFailedCalls(failure time.Time, calls []analyzer.CloudTrailEvent) []analyzer.CloudTrailEvent {
+	var failed []analyzer.CloudTrailEvent
+	for _, call := range calls {
+		if call.ErrorCode != "" {
+			failed = append(failed, call)
+		}
+	}
+	sort.SliceStable(failed, func(i, j int) bool {
+		return failure.Sub(failed[i].EventTime).Abs() < failure.Sub(failed[j].EventTime).Abs()
+	})
+	if len(failed) > maxFailedCallEvidence {
+		failed = failed[:maxFailedCallEvidence]
+	}
+	return failed
+}
@@ -0,0 +1,171 @@
+package patterns
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"cfn-root-cause/analyzer"
+	"cfn-root-cause/cfntemplate"
+)
+
+// PatternParameterConstraint identifies findings about parameter values violating their constraints
+const PatternParameterConstraint = "parameter-constraint"
+
+// maxParameterValueLength limits the length of a parameter value shown in a finding
+const maxParameterValueLength = 80
+
+// parameterFailurePatterns extract the parameter name from parameter validation failures
+var parameterFailurePatterns = []*regexp.Regexp{
+	regexp.MustCompile(`Parameter '?([A-Za-z0-9]+)'? (?:must|failed to satisfy)`),
+	regexp.MustCompile(`for parameter name '?([A-Za-z0-9]+)'?`),
+	regexp.MustCompile(`Parameters: \[([A-Za-z0-9]+)[],]`),
+	regexp.MustCompile(`[Pp]arameter '([A-Za-z0-9]+)' (?:is|has)`),
+}
+
+// ParameterViolation returns the name of the parameter a parameter validation failure is about
+func ParameterViolation(reason string) (string, bool) {
+	for _, pattern := range parameterFailurePatterns {
+		if match := pattern.FindStringSubmatch(reason); match != nil {
+			return match[1], true
+		}
+	}
+	return "", false
+}
+
+// ParameterValue is the value provided for a parameter in the failed deployment
+type ParameterValue struct {
+	Value string
+
+	// Provided is false if the value is unknown, e.g. because the stack was rolled back
+	Provided bool
+}
+
+// DescribeParameterViolation explains a parameter validation failure with the constraints of the
+// parameter definition next to the provided value, and names the constraints the value violates.
+// Values of NoEcho parameters are never shown.
+func DescribeParameterViolation(stackErr analyzer.StackError, name string, definition cfntemplate.Parameter, value ParameterValue) analyzer.Finding {
+	constraints := parameterConstraints(definition)
+	evidence := []string{
+		fmt.Sprintf("%s (%s) %s at %s: %s", stackErr.LogicalResourceId, stackErr.ResourceType,
+			stackErr.ResourceStatus, formatTimestamp(stackErr.Timestamp), stackErr.ResourceStatusReason),
+		fmt.Sprintf("Parameter %s of type %s", name, valueOrUnknown(definition.Type)),
+	}
+
+	shown := "unknown"
+	switch {
+	case definition.NoEcho:
+		shown = "hidden (NoEcho)"
+	case value.Provided:
+		shown = strconv.Quote(truncateValue(value.Value))
+	case definition.HasDefault:
+		shown = strconv.Quote(truncateValue(definition.Default)) + " (default)"
+	}
+	evidence = append(evidence, "Provided value: "+shown)
+	for _, constraint := range constraints {
+		evidence = append(evidence, "Constraint: "+constraint)
+	}
+
+	explanation := fmt.Sprintf("The value of parameter %s violates its definition in the template.", name)
+	if value.Provided && !definition.NoEcho {
+		if violated := violatedConstraints(definition, value.Value); len(violated) > 0 {
+			explanation = fmt.Sprintf("The value of parameter %s violates %s.", name, strings.Join(violated, " and "))
+		}
+	}
+	if definition.ConstraintDescription != "" {
+		explanation += fmt.Sprintf(" The template describes the constraint as: %s", definition.ConstraintDescription)
+	}
+
+	suggestion := fmt.Sprintf("Pass a value for %s that satisfies the constraints, e.g. "+
+		"--parameter-overrides %s=<value>.", name, name)
+	if len(definition.AllowedValues) > 0 {
+		suggestion = fmt.Sprintf("Pass one of the allowed values for %s: %s.", name, strings.Join(definition.AllowedValues, ", "))
+	}
+	if len(constraints) == 0 {
+		suggestion = fmt.Sprintf("Check that the value of %s exists and has the expected format of %s.",
+			name, valueOrUnknown(definition.Type))
+	}
+
+	return analyzer.Finding{
+		Pattern:           PatternParameterConstraint,
+		LogicalResourceId: stackErr.LogicalResourceId,
+		Title:             "Parameter constraint violated",
+		Explanation:       explanation,
+		Evidence:          evidence,
+		Suggestion:        suggestion,
+	}
+}
+
+// parameterConstraints describes the constraints of a parameter definition
+func parameterConstraints(definition cfntemplate.Parameter) []string {
+	var constraints []string
+	if len(definition.AllowedValues) > 0 {
+		constraints = append(constraints, "AllowedValues ["+strings.Join(definition.AllowedValues, ", ")+"]")
+	}
+	if definition.AllowedPattern != "" {
+		constraints = append(constraints, "AllowedPattern "+definition.AllowedPattern)
+	}
+	if definition.MinLength != "" {
+		constraints = append(constraints, "MinLength "+definition.MinLength)
+	}
+	if definition.MaxLength != "" {
+		constraints = append(constraints, "MaxLength "+definition.MaxLength)
+	}
+	if definition.MinValue != "" {
+		constraints = append(constraints, "MinValue "+definition.MinValue)
+	}
+	if definition.MaxValue != "" {
+		constraints = append(constraints, "MaxValue "+definition.MaxValue)
+	}
+	return constraints
+}
+
+// violatedConstraints returns the constraints of the definition the value does not satisfy
+func violatedConstraints(definition cfntemplate.Parameter, value string) []string {
+	var violated []string
+
+	if len(definition.AllowedValues) > 0 {
+		allowed := false
+		for _, allowedValue := range definition.AllowedValues {
+			allowed = allowed || allowedValue == value
+		}
+		if !allowed {
+			violated = append(violated, "AllowedValues")
+		}
+	}
+	if definition.AllowedPattern != "" {
+		// CloudFormation matches the pattern against the whole value
+		if pattern, err := regexp.Compile("^(?:" + definition.AllowedPattern + ")$"); err == nil && !pattern.MatchString(value) {
+			violated = append(violated, "AllowedPattern "+definition.AllowedPattern)
+		}
+	}
+
+	length := float64(utf8.RuneCountInString(value))
+	if limit, err := strconv.ParseFloat(definition.MinLength, 64); err == nil && length < limit {
+		violated = append(violated, fmt.Sprintf("MinLength %s (the value has %d characters)", definition.MinLength, int(length)))
+	}
+	if limit, err := strconv.ParseFloat(definition.MaxLength, 64); err == nil && length > limit {
+		violated = append(violated, fmt.Sprintf("MaxLength %s (the value has %d characters)", definition.MaxLength, int(length)))
+	}
+
+	if number, err := strconv.ParseFloat(value, 64); err == nil {
+		if limit, err := strconv.ParseFloat(definition.MinValue, 64); err == nil && number < limit {
+			violated = append(violated, "MinValue "+definition.MinValue)
+		}
+		if limit, err := strconv.ParseFloat(definition.MaxValue, 64); err == nil && number > limit {
+			violated = append(violated, "MaxValue "+definition.MaxValue)
+		}
+	}
+
+	return violated
+}
+
+// truncateValue shortens long parameter values, which may contain documents or keys
+func truncateValue(value string) string {
+	if utf8.RuneCountInString(value) <= maxParameterValueLength {
+		return value
+	}
+	return string([]rune(value)[:maxParameterValueLength]) + "..."
+}
@@ -0,0 +1,46 @@
+// Package patterns recognizes well-known CloudFormation failure patterns and explains their root cause
+package patterns
+
+import (
+	"time"
+
+	"cfn-root-cause/analyzer"
+
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation/types"
+)
+
+// Detect runs all pattern detectors against the stack events, correlated errors and the
+// CloudTrail events retrieved during the analysis.
+// The full (unfiltered) event history is needed because several patterns depend on
+// successful events, such as the creation of a resource the failed resource relied on.
+func Detect(events []types.StackEvent, errors []analyzer.CorrelatedError, trailEvents []analyzer.CloudTrailEvent) []analyzer.Finding {
+	var findings []analyzer.Finding
+
+	findings = append(findings, DetectIAMPropagation(events, errors)...)
+	findings = append(findings, DetectImportFailure(events, errors, trailEvents)...)
+	findings = append(findings, DetectFlakyResources(events, errors)...)
+	findings = append(findings, DetectMissingCapabilities(events, errors)...)
+
+	return findings
+}
+
+// safeString safely dereferences a string pointer, returning empty string if nil
+func safeString(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
+// safeTime safely dereferences a time pointer, returning zero time if nil
+func safeTime(t *time.Time) time.Time {
+	if t == nil {
+		return time.Time{}
+	}
+	return *t
+}
+
+// formatTimestamp formats a time.Time for use in finding evidence
+func formatTimestamp(t time.Time) string {
+	return t.Format("2006-01-02 15:04:05 MST")
+}
@@ -0,0 +1,154 @@
+package patterns
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"cfn-root-cause/analyzer"
+)
+
+// PatternDatabaseEvents identifies findings about RDS events recorded while a database resource failed
+const PatternDatabaseEvents = "rds-events"
+
+// maxDatabaseEvidence limits the number of RDS events shown as evidence, the latest first
+const maxDatabaseEvidence = 10
+
+// rdsSourceTypes maps the RDS resource types to the source types of their RDS events
+var rdsSourceTypes = map[string]string{
+	"AWS::RDS::DBInstance":       "db-instance",
+	"AWS::RDS::DBCluster":        "db-cluster",
+	"AWS::RDS::DBParameterGroup": "db-parameter-group",
+	"AWS::RDS::DBProxy":          "db-proxy",
+}
+
+// problemCategories are the RDS event categories that report a problem
+var problemCategories = map[string]bool{
+	"failure":     true,
+	"low storage": true,
+}
+
+// problemMessagePattern recognizes RDS event messages that report a problem in other categories,
+// e.g. "The database instance is in an incompatible-parameters state"
+var problemMessagePattern = regexp.MustCompile(`(?i)incompatible|storage[- ]full|\bfail(?:ed|ure|s)?\b|unable|cannot|could not|not supported|insufficient|invalid`)
+
+// databaseHints suggest a remediation for well-known RDS-side causes, the first matching hint wins
+var databaseHints = []struct {
+	pattern    *regexp.Regexp
+	suggestion string
+}{
+	{regexp.MustCompile(`(?i)incompatible[- ]parameters?`), "Check the values of the DB parameter group against the " +
+		"engine version and instance class, e.g. memory settings larger than the instance provides, and reset or " +
+		"correct the offending parameters."},
+	{regexp.MustCompile(`(?i)storage[- ]full|low storage|out of storage`), "Increase AllocatedStorage or enable storage " +
+		"autoscaling with MaxAllocatedStorage; RDS cannot modify an instance whose storage is full."},
+	{regexp.MustCompile(`(?i)engine version|upgrade|incompatible.*version|version.*(?:not supported|incompatible)`), "Check " +
+		"that the EngineVersion is available for the engine, instance class and region (aws rds " +
+		"describe-db-engine-versions), and set AllowMajorVersionUpgrade for major version upgrades."},
+	{regexp.MustCompile(`(?i)kms|encrypt`), "Check that the KMS key of the database is enabled and that RDS may use it."},
+	{regexp.MustCompile(`(?i)subnet|network|security group|vpc`), "Check the DB subnet group and security groups: the subnets " +
+		"need free IP addresses in at least two Availability Zones."},
+}
+
+// DatabaseSource is a failed database resource and the RDS event source it corresponds to
+type DatabaseSource struct {
+	Error analyzer.StackError
+
+	// SourceType is the RDS source type, e.g. db-instance
+	SourceType string
+
+	// Identifier is the RDS identifier, the physical ID of the resource
+	Identifier string
+}
+
+// RDSSourceType returns the RDS event source type of a resource type, "" for other resource types
+func RDSSourceType(resourceType string) string {
+	return rdsSourceTypes[resourceType]
+}
+
+// IsDatabaseProblem reports whether an RDS event reports a problem rather than progress, such as
+// "Backing up DB instance"
+func IsDatabaseProblem(event analyzer.DatabaseEvent) bool {
+	for _, category := range event.Categories {
+		if problemCategories[category] {
+			return true
+		}
+	}
+	return problemMessagePattern.MatchString(event.Message)
+}
+
+// DescribeDatabaseEvents explains a failed database resource with the events RDS recorded during
+// the deployment, which usually name the cause CloudFormation hides behind messages such as
+// "did not stabilize". There is no finding if RDS recorded no events.
+func DescribeDatabaseEvents(source DatabaseSource, events []analyzer.DatabaseEvent) (analyzer.Finding, bool) {
+	if len(events) == 0 {
+		return analyzer.Finding{}, false
+	}
+
+	var problems []analyzer.DatabaseEvent
+	for _, event := range events {
+		if IsDatabaseProblem(event) {
+			problems = append(problems, event)
+		}
+	}
+
+	shown := problems
+	if len(shown) == 0 {
+		shown = events
+	}
+	if len(shown) > maxDatabaseEvidence {
+		shown = shown[len(shown)-maxDatabaseEvidence:]
+	}
+
+	stackError := source.Error
+	evidence := []string{fmt.Sprintf("%s (%s) %s: %s", stackError.LogicalResourceId, stackError.ResourceType,
+		stackError.ResourceStatus, stackError.ResourceStatusReason)}
+	for i := len(shown) - 1; i >= 0; i-- {
+		event := shown[i]
+		categories := ""
+		if len(event.Categories) > 0 {
+			categories = " [" + strings.Join(event.Categories, ", ") + "]"
+		}
+		evidence = append(evidence, fmt.Sprintf("RDS %s %s at %s%s: %s", event.SourceType, event.SourceIdentifier,
+			formatTimestamp(event.Time), categories, event.Message))
+	}
+
+	var explanation, suggestion string
+	if len(problems) > 0 {
+		latest := problems[len(problems)-1]
+		explanation = fmt.Sprintf("RDS reported a problem with %s while CloudFormation deployed %s: %s. This is "+
+			"most likely the cause of the failure, which the CloudFormation event does not name.",
+			source.Identifier, stackError.LogicalResourceId, strings.TrimSuffix(latest.Message, "."))
+		suggestion = databaseSuggestion(problems)
+	} else {
+		explanation = fmt.Sprintf("RDS recorded %d event(s) for %s while CloudFormation deployed %s, none of them "+
+			"reporting a problem. The latest events show how far the operation got before it failed.",
+			len(events), source.Identifier, stackError.LogicalResourceId)
+	}
+	if suggestion == "" {
+		suggestion = fmt.Sprintf("Review all events with: aws rds describe-events --source-type %s --source-identifier %s "+
+			"--duration 1440", source.SourceType, source.Identifier)
+	}
+
+	return analyzer.Finding{
+		Pattern:           PatternDatabaseEvents,
+		LogicalResourceId: stackError.LogicalResourceId,
+		Title:             "RDS events during the deployment",
+		Explanation:       explanation,
+		Evidence:          evidence,
+		Suggestion:        suggestion,
+	}, true
+}
+
+// databaseSuggestion returns the hint for the latest problem with a well-known cause, "" if none has one
+func databaseSuggestion(problems []analyzer.DatabaseEvent) string {
+	for i := len(problems) - 1; i >= 0; i-- {
+		text := problems[i].Message + " " + strings.Join(problems[i].Categories, " ")
+		for _, hint := range databaseHints {
+			if hint.pattern.MatchString(text) {
+				return hint.suggestion
+			}
+		}
+	}
+	return ""
+}
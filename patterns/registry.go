@@ -0,0 +1,178 @@
+package patterns
+
+import (
+	"fmt"
+	"strings"
+
+	"cfn-root-cause/analyzer"
+
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation/types"
+)
+
+// PatternRegistryType identifies findings about failed resources of private or third-party types
+const PatternRegistryType = "registry-type"
+
+// awsNamespaces contains the type namespaces of resource types provided by AWS
+var awsNamespaces = map[string]bool{
+	"AWS":    true,
+	"Alexa":  true,
+	"Custom": true,
+}
+
+// schemaMismatchPatterns contains fragments of handler errors caused by properties that do not
+// match the schema of the activated type version
+var schemaMismatchPatterns = []string{
+	"model validation failed",
+	"extraneous key",
+	"required key",
+	"expected type",
+	"does not match the schema",
+	"schema validation",
+	"unrecognized field",
+	"unknown property",
+	"invalidrequest",
+	"handlerinternalfailure",
+}
+
+// permissionPatterns contains fragments of handler errors caused by a missing or insufficient execution role
+var permissionPatterns = []string{
+	"accessdenied",
+	"access denied",
+	"not authorized to perform",
+	"unable to assume",
+}
+
+// IsRegistryType reports whether a resource type is a private or third-party registry type,
+// such as MongoDB::Atlas::Cluster, as opposed to types provided by AWS
+func IsRegistryType(resourceType string) bool {
+	parts := strings.Split(resourceType, "::")
+	return len(parts) >= 3 && !awsNamespaces[parts[0]]
+}
+
+// DetectRegistryTypeIssues describes failed resources of private and third-party types with the
+// registered version, deprecation status and execution role of their type, and explains failures
+// caused by a template written for a different version of the type. registryTypes holds the
+// types described by the registry, by type name; resources of other types are skipped.
+func DetectRegistryTypeIssues(errors []analyzer.CorrelatedError, registryTypes map[string]*analyzer.RegistryType) []analyzer.Finding {
+	var findings []analyzer.Finding
+
+	for _, err := range errors {
+		stackErr := err.StackError
+		registryType := registryTypes[stackErr.ResourceType]
+		if registryType == nil {
+			continue
+		}
+
+		reason := stackErr.ResourceStatusReason
+		if err.DetailedMessage != "" {
+			reason = err.DetailedMessage
+		}
+		public := registryType.Visibility == string(types.VisibilityPublic) || registryType.PublisherId != ""
+		version := registryVersion(registryType)
+		newerVersion := public && registryType.LatestPublicVersion != "" &&
+			registryType.LatestPublicVersion != registryType.PublicVersionNumber
+
+		title := "Third-party resource type failed"
+		explanation := fmt.Sprintf("%s uses the %s type %s, version %s.",
+			stackErr.LogicalResourceId, visibilityName(public), stackErr.ResourceType, version)
+		suggestion := fmt.Sprintf("Check the documentation and the schema of %s version %s: "+
+			"aws cloudformation describe-type --type RESOURCE --type-name %s",
+			stackErr.ResourceType, version, stackErr.ResourceType)
+
+		switch {
+		case containsAny(reason, schemaMismatchPatterns):
+			title = "Resource type version mismatch"
+			explanation += " The handler rejected the properties of the resource, which usually means the " +
+				"template was written for a different version of the type than the one used by this account."
+			if public {
+				suggestion = fmt.Sprintf("Align the properties with the schema of version %s, or activate the version "+
+					"the template was written for: aws cloudformation activate-type --type RESOURCE --type-name %s "+
+					"--publisher-id %s --version-bump MAJOR", version, stackErr.ResourceType, registryType.PublisherId)
+			} else {
+				suggestion = fmt.Sprintf("Align the properties with the schema of version %s, or make the version the "+
+					"template was written for the default: aws cloudformation set-type-default-version --type RESOURCE "+
+					"--type-name %s --version-id <version>", version, stackErr.ResourceType)
+			}
+		case containsAny(reason, permissionPatterns):
+			title = "Resource type execution role denied"
+			if registryType.ExecutionRoleArn == "" {
+				explanation += " The type has no execution role, so its handlers cannot call AWS services on your behalf."
+			} else {
+				explanation += fmt.Sprintf(" Its handlers run with the execution role %s, which lacks a permission "+
+					"the handler needs.", registryType.ExecutionRoleArn)
+			}
+			suggestion = "Grant the execution role the permissions listed in the handlers section of the type's " +
+				"schema, or re-register or re-activate the type with a role that has them."
+		}
+
+		if registryType.DeprecatedStatus == string(types.DeprecatedStatusDeprecated) {
+			explanation += " The type is deprecated; new operations on its resources may fail."
+		}
+		if newerVersion {
+			explanation += fmt.Sprintf(" Version %s is available.", registryType.LatestPublicVersion)
+		}
+
+		evidence := []string{
+			fmt.Sprintf("%s (%s) %s at %s: %s", stackErr.LogicalResourceId, stackErr.ResourceType,
+				stackErr.ResourceStatus, formatTimestamp(stackErr.Timestamp), stackErr.ResourceStatusReason),
+			fmt.Sprintf("Type version: %s (%s)", version, registryType.Arn),
+			fmt.Sprintf("Deprecation status: %s", valueOrUnknown(registryType.DeprecatedStatus)),
+		}
+		if registryType.ExecutionRoleArn != "" {
+			evidence = append(evidence, fmt.Sprintf("Execution role: %s", registryType.ExecutionRoleArn))
+		} else {
+			evidence = append(evidence, "Execution role: none")
+		}
+		if public {
+			evidence = append(evidence, fmt.Sprintf("Publisher: %s, latest version: %s, automatic updates: %t",
+				valueOrUnknown(registryType.PublisherId), valueOrUnknown(registryType.LatestPublicVersion), registryType.AutoUpdate))
+		}
+
+		findings = append(findings, analyzer.Finding{
+			Pattern:           PatternRegistryType,
+			LogicalResourceId: stackErr.LogicalResourceId,
+			Title:             title,
+			Explanation:       explanation,
+			Evidence:          evidence,
+			Suggestion:        suggestion,
+		})
+	}
+
+	return findings
+}
+
+// registryVersion returns the version of a type used by stack operations: the activated public
+// version of third-party types, or the default version of private types
+func registryVersion(registryType *analyzer.RegistryType) string {
+	if registryType.PublicVersionNumber != "" {
+		return registryType.PublicVersionNumber
+	}
+	return valueOrUnknown(registryType.DefaultVersionId)
+}
+
+// visibilityName describes whether a type is a public third-party or a private type
+func visibilityName(public bool) string {
+	if public {
+		return "third-party"
+	}
+	return "private"
+}
+
+// valueOrUnknown returns the value, or "unknown" if it is empty
+func valueOrUnknown(value string) string {
+	if value == "" {
+		return "unknown"
+	}
+	return value
+}
+
+// containsAny checks if the text contains any of the lowercase fragments, ignoring case
+func containsAny(text string, fragments []string) bool {
+	lower := strings.ToLower(text)
+	for _, fragment := range fragments {
+		if strings.Contains(lower, fragment) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,232 @@
+package patterns
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"cfn-root-cause/analyzer"
+)
+
+// PatternBucketNameConflict identifies findings about S3 buckets whose name is already taken
+const PatternBucketNameConflict = "bucket-name-conflict"
+
+// Owners of a taken bucket name
+const (
+	// BucketOwnerAccount is a bucket of the account of the stack
+	BucketOwnerAccount = "account"
+
+	// BucketOwnerOtherAccount is a bucket of another AWS account; bucket names are global
+	BucketOwnerOtherAccount = "other-account"
+
+	// BucketOwnerNone means the bucket no longer exists
+	BucketOwnerNone = "none"
+)
+
+// bucketExistsPattern recognizes failures of existing bucket names, e.g. "my-bucket already exists"
+// or "my-bucket already exists in stack arn:aws:cloudformation:..."; the groups are the bucket name
+// and the stack ARN
+var bucketExistsPattern = regexp.MustCompile(`([a-z0-9][a-z0-9.-]{1,61}[a-z0-9]) already exists(?: in stack (arn:\S+))?`)
+
+// bucketOwnedByYouPattern and bucketTakenPattern recognize the S3 error codes and messages that
+// tell who owns the bucket
+var (
+	bucketOwnedByYouPattern = regexp.MustCompile(`(?i)BucketAlreadyOwnedByYou|you already own it`)
+	bucketTakenPattern      = regexp.MustCompile(`(?i)BucketAlreadyExists\b|requested bucket name is not available`)
+)
+
+// BucketConflict is a bucket that failed because its name is already taken
+type BucketConflict struct {
+	Error analyzer.StackError
+
+	// BucketName is "" if neither the errors nor the CreateBucket call name it
+	BucketName string
+
+	// ConflictingStack is the name of the stack CloudFormation reports as managing the bucket, "" if
+	// it names none
+	ConflictingStack string
+
+	// Owner is the owner the S3 error implies, "" if it does not tell
+	Owner string
+}
+
+// BucketOwnership is the current owner of a taken bucket name
+type BucketOwnership struct {
+	Owner  string
+	Region string
+
+	// Stack and LogicalResourceId identify the stack managing a bucket of the account, "" if none does
+	Stack             string
+	LogicalResourceId string
+
+	// Creator is the principal that created an unmanaged bucket of the account, per CloudTrail
+	Creator string
+	Created time.Time
+}
+
+// BucketConflicts returns the S3 buckets that failed because a bucket of the name already exists,
+// with the bucket name from the errors or the failed CreateBucket call
+func BucketConflicts(errors []analyzer.CorrelatedError) []BucketConflict {
+	var conflicts []BucketConflict
+	for _, err := range errors {
+		if err.StackError.ResourceType != "AWS::S3::Bucket" {
+			continue
+		}
+		texts := []string{err.StackError.ResourceStatusReason, err.DetailedMessage}
+		if err.CloudTrailEvent != nil {
+			texts = append(texts, err.CloudTrailEvent.ErrorCode, err.CloudTrailEvent.ErrorMessage)
+		}
+
+		conflict := BucketConflict{Error: err.StackError}
+		matched := false
+		for _, text := range texts {
+			if match := bucketExistsPattern.FindStringSubmatch(text); match != nil {
+				matched = true
+				if conflict.BucketName == "" {
+					conflict.BucketName = match[1]
+				}
+				if conflict.ConflictingStack == "" && match[2] != "" {
+					conflict.ConflictingStack = stackNameOfArn(match[2])
+				}
+			}
+			switch {
+			case bucketOwnedByYouPattern.MatchString(text):
+				matched = true
+				conflict.Owner = BucketOwnerAccount
+			case bucketTakenPattern.MatchString(text) && conflict.Owner == "":
+				matched = true
+				conflict.Owner = BucketOwnerOtherAccount
+			}
+		}
+		if !matched {
+			continue
+		}
+
+		if conflict.BucketName == "" && err.CloudTrailEvent != nil {
+			conflict.BucketName = stringParameter(err.CloudTrailEvent.RequestParameters, "bucketName")
+		}
+		if conflict.ConflictingStack != "" {
+			conflict.Owner = BucketOwnerAccount
+		}
+		conflicts = append(conflicts, conflict)
+	}
+	return conflicts
+}
+
+// BucketCreation returns the principal and time of the latest successful CreateBucket call before
+// the failure; ok is false if the events contain none
+func BucketCreation(events []analyzer.CloudTrailEvent, before time.Time) (creator string, created time.Time, ok bool) {
+	for _, event := range events {
+		if event.EventName != "CreateBucket" || event.ErrorCode != "" || event.EventTime.After(before) {
+			continue
+		}
+		if ok && !event.EventTime.After(created) {
+			continue
+		}
+		creator, _ = event.UserIdentity["arn"].(string)
+		if creator == "" {
+			creator, _ = event.UserIdentity["invokedBy"].(string)
+		}
+		created, ok = event.EventTime, true
+	}
+	return creator, created, ok
+}
+
+// DescribeBucketConflict explains a bucket whose name is taken, by the account or globally by
+// another account; ownership is nil if the current owner could not be determined
+func DescribeBucketConflict(stackName string, conflict BucketConflict, ownership *BucketOwnership) analyzer.Finding {
+	stackErr := conflict.Error
+	name := conflict.BucketName
+	if name == "" {
+		name = "the bucket name"
+	}
+	evidence := []string{fmt.Sprintf("%s (%s) %s: %s", stackErr.LogicalResourceId, stackErr.ResourceType,
+		stackErr.ResourceStatus, stackErr.ResourceStatusReason)}
+
+	owner, stack, logicalId := conflict.Owner, conflict.ConflictingStack, ""
+	if ownership != nil {
+		owner = ownership.Owner
+		switch owner {
+		case BucketOwnerNone:
+			evidence = append(evidence, fmt.Sprintf("HeadBucket: %s does not exist (any longer)", name))
+		case BucketOwnerOtherAccount:
+			evidence = append(evidence, fmt.Sprintf("HeadBucket: %s exists, but not in the account of the stack", name))
+		default:
+			evidence = append(evidence, fmt.Sprintf("HeadBucket: %s exists in the account of the stack%s", name, inRegion(ownership.Region)))
+		}
+		if ownership.Stack != "" {
+			stack, logicalId = ownership.Stack, ownership.LogicalResourceId
+			evidence = append(evidence, fmt.Sprintf("%s is %s of stack %s", name, logicalId, stack))
+		}
+		if ownership.Creator != "" {
+			evidence = append(evidence, fmt.Sprintf("CloudTrail: CreateBucket by %s at %s", ownership.Creator,
+				formatTimestamp(ownership.Created)))
+		}
+	}
+
+	var explanation, suggestion string
+	switch {
+	case owner == BucketOwnerOtherAccount:
+		explanation = fmt.Sprintf("%s is taken globally by another AWS account. S3 bucket names are shared by all "+
+			"accounts, so this account cannot create a bucket of that name and no change in this account frees it.", name)
+		suggestion = "Choose another BucketName, for example by adding the account ID and region " +
+			"(!Sub 'name-${AWS::AccountId}-${AWS::Region}'), or omit BucketName and let CloudFormation generate a unique name."
+	case owner == BucketOwnerAccount && stack != "" && stack != stackName:
+		explanation = fmt.Sprintf("%s already exists in this account and is managed by stack %s, so %s cannot "+
+			"create it as well.", name, stack, stackName)
+		suggestion = fmt.Sprintf("Use a different BucketName in %s, or reference the bucket of %s (e.g. through an "+
+			"export) instead of creating it again.", stackName, stack)
+	case owner == BucketOwnerAccount && stack != "" && logicalId != "" && logicalId != stackErr.LogicalResourceId:
+		explanation = fmt.Sprintf("%s already exists in this account as %s of this stack, so %s cannot use the same "+
+			"name.", name, logicalId, stackErr.LogicalResourceId)
+		suggestion = fmt.Sprintf("Give %s a different BucketName.", stackErr.LogicalResourceId)
+	case owner == BucketOwnerAccount && stack != "":
+		explanation = fmt.Sprintf("%s already exists in this account as the current bucket of %s. A replacement of a "+
+			"bucket with a custom name fails, because the new bucket needs the name of the old one.", name,
+			stackErr.LogicalResourceId)
+		suggestion = "Change the BucketName together with the property that requires the replacement, or move the bucket " +
+			"to a new logical ID with a different name."
+	case owner == BucketOwnerAccount:
+		explanation = fmt.Sprintf("%s already exists in this account, but no stack in this region manages it. It was "+
+			"most likely retained by DeletionPolicy: Retain of a deleted stack, or created outside CloudFormation.", name)
+		suggestion = fmt.Sprintf("Import the existing bucket into %s with a change set of type IMPORT, delete it if it is "+
+			"no longer needed (aws s3 rb s3://%s --force), or choose another BucketName.", stackName, conflict.BucketName)
+	case owner == BucketOwnerNone:
+		explanation = fmt.Sprintf("%s was taken when the deployment ran but no longer exists, so it was deleted in the "+
+			"meantime.", name)
+		suggestion = "Retry the deployment. Names of deleted buckets can take a while until they can be used again, and " +
+			"another account may claim the name first."
+	default:
+		explanation = fmt.Sprintf("%s is already taken, either by a bucket of this account or globally by another "+
+			"account.", name)
+		suggestion = fmt.Sprintf("Check who owns it with 'aws s3api head-bucket --bucket %s': 200 means this account, "+
+			"403 another account, which requires a different BucketName.", conflict.BucketName)
+	}
+
+	return analyzer.Finding{
+		Pattern:           PatternBucketNameConflict,
+		LogicalResourceId: stackErr.LogicalResourceId,
+		Title:             "S3 bucket name already taken",
+		Explanation:       explanation,
+		Evidence:          evidence,
+		Suggestion:        suggestion,
+	}
+}
+
+// inRegion describes the region of a resource, "" if it is unknown
+func inRegion(region string) string {
+	if region == "" {
+		return ""
+	}
+	return " (" + region + ")"
+}
+
+// stackNameOfArn returns the stack name of a stack ARN like arn:aws:cloudformation:...:stack/<name>/<id>
+func stackNameOfArn(stackArn string) string {
+	parts := strings.Split(stackArn, "/")
+	if len(parts) < 2 {
+		return stackArn
+	}
+	return parts[1]
+}
@@ -0,0 +1,196 @@
+package patterns
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"cfn-root-cause/analyzer"
+
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation/types"
+)
+
+// PatternStabilization identifies findings about resources that did not stabilize after
+// CloudFormation created or updated them
+const PatternStabilization = "stabilization"
+
+// stabilizationPattern recognizes failure reasons of resources CloudFormation waited for in vain
+var stabilizationPattern = regexp.MustCompile(`(?i)did not stabilize|failed to stabilize|stabilization|exceeded attempts to wait|resource is not in the state`)
+
+// statusPropertyPattern recognizes properties that describe the state of a resource, e.g.
+// Status, DBInstanceStatus, State or StatusReason
+var statusPropertyPattern = regexp.MustCompile(`(?i)(status|state)(reason|message|code|name)?$`)
+
+// Kinds of resource states, recognized by fragments of their values
+var (
+	failedStates  = []string{"fail", "error", "impaired", "unhealthy", "incompatible", "inaccessible", "deleted"}
+	pendingStates = []string{"pending", "creating", "updating", "modifying", "provisioning", "progress", "starting",
+		"initializing", "configuring", "deploying", "backing-up"}
+)
+
+// maxStatusProperties limits the number of state properties shown as evidence
+const maxStatusProperties = 8
+
+// IsStabilizationFailure reports whether a resource failed because it did not reach a stable state
+// in time; custom resources, which never stabilize on their own, are left to their own pattern
+func IsStabilizationFailure(stackErr analyzer.StackError) bool {
+	if stackErr.ResourceType == "AWS::CloudFormation::CustomResource" || strings.HasPrefix(stackErr.ResourceType, "Custom::") {
+		return false
+	}
+	return stabilizationPattern.MatchString(stackErr.ResourceStatusReason)
+}
+
+// ResourceTransitions returns the stack events of a resource from the start of the operation that
+// failed to the failure, the oldest first
+func ResourceTransitions(events []types.StackEvent, stackErr analyzer.StackError) []types.StackEvent {
+	var resourceEvents []types.StackEvent
+	for _, event := range events {
+		if safeString(event.LogicalResourceId) == stackErr.LogicalResourceId && !safeTime(event.Timestamp).After(stackErr.Timestamp) {
+			resourceEvents = append(resourceEvents, event)
+		}
+	}
+	sort.SliceStable(resourceEvents, func(i, j int) bool {
+		return safeTime(resourceEvents[i].Timestamp).Before(safeTime(resourceEvents[j].Timestamp))
+	})
+
+	start := len(resourceEvents) - 1
+	for start > 0 && strings.HasSuffix(string(resourceEvents[start-1].ResourceStatus), "_IN_PROGRESS") {
+		start--
+	}
+	if start < 0 {
+		return nil
+	}
+	return resourceEvents[start:]
+}
+
+// StatusProperties returns the properties of a resource state that describe its status, as
+// "name=value" sorted by name; properties nested in a status property are named by their path,
+// e.g. State.Name
+func StatusProperties(properties map[string]interface{}) []string {
+	var found []string
+	var collect func(prefix string, values map[string]interface{}, parentMatches bool, depth int)
+	collect = func(prefix string, values map[string]interface{}, parentMatches bool, depth int) {
+		for name, value := range values {
+			matches := statusPropertyPattern.MatchString(name)
+			switch v := value.(type) {
+			case string:
+				if (matches || parentMatches) && v != "" {
+					found = append(found, fmt.Sprintf("%s%s=%s", prefix, name, v))
+				}
+			case map[string]interface{}:
+				if depth < 2 {
+					collect(prefix+name+".", v, matches, depth+1)
+				}
+			}
+		}
+	}
+	collect("", properties, false, 0)
+	sort.Strings(found)
+	return found
+}
+
+// stateKind classifies status properties as "failed", "pending" or "ready"; "" if there are none
+func stateKind(statuses []string) string {
+	kind := ""
+	for _, status := range statuses {
+		name, value, _ := strings.Cut(status, "=")
+		if strings.HasSuffix(strings.ToLower(name), "reason") || strings.HasSuffix(strings.ToLower(name), "message") {
+			continue
+		}
+		if kind == "" {
+			kind = "ready"
+		}
+		value = strings.ToLower(value)
+		for _, fragment := range failedStates {
+			if strings.Contains(value, fragment) {
+				return "failed"
+			}
+		}
+		for _, fragment := range pendingStates {
+			if strings.Contains(value, fragment) {
+				kind = "pending"
+			}
+		}
+	}
+	return kind
+}
+
+// DescribeStabilization explains a resource that did not stabilize with its stack events and its
+// current state in the service. state is nil if the resource no longer exists; stateRead is
+// false if the state could not be read at all.
+func DescribeStabilization(stackErr analyzer.StackError, transitions []types.StackEvent, state *analyzer.ResourceState, stateRead bool) analyzer.Finding {
+	logicalId := stackErr.LogicalResourceId
+	evidence := []string{fmt.Sprintf("%s (%s) %s: %s", logicalId, stackErr.ResourceType,
+		stackErr.ResourceStatus, stackErr.ResourceStatusReason)}
+
+	waited := time.Duration(0)
+	if len(transitions) > 1 {
+		var steps []string
+		for _, event := range transitions {
+			steps = append(steps, fmt.Sprintf("%s %s", string(event.ResourceStatus), safeTime(event.Timestamp).Format("15:04:05")))
+		}
+		waited = safeTime(transitions[len(transitions)-1].Timestamp).Sub(safeTime(transitions[0].Timestamp))
+		evidence = append(evidence, fmt.Sprintf("Stack events: %s (%s)", strings.Join(steps, " -> "), waited.Round(time.Second)))
+	}
+
+	var statuses []string
+	if state != nil {
+		statuses = StatusProperties(state.Properties)
+		shown := statuses
+		if len(shown) > maxStatusProperties {
+			shown = shown[:maxStatusProperties]
+		}
+		if len(shown) > 0 {
+			evidence = append(evidence, fmt.Sprintf("Current state of %s: %s", state.Identifier, strings.Join(shown, ", ")))
+		}
+	}
+
+	waitedFor := ""
+	if waited > 0 {
+		waitedFor = fmt.Sprintf(" for %s", waited.Round(time.Second))
+	}
+	var explanation, suggestion string
+	switch kind := stateKind(statuses); {
+	case !stateRead:
+		explanation = fmt.Sprintf("CloudFormation waited%s for %s to reach a stable state, which it did not. The "+
+			"current state of the resource could not be read through the Cloud Control API.", waitedFor, logicalId)
+		suggestion = fmt.Sprintf("Check the state and the events of the resource in the console of its service, e.g. with "+
+			"'aws cloudcontrol get-resource --type-name %s --identifier <physical ID>'.", stackErr.ResourceType)
+	case state == nil:
+		explanation = fmt.Sprintf("CloudFormation waited%s for %s to reach a stable state, which it did not. The "+
+			"resource no longer exists, most likely deleted by the rollback, so its final state is unknown.", waitedFor, logicalId)
+		suggestion = "Deploy again and watch the resource in the console of its service while CloudFormation waits, or " +
+			"disable rollback (--disable-rollback) to keep it for inspection."
+	case kind == "failed":
+		explanation = fmt.Sprintf("%s itself failed in its service, so it could never stabilize: %s.", logicalId,
+			strings.Join(statuses, ", "))
+		suggestion = "Fix the cause the service reports in the state of the resource; it is usually in a status reason " +
+			"or message property, or in the events of the service."
+	case kind == "pending":
+		explanation = fmt.Sprintf("%s is still transitioning (%s), so its service takes longer than CloudFormation "+
+			"waited%s. Slow operations such as large restores, replication or dependencies that are not ready keep it "+
+			"pending.", logicalId, strings.Join(statuses, ", "), waitedFor)
+		suggestion = "Check what the resource waits for in the console of its service, for example capacity, a " +
+			"dependency or network access it needs, and deploy again once the cause is resolved."
+	case kind == "ready":
+		explanation = fmt.Sprintf("%s is in a stable state now (%s), so it became ready only after CloudFormation "+
+			"stopped waiting%s.", logicalId, strings.Join(statuses, ", "), waitedFor)
+		suggestion = "Deploy again; if it keeps happening, look for what slows the resource down during the deployment, " +
+			"such as its size or dependencies that become ready late."
+	default:
+		explanation = fmt.Sprintf("CloudFormation waited%s for %s to reach a stable state, which it did not. The "+
+			"resource still exists, but its properties report no status.", waitedFor, logicalId)
+		suggestion = "Check the events and health of the resource in the console of its service."
+	}
+
+	return analyzer.Finding{
+		Pattern:           PatternStabilization,
+		LogicalResourceId: logicalId,
+		Title:             "Resource did not stabilize",
+		Explanation:       explanation,
+		Evidence:          evidence,
+		Suggestion:        suggestion,
+	}
+}
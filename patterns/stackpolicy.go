@@ -0,0 +1,219 @@
+package patterns
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"cfn-root-cause/analyzer"
+)
+
+// Patterns of findings about stack protection
+const (
+	// PatternStackPolicy identifies findings about updates denied by the stack policy
+	PatternStackPolicy = "stack-policy"
+
+	// PatternTerminationProtection identifies findings about deletions blocked by termination protection
+	PatternTerminationProtection = "termination-protection"
+)
+
+// stackPolicyPattern recognizes updates denied by the stack policy
+var stackPolicyPattern = regexp.MustCompile(`(?i)denied by (?:the )?stack policy`)
+
+// policyStatementPattern extracts the number of the denying statement, e.g. "Statement [#2]"
+var policyStatementPattern = regexp.MustCompile(`(?i)statement \[?#?(\d+)\]?`)
+
+// policyActionPattern extracts the denied update action, e.g. "Update:Replace"
+var policyActionPattern = regexp.MustCompile(`Update:(?:Modify|Replace|Delete|\*)`)
+
+// terminationProtectionPattern recognizes deletions of stacks with termination protection, e.g.
+// "Stack [arn:...] cannot be deleted while TerminationProtection is enabled"
+var terminationProtectionPattern = regexp.MustCompile(`(?i)(?:stack \[?([^\]\s]+)\]? )?cannot be deleted while terminationprotection is enabled`)
+
+// StackPolicyDenial is an update of a resource the stack policy denied
+type StackPolicyDenial struct {
+	// Statement is the 1-based number of the denying statement, 0 if the reason does not name it
+	Statement int
+
+	// Action is the denied update action, e.g. Update:Replace; "" if the reason does not name it
+	Action string
+}
+
+// PolicyStatement is a statement of a stack policy
+type PolicyStatement struct {
+	Effect   string
+	Action   []string
+	Resource []string
+
+	// JSON is the statement as written in the policy, compacted
+	JSON string
+}
+
+// StackPolicyViolation returns the denial a failure reason reports
+func StackPolicyViolation(reason string) (StackPolicyDenial, bool) {
+	if !stackPolicyPattern.MatchString(reason) {
+		return StackPolicyDenial{}, false
+	}
+
+	var denial StackPolicyDenial
+	if match := policyStatementPattern.FindStringSubmatch(reason); match != nil {
+		denial.Statement, _ = strconv.Atoi(match[1])
+	}
+	denial.Action = policyActionPattern.FindString(reason)
+	return denial, true
+}
+
+// ParseStackPolicy parses the statements of a stack policy body. Action and Resource may be given
+// as a string or a list.
+func ParseStackPolicy(body string) ([]PolicyStatement, error) {
+	var policy struct {
+		Statement []json.RawMessage `json:"Statement"`
+	}
+	if err := json.Unmarshal([]byte(body), &policy); err != nil {
+		return nil, fmt.Errorf("failed to parse stack policy: %w", err)
+	}
+
+	statements := make([]PolicyStatement, 0, len(policy.Statement))
+	for _, raw := range policy.Statement {
+		var statement struct {
+			Effect   string       `json:"Effect"`
+			Action   stringOrList `json:"Action"`
+			Resource stringOrList `json:"Resource"`
+		}
+		if err := json.Unmarshal(raw, &statement); err != nil {
+			return nil, fmt.Errorf("failed to parse stack policy statement: %w", err)
+		}
+		var compacted bytes.Buffer
+		if err := json.Compact(&compacted, raw); err != nil {
+			return nil, fmt.Errorf("failed to parse stack policy statement: %w", err)
+		}
+		statements = append(statements, PolicyStatement{
+			Effect:   statement.Effect,
+			Action:   statement.Action,
+			Resource: statement.Resource,
+			JSON:     compacted.String(),
+		})
+	}
+	return statements, nil
+}
+
+// stringOrList decodes a JSON string or list of strings
+type stringOrList []string
+
+// UnmarshalJSON decodes a single string as a list of one element
+func (l *stringOrList) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*l = []string{single}
+		return nil
+	}
+	var list []string
+	if err := json.Unmarshal(data, &list); err != nil {
+		return err
+	}
+	*l = list
+	return nil
+}
+
+// DenyingStatements returns the 1-based numbers of the Deny statements that apply to an update of
+// the resource: the statement the denial names, or all Deny statements covering the resource
+func DenyingStatements(statements []PolicyStatement, logicalId string, denial StackPolicyDenial) []int {
+	if denial.Statement > 0 && denial.Statement <= len(statements) {
+		return []int{denial.Statement}
+	}
+
+	var numbers []int
+	for i, statement := range statements {
+		if !strings.EqualFold(statement.Effect, "Deny") || !coversResource(statement.Resource, logicalId) {
+			continue
+		}
+		if denial.Action != "" && len(statement.Action) > 0 && !matchesAny(statement.Action, denial.Action) {
+			continue
+		}
+		numbers = append(numbers, i+1)
+	}
+	return numbers
+}
+
+// coversResource reports whether one of the policy resources, such as "LogicalResourceId/Database*"
+// or "*", covers the logical resource
+func coversResource(resources []string, logicalId string) bool {
+	return matchesAny(resources, "LogicalResourceId/"+logicalId)
+}
+
+// matchesAny reports whether one of the wildcard patterns matches the value
+func matchesAny(patterns []string, value string) bool {
+	for _, pattern := range patterns {
+		if pattern == "*" {
+			return true
+		}
+		if matched, err := path.Match(pattern, value); err == nil && matched {
+			return true
+		}
+	}
+	return false
+}
+
+// DescribeStackPolicyDenial explains an update the stack policy denied, with the denying statements
+// of the current policy; statements is nil if the policy could not be retrieved
+func DescribeStackPolicyDenial(stackName string, err analyzer.StackError, denial StackPolicyDenial, statements []PolicyStatement) analyzer.Finding {
+	logicalId := err.LogicalResourceId
+	evidence := []string{fmt.Sprintf("%s (%s) %s: %s", logicalId, err.ResourceType, err.ResourceStatus, err.ResourceStatusReason)}
+	for _, number := range DenyingStatements(statements, logicalId, denial) {
+		evidence = append(evidence, fmt.Sprintf("Stack policy statement #%d: %s", number, statements[number-1].JSON))
+	}
+
+	action := denial.Action
+	if action == "" {
+		action = "Update:*"
+	}
+	explanation := fmt.Sprintf("The stack policy of %s protects %s, so CloudFormation refused the %s update of the "+
+		"resource and rolled back the deployment.", stackName, logicalId, action)
+	override := fmt.Sprintf(`{"Statement":[{"Effect":"Allow","Action":"%s","Principal":"*","Resource":"LogicalResourceId/%s"}]}`,
+		action, logicalId)
+	suggestion := fmt.Sprintf("If the change is intended, allow it for this deployment only: aws cloudformation update-stack "+
+		"--stack-name %s ... --stack-policy-during-update-body '%s'. To allow it permanently, add that statement to the stack "+
+		"policy with aws cloudformation set-stack-policy, or narrow the Resource of the denying statement.", stackName, override)
+	if action == "Update:Replace" || action == "Update:Delete" {
+		suggestion += " Review the change first: replacing or deleting a protected resource usually loses its data."
+	}
+
+	return analyzer.Finding{
+		Pattern:           PatternStackPolicy,
+		LogicalResourceId: logicalId,
+		Title:             "Update denied by stack policy",
+		Explanation:       explanation,
+		Evidence:          evidence,
+		Suggestion:        suggestion,
+	}
+}
+
+// TerminationProtectedStack returns the stack a failure reason reports as protected from deletion,
+// by name or ARN; it is "" if the reason does not name it
+func TerminationProtectedStack(reason string) (string, bool) {
+	match := terminationProtectionPattern.FindStringSubmatch(reason)
+	if match == nil {
+		return "", false
+	}
+	return match[1], true
+}
+
+// DescribeTerminationProtection explains a deletion of the protected stack blocked by termination protection
+func DescribeTerminationProtection(err analyzer.StackError, protectedStack string) analyzer.Finding {
+	return analyzer.Finding{
+		Pattern:           PatternTerminationProtection,
+		LogicalResourceId: err.LogicalResourceId,
+		Title:             "Deletion blocked by termination protection",
+		Explanation: fmt.Sprintf("Termination protection is enabled on %s, so CloudFormation refused to delete it. "+
+			"Nested stacks inherit the protection of their root stack.", protectedStack),
+		Evidence: []string{fmt.Sprintf("%s (%s) %s: %s", err.LogicalResourceId, err.ResourceType, err.ResourceStatus,
+			err.ResourceStatusReason)},
+		Suggestion: fmt.Sprintf("If the deletion is intended, disable the protection of the root stack and retry: "+
+			"aws cloudformation update-termination-protection --no-enable-termination-protection --stack-name %s",
+			protectedStack),
+	}
+}
@@ -0,0 +1,227 @@
+package patterns
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"cfn-root-cause/analyzer"
+
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation/types"
+)
+
+// PatternStuckResource identifies findings about resources that have been in progress far longer
+// than usual
+const PatternStuckResource = "stuck-resource"
+
+// DefaultStuckFactor is how many times its usual duration a resource may be in progress before
+// it is flagged as likely hung
+const DefaultStuckFactor = 3
+
+// defaultTypicalDuration is the usual duration of resources of types without a published baseline
+const defaultTypicalDuration = 5 * time.Minute
+
+// typicalDurations are usual create and update durations of slow resource types, taken from the
+// AWS documentation and experience; other types usually take less than defaultTypicalDuration
+var typicalDurations = map[string]time.Duration{
+	"AWS::CloudFront::Distribution":                         15 * time.Minute,
+	"AWS::RDS::DBInstance":                                  20 * time.Minute,
+	"AWS::RDS::DBCluster":                                   15 * time.Minute,
+	"AWS::ElastiCache::ReplicationGroup":                    20 * time.Minute,
+	"AWS::ElastiCache::CacheCluster":                        15 * time.Minute,
+	"AWS::OpenSearchService::Domain":                        30 * time.Minute,
+	"AWS::Elasticsearch::Domain":                            30 * time.Minute,
+	"AWS::EKS::Cluster":                                     15 * time.Minute,
+	"AWS::EKS::Nodegroup":                                   10 * time.Minute,
+	"AWS::MSK::Cluster":                                     30 * time.Minute,
+	"AWS::Redshift::Cluster":                                15 * time.Minute,
+	"AWS::DirectoryService::MicrosoftAD":                    40 * time.Minute,
+	"AWS::DocDB::DBCluster":                                 15 * time.Minute,
+	"AWS::Neptune::DBCluster":                               15 * time.Minute,
+	"AWS::EC2::TransitGatewayAttachment":                    10 * time.Minute,
+	"AWS::CertificateManager::Certificate":                  10 * time.Minute,
+	"AWS::ECS::Service":                                     10 * time.Minute,
+	"AWS::AutoScaling::AutoScalingGroup":                    10 * time.Minute,
+	"AWS::ElasticLoadBalancingV2::LoadBalancer":             5 * time.Minute,
+	"AWS::GlobalAccelerator::Accelerator":                   10 * time.Minute,
+	"AWS::SageMaker::Endpoint":                              15 * time.Minute,
+	"AWS::AmazonMQ::Broker":                                 20 * time.Minute,
+	"AWS::ApiGateway::DomainName":                           10 * time.Minute,
+	"AWS::AppSync::DomainName":                              10 * time.Minute,
+	"AWS::CloudFormation::Stack":                            30 * time.Minute,
+	"AWS::ServiceCatalog::CloudFormationProvisionedProduct": 30 * time.Minute,
+}
+
+// Sources of the usual duration of a resource
+const (
+	// DurationFromHistory is the longest duration of the resource in earlier operations of the stack
+	DurationFromHistory = "earlier deployments of the stack"
+
+	// DurationFromBaseline is the published usual duration of the resource type
+	DurationFromBaseline = "usual duration of the type"
+)
+
+// StuckResource is a resource that is in progress in the current operation of a stack
+type StuckResource struct {
+	LogicalResourceId string
+	ResourceType      string
+	Status            string
+	Since             time.Time
+	Elapsed           time.Duration
+
+	// Expected is the usual duration of the resource and ExpectedSource where it comes from
+	Expected       time.Duration
+	ExpectedSource string
+
+	// Hung is true if the resource has been in progress more than the stuck factor times Expected
+	Hung bool
+}
+
+// PastDurations returns the durations of the completed operations of each resource in the stack
+// events, by logical ID; operations that failed are not counted
+func PastDurations(events []types.StackEvent) map[string][]time.Duration {
+	return completedDurations(events, func(event types.StackEvent) string { return safeString(event.LogicalResourceId) })
+}
+
+// completedDurations returns the durations of the completed resource operations in the stack
+// events, grouped by key
+func completedDurations(events []types.StackEvent, key func(types.StackEvent) string) map[string][]time.Duration {
+	sorted := sortedOldestFirst(events)
+	started := make(map[string]time.Time)
+	durations := make(map[string][]time.Duration)
+	for _, event := range sorted {
+		logicalId := safeString(event.LogicalResourceId)
+		status := string(event.ResourceStatus)
+		switch {
+		case strings.HasSuffix(status, "_IN_PROGRESS"):
+			if _, ok := started[logicalId]; !ok {
+				started[logicalId] = safeTime(event.Timestamp)
+			}
+		case strings.HasSuffix(status, "_COMPLETE") && !strings.Contains(status, "ROLLBACK"):
+			if start, ok := started[logicalId]; ok {
+				durations[key(event)] = append(durations[key(event)], safeTime(event.Timestamp).Sub(start))
+			}
+			delete(started, logicalId)
+		default:
+			delete(started, logicalId)
+		}
+	}
+	return durations
+}
+
+// InProgressResources returns the resources whose latest event in the operation events is in
+// progress, with how long they have been in progress at now and their usual duration, the
+// longest first. The stack itself is not included. past are the durations of earlier operations
+// by logical ID, as returned by PastDurations; factor is the stuck factor.
+func InProgressResources(operationEvents []types.StackEvent, past map[string][]time.Duration, now time.Time, factor float64) []StuckResource {
+	sorted := sortedOldestFirst(operationEvents)
+	latest := make(map[string]types.StackEvent)
+	since := make(map[string]time.Time)
+	for _, event := range sorted {
+		logicalId := safeString(event.LogicalResourceId)
+		if safeString(event.PhysicalResourceId) == safeString(event.StackId) && safeString(event.ResourceType) == "AWS::CloudFormation::Stack" {
+			continue
+		}
+		previous, seen := latest[logicalId]
+		if !seen || previous.ResourceStatus != event.ResourceStatus {
+			since[logicalId] = safeTime(event.Timestamp)
+		}
+		latest[logicalId] = event
+	}
+
+	var resources []StuckResource
+	for logicalId, event := range latest {
+		if !strings.HasSuffix(string(event.ResourceStatus), "_IN_PROGRESS") {
+			continue
+		}
+		resource := StuckResource{
+			LogicalResourceId: logicalId,
+			ResourceType:      safeString(event.ResourceType),
+			Status:            string(event.ResourceStatus),
+			Since:             since[logicalId],
+			Elapsed:           now.Sub(since[logicalId]),
+		}
+		resource.Expected, resource.ExpectedSource = ExpectedDuration(resource.ResourceType, past[logicalId])
+		resource.Hung = resource.Elapsed > time.Duration(factor*float64(resource.Expected))
+		resources = append(resources, resource)
+	}
+	sort.Slice(resources, func(i, j int) bool {
+		if resources[i].Elapsed != resources[j].Elapsed {
+			return resources[i].Elapsed > resources[j].Elapsed
+		}
+		return resources[i].LogicalResourceId < resources[j].LogicalResourceId
+	})
+	return resources
+}
+
+// ExpectedDuration returns the usual duration of a resource: the longest of its earlier
+// operations, or the published usual duration of its type if it has none
+func ExpectedDuration(resourceType string, past []time.Duration) (time.Duration, string) {
+	longest := time.Duration(0)
+	for _, duration := range past {
+		longest = max(longest, duration)
+	}
+	if longest > 0 {
+		// Fast resources vary a lot relative to their duration
+		return max(longest, time.Minute), DurationFromHistory
+	}
+	if duration, ok := typicalDurations[resourceType]; ok {
+		return duration, DurationFromBaseline
+	}
+	return defaultTypicalDuration, DurationFromBaseline
+}
+
+// DescribeStuckResource explains a resource that is likely hung, with guidance depending on the
+// operation of the stack: updates can be cancelled, creations and rollbacks cannot
+func DescribeStuckResource(stackName, stackStatus string, resource StuckResource) analyzer.Finding {
+	evidence := []string{
+		fmt.Sprintf("%s (%s) %s since %s (%s)", resource.LogicalResourceId, resource.ResourceType, resource.Status,
+			formatTimestamp(resource.Since), resource.Elapsed.Round(time.Second)),
+		fmt.Sprintf("Usually takes up to %s (%s)", resource.Expected.Round(time.Second), resource.ExpectedSource),
+	}
+	explanation := fmt.Sprintf("%s has been %s for %s, %.0f times as long as it usually takes, so it is most "+
+		"likely hung waiting for something that will not happen.", resource.LogicalResourceId, resource.Status,
+		resource.Elapsed.Round(time.Minute), float64(resource.Elapsed)/float64(resource.Expected))
+
+	var suggestions []string
+	if resource.ResourceType == "AWS::CloudFormation::CustomResource" || strings.HasPrefix(resource.ResourceType, "Custom::") {
+		suggestions = append(suggestions, "A custom resource waits for a response until it times out after an hour; "+
+			"send a FAILED response to its ResponseURL (from the request in the logs of the function) to end the wait.")
+	}
+	switch stackStatus {
+	case string(types.StackStatusUpdateInProgress):
+		suggestions = append(suggestions, fmt.Sprintf("Cancel the update with 'aws cloudformation cancel-update-stack "+
+			"--stack-name %s'; CloudFormation then rolls the stack back to its previous state.", stackName))
+	case string(types.StackStatusCreateInProgress):
+		suggestions = append(suggestions, "A stack creation cannot be cancelled; it fails when the resource times out, "+
+			"or delete the stack to stop it. Set TimeoutInMinutes (--timeout-in-minutes) on future creations to fail sooner.")
+	case string(types.StackStatusUpdateRollbackInProgress):
+		suggestions = append(suggestions, "A rollback cannot be cancelled; once it fails, continue it with "+
+			"'aws cloudformation continue-update-rollback --resources-to-skip' for the resources that do not stabilize.")
+	case string(types.StackStatusRollbackInProgress):
+		suggestions = append(suggestions, "A rollback cannot be cancelled; once it fails, delete the stack and "+
+			"retain the resources that do not stabilize.")
+	default:
+		suggestions = append(suggestions, "Wait for the operation to time out, then review the stack events.")
+	}
+	suggestions = append(suggestions, "Check the resource in the console of its service for what it is waiting for.")
+
+	return analyzer.Finding{
+		Pattern:           PatternStuckResource,
+		LogicalResourceId: resource.LogicalResourceId,
+		Title:             "Resource likely hung",
+		Explanation:       explanation,
+		Evidence:          evidence,
+		Suggestion:        strings.Join(suggestions, " "),
+	}
+}
+
+// sortedOldestFirst returns a copy of the stack events ordered by time, the oldest first
+func sortedOldestFirst(events []types.StackEvent) []types.StackEvent {
+	sorted := append([]types.StackEvent(nil), events...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return safeTime(sorted[i].Timestamp).Before(safeTime(sorted[j].Timestamp))
+	})
+	return sorted
+}
@@ -0,0 +1,101 @@
+package patterns
+
+import (
+	"fmt"
+	"regexp"
+
+	"cfn-root-cause/analyzer"
+)
+
+// PatternVPCLimit identifies findings about VPC resources exceeding a quota of the account
+const PatternVPCLimit = "vpc-limit"
+
+// vpcLimits recognize the VPC quota failures by their EC2 error code, also when only the message
+// of the error is given, e.g. "The maximum number of addresses has been reached."
+var vpcLimits = []struct {
+	code    string
+	message *regexp.Regexp
+}{
+	{"AddressLimitExceeded", regexp.MustCompile(`(?i)AddressLimitExceeded|maximum number of addresses has been reached`)},
+	{"NatGatewayLimitExceeded", regexp.MustCompile(`(?i)NatGatewayLimitExceeded|limit of \d+ NAT gateways`)},
+	{"VpcLimitExceeded", regexp.MustCompile(`(?i)VpcLimitExceeded|maximum number of VPCs has been reached`)},
+	{"InternetGatewayLimitExceeded", regexp.MustCompile(`(?i)InternetGatewayLimitExceeded|maximum number of internet gateways has been reached`)},
+	{"NetworkInterfaceLimitExceeded", regexp.MustCompile(`(?i)NetworkInterfaceLimitExceeded|maximum number of network interfaces has been reached`)},
+}
+
+// VPCLimitFailure is a resource that failed because a quota of VPC resources is reached
+type VPCLimitFailure struct {
+	Error analyzer.StackError
+
+	// Code is the EC2 error code of the quota, e.g. AddressLimitExceeded
+	Code string
+}
+
+// VPCLimitFailures returns the failures caused by a reached quota of Elastic IP addresses, NAT
+// gateways, VPCs, internet gateways or network interfaces
+func VPCLimitFailures(errors []analyzer.CorrelatedError) []VPCLimitFailure {
+	var failures []VPCLimitFailure
+	for _, err := range errors {
+		texts := []string{err.StackError.ResourceStatusReason, err.DetailedMessage}
+		if err.CloudTrailEvent != nil {
+			texts = append(texts, err.CloudTrailEvent.ErrorCode, err.CloudTrailEvent.ErrorMessage)
+		}
+		if code := vpcLimitCode(texts); code != "" {
+			failures = append(failures, VPCLimitFailure{Error: err.StackError, Code: code})
+		}
+	}
+	return failures
+}
+
+// vpcLimitCode returns the error code of the VPC quota the texts report as reached, "" if none
+func vpcLimitCode(texts []string) string {
+	for _, limit := range vpcLimits {
+		for _, text := range texts {
+			if limit.message.MatchString(text) {
+				return limit.code
+			}
+		}
+	}
+	return ""
+}
+
+// DescribeVPCLimit explains a failure on a reached VPC quota with the resources in use and the
+// quota of the account; usage is nil if they could not be read
+func DescribeVPCLimit(failure VPCLimitFailure, usage *analyzer.ResourceUsage) analyzer.Finding {
+	stackErr := failure.Error
+	evidence := []string{fmt.Sprintf("%s (%s) %s: %s", stackErr.LogicalResourceId, stackErr.ResourceType,
+		stackErr.ResourceStatus, stackErr.ResourceStatusReason)}
+
+	explanation := fmt.Sprintf("%s failed because the account reached a VPC quota (%s).", stackErr.LogicalResourceId,
+		failure.Code)
+	suggestion := "Release resources of the kind that are no longer used, or request a quota increase in Service Quotas."
+	if usage != nil {
+		quota := fmt.Sprintf("%d", usage.Quota)
+		if usage.DefaultQuota {
+			quota += " (AWS default; the quota of the account could not be read)"
+		}
+		evidence = append(evidence, fmt.Sprintf("You have %d/%s %s in %s", usage.Used, quota, usage.Resource, usage.Scope))
+
+		explanation = fmt.Sprintf("%s failed because the account reached its quota of %s: %d of %d in %s are in use.",
+			stackErr.LogicalResourceId, usage.Resource, usage.Used, usage.Quota, usage.Scope)
+		if usage.Used < usage.Quota {
+			explanation += " Some were released since the failure, or were being created in parallel when it happened."
+		}
+		suggestion = fmt.Sprintf("Release %s that are no longer used, or request an increase of the quota in Service "+
+			"Quotas (aws service-quotas request-service-quota-increase --service-code %s --quota-code %s --desired-value %d).",
+			usage.Resource, usage.ServiceCode, usage.QuotaCode, usage.Quota*2)
+		if failure.Code == "AddressLimitExceeded" {
+			suggestion += " Unassociated Elastic IP addresses are listed by 'aws ec2 describe-addresses " +
+				"--query \"Addresses[?AssociationId==null]\"'."
+		}
+	}
+
+	return analyzer.Finding{
+		Pattern:           PatternVPCLimit,
+		LogicalResourceId: stackErr.LogicalResourceId,
+		Title:             "VPC quota reached",
+		Explanation:       explanation,
+		Evidence:          evidence,
+		Suggestion:        suggestion,
+	}
+}
@@ -0,0 +1,227 @@
+// Package plugins runs enricher plugins: executables that receive a correlated error as JSON on
+// stdin and print enrichment JSON on stdout, so teams can add proprietary lookups such as CMDB
+// owners or internal runbooks without changing the analyzer.
+package plugins
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"cfn-root-cause/analyzer"
+	"cfn-root-cause/classify"
+)
+
+// APIVersion is the version of the request and response format, passed in the request and as
+// CFNRC_PLUGIN_API_VERSION, so plugins can reject formats they do not understand
+const APIVersion = "1"
+
+// PatternPrefix prefixes the pattern of findings returned by plugins, e.g. "plugin:cmdb-owner"
+const PatternPrefix = "plugin:"
+
+// runTimeout limits how long a plugin may take for one error
+const runTimeout = 10 * time.Second
+
+// maxStderrLength limits the stderr output of a failed plugin quoted in the error
+const maxStderrLength = 512
+
+// dirName is the plugins directory inside the config directory
+const dirName = "plugins"
+
+// Plugin is an executable enricher
+type Plugin struct {
+	Name string
+	Path string
+}
+
+// Request is the JSON document a plugin receives on stdin
+type Request struct {
+	APIVersion string       `json:"apiVersion"`
+	StackName  string       `json:"stackName"`
+	StackId    string       `json:"stackId,omitempty"`
+	Error      RequestError `json:"error"`
+}
+
+// RequestError is the correlated error a plugin enriches
+type RequestError struct {
+	Timestamp            time.Time          `json:"timestamp"`
+	LogicalResourceId    string             `json:"logicalResourceId"`
+	ResourceType         string             `json:"resourceType"`
+	ResourceStatus       string             `json:"resourceStatus"`
+	ResourceStatusReason string             `json:"resourceStatusReason"`
+	EventId              string             `json:"eventId"`
+	Category             string             `json:"category"`
+	ErrorCode            string             `json:"errorCode,omitempty"`
+	RequestID            string             `json:"requestId,omitempty"`
+	DetailedMessage      string             `json:"detailedMessage,omitempty"`
+	CloudTrail           *RequestCloudTrail `json:"cloudTrail,omitempty"`
+}
+
+// RequestCloudTrail is the CloudTrail event correlated with the error, including the identity
+// that made the failed call
+type RequestCloudTrail struct {
+	EventTime    time.Time              `json:"eventTime"`
+	EventName    string                 `json:"eventName"`
+	EventSource  string                 `json:"eventSource"`
+	ErrorCode    string                 `json:"errorCode,omitempty"`
+	ErrorMessage string                 `json:"errorMessage,omitempty"`
+	UserIdentity map[string]interface{} `json:"userIdentity,omitempty"`
+}
+
+// Response is the JSON document a plugin prints on stdout. Empty output means no enrichment.
+type Response struct {
+	Findings []ResponseFinding `json:"findings"`
+}
+
+// ResponseFinding is a finding added by a plugin to the error it enriched
+type ResponseFinding struct {
+	Title       string   `json:"title"`
+	Explanation string   `json:"explanation"`
+	Evidence    []string `json:"evidence"`
+	Suggestion  string   `json:"suggestion"`
+}
+
+// DefaultDir returns the default plugins directory, e.g. ~/.config/cfnrc/plugins on Linux
+func DefaultDir() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to determine config directory: %w", err)
+	}
+	return filepath.Join(dir, "cfnrc", dirName), nil
+}
+
+// Discover returns the executables in dir ordered by name; hidden files, directories and files
+// without execute permission are skipped. If dir is empty, the default directory is used and a
+// missing directory yields no plugins. An explicitly given directory must exist.
+func Discover(dir string) ([]Plugin, error) {
+	explicit := dir != ""
+	if !explicit {
+		var err error
+		dir, err = DefaultDir()
+		if err != nil {
+			return nil, nil
+		}
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if !explicit && errors.Is(err, fs.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read plugins directory '%s': %w", dir, err)
+	}
+
+	var plugins []Plugin
+	for _, entry := range entries {
+		if strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		path := filepath.Join(dir, entry.Name())
+		info, err := os.Stat(path)
+		if err != nil || !info.Mode().IsRegular() || info.Mode().Perm()&0o111 == 0 {
+			continue
+		}
+		plugins = append(plugins, Plugin{Name: strings.TrimSuffix(entry.Name(), filepath.Ext(entry.Name())), Path: path})
+	}
+
+	sort.Slice(plugins, func(i, j int) bool { return plugins[i].Name < plugins[j].Name })
+	return plugins, nil
+}
+
+// NewRequest builds the request for an error of an analysis
+func NewRequest(analysis *analyzer.StackAnalysis, err analyzer.CorrelatedError) Request {
+	stackErr := err.StackError
+	request := Request{
+		APIVersion: APIVersion,
+		StackName:  analysis.StackName,
+		StackId:    analysis.StackId,
+		Error: RequestError{
+			Timestamp:            stackErr.Timestamp.UTC(),
+			LogicalResourceId:    stackErr.LogicalResourceId,
+			ResourceType:         stackErr.ResourceType,
+			ResourceStatus:       stackErr.ResourceStatus,
+			ResourceStatusReason: stackErr.ResourceStatusReason,
+			EventId:              stackErr.EventId,
+			Category:             classify.Category(err),
+			ErrorCode:            classify.ErrorCode(err),
+			RequestID:            classify.RequestID(err),
+			DetailedMessage:      err.DetailedMessage,
+		},
+	}
+	if event := err.CloudTrailEvent; event != nil {
+		request.Error.CloudTrail = &RequestCloudTrail{
+			EventTime:    event.EventTime.UTC(),
+			EventName:    event.EventName,
+			EventSource:  event.EventSource,
+			ErrorCode:    event.ErrorCode,
+			ErrorMessage: event.ErrorMessage,
+			UserIdentity: event.UserIdentity,
+		}
+	}
+	return request
+}
+
+// Run executes the plugin with the request on stdin and returns the findings it printed
+func (p Plugin) Run(ctx context.Context, request Request) ([]analyzer.Finding, error) {
+	payload, err := json.Marshal(request)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode plugin request: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, runTimeout)
+	defer cancel()
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, p.Path)
+	cmd.Stdin = bytes.NewReader(payload)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	cmd.Env = append(os.Environ(), "CFNRC_PLUGIN_API_VERSION="+APIVersion)
+
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return nil, fmt.Errorf("plugin %s timed out after %s", p.Name, runTimeout)
+		}
+		message := strings.TrimSpace(stderr.String())
+		if len(message) > maxStderrLength {
+			message = message[:maxStderrLength] + "..."
+		}
+		if message != "" {
+			return nil, fmt.Errorf("plugin %s failed: %w: %s", p.Name, err, message)
+		}
+		return nil, fmt.Errorf("plugin %s failed: %w", p.Name, err)
+	}
+
+	if len(bytes.TrimSpace(stdout.Bytes())) == 0 {
+		return nil, nil
+	}
+	var response Response
+	if err := json.Unmarshal(stdout.Bytes(), &response); err != nil {
+		return nil, fmt.Errorf("plugin %s printed invalid JSON: %w", p.Name, err)
+	}
+
+	findings := make([]analyzer.Finding, 0, len(response.Findings))
+	for _, finding := range response.Findings {
+		if finding.Title == "" {
+			return nil, fmt.Errorf("plugin %s returned a finding without title", p.Name)
+		}
+		findings = append(findings, analyzer.Finding{
+			Pattern:           PatternPrefix + p.Name,
+			LogicalResourceId: request.Error.LogicalResourceId,
+			Title:             finding.Title,
+			Explanation:       finding.Explanation,
+			Evidence:          finding.Evidence,
+			Suggestion:        finding.Suggestion,
+		})
+	}
+	return findings, nil
+}
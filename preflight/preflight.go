@@ -0,0 +1,128 @@
+// Package preflight checks whether the current credentials have the permissions the analyzer needs.
+// Each permission is verified with a harmless read call, so the checks reflect the effective policy
+// including permission boundaries and service control policies.
+package preflight
+
+import (
+	"context"
+	"errors"
+
+	"cfn-root-cause/awserrors"
+	"cfn-root-cause/cfnclient"
+	"cfn-root-cause/cloudtrail"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation"
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation/types"
+	ct "github.com/aws/aws-sdk-go-v2/service/cloudtrail"
+	"github.com/aws/smithy-go"
+)
+
+// probeStackName is a stack name that does not exist; describing it succeeds up to the
+// existence check when the permission is granted
+const probeStackName = "cfnrc-preflight-probe"
+
+// probeTypeName is a resource type that is not registered; describing it fails with
+// TypeNotFoundException when the permission is granted
+const probeTypeName = "Cfnrc::Preflight::Probe"
+
+// Results of a permission check
+const (
+	StatusAllowed = "OK"
+	StatusDenied  = "MISSING"
+	StatusUnknown = "UNKNOWN"
+)
+
+// Result is the outcome of checking one permission
+type Result struct {
+	Permission string
+	Purpose    string
+	Required   bool
+	Status     string
+
+	// Err is the error of the check call if the status is StatusUnknown
+	Err error
+}
+
+// check describes a permission and the call that exercises it
+type check struct {
+	permission string
+	purpose    string
+	required   bool
+	call       func(ctx context.Context) error
+}
+
+// Run checks all permissions used by the analyzer
+func Run(ctx context.Context, cfn cfnclient.CloudFormationAPI, trail cloudtrail.CloudTrailAPI) []Result {
+	checks := []check{
+		{"cloudformation:DescribeStackEvents", "read the events of the analyzed stack", true, func(ctx context.Context) error {
+			_, err := cfn.DescribeStackEvents(ctx, &cloudformation.DescribeStackEventsInput{StackName: aws.String(probeStackName)})
+			return err
+		}},
+		{"cloudformation:DescribeStacks", "validate stack names and find the latest stack", true, func(ctx context.Context) error {
+			_, err := cfn.DescribeStacks(ctx, &cloudformation.DescribeStacksInput{StackName: aws.String(probeStackName)})
+			return err
+		}},
+		{"cloudformation:ListStacks", "find the latest or failed stacks, list subcommand", true, func(ctx context.Context) error {
+			_, err := cfn.ListStacks(ctx, &cloudformation.ListStacksInput{})
+			return err
+		}},
+		{"cloudtrail:LookupEvents", "detailed messages for GeneralServiceExceptions", true, func(ctx context.Context) error {
+			_, err := trail.LookupEvents(ctx, &ct.LookupEventsInput{MaxResults: aws.Int32(1)})
+			return err
+		}},
+		{"cloudtrail:DescribeTrails", "check that a trail records the management events of the region", false, func(ctx context.Context) error {
+			_, err := trail.DescribeTrails(ctx, &ct.DescribeTrailsInput{})
+			return err
+		}},
+		{"cloudformation:GetTemplate", "--template-diff", false, func(ctx context.Context) error {
+			_, err := cfn.GetTemplate(ctx, &cloudformation.GetTemplateInput{StackName: aws.String(probeStackName)})
+			return err
+		}},
+		{"cloudformation:DescribeType", "diagnostics of failed private and third-party resource types", false, func(ctx context.Context) error {
+			_, err := cfn.DescribeType(ctx, &cloudformation.DescribeTypeInput{
+				Type:     types.RegistryTypeResource,
+				TypeName: aws.String(probeTypeName),
+			})
+			return err
+		}},
+	}
+
+	results := make([]Result, 0, len(checks))
+	for _, c := range checks {
+		result := Result{Permission: c.permission, Purpose: c.purpose, Required: c.required}
+		result.Status, result.Err = evaluate(c.call(ctx))
+		results = append(results, result)
+	}
+
+	return results
+}
+
+// Passed reports whether all required permissions are granted
+func Passed(results []Result) bool {
+	for _, result := range results {
+		if result.Required && result.Status != StatusAllowed {
+			return false
+		}
+	}
+	return true
+}
+
+// evaluate derives the check status from the error of the check call. A ValidationError means
+// the request was authorized and only rejected because the probe stack does not exist, and a
+// TypeNotFoundException that the probe type is not registered.
+func evaluate(err error) (string, error) {
+	if err == nil {
+		return StatusAllowed, nil
+	}
+	if awserrors.IsPermissionError(err) {
+		return StatusDenied, nil
+	}
+
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) && (apiErr.ErrorCode() == "ValidationError" || apiErr.ErrorCode() == "TypeNotFoundException") {
+		return StatusAllowed, nil
+	}
+
+	return StatusUnknown, awserrors.ParseAWSError(err, "AWS")
+}
@@ -0,0 +1,72 @@
+// Package rdsevents reads the events RDS recorded for the database resources of a failed
+// deployment, which name the RDS-side reason CloudFormation does not report
+package rdsevents
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"cfn-root-cause/analyzer"
+	"cfn-root-cause/awserrors"
+	"cfn-root-cause/metrics"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/rds"
+	"github.com/aws/aws-sdk-go-v2/service/rds/types"
+)
+
+// MaxEvents limits the number of events read for a resource
+const MaxEvents = 500
+
+// Client wraps the RDS client
+type Client struct {
+	rds *rds.Client
+}
+
+// NewClientWithConfig creates a new RDS events client with a custom AWS config
+func NewClientWithConfig(cfg aws.Config) *Client {
+	return &Client{rds: rds.NewFromConfig(cfg)}
+}
+
+// Events returns the events RDS recorded for a source between start and end, oldest first.
+// sourceType is an RDS source type such as db-instance or db-cluster. RDS keeps events for 14 days.
+func (c *Client) Events(ctx context.Context, sourceType, identifier string, start, end time.Time) ([]analyzer.DatabaseEvent, error) {
+	var events []analyzer.DatabaseEvent
+	var marker *string
+
+	for {
+		output, err := c.rds.DescribeEvents(ctx, &rds.DescribeEventsInput{
+			SourceType:       types.SourceType(sourceType),
+			SourceIdentifier: aws.String(identifier),
+			StartTime:        aws.Time(start),
+			EndTime:          aws.Time(end),
+			Marker:           marker,
+			MaxRecords:       aws.Int32(100),
+		})
+		if err != nil {
+			if awserrors.IsThrottlingError(err) {
+				metrics.ThrottlesTotal.Inc("RDS")
+			}
+			return events, fmt.Errorf("failed to describe RDS events of %s: %w", identifier,
+				awserrors.ParseAWSError(err, "RDS"))
+		}
+
+		for _, event := range output.Events {
+			events = append(events, analyzer.DatabaseEvent{
+				Time:             aws.ToTime(event.Date),
+				SourceIdentifier: aws.ToString(event.SourceIdentifier),
+				SourceType:       string(event.SourceType),
+				Categories:       event.EventCategories,
+				Message:          aws.ToString(event.Message),
+			})
+		}
+
+		if output.Marker == nil || len(events) >= MaxEvents {
+			break
+		}
+		marker = output.Marker
+	}
+
+	return events, nil
+}
@@ -0,0 +1,224 @@
+// Package redact masks account IDs, IP addresses and generated resource name suffixes in analyses,
+// so reports can be shared in public forums or with vendors. Each distinct value is replaced by a
+// numbered placeholder, e.g. <account-1>, so references to the same account remain recognizable.
+package redact
+
+import (
+	"fmt"
+	"net"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"cfn-root-cause/analyzer"
+)
+
+var (
+	// accountPattern matches 12-digit account IDs, including the account segment of ARNs
+	accountPattern = regexp.MustCompile(`\b\d{12}\b`)
+
+	// ipv4Pattern matches IPv4 addresses
+	ipv4Pattern = regexp.MustCompile(`\b(?:\d{1,3}\.){3}\d{1,3}\b`)
+
+	// ipv6Pattern matches candidates for IPv6 addresses, which are validated with net.ParseIP
+	ipv6Pattern = regexp.MustCompile(`[0-9A-Fa-f]{0,4}(?::[0-9A-Fa-f]{0,4}){2,7}`)
+
+	// suffixPattern matches the random suffix CloudFormation appends to generated physical names,
+	// e.g. the 1A2B3C4D5E6F7 of my-stack-Bucket-1A2B3C4D5E6F7
+	suffixPattern = regexp.MustCompile(`\b([A-Za-z0-9][A-Za-z0-9]*-)([A-Za-z0-9]{12,13})\b`)
+
+	// accountStackPattern matches stack names embedding the account ID, such as the
+	// SC-123456789012-pp-abcdefgh1234 stacks provisioning Service Catalog products
+	accountStackPattern = regexp.MustCompile(`^SC-\d{12}-`)
+)
+
+// Redactor masks sensitive values consistently across all analyses it is applied to
+type Redactor struct {
+	placeholders map[string]string
+	counts       map[string]int
+}
+
+// New creates a redactor
+func New() *Redactor {
+	return &Redactor{placeholders: make(map[string]string), counts: make(map[string]int)}
+}
+
+// placeholder returns the placeholder of a value, numbering the values of each kind in order of appearance
+func (r *Redactor) placeholder(kind, value string) string {
+	key := kind + ":" + value
+	if placeholder, ok := r.placeholders[key]; ok {
+		return placeholder
+	}
+	r.counts[kind]++
+	placeholder := fmt.Sprintf("<%s-%d>", kind, r.counts[kind])
+	r.placeholders[key] = placeholder
+	return placeholder
+}
+
+// Text masks account IDs, IP addresses and generated name suffixes in text
+func (r *Redactor) Text(text string) string {
+	text = accountPattern.ReplaceAllStringFunc(text, func(account string) string {
+		return r.placeholder("account", account)
+	})
+	text = ipv4Pattern.ReplaceAllStringFunc(text, func(ip string) string {
+		if net.ParseIP(ip) == nil {
+			return ip
+		}
+		return r.placeholder("ip", ip)
+	})
+	text = ipv6Pattern.ReplaceAllStringFunc(text, func(candidate string) string {
+		if !isIPv6(candidate) {
+			return candidate
+		}
+		return r.placeholder("ip", strings.ToLower(candidate))
+	})
+	return suffixPattern.ReplaceAllStringFunc(text, func(name string) string {
+		match := suffixPattern.FindStringSubmatch(name)
+		if !isGeneratedSuffix(match[2]) {
+			return name
+		}
+		return match[1] + r.placeholder("suffix", match[2])
+	})
+}
+
+// isIPv6 reports whether a candidate is an IPv6 address with at least three groups, which
+// excludes names such as AWS::EC2::Instance and times such as 12:30:00
+func isIPv6(candidate string) bool {
+	ip := net.ParseIP(candidate)
+	if ip == nil || ip.To4() != nil {
+		return false
+	}
+	groups := 0
+	for _, group := range strings.Split(candidate, ":") {
+		if group != "" {
+			groups++
+		}
+	}
+	return groups >= 3
+}
+
+// isGeneratedSuffix reports whether a name segment looks like a generated suffix: it contains
+// digits and upper case letters, unlike words such as "Configuration" and the lower case hex
+// groups of request IDs
+func isGeneratedSuffix(segment string) bool {
+	hasDigit := strings.IndexFunc(segment, unicode.IsDigit) >= 0
+	hasUpper := strings.IndexFunc(segment, unicode.IsUpper) >= 0
+	return hasDigit && hasUpper
+}
+
+// Analysis masks the sensitive values in all texts of an analysis. Logical resource IDs, resource
+// types and stack names are kept, since they come from the template and are needed to follow the
+// report; only stack names embedding the account ID are masked.
+func (r *Redactor) Analysis(analysis *analyzer.StackAnalysis) {
+	analysis.StackName = r.stackName(analysis.StackName)
+	analysis.StackId = r.Text(analysis.StackId)
+	for i := range analysis.RelatedStacks {
+		analysis.RelatedStacks[i] = r.stackName(analysis.RelatedStacks[i])
+	}
+	for i := range analysis.Errors {
+		r.correlatedError(&analysis.Errors[i])
+	}
+	for i := range analysis.Ignored {
+		r.correlatedError(&analysis.Ignored[i].Error)
+	}
+	for i := range analysis.Findings {
+		finding := &analysis.Findings[i]
+		finding.Title = r.Text(finding.Title)
+		finding.Explanation = r.Text(finding.Explanation)
+		finding.Suggestion = r.Text(finding.Suggestion)
+		for j := range finding.Evidence {
+			finding.Evidence[j] = r.Text(finding.Evidence[j])
+		}
+	}
+	for i := range analysis.Warnings {
+		analysis.Warnings[i].Message = r.Text(analysis.Warnings[i].Message)
+	}
+	if analysis.StackStatus != nil {
+		analysis.StackStatus.Reason = r.Text(analysis.StackStatus.Reason)
+	}
+	if product := analysis.ProvisionedProduct; product != nil {
+		product.StatusMessage = r.Text(product.StatusMessage)
+		for i := range product.RecordErrors {
+			product.RecordErrors[i] = r.Text(product.RecordErrors[i])
+		}
+		product.StackId = r.Text(product.StackId)
+	}
+	if analysis.Identity != nil {
+		analysis.Identity.AccountID = r.Text(analysis.Identity.AccountID)
+		analysis.Identity.Principal = r.Text(analysis.Identity.Principal)
+	}
+	if stackContext := analysis.StackContext; stackContext != nil {
+		for i := range stackContext.Parameters {
+			stackContext.Parameters[i].Value = r.Text(stackContext.Parameters[i].Value)
+			stackContext.Parameters[i].ResolvedValue = r.Text(stackContext.Parameters[i].ResolvedValue)
+		}
+		for i := range stackContext.Outputs {
+			stackContext.Outputs[i].Value = r.Text(stackContext.Outputs[i].Value)
+			stackContext.Outputs[i].ExportName = r.Text(stackContext.Outputs[i].ExportName)
+		}
+	}
+	if analysis.Metadata != nil {
+		analysis.Metadata.AccountID = r.Text(analysis.Metadata.AccountID)
+	}
+	if analysis.TemplateDiff != nil {
+		analysis.TemplateDiff.ChangeSetId = r.Text(analysis.TemplateDiff.ChangeSetId)
+		analysis.TemplateDiff.Diff = r.Text(analysis.TemplateDiff.Diff)
+	}
+	analysis.AISummary = r.Text(analysis.AISummary)
+}
+
+// stackName masks the account ID in stack names embedding it, and keeps other stack names
+func (r *Redactor) stackName(name string) string {
+	if accountStackPattern.MatchString(name) {
+		return r.Text(name)
+	}
+	return name
+}
+
+// correlatedError masks the messages of an error and its CloudTrail event. The physical ID is
+// masked like the resources of the event, so the correlation evidence is the same after redaction.
+func (r *Redactor) correlatedError(err *analyzer.CorrelatedError) {
+	err.StackError.ResourceStatusReason = r.Text(err.StackError.ResourceStatusReason)
+	err.StackError.PhysicalResourceId = r.Text(err.StackError.PhysicalResourceId)
+	err.DetailedMessage = r.Text(err.DetailedMessage)
+
+	if event := err.CloudTrailEvent; event != nil {
+		redacted := *event
+		redacted.ErrorMessage = r.Text(event.ErrorMessage)
+		redacted.UserIdentity, _ = r.value(event.UserIdentity).(map[string]interface{})
+		redacted.ResponseElements, _ = r.value(event.ResponseElements).(map[string]interface{})
+		redacted.RequestParameters, _ = r.value(event.RequestParameters).(map[string]interface{})
+		if event.Resources != nil {
+			redacted.Resources = make([]analyzer.TrailResource, 0, len(event.Resources))
+			for _, resource := range event.Resources {
+				redacted.Resources = append(redacted.Resources, analyzer.TrailResource{Type: resource.Type, Name: r.Text(resource.Name)})
+			}
+		}
+		err.CloudTrailEvent = &redacted
+	}
+}
+
+// value masks the strings of a decoded JSON value
+func (r *Redactor) value(value interface{}) interface{} {
+	switch v := value.(type) {
+	case string:
+		return r.Text(v)
+	case map[string]interface{}:
+		if v == nil {
+			return v
+		}
+		redacted := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			redacted[key] = r.value(item)
+		}
+		return redacted
+	case []interface{}:
+		redacted := make([]interface{}, len(v))
+		for i, item := range v {
+			redacted[i] = r.value(item)
+		}
+		return redacted
+	default:
+		return v
+	}
+}
@@ -0,0 +1,86 @@
+package redact_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"cfn-root-cause/analyzer"
+	"cfn-root-cause/correlator"
+	"cfn-root-cause/redact"
+)
+
+func TestAnalysisKeepsCorrelationEvidence(t *testing.T) {
+	timestamp := time.Date(2026, 1, 8, 9, 38, 59, 0, time.UTC)
+	topicArn := "arn:aws:sns:eu-central-1:123456789012:ops-alerts"
+	analysis := &analyzer.StackAnalysis{
+		StackName: "my-stack",
+		Errors: []analyzer.CorrelatedError{{
+			StackError: analyzer.StackError{
+				Timestamp:          timestamp,
+				ResourceType:       "AWS::SNS::Topic",
+				LogicalResourceId:  "Notifications",
+				ResourceStatus:     "CREATE_FAILED",
+				PhysicalResourceId: topicArn,
+			},
+			CloudTrailEvent: &analyzer.CloudTrailEvent{
+				EventTime:   timestamp.Add(-2 * time.Second),
+				EventName:   "SetTopicAttributes",
+				EventSource: "sns.amazonaws.com",
+				ErrorCode:   "InvalidParameter",
+				Resources:   []analyzer.TrailResource{{Type: "AWS::SNS::Topic", Name: topicArn}},
+			},
+		}},
+	}
+
+	wantConfidence := correlator.Confidence(analysis.Errors[0])
+	wantEvidence := correlator.Evidence(analysis.Errors[0])
+	if wantConfidence != correlator.ConfidenceHigh {
+		t.Fatalf("Confidence before redaction = %q, want %q", wantConfidence, correlator.ConfidenceHigh)
+	}
+
+	redact.New().Analysis(analysis)
+
+	if got := correlator.Confidence(analysis.Errors[0]); got != wantConfidence {
+		t.Errorf("Confidence after redaction = %q, want %q", got, wantConfidence)
+	}
+	gotEvidence := correlator.Evidence(analysis.Errors[0])
+	if len(gotEvidence) != len(wantEvidence) {
+		t.Fatalf("Evidence after redaction = %v, want %v", gotEvidence, wantEvidence)
+	}
+	for i := range wantEvidence {
+		if gotEvidence[i].Kind != wantEvidence[i].Kind || gotEvidence[i].Offset != wantEvidence[i].Offset {
+			t.Errorf("Evidence[%d] after redaction = %+v, want %+v", i, gotEvidence[i], wantEvidence[i])
+		}
+	}
+}
+
+func TestAnalysisMasksProvisionedProduct(t *testing.T) {
+	stackId := "arn:aws:cloudformation:eu-central-1:123456789012:stack/SC-123456789012-pp-abcdefgh1234/0a1b2c3d"
+	analysis := &analyzer.StackAnalysis{
+		StackName:     "SC-123456789012-pp-abcdefgh1234",
+		StackId:       stackId,
+		RelatedStacks: []string{"network", "SC-123456789012-pp-ijklmnop5678"},
+		ProvisionedProduct: &analyzer.ProvisionedProduct{
+			Name:          "orders-bucket",
+			StatusMessage: "Stack " + stackId + " failed",
+			RecordErrors:  []string{"Failed to provision stack " + stackId},
+			StackId:       stackId,
+		},
+	}
+
+	redact.New().Analysis(analysis)
+
+	product := analysis.ProvisionedProduct
+	for _, text := range []string{analysis.StackName, analysis.RelatedStacks[1], product.StatusMessage, product.RecordErrors[0], product.StackId} {
+		if strings.Contains(text, "123456789012") {
+			t.Errorf("account ID not masked in %q", text)
+		}
+	}
+	if analysis.StackName != "SC-<account-1>-pp-abcdefgh1234" {
+		t.Errorf("StackName = %q, want the account masked", analysis.StackName)
+	}
+	if analysis.RelatedStacks[0] != "network" {
+		t.Errorf("RelatedStacks[0] = %q, want %q", analysis.RelatedStacks[0], "network")
+	}
+}
@@ -0,0 +1,101 @@
+// Package remediation plans the actions that bring a stuck stack back into a deployable state.
+// Planning is read-only; the actions are only executed by the remediate subcommand with --allow-mutations.
+package remediation
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation/types"
+)
+
+// Kinds of remediation actions
+const (
+	// ActionContinueUpdateRollback resumes a failed update rollback, skipping resources that cannot be rolled back
+	ActionContinueUpdateRollback = "continue-update-rollback"
+
+	// ActionDeleteStack deletes a stack whose creation failed and was rolled back; it cannot be updated
+	ActionDeleteStack = "delete-stack"
+)
+
+// Action is a remediation of a stuck stack
+type Action struct {
+	Kind      string
+	StackName string
+
+	// ResourcesToSkip are the logical IDs skipped by ActionContinueUpdateRollback
+	ResourcesToSkip []string
+
+	// Description explains what the action does
+	Description string
+
+	// Warning explains the consequences the user must accept before the action is executed
+	Warning string
+}
+
+// Plan returns the remediation of a stack in the given status, or an error if the status
+// needs none. events are the stack events of the latest operation, newest first; failed resources
+// of an update rollback are skipped unless skip lists the resources explicitly.
+func Plan(stackName string, status types.StackStatus, events []types.StackEvent, skip []string) (*Action, error) {
+	switch status {
+	case types.StackStatusUpdateRollbackFailed:
+		if len(skip) == 0 {
+			skip = rollbackFailures(events)
+		}
+		description := "Continue the failed update rollback"
+		if len(skip) > 0 {
+			description += fmt.Sprintf(", skipping %s", strings.Join(skip, ", "))
+		}
+		return &Action{
+			Kind:            ActionContinueUpdateRollback,
+			StackName:       stackName,
+			ResourcesToSkip: skip,
+			Description:     description,
+			Warning: "Skipped resources are marked UPDATE_COMPLETE without being rolled back, so their actual " +
+				"configuration may differ from the template until they are fixed manually.",
+		}, nil
+	case types.StackStatusRollbackComplete:
+		if len(skip) > 0 {
+			return nil, fmt.Errorf("resources to skip only apply to stacks in %s", types.StackStatusUpdateRollbackFailed)
+		}
+		return &Action{
+			Kind:        ActionDeleteStack,
+			StackName:   stackName,
+			Description: "Delete the stack, whose creation failed and was rolled back, so it can be created again",
+			Warning: "All resources still retained by the stack are deleted, except those with a " +
+				"DeletionPolicy of Retain.",
+		}, nil
+	default:
+		return nil, fmt.Errorf("stack '%s' is in status %s; remediation is only available for %s and %s",
+			stackName, status, types.StackStatusUpdateRollbackFailed, types.StackStatusRollbackComplete)
+	}
+}
+
+// rollbackFailures returns the resources whose most recent event in the operation is UPDATE_FAILED,
+// i.e. the resources CloudFormation could not roll back
+func rollbackFailures(events []types.StackEvent) []string {
+	latest := make(map[string]types.ResourceStatus)
+
+	for _, event := range events {
+		logicalId := aws.ToString(event.LogicalResourceId)
+		if aws.ToString(event.PhysicalResourceId) == aws.ToString(event.StackId) {
+			continue
+		}
+		// Events are ordered newest first, so the first event per resource is its latest
+		if _, seen := latest[logicalId]; !seen {
+			latest[logicalId] = event.ResourceStatus
+		}
+	}
+
+	var failed []string
+	for logicalId, status := range latest {
+		if status == types.ResourceStatusUpdateFailed {
+			failed = append(failed, logicalId)
+		}
+	}
+	sort.Strings(failed)
+
+	return failed
+}
@@ -0,0 +1,64 @@
+// Package resourcestate reads the current state of resources through the Cloud Control API
+package resourcestate
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"cfn-root-cause/analyzer"
+	"cfn-root-cause/awserrors"
+	"cfn-root-cause/metrics"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudcontrol"
+	"github.com/aws/smithy-go"
+)
+
+// ErrUnsupported indicates that the Cloud Control API cannot read resources of the type
+var ErrUnsupported = errors.New("the Cloud Control API does not support reading resources of this type")
+
+// Client wraps the Cloud Control API client
+type Client struct {
+	cloudcontrol *cloudcontrol.Client
+}
+
+// NewClientWithConfig creates a new Cloud Control API client with a custom AWS config
+func NewClientWithConfig(cfg aws.Config) *Client {
+	return &Client{cloudcontrol: cloudcontrol.NewFromConfig(cfg)}
+}
+
+// Get returns the current state of a resource by its type and primary identifier, usually the
+// physical resource ID; nil if the resource no longer exists. Types the Cloud Control API cannot
+// read get ErrUnsupported.
+func (c *Client) Get(ctx context.Context, typeName, identifier string) (*analyzer.ResourceState, error) {
+	output, err := c.cloudcontrol.GetResource(ctx, &cloudcontrol.GetResourceInput{
+		TypeName:   aws.String(typeName),
+		Identifier: aws.String(identifier),
+	})
+	if err != nil {
+		if awserrors.IsThrottlingError(err) {
+			metrics.ThrottlesTotal.Inc("Cloud Control")
+		}
+		var apiErr smithy.APIError
+		if errors.As(err, &apiErr) {
+			switch apiErr.ErrorCode() {
+			case "ResourceNotFoundException":
+				return nil, nil
+			case "UnsupportedActionException", "TypeNotFoundException":
+				return nil, ErrUnsupported
+			}
+		}
+		return nil, fmt.Errorf("failed to get resource %s (%s): %w", identifier, typeName,
+			awserrors.ParseAWSError(err, "Cloud Control"))
+	}
+
+	state := &analyzer.ResourceState{TypeName: typeName, Identifier: identifier}
+	if output.ResourceDescription != nil {
+		if err := json.Unmarshal([]byte(aws.ToString(output.ResourceDescription.Properties)), &state.Properties); err != nil {
+			return nil, fmt.Errorf("failed to parse properties of resource %s: %w", identifier, err)
+		}
+	}
+	return state, nil
+}
@@ -0,0 +1,163 @@
+// Package rootcause ranks the failed resources of an analysis by how likely they caused the failure
+package rootcause
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"cfn-root-cause/analyzer"
+	"cfn-root-cause/classify"
+	"cfn-root-cause/correlator"
+	"cfn-root-cause/sorter"
+)
+
+// Scores added to a candidate for each piece of evidence
+const (
+	scoreTriggering  = 40
+	scoreFinding     = 30
+	scoreFirst       = 20
+	scoreCloudTrail  = 5
+	scoreCategory    = 5
+	scoreUnexplained = -10
+)
+
+// confidenceWeights multiply the CloudTrail score by the correlation confidence
+var confidenceWeights = map[string]int{
+	correlator.ConfidenceHigh:   3,
+	correlator.ConfidenceMedium: 2,
+	correlator.ConfidenceLow:    1,
+}
+
+// Candidate is a failed resource that may have caused the failure
+type Candidate struct {
+	// Error is the first error of the resource
+	Error analyzer.CorrelatedError
+
+	// Finding is the failure pattern recognized for the resource, nil if none
+	Finding *analyzer.Finding
+
+	// Category is the failure category of the error, see classify.Category
+	Category string
+
+	// Confidence rates the correlation of the CloudTrail event, see correlator.Confidence;
+	// "" without CloudTrail event
+	Confidence string
+
+	// Score is higher for more likely root causes
+	Score int
+
+	// Reasons list the evidence the score is based on
+	Reasons []string
+}
+
+// Rank returns the failed resources that may have caused the failure, the most likely first.
+// Cancelled resources are consequences of other failures and not ranked. Candidates with the same
+// score are ordered chronologically.
+func Rank(analysis *analyzer.StackAnalysis) []Candidate {
+	if analysis == nil {
+		return nil
+	}
+
+	findings := make(map[string]analyzer.Finding)
+	for _, finding := range analysis.Findings {
+		if _, seen := findings[finding.LogicalResourceId]; !seen {
+			findings[finding.LogicalResourceId] = finding
+		}
+	}
+
+	var candidates []Candidate
+	seen := make(map[string]bool)
+	for _, err := range analysis.Errors {
+		logicalId := err.StackError.LogicalResourceId
+		if seen[logicalId] || classify.Category(err) == classify.CategoryCancelled {
+			continue
+		}
+		seen[logicalId] = true
+
+		candidate := Candidate{Error: err, Category: classify.Category(err), Confidence: correlator.Confidence(err)}
+		if finding, ok := findings[logicalId]; ok {
+			candidate.Finding = &finding
+		}
+		candidates = append(candidates, candidate)
+	}
+	if len(candidates) == 0 {
+		return nil
+	}
+
+	first := 0
+	for i, candidate := range candidates {
+		if candidate.Error.StackError.Timestamp.Before(candidates[first].Error.StackError.Timestamp) {
+			first = i
+		}
+	}
+
+	for i := range candidates {
+		score(&candidates[i], i == first)
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		if candidates[i].Score != candidates[j].Score {
+			return candidates[i].Score > candidates[j].Score
+		}
+		return candidates[i].Error.StackError.Timestamp.Before(candidates[j].Error.StackError.Timestamp)
+	})
+
+	return candidates
+}
+
+// MostLikely returns the most likely root cause of the analysis, or nil if no resource failed
+func MostLikely(analysis *analyzer.StackAnalysis) *Candidate {
+	candidates := Rank(analysis)
+	if len(candidates) == 0 {
+		return nil
+	}
+	return &candidates[0]
+}
+
+// score rates the evidence of a candidate; first is set for the earliest failure of the operation
+func score(candidate *Candidate, first bool) {
+	err := candidate.Error
+
+	if sorter.Severity(err) == sorter.SeverityRootCause {
+		candidate.add(scoreTriggering, "failed in the triggering operation, not while cleaning up")
+	} else {
+		candidate.add(0, fmt.Sprintf("failed while cleaning up (%s)", err.StackError.ResourceStatus))
+	}
+	if candidate.Finding != nil {
+		candidate.add(scoreFinding, fmt.Sprintf("failure pattern recognized: %s", candidate.Finding.Title))
+	}
+	if first {
+		candidate.add(scoreFirst, "first failure of the operation")
+	}
+	if event := err.CloudTrailEvent; event != nil && event.ErrorCode != "" {
+		candidate.add(scoreCloudTrail*confidenceWeights[candidate.Confidence],
+			fmt.Sprintf("CloudTrail call %s failed with %s %s (%s correlation confidence)",
+				event.EventName, event.ErrorCode, offset(err), candidate.Confidence))
+	} else if err.StackError.IsGeneralServiceException {
+		candidate.add(scoreUnexplained, "GeneralServiceException without CloudTrail details")
+	}
+	if candidate.Category != classify.CategoryOther {
+		candidate.add(scoreCategory, fmt.Sprintf("category %s", candidate.Category))
+	}
+}
+
+// offset describes when the CloudTrail call of an error happened relative to the failure, e.g.
+// "2s before the failure"
+func offset(err analyzer.CorrelatedError) string {
+	diff := err.CloudTrailEvent.EventTime.Sub(err.StackError.Timestamp).Round(time.Second)
+	switch {
+	case diff < 0:
+		return fmt.Sprintf("%s before the failure", -diff)
+	case diff > 0:
+		return fmt.Sprintf("%s after the failure", diff)
+	default:
+		return "at the time of the failure"
+	}
+}
+
+// add records a piece of evidence and its score
+func (c *Candidate) add(points int, reason string) {
+	c.Score += points
+	c.Reasons = append(c.Reasons, reason)
+}
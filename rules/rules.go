@@ -0,0 +1,236 @@
+// Package rules classifies errors with user-defined rules: CEL expressions over the correlated
+// error that assign categories, owners, severities and custom remediation text
+package rules
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"strings"
+
+	"cfn-root-cause/analyzer"
+	"cfn-root-cause/classify"
+
+	"github.com/google/cel-go/cel"
+)
+
+// DefaultFileName is the rules file picked up from the working directory when no file is given
+const DefaultFileName = ".cfnrc-rules.json"
+
+// PatternPrefix prefixes the pattern of findings added by rules, e.g. "rule:storage-conflicts"
+const PatternPrefix = "rule:"
+
+// Severities lists the valid severities, lowest first
+var Severities = []string{"low", "medium", "high", "critical"}
+
+// Rule assigns a classification to the errors its expression matches
+type Rule struct {
+	Name string `json:"name"`
+
+	// When is a CEL expression over the variables error and stack that evaluates to a bool, e.g.
+	// error.resourceType.startsWith("AWS::RDS::") && error.errorCode == "AccessDenied"
+	When string `json:"when"`
+
+	Category string `json:"category,omitempty"`
+	Owner    string `json:"owner,omitempty"`
+	Severity string `json:"severity,omitempty"`
+
+	// Remediation is added to the report as a finding for the matched error
+	Remediation string `json:"remediation,omitempty"`
+
+	program cel.Program
+}
+
+// File is the content of a rules file
+type File struct {
+	Rules []*Rule `json:"rules"`
+}
+
+// Load reads and compiles the rules file at path.
+// If path is empty, DefaultFileName is used and a missing file yields no rules.
+// An explicitly given path must exist.
+func Load(path string) ([]*Rule, error) {
+	explicit := path != ""
+	if !explicit {
+		path = DefaultFileName
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if !explicit && errors.Is(err, fs.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read rules file '%s': %w", path, err)
+	}
+
+	file := &File{}
+	if err := json.Unmarshal(data, file); err != nil {
+		return nil, fmt.Errorf("failed to parse rules file '%s': %w", path, err)
+	}
+
+	env, err := newEnv()
+	if err != nil {
+		return nil, err
+	}
+	for i, rule := range file.Rules {
+		if err := rule.compile(env); err != nil {
+			return nil, fmt.Errorf("invalid rule %d in rules file '%s': %w", i+1, path, err)
+		}
+	}
+
+	return file.Rules, nil
+}
+
+// newEnv declares the variables available to rule expressions
+func newEnv() (*cel.Env, error) {
+	env, err := cel.NewEnv(
+		cel.Variable("error", cel.MapType(cel.StringType, cel.DynType)),
+		cel.Variable("stack", cel.MapType(cel.StringType, cel.StringType)),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create rule environment: %w", err)
+	}
+	return env, nil
+}
+
+// compile checks the rule and compiles its expression
+func (r *Rule) compile(env *cel.Env) error {
+	if r.Name == "" {
+		return fmt.Errorf("rule must set a name")
+	}
+	if r.When == "" {
+		return fmt.Errorf("rule %s must set when", r.Name)
+	}
+	if r.Category == "" && r.Owner == "" && r.Severity == "" && r.Remediation == "" {
+		return fmt.Errorf("rule %s must set category, owner, severity or remediation", r.Name)
+	}
+	if r.Severity != "" && !isSeverity(r.Severity) {
+		return fmt.Errorf("rule %s has unknown severity '%s': must be one of %s", r.Name, r.Severity, strings.Join(Severities, ", "))
+	}
+
+	ast, issues := env.Compile(r.When)
+	if issues != nil && issues.Err() != nil {
+		return fmt.Errorf("invalid expression of rule %s: %w", r.Name, issues.Err())
+	}
+	if ast.OutputType() != cel.BoolType && ast.OutputType() != cel.DynType {
+		return fmt.Errorf("expression of rule %s must evaluate to a bool, not %s", r.Name, ast.OutputType())
+	}
+	program, err := env.Program(ast)
+	if err != nil {
+		return fmt.Errorf("invalid expression of rule %s: %w", r.Name, err)
+	}
+	r.program = program
+	return nil
+}
+
+// Matches reports whether the expression of the rule matches the error
+func (r *Rule) Matches(analysis *analyzer.StackAnalysis, err analyzer.CorrelatedError) (bool, error) {
+	value, _, evalErr := r.program.Eval(variables(analysis, err))
+	if evalErr != nil {
+		return false, fmt.Errorf("rule %s failed for %s: %w", r.Name, err.StackError.LogicalResourceId, evalErr)
+	}
+	matched, ok := value.Value().(bool)
+	if !ok {
+		return false, fmt.Errorf("rule %s did not evaluate to a bool for %s", r.Name, err.StackError.LogicalResourceId)
+	}
+	return matched, nil
+}
+
+// variables returns the variables of rule expressions for an error. All keys are always present,
+// with empty values if unknown, so expressions do not need has() checks.
+func variables(analysis *analyzer.StackAnalysis, err analyzer.CorrelatedError) map[string]interface{} {
+	stackErr := err.StackError
+	errorVariables := map[string]interface{}{
+		"logicalResourceId": stackErr.LogicalResourceId,
+		"resourceType":      stackErr.ResourceType,
+		"resourceStatus":    stackErr.ResourceStatus,
+		"message":           stackErr.ResourceStatusReason,
+		"detailedMessage":   err.DetailedMessage,
+		"errorCode":         classify.ErrorCode(err),
+		"category":          classify.BuiltinCategory(err),
+		"service":           classify.Service(err),
+		"eventName":         "",
+		"eventSource":       "",
+		"userIdentity":      map[string]interface{}{},
+	}
+	if event := err.CloudTrailEvent; event != nil {
+		errorVariables["eventName"] = event.EventName
+		errorVariables["eventSource"] = event.EventSource
+		if event.UserIdentity != nil {
+			errorVariables["userIdentity"] = event.UserIdentity
+		}
+	}
+
+	return map[string]interface{}{
+		"error": errorVariables,
+		"stack": map[string]string{"name": analysis.StackName, "id": analysis.StackId},
+	}
+}
+
+// Apply classifies each error with the first matching rule and adds the remediation of the rule
+// as a finding. Rules failing to evaluate are skipped and returned as errors.
+func Apply(analysis *analyzer.StackAnalysis, rules []*Rule) error {
+	var failures []error
+
+	for i, err := range analysis.Errors {
+		for _, rule := range rules {
+			matched, evalErr := rule.Matches(analysis, err)
+			if evalErr != nil {
+				failures = append(failures, evalErr)
+				continue
+			}
+			if !matched {
+				continue
+			}
+
+			analysis.Errors[i].Classification = &analyzer.Classification{
+				Rule:     rule.Name,
+				Category: rule.Category,
+				Owner:    rule.Owner,
+				Severity: rule.Severity,
+			}
+			if rule.Remediation != "" {
+				analysis.Findings = append(analysis.Findings, finding(rule, err))
+			}
+			break
+		}
+	}
+
+	return errors.Join(failures...)
+}
+
+// finding describes the remediation of a rule for a matched error
+func finding(rule *Rule, err analyzer.CorrelatedError) analyzer.Finding {
+	var details []string
+	if rule.Owner != "" {
+		details = append(details, "owner "+rule.Owner)
+	}
+	if rule.Severity != "" {
+		details = append(details, "severity "+rule.Severity)
+	}
+	explanation := fmt.Sprintf("The error matches the classification rule %s.", rule.Name)
+	if len(details) > 0 {
+		explanation = fmt.Sprintf("The error matches the classification rule %s (%s).", rule.Name, strings.Join(details, ", "))
+	}
+
+	return analyzer.Finding{
+		Pattern:           PatternPrefix + rule.Name,
+		LogicalResourceId: err.StackError.LogicalResourceId,
+		Title:             rule.Name,
+		Explanation:       explanation,
+		Evidence:          []string{"Rule: " + rule.When},
+		Suggestion:        rule.Remediation,
+	}
+}
+
+// isSeverity reports whether severity is one of Severities
+func isSeverity(severity string) bool {
+	for _, s := range Severities {
+		if s == severity {
+			return true
+		}
+	}
+	return false
+}
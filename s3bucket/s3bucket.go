@@ -0,0 +1,83 @@
+// Package s3bucket determines whether an S3 bucket name is taken, and by which account
+package s3bucket
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"cfn-root-cause/awserrors"
+	"cfn-root-cause/metrics"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// bucketRegionHeader names the region of a bucket in HeadBucket responses
+const bucketRegionHeader = "X-Amz-Bucket-Region"
+
+// Status is the state of a bucket name
+type Status struct {
+	// Exists is false if no account owns a bucket of the name
+	Exists bool
+
+	// OwnedByAccount is true if the bucket belongs to the account of the analysis
+	OwnedByAccount bool
+
+	// Region is the region of the bucket, "" if it does not exist or S3 did not tell
+	Region string
+}
+
+// Client wraps the S3 client
+type Client struct {
+	s3 *s3.Client
+}
+
+// NewClientWithConfig creates a new S3 client with a custom AWS config
+func NewClientWithConfig(cfg aws.Config) *Client {
+	return &Client{s3: s3.NewFromConfig(cfg)}
+}
+
+// Head determines whether a bucket of the name exists and whether it belongs to the account; without
+// an account ID, every accessible bucket counts as one of the account. Buckets in other regions are
+// looked up again in their region. S3 answers 403 both for buckets of
+// other accounts and for buckets of the account the credentials may not access; both count as
+// buckets of another account.
+func (c *Client) Head(ctx context.Context, bucketName, accountID string) (*Status, error) {
+	input := &s3.HeadBucketInput{Bucket: aws.String(bucketName)}
+	if accountID != "" {
+		input.ExpectedBucketOwner = aws.String(accountID)
+	}
+
+	output, err := c.s3.HeadBucket(ctx, input)
+	status, region := responseStatus(err)
+	if status == http.StatusMovedPermanently && region != "" {
+		output, err = c.s3.HeadBucket(ctx, input, func(o *s3.Options) { o.Region = region })
+		status, _ = responseStatus(err)
+	}
+
+	switch {
+	case err == nil:
+		return &Status{Exists: true, OwnedByAccount: true, Region: aws.ToString(output.BucketRegion)}, nil
+	case status == http.StatusNotFound:
+		return &Status{}, nil
+	case status == http.StatusForbidden:
+		return &Status{Exists: true, Region: region}, nil
+	}
+	if awserrors.IsThrottlingError(err) {
+		metrics.ThrottlesTotal.Inc("S3")
+	}
+	return nil, fmt.Errorf("failed to look up bucket %s: %w", bucketName, awserrors.ParseAWSError(err, "S3"))
+}
+
+// responseStatus returns the HTTP status of a failed S3 call and the region of the bucket S3
+// reported; 0 if the call did not fail with an HTTP response
+func responseStatus(err error) (int, string) {
+	var responseErr *awshttp.ResponseError
+	if err == nil || !errors.As(err, &responseErr) || responseErr.Response == nil {
+		return 0, ""
+	}
+	return responseErr.HTTPStatusCode(), responseErr.Response.Header.Get(bucketRegionHeader)
+}
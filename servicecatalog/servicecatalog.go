@@ -0,0 +1,138 @@
+// Package servicecatalog resolves Service Catalog provisioned products to the CloudFormation
+// stacks that provision them
+package servicecatalog
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"cfn-root-cause/analyzer"
+	"cfn-root-cause/awserrors"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/servicecatalog"
+)
+
+// stackOutputKey is the record output holding the ARN of the provisioning stack
+const stackOutputKey = "CloudformationStackARN"
+
+// Client wraps the AWS Service Catalog client
+type Client struct {
+	sc *servicecatalog.Client
+}
+
+// NewClientWithConfig creates a new Service Catalog client with a custom AWS config
+func NewClientWithConfig(cfg aws.Config) *Client {
+	return &Client{sc: servicecatalog.NewFromConfig(cfg)}
+}
+
+// ProvisionedProduct describes a provisioned product, given by ID (pp-...) or name, and finds
+// the stack of its latest provisioning record. If the names of the product and its provisioning
+// artifact cannot be looked up, the provisioned product is returned together with the error.
+func (c *Client) ProvisionedProduct(ctx context.Context, idOrName string) (*analyzer.ProvisionedProduct, error) {
+	input := &servicecatalog.DescribeProvisionedProductInput{Name: aws.String(idOrName)}
+	if strings.HasPrefix(idOrName, "pp-") {
+		input = &servicecatalog.DescribeProvisionedProductInput{Id: aws.String(idOrName)}
+	}
+	output, err := c.sc.DescribeProvisionedProduct(ctx, input)
+	if err != nil {
+		return nil, fmt.Errorf("failed to describe provisioned product '%s': %w", idOrName, awserrors.ParseAWSError(err, "Service Catalog"))
+	}
+	detail := output.ProvisionedProductDetail
+	if detail == nil {
+		return nil, fmt.Errorf("provisioned product '%s' not found", idOrName)
+	}
+	if productType := aws.ToString(detail.Type); productType != "" && productType != "CFN_STACK" {
+		return nil, fmt.Errorf("provisioned product '%s' is of type %s, only CFN_STACK products are provisioned by CloudFormation stacks",
+			idOrName, productType)
+	}
+
+	product := &analyzer.ProvisionedProduct{
+		Id:                     aws.ToString(detail.Id),
+		Name:                   aws.ToString(detail.Name),
+		Status:                 string(detail.Status),
+		StatusMessage:          aws.ToString(detail.StatusMessage),
+		ProductId:              aws.ToString(detail.ProductId),
+		ProvisioningArtifactId: aws.ToString(detail.ProvisioningArtifactId),
+		RecordId:               aws.ToString(detail.LastProvisioningRecordId),
+	}
+	if product.RecordId == "" {
+		product.RecordId = aws.ToString(detail.LastRecordId)
+	}
+
+	if err := c.readRecord(ctx, product); err != nil {
+		return nil, err
+	}
+	if product.StackId == "" {
+		reason := product.StatusMessage
+		if len(product.RecordErrors) > 0 {
+			reason = strings.Join(product.RecordErrors, "; ")
+		}
+		if reason != "" {
+			return nil, fmt.Errorf("provisioned product '%s' has no CloudFormation stack (%s): %s", idOrName, product.Status, reason)
+		}
+		return nil, fmt.Errorf("provisioned product '%s' has no CloudFormation stack (%s)", idOrName, product.Status)
+	}
+
+	return product, c.readNames(ctx, product)
+}
+
+// readRecord reads the stack ARN and the errors of the latest provisioning record
+func (c *Client) readRecord(ctx context.Context, product *analyzer.ProvisionedProduct) error {
+	if product.RecordId == "" {
+		return nil
+	}
+
+	var pageToken *string
+	for {
+		output, err := c.sc.DescribeRecord(ctx, &servicecatalog.DescribeRecordInput{
+			Id:        aws.String(product.RecordId),
+			PageToken: pageToken,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to describe provisioning record %s: %w", product.RecordId, awserrors.ParseAWSError(err, "Service Catalog"))
+		}
+
+		if pageToken == nil && output.RecordDetail != nil {
+			for _, recordErr := range output.RecordDetail.RecordErrors {
+				message := aws.ToString(recordErr.Description)
+				if code := aws.ToString(recordErr.Code); code != "" {
+					message = code + ": " + message
+				}
+				product.RecordErrors = append(product.RecordErrors, message)
+			}
+		}
+		for _, recordOutput := range output.RecordOutputs {
+			if aws.ToString(recordOutput.OutputKey) == stackOutputKey {
+				product.StackId = aws.ToString(recordOutput.OutputValue)
+			}
+		}
+
+		if output.NextPageToken == nil || product.StackId != "" {
+			return nil
+		}
+		pageToken = output.NextPageToken
+	}
+}
+
+// readNames looks up the names of the product and the provisioned version
+func (c *Client) readNames(ctx context.Context, product *analyzer.ProvisionedProduct) error {
+	if product.ProductId == "" {
+		return nil
+	}
+
+	output, err := c.sc.DescribeProduct(ctx, &servicecatalog.DescribeProductInput{Id: aws.String(product.ProductId)})
+	if err != nil {
+		return fmt.Errorf("failed to look up the name of product %s: %w", product.ProductId, awserrors.ParseAWSError(err, "Service Catalog"))
+	}
+	if summary := output.ProductViewSummary; summary != nil {
+		product.ProductName = aws.ToString(summary.Name)
+	}
+	for _, artifact := range output.ProvisioningArtifacts {
+		if aws.ToString(artifact.Id) == product.ProvisioningArtifactId {
+			product.ProvisioningArtifactName = aws.ToString(artifact.Name)
+		}
+	}
+	return nil
+}
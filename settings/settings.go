@@ -0,0 +1,121 @@
+// Package settings loads the analyzer configuration file
+package settings
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// configFileName is the name of the configuration file inside the config directory
+const configFileName = "config.json"
+
+// Config holds user preferences loaded from the configuration file
+type Config struct {
+	// Theme is the name of the built-in color theme
+	Theme string `json:"theme,omitempty"`
+
+	// Colors overrides individual theme colors by role (error, warning, highlight, heading)
+	Colors map[string]string `json:"colors,omitempty"`
+
+	// AI selects the provider that summarizes reports with --ai-summary
+	AI AIConfig `json:"ai,omitempty"`
+
+	// OpsCenter configures the OpsItems created with --ops-item
+	OpsCenter OpsCenterConfig `json:"opsCenter,omitempty"`
+
+	// Sinks are the destinations of the report when no --sink flag is given
+	Sinks []SinkConfig `json:"sinks,omitempty"`
+
+	// LatestFailed analyzes the most recently updated stack with a failure status when no stack is
+	// given, as --latest-failed does
+	LatestFailed bool `json:"latestFailed,omitempty"`
+}
+
+// SinkConfig configures a destination of the report
+type SinkConfig struct {
+	// Format is the report format; empty means the --format format, json for webhooks and compact for Slack and SNS
+	Format string `json:"format,omitempty"`
+
+	// Destination is - for stdout, a file, s3://bucket/key, an https:// webhook URL,
+	// slack:<incoming webhook URL> or an SNS topic ARN
+	Destination string `json:"destination"`
+}
+
+// AIConfig configures the AI provider used for report summaries
+type AIConfig struct {
+	// Provider is bedrock, openai or ollama
+	Provider string `json:"provider,omitempty"`
+
+	// Model is the model ID, e.g. anthropic.claude-3-5-haiku-20241022-v1:0 for Bedrock
+	Model string `json:"model,omitempty"`
+
+	// Endpoint is the base URL of OpenAI-compatible and Ollama servers
+	Endpoint string `json:"endpoint,omitempty"`
+
+	// APIKeyEnv names the environment variable holding the API key of OpenAI-compatible endpoints
+	APIKeyEnv string `json:"apiKeyEnv,omitempty"`
+
+	// Region overrides the AWS region of Bedrock, which may differ from the region of the stacks
+	Region string `json:"region,omitempty"`
+
+	// MaxTokens limits the length of the summary
+	MaxTokens int `json:"maxTokens,omitempty"`
+}
+
+// OpsCenterConfig configures the OpsCenter OpsItems created for failed stacks
+type OpsCenterConfig struct {
+	// Stacks are glob patterns of the production stacks OpsItems are created for; empty means all stacks
+	Stacks []string `json:"stacks,omitempty"`
+
+	// Severity is the OpsItem severity from 1 (critical) to 4 (low)
+	Severity string `json:"severity,omitempty"`
+
+	// Category is the OpsItem category, e.g. Availability
+	Category string `json:"category,omitempty"`
+
+	// NotificationTopics are SNS topic ARNs notified when an OpsItem is created or changed
+	NotificationTopics []string `json:"notificationTopics,omitempty"`
+}
+
+// DefaultPath returns the default configuration file location,
+// e.g. ~/.config/cfnrc/config.json on Linux
+func DefaultPath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to determine config directory: %w", err)
+	}
+	return filepath.Join(dir, "cfnrc", configFileName), nil
+}
+
+// Load reads the configuration file at path.
+// If path is empty, the default location is used and a missing file yields an empty configuration.
+// An explicitly given path must exist.
+func Load(path string) (*Config, error) {
+	explicit := path != ""
+	if !explicit {
+		var err error
+		path, err = DefaultPath()
+		if err != nil {
+			return &Config{}, nil
+		}
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if !explicit && errors.Is(err, fs.ErrNotExist) {
+			return &Config{}, nil
+		}
+		return nil, fmt.Errorf("failed to read config file '%s': %w", path, err)
+	}
+
+	cfg := &Config{}
+	if err := json.Unmarshal(data, cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse config file '%s': %w", path, err)
+	}
+
+	return cfg, nil
+}
@@ -0,0 +1,273 @@
+// Package sink delivers formatted reports to their destinations: the terminal, files, S3 objects,
+// webhooks, Slack channels and SNS topics. One run can deliver the report to several sinks, each in
+// its own format.
+package sink
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+
+	"cfn-root-cause/awserrors"
+	"cfn-root-cause/webhook"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/sns"
+)
+
+// Kinds of destinations
+const (
+	KindTerminal = "terminal"
+	KindFile     = "file"
+	KindS3       = "s3"
+	KindWebhook  = "webhook"
+	KindSlack    = "slack"
+	KindSNS      = "sns"
+)
+
+// slackPrefix marks Slack incoming webhook URLs, e.g. slack:https://hooks.slack.com/services/...
+const slackPrefix = "slack:"
+
+// snsMessageLimit is the maximum size of an SNS message in bytes
+const snsMessageLimit = 256 * 1024
+
+// requestTimeout limits how long a Slack delivery may take
+const requestTimeout = 30 * time.Second
+
+// formatPattern matches the format prefix of a sink specification
+var formatPattern = regexp.MustCompile(`^([a-z-]+)=(.+)$`)
+
+// Spec configures a sink: the report format it receives and its destination
+type Spec struct {
+	// Format is the report format; empty means the default format of the destination
+	Format string
+
+	// Destination is - for the terminal, a file path, s3://bucket/key, an https:// webhook URL,
+	// slack:<incoming webhook URL> or an SNS topic ARN
+	Destination string
+}
+
+// ParseSpec parses a sink specification of the form [format=]destination,
+// e.g. json=s3://bucket/reports/report.json
+func ParseSpec(value string) (Spec, error) {
+	spec := Spec{Destination: value}
+	if match := formatPattern.FindStringSubmatch(value); match != nil {
+		spec = Spec{Format: match[1], Destination: match[2]}
+	}
+	if spec.Destination == "" {
+		return Spec{}, fmt.Errorf("invalid sink '%s': missing destination", value)
+	}
+	if _, err := spec.Kind(); err != nil {
+		return Spec{}, err
+	}
+	return spec, nil
+}
+
+// Kind returns the kind of the destination
+func (s Spec) Kind() (string, error) {
+	switch {
+	case s.Destination == "-":
+		return KindTerminal, nil
+	case strings.HasPrefix(s.Destination, "s3://"):
+		return KindS3, nil
+	case strings.HasPrefix(s.Destination, slackPrefix):
+		return KindSlack, nil
+	case strings.HasPrefix(s.Destination, "arn:") && strings.Contains(s.Destination, ":sns:"):
+		return KindSNS, nil
+	case strings.HasPrefix(s.Destination, "https://"):
+		return KindWebhook, nil
+	case strings.Contains(s.Destination, "://"):
+		return "", fmt.Errorf("unsupported sink '%s': must be -, a file, s3://bucket/key, an https:// webhook URL, slack:<webhook URL> or an SNS topic ARN", s.Destination)
+	}
+	return KindFile, nil
+}
+
+// Local reports whether the sink writes to this machine: the terminal or a file
+func (s Spec) Local() bool {
+	kind, _ := s.Kind()
+	return kind == KindTerminal || kind == KindFile
+}
+
+// Sink is a destination of the report
+type Sink interface {
+	// Write delivers the formatted report
+	Write(ctx context.Context, report string) error
+
+	// String describes the destination in progress messages
+	String() string
+}
+
+// New creates the sink of a specification. AWS sinks use the given configuration; webhook
+// requests are signed with the secret if it is not empty.
+func New(cfg aws.Config, spec Spec, webhookSecret string) (Sink, error) {
+	kind, err := spec.Kind()
+	if err != nil {
+		return nil, err
+	}
+
+	switch kind {
+	case KindTerminal:
+		return terminalSink{}, nil
+	case KindS3:
+		bucket, key, _ := strings.Cut(strings.TrimPrefix(spec.Destination, "s3://"), "/")
+		if bucket == "" || key == "" || strings.HasSuffix(key, "/") {
+			return nil, fmt.Errorf("invalid sink '%s': must be s3://bucket/key", spec.Destination)
+		}
+		return &s3Sink{client: s3.NewFromConfig(cfg), bucket: bucket, key: key}, nil
+	case KindWebhook:
+		client, err := webhook.NewClient(spec.Destination, webhookSecret)
+		if err != nil {
+			return nil, err
+		}
+		return &webhookSink{client: client, url: spec.Destination}, nil
+	case KindSlack:
+		url := strings.TrimPrefix(spec.Destination, slackPrefix)
+		if err := webhook.ValidateURL(url); err != nil {
+			return nil, err
+		}
+		return &slackSink{url: url, http: &http.Client{Timeout: requestTimeout}}, nil
+	case KindSNS:
+		return &snsSink{client: sns.NewFromConfig(cfg), topicArn: spec.Destination}, nil
+	}
+	return fileSink{path: spec.Destination}, nil
+}
+
+// terminalSink prints the report to stdout
+type terminalSink struct{}
+
+func (terminalSink) Write(ctx context.Context, report string) error {
+	fmt.Print(report)
+	return nil
+}
+
+func (terminalSink) String() string {
+	return "stdout"
+}
+
+// fileSink writes the report to a local file
+type fileSink struct {
+	path string
+}
+
+func (s fileSink) Write(ctx context.Context, report string) error {
+	if err := os.WriteFile(s.path, []byte(report), 0o644); err != nil {
+		return fmt.Errorf("failed to write report to '%s': %w", s.path, err)
+	}
+	return nil
+}
+
+func (s fileSink) String() string {
+	return s.path
+}
+
+// s3Sink uploads the report as an S3 object; its content type follows the extension of the key
+type s3Sink struct {
+	client *s3.Client
+	bucket string
+	key    string
+}
+
+func (s *s3Sink) Write(ctx context.Context, report string) error {
+	contentType := mime.TypeByExtension(path.Ext(s.key))
+	if contentType == "" {
+		contentType = "text/plain; charset=utf-8"
+	}
+
+	_, err := s.client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:      aws.String(s.bucket),
+		Key:         aws.String(s.key),
+		Body:        strings.NewReader(report),
+		ContentType: aws.String(contentType),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to upload report to %s: %w", s, awserrors.ParseAWSError(err, "S3"))
+	}
+	return nil
+}
+
+func (s *s3Sink) String() string {
+	return "s3://" + s.bucket + "/" + s.key
+}
+
+// webhookSink posts the report to an HTTPS endpoint, signed if a secret is configured
+type webhookSink struct {
+	client *webhook.Client
+	url    string
+}
+
+func (s *webhookSink) Write(ctx context.Context, report string) error {
+	return s.client.Post(ctx, []byte(report))
+}
+
+func (s *webhookSink) String() string {
+	return s.url
+}
+
+// slackSink posts the report as a preformatted message to a Slack incoming webhook
+type slackSink struct {
+	url  string
+	http *http.Client
+}
+
+func (s *slackSink) Write(ctx context.Context, report string) error {
+	body, err := json.Marshal(map[string]string{"text": "```\n" + strings.TrimRight(report, "\n") + "\n```"})
+	if err != nil {
+		return fmt.Errorf("failed to encode Slack message: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create Slack request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "cfn-analyzer")
+
+	resp, err := s.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to post report to Slack: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("failed to post report to Slack: %s", resp.Status)
+	}
+	return nil
+}
+
+func (s *slackSink) String() string {
+	return "Slack"
+}
+
+// snsSink publishes the report to an SNS topic
+type snsSink struct {
+	client   *sns.Client
+	topicArn string
+}
+
+func (s *snsSink) Write(ctx context.Context, report string) error {
+	if len(report) > snsMessageLimit {
+		return fmt.Errorf("report of %d bytes exceeds the SNS message limit of %d bytes; use the compact format", len(report), snsMessageLimit)
+	}
+
+	_, err := s.client.Publish(ctx, &sns.PublishInput{
+		TopicArn: aws.String(s.topicArn),
+		Subject:  aws.String("CloudFormation failure analysis"),
+		Message:  aws.String(report),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to publish report to %s: %w", s.topicArn, awserrors.ParseAWSError(err, "SNS"))
+	}
+	return nil
+}
+
+func (s *snsSink) String() string {
+	return s.topicArn
+}
@@ -0,0 +1,117 @@
+// Package sorter orders correlated errors for display in the report
+package sorter
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"cfn-root-cause/analyzer"
+)
+
+// Sort keys supported by SortErrors
+const (
+	SortNone     = ""
+	SortTime     = "time"
+	SortResource = "resource"
+	SortSeverity = "severity"
+	SortService  = "service"
+)
+
+// sortKeys lists the selectable sort keys in the order they are documented
+var sortKeys = []string{SortTime, SortResource, SortSeverity, SortService}
+
+// Severity ranks returned by Severity; lower ranks are more severe
+const (
+	SeverityRootCause = iota
+	SeverityCleanup
+	SeverityCancelled
+)
+
+// cancellationPatterns identify follow-up failures caused by another resource failing
+var cancellationPatterns = []string{
+	"resource creation cancelled",
+	"resource update cancelled",
+	"resource deletion cancelled",
+}
+
+// SortKeys returns the names of the selectable sort keys
+func SortKeys() []string {
+	return append([]string(nil), sortKeys...)
+}
+
+// IsValidSortKey checks if the given key is a supported sort key
+func IsValidSortKey(key string) bool {
+	if key == SortNone {
+		return true
+	}
+	for _, k := range sortKeys {
+		if k == key {
+			return true
+		}
+	}
+	return false
+}
+
+// SortErrors orders errors in place by the given key.
+// Errors that compare equal are ordered chronologically; SortNone keeps the extraction order.
+func SortErrors(errors []analyzer.CorrelatedError, key string) error {
+	var compare func(a, b analyzer.CorrelatedError) int
+
+	switch key {
+	case SortNone:
+		return nil
+	case SortTime:
+		compare = func(a, b analyzer.CorrelatedError) int { return 0 }
+	case SortResource:
+		compare = func(a, b analyzer.CorrelatedError) int {
+			return strings.Compare(a.StackError.LogicalResourceId, b.StackError.LogicalResourceId)
+		}
+	case SortSeverity:
+		compare = func(a, b analyzer.CorrelatedError) int {
+			return Severity(a) - Severity(b)
+		}
+	case SortService:
+		compare = func(a, b analyzer.CorrelatedError) int {
+			return strings.Compare(Service(a.StackError.ResourceType), Service(b.StackError.ResourceType))
+		}
+	default:
+		return fmt.Errorf("unknown sort key '%s': must be one of %s", key, strings.Join(sortKeys, ", "))
+	}
+
+	sort.SliceStable(errors, func(i, j int) bool {
+		if c := compare(errors[i], errors[j]); c != 0 {
+			return c < 0
+		}
+		return errors[i].StackError.Timestamp.Before(errors[j].StackError.Timestamp)
+	})
+
+	return nil
+}
+
+// Severity ranks an error: failures of the triggering operation come first, followed by
+// failures while deleting or rolling back, then cancellations caused by other failures
+func Severity(err analyzer.CorrelatedError) int {
+	reason := strings.ToLower(err.StackError.ResourceStatusReason)
+	for _, pattern := range cancellationPatterns {
+		if strings.Contains(reason, pattern) {
+			return SeverityCancelled
+		}
+	}
+
+	status := err.StackError.ResourceStatus
+	if strings.HasPrefix(status, "DELETE_") || strings.Contains(status, "ROLLBACK") {
+		return SeverityCleanup
+	}
+
+	return SeverityRootCause
+}
+
+// Service returns the service part of a resource type, e.g. "IAM" for "AWS::IAM::Role"
+func Service(resourceType string) string {
+	parts := strings.Split(resourceType, "::")
+	if len(parts) < 2 {
+		return resourceType
+	}
+	return parts[1]
+}
@@ -0,0 +1,77 @@
+// Package synthetic generates stack histories and CloudTrail events for benchmarks of the
+// analysis of very large stacks. The histories are generated from the given random source, so
+// runs with the same seed are comparable across changes.
+package synthetic
+
+import (
+	"fmt"
+	"math/rand"
+	"time"
+
+	"cfn-root-cause/analyzer"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation/types"
+)
+
+// Start is the time the generated stack histories begin
+var Start = time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+
+// resourceTypes and eventSources are the services the synthetic resources and API calls belong to
+var (
+	resourceTypes = []string{"AWS::Lambda::Function", "AWS::S3::Bucket", "AWS::IAM::Role", "AWS::SQS::Queue", "AWS::DynamoDB::Table"}
+	eventSources  = []string{"lambda.amazonaws.com", "s3.amazonaws.com", "iam.amazonaws.com", "sqs.amazonaws.com", "dynamodb.amazonaws.com"}
+)
+
+// StackEvents returns a stack history of n events, newest first like DescribeStackEvents,
+// spread over one day with failures at the given rate
+func StackEvents(random *rand.Rand, start time.Time, n int, failureRate float64) []types.StackEvent {
+	events := make([]types.StackEvent, n)
+	for i := range events {
+		kind := random.Intn(len(resourceTypes))
+		status := types.ResourceStatusCreateComplete
+		reason := ""
+		if random.Float64() < failureRate {
+			status = types.ResourceStatusCreateFailed
+			reason = "Resource handler returned message: \"Access denied\" (Service: 400, HandlerErrorCode: AccessDenied)"
+			if random.Intn(2) == 0 {
+				reason = "GeneralServiceException"
+			}
+		}
+		timestamp := start.Add(time.Duration(n-i) * 8 * time.Second)
+		events[i] = types.StackEvent{
+			EventId:              aws.String(fmt.Sprintf("event-%d", i)),
+			StackName:            aws.String("benchmark"),
+			StackId:              aws.String("arn:aws:cloudformation:us-east-1:123456789012:stack/benchmark/1"),
+			LogicalResourceId:    aws.String(fmt.Sprintf("Resource%d", i%2000)),
+			PhysicalResourceId:   aws.String(fmt.Sprintf("benchmark-resource-%d", i%2000)),
+			ResourceType:         aws.String(resourceTypes[kind]),
+			ResourceStatus:       status,
+			ResourceStatusReason: aws.String(reason),
+			Timestamp:            aws.Time(timestamp),
+		}
+	}
+	return events
+}
+
+// TrailEvents returns n CloudTrail events in the time range of the stack history; a
+// quarter of them carry error information and mention a resource. stackEvents is the number of
+// events of the stack history, which sets its time range
+func TrailEvents(random *rand.Rand, start time.Time, n, stackEvents int) []analyzer.CloudTrailEvent {
+	events := make([]analyzer.CloudTrailEvent, n)
+	for i := range events {
+		kind := random.Intn(len(eventSources))
+		event := analyzer.CloudTrailEvent{
+			EventTime:   start.Add(time.Duration(random.Intn(stackEvents*8)) * time.Second),
+			EventName:   "CreateResource",
+			EventSource: eventSources[kind],
+			EventID:     fmt.Sprintf("trail-%d", i),
+		}
+		if random.Intn(4) == 0 {
+			event.ErrorCode = "AccessDenied"
+			event.ErrorMessage = fmt.Sprintf("User is not authorized to create resource%d", random.Intn(2000))
+		}
+		events[i] = event
+	}
+	return events
+}
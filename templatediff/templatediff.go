@@ -0,0 +1,215 @@
+// Package templatediff computes line-based unified diffs of CloudFormation templates
+package templatediff
+
+import (
+	"fmt"
+	"strings"
+)
+
+// DefaultContext is the number of unchanged lines shown around each change
+const DefaultContext = 3
+
+// maxCells limits the size of the comparison table; larger changes are shown as a full replacement
+const maxCells = 4_000_000
+
+// opKind is the kind of a diff line
+type opKind int
+
+const (
+	opEqual opKind = iota
+	opDelete
+	opInsert
+)
+
+// op is a single line of the edit script
+type op struct {
+	kind opKind
+	text string
+}
+
+// Unified returns a unified diff turning oldText into newText, or "" if the texts are equal
+func Unified(oldName, newName, oldText, newText string, context int) string {
+	if oldText == newText {
+		return ""
+	}
+
+	ops := diffLines(splitLines(oldText), splitLines(newText))
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("--- %s\n+++ %s\n", oldName, newName))
+	for _, h := range hunks(ops, context) {
+		sb.WriteString(h)
+	}
+
+	return sb.String()
+}
+
+// splitLines splits text into lines without line terminators
+func splitLines(text string) []string {
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	text = strings.TrimSuffix(text, "\n")
+	if text == "" {
+		return nil
+	}
+	return strings.Split(text, "\n")
+}
+
+// diffLines computes an edit script using the longest common subsequence of the lines
+// between the common prefix and suffix
+func diffLines(a, b []string) []op {
+	var ops []op
+
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		ops = append(ops, op{opEqual, a[prefix]})
+		prefix++
+	}
+
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	ops = append(ops, diffMiddle(a[prefix:len(a)-suffix], b[prefix:len(b)-suffix])...)
+
+	for i := len(a) - suffix; i < len(a); i++ {
+		ops = append(ops, op{opEqual, a[i]})
+	}
+
+	return ops
+}
+
+// diffMiddle computes the edit script of two line ranges without a common prefix or suffix
+func diffMiddle(a, b []string) []op {
+	var ops []op
+
+	if len(a)*len(b) > maxCells {
+		for _, line := range a {
+			ops = append(ops, op{opDelete, line})
+		}
+		for _, line := range b {
+			ops = append(ops, op{opInsert, line})
+		}
+		return ops
+	}
+
+	// lcs[i][j] is the length of the longest common subsequence of a[i:] and b[j:]
+	lcs := make([][]int32, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int32, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] == b[j]:
+			ops = append(ops, op{opEqual, a[i]})
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			ops = append(ops, op{opDelete, a[i]})
+			i++
+		default:
+			ops = append(ops, op{opInsert, b[j]})
+			j++
+		}
+	}
+	for ; i < len(a); i++ {
+		ops = append(ops, op{opDelete, a[i]})
+	}
+	for ; j < len(b); j++ {
+		ops = append(ops, op{opInsert, b[j]})
+	}
+
+	return ops
+}
+
+// hunks groups the edit script into unified diff hunks with the given number of context lines
+func hunks(ops []op, context int) []string {
+	var result []string
+
+	// Line numbers (1-based) in the old and new text for each op
+	oldLine := make([]int, len(ops))
+	newLine := make([]int, len(ops))
+	o, n := 1, 1
+	for k, e := range ops {
+		oldLine[k], newLine[k] = o, n
+		if e.kind != opInsert {
+			o++
+		}
+		if e.kind != opDelete {
+			n++
+		}
+	}
+
+	for k := 0; k < len(ops); {
+		if ops[k].kind == opEqual {
+			k++
+			continue
+		}
+
+		// Extend the hunk while changes are separated by at most 2*context unchanged lines
+		start := max(0, k-context)
+		end := k
+		for end < len(ops) {
+			if ops[end].kind != opEqual {
+				end++
+				continue
+			}
+			run := end
+			for run < len(ops) && ops[run].kind == opEqual {
+				run++
+			}
+			if run == len(ops) || run-end > 2*context {
+				end = min(len(ops), end+context)
+				break
+			}
+			end = run
+		}
+
+		var sb strings.Builder
+		oldCount, newCount := 0, 0
+		for _, e := range ops[start:end] {
+			switch e.kind {
+			case opEqual:
+				sb.WriteString(" " + e.text + "\n")
+				oldCount++
+				newCount++
+			case opDelete:
+				sb.WriteString("-" + e.text + "\n")
+				oldCount++
+			case opInsert:
+				sb.WriteString("+" + e.text + "\n")
+				newCount++
+			}
+		}
+
+		result = append(result, fmt.Sprintf("@@ -%s +%s @@\n", hunkRange(oldLine[start], oldCount),
+			hunkRange(newLine[start], newCount))+sb.String())
+		k = end
+	}
+
+	return result
+}
+
+// hunkRange formats the start and length of a hunk range
+func hunkRange(start, count int) string {
+	if count == 0 {
+		return fmt.Sprintf("%d,0", start-1)
+	}
+	if count == 1 {
+		return fmt.Sprintf("%d", start)
+	}
+	return fmt.Sprintf("%d,%d", start, count)
+}
@@ -6,6 +6,8 @@ import (
 	"errors"
 	"fmt"
 	"regexp"
+	"slices"
+	"sort"
 	"strings"
 	"time"
 
@@ -68,9 +70,17 @@ type CloudFormationClient interface {
 // Returns nil if the stack exists, or an error if it doesn't or if there's an API error
 // Requirements: 6.4
 func ValidateStackExists(ctx context.Context, client CloudFormationClient, stackName string) error {
+	_, err := DescribeStack(ctx, client, stackName)
+	return err
+}
+
+// DescribeStack validates the stack name and returns the description of the stack, with its status,
+// service role and creation time, so later phases need not describe it again. It returns an error
+// wrapping ErrStackNotFound if the stack does not exist.
+func DescribeStack(ctx context.Context, client CloudFormationClient, stackName string) (*types.Stack, error) {
 	// First validate the format
 	if err := ValidateStackName(stackName); err != nil {
-		return err
+		return nil, err
 	}
 
 	// Check if stack exists via AWS API
@@ -82,18 +92,18 @@ func ValidateStackExists(ctx context.Context, client CloudFormationClient, stack
 	if err != nil {
 		// Check if it's a "stack not found" error
 		if isStackNotFoundError(err) {
-			return fmt.Errorf("%w: stack '%s' does not exist in your AWS account", ErrStackNotFound, stackName)
+			return nil, fmt.Errorf("%w: stack '%s' does not exist in your AWS account", ErrStackNotFound, stackName)
 		}
 		// Parse and return user-friendly error message for other AWS errors
 		awsErr := awserrors.ParseAWSError(err, "CloudFormation")
-		return fmt.Errorf("failed to describe stack: %w", awsErr)
+		return nil, fmt.Errorf("failed to describe stack: %w", awsErr)
 	}
 
 	if len(output.Stacks) == 0 {
-		return fmt.Errorf("%w: stack '%s' does not exist in your AWS account", ErrStackNotFound, stackName)
+		return nil, fmt.Errorf("%w: stack '%s' does not exist in your AWS account", ErrStackNotFound, stackName)
 	}
 
-	return nil
+	return &output.Stacks[0], nil
 }
 
 // isStackNotFoundError checks if the error indicates a stack was not found
@@ -112,26 +122,50 @@ func isStackNotFoundError(err error) bool {
 // It returns the stack name of the stack with the most recent LastUpdatedTime or CreationTime
 // Requirements: 6.4
 func GetLatestStack(ctx context.Context, client CloudFormationClient) (string, error) {
-	// Define stack statuses to include - we want active stacks that could have errors
-	statusFilters := []types.StackStatus{
-		types.StackStatusCreateComplete,
-		types.StackStatusCreateFailed,
-		types.StackStatusCreateInProgress,
-		types.StackStatusDeleteFailed,
-		types.StackStatusDeleteInProgress,
-		types.StackStatusRollbackComplete,
-		types.StackStatusRollbackFailed,
-		types.StackStatusRollbackInProgress,
-		types.StackStatusUpdateComplete,
-		types.StackStatusUpdateFailed,
-		types.StackStatusUpdateInProgress,
-		types.StackStatusUpdateRollbackComplete,
-		types.StackStatusUpdateRollbackFailed,
-		types.StackStatusUpdateRollbackInProgress,
+	candidates, err := LatestStacks(ctx, client, nil, 1)
+	if err != nil {
+		return "", err
 	}
+	return candidates[0].StackName, nil
+}
+
+// ActiveStackStatuses are the statuses of stacks that still exist and could have errors, the stacks
+// considered when no stack name is given
+var ActiveStackStatuses = []types.StackStatus{
+	types.StackStatusCreateComplete,
+	types.StackStatusCreateFailed,
+	types.StackStatusCreateInProgress,
+	types.StackStatusDeleteFailed,
+	types.StackStatusDeleteInProgress,
+	types.StackStatusRollbackComplete,
+	types.StackStatusRollbackFailed,
+	types.StackStatusRollbackInProgress,
+	types.StackStatusUpdateComplete,
+	types.StackStatusUpdateFailed,
+	types.StackStatusUpdateInProgress,
+	types.StackStatusUpdateRollbackComplete,
+	types.StackStatusUpdateRollbackFailed,
+	types.StackStatusUpdateRollbackInProgress,
+}
+
+// StackCandidate is a stack that may be the one to analyze when no stack name is given
+type StackCandidate struct {
+	StackName string
+	Status    types.StackStatus
+
+	// UpdatedTime is the LastUpdatedTime of the stack, or its CreationTime if it was never updated
+	UpdatedTime time.Time
+}
 
-	var latestStackName string
-	var latestTime time.Time
+// LatestStacks returns up to limit stacks in one of the given statuses, the most recently updated
+// first, by their LastUpdatedTime or CreationTime. No statuses select all stacks that still exist
+// and could have errors. It returns ErrNoStacksFound if there are none.
+func LatestStacks(ctx context.Context, client CloudFormationClient, statuses []types.StackStatus, limit int) ([]StackCandidate, error) {
+	statusFilters := statuses
+	if len(statusFilters) == 0 {
+		statusFilters = ActiveStackStatuses
+	}
+	var candidates []StackCandidate
 	var nextToken *string
 
 	for {
@@ -144,7 +178,7 @@ func GetLatestStack(ctx context.Context, client CloudFormationClient) (string, e
 		if err != nil {
 			// Parse and return user-friendly error message for AWS errors
 			awsErr := awserrors.ParseAWSError(err, "CloudFormation")
-			return "", fmt.Errorf("failed to list CloudFormation stacks: %w", awsErr)
+			return nil, fmt.Errorf("failed to list CloudFormation stacks: %w", awsErr)
 		}
 
 		for _, summary := range output.StackSummaries {
@@ -158,11 +192,12 @@ func GetLatestStack(ctx context.Context, client CloudFormationClient) (string, e
 				continue
 			}
 
-			if stackTime.After(latestTime) {
-				latestTime = stackTime
-				if summary.StackName != nil {
-					latestStackName = *summary.StackName
-				}
+			if summary.StackName != nil {
+				candidates = append(candidates, StackCandidate{
+					StackName:   *summary.StackName,
+					Status:      summary.StackStatus,
+					UpdatedTime: stackTime,
+				})
 			}
 		}
 
@@ -172,9 +207,42 @@ func GetLatestStack(ctx context.Context, client CloudFormationClient) (string, e
 		nextToken = output.NextToken
 	}
 
-	if latestStackName == "" {
-		return "", ErrNoStacksFound
+	if len(candidates) == 0 {
+		return nil, ErrNoStacksFound
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].UpdatedTime.After(candidates[j].UpdatedTime)
+	})
+	if limit > 0 && len(candidates) > limit {
+		candidates = candidates[:limit]
+	}
+	return candidates, nil
+}
+
+// ParseStackStatuses converts stack status names such as review_in_progress, compared
+// case-insensitively, to stack statuses
+func ParseStackStatuses(names []string) ([]types.StackStatus, error) {
+	known := types.StackStatus("").Values()
+	statuses := make([]types.StackStatus, 0, len(names))
+	for _, name := range names {
+		status := types.StackStatus(strings.ToUpper(name))
+		if !slices.Contains(known, status) {
+			return nil, fmt.Errorf("unknown stack status '%s'", name)
+		}
+		statuses = append(statuses, status)
 	}
+	return statuses, nil
+}
 
-	return latestStackName, nil
+// SelectStackStatuses adds the included statuses to the base statuses and removes the excluded ones,
+// keeping the order of the base statuses
+func SelectStackStatuses(base, include, exclude []types.StackStatus) []types.StackStatus {
+	var selected []types.StackStatus
+	for _, status := range append(slices.Clone(base), include...) {
+		if !slices.Contains(exclude, status) && !slices.Contains(selected, status) {
+			selected = append(selected, status)
+		}
+	}
+	return selected
 }
@@ -0,0 +1,183 @@
+// Package vpcquota counts the VPC resources of the account and reads the quotas that limit them
+package vpcquota
+
+import (
+	"context"
+	"fmt"
+
+	"cfn-root-cause/analyzer"
+	"cfn-root-cause/awserrors"
+	"cfn-root-cause/metrics"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/aws/aws-sdk-go-v2/service/servicequotas"
+)
+
+// quota is a Service Quotas quota of VPC resources and how to count the resources it limits
+type quota struct {
+	resource     string
+	serviceCode  string
+	quotaCode    string
+	defaultValue int
+
+	// count returns the resources in use by scope, the region or an Availability Zone
+	count func(c *Client, ctx context.Context) (map[string]int, error)
+}
+
+// quotas are the quotas of VPC resources by the EC2 error code of a request exceeding them
+var quotas = map[string]quota{
+	"AddressLimitExceeded":          {"Elastic IP addresses", "ec2", "L-0263D0A3", 5, (*Client).countAddresses},
+	"NatGatewayLimitExceeded":       {"NAT gateways", "vpc", "L-FE5A380F", 5, (*Client).countNatGateways},
+	"VpcLimitExceeded":              {"VPCs", "vpc", "L-F678F1CE", 5, (*Client).countVpcs},
+	"InternetGatewayLimitExceeded":  {"internet gateways", "vpc", "L-A4707A72", 5, (*Client).countInternetGateways},
+	"NetworkInterfaceLimitExceeded": {"network interfaces", "vpc", "L-DF5E4CA3", 5000, (*Client).countNetworkInterfaces},
+}
+
+// Client wraps the EC2 and Service Quotas clients
+type Client struct {
+	ec2           *ec2.Client
+	servicequotas *servicequotas.Client
+	region        string
+}
+
+// NewClientWithConfig creates a new client with a custom AWS config
+func NewClientWithConfig(cfg aws.Config) *Client {
+	return &Client{
+		ec2:           ec2.NewFromConfig(cfg),
+		servicequotas: servicequotas.NewFromConfig(cfg),
+		region:        cfg.Region,
+	}
+}
+
+// Usage returns the resources in use and the quota for an EC2 limit error code such as
+// AddressLimitExceeded; nil for codes of other quotas. For quotas per Availability Zone, the
+// zone with the most resources is returned. If Service Quotas cannot be read, the AWS default
+// of the quota is used.
+func (c *Client) Usage(ctx context.Context, errorCode string) (*analyzer.ResourceUsage, error) {
+	q, ok := quotas[errorCode]
+	if !ok {
+		return nil, nil
+	}
+
+	counts, err := q.count(c, ctx)
+	if err != nil {
+		if awserrors.IsThrottlingError(err) {
+			metrics.ThrottlesTotal.Inc("EC2")
+		}
+		return nil, fmt.Errorf("failed to count %s: %w", q.resource, awserrors.ParseAWSError(err, "EC2"))
+	}
+
+	usage := &analyzer.ResourceUsage{
+		Resource:    q.resource,
+		Scope:       c.region,
+		ServiceCode: q.serviceCode,
+		QuotaCode:   q.quotaCode,
+	}
+	for scope, count := range counts {
+		if count > usage.Used || (count == usage.Used && scope < usage.Scope) {
+			usage.Scope, usage.Used = scope, count
+		}
+	}
+
+	output, err := c.servicequotas.GetServiceQuota(ctx, &servicequotas.GetServiceQuotaInput{
+		ServiceCode: aws.String(q.serviceCode),
+		QuotaCode:   aws.String(q.quotaCode),
+	})
+	if err == nil && output.Quota != nil && output.Quota.Value != nil {
+		usage.Quota = int(*output.Quota.Value)
+	} else {
+		usage.Quota, usage.DefaultQuota = q.defaultValue, true
+	}
+	return usage, nil
+}
+
+// countAddresses counts the Elastic IP addresses of the region
+func (c *Client) countAddresses(ctx context.Context) (map[string]int, error) {
+	output, err := c.ec2.DescribeAddresses(ctx, &ec2.DescribeAddressesInput{
+		Filters: []ec2types.Filter{{Name: aws.String("domain"), Values: []string{"vpc"}}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return map[string]int{c.region: len(output.Addresses)}, nil
+}
+
+// countNatGateways counts the NAT gateways that are pending or available, by Availability Zone
+func (c *Client) countNatGateways(ctx context.Context) (map[string]int, error) {
+	paginator := ec2.NewDescribeNatGatewaysPaginator(c.ec2, &ec2.DescribeNatGatewaysInput{
+		Filter: []ec2types.Filter{{Name: aws.String("state"), Values: []string{"pending", "available"}}},
+	})
+	perSubnet := make(map[string]int)
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, gateway := range page.NatGateways {
+			perSubnet[aws.ToString(gateway.SubnetId)]++
+		}
+	}
+	if len(perSubnet) == 0 {
+		return nil, nil
+	}
+
+	subnetIds := make([]string, 0, len(perSubnet))
+	for subnetId := range perSubnet {
+		subnetIds = append(subnetIds, subnetId)
+	}
+	subnets, err := c.ec2.DescribeSubnets(ctx, &ec2.DescribeSubnetsInput{SubnetIds: subnetIds})
+	if err != nil {
+		return nil, err
+	}
+	counts := make(map[string]int)
+	for _, subnet := range subnets.Subnets {
+		counts[aws.ToString(subnet.AvailabilityZone)] += perSubnet[aws.ToString(subnet.SubnetId)]
+	}
+	return counts, nil
+}
+
+// countVpcs counts the VPCs of the region
+func (c *Client) countVpcs(ctx context.Context) (map[string]int, error) {
+	paginator := ec2.NewDescribeVpcsPaginator(c.ec2, &ec2.DescribeVpcsInput{})
+	count := 0
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+		count += len(page.Vpcs)
+	}
+	return map[string]int{c.region: count}, nil
+}
+
+// countInternetGateways counts the internet gateways of the region
+func (c *Client) countInternetGateways(ctx context.Context) (map[string]int, error) {
+	paginator := ec2.NewDescribeInternetGatewaysPaginator(c.ec2, &ec2.DescribeInternetGatewaysInput{})
+	count := 0
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+		count += len(page.InternetGateways)
+	}
+	return map[string]int{c.region: count}, nil
+}
+
+// countNetworkInterfaces counts the network interfaces of the region
+func (c *Client) countNetworkInterfaces(ctx context.Context) (map[string]int, error) {
+	paginator := ec2.NewDescribeNetworkInterfacesPaginator(c.ec2, &ec2.DescribeNetworkInterfacesInput{
+		MaxResults: aws.Int32(1000),
+	})
+	count := 0
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+		count += len(page.NetworkInterfaces)
+	}
+	return map[string]int{c.region: count}, nil
+}
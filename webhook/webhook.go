@@ -0,0 +1,100 @@
+// Package webhook delivers analysis results to HTTPS endpoints
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// SignatureHeader carries the HMAC-SHA256 signature of the request body as "sha256=<hex>"
+const SignatureHeader = "X-Cfnrc-Signature-256"
+
+// TimestampHeader carries the Unix time the request was sent; it is part of the signed content
+const TimestampHeader = "X-Cfnrc-Timestamp"
+
+// SecretEnvVar is the environment variable the signing secret is read from
+const SecretEnvVar = "CFNRC_WEBHOOK_SECRET"
+
+// requestTimeout limits how long a delivery may take
+const requestTimeout = 30 * time.Second
+
+// Client posts payloads to a webhook endpoint
+type Client struct {
+	url    string
+	secret []byte
+	http   *http.Client
+}
+
+// NewClient creates a client for the given HTTPS endpoint.
+// If secret is not empty, requests are signed with HMAC-SHA256.
+func NewClient(endpoint, secret string) (*Client, error) {
+	if err := ValidateURL(endpoint); err != nil {
+		return nil, err
+	}
+
+	return &Client{
+		url:    endpoint,
+		secret: []byte(secret),
+		http:   &http.Client{Timeout: requestTimeout},
+	}, nil
+}
+
+// ValidateURL checks that the endpoint is an absolute HTTPS URL
+func ValidateURL(endpoint string) error {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return fmt.Errorf("invalid webhook URL '%s': %w", endpoint, err)
+	}
+	if u.Scheme != "https" || u.Host == "" {
+		return fmt.Errorf("invalid webhook URL '%s': must be an https:// URL", endpoint)
+	}
+	return nil
+}
+
+// Post sends the JSON payload to the endpoint; any non-2xx response is an error
+func (c *Client) Post(ctx context.Context, payload []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.url, bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("failed to create webhook request: %w", err)
+	}
+
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "cfn-analyzer")
+	req.Header.Set(TimestampHeader, timestamp)
+	if len(c.secret) > 0 {
+		req.Header.Set(SignatureHeader, "sha256="+Sign(c.secret, timestamp, payload))
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to deliver webhook: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("webhook endpoint returned %s: %s", resp.Status, bytes.TrimSpace(body))
+	}
+
+	return nil
+}
+
+// Sign returns the hex-encoded HMAC-SHA256 of "<timestamp>.<payload>".
+// Receivers recompute it with the shared secret and reject stale timestamps to prevent replays.
+func Sign(secret []byte, timestamp string, payload []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(payload)
+	return hex.EncodeToString(mac.Sum(nil))
+}